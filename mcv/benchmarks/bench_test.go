@@ -0,0 +1,225 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/benchgen"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/imgbuild"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+)
+
+// sizes are the synthetic caches every benchmark runs against: many small
+// kernels, and few large ones.
+var sizes = []struct {
+	name string
+	opts benchgen.Options
+}{
+	{"small-kernels", benchgen.Options{Kernels: 100, BinarySize: 16 << 10, Backend: "cuda", Arch: "90", Seed: 1}},
+	{"large-kernels", benchgen.Options{Kernels: 8, BinarySize: 4 << 20, Backend: "cuda", Arch: "90", Seed: 1}},
+}
+
+func TestMain(m *testing.M) {
+	logging.SetLevel(logging.WarnLevel)
+
+	tmp, err := os.MkdirTemp("", "mcv-bench-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Benchmarks measure mcv, not the registry politeness limits.
+	os.Setenv("MCV_REGISTRY_QPS", "0")
+	os.Setenv("MCV_IMAGE_STORE", filepath.Join(tmp, "oci"))
+	os.Setenv("MCV_INVENTORY_FILE", filepath.Join(tmp, "installed.json"))
+	if _, err := config.Initialize(filepath.Join(tmp, "config")); err != nil {
+		log.Fatal(err)
+	}
+	config.SetEnabledGPU(false)
+	config.SetSkipPrecheck(true)
+
+	code := m.Run()
+	os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
+// generate writes the synthetic cache for opts and returns its directory
+// and size.
+func generate(b *testing.B, opts benchgen.Options) (string, int64) {
+	b.Helper()
+	dir := b.TempDir()
+	size, err := benchgen.Generate(dir, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return dir, size
+}
+
+// createImage builds the image for opts into the local store and returns
+// its name and the cache size.
+func createImage(b *testing.B, tag string, opts benchgen.Options) (string, int64) {
+	b.Helper()
+	dir, size := generate(b, opts)
+	imageName := "localhost/mcv-bench/" + tag + ":latest"
+	builder, err := imgbuild.New(imgbuild.BuilderNative, imgbuild.BuildOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := builder.CreateImage(imageName, dir); err != nil {
+		b.Fatal(err)
+	}
+	return imageName, size
+}
+
+func loadImage(b *testing.B, imageName string) v1.Image {
+	b.Helper()
+	img, err := imgstore.Load(imageName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return img
+}
+
+// startRegistry serves an in-memory registry and returns its host.
+func startRegistry(b *testing.B) string {
+	b.Helper()
+	srv := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0))))
+	b.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func BenchmarkCreate(b *testing.B) {
+	for _, s := range sizes {
+		b.Run(s.name, func(b *testing.B) {
+			dir, size := generate(b, s.opts)
+			builder, err := imgbuild.New(imgbuild.BuilderNative, imgbuild.BuildOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := builder.CreateImage("localhost/mcv-bench/create:latest", dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPush(b *testing.B) {
+	for _, s := range sizes {
+		b.Run(s.name, func(b *testing.B) {
+			imageName, size := createImage(b, "push-"+s.name, s.opts)
+			img := loadImage(b, imageName)
+			host := startRegistry(b)
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// A fresh repository each time, so no blob is already present.
+				ref, err := name.ParseReference(fmt.Sprintf("%s/push/%d:latest", host, i), name.Insecure)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := registry.Push(context.Background(), img, ref, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRepush(b *testing.B) {
+	for _, s := range sizes {
+		b.Run(s.name, func(b *testing.B) {
+			imageName, size := createImage(b, "repush-"+s.name, s.opts)
+			img := loadImage(b, imageName)
+			ref, err := name.ParseReference(startRegistry(b)+"/repush:latest", name.Insecure)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := registry.Push(context.Background(), img, ref, nil); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Every blob is already present, so only the manifest is written.
+				if _, err := registry.Push(context.Background(), img, ref, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPull(b *testing.B) {
+	for _, s := range sizes {
+		b.Run(s.name, func(b *testing.B) {
+			imageName, size := createImage(b, "pull-"+s.name, s.opts)
+			ref, err := name.ParseReference(startRegistry(b)+"/pull:latest", name.Insecure)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := remote.Write(ref, loadImage(b, imageName), registry.Options()...); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				img, err := remote.Image(ref, registry.Options()...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				layers, err := img.Layers()
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, l := range layers {
+					rc, err := l.Compressed()
+					if err != nil {
+						b.Fatal(err)
+					}
+					_, err = io.Copy(io.Discard, rc)
+					rc.Close()
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	for _, s := range sizes {
+		b.Run(s.name, func(b *testing.B) {
+			imageName, size := createImage(b, "extract-"+s.name, s.opts)
+			mgr := fetcher.New()
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				constants.ExtractCacheDir = b.TempDir()
+				b.StartTimer()
+				if err := mgr.FetchAndExtractCache(imageName); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
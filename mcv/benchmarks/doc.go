@@ -0,0 +1,8 @@
+// Package benchmarks measures the throughput of creating, pushing, pulling
+// and extracting cache images built from synthetic caches.
+//
+// Run the suite and compare against a baseline with:
+//
+//	make bench
+//	make bench-compare BENCH_BASELINE=old.txt
+package benchmarks
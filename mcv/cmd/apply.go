@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/apply"
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type applyFlags struct {
+	file, statePath  string
+	prune, dryRun    bool
+	baremetal, noGPU bool
+}
+
+func newApplyCommand() *cobra.Command {
+	var f applyFlags
+
+	cmd := &cobra.Command{
+		Use:   "apply -f FILE",
+		Short: "Reconcile the node's caches with a file listing those it should have",
+		Long: `Make the caches extracted on this node match a node caches file, a list of
+the cache images that should be there, each with its own directory:
+extract those not extracted yet, extract again those whose image changed
+or whose tag now refers to another digest, repair those whose extracted
+files were modified or removed, and remove those no longer listed. Files
+the workload added to a cache directory are left alone. What was
+extracted is recorded in --state; run it again at any time, or with
+--dry-run to see what it would do.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logLevel, _ := cmd.Flags().GetString("log-level")
+			runApply(f, logLevel)
+		},
+	}
+	cmd.Flags().StringVarP(&f.file, "file", "f", apply.DefaultFile, "Node caches file")
+	cmd.Flags().StringVar(&f.statePath, "state", constants.ApplyStateFile, "File recording the caches mcv apply extracted")
+	cmd.Flags().BoolVar(&f.prune, "prune", true, "Remove the caches no longer in the file; --prune=false keeps them")
+	cmd.Flags().BoolVar(&f.dryRun, "dry-run", false, "Print what would be done without changing anything")
+	cmd.Flags().BoolVarP(&f.baremetal, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
+	cmd.Flags().BoolVar(&f.noGPU, "no-gpu", false, "Disable GPU logic for testing")
+	return cmd
+}
+
+func loadApplyFile(path string) *apply.File {
+	f, err := apply.Load(path)
+	if err != nil {
+		fail(exitError, err)
+	}
+	for _, c := range f.Caches {
+		if err := validateImageName(c.Image); err != nil {
+			failf(exitError, "Cache %s: %w", c.Name, err)
+		}
+		if c.Kernels != "" {
+			if err := fetcher.ValidateKernelPolicy(c.Kernels); err != nil {
+				failf(exitError, "Cache %s: %w", c.Name, err)
+			}
+		}
+		if c.Expired != "" {
+			if err := fetcher.ValidateExpiredPolicy(c.Expired); err != nil {
+				failf(exitError, "Cache %s: %w", c.Name, err)
+			}
+		}
+	}
+	return f
+}
+
+func runApply(f applyFlags, logLevel string) {
+	file := loadApplyFile(f.file)
+	state, err := apply.LoadState(f.statePath)
+	if err != nil {
+		fail(exitError, err)
+	}
+	steps := apply.Plan(file, state, fetcher.ResolveDigest, f.prune)
+	if f.dryRun {
+		printApplySteps(steps, false)
+		return
+	}
+
+	configureBaremetalAndGPU(f.baremetal, f.noGPU)
+	gpuEnabled := config.IsGPUEnabled()
+	// Extraction turns the precheck off after running it once, so restore
+	// the setting for each cache.
+	skipPrecheck := config.IsSkipPrecheckEnabled()
+	extract := func(c apply.Cache) (string, error) {
+		img, err := fetcher.NewImgFetcher().FetchImg(c.Image)
+		if err != nil {
+			return "", err
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return "", fmt.Errorf("failed to get image digest: %w", err)
+		}
+		constants.ExtractCacheDir = ""
+		opts := client.Options{
+			ImageName:       c.Image,
+			CacheDir:        c.Dir,
+			EnableGPU:       &gpuEnabled,
+			LogLevel:        logLevel,
+			EnableBaremetal: &f.baremetal,
+			SkipPrecheck:    &skipPrecheck,
+			Kernels:         c.Kernels,
+			ExpiredPolicy:   c.Expired,
+		}
+		if _, _, err := client.ExtractCache(opts); err != nil {
+			return "", err
+		}
+		return digest.String(), nil
+	}
+
+	err = apply.Apply(steps, f.statePath, extract)
+	printApplySteps(steps, true)
+	if err != nil {
+		failf(exitError, "apply of %s failed: %w", f.file, err)
+	}
+	logging.Infof("Node caches match %s.", f.file)
+}
+
+// printApplySteps lists the action each cache needs, and with applied
+// whether it was taken.
+func printApplySteps(steps []apply.Step, applied bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CACHE\tIMAGE\tACTION\tRESULT\tDETAIL")
+	for _, s := range steps {
+		result := "-"
+		switch {
+		case !applied || s.Action == apply.ActionNone || s.Action == apply.ActionKeep:
+		case s.Err != nil && s.Optional:
+			result = "failed (optional)"
+		case s.Err != nil:
+			result = "failed"
+		default:
+			result = "done"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Image, s.Action, result, orDash(s.Detail))
+	}
+	w.Flush()
+	for _, s := range steps {
+		if s.Err != nil {
+			fmt.Printf("%s: %v\n", s.Name, s.Err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Command benchgen writes a synthetic Triton cache for benchmarking
+// mcv create and extract.
+package main
+
+import (
+	"os"
+
+	units "github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/benchgen"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	opts := benchgen.DefaultOptions
+	var outDir, binarySize string
+
+	cmd := &cobra.Command{
+		Use:   "benchgen",
+		Short: "Generate a synthetic Triton cache for benchmarks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			size, err := units.RAMInBytes(binarySize)
+			if err != nil {
+				return err
+			}
+			opts.BinarySize = size
+			total, err := benchgen.Generate(outDir, opts)
+			if err != nil {
+				return err
+			}
+			logging.Infof("Wrote %d kernels (%s) to %s", opts.Kernels, units.BytesSize(float64(total)), outDir)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outDir, "dir", "d", "", "Directory to write the cache to")
+	cmd.Flags().IntVar(&opts.Kernels, "kernels", opts.Kernels, "Number of kernels")
+	cmd.Flags().StringVar(&binarySize, "binary-size", units.BytesSize(float64(opts.BinarySize)), "Size of each kernel binary, e.g. 64KiB or 4MiB")
+	cmd.Flags().StringVar(&opts.Backend, "backend", opts.Backend, "Kernel backend: cuda or hip")
+	cmd.Flags().StringVar(&opts.Arch, "arch", opts.Arch, "Target arch, e.g. 90 or gfx942")
+	cmd.Flags().Int64Var(&opts.Seed, "seed", opts.Seed, "Seed for the generated content")
+	_ = cmd.MarkFlagRequired("dir")
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
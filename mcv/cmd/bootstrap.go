@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/bootstrap"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// handleBootstrap installs the running binary as a sysext extension under
+// root. On the running host it also activates the extension, loads the
+// units and installs the SELinux module when the tools are present.
+func handleBootstrap(root string) {
+	self, err := os.Executable()
+	if err != nil {
+		logFatal("Failed to find the mcv binary", err, exitError)
+	}
+	written, err := bootstrap.Install(bootstrap.Options{Root: root, Binary: self})
+	for _, p := range written {
+		logging.Infof("Installed %s", p)
+	}
+	if err != nil {
+		logFatal("Bootstrap failed", err, exitError)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, bootstrap.CacheRoot), 0755); err != nil {
+		logFatal("Bootstrap failed", err, exitError)
+	}
+
+	module := filepath.Join(bootstrap.ExtensionsDir, bootstrap.ExtensionName, "usr/share/selinux/packages/mcv/mcv.cil")
+	steps := [][]string{
+		{"systemd-sysext", "refresh"},
+		{"systemctl", "daemon-reload"},
+		{"semodule", "-i", module},
+		{"restorecon", "-R", bootstrap.CacheRoot},
+	}
+	if root != "/" {
+		fmt.Printf("Installed into %s. On the booted host run:\n", root)
+		for _, s := range steps {
+			fmt.Println(" ", strings.Join(s, " "))
+		}
+		return
+	}
+	for _, s := range steps {
+		if _, err := exec.LookPath(s[0]); err != nil {
+			diag.Warnf(diag.BootstrapStepSkipped, "Skipping %q: %s not found", strings.Join(s, " "), s[0])
+			continue
+		}
+		if out, err := exec.Command(s[0], s[1:]...).CombinedOutput(); err != nil {
+			failf(exitError, "%s failed: %v: %s", strings.Join(s, " "), err, out)
+		}
+	}
+	fmt.Printf("mcv is installed. Put the caches to extract at boot in %s/mcv-compose.yaml.\n", bootstrap.ConfigDir)
+}
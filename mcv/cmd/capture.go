@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/capture"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCaptureCommand() *cobra.Command {
+	var cacheDir, output string
+	var duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "capture -d DIR [-- COMMAND [ARGS...]]",
+		Short: "Record the kernel cache entries a canary run compiles or loads",
+		Long: `Watch a kernel cache directory while a canary workload runs and record
+the kernels it compiles or loads. The capture ends when COMMAND exits, or
+without a command after --duration or on interrupt. Package only the
+recorded entries with mcv --create --filter-from.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runCapture(cacheDir, output, duration, args)
+		},
+	}
+	cmd.Flags().StringVarP(&cacheDir, "dir", "d", "", "Kernel cache directory to watch, e.g. ~/.triton/cache")
+	cmd.Flags().StringVarP(&output, "output", "o", "capture.json", "File to write the capture to")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Stop capturing after this long (default: until interrupted or COMMAND exits)")
+	_ = cmd.MarkFlagRequired("dir")
+	return cmd
+}
+
+func runCapture(cacheDir, output string, duration time.Duration, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		c   *capture.Capture
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := capture.Watch(ctx, cacheDir)
+		done <- result{c, err}
+	}()
+
+	exitCode := 0
+	if len(args) > 0 {
+		// Give the watches a moment to be in place before the workload starts.
+		time.Sleep(500 * time.Millisecond)
+		run := exec.CommandContext(ctx, args[0], args[1:]...)
+		run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+		err := run.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			diag.Warnf(diag.CapturedCommandFail, "%s exited with status %d", args[0], exitErr.ExitCode())
+			exitCode = exitError
+		case err != nil:
+			logging.Errorf("Failed to run %s: %v", args[0], err)
+			exitCode = exitError
+		}
+		cancel()
+	}
+
+	res := <-done
+	if res.err != nil {
+		fail(exitError, res.err)
+	}
+	if err := res.c.Save(output); err != nil {
+		fail(exitError, err)
+	}
+	compiled, used := 0, 0
+	for _, e := range res.c.Entries {
+		if e.Compiled {
+			compiled++
+		}
+		if e.Used {
+			used++
+		}
+	}
+	logging.Infof("Captured %d cache entries (%d compiled, %d loaded) to %s", len(res.c.Entries), compiled, used, output)
+	os.Exit(exitCode)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCleanupCommand() *cobra.Command {
+	var kept, dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove temporary build and extract files",
+		Long: `Remove the build contexts, fetched images and other temporary files MCV
+leaves in ` + constants.MCVBuildDir + ` when a run fails or is killed. Nothing
+is removed while another mcv process is using them. With --kept, also
+remove the files --keep-temp kept in ` + constants.MCVDebugDir + `, except
+those of runs still in progress.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runCleanup(kept, dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&kept, "kept", false, "Also remove the files kept with --keep-temp")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List what would be removed without removing it")
+	return cmd
+}
+
+func runCleanup(kept, dryRun bool) {
+	failed := false
+	removed, err := utils.CleanBuildDir(dryRun)
+	if err != nil {
+		if errors.Is(err, utils.ErrBuildDirInUse) {
+			logging.Errorf("Not cleaning up: %v", err)
+		} else {
+			logging.Errorf("Failed to clean up: %v", err)
+		}
+		failed = true
+	}
+	if kept {
+		k, err := utils.CleanKeptTemp(dryRun)
+		if err != nil {
+			logging.Errorf("Failed to remove kept files: %v", err)
+			failed = true
+		}
+		removed = append(removed, k...)
+	}
+	if len(removed) > 0 || !failed {
+		printCleanup(removed, dryRun)
+	}
+	if failed {
+		os.Exit(exitError)
+	}
+}
+
+func printCleanup(removed []utils.TempEntry, dryRun bool) {
+	if len(removed) == 0 {
+		fmt.Println("Nothing to remove.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE")
+	var total int64
+	for _, e := range removed {
+		fmt.Fprintf(w, "%s\t%s\n", e.Path, units.BytesSize(float64(e.Size)))
+		total += e.Size
+	}
+	w.Flush()
+	verb := "Freed"
+	if dryRun {
+		verb = "Would free"
+	}
+	fmt.Printf("\n%s %s.\n", verb, units.BytesSize(float64(total)))
+}
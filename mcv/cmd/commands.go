@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// The subcommands below run what the root command's --create, --extract,
+// --verify-only, --check-compat, --hw-info, --gpu-info and --bootstrap
+// flags do, with only the flags that apply to them. The root flags remain
+// for existing scripts.
+
+func newCreateCommand() *cobra.Command {
+	var imageName, cacheDir string
+	var baremetal, noGPU bool
+	var opts createFlags
+
+	cmd := &cobra.Command{
+		Use:   "create -i IMAGE -d DIR",
+		Short: "Package a Triton or vLLM cache directory as an OCI image",
+		Long: `Package the Triton or vLLM kernel cache in --dir as the OCI image --image,
+labelled with the GPU targets it was built for. Same as mcv --create.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configureBaremetalAndGPU(baremetal, noGPU)
+			if err := validateImageName(imageName); err != nil {
+				fail(exitCreateError, err)
+			}
+			runCreate(imageName, cacheDir, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to create")
+	cmd.Flags().StringVarP(&cacheDir, "dir", "d", "", "Triton/vLLM cache directory to package")
+	cmd.Flags().BoolVarP(&baremetal, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
+	cmd.Flags().BoolVar(&noGPU, "no-gpu", false, "Disable GPU logic for testing")
+	addCreateFlags(cmd, &opts)
+	_ = cmd.MarkFlagRequired("image")
+	_ = cmd.MarkFlagRequired("dir")
+	return cmd
+}
+
+func newExtractCommand(logLevel *string) *cobra.Command {
+	var imageName, cacheDir string
+	var baremetal, noGPU bool
+	var opts extractFlags
+
+	cmd := &cobra.Command{
+		Use:   "extract -i IMAGE [-d DIR]",
+		Short: "Extract a Triton or vLLM cache from an OCI image",
+		Long: `Check that the GPUs can use the cache in --image and extract it to --dir,
+or the runtime's default cache directory. Same as mcv --extract.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if opts.verifyOnly {
+				failf(exitError, "--verify-only cannot be used with extract; use mcv verify")
+			}
+			configureBaremetalAndGPU(baremetal, noGPU)
+			if err := validateImageName(imageName); err != nil {
+				fail(exitError, err)
+			}
+			runExtract(imageName, cacheDir, *logLevel, baremetal, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to extract")
+	cmd.Flags().StringVarP(&cacheDir, "dir", "d", "", "Triton/vLLM cache directory to extract to")
+	cmd.Flags().BoolVarP(&baremetal, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
+	cmd.Flags().BoolVar(&noGPU, "no-gpu", false, "Disable GPU logic for testing")
+	addExtractFlags(cmd, &opts)
+	_ = cmd.Flags().MarkHidden("verify-only")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
+
+func newVerifyCommand(logLevel *string) *cobra.Command {
+	var imageName string
+	var baremetal, noGPU bool
+	var opts extractFlags
+
+	cmd := &cobra.Command{
+		Use:   "verify -i IMAGE",
+		Short: "Check GPU compatibility and the signature of an image without extracting it",
+		Long: `Check that at least one GPU can use --image and that the image passes the
+containers signature policy, and print a JSON report of both steps. Same
+as mcv --verify-only; mcv extract --require-compat also extracts it.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configureBaremetalAndGPU(baremetal, noGPU)
+			if err := validateImageName(imageName); err != nil {
+				fail(exitError, err)
+			}
+			runVerifyAndExtract(imageName, "", *logLevel, baremetal, opts, false)
+		},
+	}
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to verify")
+	cmd.Flags().BoolVarP(&baremetal, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
+	cmd.Flags().BoolVar(&noGPU, "no-gpu", false, "Disable GPU logic for testing")
+	cmd.Flags().StringVar(&opts.signaturePolicy, "signature-policy", "", "Containers policy.json to verify the signature with (default the host's)")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
+
+func newCheckCompatCommand() *cobra.Command {
+	var imageName string
+	var remote remoteCompatFlags
+
+	cmd := &cobra.Command{
+		Use:   "check-compat -i IMAGE [--ssh [USER@]HOST | --fingerprint FILE]",
+		Short: "Check that the GPUs can use an image",
+		Long: `Compare the GPU targets recorded in --image with this host's GPUs, exiting
+non-zero unless at least one GPU can use the image. Same as mcv
+--check-compat.
+
+With --ssh, compare them with the GPUs of another host instead: mcv
+host-report runs there over SSH to list its GPUs, and the image is pulled
+and checked here, so the host needs no access to the registry. With
+--fingerprint, use a report mcv host-report printed on the host earlier,
+for hosts that cannot be reached from here.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := validateImageName(imageName); err != nil {
+				fail(exitLogError, err)
+			}
+			if remote.ssh != "" || remote.fingerprint != "" {
+				handleRemoteCheckCompat(imageName, remote)
+			}
+			handleCheckCompat(imageName)
+		},
+	}
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to check compatibility with")
+	cmd.Flags().StringVar(&remote.ssh, "ssh", "", "Check the GPUs of this [USER@]HOST, reached over SSH, instead of this host's")
+	cmd.Flags().StringArrayVarP(&remote.sshOptions, "ssh-option", "o", nil, "Extra ssh option with --ssh, e.g. Port=2222 (repeatable)")
+	cmd.Flags().StringVar(&remote.mcvPath, "mcv-path", "mcv", "mcv on the --ssh host")
+	cmd.Flags().StringVar(&remote.fingerprint, "fingerprint", "", "Check the GPUs in this mcv host-report output instead of this host's, - for stdin")
+	cmd.MarkFlagsMutuallyExclusive("ssh", "fingerprint")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
+
+// printIndexCompat lists each image of an index with the GPUs that can
+// use it, or why none can.
+func printIndexCompat(results []client.PlatformCompat) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLATFORM\tDIGEST\tTARGETS\tCOMPATIBLE\tGPUS")
+	for _, r := range results {
+		platform := r.Platform
+		if platform == "" {
+			platform = "-"
+		}
+		var targets []string
+		for _, t := range r.Targets {
+			targets = append(targets, t.Backend+":"+t.Arch)
+		}
+		if len(targets) == 0 {
+			targets = []string{"-"}
+		}
+		compatible, gpus := "no", r.Error
+		if r.Compatible() {
+			compatible, gpus = "yes", fmt.Sprint(r.Matched)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", platform, r.Digest, strings.Join(targets, ","), compatible, gpus)
+	}
+	w.Flush()
+}
+
+func newHWInfoCommand() *cobra.Command {
+	var opts hwInfoFlags
+
+	cmd := &cobra.Command{
+		Use:   "hw-info",
+		Short: "Display the host's hardware and GPUs",
+		Long: `Print the host, CPU and accelerator information as tables, and record it
+for mcv hw-diff. Same as mcv --hw-info.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			handleHWInfo(opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.wide, "wide", false, "List every accelerator with full details instead of grouping them")
+	cmd.Flags().StringVar(&opts.record, "record-devices", "", "Also record what the GPU libraries and tools report to this file, for replay with MCV_DEVICE_FIXTURE")
+	return cmd
+}
+
+func newGPUInfoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "gpu-info",
+		Short: "Display a summary of the host's GPUs",
+		Long:  `Print the host's GPUs grouped by type. Same as mcv --gpu-info.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			handleGPUInfo()
+		},
+	}
+}
+
+func newBootstrapCommand() *cobra.Command {
+	var root string
+
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Install mcv as a systemd-sysext extension",
+		Long: `Install mcv as a systemd-sysext extension for image-based OSes such as
+Fedora CoreOS. Same as mcv --bootstrap.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			handleBootstrap(root)
+		},
+	}
+	cmd.Flags().StringVar(&root, "root", "/", "Filesystem root to install into")
+	return cmd
+}
+
+func newInspectCommand() *cobra.Command {
+	var imageName, output string
+
+	cmd := &cobra.Command{
+		Use:   "inspect -i IMAGE",
+		Short: "Show the caches and GPU targets of an image without extracting it",
+		Long: `Print the digest and layers of --image, the caches it holds with their
+entry counts, sizes, cache.<type>.image/* labels and summaries, and the
+GPU targets they were built for. Only the image's manifest and config are
+pulled, and the small metadata layer of a summary too large for a label;
+no cache layer is downloaded or extracted. With -o json, print them with
+the manifest and all the image's labels.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runInspect(imageName, output)
+		},
+	}
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to inspect")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
+
+func runInspect(imageName, output string) {
+	if output != "text" && output != "json" {
+		failf(exitError, "Unknown output format %q: use text or json", output)
+	}
+	if err := validateImageName(imageName); err != nil {
+		fail(exitError, err)
+	}
+	info, err := client.InspectImage(imageName)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			fail(exitError, err)
+		}
+		return
+	}
+	fmt.Printf("Image:   %s\n", info.Image)
+	fmt.Printf("Digest:  %s\n", info.Digest)
+	fmt.Printf("Caches:  %s\n", strings.Join(info.CacheTypes, ", "))
+	if len(info.HostArchs) > 0 {
+		fmt.Printf("CPU:     %s\n", strings.Join(info.HostArchs, ", "))
+	}
+	fmt.Printf("Layers:  %d (%s)\n", len(info.Manifest.Layers), units.HumanSize(float64(info.LayerBytes())))
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CACHE\tENTRIES\tSIZE")
+	for _, c := range info.Caches {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", c.Type, c.Entries, units.HumanSize(float64(c.SizeBytes)))
+	}
+	w.Flush()
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tARCH\tWARP SIZE")
+	for _, t := range info.Targets {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", t.Backend, t.Arch, t.WarpSize)
+	}
+	w.Flush()
+	printInspectLabels(info)
+}
+
+// printInspectLabels prints the mcv and cache labels of info, in key
+// order, then each cache's summary, which is too long for one line.
+func printInspectLabels(info *client.ImageInfo) {
+	labels := maps.Clone(info.MCVLabels)
+	for _, c := range info.Caches {
+		for k, v := range c.Labels {
+			if !strings.HasSuffix(k, "/summary") {
+				labels[k] = v
+			}
+		}
+	}
+	if len(labels) > 0 {
+		fmt.Println()
+		fmt.Println("Labels:")
+		for _, k := range slices.Sorted(maps.Keys(labels)) {
+			fmt.Printf("  %s=%s\n", k, labels[k])
+		}
+	}
+	for _, c := range info.Caches {
+		if len(c.Summary) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, c.Summary, "  ", "  "); err != nil {
+			buf.Reset()
+			buf.Write(c.Summary)
+		}
+		fmt.Printf("\n%s summary:\n  %s\n", c.Type, buf.String())
+	}
+}
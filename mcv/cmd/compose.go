@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/compose"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newComposeCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "compose",
+		Short: "Extract the full set of cache images a model needs",
+		Long: `Manage the cache images listed in a compose file: one image per
+component of a model, such as a draft model, the main model and an
+embedder. Components are extracted after the components they depend on.`,
+	}
+	cmd.PersistentFlags().StringVarP(&file, "file", "f", compose.DefaultFile, "Compose file")
+	cmd.AddCommand(newComposeUpCommand(&file), newComposeStatusCommand(&file))
+	return cmd
+}
+
+func newComposeUpCommand(file *string) *cobra.Command {
+	var baremetalFlag, noGPUFlag, updatePinFlag bool
+
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Verify and extract every component in dependency order",
+		Run: func(cmd *cobra.Command, args []string) {
+			logLevel, _ := cmd.Flags().GetString("log-level")
+			runComposeUp(*file, logLevel, baremetalFlag, noGPUFlag, updatePinFlag)
+		},
+	}
+	cmd.Flags().BoolVarP(&baremetalFlag, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
+	cmd.Flags().BoolVar(&noGPUFlag, "no-gpu", false, "Disable GPU logic for testing")
+	cmd.Flags().BoolVar(&updatePinFlag, "update-pin", false, "With digest pinning, accept and record new digests for the component tags")
+	return cmd
+}
+
+func newComposeStatusCommand(file *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show which components are extracted",
+		Run: func(cmd *cobra.Command, args []string) {
+			runComposeStatus(*file)
+		},
+	}
+}
+
+func loadComposeFile(path string) *compose.File {
+	f, err := compose.Load(path)
+	if err != nil {
+		fail(exitError, err)
+	}
+	for _, c := range f.Components {
+		if err := validateImageName(c.Image); err != nil {
+			failf(exitError, "Component %s: %w", c.Name, err)
+		}
+	}
+	return f
+}
+
+func runComposeUp(path, logLevel string, baremetalFlag, noGPUFlag, updatePinFlag bool) {
+	f := loadComposeFile(path)
+	configureBaremetalAndGPU(baremetalFlag, noGPUFlag)
+
+	gpuEnabled := config.IsGPUEnabled()
+	// Extraction turns the precheck off after running it once, so restore
+	// the setting for each component.
+	skipPrecheck := config.IsSkipPrecheckEnabled()
+	extract := func(c compose.Component) (string, error) {
+		img, err := fetcher.NewImgFetcher().FetchImg(c.Image)
+		if err != nil {
+			return "", err
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return "", fmt.Errorf("failed to get image digest: %w", err)
+		}
+		// Without a dir, each component goes to its runtime's directory.
+		constants.ExtractCacheDir = ""
+		opts := client.Options{
+			ImageName:       c.Image,
+			CacheDir:        c.Dir,
+			EnableGPU:       &gpuEnabled,
+			LogLevel:        logLevel,
+			EnableBaremetal: &baremetalFlag,
+			SkipPrecheck:    &skipPrecheck,
+			UpdatePin:       &updatePinFlag,
+		}
+		if _, _, err := client.ExtractCache(opts); err != nil {
+			return "", err
+		}
+		return digest.String(), nil
+	}
+
+	if err := compose.Up(f, constants.ComposeStateDir, extract); err != nil {
+		failf(exitError, "compose up for %s failed: %w", f.Model, err)
+	}
+	logging.Infof("All %d component(s) of %s extracted.", len(f.Components), f.Model)
+}
+
+func runComposeStatus(path string) {
+	f := loadComposeFile(path)
+	statuses, err := compose.Status(f, constants.ComposeStateDir)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	fmt.Printf("Model: %s\n", f.Model)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tIMAGE\tSTATE\tDIGEST\tEXTRACTED")
+	ready := true
+	for _, s := range statuses {
+		extracted := "-"
+		if !s.ExtractedAt.IsZero() {
+			extracted = s.ExtractedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		digest := s.Digest
+		if digest == "" {
+			digest = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Image, s.State, digest, extracted)
+		ready = ready && s.State == compose.StateExtracted
+	}
+	w.Flush()
+	for _, s := range statuses {
+		if s.Error != "" && s.State != compose.StateExtracted {
+			fmt.Printf("%s: %s\n", s.Name, s.Error)
+		}
+	}
+	if !ready {
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build !mcv_edge
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redhat-et/MCU/mcv/pkg/imgcopy"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCopyCommand() *cobra.Command {
+	var opts imgcopy.Options
+
+	cmd := &cobra.Command{
+		Use:   "copy SRC DST",
+		Short: "Copy a cache image with its signatures and attached artifacts",
+		Long: `Copy a cache image between registries, OCI layout directories and
+skopeo dir: directories, keeping its digest, labels and annotations, its
+signatures, its OCI referrers and the signatures, attestations and SBOMs
+cosign stores next to it. Locations are given as skopeo takes them:
+docker://REF (or just REF), oci:/path[:tag] or dir:/path. Index images are
+copied with all their platforms. The source must pass the host's signature
+policy, as with skopeo copy. Prints the digest of the copied image.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCopy(args[0], args[1], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.SignaturePolicy, "signature-policy", "", "The containers policy.json the source must pass (default the host's)")
+	cmd.Flags().BoolVar(&opts.InsecurePolicy, "insecure-policy", false, "Copy without checking the source against a signature policy")
+	return cmd
+}
+
+func runCopy(src, dst string, opts imgcopy.Options) {
+	srcLoc, err := imgcopy.ParseLocation(src)
+	if err != nil {
+		fail(exitError, err)
+	}
+	dstLoc, err := imgcopy.ParseLocation(dst)
+	if err != nil {
+		fail(exitError, err)
+	}
+	ctx, cancel := registry.Context(context.Background(), registry.OpPush)
+	res, err := imgcopy.Copy(ctx, srcLoc, dstLoc, opts)
+	err = registry.TimeoutError(ctx, err)
+	cancel()
+	if err != nil {
+		fail(exitError, err)
+	}
+	logging.Infof("Copied %s to %s with %d attached artifact(s)", srcLoc, dstLoc, res.Attachments)
+	if dstLoc.Transport == imgcopy.TransportDocker {
+		fmt.Println(imgref.WithDigest(dstLoc.Name, res.Digest))
+	} else {
+		fmt.Println(res.Digest)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/coverage"
+	"github.com/spf13/cobra"
+)
+
+func newCoverageCommand() *cobra.Command {
+	var cacheDir, modelConfig, output string
+
+	cmd := &cobra.Command{
+		Use:   "coverage",
+		Short: "Estimate whether a vLLM cache covers what a model config will compile",
+		Long: `Compare the compiled graphs and kernels in a vLLM cache with a vLLM
+model config (tensor and pipeline parallel size, dtype, compile sizes and
+batch token limit) and report the gaps vLLM would fill by compiling at
+startup. Exits non-zero when gaps are found.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runCoverage(cacheDir, modelConfig, output)
+		},
+	}
+	cmd.Flags().StringVarP(&cacheDir, "dir", "d", "", "vLLM cache directory, e.g. ~/.cache/vllm")
+	cmd.Flags().StringVar(&modelConfig, "model-config", "", "vLLM config YAML, as given to vllm serve --config")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	_ = cmd.MarkFlagRequired("dir")
+	_ = cmd.MarkFlagRequired("model-config")
+	return cmd
+}
+
+func runCoverage(cacheDir, modelConfig, output string) {
+	cfg, err := coverage.LoadModelConfig(modelConfig)
+	if err != nil {
+		fail(exitError, err)
+	}
+	report, err := coverage.Analyze(cacheDir, cfg)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fail(exitLogError, err)
+		}
+	case "text":
+		printCoverage(report)
+	default:
+		failf(exitLogError, "Unknown output format %q: must be text or json", output)
+	}
+	if !report.Covered() {
+		os.Exit(exitError)
+	}
+}
+
+func printCoverage(report *coverage.Report) {
+	if len(report.Entries) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HASH\tRANKS\tGRAPHS\tSIZES\tDTYPES\tGAPS")
+		for _, e := range report.Entries {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%d\n", e.Hash, len(e.Ranks), e.Graphs,
+				orDash(joinInts(e.Sizes)),
+				orDash(strings.Join(e.Dtypes, ",")), len(e.Gaps))
+		}
+		w.Flush()
+		fmt.Println()
+	}
+	for _, note := range report.Notes {
+		fmt.Printf("Note: %s\n", note)
+	}
+	if report.Covered() {
+		fmt.Printf("Covered: %s holds every graph the config compiles.\n", report.Best)
+		return
+	}
+	if report.Best != "" {
+		fmt.Printf("Likely JIT compiles with the closest entry %s:\n", report.Best)
+	} else {
+		fmt.Println("Likely JIT compiles:")
+	}
+	for _, gap := range report.Gaps {
+		fmt.Printf("  - %s\n", gap)
+	}
+}
+
+func joinInts(ns []int) string {
+	parts := make([]string, 0, len(ns))
+	for _, n := range ns {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ",")
+}
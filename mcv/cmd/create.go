@@ -0,0 +1,262 @@
+//go:build !mcv_edge
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containers/buildah"
+	"github.com/containers/storage/pkg/unshare"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/capture"
+	"github.com/redhat-et/MCU/mcv/pkg/chunk"
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/imgbuild"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// initReexec runs buildah's child process entry points, reporting
+// whether this process was one.
+func initReexec() bool {
+	return buildah.InitReexec()
+}
+
+// imageCommands returns the subcommands that build or write images.
+func imageCommands() []*cobra.Command {
+	return []*cobra.Command{newMigrateCacheCommand(), newCopyCommand(), newSplitCommand()}
+}
+
+func addCreateFlags(cmd *cobra.Command, opts *createFlags) {
+	cmd.Flags().StringVar(&opts.builder, "builder", "", fmt.Sprintf("Image builder backend for --create: %s (default native)", strings.Join(imgbuild.Builders(), ", ")))
+	cmd.Flags().StringVar(&opts.baseImage, "base-image", "", "Base image for --create (default scratch)")
+	cmd.Flags().StringArrayVar(&opts.labels, "label", nil, "Extra image label key=value for --create (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.copies, "copy", nil, "Extra file to add with --create as src:dest (repeatable)")
+	cmd.Flags().StringVar(&opts.compression, "compression", "", fmt.Sprintf("Layer compression for --create: %s (default gzip)", strings.Join(imgbuild.Compressions(), ", ")))
+	cmd.Flags().IntVar(&opts.compressionLevel, "compression-level", 0, "Compression level for --create (default: algorithm default)")
+	cmd.Flags().IntVar(&opts.compressionWorkers, "compression-workers", 0, "Parallel compression workers for --create (default: all CPUs the cgroup limits allow)")
+	cmd.Flags().StringArrayVar(&opts.excludes, "exclude", nil, "Glob of cache files to leave out with --create (repeatable)")
+	cmd.Flags().StringVar(&opts.filterFrom, "filter-from", "", "Package only the cache entries recorded by mcv capture in this file with --create")
+	cmd.Flags().StringVar(&opts.maxFileSize, "max-file-size", "", "Leave out cache files larger than this with --create, e.g. 512M")
+	cmd.Flags().StringVar(&opts.tritonDumpDir, "triton-dump-dir", "", "Triton dump directory to package with --create, e.g. $TRITON_DUMP_DIR")
+	cmd.Flags().StringVar(&opts.tritonOverrideDir, "triton-override-dir", "", "Triton override directory to package with --create, e.g. $TRITON_OVERRIDE_DIR")
+	cmd.Flags().StringVar(&opts.source, "source", "", "Source URL recorded in the image annotations with --create")
+	cmd.Flags().StringVar(&opts.revision, "revision", "", "Source revision recorded in the image annotations with --create")
+	cmd.Flags().StringVar(&opts.vllmPython, "vllm-python", "", "Python interpreter of the vLLM installation that built the cache, to record its versions with --create (default python3)")
+	cmd.Flags().StringVar(&opts.validUntil, "valid-until", "", "Expiry recorded in the image with --create: a date (2026-06-30), RFC 3339 time or duration (90d)")
+	cmd.Flags().StringVar(&opts.driverEOL, "driver-eol", "", "YAML file mapping driver branches to end of life dates; with --create, the image expires with the host's GPU drivers")
+	cmd.Flags().StringVar(&opts.annotatePlugin, "annotate-plugin", "", "Executable given the cache manifest on stdin that returns extra labels and annotations for --create")
+	cmd.Flags().StringVar(&opts.fsImage, "fs-image", "", fmt.Sprintf("Store the cache as a filesystem image layer that --extract --mount can mount, with --create: %s", strings.Join(cache.FSImageFormats(), ", ")))
+	cmd.Flags().BoolVar(&opts.chunked, "chunked", false, "Store large cache files as deduplicated chunks in separate layers with --create")
+	cmd.Flags().StringVar(&opts.chunkThreshold, "chunk-threshold", "", "Chunk cache files of at least this size with --chunked (default 16M)")
+	cmd.Flags().StringArrayVar(&opts.hotKernels, "hot-kernels", nil, "Glob of Triton kernel names, e.g. '*attn*', to mark hot and extract first from the image built with --create (repeatable)")
+	cmd.Flags().StringVar(&opts.secretScan, "secret-scan", "", fmt.Sprintf("Scan the cache for secrets before --create: %s (default off)", strings.Join(imgbuild.SecretScanPolicies(), ", ")))
+}
+
+// envDockerfileTemplate named the Dockerfile template of earlier builders,
+// no longer used since images are assembled natively.
+const envDockerfileTemplate = "MCV_DOCKERFILE_TEMPLATE"
+
+func runCreate(imageName, cacheDir string, createOpts createFlags) {
+	if imgref.IsDigest(imageName) {
+		failf(exitCreateError, "Cannot create %s: images are created under a tag, not a digest", imageName)
+	}
+	if imgref.IsLayout(imageName) {
+		failf(exitCreateError, "Cannot create %s: images are created in the local image store, OCI layouts are only read", imageName)
+	}
+
+	// Check if the cache directory exists
+	if _, err := utils.FilePathExists(cacheDir); err != nil {
+		logFatal("Error checking cache file path", err, exitCreateError)
+	}
+
+	if os.Getenv(envDockerfileTemplate) != "" {
+		diag.Warnf(diag.TemplateIgnored, "Ignoring %s: images are assembled without a Dockerfile", envDockerfileTemplate)
+	}
+
+	buildOpts, err := buildOptionsFromFlags(createOpts)
+	if err != nil {
+		logFatal("Invalid create options", err, exitCreateError)
+	}
+
+	// Initialize the image builder
+	builder, err := newImageBuilder(createOpts.builder, buildOpts)
+	if err != nil {
+		logFatal("Failed to create builder", err, exitCreateError)
+	}
+
+	// Create the OCI image
+	if err := builder.CreateImage(imageName, cacheDir); err != nil {
+		logging.Errorf("Failed to create the OCI image: %v", err)
+		// Builders only clean up after a successful build.
+		if err := imgbuild.CleanupWithTimeout(); err != nil {
+			diag.Warnf(diag.CleanupFailed, "cleanup failed: %v", err)
+		}
+		os.Exit(exitCreateError)
+	}
+
+	logging.Info("OCI image created successfully.")
+	// Print the digest reference for scripts to deploy with --digest-only.
+	if d, ok := builder.(imgbuild.Digester); ok && d.ImageDigest() != "" {
+		fmt.Println(imgref.WithDigest(imageName, d.ImageDigest()))
+	}
+}
+
+// validUntilFromFlags returns the expiry to record in the image: the
+// earlier of --valid-until and the end of life of the host's GPU drivers
+// given in --driver-eol.
+func validUntilFromFlags(f createFlags) (time.Time, error) {
+	var validUntil time.Time
+	var err error
+	if f.validUntil != "" {
+		if validUntil, err = cache.ParseValidUntil(f.validUntil, time.Now()); err != nil {
+			return validUntil, err
+		}
+	}
+	if f.driverEOL == "" {
+		return validUntil, nil
+	}
+	eol, err := cache.LoadDriverEOL(f.driverEOL)
+	if err != nil {
+		return validUntil, err
+	}
+	summary, err := client.GetSystemGPUInfo()
+	if err != nil {
+		return validUntil, fmt.Errorf("--driver-eol needs the host's GPU drivers: %w", err)
+	}
+	var versions []string
+	for _, g := range summary.GPUs {
+		versions = append(versions, g.DriverVersion)
+	}
+	driverEOL, err := eol.Expiry(versions)
+	if err != nil {
+		return validUntil, err
+	}
+	if validUntil.IsZero() || driverEOL.Before(validUntil) {
+		validUntil = driverEOL
+	}
+	return validUntil, nil
+}
+
+// newImageBuilder returns the builder for backend, falling back to the
+// configured default when backend is empty.
+func newImageBuilder(backend string, opts imgbuild.BuildOptions) (imgbuild.ImageBuilder, error) {
+	if backend == "" {
+		backend = config.Builder()
+	}
+	if backend == imgbuild.BuilderBuildah {
+		// Only buildah needs a user namespace for rootless builds.
+		unshare.MaybeReexecUsingUserNamespace(false)
+	}
+	return imgbuild.New(backend, opts)
+}
+
+// buildOptionsFromFlags resolves image customizations, preferring flags over
+// values from the MCV config.
+func buildOptionsFromFlags(f createFlags) (imgbuild.BuildOptions, error) {
+	opts := imgbuild.BuildOptions{
+		BaseImage:         config.BaseImage(),
+		TritonDumpDir:     f.tritonDumpDir,
+		TritonOverrideDir: f.tritonOverrideDir,
+		Source:            f.source,
+		Revision:          f.revision,
+	}
+	if f.baseImage != "" {
+		opts.BaseImage = f.baseImage
+	}
+
+	labels, err := imgbuild.ParseLabels(f.labels)
+	if err != nil {
+		return opts, err
+	}
+	opts.ExtraLabels = labels
+
+	copies, err := imgbuild.ParseCopySpecs(f.copies)
+	if err != nil {
+		return opts, err
+	}
+	opts.ExtraCopies = copies
+
+	opts.Compression = imgbuild.Compression{
+		Algorithm: config.Compression(),
+		Level:     config.CompressionLevel(),
+		Workers:   config.CompressionWorkers(),
+	}
+	if f.compression != "" {
+		opts.Compression.Algorithm = f.compression
+	}
+	if f.compressionLevel != 0 {
+		opts.Compression.Level = f.compressionLevel
+	}
+	if f.compressionWorkers != 0 {
+		opts.Compression.Workers = f.compressionWorkers
+	}
+	if err := opts.Compression.Validate(); err != nil {
+		return opts, err
+	}
+
+	maxSize, err := imgbuild.ParseFileSize(f.maxFileSize)
+	if err != nil {
+		return opts, err
+	}
+	opts.Filter = imgbuild.ContentFilter{Exclude: f.excludes, MaxFileSize: maxSize}
+	if f.filterFrom != "" {
+		c, err := capture.Load(f.filterFrom)
+		if err != nil {
+			return opts, err
+		}
+		opts.Filter.Include = c.Include()
+		logging.Infof("Packaging %d cache entries captured in %s", len(c.Entries), c.CacheDir)
+	}
+	if err := opts.Filter.Validate(); err != nil {
+		return opts, err
+	}
+
+	if err := cache.ValidateFSImageFormat(f.fsImage); err != nil {
+		return opts, err
+	}
+	opts.FSImage = f.fsImage
+
+	if err := cache.ValidateHotKernels(f.hotKernels); err != nil {
+		return opts, err
+	}
+	opts.HotKernels = f.hotKernels
+
+	if f.chunked {
+		opts.ChunkThreshold = chunk.DefaultThreshold
+		if f.chunkThreshold != "" {
+			if opts.ChunkThreshold, err = imgbuild.ParseFileSize(f.chunkThreshold); err != nil {
+				return opts, err
+			}
+		}
+	} else if f.chunkThreshold != "" {
+		return opts, fmt.Errorf("--chunk-threshold requires --chunked")
+	}
+
+	if opts.ValidUntil, err = validUntilFromFlags(f); err != nil {
+		return opts, err
+	}
+
+	opts.VLLMPython = config.VLLMPython()
+	if f.vllmPython != "" {
+		opts.VLLMPython = f.vllmPython
+	}
+
+	opts.AnnotatePlugin = config.AnnotatePlugin()
+	if f.annotatePlugin != "" {
+		opts.AnnotatePlugin = f.annotatePlugin
+	}
+
+	opts.SecretScan = config.SecretScan()
+	if f.secretScan != "" {
+		opts.SecretScan = f.secretScan
+	}
+	return opts, imgbuild.ValidateSecretScanPolicy(opts.SecretScan)
+}
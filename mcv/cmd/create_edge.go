@@ -0,0 +1,22 @@
+//go:build mcv_edge
+
+package main
+
+import "github.com/spf13/cobra"
+
+// Edge builds only pull, verify and extract images: without buildah,
+// there is nothing to re-execute and no image building commands.
+
+func initReexec() bool {
+	return false
+}
+
+func imageCommands() []*cobra.Command {
+	return nil
+}
+
+func addCreateFlags(cmd *cobra.Command, opts *createFlags) {}
+
+func runCreate(imageName, cacheDir string, createOpts createFlags) {
+	failf(exitCreateError, "This mcv is an edge build and cannot create images, use a full build")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/doctor"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCommand() *cobra.Command {
+	var opts doctor.Options
+	var dirs []string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that this host can build and extract cache images",
+		Long: `Check user namespaces and subordinate IDs, the image storage driver,
+registry access, GPU drivers and libraries, and the permissions and free
+space of the directories MCV writes to, and suggest a fix for each failed
+check. Exits non-zero when a check fails.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.Dirs = append([]string{constants.TritonCacheDir, constants.VLLMCacheDir,
+				constants.ImageStoreDir, constants.MCVBuildDir}, dirs...)
+			runDoctor(opts, output)
+		},
+	}
+	cmd.Flags().StringVar(&opts.Builder, "builder", config.Builder(), "Image builder to check for: native, buildah or docker")
+	cmd.Flags().StringVar(&opts.Registry, "registry", "quay.io", "Registry host to check")
+	cmd.Flags().StringVarP(&opts.Image, "image", "i", "", "Image to check can be read, instead of --registry")
+	cmd.Flags().StringArrayVarP(&dirs, "dir", "d", nil, "Extra cache directory to check (repeatable)")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+func runDoctor(opts doctor.Options, output string) {
+	results := doctor.Run(context.Background(), opts)
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fail(exitLogError, err)
+		}
+	case "text":
+		printDoctor(results)
+	default:
+		failf(exitLogError, "Unknown output format %q: must be text or json", output)
+	}
+	if doctor.Failed(results) {
+		os.Exit(exitError)
+	}
+}
+
+func printDoctor(results []doctor.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Probe, r.Status, r.Detail)
+	}
+	w.Flush()
+
+	first := true
+	for _, r := range results {
+		if r.Remedy == "" || r.Status == doctor.StatusOK {
+			continue
+		}
+		if first {
+			fmt.Println("\nSuggested fixes:")
+			first = false
+		}
+		fmt.Printf("  - %s: %s\n", r.Probe, r.Remedy)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/spf13/cobra"
+)
+
+func newExplainCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "explain [CODE]",
+		Short: "Explain the diagnostic code of a warning",
+		Long: `Describe what the warning with diagnostic CODE, e.g. MCV1102, means and
+what to do about it. Without CODE, list every code. Warnings found
+acceptable can be suppressed with --suppress-warnings, MCV_SUPPRESS_WARNINGS
+or a MCV_SUPPRESS_WARNINGS=MCV1203,MCV1302 line in mcv.config; they are
+then only logged at debug level.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runExplain(args, output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+func runExplain(args []string, output string) {
+	if output != "text" && output != "json" {
+		failf(exitError, "Unknown output format %q: use text or json", output)
+	}
+	diags := diag.All()
+	if len(args) == 1 {
+		d, ok := diag.Lookup(args[0])
+		if !ok {
+			failf(exitError, "Unknown diagnostic code %s; mcv explain lists them", args[0])
+		}
+		diags = []diag.Diagnostic{d}
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diags); err != nil {
+			fail(exitError, err)
+		}
+		return
+	}
+	if len(args) == 1 {
+		d := diags[0]
+		fmt.Printf("%s: %s\n\n%s\n", d.Code, d.Title, d.Explanation)
+		if diag.Suppressed(d.Code) {
+			fmt.Println("\nSuppressed by the configuration.")
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tTITLE\tSUPPRESSED")
+	for _, d := range diags {
+		suppressed := ""
+		if diag.Suppressed(d.Code) {
+			suppressed = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Code, d.Title, suppressed)
+	}
+	w.Flush()
+}
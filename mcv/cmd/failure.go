@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+	"github.com/redhat-et/MCU/mcv/pkg/logformat"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Error formats of --error-format.
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+var (
+	errorFormat = errorFormatText
+	// failCommand is the command that is running, for the error report.
+	failCommand = "mcv"
+)
+
+// failureReport is the error --error-format json prints when mcv fails.
+type failureReport struct {
+	Error    string `json:"error"`
+	Class    string `json:"class,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	Command  string `json:"command"`
+}
+
+// fail logs err and exits with the exit code of its failure class, or
+// with code when it has none, so that scripts can tell an incompatible
+// GPU from a registry refusing credentials whatever the command. With
+// --error-format json, err is also printed as a JSON object, the last line
+// on stderr, masked as logs are.
+func fail(code int, err error) {
+	rep := failureReport{Error: logformat.Redact(err.Error()), ExitCode: code, Command: failCommand}
+	if class := failure.Classify(err); class != nil {
+		rep.Class, rep.ExitCode = class.Name, class.ExitCode
+	}
+	logging.Error(err)
+	if errorFormat == errorFormatJSON {
+		if data, jerr := json.Marshal(rep); jerr == nil {
+			fmt.Fprintln(os.Stderr, string(data))
+		}
+	}
+	os.Exit(rep.ExitCode)
+}
+
+// failf fails with the error format and args describe; see fail.
+func failf(code int, format string, args ...any) {
+	fail(code, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+	"github.com/redhat-et/MCU/mcv/pkg/report"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHostReportCommand() *cobra.Command {
+	var imageName, output string
+	var noGPUFlag, baremetal bool
+
+	cmd := &cobra.Command{
+		Use:   "host-report",
+		Short: "Print this host's GPUs and image compatibility as JSON",
+		Long: `Print this host's GPUs, GPU targets and host facts as JSON, and with
+--image whether the GPUs can use that image. fleet-check runs this on
+each host. With --baremetal, include the results of the baremetal host
+checks under "hostChecks". With --output, print the report as CSV,
+Markdown or HTML instead.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runHostReport(imageName, output, noGPUFlag, baremetal)
+		},
+	}
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to check compatibility with")
+	cmd.Flags().StringVarP(&output, "output", "o", "json", "Output format: json, "+strings.Join(report.Formats(), ", "))
+	cmd.Flags().BoolVar(&noGPUFlag, "no-gpu", false, "Disable GPU logic for testing")
+	cmd.Flags().BoolVarP(&baremetal, "baremetal", "b", false, "Include the baremetal host checks")
+	return cmd
+}
+
+func runHostReport(imageName, output string, noGPUFlag, baremetal bool) {
+	if imageName != "" {
+		if err := validateImageName(imageName); err != nil {
+			fail(exitError, err)
+		}
+	}
+	exporter := reportExporter(output, "json")
+	configureBaremetalAndGPU(baremetal, noGPUFlag)
+	hostReport, err := client.GetHostReport(imageName)
+	if err != nil {
+		logFatal("Failed to get host report", err, exitError)
+	}
+	if exporter != nil {
+		if err := exporter.Export(os.Stdout, report.ForHost(hostReport)); err != nil {
+			fail(exitError, err)
+		}
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(hostReport); err != nil {
+		fail(exitError, err)
+	}
+}
+
+func newFleetCheckCommand() *cobra.Command {
+	var hostsFile, imageName, output string
+	var sshOptions []string
+	var parallel int
+
+	cmd := &cobra.Command{
+		Use:   "fleet-check",
+		Short: "Check that every host in a fleet can use an image",
+		Long: `Run mcv host-report on every host in a hosts file over SSH and report
+hosts that cannot use the image, and differences between hosts, such as
+mixed driver versions or GPU targets, that would make a rollout succeed
+on some hosts and fail on others. With --output, print the results as
+CSV, Markdown or HTML instead of a table, e.g. to attach to a change
+ticket.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runFleetCheck(hostsFile, imageName, output, sshOptions, parallel)
+		},
+	}
+	cmd.Flags().StringVar(&hostsFile, "hosts", "", "YAML file listing the hosts to check")
+	cmd.Flags().StringVarP(&imageName, "image", "i", "", "OCI image to check compatibility with")
+	cmd.Flags().StringArrayVarP(&sshOptions, "ssh-option", "o", nil, "Extra ssh option, e.g. StrictHostKeyChecking=accept-new (repeatable)")
+	cmd.Flags().IntVar(&parallel, "parallel", 8, "Number of hosts to check at a time")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text, "+strings.Join(report.Formats(), ", "))
+	_ = cmd.MarkFlagRequired("hosts")
+	return cmd
+}
+
+func runFleetCheck(hostsFile, imageName, output string, sshOptions []string, parallel int) {
+	if imageName != "" {
+		if err := validateImageName(imageName); err != nil {
+			fail(exitError, err)
+		}
+	}
+	exporter := reportExporter(output, "text")
+	hosts, err := fleet.LoadHosts(hostsFile)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	var sshArgs []string
+	for _, o := range sshOptions {
+		sshArgs = append(sshArgs, "-o", o)
+	}
+	logging.Infof("Checking %d host(s)", len(hosts))
+	results := fleet.Check(context.Background(), hosts, imageName, parallel, fleet.SSHRunner(sshArgs...))
+
+	if exporter != nil {
+		rep := report.ForFleet(imageName, results)
+		if err := exporter.Export(os.Stdout, rep); err != nil {
+			fail(exitError, err)
+		}
+		if len(rep.Issues) > 0 {
+			os.Exit(exitError)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tGPUS\tDRIVER\tTARGETS\tCOMPATIBLE")
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\tunreachable\n", r.Host.Name)
+			continue
+		}
+		var gpus, drivers []string
+		for _, g := range r.Report.GPUs {
+			gpus = append(gpus, fmt.Sprintf("%dx %s", len(g.IDs), g.GPUType))
+			drivers = append(drivers, g.DriverVersion)
+		}
+		compatible := "-"
+		if imageName != "" {
+			compatible = fmt.Sprintf("%t", r.Report.Compatible)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Host.Name, orDash(strings.Join(gpus, ", ")),
+			orDash(strings.Join(drivers, ", ")), orDash(strings.Join(r.Report.Targets, ", ")), compatible)
+	}
+	w.Flush()
+
+	issues := fleet.Analyze(results)
+	if len(issues) == 0 {
+		fmt.Println("Fleet is homogeneous.")
+		return
+	}
+	fmt.Printf("\n%d issue(s):\n", len(issues))
+	for _, issue := range issues {
+		fmt.Printf("  - %s\n", issue)
+	}
+	os.Exit(exitError)
+}
+
+type rolloutFlags struct {
+	hostsFile  string
+	sshOptions []string
+	dryRun     bool
+	opts       fleet.RolloutOptions
+}
+
+func newFleetRolloutCommand() *cobra.Command {
+	var f rolloutFlags
+
+	cmd := &cobra.Command{
+		Use:   "fleet-rollout",
+		Short: "Roll a cache image out to a fleet in waves, with a canary and rollback",
+		Long: `Extract an image on the hosts in a hosts file over SSH, a wave at a time.
+The first --canary hosts go first and must all succeed. The rest follow in
+waves of --wave-size hosts, with --pause between waves. A host succeeds
+once the image is extracted and --health-cmd, run on the host, passes
+within --health-timeout. When a canary host fails, or more than
+--max-failures hosts fail overall, the rollout stops and every host it
+changed gets --previous-image extracted again.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runFleetRollout(f)
+		},
+	}
+	cmd.Flags().StringVar(&f.hostsFile, "hosts", "", "YAML file listing the hosts to roll out to, in order")
+	cmd.Flags().StringVarP(&f.opts.Image, "image", "i", "", "OCI image to roll out")
+	cmd.Flags().StringVar(&f.opts.Previous, "previous-image", "", "OCI image to extract again on the changed hosts if the rollout fails (default leave them as they are)")
+	cmd.Flags().StringVarP(&f.opts.CacheDir, "dir", "d", "", "Cache directory to extract to on each host")
+	cmd.Flags().StringArrayVar(&f.opts.ExtractArgs, "extract-arg", nil, "Extra option for mcv --extract on each host, e.g. --kernels=compatible (repeatable)")
+	cmd.Flags().IntVar(&f.opts.Canary, "canary", 1, "Number of hosts in the canary wave")
+	cmd.Flags().IntVar(&f.opts.WaveSize, "wave-size", 5, "Number of hosts in each wave after the canary, 0 for all of them")
+	cmd.Flags().IntVar(&f.opts.MaxFailures, "max-failures", 0, "Number of hosts after the canary wave that may fail before the rollout stops")
+	cmd.Flags().StringVar(&f.opts.HealthCmd, "health-cmd", "", "Shell command run on each host after extracting that succeeds once it is healthy, e.g. \"curl -sf localhost:8000/health\"")
+	cmd.Flags().DurationVar(&f.opts.HealthTimeout, "health-timeout", 5*time.Minute, "How long a host has to pass --health-cmd")
+	cmd.Flags().DurationVar(&f.opts.HealthInterval, "health-interval", 10*time.Second, "How often to run --health-cmd until it passes")
+	cmd.Flags().DurationVar(&f.opts.Pause, "pause", 0, "How long to wait between waves, e.g. to watch dashboards")
+	cmd.Flags().StringArrayVarP(&f.sshOptions, "ssh-option", "o", nil, "Extra ssh option, e.g. StrictHostKeyChecking=accept-new (repeatable)")
+	cmd.Flags().BoolVar(&f.dryRun, "dry-run", false, "Print the waves without changing any host")
+	_ = cmd.MarkFlagRequired("hosts")
+	return cmd
+}
+
+func runFleetRollout(f rolloutFlags) {
+	for _, image := range []string{f.opts.Image, f.opts.Previous} {
+		if image == "" {
+			continue
+		}
+		if err := validateImageName(image); err != nil {
+			fail(exitError, err)
+		}
+	}
+	if err := fleet.ValidateRollout(f.opts); err != nil {
+		fail(exitError, err)
+	}
+	hosts, err := fleet.LoadHosts(f.hostsFile)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	if f.dryRun {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "WAVE\tHOSTS")
+		for i, wave := range fleet.Waves(hosts, f.opts.Canary, f.opts.WaveSize) {
+			names := make([]string, len(wave))
+			for j, h := range wave {
+				names[j] = h.Name
+			}
+			fmt.Fprintf(w, "%s\t%s\n", waveName(i), strings.Join(names, ", "))
+		}
+		w.Flush()
+		return
+	}
+
+	var sshArgs []string
+	for _, o := range f.sshOptions {
+		sshArgs = append(sshArgs, "-o", o)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	res := fleet.Rollout(ctx, hosts, f.opts, fleet.SSHExec(sshArgs...))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tWAVE\tSTATUS\tDETAIL")
+	for _, h := range res.Hosts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.Host.Name, waveName(h.Wave), h.Status, orDash(h.Detail))
+	}
+	w.Flush()
+	if res.Aborted != "" {
+		fmt.Printf("\nRollout aborted: %s.\n", res.Aborted)
+		os.Exit(exitError)
+	}
+	fmt.Printf("\nRolled %s out to %d host(s).\n", f.opts.Image, len(res.Hosts))
+}
+
+func waveName(i int) string {
+	if i == 0 {
+		return "canary"
+	}
+	return fmt.Sprintf("%d", i)
+}
+
+// reportExporter returns the exporter for output, or nil for the command's
+// built-in format def.
+func reportExporter(output, def string) report.Exporter {
+	if output == def {
+		return nil
+	}
+	exporter, err := report.Get(output)
+	if err != nil {
+		fail(exitError, err)
+	}
+	return exporter
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/hwdiff"
+	"github.com/spf13/cobra"
+)
+
+func newHWDiffCommand() *cobra.Command {
+	var baseline, output string
+	var update bool
+
+	cmd := &cobra.Command{
+		Use:   "hw-diff",
+		Short: "Compare this host's GPUs and drivers with an earlier snapshot",
+		Long: `Compare this host's GPUs, GPU drivers, ECC modes and kernel with the
+snapshot mcv --hw-info last recorded, or with --baseline, and report the
+changes that may invalidate the caches deployed on the host: driver
+upgrades or downgrades, missing or new GPUs, and ECC or virtualization
+changes. Exits non-zero when anything changed.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runHWDiff(baseline, output, update)
+		},
+	}
+	cmd.Flags().StringVar(&baseline, "baseline", config.HWSnapshot(), "Snapshot to compare with")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&update, "update", false, "Record the current state as the last snapshot once compared")
+	return cmd
+}
+
+func runHWDiff(baseline, output string, update bool) {
+	if output != "text" && output != "json" {
+		failf(exitError, "Unknown output format %q: must be text or json", output)
+	}
+	base, err := hwdiff.Load(baseline)
+	if err != nil {
+		failf(exitError, "%w; record one with mcv --hw-info", err)
+	}
+	// Detect the GPUs again: cached devices may predate a driver change.
+	config.SetDeviceCache(config.DeviceCache(), 0)
+	cur := client.TakeHWSnapshot()
+	changes := hwdiff.Diff(*base, cur)
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if changes == nil {
+			changes = []hwdiff.Change{}
+		}
+		if err := enc.Encode(changes); err != nil {
+			fail(exitError, err)
+		}
+	} else {
+		printHWDiff(base, changes)
+	}
+
+	if update {
+		if err := hwdiff.Save(config.HWSnapshot(), cur); err != nil {
+			fail(exitError, err)
+		}
+	}
+	if len(changes) > 0 {
+		os.Exit(exitError)
+	}
+}
+
+func printHWDiff(base *hwdiff.Snapshot, changes []hwdiff.Change) {
+	since := base.Taken.Local().Format("2006-01-02 15:04")
+	if len(changes) == 0 {
+		fmt.Printf("No hardware changes since %s\n", since)
+		return
+	}
+	fmt.Printf("Hardware changes since %s:\n\n", since)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tGPU\tOLD\tNEW\tIMPACT")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Kind, orDash(c.GPU), orDash(c.Old), orDash(c.New), c.Impact)
+	}
+	w.Flush()
+}
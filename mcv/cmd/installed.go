@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/inventory"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newListInstalledCommand() *cobra.Command {
+	var dir, output string
+
+	cmd := &cobra.Command{
+		Use:   "list-installed",
+		Short: "List the cache images extracted on this host",
+		Long: `List the cache images extracted on this host, as recorded in the inventory
+(MCV_INVENTORY_FILE, default ~/.mcv/installed.json) by every extract: the
+image, its digest, the directory it was extracted into, how many kernel
+entries it wrote there and how many of those are missing. Caches
+extracted by earlier releases are not listed until extracted again.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runListInstalled(absDir(dir), output)
+		},
+	}
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "Only list the images extracted into this directory")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+func newRemoveCommand() *cobra.Command {
+	var image, dir string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "remove -i IMAGE [-d DIR]",
+		Short: "Remove the kernel entries an extracted cache image wrote",
+		Long: `Remove the kernel entries IMAGE, a reference as extracted or a digest,
+wrote into each directory it was extracted into, or only into --dir.
+Entries another installed image wrote too, and files of no image such as
+kernels compiled at run time, are left alone.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runRemove(image, absDir(dir), dryRun)
+		},
+	}
+	cmd.Flags().StringVarP(&image, "image", "i", "", "Installed image to remove")
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "Only remove the image from this directory")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the entries that would be removed without removing them")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
+
+type upgradeFlags struct {
+	image, dir       string
+	dryRun           bool
+	baremetal, noGPU bool
+}
+
+func newUpgradeCommand() *cobra.Command {
+	var f upgradeFlags
+
+	cmd := &cobra.Command{
+		Use:   "upgrade [-i IMAGE] [-d DIR]",
+		Short: "Extract the images the tags of installed caches now refer to",
+		Long: `Resolve again the tag each installed cache image was extracted by, and
+where it now refers to another image, extract that one into the same
+directory and remove the entries of the previous one. Images extracted by
+digest are left as they are. With digest pinning, the new digests are
+pinned.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logLevel, _ := cmd.Flags().GetString("log-level")
+			f.dir = absDir(f.dir)
+			runUpgrade(f, logLevel)
+		},
+	}
+	cmd.Flags().StringVarP(&f.image, "image", "i", "", "Only upgrade this installed image")
+	cmd.Flags().StringVarP(&f.dir, "dir", "d", "", "Only upgrade the images extracted into this directory")
+	cmd.Flags().BoolVar(&f.dryRun, "dry-run", false, "List the upgrades without extracting them")
+	cmd.Flags().BoolVarP(&f.baremetal, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
+	cmd.Flags().BoolVar(&f.noGPU, "no-gpu", false, "Disable GPU logic for testing")
+	return cmd
+}
+
+// absDir returns dir as the inventory records directories: absolute and
+// clean.
+func absDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		fail(exitError, err)
+	}
+	return abs
+}
+
+func loadInventory() *inventory.Inventory {
+	inv, err := inventory.Load(config.InventoryFile())
+	if err != nil {
+		fail(exitError, err)
+	}
+	return inv
+}
+
+// installedStatus says whether the entries of in are all there.
+func installedStatus(in inventory.Install) string {
+	if _, err := os.Stat(in.Dir); err != nil {
+		return "dir missing"
+	}
+	if missing := inventory.Missing(in); missing > 0 {
+		return fmt.Sprintf("%d of %d entries missing", missing, len(in.Entries))
+	}
+	return "ok"
+}
+
+func runListInstalled(dir, output string) {
+	if output != "text" && output != "json" {
+		failf(exitError, "Unknown output format %q: must be text or json", output)
+	}
+	var installed []inventory.Install
+	for _, in := range loadInventory().Installed {
+		if dir == "" || in.Dir == dir {
+			installed = append(installed, in)
+		}
+	}
+
+	if output == "json" {
+		type listed struct {
+			inventory.Install
+			Status string `json:"status"`
+		}
+		out := []listed{}
+		for _, in := range installed {
+			out = append(out, listed{in, installedStatus(in)})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fail(exitError, err)
+		}
+		return
+	}
+	if len(installed) == 0 {
+		fmt.Println("No cache images installed.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tDIGEST\tDIR\tENTRIES\tSTATUS\tINSTALLED")
+	for _, in := range installed {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", in.Image, in.Digest, in.Dir, len(in.Entries),
+			installedStatus(in), in.InstalledAt.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+func runRemove(image, dir string, dryRun bool) {
+	inv := loadInventory()
+	found := inv.Find(image, dir)
+	if len(found) == 0 {
+		if dir != "" {
+			failf(exitError, "%s is not installed in %s", image, dir)
+		}
+		failf(exitError, "%s is not installed", image)
+	}
+	for _, in := range found {
+		if dryRun {
+			owned := inv.Owned(in)
+			fmt.Printf("Would remove %d of the %d entries %s wrote into %s\n", len(owned), len(in.Entries), in.Image, in.Dir)
+			for _, e := range owned {
+				fmt.Printf("  %s\n", e)
+			}
+			continue
+		}
+		removed, err := inv.Remove(in)
+		if saveErr := inv.Save(config.InventoryFile()); err == nil {
+			err = saveErr
+		}
+		if err != nil {
+			failf(exitError, "failed to remove %s from %s: %w", in.Image, in.Dir, err)
+		}
+		logging.Infof("Removed %s from %s: %d entries", in.Image, in.Dir, len(removed))
+	}
+}
+
+func runUpgrade(f upgradeFlags, logLevel string) {
+	inv := loadInventory()
+	targets := inv.Installed
+	if f.image != "" || f.dir != "" {
+		targets = nil
+		for _, in := range inv.Installed {
+			if f.dir != "" && in.Dir != f.dir {
+				continue
+			}
+			if f.image != "" && !in.Is(f.image) {
+				continue
+			}
+			targets = append(targets, in)
+		}
+		if len(targets) == 0 {
+			failf(exitError, "No installed image matches")
+		}
+	}
+
+	configureBaremetalAndGPU(f.baremetal, f.noGPU)
+	gpuEnabled := config.IsGPUEnabled()
+	skipPrecheck := config.IsSkipPrecheckEnabled()
+	updatePin := true
+	extract := func(in inventory.Install) error {
+		constants.ExtractCacheDir = ""
+		opts := client.Options{
+			ImageName:       in.Image,
+			CacheDir:        in.Dir,
+			EnableGPU:       &gpuEnabled,
+			LogLevel:        logLevel,
+			EnableBaremetal: &f.baremetal,
+			SkipPrecheck:    &skipPrecheck,
+			UpdatePin:       &updatePin,
+		}
+		_, _, err := client.ExtractCache(opts)
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tDIR\tFROM\tTO\tRESULT")
+	failed := 0
+	for _, in := range targets {
+		latest, result := upgradeInstall(in, f.dryRun, extract)
+		if result == "failed" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", in.Image, in.Dir, in.Digest, orDash(latest), result)
+	}
+	w.Flush()
+	if failed > 0 {
+		failf(exitError, "%d of %d installed image(s) not upgraded", failed, len(targets))
+	}
+}
+
+// upgradeInstall upgrades in if its tag now refers to another image, and
+// returns the digest the tag refers to and what it did.
+func upgradeInstall(in inventory.Install, dryRun bool, extract func(inventory.Install) error) (string, string) {
+	if imgref.Digest(in.Image) != "" {
+		return "", "pinned by digest"
+	}
+	digest, err := fetcher.ResolveDigest(in.Image)
+	if err != nil {
+		logging.Errorf("Failed to resolve %s: %v", in.Image, err)
+		return "", "failed"
+	}
+	if digest == in.Digest {
+		return digest, "up to date"
+	}
+	if dryRun {
+		return digest, "would upgrade"
+	}
+	// The image the tag now refers to may be installed in the directory
+	// already, e.g. by an upgrade that failed to remove the previous one.
+	if len(loadInventory().Find(digest, in.Dir)) == 0 {
+		if err := extract(in); err != nil {
+			logging.Errorf("Failed to extract %s into %s: %v", in.Image, in.Dir, err)
+			return digest, "failed"
+		}
+	}
+	// The extraction recorded the new image; what it did not write again of
+	// the previous one goes.
+	inv := loadInventory()
+	removed, err := inv.Remove(in)
+	if saveErr := inv.Save(config.InventoryFile()); err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		logging.Errorf("Failed to remove the previous %s from %s: %v", in.Image, in.Dir, err)
+		return digest, "failed"
+	}
+	logging.Infof("Upgraded %s in %s to %s, removing %d previous entries", in.Image, in.Dir, digest, len(removed))
+	return digest, "upgraded"
+}
@@ -1,27 +1,57 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"regexp"
-
-	"github.com/containers/buildah"
-	"github.com/containers/storage/pkg/unshare"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/build"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
 	"github.com/redhat-et/MCU/mcv/pkg/client"
 	"github.com/redhat-et/MCU/mcv/pkg/config"
-	"github.com/redhat-et/MCU/mcv/pkg/imgbuild"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/fips"
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+	"github.com/redhat-et/MCU/mcv/pkg/hwdiff"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/iolimit"
 	"github.com/redhat-et/MCU/mcv/pkg/logformat"
-	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	"github.com/redhat-et/MCU/mcv/pkg/pciids"
+	"github.com/redhat-et/MCU/mcv/pkg/probe"
+	"github.com/redhat-et/MCU/mcv/pkg/reslimit"
 	logging "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// Exit codes, the only ones mcv exits with besides those of the failure
+// classes, 40 to 45 (see pkg/failure), which replace them when the cause
+// of a failure is known. Every command exits with exitError when it fails,
+// or finds what it checks for wrong, e.g. doctor or hwdiff.
 const (
-	exitNormal       = 0
-	exitExtractError = 1
-	exitCreateError  = 2
-	exitLogError     = 3
-	imageNameRegex   = `^([a-z0-9]+([._-][a-z0-9]+)*(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(\/[a-z0-9]+([._-][a-z0-9]+)*)*(?::[\w][\w.-]{0,127})?$`
+	exitNormal = 0
+	exitError  = 1
+	// exitCreateError and exitLogError are kept from the first releases:
+	// create failed, and logging, the configuration or FIPS mode could not
+	// be set up, whatever the command.
+	exitCreateError = 2
+	exitLogError    = 3
+
+	// exitExtractPartial is extract's exit code when, with --entry-errors
+	// skip, it extracted the cache but for the entries it skipped.
+	exitExtractPartial = 15
 )
 
 func main() {
@@ -31,10 +61,15 @@ func main() {
 		logFatal("Error initializing config", err, exitLogError)
 	}
 
-	if buildah.InitReexec() {
+	if config.IsFIPSRequired() {
+		if err := fips.Verify(); err != nil {
+			logFatal("Error verifying FIPS mode", err, exitLogError)
+		}
+	}
+
+	if initReexec() {
 		return
 	}
-	unshare.MaybeReexecUsingUserNamespace(false)
 
 	cmd := buildRootCommand()
 	if err := cmd.Execute(); err != nil {
@@ -47,48 +82,317 @@ func initializeLogging() {
 	logging.SetFormatter(logformat.Default)
 }
 
+// logFatal fails with err, explained by message; see fail.
 func logFatal(message string, err error, exitCode int) {
-	logging.Fatalf("%s: %v", message, err)
-	os.Exit(exitCode)
+	fail(exitCode, fmt.Errorf("%s: %w", message, err))
+}
+
+// configureContainerized points hardware detection and Triton and vLLM
+// cache discovery at the host's root filesystem when mcv runs in a
+// container, and installs the PCI IDs database embedded in container image
+// builds. Setting --host-root implies --containerized.
+func configureContainerized(cmd *cobra.Command, containerized bool, hostRoot, hostHome string) {
+	if cmd.Flags().Changed("containerized") {
+		config.SetContainerized(containerized)
+	}
+	if cmd.Flags().Changed("host-root") {
+		config.SetHostRoot(hostRoot)
+		if !cmd.Flags().Changed("containerized") {
+			config.SetContainerized(true)
+		}
+	}
+	if cmd.Flags().Changed("host-home") {
+		config.SetHostHome(hostHome)
+	}
+	if config.IsContainerized() {
+		root := config.HostRoot()
+		if _, err := os.Stat(filepath.Join(root, "sys")); err != nil {
+			logFatal("Error finding the host's root filesystem", fmt.Errorf("%s: %w; mount it with -v /:%s:ro", root, err, root), exitLogError)
+		}
+		if err := hostfs.SetRoot(root); err != nil {
+			logFatal("Error setting the host root", err, exitLogError)
+		}
+		home := config.HostHome()
+		if home == "" {
+			home, _ = os.UserHomeDir()
+		}
+		constants.SetUserHome(hostfs.Path(home))
+		logging.Debugf("Reading host hardware information from %s and caches from %s", root, hostfs.Path(home))
+	}
+	if err := pciids.Install(); err != nil {
+		logging.Debugf("Embedded PCI IDs database not installed: %v", err)
+	}
+}
+
+// createFlags holds the image customization flags used with --create.
+type createFlags struct {
+	builder   string
+	baseImage string
+	labels    []string
+	copies    []string
+
+	compression        string
+	compressionLevel   int
+	compressionWorkers int
+
+	excludes    []string
+	maxFileSize string
+	secretScan  string
+	filterFrom  string
+	vllmPython  string
+
+	tritonDumpDir     string
+	tritonOverrideDir string
+
+	source         string
+	revision       string
+	annotatePlugin string
+
+	chunked        bool
+	chunkThreshold string
+	fsImage        string
+	hotKernels     []string
+
+	validUntil string
+	driverEOL  string
+}
+
+// hwInfoFlags holds the flags used with --hw-info.
+type hwInfoFlags struct {
+	wide   bool
+	record string
+}
+
+// bootstrapFlags holds the flags used with --bootstrap.
+type bootstrapFlags struct {
+	enabled bool
+	root    string
+}
+
+// extractFlags holds the flags used with --extract.
+type extractFlags struct {
+	resume    bool
+	force     bool
+	yes       bool
+	backup    bool
+	updatePin bool
+
+	container       string
+	runtimeEndpoint string
+
+	placement string
+
+	vllmKeyMismatch string
+	mount           bool
+
+	fileMode string
+	dirMode  string
+	owner    string
+
+	expired string
+
+	sharedLock  string
+	lockTimeout time.Duration
+
+	readyFile string
+	readyAddr string
+	readyWhen string
+
+	entryErrors string
+
+	nice       int
+	ioNice     string
+	writeLimit float64
+
+	store          string
+	tmpfsSize      string
+	bucket         string
+	bucketEndpoint string
+	bucketMount    string
+
+	kernels string
+
+	requireCompat   bool
+	verifyOnly      bool
+	signaturePolicy string
 }
 
 func buildRootCommand() *cobra.Command {
 	var imageName, cacheDirName, logLevel string
+	var createOpts createFlags
+	var extractOpts extractFlags
+	var bootstrapOpts bootstrapFlags
+	var hwInfoOpts hwInfoFlags
 	var createFlag, extractFlag, baremetalFlag, noGPUFlag, hwInfoFlag, checkCompatFlag, gpuInfoFlag bool
+	var keepTemp bool
+	var deviceCache string
+	var deviceCacheTTL time.Duration
+	var containerized bool
+	var hostRoot string
+	var hostHome string
+	var noPreflightCache bool
+	var telemetryEndpoint string
+	var digestOnly bool
+	var trustOpts trustFlags
+	var suppressWarnings []string
 
 	cmd := &cobra.Command{
 		Use:   "mcv",
 		Short: "A GPU Kernel runtime container image management utility",
+		Long: `mcv packages Triton and vLLM GPU kernel caches as OCI images, checks that
+a host's GPUs can use them and extracts them.
+
+The --create, --extract, --verify-only, --check-compat, --hw-info,
+--gpu-info and --bootstrap flags of earlier releases still work, as
+aliases of the subcommands of the same names.`,
+		Version: build.Version,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			failCommand = cmd.CommandPath()
+			if err := config.BindFlags(cmd.Flags(), flagScope(cmd)); err != nil {
+				logFatal("Error reading options from the environment", err, exitLogError)
+			}
 			if err := logformat.ConfigureLogging(logLevel); err != nil {
 				logFatal("Error configuring logging", err, exitLogError)
 			}
+			if errorFormat != errorFormatText && errorFormat != errorFormatJSON {
+				format := errorFormat
+				errorFormat = errorFormatText
+				failf(exitLogError, "unknown --error-format %q: use text or json", format)
+			}
+			if len(suppressWarnings) > 0 {
+				config.SetSuppressedWarnings(suppressWarnings)
+			}
+			if keepTemp {
+				config.SetKeepTemp(true)
+			}
+			if noPreflightCache {
+				config.SetNoPreflightCache(true)
+			}
+			if digestOnly {
+				config.SetDigestOnly(true)
+			}
+			if telemetryEndpoint != "" {
+				config.SetTelemetryEndpoint(telemetryEndpoint)
+			}
+			configureTimeouts(cmd)
+			if cmd.Flags().Changed("device-cache") || cmd.Flags().Changed("device-cache-ttl") {
+				config.SetDeviceCache(deviceCache, deviceCacheTTL)
+			}
+			configureContainerized(cmd, containerized, hostRoot, hostHome)
+			if err := configureTrust(trustOpts); err != nil {
+				logFatal("Error loading the trust material from the cluster", err, exitLogError)
+			}
+			if l := reslimit.Current(); l != (reslimit.Limits{}) {
+				logging.Debugf("cgroup limits: %s", l)
+			}
+			reslimit.Apply()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			handleRunCommand(imageName, cacheDirName, logLevel, createFlag, extractFlag, baremetalFlag, noGPUFlag, hwInfoFlag, checkCompatFlag, gpuInfoFlag)
+			handleRunCommand(imageName, cacheDirName, logLevel, createFlag, extractFlag, baremetalFlag, noGPUFlag, hwInfoFlag, checkCompatFlag, gpuInfoFlag, createOpts, extractOpts, bootstrapOpts, hwInfoOpts)
 		},
 	}
 
+	cmd.SetVersionTemplate(fmt.Sprintf("mcv version {{.Version}} (%s, %s/%s)\n%s\n",
+		build.Revision, build.OS, build.Arch, fips.Report(config.IsFIPSRequired())))
 	addFlags(cmd, &imageName, &cacheDirName, &logLevel, &createFlag, &extractFlag, &baremetalFlag, &noGPUFlag, &hwInfoFlag, &checkCompatFlag, &gpuInfoFlag)
+	cmd.PersistentFlags().StringVar(&deviceCache, "device-cache", config.DeviceCache(), "File caching the detected GPUs")
+	cmd.PersistentFlags().DurationVar(&deviceCacheTTL, "device-cache-ttl", config.DeviceCacheTTL(), "How long the detected GPUs stay cached, 0 disables caching")
+	cmd.PersistentFlags().BoolVar(&containerized, "containerized", false, "mcv runs in a privileged container with the host's root filesystem at --host-root")
+	cmd.PersistentFlags().StringVar(&hostRoot, "host-root", "/host", "Where the host's root filesystem is mounted; implies --containerized")
+	cmd.PersistentFlags().StringVar(&hostHome, "host-home", "", "With --containerized, the host user's home directory holding the Triton and vLLM caches (default mcv's home directory)")
+	cmd.PersistentFlags().BoolVar(&noPreflightCache, "no-preflight-cache", false, "Check GPU compatibility again instead of reusing a cached result for the image and GPUs")
+	cmd.PersistentFlags().BoolVar(&digestOnly, "digest-only", false, "Refuse to extract or check images referenced by tag instead of @sha256 digest")
+	cmd.PersistentFlags().StringVar(&telemetryEndpoint, "telemetry-endpoint", "", "Opt in to sending anonymized extraction statistics to this URL")
+	cmd.PersistentFlags().BoolVar(&keepTemp, "keep-temp", false, "Keep the build context, image layout and fetched manifests in "+constants.MCVDebugDir+" for debugging")
+	cmd.PersistentFlags().StringVar(&errorFormat, "error-format", errorFormatText, "How the error mcv fails with is reported: text, or json to also print it as a final JSON object on stderr")
+	cmd.PersistentFlags().StringArrayVar(&suppressWarnings, "suppress-warnings", nil, "Diagnostic code of a warning to log at debug level only, e.g. MCV1203 (repeatable); mcv explain lists them")
+	addTrustFlags(cmd, &trustOpts)
+	addTimeoutFlags(cmd)
+	addCreateFlags(cmd, &createOpts)
+	addExtractFlags(cmd, &extractOpts)
+	cmd.Flags().BoolVar(&bootstrapOpts.enabled, "bootstrap", false, "Install mcv as a systemd-sysext extension for image-based OSes such as Fedora CoreOS")
+	cmd.Flags().StringVar(&bootstrapOpts.root, "bootstrap-root", "/", "Filesystem root to install into with --bootstrap")
+	cmd.Flags().BoolVar(&hwInfoOpts.wide, "wide", false, "With --hw-info, list every accelerator with full details instead of grouping them")
+	cmd.Flags().StringVar(&hwInfoOpts.record, "record-devices", "", "With --hw-info, also record what the GPU libraries and tools report to this file, for replay with MCV_DEVICE_FIXTURE")
+	// The subcommands document these; they are kept for existing scripts.
+	cmd.Flags().VisitAll(func(f *pflag.Flag) { f.Hidden = true })
+	cmd.AddCommand(newCreateCommand(), newExtractCommand(&logLevel), newVerifyCommand(&logLevel), newCheckCompatCommand(),
+		newHWInfoCommand(), newGPUInfoCommand(), newInspectCommand(), newBootstrapCommand(), newPushCommand(), newPullCommand(), newExplainCommand())
+	cmd.AddCommand(newComposeCommand(), newApplyCommand(), newListInstalledCommand(), newRemoveCommand(), newUpgradeCommand(), newHostReportCommand(), newFleetCheckCommand(), newFleetRolloutCommand(), newVersionCommand(), newCoverageCommand(), newCaptureCommand(), newUsageCommand(), newDoctorCommand(), newNFDCommand(), newCleanupCommand(), newHWDiffCommand())
+	cmd.AddCommand(imageCommands()...)
 	return cmd
 }
 
+// flagScope returns the scope of the flags of cmd for config.BindFlags:
+// the path below mcv of the command that defines each flag, empty for the
+// flags of mcv itself.
+func flagScope(cmd *cobra.Command) func(name string) string {
+	return func(name string) string {
+		for c := cmd; c.HasParent(); c = c.Parent() {
+			if c.LocalFlags().Lookup(name) != nil {
+				return strings.TrimPrefix(c.CommandPath(), c.Root().Name()+" ")
+			}
+		}
+		return ""
+	}
+}
+
+func addExtractFlags(cmd *cobra.Command, opts *extractFlags) {
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "Resume an interrupted --extract instead of starting over")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "With --extract, extract even if --dir already holds the image")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "With --extract, overwrite the files --dir holds without asking; --force does too")
+	cmd.Flags().BoolVar(&opts.backup, "backup", false, "With --extract, move the files --dir holds to a timestamped directory next to it before extracting")
+	cmd.Flags().BoolVar(&opts.updatePin, "update-pin", false, "With digest pinning, accept and record a new digest for the --extract tag")
+	cmd.Flags().StringVar(&opts.container, "container", "", "With --extract, extract into this running container; --dir is the path inside it")
+	cmd.Flags().StringVar(&opts.placement, "placement", "", "With --extract, YAML file mapping GPUs or NUMA nodes to cache dirs, instead of --dir")
+	cmd.Flags().BoolVar(&opts.mount, "mount", false, "With --extract, mount a squashfs or erofs cache image on --dir instead of unpacking it (needs root)")
+	cmd.Flags().StringVar(&opts.vllmKeyMismatch, "vllm-key-mismatch", "", fmt.Sprintf("With --extract, what to do with a vLLM cache built for other vLLM, torch or Triton versions: %s (default refuse)", strings.Join(fetcher.VLLMKeyPolicies(), ", ")))
+	cmd.Flags().StringVar(&opts.runtimeEndpoint, "runtime-endpoint", "", "CRI socket of the runtime running --container (default: crictl's)")
+	cmd.Flags().StringVar(&opts.fileMode, "file-mode", "", "With --extract, octal mode given to every extracted file, e.g. 0444")
+	cmd.Flags().StringVar(&opts.dirMode, "dir-mode", "", "With --extract, octal mode given to every extracted directory, e.g. 0555")
+	cmd.Flags().StringVar(&opts.owner, "owner", "", "With --extract, user[:group] given the extracted cache, by name or ID")
+	cmd.Flags().StringVar(&opts.expired, "expired", "", fmt.Sprintf("With --extract, what to do with a cache image past its valid-until date: %s (default warn)", strings.Join(fetcher.ExpiredPolicies(), ", ")))
+	cmd.Flags().StringVar(&opts.sharedLock, "shared-lock", "", fmt.Sprintf("With --extract, when to lock --dir against other nodes sharing it: %s (default auto, for NFS, GPFS and other network filesystems)", strings.Join(fetcher.SharedLockModes(), ", ")))
+	cmd.Flags().DurationVar(&opts.lockTimeout, "lock-timeout", 0, "With --extract, how long to wait for another node extracting into a shared --dir (default 30m)")
+	cmd.Flags().StringVar(&opts.readyFile, "ready-file", "", "With --extract, write \"hot\" to this file once the hot kernels are extracted, then \"complete\" once the whole cache is")
+	cmd.Flags().StringVar(&opts.readyAddr, "ready-addr", "", "With --extract, serve "+probe.ReadyPath+" on this address, e.g. :8081, while extracting, and keep serving once done until stopped, for Kubernetes probes")
+	cmd.Flags().StringVar(&opts.readyWhen, "ready-when", fetcher.ReadyComplete, fmt.Sprintf("With --ready-addr, the stage after which %s succeeds: %s", probe.ReadyPath, strings.Join(probe.Stages(), ", ")))
+	cmd.Flags().StringVar(&opts.entryErrors, "entry-errors", "", fmt.Sprintf("With --extract, what to do with a cache entry that is corrupt or cannot be written: %s (default fail); skip extracts the rest, reports the entries skipped and exits %d", strings.Join(cache.EntryErrorPolicies(), ", "), exitExtractPartial))
+	cmd.Flags().IntVar(&opts.nice, "nice", 0, "With --extract, the nice value to extract with, e.g. 10 to yield the CPU to the host's workloads")
+	cmd.Flags().StringVar(&opts.ioNice, "ionice", "", fmt.Sprintf("With --extract, the I/O priority to extract with, as class[:level] with class one of %s and level 0 to 7, e.g. idle or best-effort:7", strings.Join(iolimit.Classes(), ", ")))
+	cmd.Flags().Float64Var(&opts.writeLimit, "write-limit", 0, "With --extract, the MB/s to write extracted files at (default no limit)")
+	cmd.Flags().StringVar(&opts.store, "store", "", fmt.Sprintf("With --extract, where to write the cache: %s (default dir); tmpfs mounts one on --dir unless it already is on tmpfs; s3 and gcs stage the cache in --dir, or a temporary directory, and upload it to --bucket", strings.Join(cache.StoreKinds(), ", ")))
+	cmd.Flags().StringVar(&opts.tmpfsSize, "tmpfs-size", "", "With --store tmpfs, the size of the tmpfs mounted, e.g. 8G (default half the memory)")
+	cmd.Flags().StringVar(&opts.bucket, "bucket", "", "With --store s3 or gcs, the bucket[/prefix] to upload the cache to, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (an HMAC key for gcs)")
+	cmd.Flags().StringVar(&opts.bucketEndpoint, "bucket-endpoint", "", "With --store s3, the URL of an S3-compatible service to upload to, e.g. a MinIO server (default AWS in AWS_REGION)")
+	cmd.Flags().StringVar(&opts.bucketMount, "bucket-mount", "", "With --store s3 or gcs, where consumers see the bucket prefix, e.g. /mnt/cache; paths in the cache metadata are resolved to it (default leave "+cache.CacheRootPlaceholder+")")
+	cmd.Flags().StringVar(&opts.kernels, "kernels", "", fmt.Sprintf("With --extract, which kernels of the image to restore: %s (default all); compatible removes those built for none of the GPUs, for images holding kernels of several architectures", strings.Join(fetcher.KernelPolicies(), ", ")))
+	cmd.Flags().BoolVar(&opts.requireCompat, "require-compat", false, "With --extract, check GPU compatibility and the image signature first, extract only if both pass, and print a JSON report")
+	cmd.Flags().BoolVar(&opts.verifyOnly, "verify-only", false, "Check GPU compatibility and the signature of --image without extracting it, and print a JSON report")
+	cmd.Flags().StringVar(&opts.signaturePolicy, "signature-policy", "", "With --require-compat or --verify-only, the containers policy.json to verify signatures with (default the host's)")
+}
+
 func addFlags(cmd *cobra.Command, imageName, cacheDirName, logLevel *string, createFlag, extractFlag, baremetalFlag, noGPUFlag, hwInfoFlag, checkCompatFlag, gpuInfoFlag *bool) {
 	cmd.Flags().StringVarP(imageName, "image", "i", "", "OCI image name")
 	cmd.Flags().StringVarP(cacheDirName, "dir", "d", "", "Triton/vLLM Cache Directory")
-	cmd.Flags().StringVarP(logLevel, "log-level", "l", "", "Set the logging verbosity level: debug, info, warning or error")
+	cmd.PersistentFlags().StringVarP(logLevel, "log-level", "l", "", "Set the logging verbosity level: debug, info, warning or error")
 	cmd.Flags().BoolVarP(createFlag, "create", "c", false, "Create OCI image")
 	cmd.Flags().BoolVarP(extractFlag, "extract", "e", false, "Extract a Triton/vLLM cache from an OCI image")
 	cmd.Flags().BoolVarP(baremetalFlag, "baremetal", "b", false, "Run baremetal/detailed preflight checks")
 	cmd.Flags().BoolVar(noGPUFlag, "no-gpu", false, "Disable GPU logic for testing")
-	cmd.Flags().BoolVar(hwInfoFlag, "hw-info", false, "Display system hardware info")
+	cmd.Flags().BoolVar(hwInfoFlag, "hw-info", false, "Display system hardware info, and record it for mcv hw-diff")
 	cmd.Flags().BoolVar(gpuInfoFlag, "gpu-info", false, "Display GPU info")
 	cmd.Flags().BoolVar(checkCompatFlag, "check-compat", false, "Check system GPU compatibility with a given image")
 }
 
-func handleRunCommand(imageName, cacheDirName, logLevel string, createFlag, extractFlag, baremetalFlag, noGPUFlag, hwInfoFlag, checkCompatFlag, gpuInfoFlag bool) {
+func handleRunCommand(imageName, cacheDirName, logLevel string, createFlag, extractFlag, baremetalFlag, noGPUFlag, hwInfoFlag, checkCompatFlag, gpuInfoFlag bool, createOpts createFlags, extractOpts extractFlags, bootstrapOpts bootstrapFlags, hwInfoOpts hwInfoFlags) {
+	if bootstrapOpts.enabled {
+		handleBootstrap(bootstrapOpts.root)
+		os.Exit(exitNormal)
+	}
+
 	if hwInfoFlag {
-		handleHWInfo()
+		handleHWInfo(hwInfoOpts)
 	}
 
 	if gpuInfoFlag {
@@ -102,27 +406,32 @@ func handleRunCommand(imageName, cacheDirName, logLevel string, createFlag, extr
 	configureBaremetalAndGPU(baremetalFlag, noGPUFlag)
 
 	if (createFlag || extractFlag) && imageName == "" {
-		logging.Error("--image is required when using --create or --extract")
-		os.Exit(exitLogError)
+		failf(exitLogError, "--image is required when using --create or --extract")
 	}
 
-	if createFlag || extractFlag || checkCompatFlag {
+	if createFlag || extractFlag || checkCompatFlag || extractOpts.verifyOnly {
 		if err := validateImageName(imageName); err != nil {
-			logging.Error(err)
-			os.Exit(exitLogError)
+			fail(exitLogError, err)
 		}
 	}
 
+	if extractOpts.verifyOnly {
+		if extractFlag || createFlag {
+			failf(exitLogError, "--verify-only cannot be used with --create or --extract")
+		}
+		runVerifyAndExtract(imageName, cacheDirName, logLevel, baremetalFlag, extractOpts, false)
+	}
+
 	if createFlag {
-		runCreate(imageName, cacheDirName)
+		runCreate(imageName, cacheDirName, createOpts)
 	}
 
 	if extractFlag {
-		runExtract(imageName, cacheDirName, logLevel, baremetalFlag)
+		runExtract(imageName, cacheDirName, logLevel, baremetalFlag, extractOpts)
 	}
 
 	if !createFlag && !extractFlag {
-		logging.Error("No action specified. Use --create or --extract flag.")
+		logging.Error("No action specified. Use a subcommand such as mcv create or mcv extract; see mcv --help.")
 		os.Exit(exitNormal)
 	}
 }
@@ -132,33 +441,34 @@ func validateImageName(imageName string) error {
 		return fmt.Errorf("--image is required")
 	}
 
-	matched, err := regexp.MatchString(imageNameRegex, imageName)
-	if err != nil {
-		return fmt.Errorf("error validating image name: %v", err)
-	}
-
-	if !matched {
-		return fmt.Errorf("invalid image name: %s. Ensure it is a valid Docker or Quay image URL", imageName)
-	}
-
-	return nil
+	return imgref.Validate(imageName)
 }
 
-func handleHWInfo() {
-	xpu, err := client.GetXPUInfo()
+func handleHWInfo(opts hwInfoFlags) {
+	if opts.record != "" {
+		if err := devices.WriteFixture(opts.record, devices.RecordFixture(context.Background())); err != nil {
+			logFatal("Error recording devices", err, exitLogError)
+		}
+		logging.Infof("Recorded devices to %s", opts.record)
+	}
+	xpu, err := client.GetXPUDetails()
 	if err != nil {
-		logging.Errorf("Error getting system hardware: %v", err)
-		os.Exit(exitLogError)
+		logFatal("Error getting system hardware", err, exitLogError)
+	}
+	if err := client.WriteXPUInfo(os.Stdout, xpu, opts.wide); err != nil {
+		logFatal("Error printing system hardware", err, exitLogError)
+	}
+	// Recorded for mcv hw-diff to compare with.
+	if err := hwdiff.Save(config.HWSnapshot(), client.TakeHWSnapshot()); err != nil {
+		diag.Warnf(diag.HWSnapshotNotSaved, "Failed to record the hardware snapshot: %v", err)
 	}
-	client.PrintXPUInfo(xpu)
 	os.Exit(exitNormal)
 }
 
 func handleGPUInfo() {
 	summary, err := client.GetSystemGPUInfo()
 	if err != nil {
-		logging.Errorf("Error getting system hardware: %v", err)
-		os.Exit(exitLogError)
+		logFatal("Error getting system hardware", err, exitLogError)
 	}
 	client.PrintGPUSummary(summary)
 	os.Exit(exitNormal)
@@ -166,30 +476,40 @@ func handleGPUInfo() {
 
 func handleCheckCompat(imageName string) {
 	if imageName == "" {
-		logging.Error("--image is required with --check-compat")
-		os.Exit(exitLogError)
+		failf(exitLogError, "--image is required with --check-compat")
 	}
 
-	matched, unmatched, err := client.PreflightCheck(imageName)
+	// Every image of an index is checked, not only the one for this
+	// platform, to tell which of them the GPUs can use.
+	results, err := client.CheckIndexCompat(imageName)
 	if err != nil {
-		logging.Errorf("Preflight check failed: %v", err)
+		logFatal("Preflight check failed", err, exitError)
+	}
+	if results != nil {
+		printIndexCompat(results)
+		for _, r := range results {
+			if r.Compatible() {
+				os.Exit(exitNormal)
+			}
+		}
+		fail(exitError, failure.New(failure.Incompatible, errors.New("no image of the index is compatible with the GPUs")))
 	}
 
+	matched, unmatched, err := client.PreflightCheck(imageName)
 	if len(matched) > 0 {
 		logging.Debugf("Compatible GPU(s) found (%d):", len(matched))
 		logging.Debugf("IDs: %v", matched)
-	} else {
-		logging.Warn("No compatible GPUs found for the image.")
 	}
-
 	if len(unmatched) > 0 {
 		logging.Debugf("Incompatible GPU(s) found (%d):", len(unmatched))
 		logging.Debugf("IDs: %v", unmatched)
 	}
 
-	if err != nil || len(matched) == 0 {
-		logging.Warn("Exiting: no compatible GPU(s) detected or error occurred during compatibility check")
-		os.Exit(exitExtractError)
+	if err == nil && len(matched) == 0 {
+		err = failure.New(failure.Incompatible, errors.New("no compatible GPU(s) detected"))
+	}
+	if err != nil {
+		logFatal("Preflight check failed", err, exitError)
 	}
 	os.Exit(exitNormal)
 }
@@ -206,7 +526,7 @@ func configureBaremetalAndGPU(baremetalFlag, noGPUFlag bool) {
 
 	xpuInfo, err := client.GetXPUInfo()
 	if err != nil || xpuInfo == nil || xpuInfo.Acc == nil || len(xpuInfo.Acc.Devices) == 0 {
-		logging.Warn("No hardware accelerator found. GPU support will be disabled.")
+		diag.Warn(diag.NoAccelerator, "No hardware accelerator found. GPU support will be disabled.")
 		config.SetEnabledGPU(false)
 		return
 	}
@@ -222,40 +542,164 @@ func configureBaremetalAndGPU(baremetalFlag, noGPUFlag bool) {
 	config.SetEnabledGPU(true)
 }
 
-func runCreate(imageName, cacheDir string) {
-	// Check if the cache directory exists
-	if _, err := utils.FilePathExists(cacheDir); err != nil {
-		logging.Errorf("Error checking cache file path: %v", err)
-		os.Exit(exitCreateError)
+func runExtract(imageName, cacheDir, logLevel string, baremetalFlag bool, f extractFlags) {
+	fetcher.OnOverwrite(confirmOverwrite(f.yes || f.force, f.backup))
+	if f.placement != "" && (cacheDir != "" || f.container != "") {
+		failf(exitError, "--placement cannot be used with --dir or --container")
+	}
+	if f.container != "" && cacheDir == "" {
+		failf(exitError, "--dir is required with --container: the cache path inside the container")
+	}
+	if f.requireCompat {
+		if f.readyAddr != "" {
+			failf(exitError, "--ready-addr cannot be used with --require-compat")
+		}
+		runVerifyAndExtract(imageName, cacheDir, logLevel, baremetalFlag, f, true)
+		return
+	}
+	if f.readyAddr != "" {
+		serveExtract(extractOptions(imageName, cacheDir, logLevel, baremetalFlag, f), f)
+		return
+	}
+	_, _, err := client.ExtractCache(extractOptions(imageName, cacheDir, logLevel, baremetalFlag, f))
+	if skipped, ok := fetcher.IsPartial(err); ok {
+		logSkippedEntries(skipped)
+		os.Exit(exitExtractPartial)
+	}
+	if err != nil {
+		logFatal("Error extracting image", err, exitError)
+	}
+}
+
+// logSkippedEntries reports the entries a partial extraction skipped.
+func logSkippedEntries(skipped []cache.SkippedEntry) {
+	diag.Warnf(diag.PartialExtraction, "Cache extracted partially: %d entries were skipped", len(skipped))
+	for _, e := range skipped {
+		diag.Warnf(diag.PartialExtraction, "  %s: %s", e.Name, e.Reason)
 	}
+}
 
-	// Initialize the image builder
-	builder, _ := imgbuild.New()
-	if builder == nil {
-		logging.Errorf("Failed to create builder")
-		os.Exit(exitCreateError)
+// serveExtract extracts the image in the background while serving its
+// readiness on f.readyAddr, then keeps serving until stopped, so that mcv
+// can run as a Kubernetes sidecar whose startup probe holds back the
+// workload until the cache is in place. It exits non-zero if the
+// extraction fails, for the container to be restarted. A partial
+// extraction is served as ready, exiting with exitExtractPartial once
+// stopped.
+func serveExtract(opts client.Options, f extractFlags) {
+	srv, err := probe.New(f.readyWhen)
+	if err == nil {
+		err = srv.Start(f.readyAddr)
+	}
+	if err != nil {
+		fail(exitError, err)
+	}
+	fetcher.OnReady(srv.Reach)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.ExtractCache(opts)
+		done <- err
+	}()
+
+	code := exitNormal
+	select {
+	case err := <-done:
+		skipped, partial := fetcher.IsPartial(err)
+		if partial {
+			logSkippedEntries(skipped)
+			code = exitExtractPartial
+		}
+		if err == nil || partial {
+			logging.Info("Cache extracted, serving readiness until stopped")
+			<-ctx.Done()
+		} else {
+			logging.Errorf("Error extracting image: %v", err)
+			code = exitError
+		}
+	case <-ctx.Done():
+		diag.Warn(diag.ExtractInterrupted, "Stopped before the cache was extracted")
+		code = exitError
 	}
 
-	// Create the OCI image
-	if err := builder.CreateImage(imageName, cacheDir); err != nil {
-		logging.Errorf("Failed to create the OCI image: %v", err)
-		os.Exit(exitCreateError)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		diag.Warnf(diag.ProbeStopFailed, "Failed to stop the probe server: %v", err)
 	}
+	os.Exit(code)
+}
 
-	logging.Info("OCI image created successfully.")
+// runVerifyAndExtract checks GPU compatibility and the image signature
+// and, with extract, extracts the image, printing the outcome of each
+// step as JSON. It exits non-zero if any step failed, or with
+// exitExtractPartial if the extraction skipped entries.
+func runVerifyAndExtract(imageName, cacheDir, logLevel string, baremetalFlag bool, f extractFlags, extract bool) {
+	rep := client.VerifyAndExtract(extractOptions(imageName, cacheDir, logLevel, baremetalFlag, f), extract)
+	// Step details are errors, and may quote URLs with credentials.
+	for i := range rep.Steps {
+		rep.Steps[i].Detail = logformat.Redact(rep.Steps[i].Detail)
+	}
+	for i := range rep.Skipped {
+		rep.Skipped[i].Reason = logformat.Redact(rep.Skipped[i].Reason)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		fail(exitError, err)
+	}
+	if !rep.Success {
+		os.Exit(exitError)
+	}
+	if len(rep.Skipped) > 0 {
+		os.Exit(exitExtractPartial)
+	}
+	os.Exit(exitNormal)
 }
 
-func runExtract(imageName, cacheDir, logLevel string, baremetalFlag bool) {
+func extractOptions(imageName, cacheDir, logLevel string, baremetalFlag bool, f extractFlags) client.Options {
 	gpuEnabled := config.IsGPUEnabled()
-	opts := client.Options{
+	var tmpfsSize int64
+	if f.tmpfsSize != "" {
+		n, err := units.RAMInBytes(f.tmpfsSize)
+		if err != nil || n <= 0 {
+			failf(exitError, "Invalid --tmpfs-size %q", f.tmpfsSize)
+		}
+		tmpfsSize = n
+	}
+	return client.Options{
 		ImageName:       imageName,
 		CacheDir:        cacheDir,
 		EnableGPU:       &gpuEnabled,
 		LogLevel:        logLevel,
 		EnableBaremetal: &baremetalFlag,
-	}
-	if _, _, err := client.ExtractCache(opts); err != nil {
-		logging.Errorf("Error extracting image: %v", err)
-		os.Exit(exitExtractError)
+		Resume:          &f.resume,
+		Force:           &f.force,
+		UpdatePin:       &f.updatePin,
+		ContainerID:     f.container,
+		RuntimeEndpoint: f.runtimeEndpoint,
+		Placement:       f.placement,
+		VLLMKeyMismatch: f.vllmKeyMismatch,
+		MountFSImage:    &f.mount,
+		FileMode:        f.fileMode,
+		DirMode:         f.dirMode,
+		Owner:           f.owner,
+		ExpiredPolicy:   f.expired,
+		SharedLock:      f.sharedLock,
+		LockTimeout:     f.lockTimeout,
+		SignaturePolicy: f.signaturePolicy,
+		ReadyFile:       f.readyFile,
+		EntryErrors:     f.entryErrors,
+		Nice:            f.nice,
+		IONice:          f.ioNice,
+		WriteLimit:      f.writeLimit,
+		Store:           f.store,
+		TmpfsSize:       tmpfsSize,
+		Bucket:          f.bucket,
+		BucketEndpoint:  f.bucketEndpoint,
+		BucketMount:     f.bucketMount,
+		Kernels:         f.kernels,
 	}
 }
@@ -0,0 +1,122 @@
+//go:build !mcv_edge
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/imgbuild"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type migrateFlags struct {
+	from        string
+	to          string
+	cacheDir    string
+	imageName   string
+	outputImage string
+	builder     string
+	dryRun      bool
+}
+
+func newMigrateCacheCommand() *cobra.Command {
+	var f migrateFlags
+
+	cmd := &cobra.Command{
+		Use:   "migrate-cache",
+		Short: "Rewrite a Triton cache to a newer cache layout",
+		Long: `Rewrite an extracted cache directory (--dir) or a packaged cache image
+(--image) from one Triton cache layout to another. Kernels that cannot be
+converted mechanically are reported and need to be recompiled.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runMigrateCache(f)
+		},
+	}
+
+	cmd.Flags().StringVar(&f.from, "from", cache.LayoutV2, "Layout version of the source cache")
+	cmd.Flags().StringVar(&f.to, "to", cache.LayoutV3, "Layout version to migrate to")
+	cmd.Flags().StringVarP(&f.cacheDir, "dir", "d", "", "Extracted Triton cache directory to migrate in place")
+	cmd.Flags().StringVarP(&f.imageName, "image", "i", "", "Cache image to migrate")
+	cmd.Flags().StringVarP(&f.outputImage, "output-image", "o", "", "Name of the migrated image (default: same as --image)")
+	cmd.Flags().StringVar(&f.builder, "builder", "", "Image builder backend used to repackage --image")
+	cmd.Flags().BoolVar(&f.dryRun, "dry-run", false, "Report what would change without writing anything")
+	return cmd
+}
+
+func runMigrateCache(f migrateFlags) {
+	if (f.cacheDir == "") == (f.imageName == "") {
+		failf(exitError, "exactly one of --dir or --image is required")
+	}
+
+	cacheDir := f.cacheDir
+	if f.imageName != "" {
+		if err := validateImageName(f.imageName); err != nil {
+			fail(exitError, err)
+		}
+		tmpDir, err := os.MkdirTemp("", "mcv-migrate-")
+		if err != nil {
+			logFatal("Failed to create temporary directory", err, exitError)
+		}
+		defer utils.RemoveTemp(tmpDir)
+
+		// The image is only unpacked for rewriting, so skip the GPU checks.
+		config.SetEnabledGPU(false)
+		constants.ExtractCacheDir = tmpDir
+		if err := fetcher.New().FetchAndExtractCache(f.imageName); err != nil {
+			logFatal("Error extracting image", err, exitError)
+		}
+		cacheDir = tmpDir
+	}
+
+	report, err := cache.MigrateTritonCache(cacheDir, f.from, f.to, f.dryRun)
+	if err != nil {
+		logFatal("Cache migration failed", err, exitError)
+	}
+	printMigrationReport(report, f.dryRun)
+
+	if f.imageName == "" || f.dryRun {
+		return
+	}
+
+	outputImage := f.outputImage
+	if outputImage == "" {
+		outputImage = f.imageName
+	}
+	if err := validateImageName(outputImage); err != nil {
+		fail(exitError, err)
+	}
+	builder, err := newImageBuilder(f.builder, imgbuild.BuildOptions{BaseImage: config.BaseImage()})
+	if err != nil {
+		logFatal("Failed to create builder", err, exitError)
+	}
+	if err := builder.CreateImage(outputImage, cacheDir); err != nil {
+		logFatal("Failed to create the migrated image", err, exitError)
+	}
+	logging.Infof("Migrated image %s created successfully.", outputImage)
+}
+
+func printMigrationReport(r *cache.MigrationReport, dryRun bool) {
+	verb := "Migrated"
+	if dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Printf("%s: %d kernel(s)\n", verb, len(r.Migrated))
+	for _, p := range r.Migrated {
+		fmt.Printf("  %s\n", p)
+	}
+	fmt.Printf("Already up to date: %d kernel(s)\n", len(r.Unchanged))
+	fmt.Printf("Group files created: %d\n", len(r.GroupsCreated))
+	if len(r.NeedsRecompile) > 0 {
+		fmt.Printf("Needs recompilation: %d kernel(s)\n", len(r.NeedsRecompile))
+		for _, issue := range r.NeedsRecompile {
+			fmt.Printf("  %s: %s\n", issue.Path, issue.Reason)
+		}
+	}
+}
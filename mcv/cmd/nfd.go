@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/hotplug"
+	"github.com/redhat-et/MCU/mcv/pkg/nfd"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+// hotplugSettle is how long device events must stop before the GPUs are
+// detected again.
+const hotplugSettle = 2 * time.Second
+
+// nfdFlags holds the flags of mcv nfd.
+type nfdFlags struct {
+	featureFile string
+	nodeFeature bool
+	node        string
+	namespace   string
+	kubeconfig  string
+	interval    time.Duration
+	leaderElect bool
+	hotplug     bool
+	noGPU       bool
+}
+
+func newNFDCommand() *cobra.Command {
+	var f nfdFlags
+
+	cmd := &cobra.Command{
+		Use:   "nfd",
+		Short: "Publish this node's GPUs and GPU targets to Node Feature Discovery",
+		Long: `Publish the GPUs and GPU targets MCV detects to Node Feature Discovery,
+as a feature file for NFD's local source (--feature-file), as a
+NodeFeature custom resource for NFD v0.14+ (--node-feature), or both.
+With --interval, republish until stopped, and with --watch-hotplug also
+as soon as GPUs are added or removed. With --leader-elect, replicas
+publishing for the same node take turns through a Lease, so only one
+writes.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runNFD(f)
+		},
+	}
+	cmd.Flags().StringVar(&f.featureFile, "feature-file", "", "Write labels to this file, e.g. /etc/kubernetes/node-feature-discovery/features.d/mcv")
+	cmd.Flags().BoolVar(&f.nodeFeature, "node-feature", false, "Publish a NodeFeature custom resource")
+	cmd.Flags().StringVar(&f.node, "node", os.Getenv("NODE_NAME"), "Node name (default $NODE_NAME or the host name)")
+	cmd.Flags().StringVarP(&f.namespace, "namespace", "n", namespaceDefault(), "Namespace of the NodeFeature and Lease (default $POD_NAMESPACE or the MCV namespace)")
+	cmd.Flags().StringVar(&f.kubeconfig, "kubeconfig", config.KubeConfig(), "Kubeconfig file; empty uses the pod's service account")
+	cmd.Flags().DurationVar(&f.interval, "interval", 0, "Republish at this interval until stopped; 0 publishes once")
+	cmd.Flags().BoolVar(&f.leaderElect, "leader-elect", false, "With --node-feature, publish only while holding the node's Lease")
+	cmd.Flags().BoolVar(&f.hotplug, "watch-hotplug", false, "With --interval, detect the GPUs again and republish when GPUs are added or removed")
+	cmd.Flags().BoolVar(&f.noGPU, "no-gpu", false, "Disable GPU logic for testing")
+	return cmd
+}
+
+func namespaceDefault() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	return config.Namespace()
+}
+
+func runNFD(f nfdFlags) {
+	if f.featureFile == "" && !f.nodeFeature {
+		failf(exitError, "nothing to publish: pass --feature-file, --node-feature or both")
+	}
+	if f.leaderElect && !f.nodeFeature {
+		failf(exitError, "--leader-elect needs --node-feature")
+	}
+	if f.hotplug && f.interval == 0 {
+		failf(exitError, "--watch-hotplug needs --interval")
+	}
+	if f.node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			logFatal("Failed to get hostname", err, exitError)
+		}
+		f.node = hostname
+	}
+	configureBaremetalAndGPU(false, f.noGPU)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var dyn dynamic.Interface
+	var leases coordinationv1.CoordinationV1Interface
+	if f.nodeFeature {
+		cfg, err := nfd.RESTConfig(f.kubeconfig)
+		if err == nil {
+			dyn, err = dynamic.NewForConfig(cfg)
+		}
+		if err == nil && f.leaderElect {
+			leases, err = coordinationv1.NewForConfig(cfg)
+		}
+		if err != nil {
+			fail(exitError, err)
+		}
+	}
+
+	var hotplugEvents <-chan hotplug.Event
+	if f.hotplug {
+		events, err := hotplug.Watch(ctx)
+		if err != nil {
+			fail(exitError, err)
+		}
+		hotplugEvents = events
+	}
+
+	publish := func(ctx context.Context) error {
+		for {
+			if err := publishNodeFeatures(ctx, f, dyn); err != nil {
+				return err
+			}
+			if f.interval == 0 {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(f.interval):
+			case ev, ok := <-hotplugEvents:
+				if !ok {
+					hotplugEvents = nil
+					continue
+				}
+				refreshGPUs(hotplug.Settle(ctx, hotplugEvents, ev, hotplugSettle), f.noGPU)
+			}
+		}
+	}
+
+	var err error
+	if f.leaderElect {
+		identity := os.Getenv("POD_NAME")
+		if identity == "" {
+			identity = fmt.Sprintf("%s-%d", f.node, os.Getpid())
+		}
+		err = nfd.RunElected(ctx, leases, f.namespace, "mcv-nfd-"+f.node, identity, publish)
+	} else {
+		err = publish(ctx)
+	}
+	if err != nil {
+		fail(exitError, err)
+	}
+}
+
+// refreshGPUs logs a burst of device events and makes the next host
+// report detect the GPUs again, enabling GPU support if the first GPUs
+// were just added or disabling it if the last were removed.
+func refreshGPUs(events []hotplug.Event, noGPU bool) {
+	for _, ev := range events {
+		device := ev.PCISlot
+		if device == "" {
+			device = ev.DevPath
+		}
+		logging.WithFields(logging.Fields{
+			"action":    ev.Action,
+			"subsystem": ev.Subsystem,
+			"device":    device,
+		}).Info("GPU device event")
+	}
+	logging.Infof("Detecting GPUs again after %d device event(s)", len(events))
+	accelerator.Shutdown()
+	devices.Refresh()
+	configureBaremetalAndGPU(false, noGPU)
+}
+
+func publishNodeFeatures(ctx context.Context, f nfdFlags, dyn dynamic.Interface) error {
+	report, err := client.GetHostReport("")
+	if err != nil {
+		return fmt.Errorf("failed to get host report: %w", err)
+	}
+	if f.featureFile != "" {
+		// Let NFD drop the labels if republishing stops.
+		var ttl time.Duration
+		if f.interval > 0 {
+			ttl = 3 * f.interval
+		}
+		if err := nfd.WriteFeatureFile(f.featureFile, nfd.Labels(report), ttl); err != nil {
+			return err
+		}
+		logging.Infof("Wrote node features to %s", f.featureFile)
+	}
+	if f.nodeFeature {
+		obj := nfd.NodeFeature(f.node, f.namespace, report)
+		if err := nfd.Publish(ctx, dyn, obj); err != nil {
+			return err
+		}
+		logging.Infof("Published NodeFeature %s/%s", f.namespace, obj.GetName())
+	}
+	return nil
+}
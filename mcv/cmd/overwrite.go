@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	logging "github.com/sirupsen/logrus"
+)
+
+// confirmOverwrite returns the hook asked before --extract writes into a
+// directory holding files of another image, or of none: with backup it
+// moves them aside, with yes it overwrites them, and otherwise it asks on
+// the terminal, refusing when there is no one to ask.
+func confirmOverwrite(yes, backup bool) func(dir string, files int) error {
+	return func(dir string, files int) error {
+		if backup {
+			moved, err := fetcher.MoveAside(dir)
+			if err != nil {
+				return err
+			}
+			logging.Infof("Moved the %d file(s) %s held to %s", files, dir, moved)
+			return nil
+		}
+		if yes {
+			return nil
+		}
+		refused := fmt.Errorf("%s already holds %d file(s) that extracting may overwrite; pass --yes to overwrite them or --backup to move them aside", dir, files)
+		if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+			return refused
+		}
+		fmt.Fprintf(os.Stderr, "%s already holds %d file(s) that extracting may overwrite. Overwrite them? [y/N] ", dir, files)
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && answer == "" {
+			// Stdin is /dev/null, as in a container started without one.
+			fmt.Fprintln(os.Stderr)
+			return refused
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return nil
+		}
+		return fmt.Errorf("not extracting into %s: overwriting its files was declined", dir)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/docker/go-units"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// registryFlags holds the registry access flags of push and pull.
+type registryFlags struct {
+	authFile string
+	insecure bool
+}
+
+func addRegistryFlags(cmd *cobra.Command, f *registryFlags) {
+	cmd.Flags().StringVar(&f.authFile, "authfile", os.Getenv("REGISTRY_AUTH_FILE"), "Docker config or containers auth.json to take registry credentials from (default $REGISTRY_AUTH_FILE, else ~/.docker/config.json or podman's auth.json)")
+	cmd.Flags().BoolVar(&f.insecure, "insecure", false, "Allow plain HTTP, and HTTPS without verifying the registry's certificate")
+}
+
+// configureRegistry applies f to every registry call that follows.
+func configureRegistry(f registryFlags, exitCode int) {
+	if f.authFile != "" {
+		if err := registry.SetAuthFile(f.authFile); err != nil {
+			fail(exitCode, err)
+		}
+	}
+	registry.SetInsecure(f.insecure)
+}
+
+func newPushCommand() *cobra.Command {
+	var f registryFlags
+	var mountFrom []string
+
+	cmd := &cobra.Command{
+		Use:   "push IMAGE [DESTINATION]",
+		Short: "Push a locally created cache image to a registry",
+		Long: `Push IMAGE, as created by mcv create or found in the local podman or docker
+image store, to its registry, or to DESTINATION if given. Layers the
+registry already has are not uploaded again. Prints the digest reference
+of the pushed image.`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			dest := args[0]
+			if len(args) == 2 {
+				dest = args[1]
+			}
+			runPush(args[0], dest, mountFrom, f)
+		},
+	}
+	addRegistryFlags(cmd, &f)
+	cmd.Flags().StringArrayVar(&mountFrom, "mount-from", nil, "Repository on the same registry to mount layers from instead of uploading them, e.g. quay.io/example/llama-70b-cache (repeatable)")
+	return cmd
+}
+
+func runPush(src, dest string, mountFrom []string, f registryFlags) {
+	configureRegistry(f, exitError)
+	if imgref.IsDigest(dest) {
+		failf(exitError, "Cannot push to %s: push to a tag, the digest is printed once pushed", dest)
+	}
+	ref, err := registry.ParseReference(dest)
+	if err != nil {
+		failf(exitError, "Invalid destination %s: %w", dest, err)
+	}
+	var repos []name.Repository
+	for _, m := range mountFrom {
+		repo, err := name.NewRepository(m)
+		if err != nil {
+			failf(exitError, "Invalid --mount-from %s: %w", m, err)
+		}
+		repos = append(repos, repo)
+	}
+
+	img, err := fetcher.FetchLocal(src)
+	if err != nil {
+		fail(exitError, err)
+	}
+	ctx, cancel := registry.Context(context.Background(), registry.OpPush)
+	stats, err := registry.Push(ctx, img, ref, repos)
+	err = registry.TimeoutError(ctx, err)
+	cancel()
+	if err != nil {
+		fail(exitError, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		fail(exitError, err)
+	}
+	logging.Infof("Pushed %s: uploaded %d of %d layer(s) (%s), %s already in the registry",
+		dest, stats.Uploaded, stats.Layers, units.HumanSize(float64(stats.UploadedBytes)), units.HumanSize(float64(stats.SkippedBytes)))
+	fmt.Println(imgref.WithDigest(dest, digest.String()))
+}
+
+func newPullCommand() *cobra.Command {
+	var f registryFlags
+
+	cmd := &cobra.Command{
+		Use:   "pull IMAGE",
+		Short: "Pull a cache image into mcv's local image store",
+		Long: `Pull IMAGE from its registry into mcv's local image store, where extract,
+inspect and check-compat find it without contacting the registry again,
+e.g. to stage an image before a maintenance window. Prints the digest
+reference of the pulled image.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runPull(args[0], f)
+		},
+	}
+	addRegistryFlags(cmd, &f)
+	return cmd
+}
+
+func runPull(imageName string, f registryFlags) {
+	configureRegistry(f, exitError)
+	if err := validateImageName(imageName); err != nil {
+		fail(exitError, err)
+	}
+	ref, err := registry.ParseReference(imageName)
+	if err != nil {
+		failf(exitError, "Invalid image %s: %w", imageName, err)
+	}
+	img, err := pullToStore(ref, imageName)
+	if err != nil {
+		fail(exitError, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		fail(exitError, err)
+	}
+	logging.Infof("Pulled %s into %s", imageName, imgstore.Path())
+	fmt.Println(imgref.WithDigest(imageName, digest.String()))
+}
+
+// pullToStore pulls ref, layers included, into the image store as
+// imageName within the pull timeout.
+func pullToStore(ref name.Reference, imageName string) (v1.Image, error) {
+	ctx, cancel := registry.Context(context.Background(), registry.OpPull)
+	defer cancel()
+	img, err := remote.Image(ref, registry.Options(remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", imageName, registry.TimeoutError(ctx, err))
+	}
+	if err := imgstore.Save(img, imageName); err != nil {
+		return nil, registry.TimeoutError(ctx, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/client"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+	logging "github.com/sirupsen/logrus"
+)
+
+// remoteCompatFlags holds the flags check-compat checks another host's
+// GPUs with.
+type remoteCompatFlags struct {
+	ssh         string
+	sshOptions  []string
+	mcvPath     string
+	fingerprint string
+}
+
+// handleRemoteCheckCompat checks imageName against the GPUs of the host
+// --ssh names, or of the report --fingerprint holds, and exits.
+func handleRemoteCheckCompat(imageName string, f remoteCompatFlags) {
+	var rep *fleet.HostReport
+	var err error
+	if f.fingerprint != "" {
+		if rep, err = fleet.LoadHostReport(f.fingerprint); err != nil {
+			fail(exitError, err)
+		}
+	} else {
+		host, err := fleet.ParseHost(f.ssh, f.mcvPath)
+		if err != nil {
+			failf(exitLogError, "invalid --ssh: %w", err)
+		}
+		var sshArgs []string
+		for _, o := range f.sshOptions {
+			sshArgs = append(sshArgs, "-o", o)
+		}
+		logging.Infof("Listing the GPUs of %s over SSH", host.Name)
+		if rep, err = fleet.SSHRunner(sshArgs...)(context.Background(), host, ""); err != nil {
+			logFatal("Failed to get host report of "+host.Name, err, exitError)
+		}
+	}
+	name := rep.Hostname
+	if name == "" {
+		name = f.ssh
+	}
+	logging.Infof("Checking %s against %s (%s)", imageName, name, orDash(strings.Join(rep.Targets, ", ")))
+
+	results, err := client.CheckHostCompat(imageName, rep)
+	if err != nil {
+		logFatal("Preflight check failed", err, exitError)
+	}
+	printIndexCompat(results)
+	for _, r := range results {
+		if r.Compatible() {
+			os.Exit(exitNormal)
+		}
+	}
+	fail(exitError, failure.New(failure.Incompatible, fmt.Errorf("no GPU of %s can use %s", name, imageName)))
+}
@@ -0,0 +1,150 @@
+//go:build !mcv_edge
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/imgbuild"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// splitByArch splits an image by the GPU target of its kernels.
+const splitByArch = "arch"
+
+// archPlaceholder stands for the target's name in split image names.
+const archPlaceholder = "{arch}"
+
+type splitFlags struct {
+	imageName string
+	by        string
+	output    string
+	builder   string
+	labels    []string
+	dryRun    bool
+}
+
+func newSplitCommand() *cobra.Command {
+	var f splitFlags
+
+	cmd := &cobra.Command{
+		Use:   "split",
+		Short: "Split a cache image into one image per GPU architecture",
+		Long: `Split a cache image holding kernels built for several GPU architectures
+into one image per architecture, for registries and fleets that prefer
+homogeneous images. Each image holds the kernels of its architecture and
+every file that belongs to no kernel, and gets the labels of its own
+kernels.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runSplit(f)
+		},
+	}
+
+	cmd.Flags().StringVarP(&f.imageName, "image", "i", "", "Cache image to split")
+	cmd.Flags().StringVar(&f.by, "by", splitByArch, "What to split the image by: "+splitByArch)
+	cmd.Flags().StringVarP(&f.output, "output-image", "o", "", "Name of the split images, with "+archPlaceholder+" standing for the architecture, e.g. sm90 or gfx942 (default: --image's tag suffixed with -"+archPlaceholder+")")
+	cmd.Flags().StringVar(&f.builder, "builder", "", "Image builder backend used to build the split images")
+	cmd.Flags().StringArrayVar(&f.labels, "label", nil, "Extra image label key=value for the split images (repeatable)")
+	cmd.Flags().BoolVar(&f.dryRun, "dry-run", false, "List the images that would be built without building them")
+	return cmd
+}
+
+// splitImageName returns the name of the image of arch split from
+// imageName, following template if set.
+func splitImageName(imageName, template, arch string) string {
+	if template == "" {
+		template = imgref.Repository(imageName) + ":" + archPlaceholder
+		if tag := imgref.Tag(imageName); tag != "" {
+			template = imgref.Repository(imageName) + ":" + tag + "-" + archPlaceholder
+		}
+	}
+	return strings.ReplaceAll(template, archPlaceholder, arch)
+}
+
+func runSplit(f splitFlags) {
+	if err := validateImageName(f.imageName); err != nil {
+		fail(exitError, err)
+	}
+	if f.by != splitByArch {
+		failf(exitError, "Unsupported --by %q (supported: %s)", f.by, splitByArch)
+	}
+	if f.output != "" && !strings.Contains(f.output, archPlaceholder) {
+		failf(exitError, "--output-image must contain %s to name each image apart", archPlaceholder)
+	}
+	labels, err := imgbuild.ParseLabels(f.labels)
+	if err != nil {
+		fail(exitError, err)
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[cache.SplitFromLabel] = f.imageName
+
+	tmpDir, err := os.MkdirTemp("", "mcv-split-")
+	if err != nil {
+		logFatal("Failed to create temporary directory", err, exitError)
+	}
+	defer utils.RemoveTemp(tmpDir)
+
+	// The image is only unpacked for repackaging, so skip the GPU checks.
+	config.SetEnabledGPU(false)
+	cacheDir := filepath.Join(tmpDir, "cache")
+	constants.ExtractCacheDir = cacheDir
+	if err := fetcher.New().FetchAndExtractCache(f.imageName); err != nil {
+		logFatal("Error extracting image", err, exitError)
+	}
+	// Extraction resolved the embedded paths to cacheDir, which the copies
+	// of each architecture do not share.
+	if _, err := cache.CanonicalizePaths(cacheDir, []string{cacheDir}); err != nil {
+		logFatal("Failed to make the cache relocatable", err, exitError)
+	}
+
+	groups := cache.GroupKernelsByArch(cacheDir)
+	switch len(groups) {
+	case 0:
+		failf(exitError, "%s records no kernel architectures to split by", f.imageName)
+	case 1:
+		logging.Infof("%s only holds %s kernels, nothing to split", f.imageName, groups[0].Name)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARCH\tBACKEND\tKERNELS\tIMAGE")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", g.Name, g.Backend, len(g.Dirs), splitImageName(f.imageName, f.output, g.Name))
+	}
+	w.Flush()
+	if f.dryRun {
+		return
+	}
+
+	for _, g := range groups {
+		outputImage := splitImageName(f.imageName, f.output, g.Name)
+		if err := validateImageName(outputImage); err != nil {
+			fail(exitError, err)
+		}
+		archDir := filepath.Join(tmpDir, g.Name)
+		if err := cache.CopyDirExcluding(cacheDir, archDir, cache.OtherArchDirs(groups, g.Name)); err != nil {
+			failf(exitError, "Failed to stage the %s kernels: %w", g.Name, err)
+		}
+		builder, err := newImageBuilder(f.builder, imgbuild.BuildOptions{BaseImage: config.BaseImage(), ExtraLabels: labels})
+		if err != nil {
+			logFatal("Failed to create builder", err, exitError)
+		}
+		if err := builder.CreateImage(outputImage, archDir); err != nil {
+			failf(exitError, "Failed to create the %s image: %w", g.Name, err)
+		}
+		logging.Infof("Image %s created with the %d %s kernels.", outputImage, len(g.Dirs), g.Name)
+	}
+}
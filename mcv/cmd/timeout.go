@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func addTimeoutFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Duration("timeout", config.Timeout(), "How long all registry operations may take together, counted from mcv's start; 0 for no limit")
+	cmd.PersistentFlags().Duration("pull-timeout", config.PullTimeout(), "How long pulling an image, layers included, may take; 0 for no limit")
+	cmd.PersistentFlags().Duration("push-timeout", config.PushTimeout(), "How long pushing or copying an image, or uploading to object storage, may take; 0 for no limit")
+	cmd.PersistentFlags().Duration("sign-timeout", config.SignTimeout(), "How long copying an image's signatures and attestations may take; 0 for no limit")
+	cmd.PersistentFlags().Duration("verify-timeout", config.VerifyTimeout(), "How long verifying an image's signature may take; 0 for no limit")
+}
+
+// configureTimeouts sets the timeouts given on the command line, or in the
+// environment, in place of the configured ones.
+func configureTimeouts(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	for name, set := range map[string]func(time.Duration){
+		"timeout":        config.SetTimeout,
+		"pull-timeout":   config.SetPullTimeout,
+		"push-timeout":   config.SetPushTimeout,
+		"sign-timeout":   config.SetSignTimeout,
+		"verify-timeout": config.SetVerifyTimeout,
+	} {
+		if flags.Changed(name) {
+			d, _ := flags.GetDuration(name)
+			set(d)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/nfd"
+	"github.com/redhat-et/MCU/mcv/pkg/sigverify"
+	"github.com/redhat-et/MCU/mcv/pkg/trust"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+type trustFlags struct {
+	configMap string
+	secret    string
+}
+
+func addTrustFlags(cmd *cobra.Command, f *trustFlags) {
+	cmd.PersistentFlags().StringVar(&f.configMap, "trust-configmap", "", "Load the signature policy and keys from this ConfigMap, [namespace/]name, and reload them when it changes")
+	cmd.PersistentFlags().StringVar(&f.secret, "trust-secret", "", "Load the signature policy and keys from this Secret, [namespace/]name, and reload them when it changes")
+}
+
+// configureTrust makes the trust material in the ConfigMap and Secret the
+// signature policy, and keeps it up to date while mcv runs. It fails
+// rather than fall back to the host's policy when they can't be read.
+func configureTrust(f trustFlags) error {
+	if f.configMap == "" && f.secret == "" {
+		return nil
+	}
+	syncer := &trust.Syncer{Dir: trust.Dir{Root: constants.TrustDir}}
+	if f.configMap != "" {
+		ref, err := trust.ParseRef(f.configMap, namespaceDefault())
+		if err != nil {
+			return fmt.Errorf("--trust-configmap: %w", err)
+		}
+		syncer.ConfigMap = &ref
+	}
+	if f.secret != "" {
+		ref, err := trust.ParseRef(f.secret, namespaceDefault())
+		if err != nil {
+			return fmt.Errorf("--trust-secret: %w", err)
+		}
+		syncer.Secret = &ref
+	}
+
+	restCfg, err := nfd.RESTConfig(config.KubeConfig())
+	if err != nil {
+		return err
+	}
+	if syncer.Kube, err = kubernetes.NewForConfig(restCfg); err != nil {
+		return fmt.Errorf("failed to create the Kubernetes client: %w", err)
+	}
+	if _, err := syncer.Sync(context.Background()); err != nil {
+		return err
+	}
+	sigverify.UseTrust(syncer.Dir.PolicyPath(), syncer.Dir.RegistriesDir())
+	go syncer.Run(context.Background())
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/capture"
+	logging "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newUsageCommand() *cobra.Command {
+	var cacheDir, output string
+	var interval, duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "usage -d DIR",
+		Short: "Track which kernels of an extracted cache workloads use",
+		Long: `Sample the access times of the files in an extracted kernel cache every
+--interval and record the kernels read since tracking started, e.g. as a
+sidecar of an inference server. The result is written to --output after
+each sample, and tracking resumes from it when restarted. It is a capture
+file: package only the used kernels with mcv --create --filter-from, and
+find those never used under "unused". Tracking ends after --duration or
+on interrupt.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runUsage(cacheDir, output, interval, duration)
+		},
+	}
+	cmd.Flags().StringVarP(&cacheDir, "dir", "d", "", "Extracted kernel cache directory to track, e.g. ~/.triton/cache")
+	cmd.Flags().StringVarP(&output, "output", "o", "usage.json", "File to write the usage to")
+	cmd.Flags().DurationVar(&interval, "interval", time.Minute, "How often to sample access times")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Stop tracking after this long (default: until interrupted)")
+	_ = cmd.MarkFlagRequired("dir")
+	return cmd
+}
+
+func runUsage(cacheDir, output string, interval, duration time.Duration) {
+	if interval <= 0 {
+		failf(exitError, "--interval must be positive")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	prev, err := capture.Load(output)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fail(exitError, err)
+	}
+	tracker, err := capture.NewTracker(cacheDir, prev)
+	if err != nil {
+		fail(exitError, err)
+	}
+	logging.Infof("Tracking kernel cache use in %s every %s", cacheDir, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+		}
+		n, err := tracker.Sample()
+		if err != nil {
+			fail(exitError, err)
+		}
+		if n > 0 {
+			logging.Debugf("%d cache entries read since the last sample", n)
+		}
+		if err := tracker.Capture().Save(output); err != nil {
+			fail(exitError, err)
+		}
+	}
+
+	c := tracker.Capture()
+	fmt.Printf("%d of %d cache entries used since %s; written to %s\n",
+		len(c.Entries), len(c.Entries)+len(c.Unused), c.Started.Format(time.RFC3339), output)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/build"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/fips"
+	"github.com/spf13/cobra"
+)
+
+// versionInfo is what mcv version reports.
+type versionInfo struct {
+	build.Info
+	ManifestSchemas []string `json:"manifestSchemas"`
+	DeviceBackends  []string `json:"deviceBackends"`
+	FIPS            fipsInfo `json:"fips"`
+}
+
+type fipsInfo struct {
+	Required      bool `json:"required"`
+	ModuleEnabled bool `json:"moduleEnabled"`
+}
+
+func newVersionCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the version of mcv and the components it was built with",
+		Run: func(cmd *cobra.Command, args []string) {
+			runVersion(output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+func runVersion(output string) {
+	info := versionInfo{
+		Info:           build.Get(),
+		DeviceBackends: devices.Backends(),
+		FIPS:           fipsInfo{Required: config.IsFIPSRequired(), ModuleEnabled: fips.Enabled()},
+	}
+	for _, mt := range fetcher.ManifestMediaTypes {
+		info.ManifestSchemas = append(info.ManifestSchemas, string(mt))
+	}
+
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			fail(exitLogError, err)
+		}
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Version:\t%s\n", info.Version)
+		fmt.Fprintf(w, "Revision:\t%s\n", info.Revision)
+		fmt.Fprintf(w, "Branch:\t%s\n", info.Branch)
+		fmt.Fprintf(w, "Flavor:\t%s\n", info.Flavor)
+		fmt.Fprintf(w, "Go version:\t%s\n", info.GoVersion)
+		fmt.Fprintf(w, "OS/Arch:\t%s/%s\n", info.OS, info.Arch)
+		names := make([]string, 0, len(info.Components))
+		for name := range info.Components {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s:\t%s\n", name, info.Components[name])
+		}
+		fmt.Fprintf(w, "Manifest schemas:\t%s\n", strings.Join(info.ManifestSchemas, ", "))
+		fmt.Fprintf(w, "Device backends:\t%s\n", strings.Join(info.DeviceBackends, ", "))
+		w.Flush()
+		fmt.Println(fips.Report(info.FIPS.Required))
+	default:
+		failf(exitLogError, "Unknown output format %q: must be text or json", output)
+	}
+}
@@ -5,13 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/redhat-et/MCU/mcv/pkg/config"
-	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -199,6 +197,16 @@ func TranslateGPUToArch(productName string) string {
 	}
 }
 
+// The amd-smi command lines the AMD backend runs.
+var (
+	amdSMIStatic = []string{"amd-smi", "static", "--json"}
+	amdSMIList   = []string{"amd-smi", "list", "--json"}
+)
+
+func recordAMD(ctx context.Context, f *Fixture) {
+	recordTools(ctx, f, amdSMIStatic, amdSMIList)
+}
+
 func amdCheck(r *Registry) {
 	if err := initAMDLib(); err != nil {
 		logging.Debugf("Error initializing AMD SMI: %v", err)
@@ -228,7 +236,7 @@ func amdDeviceStartup() Device {
 }
 
 func initAMDLib() error {
-	if utils.HasApp("amd-smi") {
+	if hasTool("amd-smi") {
 		return nil
 	}
 	return errors.New("couldn't find amd-smi")
@@ -262,30 +270,61 @@ func (r *gpuAMD) Init() error {
 	for gpuID, info := range gpuInfoList.GPUInfo {
 		memTotal := calculateMemoryMB(info.VRAM.Size.Value, info.VRAM.Size.Unit)
 		name := "card" + strconv.Itoa(gpuID)
-		prodName, _ := GetProductName(gpuID) // TODO error checking in the future
+		prodName, _ := productName(gpuID) // TODO error checking in the future
+		virt, profile := sriovVirtualization(info.Bus.BDF)
 		r.devices[gpuID] = GPUDevice{
 			ID: gpuID,
 			TritonInfo: TritonGPUInfo{
 				Name:              name,
 				UUID:              gpuInfoList.ListInfo[gpuID].UniqueID,
 				ComputeCapability: "",
-				Arch:              TranslateGPUToArch(info.Board.ProductName),
+				Arch:              amdArch(info),
 				WarpSize:          64,
 				MemoryTotalMB:     memTotal,
 				Backend:           "hip",
+				Virtualization:    virt,
+				Profile:           profile,
+				PCIBusID:          info.Bus.BDF,
+				ECC:               amdECC(info.RAS),
 				ID:                gpuID,
 			},
 			Summary: DeviceSummary{
-				ID:            strconv.Itoa(gpuID),
-				ProductName:   prodName,
-				DriverVersion: gpuInfoList.GPUInfo[gpuID].Driver.Version,
+				ID:             strconv.Itoa(gpuID),
+				ProductName:    prodName,
+				DriverVersion:  gpuInfoList.GPUInfo[gpuID].Driver.Version,
+				Virtualization: virt,
+				Profile:        profile,
 			},
 		}
 	}
+	assignCanonicalIDs(r.devices)
 	return nil
 }
 
+// amdArch returns the GFX architecture of a GPU, as amd-smi reports it
+// (e.g. gfx942 on MI300X), or else as guessed from its product name.
+func amdArch(info *AMDCardInfo) string {
+	if strings.HasPrefix(info.ASIC.TargetGraphicsVersion, "gfx") {
+		return info.ASIC.TargetGraphicsVersion
+	}
+	return TranslateGPUToArch(info.Board.ProductName)
+}
+
 // Converts VRAM size to MB, handling different units
+// amdECC returns whether ECC is enabled on any memory block, empty when
+// amd-smi reports no block states.
+func amdECC(ras AMDRAS) string {
+	if len(ras.ECCBlockState) == 0 {
+		return ""
+	}
+	for _, state := range ras.ECCBlockState {
+		if strings.EqualFold(state, "ENABLED") {
+			return ECCEnabled
+		}
+	}
+	return ECCDisabled
+}
+
 func calculateMemoryMB(value int, unit string) uint64 {
 	switch unit {
 	case "GB":
@@ -316,8 +355,7 @@ func getAllAMDGPUInfo(ctx context.Context) (*AMDGPUInfo, error) {
 }
 
 func getAMDGPUInfo(ctx context.Context) (map[int]*AMDCardInfo, error) {
-	cmd := exec.CommandContext(ctx, "amd-smi", "static", "--json")
-	output, err := cmd.Output()
+	output, err := runTool(ctx, amdSMIStatic)
 	if err != nil {
 		logging.Debugf("failed to execute amd-smi: %v", err)
 		return nil, fmt.Errorf("failed to execute amd-smi: %v", err)
@@ -337,8 +375,7 @@ func getAMDGPUInfo(ctx context.Context) (map[int]*AMDCardInfo, error) {
 }
 
 func getAMDListInfo(ctx context.Context) (map[int]*AMDListInfo, error) {
-	cmd := exec.CommandContext(ctx, "amd-smi", "list", "--json")
-	output, err := cmd.Output()
+	output, err := runTool(ctx, amdSMIList)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute amd-smi: %v", err)
 	}
@@ -357,10 +394,9 @@ func getAMDListInfo(ctx context.Context) (map[int]*AMDListInfo, error) {
 
 func (r *gpuAMD) GetAllGPUInfo() ([]TritonGPUInfo, error) {
 	var allTritonInfo []TritonGPUInfo
-	for gpuID := range r.devices {
-		dev := r.devices[gpuID]
+	for _, dev := range canonicalOrder(r.devices) {
 		allTritonInfo = append(allTritonInfo, dev.TritonInfo)
-		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
+		logging.Debugf("GPU %d: %+v", dev.ID, dev.TritonInfo)
 	}
 	return allTritonInfo, nil
 }
@@ -384,10 +420,9 @@ func (r *gpuAMD) GetAllSummaries() ([]DeviceSummary, error) {
 
 	// Fallback to default behavior if cache is unavailable
 	var allAccInfo []DeviceSummary
-	for gpuID := range r.devices {
-		dev := r.devices[gpuID]
+	for _, dev := range canonicalOrder(r.devices) {
 		allAccInfo = append(allAccInfo, dev.Summary)
-		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
+		logging.Debugf("GPU %d: %+v", dev.ID, dev.TritonInfo)
 	}
 	return allAccInfo, nil
 }
@@ -16,6 +16,7 @@ limitations under the License.
 package devices
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -25,6 +26,7 @@ import (
 	"time"
 
 	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	logging "github.com/sirupsen/logrus"
 	"golang.org/x/exp/maps"
 )
@@ -34,11 +36,10 @@ const (
 	AMD
 	NVML
 	ROCM
+	SYSFS
+	TEGRA
 )
 
-const cacheFilePath = "/tmp/device_cache.json"
-const cacheTTL = 10 * time.Minute // Cache Time-To-Live
-
 var (
 	deviceRegistry *Registry
 	once           sync.Once
@@ -66,7 +67,7 @@ type CachedDevice struct {
 }
 
 func (d DeviceType) String() string {
-	return [...]string{"MOCK", "AMD", "NVML", "ROCM"}[d]
+	return [...]string{"MOCK", "AMD", "NVML", "ROCM", "SYSFS", "TEGRA"}[d]
 }
 
 type Device interface {
@@ -91,9 +92,12 @@ type Device interface {
 }
 
 type DeviceSummary struct {
-	ID            string
-	DriverVersion string
-	ProductName   string
+	ID             string
+	CanonicalID    int // Position of the GPU in PCI address order, see TritonGPUInfo.CanonicalID
+	DriverVersion  string
+	ProductName    string
+	Virtualization string
+	Profile        string
 }
 
 type GPUFleetSummary struct {
@@ -101,20 +105,52 @@ type GPUFleetSummary struct {
 }
 
 type GPUGroup struct {
-	GPUType       string `json:"gpuType" yaml:"gpuType"`
-	DriverVersion string `json:"driverVersion" yaml:"driverVersion"`
-	IDs           []int  `json:"ids" yaml:"ids"`
+	GPUType        string `json:"gpuType" yaml:"gpuType"`
+	DriverVersion  string `json:"driverVersion" yaml:"driverVersion"`
+	Virtualization string `json:"virtualization,omitempty" yaml:"virtualization,omitempty"`
+	Profile        string `json:"profile,omitempty" yaml:"profile,omitempty"`
+	IDs            []int  `json:"ids" yaml:"ids"`
 }
 
 // Registry gets the default device Registry instance
 func GetRegistry() *Registry {
 	once.Do(func() {
+		if path := config.DeviceFixture(); path != "" && !replaying {
+			if f, err := LoadFixture(path); err != nil {
+				logging.Error(err)
+			} else {
+				logging.Infof("Replaying the devices recorded in %s", path)
+				useFixture(f)
+			}
+		}
 		deviceRegistry = newRegistry()
 		registerDevices(deviceRegistry)
 	})
 	return deviceRegistry
 }
 
+// Refresh forgets the detected GPUs, after GPUs were added or removed:
+// it removes the device cache and makes the next GetRegistry pick the
+// backends again, as the GPUs now present may need another one.
+func Refresh() {
+	if err := os.Remove(config.DeviceCache()); err != nil && !os.IsNotExist(err) {
+		diag.Warnf(diag.DeviceCacheCleanup, "Failed to remove device cache: %v", err)
+	}
+	deviceRegistry = nil
+	once = sync.Once{}
+}
+
+// Backends returns the GPU backends compiled into mcv. Which of them work
+// on a host depends on the vendor libraries installed; SYSFS is the
+// fallback when none are, and TEGRA is used on Jetson modules.
+func Backends() []string {
+	var names []string
+	for _, b := range libraryBackends {
+		names = append(names, b.dtype.String())
+	}
+	return append(names, SYSFS.String(), TEGRA.String())
+}
+
 // NewRegistry creates a new instance of Registry without registering devices
 func newRegistry() *Registry {
 	return &Registry{
@@ -130,12 +166,31 @@ func SetRegistry(registry *Registry) {
 	registerDevices(deviceRegistry)
 }
 
+// libraryBackend registers a backend if its library or tool works on the
+// host. record adds the backend's library calls on this host to a
+// fixture, and replay makes the backend read them from a fixture instead,
+// until the function it returns is called.
+type libraryBackend struct {
+	dtype  DeviceType
+	check  func(*Registry)
+	record func(context.Context, *Fixture)
+	replay func(*Fixture) func()
+}
+
 // Register all available devices in the global registry
 func registerDevices(r *Registry) {
-	// Call individual device check functions
-	amdCheck(r)
-	nvmlCheck(r)
-	rocmCheck(r)
+	// Jetson GPUs are part of the SoC, out of reach of the vendor
+	// libraries and the PCI devices in sysfs.
+	if !replaying && tegraCheck(r) {
+		return
+	}
+	for _, b := range libraryBackends {
+		b.check(r)
+	}
+	// Last, as it only registers if none of the above did.
+	if !replaying {
+		sysfsCheck(r)
+	}
 }
 
 func (r *Registry) MustRegister(a string, d DeviceType, deviceStartup deviceStartupFunc) {
@@ -192,8 +247,17 @@ func addDeviceInterface(registry *Registry, dtype DeviceType, accType string, de
 	return nil
 }
 
+var errCacheDisabled = errors.New("device cache disabled")
+
+// loadCache returns the cached devices, unless they are older than the
+// configured TTL or caching is disabled with a TTL of 0. Replayed devices
+// are never cached.
 func loadCache() (*DeviceCache, error) {
-	file, err := os.Open(cacheFilePath)
+	ttl := config.DeviceCacheTTL()
+	if ttl <= 0 || replaying || config.DeviceFixture() != "" {
+		return nil, errCacheDisabled
+	}
+	file, err := os.Open(config.DeviceCache())
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +269,7 @@ func loadCache() (*DeviceCache, error) {
 	}
 
 	// Check if the cache is expired
-	if time.Since(cache.Timestamp) > cacheTTL {
+	if time.Since(cache.Timestamp) > ttl {
 		return nil, errors.New("cache expired")
 	}
 
@@ -216,6 +280,9 @@ func loadCache() (*DeviceCache, error) {
 }
 
 func saveCache(devices map[string]Device) error {
+	if config.DeviceCacheTTL() <= 0 || replaying || config.DeviceFixture() != "" {
+		return nil
+	}
 	cache := DeviceCache{
 		Timestamp: time.Now(),
 		Devices:   make(map[string]CachedDevice),
@@ -244,7 +311,7 @@ func saveCache(devices map[string]Device) error {
 		}
 	}
 
-	file, err := os.Create(cacheFilePath)
+	file, err := os.Create(config.DeviceCache())
 	if err != nil {
 		return err
 	}
@@ -313,22 +380,26 @@ func SummarizeGPUs() (*GPUFleetSummary, error) {
 		return nil, err
 	}
 
-	// Group by (ProductName, DriverVersion)
+	// Group by (ProductName, DriverVersion, Virtualization, Profile)
 	type key struct {
 		product string
 		driver  string
+		virt    string
+		profile string
 	}
 	groups := map[key]*GPUGroup{}
 
 	for _, s := range summaries {
 		idInt, _ := strconv.Atoi(s.ID) // IDs are strings in DeviceSummary; best-effort parse
 
-		k := key{product: s.ProductName, driver: s.DriverVersion}
+		k := key{product: s.ProductName, driver: s.DriverVersion, virt: s.Virtualization, profile: s.Profile}
 		if _, ok := groups[k]; !ok {
 			groups[k] = &GPUGroup{
-				GPUType:       s.ProductName,
-				DriverVersion: s.DriverVersion,
-				IDs:           []int{},
+				GPUType:        s.ProductName,
+				DriverVersion:  s.DriverVersion,
+				Virtualization: s.Virtualization,
+				Profile:        s.Profile,
+				IDs:            []int{},
 			}
 		}
 		groups[k].IDs = append(groups[k].IDs, idInt)
@@ -342,6 +413,9 @@ func SummarizeGPUs() (*GPUFleetSummary, error) {
 	}
 	sort.Slice(out.GPUs, func(i, j int) bool {
 		if out.GPUs[i].GPUType == out.GPUs[j].GPUType {
+			if out.GPUs[i].DriverVersion == out.GPUs[j].DriverVersion {
+				return out.GPUs[i].Profile < out.GPUs[j].Profile
+			}
 			return out.GPUs[i].DriverVersion < out.GPUs[j].DriverVersion
 		}
 		return out.GPUs[i].GPUType < out.GPUs[j].GPUType
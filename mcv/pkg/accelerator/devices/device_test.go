@@ -0,0 +1,33 @@
+package devices
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceCache(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "device_cache.json")
+	dev := &stubDevice{infos: []TritonGPUInfo{{ID: 0, Arch: "90"}}}
+
+	config.SetDeviceCache(path, 0)
+	assert.NoError(t, saveCache(map[string]Device{config.GPU: dev}))
+	assert.NoFileExists(t, path)
+	_, err = loadCache()
+	assert.ErrorIs(t, err, errCacheDisabled)
+
+	config.SetDeviceCache(path, time.Hour)
+	assert.NoError(t, saveCache(map[string]Device{config.GPU: dev}))
+	cache, err := loadCache()
+	assert.NoError(t, err)
+	assert.Equal(t, "90", cache.Devices[config.GPU].TritonInfo[0].Arch)
+
+	config.SetDeviceCache(path, time.Nanosecond)
+	_, err = loadCache()
+	assert.ErrorContains(t, err, "cache expired")
+}
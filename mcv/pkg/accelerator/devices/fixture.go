@@ -0,0 +1,174 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+)
+
+// Fixture records what the GPU backends read from the device libraries and
+// tools of one host, so GPU detection and the compatibility checks built
+// on it can be replayed without the GPUs, e.g. in CI. Record one with
+// mcv --hw-info --record-devices and replay it with MCV_DEVICE_FIXTURE.
+type Fixture struct {
+	// Host describes the recorded host, e.g. "8x H100 SXM5, driver 550.54.15".
+	Host string `json:"host"`
+	// NVML holds the results of the NVML queries, nil without NVML.
+	NVML *NVMLFixture `json:"nvml,omitempty"`
+	// Tools maps the command lines run, e.g. "amd-smi static --json", to
+	// their output.
+	Tools map[string]string `json:"tools,omitempty"`
+	// ProductNames are the PCI product names of the accelerators, in order.
+	ProductNames []string `json:"productNames,omitempty"`
+}
+
+// NVMLFixture records the NVML queries of the NVML backend.
+type NVMLFixture struct {
+	DriverVersion string              `json:"driverVersion"`
+	Devices       []NVMLDeviceFixture `json:"devices"`
+}
+
+// NVMLDeviceFixture records the NVML queries about one GPU.
+type NVMLDeviceFixture struct {
+	Name                  string `json:"name"`
+	UUID                  string `json:"uuid"`
+	CudaComputeCapability [2]int `json:"cudaComputeCapability"`
+	MemoryTotal           uint64 `json:"memoryTotal"` // bytes
+	VGPU                  bool   `json:"vgpu,omitempty"`
+	PCIBusID              string `json:"pciBusId"`      // e.g. 0000:07:00.0
+	ECC                   string `json:"ecc,omitempty"` // ECCEnabled or ECCDisabled, empty without ECC memory
+}
+
+// The host's device tools and PCI devices, replaced while replaying a
+// fixture.
+var (
+	runTool     = execTool
+	hasTool     = utils.HasApp
+	productName = GetProductName
+	replaying   bool
+)
+
+func execTool(ctx context.Context, cmdline []string) ([]byte, error) {
+	return exec.CommandContext(ctx, cmdline[0], cmdline[1:]...).Output()
+}
+
+// LoadFixture reads a fixture written by WriteFixture.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device fixture: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse device fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// WriteFixture writes f to path as JSON.
+func WriteFixture(path string, f *Fixture) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// RecordFixture queries the device libraries and tools of this host the
+// way the GPU backends do, and returns what they returned.
+func RecordFixture(ctx context.Context) *Fixture {
+	f := &Fixture{Tools: map[string]string{}}
+	for _, b := range libraryBackends {
+		if b.record != nil {
+			b.record(ctx, f)
+		}
+	}
+	for i := 0; ; i++ {
+		name, err := productName(i)
+		if err != nil {
+			break
+		}
+		f.ProductNames = append(f.ProductNames, name)
+	}
+	if len(f.Tools) == 0 {
+		f.Tools = nil
+	}
+	return f
+}
+
+// recordTools runs the command lines of a tool backend, if the tool is
+// installed, and records their output.
+func recordTools(ctx context.Context, f *Fixture, cmdlines ...[]string) {
+	for _, cmdline := range cmdlines {
+		if !hasTool(cmdline[0]) {
+			return
+		}
+		out, err := runTool(ctx, cmdline)
+		if err != nil {
+			diag.Warnf(diag.DeviceRecording, "Not recording %s: %v", strings.Join(cmdline, " "), err)
+			continue
+		}
+		f.Tools[strings.Join(cmdline, " ")] = string(out)
+	}
+}
+
+// UseFixture makes GPU detection replay f instead of querying the host,
+// until the returned function is called. The device cache is not used
+// meanwhile, and the Jetson and sysfs backends, which read files rather
+// than libraries, are not tried.
+func UseFixture(f *Fixture) (restore func()) {
+	undo := useFixture(f)
+	deviceRegistry = nil
+	once = sync.Once{}
+	return func() {
+		undo()
+		deviceRegistry = nil
+		once = sync.Once{}
+	}
+}
+
+func useFixture(f *Fixture) (undo func()) {
+	savedRun, savedHas, savedName, savedReplaying := runTool, hasTool, productName, replaying
+	runTool = func(_ context.Context, cmdline []string) ([]byte, error) {
+		out, ok := f.Tools[strings.Join(cmdline, " ")]
+		if !ok {
+			return nil, fmt.Errorf("%s is not in the device fixture", strings.Join(cmdline, " "))
+		}
+		return []byte(out), nil
+	}
+	hasTool = func(name string) bool {
+		for cmdline := range f.Tools {
+			if strings.HasPrefix(cmdline, name+" ") {
+				return true
+			}
+		}
+		return false
+	}
+	productName = func(id int) (string, error) {
+		if id < 0 || id >= len(f.ProductNames) {
+			return "", fmt.Errorf("PCI device information unavailable")
+		}
+		return f.ProductNames[id], nil
+	}
+	replaying = true
+
+	var undoBackends []func()
+	for _, b := range libraryBackends {
+		if b.replay != nil {
+			undoBackends = append(undoBackends, b.replay(f))
+		}
+	}
+	return func() {
+		for _, u := range undoBackends {
+			u()
+		}
+		runTool, hasTool, productName, replaying = savedRun, savedHas, savedName, savedReplaying
+	}
+}
@@ -0,0 +1,84 @@
+package devices_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices/fixtures"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		fixture  string
+		backend  string
+		arch     string
+		warpSize int
+		ptx      int
+		memoryMB uint64
+		product  string
+		driver   string
+		ecc      string
+	}{
+		{"a100-sxm4-80gb", "cuda", "80", 32, 535, 81920, "A100", "535.104.05", devices.ECCEnabled},
+		{"h100-sxm5-80gb", "cuda", "90", 32, 550, 81559, "H100", "550.54.15", devices.ECCEnabled},
+		{"mi250x", "hip", "gfx90a", 64, 0, 65520, "MI250X", "6.1.5", ""},
+		{"mi300x", "hip", "gfx942", 64, 0, 196592, "MI300X", "6.8.5", devices.ECCEnabled},
+	} {
+		t.Run(tc.fixture, func(t *testing.T) {
+			f, err := fixtures.Load(tc.fixture)
+			assert.NoError(t, err)
+			defer devices.UseFixture(f)()
+
+			dev := devices.Startup(config.GPU)
+			if !assert.NotNil(t, dev) {
+				return
+			}
+			defer dev.Shutdown()
+			infos, err := dev.GetAllGPUInfo()
+			assert.NoError(t, err)
+			assert.Len(t, infos, 8)
+			for i, info := range infos {
+				assert.Equal(t, tc.backend, info.Backend)
+				assert.Equal(t, tc.arch, info.Arch)
+				assert.Equal(t, tc.warpSize, info.WarpSize)
+				assert.Equal(t, tc.ptx, info.PTXVersion)
+				assert.Equal(t, tc.memoryMB, info.MemoryTotalMB)
+				assert.Equal(t, tc.ecc, info.ECC)
+				assert.Equal(t, i, info.ID)
+			}
+			summaries, err := dev.GetAllSummaries()
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, summaries) {
+				assert.Contains(t, summaries[0].ProductName, tc.product)
+				assert.Equal(t, tc.driver, summaries[0].DriverVersion)
+			}
+		})
+	}
+}
+
+func TestRecordFixture(t *testing.T) {
+	// Recording while replaying must give back the fixture replayed.
+	for _, name := range fixtures.Names() {
+		f, err := fixtures.Load(name)
+		assert.NoError(t, err)
+		restore := devices.UseFixture(f)
+		recorded := devices.RecordFixture(context.Background())
+		restore()
+
+		assert.Equal(t, f.NVML, recorded.NVML, name)
+		assert.Equal(t, f.ProductNames, recorded.ProductNames, name)
+		for cmdline, out := range f.Tools {
+			assert.Equal(t, out, recorded.Tools[cmdline], name)
+		}
+
+		path := filepath.Join(t.TempDir(), name+".json")
+		assert.NoError(t, devices.WriteFixture(path, recorded))
+		loaded, err := devices.LoadFixture(path)
+		assert.NoError(t, err)
+		assert.Equal(t, recorded, loaded, name)
+	}
+}
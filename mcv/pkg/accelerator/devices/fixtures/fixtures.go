@@ -0,0 +1,42 @@
+// Package fixtures holds device fixtures of GPU hosts common in
+// production, A100 and H100 servers with NVML and MI250X and MI300X
+// servers with rocm-smi and amd-smi, to test GPU detection and the
+// compatibility checks on them without the GPUs.
+package fixtures
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+)
+
+//go:embed *.json
+var files embed.FS
+
+// Names returns the names of the fixtures, e.g. "h100-sxm5-80gb".
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the named fixture.
+func Load(name string) (*devices.Fixture, error) {
+	data, err := files.ReadFile(name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no device fixture %s", name)
+	}
+	var f devices.Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse device fixture %s: %w", name, err)
+	}
+	return &f, nil
+}
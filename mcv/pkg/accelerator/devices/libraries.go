@@ -0,0 +1,11 @@
+//go:build !mcv_edge
+
+package devices
+
+// libraryBackends are the GPU backends found through vendor libraries and
+// tools, in the order they are tried.
+var libraryBackends = []libraryBackend{
+	{dtype: NVML, check: nvmlCheck, record: recordNVML, replay: replayNVML},
+	{dtype: AMD, check: amdCheck, record: recordAMD},
+	{dtype: ROCM, check: rocmCheck, record: recordROCm},
+}
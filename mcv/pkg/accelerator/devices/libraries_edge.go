@@ -0,0 +1,7 @@
+//go:build mcv_edge
+
+package devices
+
+// libraryBackends is empty in edge builds: GPUs are found through sysfs
+// alone, without NVML, which needs cgo, or the amd-smi and rocm-smi tools.
+var libraryBackends []libraryBackend
@@ -0,0 +1,58 @@
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrGPUNotFound is returned when no GPU matches a UUID or PCI address.
+var ErrGPUNotFound = errors.New("GPU not found")
+
+// GetGPUInfoByUUID returns the info of the GPU of dev with the given UUID,
+// as reported by NVML (with or without its GPU- prefix) or ROCm.
+func GetGPUInfoByUUID(dev Device, uuid string) (TritonGPUInfo, error) {
+	want := normalizeUUID(uuid)
+	return findGPUInfo(dev, "UUID "+uuid, func(info TritonGPUInfo) bool {
+		return info.UUID != "" && normalizeUUID(info.UUID) == want
+	})
+}
+
+// GetGPUInfoByPCIAddress returns the info of the GPU of dev at the given
+// PCI address, such as 0000:3b:00.0, 3b:00.0 or, as nvidia-smi prints it,
+// 00000000:3B:00.0.
+func GetGPUInfoByPCIAddress(dev Device, bdf string) (TritonGPUInfo, error) {
+	want := pciAddress(bdf)
+	return findGPUInfo(dev, "PCI address "+bdf, func(info TritonGPUInfo) bool {
+		return info.PCIBusID != "" && pciAddress(info.PCIBusID) == want
+	})
+}
+
+func findGPUInfo(dev Device, what string, match func(TritonGPUInfo) bool) (TritonGPUInfo, error) {
+	infos, err := dev.GetAllGPUInfo()
+	if err != nil {
+		return TritonGPUInfo{}, err
+	}
+	for _, info := range infos {
+		if match(info) {
+			return info, nil
+		}
+	}
+	return TritonGPUInfo{}, fmt.Errorf("%w with %s", ErrGPUNotFound, what)
+}
+
+// pciAddress normalizes a PCI address to its sysfs form, shortening the
+// 32-bit domains NVML reports.
+func pciAddress(bdf string) string {
+	bdf = normalizeBDF(bdf)
+	if domain, rest, ok := strings.Cut(bdf, ":"); ok && len(domain) == 8 && strings.HasPrefix(domain, "0000") {
+		bdf = domain[4:] + ":" + rest
+	}
+	return bdf
+}
+
+// normalizeUUID makes UUIDs comparable however they were written.
+func normalizeUUID(uuid string) string {
+	uuid = strings.ToLower(strings.TrimSpace(uuid))
+	return strings.TrimPrefix(uuid, "gpu-")
+}
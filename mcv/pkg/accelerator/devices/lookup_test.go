@@ -0,0 +1,37 @@
+package devices
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDevice reports a fixed set of GPUs.
+type stubDevice struct {
+	MockDevice
+	infos []TritonGPUInfo
+}
+
+func (d *stubDevice) GetAllGPUInfo() ([]TritonGPUInfo, error) {
+	return d.infos, nil
+}
+
+func TestGetGPUInfoByUUIDAndPCIAddress(t *testing.T) {
+	dev := &stubDevice{infos: []TritonGPUInfo{
+		{ID: 0, UUID: "GPU-8f6b2c1e-0d1a-4b5c-9e7f-000000000000", PCIBusID: "00000000:3B:00.0"},
+		{ID: 1, UUID: "GPU-1c2d3e4f-5a6b-7c8d-9e0f-111111111111", PCIBusID: "0000:af:00.0"},
+	}}
+
+	info, err := GetGPUInfoByUUID(dev, "1C2D3E4F-5A6B-7C8D-9E0F-111111111111")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, info.ID)
+
+	info, err = GetGPUInfoByPCIAddress(dev, "af:00.0")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, info.ID)
+
+	_, err = GetGPUInfoByUUID(dev, "GPU-deadbeef")
+	assert.ErrorIs(t, err, ErrGPUNotFound)
+	_, err = GetGPUInfoByPCIAddress(dev, "0000:01:00.0")
+	assert.ErrorIs(t, err, ErrGPUNotFound)
+}
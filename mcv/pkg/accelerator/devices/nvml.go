@@ -1,3 +1,5 @@
+//go:build !mcv_edge
+
 /*
 Copyright 2021-2025
 
@@ -13,6 +15,7 @@ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 See the License for the specific language governing permissions and
 limitations under the License.
 */
+
 package devices
 
 import (
@@ -26,23 +29,54 @@ import (
 	"github.com/redhat-et/MCU/mcv/pkg/config"
 )
 
-const (
-	nvmlHwType   = config.GPU
-	NVMLWarpSize = 32
-)
+const nvmlHwType = config.GPU
 
 var (
 	nvmlAccImpl = gpuNvml{}
 	nvmlType    DeviceType
 )
 
+// nvmlLibrary is the part of NVML the NVML backend uses, so that it can
+// replay a fixture instead.
+type nvmlLibrary interface {
+	Init() nvml.Return
+	Shutdown() nvml.Return
+	DeviceGetCount() (int, nvml.Return)
+	DeviceGetHandleByIndex(index int) (nvmlDevice, nvml.Return)
+	SystemGetDriverVersion() (string, nvml.Return)
+}
+
+// nvmlDevice is the part of nvml.Device the NVML backend uses.
+type nvmlDevice interface {
+	GetName() (string, nvml.Return)
+	GetUUID() (string, nvml.Return)
+	GetCudaComputeCapability() (int, int, nvml.Return)
+	GetMemoryInfo() (nvml.Memory, nvml.Return)
+	GetVirtualizationMode() (nvml.GpuVirtualizationMode, nvml.Return)
+	GetPciInfo() (nvml.PciInfo, nvml.Return)
+	GetEccMode() (current, pending nvml.EnableState, ret nvml.Return)
+}
+
+// hostNVML calls the host's NVML library.
+type hostNVML struct{}
+
+func (hostNVML) Init() nvml.Return                             { return nvml.Init() }
+func (hostNVML) Shutdown() nvml.Return                         { return nvml.Shutdown() }
+func (hostNVML) DeviceGetCount() (int, nvml.Return)            { return nvml.DeviceGetCount() }
+func (hostNVML) SystemGetDriverVersion() (string, nvml.Return) { return nvml.SystemGetDriverVersion() }
+func (hostNVML) DeviceGetHandleByIndex(index int) (nvmlDevice, nvml.Return) {
+	return nvml.DeviceGetHandleByIndex(index)
+}
+
+var nvmlLib nvmlLibrary = hostNVML{}
+
 type gpuNvml struct {
 	libInited bool
 	devices   map[int]GPUDevice // List of GPU identifiers for the device
 }
 
 func nvmlCheck(r *Registry) {
-	if err := nvml.Init(); err != nvml.SUCCESS {
+	if err := nvmlLib.Init(); err != nvml.SUCCESS {
 		logging.Debugf("Error initializing nvml: %v", nvmlErrorString(err))
 		return
 	}
@@ -91,7 +125,7 @@ func (n *gpuNvml) InitLib() (err error) {
 			err = fmt.Errorf("could not init nvml: %v", r)
 		}
 	}()
-	if ret := nvml.Init(); ret != nvml.SUCCESS {
+	if ret := nvmlLib.Init(); ret != nvml.SUCCESS {
 		err = fmt.Errorf("failed to init nvml. %s", nvmlErrorString(ret))
 		return err
 	}
@@ -106,11 +140,11 @@ func (n *gpuNvml) Init() (err error) {
 		}
 	}
 
-	count, ret := nvml.DeviceGetCount()
+	count, ret := nvmlLib.DeviceGetCount()
 	if ret != nvml.SUCCESS {
 		var errs []string
 		errs = append(errs, fmt.Sprintf("failed to get nvml device count: %v", nvml.ErrorString(ret)))
-		if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
+		if ret := nvmlLib.Shutdown(); ret != nvml.SUCCESS {
 			errs = append(errs, fmt.Sprintf("failed to shutdown nvml device: %v", nvml.ErrorString(ret)))
 		}
 
@@ -123,11 +157,11 @@ func (n *gpuNvml) Init() (err error) {
 
 	n.devices = make(map[int]GPUDevice, count)
 	for gpuID := 0; gpuID < count; gpuID++ {
-		device, ret := nvml.DeviceGetHandleByIndex(gpuID)
+		device, ret := nvmlLib.DeviceGetHandleByIndex(gpuID)
 		if ret != nvml.SUCCESS {
 			var errs []string
 			errs = append(errs, fmt.Sprintf("failed to get NVML device %d: %v", gpuID, nvml.ErrorString(ret)))
-			if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
+			if ret := nvmlLib.Shutdown(); ret != nvml.SUCCESS {
 				errs = append(errs, fmt.Sprintf("failed to shutdown nvml device: %v", nvml.ErrorString(ret)))
 			}
 
@@ -141,19 +175,22 @@ func (n *gpuNvml) Init() (err error) {
 		if err != nil {
 			return err
 		}
-		prodName, _ := GetProductName(gpuID)              // TODO error checking in the future
-		driverVersion, _ := nvml.SystemGetDriverVersion() // TODO error checking in the future
+		prodName, _ := productName(gpuID)                    // TODO error checking in the future
+		driverVersion, _ := nvmlLib.SystemGetDriverVersion() // TODO error checking in the future
 		dev := GPUDevice{
 			ID:         gpuID,
 			TritonInfo: tritonInfo,
 			Summary: DeviceSummary{ID: strconv.Itoa(gpuID),
-				ProductName:   prodName,
-				DriverVersion: driverVersion},
+				ProductName:    prodName,
+				DriverVersion:  driverVersion,
+				Virtualization: tritonInfo.Virtualization,
+				Profile:        tritonInfo.Profile},
 		}
 
 		n.devices[gpuID] = dev
 		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
 	}
+	assignCanonicalIDs(n.devices)
 
 	// Removed the line n.collectionSupported = true
 
@@ -163,10 +200,10 @@ func (n *gpuNvml) Init() (err error) {
 // Shutdown stops the GPU metric collector
 func (n *gpuNvml) Shutdown() bool {
 	n.libInited = false
-	return nvml.Shutdown() == nvml.SUCCESS
+	return nvmlLib.Shutdown() == nvml.SUCCESS
 }
 
-func getNVMLTritonGPUInfo(device nvml.Device) (TritonGPUInfo, error) {
+func getNVMLTritonGPUInfo(device nvmlDevice) (TritonGPUInfo, error) {
 	name, _ := device.GetName()
 	uuid, _ := device.GetUUID()
 
@@ -175,7 +212,7 @@ func getNVMLTritonGPUInfo(device nvml.Device) (TritonGPUInfo, error) {
 
 	mem, _ := device.GetMemoryInfo()
 	warpSize := NVMLWarpSize
-	driverVersion, _ := nvml.SystemGetDriverVersion()
+	driverVersion, _ := nvmlLib.SystemGetDriverVersion()
 	// Split the version string to extract the major version
 	versionParts := strings.Split(driverVersion, ".")
 	if len(versionParts) < 1 {
@@ -188,6 +225,13 @@ func getNVMLTritonGPUInfo(device nvml.Device) (TritonGPUInfo, error) {
 		return TritonGPUInfo{}, fmt.Errorf("failed to parse PTX version: %v", err)
 	}
 
+	virt, profile := nvmlVirtualization(device, name)
+
+	var busID string
+	if pci, ret := device.GetPciInfo(); ret == nvml.SUCCESS {
+		busID = fmt.Sprintf("%04x:%02x:%02x.0", pci.Domain, pci.Bus, pci.Device)
+	}
+
 	return TritonGPUInfo{
 		Name:              name,
 		UUID:              uuid,
@@ -197,9 +241,27 @@ func getNVMLTritonGPUInfo(device nvml.Device) (TritonGPUInfo, error) {
 		MemoryTotalMB:     mem.Total / (1024 * 1024),
 		PTXVersion:        ptxVersion,
 		Backend:           "cuda",
+		Virtualization:    virt,
+		Profile:           profile,
+		PCIBusID:          busID,
+		ECC:               nvmlECC(device),
 	}, nil
 }
 
+// nvmlECC returns the current ECC mode of a device, empty when the device
+// has no ECC memory.
+func nvmlECC(device nvmlDevice) string {
+	current, _, ret := device.GetEccMode()
+	switch {
+	case ret != nvml.SUCCESS:
+		return ""
+	case current == nvml.FEATURE_ENABLED:
+		return ECCEnabled
+	default:
+		return ECCDisabled
+	}
+}
+
 // GetGPUInfo retrieves the stored GPU info for a specific device ID.
 // It returns the GPU info or an error if the device is not found.
 func (n *gpuNvml) GetGPUInfo(gpuID int) (TritonGPUInfo, error) {
@@ -215,10 +277,9 @@ func (n *gpuNvml) GetGPUInfo(gpuID int) (TritonGPUInfo, error) {
 func (n *gpuNvml) GetAllGPUInfo() ([]TritonGPUInfo, error) {
 	var allTritonInfo []TritonGPUInfo
 
-	for gpuID := range n.devices {
-		dev := n.devices[gpuID]
+	for _, dev := range canonicalOrder(n.devices) {
 		allTritonInfo = append(allTritonInfo, dev.TritonInfo)
-		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
+		logging.Debugf("GPU %d: %+v", dev.ID, dev.TritonInfo)
 	}
 
 	return allTritonInfo, nil
@@ -246,15 +307,28 @@ func (n *gpuNvml) GetAllSummaries() ([]DeviceSummary, error) {
 
 	// Fallback to default behavior if cache is unavailable
 	var allAccInfo []DeviceSummary
-	for gpuID := range n.devices {
-		dev := n.devices[gpuID]
+	for _, dev := range canonicalOrder(n.devices) {
 		allAccInfo = append(allAccInfo, dev.Summary)
-		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
+		logging.Debugf("GPU %d: %+v", dev.ID, dev.TritonInfo)
 	}
 	return allAccInfo, nil
 }
 
 // GetSummary implements Device.
 func (n *gpuNvml) GetSummary(gpuID int) (DeviceSummary, error) {
-	panic("unimplemented")
+	dev, exists := n.devices[gpuID]
+	if !exists {
+		return DeviceSummary{}, fmt.Errorf("GPU device %d not found", gpuID)
+	}
+	return dev.Summary, nil
+}
+
+// nvmlVirtualization maps the NVML virtualization mode of a device. In a
+// vGPU guest the device name is the vGPU profile (e.g. "GRID A100-4C").
+func nvmlVirtualization(device nvmlDevice, name string) (virt, profile string) {
+	mode, ret := device.GetVirtualizationMode()
+	if ret != nvml.SUCCESS || mode != nvml.GPU_VIRTUALIZATION_MODE_VGPU {
+		return VirtualizationNone, ""
+	}
+	return VirtualizationVGPU, name
 }
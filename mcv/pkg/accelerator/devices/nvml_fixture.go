@@ -0,0 +1,133 @@
+//go:build !mcv_edge
+
+package devices
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// fixtureNVML replays the NVML queries recorded in a fixture.
+type fixtureNVML struct {
+	f *NVMLFixture
+}
+
+func (l fixtureNVML) Init() nvml.Return {
+	if l.f == nil {
+		return nvml.ERROR_LIBRARY_NOT_FOUND
+	}
+	return nvml.SUCCESS
+}
+
+func (l fixtureNVML) Shutdown() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (l fixtureNVML) DeviceGetCount() (int, nvml.Return) {
+	return len(l.f.Devices), nvml.SUCCESS
+}
+
+func (l fixtureNVML) DeviceGetHandleByIndex(index int) (nvmlDevice, nvml.Return) {
+	if index < 0 || index >= len(l.f.Devices) {
+		return nil, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return fixtureNVMLDevice(l.f.Devices[index]), nvml.SUCCESS
+}
+
+func (l fixtureNVML) SystemGetDriverVersion() (string, nvml.Return) {
+	return l.f.DriverVersion, nvml.SUCCESS
+}
+
+type fixtureNVMLDevice NVMLDeviceFixture
+
+func (d fixtureNVMLDevice) GetName() (string, nvml.Return) {
+	return d.Name, nvml.SUCCESS
+}
+
+func (d fixtureNVMLDevice) GetUUID() (string, nvml.Return) {
+	return d.UUID, nvml.SUCCESS
+}
+
+func (d fixtureNVMLDevice) GetCudaComputeCapability() (int, int, nvml.Return) {
+	return d.CudaComputeCapability[0], d.CudaComputeCapability[1], nvml.SUCCESS
+}
+
+func (d fixtureNVMLDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	return nvml.Memory{Total: d.MemoryTotal}, nvml.SUCCESS
+}
+
+func (d fixtureNVMLDevice) GetVirtualizationMode() (nvml.GpuVirtualizationMode, nvml.Return) {
+	if d.VGPU {
+		return nvml.GPU_VIRTUALIZATION_MODE_VGPU, nvml.SUCCESS
+	}
+	return nvml.GPU_VIRTUALIZATION_MODE_NONE, nvml.SUCCESS
+}
+
+func (d fixtureNVMLDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	var pci nvml.PciInfo
+	var function uint32
+	if _, err := fmt.Sscanf(d.PCIBusID, "%x:%x:%x.%x", &pci.Domain, &pci.Bus, &pci.Device, &function); err != nil {
+		return pci, nvml.ERROR_NOT_SUPPORTED
+	}
+	return pci, nvml.SUCCESS
+}
+
+func (d fixtureNVMLDevice) GetEccMode() (nvml.EnableState, nvml.EnableState, nvml.Return) {
+	switch d.ECC {
+	case ECCEnabled:
+		return nvml.FEATURE_ENABLED, nvml.FEATURE_ENABLED, nvml.SUCCESS
+	case ECCDisabled:
+		return nvml.FEATURE_DISABLED, nvml.FEATURE_DISABLED, nvml.SUCCESS
+	}
+	return 0, 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+func replayNVML(f *Fixture) func() {
+	saved := nvmlLib
+	nvmlLib = fixtureNVML{f: f.NVML}
+	return func() { nvmlLib = saved }
+}
+
+// recordNVML records the NVML queries of the NVML backend, if NVML works
+// on this host.
+func recordNVML(_ context.Context, f *Fixture) {
+	if ret := nvmlLib.Init(); ret != nvml.SUCCESS {
+		logging.Debugf("Not recording NVML: %s", nvmlErrorString(ret))
+		return
+	}
+	defer nvmlLib.Shutdown()
+
+	rec := &NVMLFixture{}
+	rec.DriverVersion, _ = nvmlLib.SystemGetDriverVersion()
+	count, ret := nvmlLib.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		diag.Warnf(diag.DeviceRecording, "Not recording NVML devices: %s", nvmlErrorString(ret))
+	}
+	for i := 0; i < count; i++ {
+		device, ret := nvmlLib.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			diag.Warnf(diag.DeviceRecording, "Not recording NVML device %d: %s", i, nvmlErrorString(ret))
+			continue
+		}
+		var d NVMLDeviceFixture
+		d.Name, _ = device.GetName()
+		d.UUID, _ = device.GetUUID()
+		d.CudaComputeCapability[0], d.CudaComputeCapability[1], _ = device.GetCudaComputeCapability()
+		if mem, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+			d.MemoryTotal = mem.Total
+		}
+		if mode, ret := device.GetVirtualizationMode(); ret == nvml.SUCCESS {
+			d.VGPU = mode == nvml.GPU_VIRTUALIZATION_MODE_VGPU
+		}
+		if pci, ret := device.GetPciInfo(); ret == nvml.SUCCESS {
+			d.PCIBusID = fmt.Sprintf("%04x:%02x:%02x.0", pci.Domain, pci.Bus, pci.Device)
+		}
+		d.ECC = nvmlECC(device)
+		rec.Devices = append(rec.Devices, d)
+	}
+	f.NVML = rec
+}
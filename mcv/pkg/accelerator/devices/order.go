@@ -0,0 +1,45 @@
+package devices
+
+import "sort"
+
+// assignCanonicalIDs numbers gpus in order of PCI address. Unlike their
+// IDs, the order the driver enumerated them in, which can change across
+// reboots, the numbering only changes when GPUs are added or removed. GPUs
+// without a PCI address come last, in ID order.
+func assignCanonicalIDs(gpus map[int]GPUDevice) {
+	for i, g := range sortByPCIAddress(gpus) {
+		g.TritonInfo.CanonicalID = i
+		g.Summary.CanonicalID = i
+		gpus[g.ID] = g
+	}
+}
+
+func sortByPCIAddress(gpus map[int]GPUDevice) []GPUDevice {
+	sorted := make([]GPUDevice, 0, len(gpus))
+	for _, g := range gpus {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].TritonInfo.PCIBusID, sorted[j].TritonInfo.PCIBusID
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		if a != b {
+			return pciAddress(a) < pciAddress(b)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// canonicalOrder returns gpus ordered by canonical ID.
+func canonicalOrder(gpus map[int]GPUDevice) []GPUDevice {
+	sorted := make([]GPUDevice, 0, len(gpus))
+	for _, g := range gpus {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].TritonInfo.CanonicalID < sorted[j].TritonInfo.CanonicalID
+	})
+	return sorted
+}
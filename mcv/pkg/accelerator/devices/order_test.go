@@ -0,0 +1,31 @@
+package devices
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignCanonicalIDs(t *testing.T) {
+	gpu := func(id int, bdf string) GPUDevice {
+		return GPUDevice{ID: id, TritonInfo: TritonGPUInfo{ID: id, PCIBusID: bdf}}
+	}
+	gpus := map[int]GPUDevice{
+		0: gpu(0, "0000:af:00.0"),
+		1: gpu(1, ""),
+		2: gpu(2, "00000000:3B:00.0"),
+		3: gpu(3, "0000:1b:00.0"),
+	}
+	assignCanonicalIDs(gpus)
+
+	assert.Equal(t, 2, gpus[0].TritonInfo.CanonicalID)
+	assert.Equal(t, 3, gpus[1].TritonInfo.CanonicalID)
+	assert.Equal(t, 1, gpus[2].TritonInfo.CanonicalID)
+	assert.Equal(t, 0, gpus[3].Summary.CanonicalID)
+
+	var ids []int
+	for _, g := range canonicalOrder(gpus) {
+		ids = append(ids, g.ID)
+	}
+	assert.Equal(t, []int{3, 2, 0, 1}, ids)
+}
@@ -5,12 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"time"
 
 	"github.com/redhat-et/MCU/mcv/pkg/config"
-	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -48,6 +46,7 @@ type ROCMCardInfo struct {
 	NodeID             string `json:"Node ID"`
 	GUID               string `json:"GUID"`
 	GFXVersion         string `json:"GFX Version"`
+	PCIBus             string `json:"PCI Bus"`
 }
 
 type ROCMSystemInfo struct {
@@ -56,6 +55,16 @@ type ROCMSystemInfo struct {
 	} `json:"system"`
 }
 
+// The rocm-smi command lines the ROCm backend runs.
+var (
+	rocmSMIGPUs   = []string{"rocm-smi", "--json", "--showproductname", "--showuniqueid", "--showserial", "--showmeminfo", "all", "--showbus"}
+	rocmSMIDriver = []string{"rocm-smi", "--json", "--showdriverversion"}
+)
+
+func recordROCm(ctx context.Context, f *Fixture) {
+	recordTools(ctx, f, rocmSMIGPUs, rocmSMIDriver)
+}
+
 func rocmCheck(r *Registry) {
 	if err := initROCmLib(); err != nil {
 		logging.Debugf("Error initializing ROCm: %v", err)
@@ -85,7 +94,7 @@ func rocmDeviceStartup() Device {
 }
 
 func initROCmLib() error {
-	if utils.HasApp("rocm-smi") {
+	if hasTool("rocm-smi") {
 		return nil
 	}
 	return errors.New("couldn't find rocm-smi")
@@ -121,7 +130,8 @@ func (r *gpuROCm) Init() error {
 	for gpuID, info := range gpuInfoList.GPUInfo {
 		memTotal, _ := strconv.ParseUint(info.VRAMTotalMemory, 10, 64)
 		name := "card" + strconv.Itoa(gpuID)
-		prodName, _ := GetProductName(gpuID) // TODO error checking in the future
+		prodName, _ := productName(gpuID) // TODO error checking in the future
+		virt, profile := sriovVirtualization(info.PCIBus)
 		r.devices[gpuID] = GPUDevice{
 			ID: gpuID,
 			TritonInfo: TritonGPUInfo{
@@ -132,15 +142,21 @@ func (r *gpuROCm) Init() error {
 				WarpSize:          64,
 				MemoryTotalMB:     memTotal / (1024 * 1024),
 				Backend:           "hip",
+				Virtualization:    virt,
+				Profile:           profile,
+				PCIBusID:          info.PCIBus,
 				ID:                gpuID,
 			},
 			Summary: DeviceSummary{
-				ID:            strconv.Itoa(gpuID),
-				ProductName:   prodName,
-				DriverVersion: gpuInfoList.DrvInfo.System.DriverVersion,
+				ID:             strconv.Itoa(gpuID),
+				ProductName:    prodName,
+				DriverVersion:  gpuInfoList.DrvInfo.System.DriverVersion,
+				Virtualization: virt,
+				Profile:        profile,
 			},
 		}
 	}
+	assignCanonicalIDs(r.devices)
 
 	return nil
 }
@@ -168,8 +184,7 @@ func getAllROCmGPUInfo(ctx context.Context) (*ROCMGPUInfo, error) {
 
 // Fetches all GPUs' info in **one single rocm-smi call**
 func getROCmGPUInfo(ctx context.Context) (map[int]*ROCMCardInfo, error) {
-	cmd := exec.CommandContext(ctx, "rocm-smi", "--json", "--showproductname", "--showuniqueid", "--showserial", "--showmeminfo", "all")
-	output, err := cmd.Output()
+	output, err := runTool(ctx, rocmSMIGPUs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute rocm-smi: %v", err)
 	}
@@ -201,8 +216,7 @@ func getROCmGPUInfo(ctx context.Context) (map[int]*ROCMCardInfo, error) {
 
 // Fetches all GPUs' info in **one single rocm-smi call**
 func getROCmSystemInfo(ctx context.Context) (*ROCMSystemInfo, error) {
-	cmd := exec.CommandContext(ctx, "rocm-smi", "--json", "--showdriverversion")
-	output, err := cmd.Output()
+	output, err := runTool(ctx, rocmSMIDriver)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute rocm-smi: %v", err)
 	}
@@ -225,10 +239,9 @@ func getROCmSystemInfo(ctx context.Context) (*ROCMSystemInfo, error) {
 // GetAllGPUInfo returns a list of GPU info for all devices
 func (r *gpuROCm) GetAllGPUInfo() ([]TritonGPUInfo, error) {
 	var allTritonInfo []TritonGPUInfo
-	for gpuID := range r.devices {
-		dev := r.devices[gpuID]
+	for _, dev := range canonicalOrder(r.devices) {
 		allTritonInfo = append(allTritonInfo, dev.TritonInfo)
-		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
+		logging.Debugf("GPU %d: %+v", dev.ID, dev.TritonInfo)
 	}
 	return allTritonInfo, nil
 }
@@ -253,10 +266,9 @@ func (r *gpuROCm) GetAllSummaries() ([]DeviceSummary, error) {
 
 	// Fallback to default behavior if cache is unavailable
 	var allAccInfo []DeviceSummary
-	for gpuID := range r.devices {
-		dev := r.devices[gpuID]
+	for _, dev := range canonicalOrder(r.devices) {
 		allAccInfo = append(allAccInfo, dev.Summary)
-		logging.Debugf("GPU %d: %+v", gpuID, dev.TritonInfo)
+		logging.Debugf("GPU %d: %+v", dev.ID, dev.TritonInfo)
 	}
 	return allAccInfo, nil
 }
@@ -0,0 +1,347 @@
+package devices
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaypipes/pcidb"
+	logging "github.com/sirupsen/logrus"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+const sysfsHwType = config.GPU
+
+// PCI vendor IDs of the GPUs the sysfs backend reports.
+const (
+	pciVendorNVIDIA = "10de"
+	pciVendorAMD    = "1002"
+)
+
+// Paths read by the sysfs backend, under hostfs.Root(); overridden in tests.
+var (
+	sysfsDRM      = "/sys/class/drm"
+	sysfsKFDNodes = "/sys/class/kfd/kfd/topology/nodes"
+	sysfsAMDGPU   = "/sys/module/amdgpu/version"
+	procNVIDIA    = "/proc/driver/nvidia"
+)
+
+var (
+	sysfsAccImpl = gpuSysfs{}
+	sysfsType    DeviceType
+
+	drmCard = regexp.MustCompile(`^card[0-9]+$`)
+)
+
+// gpuSysfs finds GPUs from /sys/class/drm and the PCI IDs database alone,
+// for minimal containers without NVML or ROCm. It cannot read an NVIDIA
+// GPU's compute capability, so those GPUs have no Arch, and it reports no
+// UUIDs.
+type gpuSysfs struct {
+	devices map[int]GPUDevice
+}
+
+// sysfsCheck registers the sysfs backend if no vendor library backend was
+// registered and sysfs lists a GPU.
+func sysfsCheck(r *Registry) {
+	if len(r.Registry[config.GPU]) > 0 {
+		return
+	}
+	if len(drmGPUs()) == 0 {
+		logging.Debug("No GPUs found in sysfs")
+		return
+	}
+	sysfsType = SYSFS
+	if err := addDeviceInterface(r, sysfsType, sysfsHwType, sysfsDeviceStartup); err == nil {
+		logging.Debugf("Using %s to obtain GPU info", sysfsAccImpl.Name())
+	} else {
+		logging.Debugf("Error registering sysfs: %v", err)
+	}
+}
+
+func sysfsDeviceStartup() Device {
+	a := sysfsAccImpl
+	if err := a.Init(); err != nil {
+		logging.Errorf("Failed to init device: %v", err)
+		return nil
+	}
+	logging.Debug("No vendor GPU library found, GPU info from sysfs is limited")
+	return &a
+}
+
+// drmGPU is a GPU found in /sys/class/drm.
+type drmGPU struct {
+	dir    string // The card's PCI device directory
+	bdf    string
+	vendor string
+	device string
+}
+
+// drmGPUs returns the NVIDIA and AMD GPUs with a DRM card, in card order.
+func drmGPUs() []drmGPU {
+	entries, err := os.ReadDir(hostfs.Path(sysfsDRM))
+	if err != nil {
+		return nil
+	}
+	var cards []int
+	for _, e := range entries {
+		if drmCard.MatchString(e.Name()) {
+			n, _ := strconv.Atoi(strings.TrimPrefix(e.Name(), "card"))
+			cards = append(cards, n)
+		}
+	}
+	sort.Ints(cards)
+
+	var gpus []drmGPU
+	seen := map[string]bool{}
+	for _, n := range cards {
+		dir, err := filepath.EvalSymlinks(filepath.Join(hostfs.Path(sysfsDRM), "card"+strconv.Itoa(n), "device"))
+		if err != nil {
+			continue
+		}
+		class := readHexAttr(dir, "class")
+		if !strings.HasPrefix(class, "03") { // Display controllers
+			continue
+		}
+		g := drmGPU{dir: dir, bdf: filepath.Base(dir), vendor: readHexAttr(dir, "vendor"), device: readHexAttr(dir, "device")}
+		if seen[g.bdf] || (g.vendor != pciVendorNVIDIA && g.vendor != pciVendorAMD) {
+			continue
+		}
+		seen[g.bdf] = true
+		gpus = append(gpus, g)
+	}
+	return gpus
+}
+
+// readHexAttr reads a sysfs attribute such as "0x10de" as "10de".
+func readHexAttr(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(string(data))), "0x")
+}
+
+func (s *gpuSysfs) Name() string {
+	return sysfsType.String()
+}
+
+func (s *gpuSysfs) DevType() DeviceType {
+	return sysfsType
+}
+
+func (s *gpuSysfs) HwType() string {
+	return sysfsHwType
+}
+
+func (s *gpuSysfs) InitLib() error {
+	return nil
+}
+
+func (s *gpuSysfs) Init() error {
+	gpus := drmGPUs()
+	if len(gpus) == 0 {
+		return fmt.Errorf("no GPUs found in %s", sysfsDRM)
+	}
+	db, err := pcidb.New()
+	if err != nil {
+		logging.Debugf("PCI IDs database unavailable: %v", err)
+	}
+	nvidiaDriver := nvidiaDriverVersion()
+	amdArchs := kfdArchs()
+
+	s.devices = make(map[int]GPUDevice, len(gpus))
+	for gpuID, g := range gpus {
+		info := TritonGPUInfo{
+			Name:     pciProductName(db, g.vendor, g.device),
+			PCIBusID: g.bdf,
+			ID:       gpuID,
+		}
+		var driver string
+		switch g.vendor {
+		case pciVendorNVIDIA:
+			info.Backend = "cuda"
+			info.WarpSize = NVMLWarpSize
+			if model := nvidiaModel(g.bdf); model != "" {
+				info.Name = model
+			}
+			driver = nvidiaDriver
+			if major, _, ok := strings.Cut(driver, "."); ok {
+				info.PTXVersion, _ = strconv.Atoi(major)
+			}
+		case pciVendorAMD:
+			info.Backend = "hip"
+			info.WarpSize = 64
+			info.Arch = amdArchs[g.bdf]
+			if vram, err := os.ReadFile(filepath.Join(g.dir, "mem_info_vram_total")); err == nil {
+				bytes, _ := strconv.ParseUint(strings.TrimSpace(string(vram)), 10, 64)
+				info.MemoryTotalMB = bytes / (1024 * 1024)
+			}
+			if v, err := os.ReadFile(hostfs.Path(sysfsAMDGPU)); err == nil {
+				driver = strings.TrimSpace(string(v))
+			}
+		}
+		info.Virtualization, info.Profile = sriovVirtualization(g.bdf)
+
+		s.devices[gpuID] = GPUDevice{
+			ID:         gpuID,
+			TritonInfo: info,
+			Summary: DeviceSummary{
+				ID:             strconv.Itoa(gpuID),
+				ProductName:    info.Name,
+				DriverVersion:  driver,
+				Virtualization: info.Virtualization,
+				Profile:        info.Profile,
+			},
+		}
+	}
+	assignCanonicalIDs(s.devices)
+	return nil
+}
+
+// pciProductName returns the model name from the PCI IDs database, or the
+// vendor and device IDs if it is not known.
+func pciProductName(db *pcidb.PCIDB, vendor, device string) string {
+	if db != nil {
+		if p, ok := db.Products[vendor+device]; ok {
+			return p.Name
+		}
+	}
+	return fmt.Sprintf("PCI device %s:%s", vendor, device)
+}
+
+// nvidiaModel returns the model the NVIDIA kernel module reports for the
+// GPU at bdf, if it is loaded.
+func nvidiaModel(bdf string) string {
+	f, err := os.Open(filepath.Join(hostfs.Path(procNVIDIA), "gpus", bdf, "information"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if model, ok := strings.CutPrefix(scanner.Text(), "Model:"); ok {
+			return strings.TrimSpace(model)
+		}
+	}
+	return ""
+}
+
+// nvidiaDriverVersion returns the version of the loaded NVIDIA kernel
+// module, from a line such as
+// "NVRM version: NVIDIA UNIX x86_64 Kernel Module  550.54.15  Tue Mar 5 ..."
+// or, for the open module,
+// "NVRM version: NVIDIA UNIX Open Kernel Module for aarch64  540.3.0  ...".
+func nvidiaDriverVersion() string {
+	data, err := os.ReadFile(filepath.Join(hostfs.Path(procNVIDIA), "version"))
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	_, rest, ok := strings.Cut(line, "Kernel Module")
+	if !ok {
+		return ""
+	}
+	for _, f := range strings.Fields(rest) {
+		if f[0] >= '0' && f[0] <= '9' && strings.Contains(f, ".") {
+			return f
+		}
+	}
+	return ""
+}
+
+// LoadedDriverVersions returns the versions of the loaded NVIDIA and AMD
+// GPU kernel drivers, e.g. "nvidia=550.54.15", and the L4T release of a
+// Jetson. They are read from /proc, /sys and /etc on each call, so they are current even when the GPUs themselves
+// come from the device cache.
+func LoadedDriverVersions() string {
+	var versions []string
+	if v := nvidiaDriverVersion(); v != "" {
+		versions = append(versions, "nvidia="+v)
+	}
+	if v, err := os.ReadFile(hostfs.Path(sysfsAMDGPU)); err == nil {
+		versions = append(versions, "amdgpu="+strings.TrimSpace(string(v)))
+	}
+	if v := l4tVersion(); v != "" {
+		versions = append(versions, "l4t="+v)
+	}
+	return strings.Join(versions, ",")
+}
+
+// kfdArchs maps the PCI addresses of AMD GPUs to their gfx targets, from
+// the amdkfd topology.
+func kfdArchs() map[string]string {
+	archs := map[string]string{}
+	nodes, _ := filepath.Glob(filepath.Join(hostfs.Path(sysfsKFDNodes), "*", "properties"))
+	for _, path := range nodes {
+		props := readProperties(path)
+		version := props["gfx_target_version"]
+		if version == 0 {
+			continue // A CPU node
+		}
+		loc := props["location_id"]
+		bdf := fmt.Sprintf("%04x:%02x:%02x.%x", props["domain"], loc>>8, (loc>>3)&0x1f, loc&0x7)
+		archs[bdf] = fmt.Sprintf("gfx%d%x%x", version/10000, (version/100)%100, version%100)
+	}
+	return archs
+}
+
+// readProperties reads a kfd properties file of "name value" lines.
+func readProperties(path string) map[string]uint64 {
+	props := map[string]uint64{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return props
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, value, ok := strings.Cut(line, " "); ok {
+			if v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64); err == nil {
+				props[name] = v
+			}
+		}
+	}
+	return props
+}
+
+func (s *gpuSysfs) Shutdown() bool {
+	return true
+}
+
+func (s *gpuSysfs) GetGPUInfo(gpuID int) (TritonGPUInfo, error) {
+	dev, exists := s.devices[gpuID]
+	if !exists {
+		return TritonGPUInfo{}, fmt.Errorf("GPU device %d not found", gpuID)
+	}
+	return dev.TritonInfo, nil
+}
+
+func (s *gpuSysfs) GetAllGPUInfo() ([]TritonGPUInfo, error) {
+	var allTritonInfo []TritonGPUInfo
+	for _, dev := range canonicalOrder(s.devices) {
+		allTritonInfo = append(allTritonInfo, dev.TritonInfo)
+	}
+	return allTritonInfo, nil
+}
+
+func (s *gpuSysfs) GetSummary(gpuID int) (DeviceSummary, error) {
+	dev, exists := s.devices[gpuID]
+	if !exists {
+		return DeviceSummary{}, fmt.Errorf("GPU device %d not found", gpuID)
+	}
+	return dev.Summary, nil
+}
+
+func (s *gpuSysfs) GetAllSummaries() ([]DeviceSummary, error) {
+	var allAccInfo []DeviceSummary
+	for _, dev := range canonicalOrder(s.devices) {
+		allAccInfo = append(allAccInfo, dev.Summary)
+	}
+	return allAccInfo, nil
+}
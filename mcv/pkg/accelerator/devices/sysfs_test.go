@@ -0,0 +1,88 @@
+package devices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSysfs lays out a DRM card for each PCI device, given as its
+// attributes, and returns the root.
+func fakeSysfs(t *testing.T, devs map[string]map[string]string) string {
+	root := t.TempDir()
+	card := 0
+	for _, bdf := range []string{"0000:3b:00.0", "0000:1b:00.0", "0000:00:02.0"} {
+		attrs, ok := devs[bdf]
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(root, "devices", bdf)
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+		for name, value := range attrs {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644))
+		}
+		cardDir := filepath.Join(root, "drm", "card"+string(rune('0'+card)))
+		assert.NoError(t, os.MkdirAll(cardDir, 0755))
+		assert.NoError(t, os.Symlink(dir, filepath.Join(cardDir, "device")))
+		assert.NoError(t, os.MkdirAll(cardDir+"-DP-1", 0755))
+		card++
+	}
+	return root
+}
+
+func TestSysfsBackend(t *testing.T) {
+	root := fakeSysfs(t, map[string]map[string]string{
+		"0000:3b:00.0": {"class": "0x030200", "vendor": "0x10de", "device": "0x2330"},
+		"0000:1b:00.0": {"class": "0x038000", "vendor": "0x1002", "device": "0x74a1", "mem_info_vram_total": "206141652992"},
+		"0000:00:02.0": {"class": "0x030000", "vendor": "0x8086", "device": "0x4680"},
+	})
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "kfd", "0"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "kfd", "1"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "kfd", "0", "properties"), []byte("cpu_cores_count 64\ngfx_target_version 0\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "kfd", "1", "properties"), []byte("gfx_target_version 90402\nlocation_id 6912\ndomain 0\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "nvidia", "gpus", "0000:3b:00.0"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "nvidia", "version"), []byte("NVRM version: NVIDIA UNIX x86_64 Kernel Module  550.54.15  Tue Mar  5 22:23:56 UTC 2024\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "nvidia", "gpus", "0000:3b:00.0", "information"), []byte("Model: \t\t NVIDIA H100 80GB HBM3\nIRQ:   \t\t 42\n"), 0644))
+
+	defer func(drm, kfd, amd, nv, pci string) {
+		sysfsDRM, sysfsKFDNodes, sysfsAMDGPU, procNVIDIA, sysfsPCIDevices = drm, kfd, amd, nv, pci
+	}(sysfsDRM, sysfsKFDNodes, sysfsAMDGPU, procNVIDIA, sysfsPCIDevices)
+	sysfsDRM = filepath.Join(root, "drm")
+	sysfsKFDNodes = filepath.Join(root, "kfd")
+	sysfsAMDGPU = filepath.Join(root, "amdgpu-version")
+	procNVIDIA = filepath.Join(root, "nvidia")
+	sysfsPCIDevices = filepath.Join(root, "devices")
+
+	gpus := drmGPUs()
+	assert.Len(t, gpus, 2)
+
+	dev := &gpuSysfs{}
+	assert.NoError(t, dev.Init())
+	infos, err := dev.GetAllGPUInfo()
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+
+	// Canonical order puts the AMD GPU, at the lower PCI address, first.
+	amd, nvidia := infos[0], infos[1]
+	assert.Equal(t, "0000:1b:00.0", amd.PCIBusID)
+	assert.Equal(t, "hip", amd.Backend)
+	assert.Equal(t, "gfx942", amd.Arch)
+	assert.Equal(t, uint64(196592), amd.MemoryTotalMB)
+	assert.Equal(t, 64, amd.WarpSize)
+
+	assert.Equal(t, "NVIDIA H100 80GB HBM3", nvidia.Name)
+	assert.Equal(t, "cuda", nvidia.Backend)
+	assert.Equal(t, 550, nvidia.PTXVersion)
+	assert.Equal(t, 0, nvidia.ID)
+	assert.Equal(t, 1, nvidia.CanonicalID)
+
+	summary, err := dev.GetSummary(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "550.54.15", summary.DriverVersion)
+	assert.Equal(t, "nvidia=550.54.15", LoadedDriverVersions())
+
+	assert.NoError(t, os.WriteFile(sysfsAMDGPU, []byte("6.7.0\n"), 0644))
+	assert.Equal(t, "nvidia=550.54.15,amdgpu=6.7.0", LoadedDriverVersions())
+}
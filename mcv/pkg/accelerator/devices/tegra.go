@@ -0,0 +1,246 @@
+package devices
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+const tegraHwType = config.GPU
+
+// Paths read by the Tegra backend, under hostfs.Root(); overridden in tests.
+var (
+	tegraRelease    = "/etc/nv_tegra_release"
+	tegraModel      = "/proc/device-tree/model"
+	tegraCompatible = "/proc/device-tree/compatible"
+	sysfsDevfreq    = "/sys/class/devfreq"
+	procMeminfo     = "/proc/meminfo"
+)
+
+var (
+	tegraAccImpl = gpuTegra{}
+	tegraType    DeviceType
+
+	// "# R36 (release), REVISION: 3.0, GCID: 36191598, BOARD: generic, ..."
+	tegraReleaseLine = regexp.MustCompile(`^# R([0-9]+) \(release\), REVISION: ([0-9.]+)`)
+)
+
+// tegraComputeCapabilities maps the integrated GPUs of Tegra SoCs, named
+// as in their devfreq device (e.g. 17000000.ga10b), to their compute
+// capability.
+var tegraComputeCapabilities = map[string]string{
+	"gm20b": "5.3", // Jetson Nano, TX1
+	"gp10b": "6.2", // Jetson TX2
+	"gv11b": "7.2", // Jetson Xavier
+	"ga10b": "8.7", // Jetson Orin
+}
+
+// tegraChips maps the SoCs in the device tree to their GPU, for when the
+// GPU has no devfreq device, as on the Nano, whose is named 57000000.gpu.
+var tegraChips = map[string]string{
+	"nvidia,tegra210": "gm20b",
+	"nvidia,tegra186": "gp10b",
+	"nvidia,tegra194": "gv11b",
+	"nvidia,tegra234": "ga10b",
+}
+
+// gpuTegra reports the integrated GPU of NVIDIA Jetson modules, which have
+// neither NVML nor a PCI GPU. Its compute capability follows from the SoC,
+// it shares the system memory, and its driver is the L4T release in
+// /etc/nv_tegra_release.
+type gpuTegra struct {
+	devices map[int]GPUDevice
+}
+
+// tegraCheck registers the Tegra backend if the host is a Jetson,
+// reporting whether it did.
+func tegraCheck(r *Registry) bool {
+	if _, ok := tegraGPU(); !ok {
+		logging.Debug("Not a Tegra system")
+		return false
+	}
+	tegraType = TEGRA
+	if err := addDeviceInterface(r, tegraType, tegraHwType, tegraDeviceStartup); err != nil {
+		logging.Debugf("Error registering tegra: %v", err)
+		return false
+	}
+	logging.Debugf("Using %s to obtain GPU info", tegraAccImpl.Name())
+	return true
+}
+
+func tegraDeviceStartup() Device {
+	a := tegraAccImpl
+	if err := a.Init(); err != nil {
+		logging.Errorf("Failed to init device: %v", err)
+		return nil
+	}
+	return &a
+}
+
+// tegraGPU returns the host's Tegra GPU, e.g. "ga10b", and whether the
+// host is a Jetson at all; the GPU of SoCs newer than mcv is "".
+func tegraGPU() (string, bool) {
+	if entries, err := os.ReadDir(hostfs.Path(sysfsDevfreq)); err == nil {
+		for _, e := range entries {
+			if _, gpu, ok := strings.Cut(e.Name(), "."); ok && tegraComputeCapabilities[gpu] != "" {
+				return gpu, true
+			}
+		}
+	}
+	tegra := false
+	if data, err := os.ReadFile(hostfs.Path(tegraCompatible)); err == nil {
+		for _, c := range strings.Split(string(data), "\x00") {
+			if gpu, ok := tegraChips[c]; ok {
+				return gpu, true
+			}
+			tegra = tegra || strings.HasPrefix(c, "nvidia,tegra")
+		}
+	}
+	if _, err := os.Stat(hostfs.Path(tegraRelease)); err == nil {
+		tegra = true
+	}
+	return "", tegra
+}
+
+// l4tVersion returns the L4T release of the host, e.g. "36.3.0".
+func l4tVersion() string {
+	f, err := os.Open(hostfs.Path(tegraRelease))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		if m := tegraReleaseLine.FindStringSubmatch(scanner.Text()); m != nil {
+			return m[1] + "." + m[2]
+		}
+	}
+	return ""
+}
+
+// memTotalMB returns the system memory, which Tegra GPUs share.
+func memTotalMB() uint64 {
+	f, err := os.Open(hostfs.Path(procMeminfo))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "MemTotal:"); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				kb, _ := strconv.ParseUint(fields[0], 10, 64)
+				return kb / 1024
+			}
+		}
+	}
+	return 0
+}
+
+func (t *gpuTegra) Name() string {
+	return tegraType.String()
+}
+
+func (t *gpuTegra) DevType() DeviceType {
+	return tegraType
+}
+
+func (t *gpuTegra) HwType() string {
+	return tegraHwType
+}
+
+func (t *gpuTegra) InitLib() error {
+	return nil
+}
+
+func (t *gpuTegra) Init() error {
+	gpu, ok := tegraGPU()
+	if !ok {
+		return fmt.Errorf("no Tegra GPU found")
+	}
+	info := TritonGPUInfo{
+		Name:          "NVIDIA Tegra " + gpu,
+		Backend:       "cuda",
+		WarpSize:      NVMLWarpSize,
+		MemoryTotalMB: memTotalMB(),
+	}
+	if model, err := os.ReadFile(hostfs.Path(tegraModel)); err == nil {
+		info.Name = strings.TrimSpace(strings.TrimRight(string(model), "\x00"))
+	}
+	if cc := tegraComputeCapabilities[gpu]; cc != "" {
+		info.ComputeCapability = cc
+		info.Arch = strings.Replace(cc, ".", "", 1)
+	} else {
+		logging.Debugf("Unknown Tegra SoC, compute capability of %s unknown", info.Name)
+	}
+
+	// JetPack 6 and later load the NVIDIA open kernel module, whose
+	// version Triton records like on other GPUs; earlier releases only
+	// have the L4T release.
+	driver := nvidiaDriverVersion()
+	if major, _, ok := strings.Cut(driver, "."); ok {
+		info.PTXVersion, _ = strconv.Atoi(major)
+	}
+	if driver == "" {
+		if l4t := l4tVersion(); l4t != "" {
+			driver = "L4T " + l4t
+		}
+	}
+
+	t.devices = map[int]GPUDevice{
+		0: {
+			ID:         0,
+			TritonInfo: info,
+			Summary: DeviceSummary{
+				ID:            "0",
+				ProductName:   info.Name,
+				DriverVersion: driver,
+			},
+		},
+	}
+	return nil
+}
+
+func (t *gpuTegra) Shutdown() bool {
+	return true
+}
+
+func (t *gpuTegra) GetGPUInfo(gpuID int) (TritonGPUInfo, error) {
+	dev, exists := t.devices[gpuID]
+	if !exists {
+		return TritonGPUInfo{}, fmt.Errorf("GPU device %d not found", gpuID)
+	}
+	return dev.TritonInfo, nil
+}
+
+func (t *gpuTegra) GetAllGPUInfo() ([]TritonGPUInfo, error) {
+	var allTritonInfo []TritonGPUInfo
+	for _, dev := range canonicalOrder(t.devices) {
+		allTritonInfo = append(allTritonInfo, dev.TritonInfo)
+	}
+	return allTritonInfo, nil
+}
+
+func (t *gpuTegra) GetSummary(gpuID int) (DeviceSummary, error) {
+	dev, exists := t.devices[gpuID]
+	if !exists {
+		return DeviceSummary{}, fmt.Errorf("GPU device %d not found", gpuID)
+	}
+	return dev.Summary, nil
+}
+
+func (t *gpuTegra) GetAllSummaries() ([]DeviceSummary, error) {
+	var allAccInfo []DeviceSummary
+	for _, dev := range canonicalOrder(t.devices) {
+		allAccInfo = append(allAccInfo, dev.Summary)
+	}
+	return allAccInfo, nil
+}
@@ -0,0 +1,73 @@
+package devices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTegraBackend(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, data string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	}
+	defer func(rel, model, compat, devfreq, mem, nv string) {
+		tegraRelease, tegraModel, tegraCompatible, sysfsDevfreq, procMeminfo, procNVIDIA = rel, model, compat, devfreq, mem, nv
+	}(tegraRelease, tegraModel, tegraCompatible, sysfsDevfreq, procMeminfo, procNVIDIA)
+	tegraRelease = filepath.Join(root, "nv_tegra_release")
+	tegraModel = filepath.Join(root, "device-tree", "model")
+	tegraCompatible = filepath.Join(root, "device-tree", "compatible")
+	sysfsDevfreq = filepath.Join(root, "devfreq")
+	procMeminfo = filepath.Join(root, "meminfo")
+	procNVIDIA = filepath.Join(root, "nvidia")
+
+	_, ok := tegraGPU()
+	assert.False(t, ok)
+	assert.False(t, tegraCheck(newRegistry()))
+
+	// A Jetson AGX Orin on JetPack 5, without the NVIDIA kernel module.
+	write(tegraRelease, "# R35 (release), REVISION: 4.1, GCID: 33958178, BOARD: t186ref, EABI: aarch64, DATE: Tue Aug  1 19:57:35 UTC 2023\n")
+	write(tegraModel, "NVIDIA Jetson AGX Orin Developer Kit\x00")
+	write(tegraCompatible, "nvidia,p3737-0000+p3701-0005\x00nvidia,p3701-0005\x00nvidia,tegra234\x00")
+	write(procMeminfo, "MemTotal:       64335836 kB\nMemFree:        60000000 kB\n")
+	assert.NoError(t, os.MkdirAll(filepath.Join(sysfsDevfreq, "17000000.ga10b"), 0755))
+
+	r := newRegistry()
+	assert.True(t, tegraCheck(r))
+	assert.Contains(t, r.Registry["gpu"], TEGRA)
+
+	dev := &gpuTegra{}
+	assert.NoError(t, dev.Init())
+	infos, err := dev.GetAllGPUInfo()
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "NVIDIA Jetson AGX Orin Developer Kit", infos[0].Name)
+		assert.Equal(t, "cuda", infos[0].Backend)
+		assert.Equal(t, "8.7", infos[0].ComputeCapability)
+		assert.Equal(t, "87", infos[0].Arch)
+		assert.Equal(t, 32, infos[0].WarpSize)
+		assert.Equal(t, uint64(62827), infos[0].MemoryTotalMB)
+		assert.Equal(t, 0, infos[0].PTXVersion)
+	}
+	summary, err := dev.GetSummary(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "L4T 35.4.1", summary.DriverVersion)
+	assert.Equal(t, "l4t=35.4.1", LoadedDriverVersions())
+
+	// JetPack 6 loads the NVIDIA open kernel module.
+	write(filepath.Join(procNVIDIA, "version"), "NVRM version: NVIDIA UNIX Open Kernel Module for aarch64  540.3.0  Release Build  (buildbrain@mobile-u64-6336-d8000)  Thu Apr 25 05:11:39 PDT 2024\n")
+	assert.NoError(t, dev.Init())
+	infos, err = dev.GetAllGPUInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, 540, infos[0].PTXVersion)
+
+	// The Nano's GPU is only known from its SoC.
+	assert.NoError(t, os.RemoveAll(sysfsDevfreq))
+	write(tegraCompatible, "nvidia,p3450-0000\x00nvidia,jetson-nano\x00nvidia,tegra210\x00")
+	gpu, ok := tegraGPU()
+	assert.True(t, ok)
+	assert.Equal(t, "gm20b", gpu)
+}
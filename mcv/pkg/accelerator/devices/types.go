@@ -16,6 +16,9 @@ limitations under the License.
 
 package devices
 
+// NVMLWarpSize is the warp size of NVIDIA GPUs.
+const NVMLWarpSize = 32
+
 // TritonGPUInfo holds key GPU fields relevant to Triton cache validation
 // It now supports both NVIDIA (CUDA) and AMD (ROCm) GPUs.
 type TritonGPUInfo struct {
@@ -53,9 +56,35 @@ type TritonGPUInfo struct {
 
 	Backend string `json:"backend"`
 
+	// Virtualization is empty for a full physical GPU, "vgpu" for an NVIDIA
+	// vGPU guest device or "sriov-vf" for an SR-IOV virtual function.
+	Virtualization string `json:"virtualization,omitempty"`
+
+	// Profile is the vGPU profile or SR-IOV slice of a virtual GPU.
+	Profile string `json:"profile,omitempty"`
+
+	// PCIBusID is the PCI address of the GPU, e.g. "0000:3b:00.0".
+	PCIBusID string `json:"pci_bus_id,omitempty"`
+
+	// ECC is ECCEnabled or ECCDisabled for the GPU memory's error
+	// correction, empty when unknown.
+	ECC string `json:"ecc,omitempty"`
+
+	// ID is the index the driver enumerated the GPU at. It can change
+	// across reboots.
 	ID int
+
+	// CanonicalID is the GPU's position when the host's GPUs are ordered by
+	// PCI address, which stays the same across reboots.
+	CanonicalID int `json:"canonical_id"`
 }
 
+// Values for TritonGPUInfo.ECC.
+const (
+	ECCEnabled  = "enabled"
+	ECCDisabled = "disabled"
+)
+
 type GPUDevice struct {
 	ID         int
 	TritonInfo TritonGPUInfo
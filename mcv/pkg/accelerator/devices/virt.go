@@ -0,0 +1,108 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+// Values for TritonGPUInfo.Virtualization.
+const (
+	VirtualizationNone  = ""
+	VirtualizationVGPU  = "vgpu"     // NVIDIA vGPU guest device
+	VirtualizationSRIOV = "sriov-vf" // SR-IOV virtual function
+)
+
+// VirtualGPUSharedMemLimit is the largest per-block shared memory (in bytes)
+// a kernel may use on a partitioned virtual GPU. Kernels above the default
+// 48 KiB opt into full-device shared memory that vGPU profiles and SR-IOV
+// slices do not guarantee.
+const VirtualGPUSharedMemLimit = 48 * 1024
+
+// sysfsPCIDevices is where PCI devices are listed, under hostfs.Root();
+// overridden in tests.
+var sysfsPCIDevices = "/sys/bus/pci/devices"
+
+// IsVirtual reports whether the GPU is a vGPU or an SR-IOV virtual function.
+func (i TritonGPUInfo) IsVirtual() bool {
+	return i.Virtualization != VirtualizationNone
+}
+
+// SupportsSharedMemory reports whether a kernel using shared bytes of shared
+// memory per block can run on the GPU. Partitioned virtual GPUs only
+// guarantee VirtualGPUSharedMemLimit.
+func (i TritonGPUInfo) SupportsSharedMemory(shared int) bool {
+	return !i.IsVirtual() || shared <= VirtualGPUSharedMemLimit
+}
+
+// sriovVirtualization checks whether the PCI device at bdf is an SR-IOV
+// virtual function and, if so, returns its slice of the physical device as
+// "VF <n>/<total>".
+func sriovVirtualization(bdf string) (virt, profile string) {
+	if bdf == "" {
+		return VirtualizationNone, ""
+	}
+	devPath := filepath.Join(hostfs.Path(sysfsPCIDevices), normalizeBDF(bdf))
+	physfn, err := filepath.EvalSymlinks(filepath.Join(devPath, "physfn"))
+	if err != nil {
+		return VirtualizationNone, ""
+	}
+
+	profile = "VF"
+	total, _ := os.ReadFile(filepath.Join(physfn, "sriov_numvfs"))
+	vfs, _ := filepath.Glob(filepath.Join(physfn, "virtfn*"))
+	self, _ := filepath.EvalSymlinks(devPath)
+	for _, vf := range vfs {
+		if target, err := filepath.EvalSymlinks(vf); err == nil && target == self {
+			profile = fmt.Sprintf("VF %s/%s", strings.TrimPrefix(filepath.Base(vf), "virtfn"), strings.TrimSpace(string(total)))
+			break
+		}
+	}
+	return VirtualizationSRIOV, profile
+}
+
+// NUMANode returns the NUMA node the GPU is attached to, or -1 if it is
+// not known: the host has a single node, or the PCI address is unknown.
+func (i TritonGPUInfo) NUMANode() int {
+	if i.PCIBusID == "" {
+		return -1
+	}
+	data, err := os.ReadFile(filepath.Join(hostfs.Path(sysfsPCIDevices), normalizeBDF(i.PCIBusID), "numa_node"))
+	if err != nil {
+		return -1
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1
+	}
+	return node
+}
+
+// normalizeBDF lowercases a PCI address and adds the 0000 domain if missing.
+func normalizeBDF(bdf string) string {
+	bdf = strings.ToLower(strings.TrimSpace(bdf))
+	if strings.Count(bdf, ":") == 1 {
+		bdf = "0000:" + bdf
+	}
+	return bdf
+}
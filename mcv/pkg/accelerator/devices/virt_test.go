@@ -0,0 +1,56 @@
+package devices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRIOVVirtualization(t *testing.T) {
+	root := t.TempDir()
+	pf := filepath.Join(root, "0000:03:00.0")
+	vf := filepath.Join(root, "0000:03:02.1")
+	assert.NoError(t, os.MkdirAll(pf, 0755))
+	assert.NoError(t, os.MkdirAll(vf, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(pf, "sriov_numvfs"), []byte("8\n"), 0644))
+	assert.NoError(t, os.Symlink(vf, filepath.Join(pf, "virtfn3")))
+	assert.NoError(t, os.Symlink(pf, filepath.Join(vf, "physfn")))
+
+	old := sysfsPCIDevices
+	sysfsPCIDevices = root
+	defer func() { sysfsPCIDevices = old }()
+
+	virt, profile := sriovVirtualization("03:02.1")
+	assert.Equal(t, VirtualizationSRIOV, virt)
+	assert.Equal(t, "VF 3/8", profile)
+
+	virt, profile = sriovVirtualization("0000:03:00.0")
+	assert.Equal(t, VirtualizationNone, virt)
+	assert.Empty(t, profile)
+}
+
+func TestSupportsSharedMemory(t *testing.T) {
+	physical := TritonGPUInfo{}
+	virtual := TritonGPUInfo{Virtualization: VirtualizationVGPU, Profile: "GRID A100-4C"}
+
+	assert.True(t, physical.SupportsSharedMemory(96*1024))
+	assert.True(t, virtual.SupportsSharedMemory(VirtualGPUSharedMemLimit))
+	assert.False(t, virtual.SupportsSharedMemory(96*1024))
+}
+
+func TestNUMANode(t *testing.T) {
+	root := t.TempDir()
+	dev := filepath.Join(root, "0000:3b:00.0")
+	assert.NoError(t, os.MkdirAll(dev, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dev, "numa_node"), []byte("1\n"), 0644))
+
+	old := sysfsPCIDevices
+	sysfsPCIDevices = root
+	defer func() { sysfsPCIDevices = old }()
+
+	assert.Equal(t, 1, TritonGPUInfo{PCIBusID: "3B:00.0"}.NUMANode())
+	assert.Equal(t, -1, TritonGPUInfo{PCIBusID: "0000:5e:00.0"}.NUMANode())
+	assert.Equal(t, -1, TritonGPUInfo{}.NUMANode())
+}
@@ -0,0 +1,133 @@
+// Package apply reconciles the kernel caches extracted on a node with a
+// file listing the cache images that should be there: it extracts the
+// missing ones, updates those whose image changed, repairs those whose
+// files changed and removes those no longer listed.
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the node caches file read when none is given.
+const DefaultFile = "node-caches.yaml"
+
+// What to do with a cache whose extracted files changed.
+const (
+	DriftRepair = "repair" // Extract the image again
+	DriftReport = "report" // Leave the files, and fail the apply
+)
+
+// File is a node caches file.
+type File struct {
+	// Root is the directory each cache without a dir is extracted to a
+	// subdirectory of, named after the cache.
+	Root   string  `yaml:"root,omitempty"`
+	Caches []Cache `yaml:"caches"`
+}
+
+// Cache is a cache image that should be extracted on the node.
+type Cache struct {
+	Name  string `yaml:"name"`
+	Image string `yaml:"image"`         // Cache image, by tag or digest
+	Dir   string `yaml:"dir,omitempty"` // Extraction directory, defaults to <root>/<name>
+	// Optional caches that cannot be extracted, e.g. because no GPU of the
+	// node can use them, do not fail the apply.
+	Optional bool   `yaml:"optional,omitempty"`
+	Drift    string `yaml:"drift,omitempty"`   // repair or report, defaults to repair
+	Kernels  string `yaml:"kernels,omitempty"` // Which kernels to extract: all or compatible
+	Expired  string `yaml:"expired,omitempty"` // What to do once the image expired: warn, block or ignore
+}
+
+// Load reads and validates the node caches file at path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node caches file: %w", err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node caches file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse decodes and validates a node caches file, and sets the defaults of
+// its caches. Unknown keys are rejected so typos do not silently drop
+// settings.
+func Parse(data []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Validate checks that every cache is named, once, has an image and a
+// directory of its own, and sets their defaults. An empty list, "caches:
+// []", is valid: applying it removes every cache.
+func (f *File) Validate() error {
+	if f.Root != "" && !filepath.IsAbs(f.Root) {
+		return fmt.Errorf("root %q is not an absolute path", f.Root)
+	}
+	names := map[string]bool{}
+	for i := range f.Caches {
+		c := &f.Caches[i]
+		if c.Name == "" {
+			return fmt.Errorf("cache %d has no name", i+1)
+		}
+		if strings.ContainsAny(c.Name, `/\`) || c.Name == "." || c.Name == ".." {
+			return fmt.Errorf("invalid cache name %q", c.Name)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate cache %q", c.Name)
+		}
+		names[c.Name] = true
+		if c.Image == "" {
+			return fmt.Errorf("cache %q has no image", c.Name)
+		}
+		if c.Dir == "" {
+			if f.Root == "" {
+				return fmt.Errorf("cache %q has no dir, and the file no root", c.Name)
+			}
+			c.Dir = filepath.Join(f.Root, c.Name)
+		}
+		if !filepath.IsAbs(c.Dir) {
+			return fmt.Errorf("cache %q: dir %q is not an absolute path", c.Name, c.Dir)
+		}
+		c.Dir = filepath.Clean(c.Dir)
+		switch c.Drift {
+		case "":
+			c.Drift = DriftRepair
+		case DriftRepair, DriftReport:
+		default:
+			return fmt.Errorf("cache %q: unsupported drift policy %q (supported: %s, %s)", c.Name, c.Drift, DriftRepair, DriftReport)
+		}
+	}
+	// Each cache owns its directory, so that removing one cannot remove
+	// another's files.
+	for i, a := range f.Caches {
+		for _, b := range f.Caches[i+1:] {
+			if within(a.Dir, b.Dir) || within(b.Dir, a.Dir) {
+				return fmt.Errorf("caches %q and %q share directory %s", a.Name, b.Name, b.Dir)
+			}
+		}
+	}
+	return nil
+}
+
+// within reports whether path is dir or under it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,174 @@
+package apply
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(`
+root: /var/lib/caches
+caches:
+  - name: llama
+    image: quay.io/example/llama-cache:v1
+  - name: embedder
+    image: quay.io/example/embedder-cache:v1
+    dir: /srv/embedder/
+    optional: true
+    drift: report
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Cache{
+		{Name: "llama", Image: "quay.io/example/llama-cache:v1", Dir: "/var/lib/caches/llama", Drift: DriftRepair},
+		{Name: "embedder", Image: "quay.io/example/embedder-cache:v1", Dir: "/srv/embedder", Optional: true, Drift: DriftReport},
+	}, f.Caches)
+
+	f, err = Parse([]byte("caches: []"))
+	assert.NoError(t, err)
+	assert.Empty(t, f.Caches)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"empty":         "",
+		"no dir":        "caches: [{name: a, image: x}]",
+		"relative dir":  "caches: [{name: a, image: x, dir: caches/a}]",
+		"relative root": "root: caches\ncaches: [{name: a, image: x}]",
+		"bad name":      "root: /c\ncaches: [{name: ../a, image: x}]",
+		"duplicate":     "root: /c\ncaches: [{name: a, image: x}, {name: a, image: y}]",
+		"missing image": "root: /c\ncaches: [{name: a}]",
+		"shared dir":    "caches: [{name: a, image: x, dir: /c}, {name: b, image: y, dir: /c/b}]",
+		"bad drift":     "root: /c\ncaches: [{name: a, image: x, drift: ignore}]",
+		"unknown field": "root: /c\ncaches: [{name: a, image: x, tag: v1}]",
+	} {
+		_, err := Parse([]byte(doc))
+		assert.Error(t, err, name)
+	}
+}
+
+func actions(steps []Step) map[string]string {
+	out := map[string]string{}
+	for _, s := range steps {
+		out[s.Name] = s.Action
+	}
+	return out
+}
+
+func TestPlanAndApply(t *testing.T) {
+	root := t.TempDir()
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	f := &File{Root: root, Caches: []Cache{
+		{Name: "llama", Image: "quay.io/example/llama-cache:v1"},
+		{Name: "gemma", Image: "quay.io/example/gemma-cache:v1", Optional: true},
+	}}
+	assert.NoError(t, f.Validate())
+
+	digests := map[string]string{"quay.io/example/llama-cache:v1": "sha256:l1", "quay.io/example/gemma-cache:v1": "sha256:g1"}
+	resolve := func(image string) (string, error) { return digests[image], nil }
+	var extracted []string
+	extract := func(c Cache) (string, error) {
+		extracted = append(extracted, c.Name)
+		if c.Name == "gemma" {
+			return "", errors.New("no compatible GPU")
+		}
+		assert.NoError(t, os.MkdirAll(filepath.Join(c.Dir, "abc"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(c.Dir, "abc", "kernel.cubin"), []byte(c.Image), 0644))
+		return digests[c.Image], nil
+	}
+	apply := func(prune bool) ([]Step, error) {
+		s, err := LoadState(statePath)
+		assert.NoError(t, err)
+		steps := Plan(f, s, resolve, prune)
+		return steps, Apply(steps, statePath, extract)
+	}
+
+	// The optional cache fails without failing the apply.
+	steps, err := apply(true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"llama": ActionInstall, "gemma": ActionInstall}, actions(steps))
+	assert.Error(t, steps[1].Err)
+	llama := filepath.Join(root, "llama")
+	assert.FileExists(t, filepath.Join(llama, "abc", "kernel.cubin"))
+
+	// Files the workload adds are left alone; extracted ones are checked.
+	assert.NoError(t, os.WriteFile(filepath.Join(llama, "compiled.json"), []byte("{}"), 0644))
+	extracted = nil
+	steps, err = apply(true)
+	assert.NoError(t, err)
+	assert.Equal(t, ActionNone, actions(steps)["llama"])
+	assert.Equal(t, []string{"gemma"}, extracted)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(llama, "abc", "kernel.cubin"), []byte("tampered"), 0644))
+	steps, err = apply(true)
+	assert.NoError(t, err)
+	assert.Equal(t, ActionRepair, actions(steps)["llama"])
+	assert.Equal(t, "1 file(s) modified", steps[0].Detail)
+	data, _ := os.ReadFile(filepath.Join(llama, "abc", "kernel.cubin"))
+	assert.Equal(t, "quay.io/example/llama-cache:v1", string(data))
+
+	// With drift reported, a missing file fails the apply and stays missing.
+	f.Caches[0].Drift = DriftReport
+	assert.NoError(t, os.Remove(filepath.Join(llama, "abc", "kernel.cubin")))
+	steps, err = apply(true)
+	assert.ErrorContains(t, err, "llama")
+	assert.Equal(t, ActionDrifted, actions(steps)["llama"])
+	assert.NoFileExists(t, filepath.Join(llama, "abc", "kernel.cubin"))
+	f.Caches[0].Drift = DriftRepair
+
+	// A moved tag updates the cache.
+	digests["quay.io/example/llama-cache:v1"] = "sha256:l2"
+	steps, err = apply(true)
+	assert.NoError(t, err)
+	assert.Equal(t, ActionUpdate, actions(steps)["llama"])
+	assert.Equal(t, "now sha256:l2", steps[0].Detail)
+	s, err := LoadState(statePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:l2", s.Caches["llama"].Digest)
+	assert.Equal(t, []string{filepath.Join("abc", "kernel.cubin")}, keys(s.Caches["llama"].Files))
+
+	// Dropped caches are kept without prune, and removed with it, leaving
+	// the workload's files.
+	f.Caches = f.Caches[1:]
+	steps, err = apply(false)
+	assert.NoError(t, err)
+	assert.Equal(t, ActionKeep, actions(steps)["llama"])
+	assert.FileExists(t, filepath.Join(llama, "abc", "kernel.cubin"))
+
+	steps, err = apply(true)
+	assert.NoError(t, err)
+	assert.Equal(t, ActionRemove, actions(steps)["llama"])
+	assert.NoDirExists(t, filepath.Join(llama, "abc"))
+	assert.FileExists(t, filepath.Join(llama, "compiled.json"))
+	s, err = LoadState(statePath)
+	assert.NoError(t, err)
+	assert.NotContains(t, s.Caches, "llama")
+}
+
+func TestApplyRefusesUnmanagedDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "kernel.cubin"), nil, 0644))
+	f := &File{Caches: []Cache{{Name: "llama", Image: "quay.io/example/llama-cache:v1", Dir: dir}}}
+	assert.NoError(t, f.Validate())
+
+	steps := Plan(f, &State{Caches: map[string]CacheState{}}, nil, true)
+	err := Apply(steps, filepath.Join(t.TempDir(), "state.json"), func(c Cache) (string, error) {
+		t.Fatal("extracted into a directory holding other files")
+		return "", nil
+	})
+	assert.Error(t, err)
+	assert.ErrorContains(t, steps[0].Err, "did not extract")
+}
+
+func keys(m map[string]string) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,342 @@
+package apply
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/sharedfs"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Actions Plan decides on for each cache, and Apply takes.
+const (
+	ActionInstall = "install"   // Not extracted yet
+	ActionUpdate  = "update"    // Extracted from another image or dir, or its tag moved
+	ActionRepair  = "repair"    // Extracted files are missing or modified
+	ActionDrifted = "drifted"   // Extracted files changed, and are only reported
+	ActionNone    = "unchanged" // Extracted, and its files are as extracted
+	ActionRemove  = "remove"    // No longer in the file
+	ActionKeep    = "keep"      // No longer in the file, kept as pruning is off
+)
+
+// CacheState is what Apply recorded about a cache it extracted.
+type CacheState struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+	Dir    string `json:"dir"`
+	// Files holds the sha256 of every file extracted, by path relative to
+	// Dir; files added to Dir later, e.g. kernels compiled at run time, are
+	// neither checked nor removed.
+	Files     map[string]string `json:"files"`
+	AppliedAt time.Time         `json:"appliedAt"`
+}
+
+// State is what Apply recorded about the caches of the node.
+type State struct {
+	Caches map[string]CacheState `json:"caches"`
+}
+
+// LoadState reads the state recorded at path, which is empty if nothing
+// was applied yet.
+func LoadState(path string) (*State, error) {
+	s := &State{Caches: map[string]CacheState{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apply state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse apply state: %w", err)
+	}
+	if s.Caches == nil {
+		s.Caches = map[string]CacheState{}
+	}
+	return s, nil
+}
+
+func (s *State) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create apply state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write apply state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Step is what Apply does, or did, to a cache.
+type Step struct {
+	Cache         // As the file lists it; only Name, Image and Dir for removals
+	Action string // One of the Action constants
+	Detail string // Why, e.g. "2 file(s) missing"
+	Err    error  // Why Apply failed to take the action
+}
+
+// Resolver returns the digest an image reference currently refers to.
+type Resolver func(image string) (string, error)
+
+// Extractor verifies and extracts the image of c into c.Dir and returns
+// the digest it extracted.
+type Extractor func(c Cache) (digest string, err error)
+
+// Plan compares f with the state s and returns the step each cache needs,
+// in the order of the file followed by the caches no longer in it. With a
+// resolve, caches whose tag now refers to another digest are updated;
+// those it cannot resolve are kept as they are. Without prune, the caches
+// no longer in the file are kept.
+func Plan(f *File, s *State, resolve Resolver, prune bool) []Step {
+	var steps []Step
+	listed := map[string]bool{}
+	for _, c := range f.Caches {
+		listed[c.Name] = true
+		steps = append(steps, planCache(c, s, resolve))
+	}
+
+	var gone []string
+	for name := range s.Caches {
+		if !listed[name] {
+			gone = append(gone, name)
+		}
+	}
+	sort.Strings(gone)
+	for _, name := range gone {
+		cs := s.Caches[name]
+		step := Step{Cache: Cache{Name: name, Image: cs.Image, Dir: cs.Dir}, Action: ActionRemove, Detail: "no longer listed"}
+		if !prune {
+			step.Action = ActionKeep
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func planCache(c Cache, s *State, resolve Resolver) Step {
+	cs, ok := s.Caches[c.Name]
+	switch {
+	case !ok:
+		return Step{Cache: c, Action: ActionInstall}
+	case cs.Image != c.Image:
+		return Step{Cache: c, Action: ActionUpdate, Detail: "was " + cs.Image}
+	case cs.Dir != c.Dir:
+		return Step{Cache: c, Action: ActionUpdate, Detail: "was in " + cs.Dir}
+	}
+	if resolve != nil {
+		digest, err := resolve(c.Image)
+		if err != nil {
+			logging.Debugf("Not checking whether %s moved: %v", c.Image, err)
+		} else if digest != cs.Digest {
+			return Step{Cache: c, Action: ActionUpdate, Detail: "now " + digest}
+		}
+	}
+	if detail := verify(cs); detail != "" {
+		action := ActionRepair
+		if c.Drift == DriftReport {
+			action = ActionDrifted
+		}
+		return Step{Cache: c, Action: action, Detail: detail}
+	}
+	return Step{Cache: c, Action: ActionNone}
+}
+
+// Apply takes the steps Plan returned, extracting caches with extract, and
+// records what it extracted in the state at statePath. Caches are removed
+// first, so that a directory can pass from a removed cache to another.
+// Apply carries on past caches it fails to apply, and returns an error
+// naming those not optional.
+func Apply(steps []Step, statePath string, extract Extractor) error {
+	s, err := LoadState(statePath)
+	if err != nil {
+		return err
+	}
+	ordered := make([]*Step, 0, len(steps))
+	for i := range steps {
+		if steps[i].Action == ActionRemove {
+			ordered = append(ordered, &steps[i])
+		}
+	}
+	for i := range steps {
+		if steps[i].Action != ActionRemove {
+			ordered = append(ordered, &steps[i])
+		}
+	}
+
+	var failed []string
+	for _, step := range ordered {
+		step.Err = applyStep(step, s, statePath, extract)
+		switch {
+		case step.Err == nil:
+		case step.Optional:
+			diag.Warnf(diag.OptionalCacheFailed, "Failed to %s optional cache %s: %v", step.Action, step.Name, step.Err)
+		default:
+			logging.Errorf("Failed to %s cache %s: %v", step.Action, step.Name, step.Err)
+			failed = append(failed, step.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d cache(s) not applied: %v", len(failed), len(steps), failed)
+	}
+	return nil
+}
+
+func applyStep(step *Step, s *State, statePath string, extract Extractor) error {
+	switch step.Action {
+	case ActionNone:
+		return nil
+	case ActionKeep:
+		diag.Warnf(diag.CacheNotRemoved, "Keeping %s in %s, no longer listed", step.Name, step.Dir)
+		return nil
+	case ActionDrifted:
+		return fmt.Errorf("files changed since extracted: %s", step.Detail)
+	case ActionRemove:
+		logging.Infof("Removing %s from %s", step.Name, step.Dir)
+		return remove(step.Name, s, statePath)
+	}
+
+	// Install, update or repair: remove the files the cache was extracted
+	// with, then extract it again. Only the files extracting writes are
+	// recorded, not those the workload added to the directory since.
+	prev, ok := s.Caches[step.Name]
+	if ok {
+		if err := remove(step.Name, s, statePath); err != nil {
+			return err
+		}
+	}
+	before, err := hashFiles(step.Dir)
+	if err != nil {
+		return err
+	}
+	if (!ok || prev.Dir != step.Dir) && len(before) > 0 {
+		return fmt.Errorf("%s holds %d file(s) mcv apply did not extract; empty it or give the cache another dir", step.Dir, len(before))
+	}
+	logging.Infof("Extracting %s from %s into %s", step.Name, step.Image, step.Dir)
+	digest, err := extract(step.Cache)
+	if err != nil {
+		return err
+	}
+	files, err := hashFiles(step.Dir)
+	if err != nil {
+		return err
+	}
+	for rel, sum := range files {
+		if before[rel] == sum {
+			delete(files, rel)
+		}
+	}
+	s.Caches[step.Name] = CacheState{
+		Image:     step.Image,
+		Digest:    digest,
+		Dir:       step.Dir,
+		Files:     files,
+		AppliedAt: time.Now().UTC(),
+	}
+	return s.save(statePath)
+}
+
+// remove deletes the files the cache name was extracted with, and the
+// directories they leave empty, and forgets the cache.
+func remove(name string, s *State, statePath string) error {
+	cs := s.Caches[name]
+	for rel := range cs.Files {
+		path := filepath.Join(cs.Dir, rel)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		for dir := filepath.Dir(path); dir != cs.Dir && within(dir, cs.Dir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	if err := sharedfs.RemoveMarker(cs.Dir); err != nil {
+		return fmt.Errorf("failed to remove extraction marker: %w", err)
+	}
+	_ = os.Remove(cs.Dir)
+	delete(s.Caches, name)
+	return s.save(statePath)
+}
+
+// verify returns how the files of cs changed since they were extracted,
+// or "" if none did.
+func verify(cs CacheState) string {
+	missing, modified := 0, 0
+	for rel, sum := range cs.Files {
+		got, err := hashFile(filepath.Join(cs.Dir, rel))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			missing++
+		case err != nil || got != sum:
+			modified++
+		}
+	}
+	switch {
+	case missing > 0 && modified > 0:
+		return fmt.Sprintf("%d file(s) missing, %d modified", missing, modified)
+	case missing > 0:
+		return fmt.Sprintf("%d file(s) missing", missing)
+	case modified > 0:
+		return fmt.Sprintf("%d file(s) modified", modified)
+	}
+	return ""
+}
+
+// hashFiles returns the sha256 of every regular file under dir, by path
+// relative to dir, besides those coordinating extractions. A missing dir
+// holds no files.
+func hashFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == dir {
+			return nil
+		}
+		if err != nil || !d.Type().IsRegular() || isExtractFile(d.Name()) {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the files extracted into %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isExtractFile(name string) bool {
+	return name == sharedfs.MarkerFileName || name == sharedfs.LockFileName
+}
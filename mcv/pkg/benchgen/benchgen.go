@@ -0,0 +1,137 @@
+// Package benchgen generates synthetic Triton caches of a chosen size for
+// benchmarking the packer and extractor.
+package benchgen
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Options describes the cache to generate.
+type Options struct {
+	Kernels    int   // Number of kernel directories
+	BinarySize int64 // Size of each kernel binary in bytes
+	Backend    string
+	Arch       string
+	Seed       int64 // Seeds the content so runs are reproducible
+}
+
+// DefaultOptions generates a small CUDA cache.
+var DefaultOptions = Options{
+	Kernels:    100,
+	BinarySize: 64 << 10,
+	Backend:    "cuda",
+	Arch:       "90",
+	Seed:       1,
+}
+
+// Generate writes a Triton cache described by opts to dir and returns its
+// total size in bytes. Each kernel directory holds the metadata, group
+// file, IR and binary files Triton writes; binaries are random and IR is
+// repetitive text, so they compress like real caches.
+func Generate(dir string, opts Options) (int64, error) {
+	if opts.Kernels < 1 {
+		return 0, fmt.Errorf("kernel count must be at least 1, got %d", opts.Kernels)
+	}
+	if opts.BinarySize < 1 {
+		return 0, fmt.Errorf("binary size must be at least 1 byte, got %d", opts.BinarySize)
+	}
+	binExt, irExt := ".cubin", ".ptx"
+	switch opts.Backend {
+	case "cuda":
+	case "hip":
+		binExt, irExt = ".hsaco", ".amdgcn"
+	default:
+		return 0, fmt.Errorf("unsupported backend %q: must be cuda or hip", opts.Backend)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	var total int64
+	for i := 0; i < opts.Kernels; i++ {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("kernel-%d-%d", opts.Seed, i)))
+		kernelDir := filepath.Join(dir, base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(hash[:]))
+		if err := os.MkdirAll(kernelDir, 0755); err != nil {
+			return 0, err
+		}
+		name := fmt.Sprintf("kernel_%d", i)
+
+		metadata, err := json.Marshal(map[string]any{
+			"hash":         fmt.Sprintf("%x", hash),
+			"target":       map[string]any{"backend": opts.Backend, "arch": archValue(opts.Arch), "warp_size": warpSize(opts.Backend)},
+			"num_warps":    4,
+			"num_ctas":     1,
+			"num_stages":   3,
+			"debug":        false,
+			"backend_name": opts.Backend,
+			"shared":       0,
+			"name":         name,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		binary := make([]byte, opts.BinarySize)
+		rng.Read(binary)
+		ir := irText(name, int(opts.BinarySize/2))
+
+		files := map[string][]byte{
+			name + ".json":  metadata,
+			name + binExt:   binary,
+			name + irExt:    ir,
+			name + ".ttir":  ir,
+			name + ".ttgir": ir,
+			name + ".llir":  ir,
+		}
+		children := map[string]string{}
+		for file, data := range files {
+			p := filepath.Join(kernelDir, file)
+			if err := os.WriteFile(p, data, 0644); err != nil {
+				return 0, err
+			}
+			children[file] = p
+			total += int64(len(data))
+		}
+
+		group, err := json.MarshalIndent(map[string]any{"child_paths": children}, "", "  ")
+		if err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(filepath.Join(kernelDir, "__grp__"+name+".json"), group, 0644); err != nil {
+			return 0, err
+		}
+		total += int64(len(group))
+	}
+	return total, nil
+}
+
+// archValue returns the arch as Triton records it: a number for CUDA
+// compute capabilities, a string such as "gfx942" otherwise.
+func archValue(arch string) any {
+	if n, err := strconv.Atoi(arch); err == nil {
+		return n
+	}
+	return arch
+}
+
+func warpSize(backend string) int {
+	if backend == "hip" {
+		return 64
+	}
+	return 32
+}
+
+// irText returns about size bytes of IR-like text for kernel name.
+func irText(name string, size int) []byte {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "  %%%d = tt.load %%arg%d : tensor<128xf32> loc(%q)\n", i, i%8, name)
+	}
+	return []byte(b.String())
+}
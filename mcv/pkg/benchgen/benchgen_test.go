@@ -0,0 +1,44 @@
+package benchgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateWritesTritonCache(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Kernels: 2, BinarySize: 1024, Backend: "hip", Arch: "gfx942", Seed: 7}
+	size, err := Generate(dir, opts)
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	binaries, _ := filepath.Glob(filepath.Join(dir, "*", "*.hsaco"))
+	assert.Len(t, binaries, 2)
+
+	var onDisk int64
+	assert.NoError(t, filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			onDisk += info.Size()
+		}
+		return err
+	}))
+	assert.Equal(t, onDisk, size)
+
+	caches := cache.DetectCaches(dir)
+	if assert.Len(t, caches, 1) {
+		assert.Equal(t, 2, caches[0].EntryCount())
+	}
+
+	// The same seed generates the same cache.
+	again := t.TempDir()
+	_, err = Generate(again, opts)
+	assert.NoError(t, err)
+	entries2, _ := os.ReadDir(again)
+	assert.Equal(t, entries[0].Name(), entries2[0].Name())
+}
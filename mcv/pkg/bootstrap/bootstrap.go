@@ -0,0 +1,190 @@
+// Package bootstrap installs mcv on image-based OSes such as Fedora CoreOS
+// and RHCOS, where /usr is read-only: the binary and its units go into a
+// systemd-sysext extension under /var, and only configuration goes to /etc.
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// ExtensionName is the name of the sysext extension.
+	ExtensionName = "mcv"
+	// ExtensionsDir is where systemd-sysext looks for extensions.
+	ExtensionsDir = "/var/lib/extensions"
+	// ConfigDir holds the files an admin edits.
+	ConfigDir = "/etc/mcv"
+	// CacheRoot is where the bootstrap unit extracts caches by default.
+	CacheRoot = "/var/lib/mcv"
+
+	unitName = "mcv-compose.service"
+)
+
+// Options describes where to install.
+type Options struct {
+	// Root is the filesystem to install into, "/" for the running host.
+	// Image builders can pass the root of the image being assembled.
+	Root string
+	// Binary is the mcv binary to install, usually the running one.
+	Binary string
+}
+
+// File is a file Install writes.
+type File struct {
+	Path    string      // Absolute path on the target filesystem
+	Mode    fs.FileMode // 0 for a symlink to Content
+	Content string      // Ignored for the binary
+	// Keep leaves an existing file alone, so reinstalling does not
+	// overwrite configuration an admin changed.
+	Keep bool
+}
+
+// Layout returns the files Install writes, other than the binary.
+func Layout() []File {
+	ext := filepath.Join(ExtensionsDir, ExtensionName)
+	return []File{
+		{
+			Path:    filepath.Join(ext, "usr/lib/extension-release.d", "extension-release."+ExtensionName),
+			Mode:    0644,
+			Content: fmt.Sprintf("ID=_any\nARCHITECTURE=%s\n", sysextArch(runtime.GOARCH)),
+		},
+		{Path: filepath.Join(ext, "usr/lib/systemd/system", unitName), Mode: 0644, Content: composeUnit},
+		{Path: filepath.Join(ext, "usr/share/selinux/packages/mcv/mcv.cil"), Mode: 0644, Content: selinuxModule},
+		{Path: filepath.Join("/etc/systemd/system", unitName+".d", "10-mcv.conf"), Mode: 0644, Content: unitDropIn},
+		{Path: filepath.Join(ConfigDir, "mcv.env"), Mode: 0644, Content: envFile, Keep: true},
+		// systemctl enable, done by hand so it also works on an offline root.
+		{Path: filepath.Join("/etc/systemd/system/multi-user.target.wants", unitName), Content: "/usr/lib/systemd/system/" + unitName},
+	}
+}
+
+// BinaryPath is where Install puts the mcv binary on the target.
+func BinaryPath() string {
+	return filepath.Join(ExtensionsDir, ExtensionName, "usr/bin/mcv")
+}
+
+// Install writes the sysext extension, its units and the default
+// configuration under opts.Root and returns the paths written. Files with
+// Mode 0 are symlinks to Content.
+func Install(opts Options) ([]string, error) {
+	if opts.Root == "" {
+		opts.Root = "/"
+	}
+	if opts.Binary == "" {
+		return nil, errors.New("no mcv binary to install")
+	}
+
+	var written []string
+	bin := filepath.Join(opts.Root, BinaryPath())
+	if err := copyBinary(opts.Binary, bin); err != nil {
+		return nil, err
+	}
+	written = append(written, bin)
+
+	for _, f := range Layout() {
+		p := filepath.Join(opts.Root, f.Path)
+		if f.Keep {
+			if _, err := os.Lstat(p); err == nil {
+				continue
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return written, fmt.Errorf("failed to create %s: %w", filepath.Dir(p), err)
+		}
+		var err error
+		if f.Mode == 0 {
+			_ = os.Remove(p)
+			err = os.Symlink(f.Content, p)
+		} else {
+			err = os.WriteFile(p, []byte(f.Content), f.Mode)
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", p, err)
+		}
+		written = append(written, p)
+	}
+	return written, nil
+}
+
+func copyBinary(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open mcv binary: %w", err)
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	// Write beside the target and rename, so a running copy of the
+	// binary is replaced rather than modified.
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to install mcv binary: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to install mcv binary: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to install mcv binary: %w", err)
+	}
+	return os.Rename(tmp, dst)
+}
+
+// sysextArch maps a Go architecture to the name systemd uses.
+func sysextArch(goarch string) string {
+	switch goarch {
+	case "amd64":
+		return "x86-64"
+	case "arm64":
+		return "arm64"
+	case "ppc64le":
+		return "ppc64-le"
+	case "s390x":
+		return "s390x"
+	}
+	return goarch
+}
+
+const composeUnit = `[Unit]
+Description=Extract the GPU kernel caches listed in /etc/mcv/mcv-compose.yaml
+Documentation=https://github.com/redhat-et/MCU
+Wants=network-online.target
+After=network-online.target systemd-sysext.service
+ConditionPathExists=/etc/mcv/mcv-compose.yaml
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/bin/mcv compose up -f /etc/mcv/mcv-compose.yaml
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const unitDropIn = `# Installed by mcv --bootstrap. Settings go in /etc/mcv/mcv.env.
+[Service]
+EnvironmentFile=-/etc/mcv/mcv.env
+StateDirectory=mcv
+`
+
+const envFile = `# mcv settings for the units installed by mcv --bootstrap.
+# Caches are extracted to the dir of each component in
+# /etc/mcv/mcv-compose.yaml; use a dir under /var/lib/mcv so the
+# SELinux module labels the cache for containers.
+MCV_IMAGE_STORE=/var/lib/mcv/oci
+MCV_INVENTORY_FILE=/var/lib/mcv/installed.json
+#MCV_PIN_DIGESTS=true
+#MCV_PIN_LOCK_FILE=/var/lib/mcv/pins.json
+`
+
+// selinuxModule labels extracted caches so containers can read them. It is
+// CIL, which semodule installs without a policy compiler on the host.
+const selinuxModule = `(filecon "/var/lib/mcv(/.*)?" any (system_u object_r container_file_t ((s0) (s0))))
+`
@@ -0,0 +1,37 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstall(t *testing.T) {
+	root := t.TempDir()
+	bin := filepath.Join(t.TempDir(), "mcv")
+	assert.NoError(t, os.WriteFile(bin, []byte("binary"), 0755))
+
+	written, err := Install(Options{Root: root, Binary: bin})
+	assert.NoError(t, err)
+	assert.Len(t, written, len(Layout())+1)
+
+	data, err := os.ReadFile(filepath.Join(root, BinaryPath()))
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", string(data))
+	assert.FileExists(t, filepath.Join(root, ExtensionsDir, ExtensionName, "usr/lib/extension-release.d/extension-release.mcv"))
+	link, err := os.Readlink(filepath.Join(root, "etc/systemd/system/multi-user.target.wants", unitName))
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/lib/systemd/system/"+unitName, link)
+
+	// Reinstalling keeps configuration the admin changed.
+	env := filepath.Join(root, ConfigDir, "mcv.env")
+	assert.NoError(t, os.WriteFile(env, []byte("MCV_PIN_DIGESTS=true\n"), 0644))
+	written, err = Install(Options{Root: root, Binary: bin})
+	assert.NoError(t, err)
+	assert.NotContains(t, written, env)
+	data, err = os.ReadFile(env)
+	assert.NoError(t, err)
+	assert.Equal(t, "MCV_PIN_DIGESTS=true\n", string(data))
+}
@@ -0,0 +1,68 @@
+// Package build holds the version information the Makefile sets with
+// -ldflags -X at link time.
+package build
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version  = "unknown"
+	Revision = "unknown"
+	Branch   = "unknown"
+	OS       = runtime.GOOS
+	Arch     = runtime.GOARCH
+)
+
+// components are the modules whose versions matter in bug reports, by the
+// name they are reported under.
+var components = map[string]string{
+	"buildah":              "github.com/containers/buildah",
+	"containers-storage":   "github.com/containers/storage",
+	"containers-image":     "github.com/containers/image/v5",
+	"go-containerregistry": "github.com/google/go-containerregistry",
+}
+
+// Info describes the binary.
+type Info struct {
+	Version    string            `json:"version"`
+	Revision   string            `json:"revision"`
+	Branch     string            `json:"branch"`
+	Flavor     string            `json:"flavor"`
+	GoVersion  string            `json:"goVersion"`
+	OS         string            `json:"os"`
+	Arch       string            `json:"arch"`
+	Components map[string]string `json:"components"`
+}
+
+// Get returns the version information of the running binary, including
+// the versions of the main modules it was built with.
+func Get() Info {
+	info := Info{
+		Version:    Version,
+		Revision:   Revision,
+		Branch:     Branch,
+		Flavor:     Flavor,
+		GoVersion:  runtime.Version(),
+		OS:         OS,
+		Arch:       Arch,
+		Components: map[string]string{},
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, dep := range bi.Deps {
+		for name, path := range components {
+			if dep.Path != path {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			info.Components[name] = dep.Version
+		}
+	}
+	return info
+}
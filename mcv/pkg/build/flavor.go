@@ -0,0 +1,6 @@
+//go:build !mcv_edge
+
+package build
+
+// Flavor names the set of features compiled in.
+const Flavor = "full"
@@ -0,0 +1,7 @@
+//go:build mcv_edge
+
+package build
+
+// Flavor names the set of features compiled in: edge builds only pull,
+// verify and extract images, and detect GPUs through sysfs.
+const Flavor = "edge"
@@ -2,7 +2,7 @@ package cache
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
 )
@@ -45,6 +46,77 @@ func DetectCaches(root string) []Cache {
 	return caches
 }
 
+// LayerTypeAnnotation names the cache type held by an image layer. Images
+// with colocated caches store each type in its own layer, and extraction
+// only reads the layers of the type it restores.
+const LayerTypeAnnotation = "cache.mcv.image/layer-type"
+
+// Component is a cache found in its own subdirectory of a directory that
+// holds caches of several types side by side.
+type Component struct {
+	Dir   string
+	Cache Cache
+}
+
+// DetectComponents looks for colocated caches of different types in the
+// immediate subdirectories of root, e.g. root/vllm holding a vLLM cache
+// next to root/triton holding a Triton cache. It returns nil unless root
+// holds more than one cache type, at most one of each, and is not itself a
+// vLLM cache.
+func DetectComponents(root string) []Component {
+	if isVLLMCacheDir(root) {
+		return nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var components []Component
+	var others []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if !isVLLMCacheDir(dir) {
+			others = append(others, dir)
+			continue
+		}
+		if vllm := DetectVLLMCache(dir); vllm != nil {
+			components = append(components, Component{Dir: dir, Cache: vllm})
+		}
+	}
+	if len(components) == 0 {
+		// A Triton cache on its own is packaged as a whole.
+		return nil
+	}
+	for _, dir := range others {
+		if triton := DetectTritonCache(dir); triton != nil {
+			components = append(components, Component{Dir: dir, Cache: triton})
+		}
+	}
+
+	seen := map[string]string{}
+	for _, c := range components {
+		if prev, ok := seen[c.Cache.Name()]; ok {
+			diag.Warnf(diag.CachesInSeveralDirs, "Found %s caches in both %s and %s; package them separately", c.Cache.Name(), prev, c.Dir)
+			return nil
+		}
+		seen[c.Cache.Name()] = c.Dir
+	}
+	if len(components) < 2 {
+		return nil
+	}
+	return components
+}
+
+// isVLLMCacheDir reports whether dir has the layout of a vLLM cache root.
+func isVLLMCacheDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "torch_compile_cache"))
+	return err == nil && info.IsDir()
+}
+
 // BuildLabels combines label maps from all caches into a single set of image labels
 func BuildLabels(caches []Cache) Labels {
 	result := make(Labels)
@@ -123,6 +195,12 @@ func getTotalDirSize(dir string) (int64, error) {
 			if err != nil {
 				return err
 			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				// Staged files streamed from the source cache.
+				if info, err = os.Stat(path); err != nil {
+					return err
+				}
+			}
 			total += info.Size()
 		}
 		return nil
@@ -159,109 +237,250 @@ func SetCachesBuildDir(caches []Cache, path string) {
 }
 
 func ExtractCacheDirectory(r io.Reader, cacheType string) ([]string, error) {
-	if cacheType == "" {
-		return nil, fmt.Errorf("cache type is empty")
+	return ExtractCacheDirectoryResumable(r, cacheType, "", false)
+}
+
+// ExtractCacheDirectoryResumable extracts like ExtractCacheDirectory while
+// keeping a journal of completed entries in the cache directory. journalID
+// identifies the content (e.g. the layer digest); when resume is set and a
+// journal for the same content exists, completed entries are skipped. The
+// journal is removed once extraction succeeds. An empty journalID disables
+// journaling.
+func ExtractCacheDirectoryResumable(r io.Reader, cacheType, journalID string, resume bool) ([]string, error) {
+	e, err := NewLayerExtractor(cacheType, resume)
+	if err != nil {
+		return nil, err
 	}
-	switch cacheType {
-	case constants.Triton:
-		return ExtractTritonCacheDirectory(r)
-	case constants.VLLM:
-		return ExtractVLLMCacheDirectory(r)
-	default:
-		return nil, fmt.Errorf("unsupported cache type: %s", cacheType)
+	if err := e.Apply(r, journalID); err != nil {
+		return nil, err
+	}
+	return e.Dirs(), nil
+}
+
+// tritonArtifactDirs maps the image paths of packaged Triton dump and
+// override directories to where Triton looks for them on this host.
+func tritonArtifactDirs() map[string]string {
+	return map[string]string{
+		constants.MCVTritonDumpDir:     constants.TritonDumpDir,
+		constants.MCVTritonOverrideDir: constants.TritonOverrideDir,
 	}
 }
 
+// artifactPath returns where an entry under one of the artifactDirs
+// prefixes is extracted to, and the directory it is mapped to.
+func artifactPath(name string, artifactDirs map[string]string) (path, root string, ok bool) {
+	for prefix, dest := range artifactDirs {
+		if rel := strings.TrimPrefix(name, prefix); rel != name && rel != "" && dest != "" {
+			return filepath.Join(dest, rel), dest, true
+		}
+	}
+	return "", "", false
+}
+
 // Shared extraction logic for Triton/VLLM cache and manifest directories.
+// Entries under the artifactDirs prefixes are extracted to the mapped
+// directories. overlay tracks the layers applied before this one, for
+// whiteouts; nil extracts a single layer. hot, if not nil, is told of the
+// manifest and of each cache file to report the hot entries ready.
+// skipped, if not nil, records the entries that cannot be extracted, and
+// the rest of the layer once it cannot be read, instead of failing. Once
+// ctx is done, the extraction stops before the next entry, keeping the
+// journal for a later resume.
 func extractCacheAndManifestDirectory(
+	ctx context.Context,
 	r io.Reader,
 	cacheDirPrefix, manifestDirPrefix, extractCacheDir, extractManifestDir string,
-) ([]string, error) {
-	var extractedDirs []string
-	gr, err := gzip.NewReader(r)
+	artifactDirs map[string]string,
+	journalID string, resume bool,
+	overlay *layerApplier,
+	hot *hotTracker,
+	skipped *skippedEntries,
+) (extractedDirs []string, err error) {
+	if overlay == nil {
+		overlay = newLayerApplier(nil)
+	}
+
+	gr, err := decompressLayer(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse layer as tar.gz: %v", err)
+		return nil, corrupt(err, fmt.Errorf("failed to decompress layer: %w", err))
 	}
 	defer gr.Close()
 
-	tr := tar.NewReader(gr)
+	stream := &streamReader{r: gr}
+	tr := tar.NewReader(stream)
 
 	// Ensure top-level output directories exist once
-	if err = os.MkdirAll(extractCacheDir, 0755); err != nil {
+	if err = overlay.store.MkdirAll(extractCacheDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
-	if err = os.MkdirAll(extractManifestDir, 0755); err != nil {
+	if err = overlay.store.MkdirAll(extractManifestDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create manifest directory: %w", err)
 	}
 
+	skippedBefore := skipped.count()
+	var journal *extractJournal
+	if journalID != "" {
+		if journal, err = openJournal(extractCacheDir, journalID, resume); err != nil {
+			return nil, err
+		}
+		// Skipped entries are not journaled, so resuming retries them.
+		defer func() { journal.Close(err == nil && skipped.count() == skippedBefore) }()
+	}
+
+entries:
 	for {
+		if err = ctx.Err(); err != nil {
+			return nil, fmt.Errorf("extraction canceled: %w", err)
+		}
 		h, ret := tr.Next()
 		if ret == io.EOF {
+			// Read the layer to its end, where gzip and the registry
+			// client check it against its checksum and digest.
+			if _, ret = io.Copy(io.Discard, stream); ret == nil {
+				break
+			}
+		}
+		if ret != nil {
+			if err = skipped.skip("rest of the layer", corrupt(ret, fmt.Errorf("error reading tar archive: %w", ret))); err != nil {
+				return nil, err
+			}
 			break
-		} else if ret != nil {
-			return nil, fmt.Errorf("error reading tar archive: %w", ret)
 		}
 
+		artifact, artifactRoot, isArtifact := artifactPath(h.Name, artifactDirs)
+
 		// Skip irrelevant files
 		if !strings.HasPrefix(h.Name, cacheDirPrefix) &&
-			!strings.HasPrefix(h.Name, manifestDirPrefix+"manifest.json") {
+			!strings.HasPrefix(h.Name, manifestDirPrefix+"manifest.json") && !isArtifact {
 			continue
 		}
 
 		// Determine output path
-		var filePath string
-		if strings.HasPrefix(h.Name, cacheDirPrefix) {
+		var filePath, root, topDir string
+		if isArtifact {
+			filePath, root = artifact, artifactRoot
+		} else if strings.HasPrefix(h.Name, cacheDirPrefix) {
 			rel := strings.TrimPrefix(h.Name, cacheDirPrefix)
 			if rel == "" {
 				continue
 			}
-			filePath = filepath.Join(extractCacheDir, rel)
-
-			topDir := filepath.Join(extractCacheDir, filepath.Dir(rel))
-			if !stringInSlice(topDir, extractedDirs) {
-				extractedDirs = append(extractedDirs, topDir)
+			filePath, root = filepath.Join(extractCacheDir, rel), extractCacheDir
+			if !isWhiteout(h.Name) {
+				topDir = filepath.Join(extractCacheDir, filepath.Dir(rel))
 			}
 		} else if strings.HasPrefix(h.Name, manifestDirPrefix) {
 			rel := strings.TrimPrefix(h.Name, manifestDirPrefix)
-			filePath = filepath.Join(extractManifestDir, rel)
+			filePath, root = filepath.Join(extractManifestDir, rel), extractManifestDir
+		}
+		if !withinDir(filePath, root) {
+			if err = skipped.skip(h.Name, fmt.Errorf("entry is outside of %s", root)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if topDir != "" && !stringInSlice(topDir, extractedDirs) {
+			extractedDirs = append(extractedDirs, topDir)
+		}
+
+		if wh, whErr := overlay.whiteout(filePath); wh {
+			if whErr != nil {
+				if err = skipped.skip(h.Name, whErr); err != nil {
+					return nil, err
+				}
+			}
+			continue
 		}
 
 		// Ensure parent dir exists
-		if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-			return nil, fmt.Errorf("failed to create directory for %s: %w", filePath, err)
+		if err = overlay.mkdirAll(filepath.Dir(filePath)); err != nil {
+			if err = skipped.skip(h.Name, fmt.Errorf("failed to create directory for %s: %w", filePath, err)); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		switch h.Typeflag {
 		case tar.TypeDir:
-			if err = os.MkdirAll(filePath, os.FileMode(h.Mode)); err != nil {
-				return nil, fmt.Errorf("failed to create directory %s: %w", filePath, err)
+			if err = overlay.replace(filePath, true); err == nil {
+				if err = overlay.store.MkdirAll(filePath, os.FileMode(h.Mode)); err != nil {
+					err = fmt.Errorf("failed to create directory %s: %w", filePath, err)
+				}
+			}
+			if err != nil {
+				if err = skipped.skip(h.Name, err); err != nil {
+					return nil, err
+				}
+				continue
 			}
+			overlay.record(filePath)
 		case tar.TypeReg:
-			if err = writeFile(filePath, tr, os.FileMode(h.Mode)); err != nil {
-				return nil, fmt.Errorf("failed to write file %s: %w", filePath, err)
+			if !isArtifact && strings.HasPrefix(h.Name, cacheDirPrefix) {
+				hot.reach(strings.TrimPrefix(h.Name, cacheDirPrefix))
+			}
+			if journal.Done(h.Name, filePath, h.Size) {
+				overlay.record(filePath)
+				if h.Name == manifestDirPrefix+"manifest.json" {
+					hot.loadManifest(filePath)
+				}
+				continue
+			}
+			if err = overlay.replace(filePath, false); err == nil {
+				if err = overlay.store.WriteFile(filePath, tr, h.Size, os.FileMode(h.Mode)); err != nil {
+					err = fmt.Errorf("failed to write file %s: %w", filePath, err)
+				}
+			}
+			if err != nil {
+				if stream.err != nil {
+					// Nothing past an unreadable entry can be read.
+					err = corrupt(stream.err, fmt.Errorf("%w; the rest of the layer is unreadable", err))
+				}
+				if err = skipped.skip(h.Name, err); err != nil {
+					return nil, err
+				}
+				if stream.err != nil {
+					break entries
+				}
+				continue
+			}
+			overlay.record(filePath)
+			if err = journal.Record(h.Name, h.Size); err != nil {
+				return nil, fmt.Errorf("failed to update extraction journal: %w", err)
+			}
+			if h.Name == manifestDirPrefix+"manifest.json" {
+				hot.loadManifest(filePath)
 			}
 		default:
 			logging.Debugf("Skipping unsupported type: %c in file %s", h.Typeflag, h.Name)
 		}
 	}
 
-	// Fix up cache JSONs
-	err = filepath.Walk(extractCacheDir, func(path string, info os.FileInfo, err error) error {
+	if err = fixupCacheDir(extractCacheDir, extractCacheDir); err != nil {
+		return nil, err
+	}
+	return extractedDirs, nil
+}
+
+// fixupCacheDir points the cache JSONs below dir, part of the cache
+// extracted to root, at where the cache now is.
+func fixupCacheDir(dir, root string) error {
+	if err := ResolvePaths(dir, root); err != nil {
+		return fmt.Errorf("error resolving cache paths: %w", err)
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if !info.IsDir() && strings.HasPrefix(info.Name(), "__grp__") && strings.HasSuffix(info.Name(), ".json") {
-			if err := utils.RestoreFullPathsInGroupJSON(path, extractCacheDir); err != nil {
-				logging.Warnf("failed to restore full paths in %s: %v", path, err)
+			if err := utils.RestoreFullPathsInGroupJSON(path, root); err != nil {
+				diag.Warnf(diag.PathsNotRelocated, "failed to restore full paths in %s: %v", path, err)
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error restoring full paths in cache JSON files: %w", err)
+		return fmt.Errorf("error restoring full paths in cache JSON files: %w", err)
 	}
-
-	return extractedDirs, nil
+	return nil
 }
 
 func stringInSlice(str string, list []string) bool {
@@ -272,26 +491,3 @@ func stringInSlice(str string, list []string) bool {
 	}
 	return false
 }
-
-func writeFile(filePath string, tarReader io.Reader, mode os.FileMode) error {
-	// Create any parent directories if needed
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return fmt.Errorf("failed to create parent directories for %s: %w", filePath, err)
-	}
-
-	outFile, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", filePath, err)
-	}
-	defer outFile.Close()
-
-	if _, err := io.Copy(outFile, tarReader); err != nil {
-		return fmt.Errorf("failed to copy content to file %s: %w", filePath, err)
-	}
-
-	if err := os.Chmod(filePath, mode); err != nil {
-		return fmt.Errorf("failed to set file permissions for %s: %w", filePath, err)
-	}
-
-	return nil
-}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/benchgen"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractTritonArtifacts(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "cache")
+	dumpDir := filepath.Join(root, "dump")
+	archive := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin":      "a",
+		"io.triton.dump/AAA/a.ttir":        "dump",
+		"io.triton.override/AAA/a.ttgir":   "override",
+		"io.triton.manifest/manifest.json": "{}",
+		"licenses/LICENSE":                 "ignored",
+	})
+
+	dirs, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, filepath.Join(root, "manifest"), map[string]string{
+			"io.triton.dump/":     dumpDir,
+			"io.triton.override/": "",
+		}, "", false, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(cacheDir, "AAA")}, dirs)
+
+	data, err := os.ReadFile(filepath.Join(dumpDir, "AAA", "a.ttir"))
+	assert.NoError(t, err)
+	assert.Equal(t, "dump", string(data))
+
+	// Artifacts without a destination are not extracted.
+	assert.NoFileExists(t, filepath.Join(cacheDir, "AAA", "a.ttgir"))
+	assert.NoDirExists(t, filepath.Join(root, "licenses"))
+}
+
+func TestDetectComponents(t *testing.T) {
+	root := t.TempDir()
+	opts := benchgen.Options{Kernels: 2, BinarySize: 64, Backend: "cuda", Arch: "90", Seed: 1}
+	_, err := benchgen.Generate(filepath.Join(root, "triton"), opts)
+	assert.NoError(t, err)
+	rank := filepath.Join(root, "vllm", "torch_compile_cache", "abc123", "rank_0_0")
+	assert.NoError(t, os.MkdirAll(filepath.Join(rank, "inductor_cache"), 0755))
+	_, err = benchgen.Generate(filepath.Join(rank, "triton_cache"), opts)
+	assert.NoError(t, err)
+
+	components := DetectComponents(root)
+	if !assert.Len(t, components, 2) {
+		return
+	}
+	dirs := map[string]string{}
+	for _, c := range components {
+		dirs[c.Cache.Name()] = c.Dir
+	}
+	assert.Equal(t, map[string]string{
+		constants.VLLM:   filepath.Join(root, "vllm"),
+		constants.Triton: filepath.Join(root, "triton"),
+	}, dirs)
+
+	// Each cache on its own is not colocated.
+	assert.Nil(t, DetectComponents(filepath.Join(root, "vllm")))
+	assert.Nil(t, DetectComponents(filepath.Join(root, "triton")))
+}
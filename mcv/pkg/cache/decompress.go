@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/redhat-et/MCU/mcv/pkg/reslimit"
+)
+
+// maxZstdDecoders is the most goroutines decoding a zstd layer, beyond
+// which streaming decompression gets no faster.
+const maxZstdDecoders = 4
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompressLayer returns a reader for the tar stream in a gzip or zstd
+// compressed layer, detecting the format from its magic bytes. Its buffers
+// and decoder goroutines are sized to the cgroup limits of mcv.
+func decompressLayer(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, reslimit.BufferSize())
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(head, zstdMagic):
+		opts := []zstd.DOption{
+			zstd.WithDecoderConcurrency(min(maxZstdDecoders, reslimit.Workers())),
+			zstd.WithDecoderLowmem(reslimit.LowMemory()),
+		}
+		if m := reslimit.DecoderMemory(); m > 0 {
+			opts = append(opts, zstd.WithDecoderMaxMemory(m))
+		}
+		zr, err := zstd.NewReader(br, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("layer is neither gzip nor zstd compressed")
+	}
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidUntilLabel records, in RFC 3339, when a cache image expires. It is
+// set as a label and a manifest annotation, so a signature over the
+// manifest covers it.
+const ValidUntilLabel = "cache.mcv.image/valid-until"
+
+// ParseValidUntil parses an expiry given as a date (2026-06-30), an
+// RFC 3339 time, or a duration from now such as 90d or 2160h.
+func ParseValidUntil(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		// Valid through the whole day.
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.Add(time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q: expected a date such as 2026-06-30, an RFC 3339 time, or a duration such as 90d", s)
+}
+
+// ValidUntil returns the expiry recorded in labels, if any.
+func ValidUntil(labels map[string]string) (time.Time, bool, error) {
+	v, ok := labels[ValidUntilLabel]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s label %q: %w", ValidUntilLabel, v, err)
+	}
+	return t, true, nil
+}
+
+// DriverEOL maps GPU driver branches, such as "535" or "550.54", to the
+// dates they reach end of life.
+type DriverEOL map[string]time.Time
+
+// LoadDriverEOL reads a YAML file mapping driver branches to end of life
+// dates:
+//
+//	"535": 2026-06-30
+//	"550": 2025-10-31
+func LoadDriverEOL(path string) (DriverEOL, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read driver EOL file: %w", err)
+	}
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse driver EOL file %s: %w", path, err)
+	}
+	eol := DriverEOL{}
+	for branch, date := range raw {
+		t, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of life date %q for driver %s in %s", date, branch, path)
+		}
+		eol[branch] = t.Add(24*time.Hour - time.Second)
+	}
+	return eol, nil
+}
+
+// Expiry returns the earliest end of life of the branches the driver
+// versions belong to. Each version is matched to its longest listed
+// branch.
+func (d DriverEOL) Expiry(versions []string) (time.Time, error) {
+	branches := make([]string, 0, len(d))
+	for b := range d {
+		branches = append(branches, b)
+	}
+	sort.Slice(branches, func(i, j int) bool { return len(branches[i]) > len(branches[j]) })
+
+	var expiry time.Time
+	for _, v := range versions {
+		found := false
+		for _, b := range branches {
+			if v == b || strings.HasPrefix(v, b+".") {
+				if expiry.IsZero() || d[b].Before(expiry) {
+					expiry = d[b]
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return time.Time{}, fmt.Errorf("no end of life date for driver %s", v)
+		}
+	}
+	if expiry.IsZero() {
+		return time.Time{}, fmt.Errorf("no driver versions to derive an expiry from")
+	}
+	return expiry, nil
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValidUntil(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Time{
+		"2026-06-30":           time.Date(2026, 6, 30, 23, 59, 59, 0, time.UTC),
+		"2026-06-30T10:00:00Z": time.Date(2026, 6, 30, 10, 0, 0, 0, time.UTC),
+		"90d":                  now.Add(90 * 24 * time.Hour),
+		"36h":                  now.Add(36 * time.Hour),
+	} {
+		got, err := ParseValidUntil(in, now)
+		assert.NoError(t, err, in)
+		assert.True(t, want.Equal(got), in)
+	}
+	for _, in := range []string{"", "soon", "-5d", "0h"} {
+		_, err := ParseValidUntil(in, now)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestDriverEOL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eol.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("\"535\": 2026-06-30\n\"550\": 2025-10-31\n\"550.54\": 2026-01-31\n"), 0644))
+	eol, err := LoadDriverEOL(path)
+	assert.NoError(t, err)
+
+	got, err := eol.Expiry([]string{"535.104.05"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-06-30", got.Format(time.DateOnly))
+
+	// The longest branch wins, and the earliest end of life across drivers.
+	got, err = eol.Expiry([]string{"535.104.05", "550.54.15"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-01-31", got.Format(time.DateOnly))
+	got, err = eol.Expiry([]string{"550.90.07"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-10-31", got.Format(time.DateOnly))
+
+	_, err = eol.Expiry([]string{"5350.1"})
+	assert.Error(t, err)
+	_, err = eol.Expiry(nil)
+	assert.Error(t, err)
+}
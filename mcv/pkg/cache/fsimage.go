@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Filesystem image formats a cache layer can be stored as instead of a tar
+// archive, so it can be mounted rather than unpacked.
+const (
+	FSImageSquashfs = "squashfs"
+	FSImageErofs    = "erofs"
+)
+
+const fsImageMediaTypePrefix = "application/vnd.mcv.cache.layer.v1."
+
+// FSImageLabel names the filesystem image format of the cache layer.
+const FSImageLabel = "cache.mcv.image/fs-image"
+
+// FSImageFormats returns the supported filesystem image formats.
+func FSImageFormats() []string {
+	return []string{FSImageSquashfs, FSImageErofs}
+}
+
+// ValidateFSImageFormat checks format is supported. Empty means tar.
+func ValidateFSImageFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range FSImageFormats() {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported filesystem image format %q (supported: %s)", format, strings.Join(FSImageFormats(), ", "))
+}
+
+// FSImageMediaType returns the layer media type of a filesystem image.
+func FSImageMediaType(format string) string {
+	return fsImageMediaTypePrefix + format
+}
+
+// FSImageFormat returns the filesystem image format of a layer media type,
+// or "" for other layers.
+func FSImageFormat(mediaType string) string {
+	format := strings.TrimPrefix(mediaType, fsImageMediaTypePrefix)
+	if format == mediaType || ValidateFSImageFormat(format) != nil {
+		return ""
+	}
+	return format
+}
+
+// UnpackFSImage copies the contents of the filesystem image blob into dir.
+func UnpackFSImage(blob, format, dir string) error {
+	var cmd *exec.Cmd
+	switch format {
+	case FSImageSquashfs:
+		cmd = exec.Command("unsquashfs", "-f", "-no-progress", "-d", dir, blob)
+	case FSImageErofs:
+		cmd = exec.Command("fsck.erofs", "--extract="+dir, "--overwrite", blob)
+	default:
+		return ValidateFSImageFormat(format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unpack %s image with %s: %w: %s", format, cmd.Args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// MountFSImage loop-mounts the filesystem image blob read-only under
+// stateDir and mounts a writable overlay of it on target, so the runtime
+// can still add the kernels it compiles. The overlay's upper and work
+// directories are kept in stateDir. target must be empty.
+func MountFSImage(blob, format, stateDir, target string) error {
+	if err := ValidateFSImageFormat(format); err != nil || format == "" {
+		return fmt.Errorf("cannot mount %q: %w", blob, err)
+	}
+	if entries, err := os.ReadDir(target); err == nil && len(entries) > 0 {
+		return fmt.Errorf("cannot mount the cache on %s: the directory is not empty", target)
+	}
+
+	lower := filepath.Join(stateDir, "lower")
+	upper := filepath.Join(stateDir, "upper")
+	work := filepath.Join(stateDir, "work")
+	for _, dir := range []string{lower, upper, work, target} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	if err := runMount("-t", format, "-o", "loop,ro", blob, lower); err != nil {
+		return err
+	}
+	opts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lower, upper, work)
+	if err := runMount("-t", "overlay", "overlay", "-o", opts, target); err != nil {
+		if out, umountErr := exec.Command("umount", lower).CombinedOutput(); umountErr != nil {
+			diag.Warnf(diag.UnmountFailed, "Failed to unmount %s: %v: %s", lower, umountErr, strings.TrimSpace(string(out)))
+		}
+		return err
+	}
+	logging.Infof("Mounted %s image %s on %s", format, blob, target)
+	return nil
+}
+
+func runMount(args ...string) error {
+	if out, err := exec.Command("mount", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("mount %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"debug/elf"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// elfArchs maps ELF machines to Go architecture names, as reported by
+// runtime.GOARCH on the hosts that load them.
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+	elf.EM_RISCV:   "riscv64",
+}
+
+// DetectHostArchs returns the sorted CPU architectures of the shared
+// objects under root, such as Triton's __triton_launcher.so and
+// cuda_utils.so or Inductor's compiled wrappers. These are built for the
+// host that ran the compilation and only load on hosts of the same
+// architecture, unlike the GPU binaries next to them. A cache without
+// shared objects returns nil: it runs on hosts of any architecture.
+func DetectHostArchs(root string) []string {
+	seen := map[string]bool{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ".so") {
+			return nil
+		}
+		f, err := elf.Open(path)
+		if err != nil {
+			logging.Debugf("Skipping %s, not an ELF object: %v", path, err)
+			return nil
+		}
+		defer f.Close()
+		arch, ok := elfArchs[f.Machine]
+		if !ok {
+			arch = strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+		}
+		seen[arch] = true
+		return nil
+	})
+	if err != nil {
+		diag.Warnf(diag.CacheReadFailed, "Error looking for host code in %s: %v", root, err)
+	}
+
+	var archs []string
+	for arch := range seen {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	if len(archs) > 1 {
+		diag.Warnf(diag.SeveralHostArchs, "Cache %s holds host code for several CPU architectures: %s", root, strings.Join(archs, ", "))
+	}
+	return archs
+}
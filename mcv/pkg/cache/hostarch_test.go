@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectHostArchs(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "AAA"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "AAA", "kernel.cubin"), []byte("gpu"), 0644))
+	assert.Nil(t, DetectHostArchs(root))
+
+	// Any ELF object built for this host stands in for a launcher stub.
+	exe, err := os.Executable()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(exe)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "AAA", "__triton_launcher.so"), data, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "AAA", "notes.so"), []byte("not ELF"), 0644))
+	assert.Equal(t, []string{runtime.GOARCH}, DetectHostArchs(root))
+}
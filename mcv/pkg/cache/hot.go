@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// ValidateHotKernels rejects malformed kernel name patterns.
+func ValidateHotKernels(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid hot kernel pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// MarkHotKernels marks the Triton entries of c whose kernel name matches
+// one of patterns (e.g. "*attn*") as hot in its manifest, and returns
+// their directories relative to the cache, sorted. mcv create writes hot
+// entries first, right after the manifest, so extraction restores them
+// first and can report the cache ready before the other entries land.
+func MarkHotKernels(c Cache, patterns []string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	mark := func(m *TritonCacheMetadata) {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, m.Name); ok && m.Dir != "" {
+				m.Hot = true
+				seen[m.Dir] = true
+				return
+			}
+		}
+	}
+	switch t := c.(type) {
+	case *TritonCache:
+		for i := range t.allMetadata {
+			mark(&t.allMetadata[i])
+		}
+	case *VLLMCache:
+		for _, v := range t.allMetadata {
+			for i, e := range v.TritonCacheEntries {
+				if m, ok := e.(TritonCacheMetadata); ok {
+					mark(&m)
+					v.TritonCacheEntries[i] = m
+				}
+			}
+		}
+	}
+
+	dirs := make([]string, 0, len(seen))
+	for d := range seen {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	logging.Infof("Marked %d %s cache entries as hot", len(dirs), c.Name())
+	return dirs
+}
+
+// hotEntry holds the fields of manifest entries hot kernel tracking
+// reads: those of Triton entries, and the Triton entries of vLLM ones.
+type hotEntry struct {
+	Dir    string     `json:"dir"`
+	Hot    bool       `json:"hot"`
+	Triton []hotEntry `json:"triton"`
+}
+
+// hotTracker follows the extraction of the hot entries of a layer, which
+// come right after its manifest, and calls ready with their directories
+// once the first other entry is reached. Layers written by other builders,
+// with the manifest after the cache or no hot entries, never call it.
+type hotTracker struct {
+	ready    func(dirs []string)
+	cacheDir string
+	dirs     []string // hot entry directories, relative to cacheDir
+	done     bool
+}
+
+func newHotTracker(cacheDir string, ready func(dirs []string)) *hotTracker {
+	if ready == nil {
+		return nil
+	}
+	return &hotTracker{ready: ready, cacheDir: cacheDir}
+}
+
+// loadManifest reads the hot entries from the manifest extracted to path,
+// unless cache files came first.
+func (h *hotTracker) loadManifest(manifestPath string) {
+	if h == nil || h.done {
+		return
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return
+	}
+	var manifest map[string][]hotEntry
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		logging.Debugf("Not tracking hot kernels, unreadable manifest: %v", err)
+		return
+	}
+	var collect func(entries []hotEntry)
+	collect = func(entries []hotEntry) {
+		for _, e := range entries {
+			if e.Hot && e.Dir != "" {
+				h.dirs = append(h.dirs, e.Dir)
+			}
+			collect(e.Triton)
+		}
+	}
+	for _, entries := range manifest {
+		collect(entries)
+	}
+}
+
+// reach notes that the cache file rel, relative to the cache directory, is
+// next, and reports the hot entries ready if it is not one of them.
+func (h *hotTracker) reach(rel string) {
+	if h == nil || h.done {
+		return
+	}
+	if len(h.dirs) == 0 {
+		// Without a manifest ahead of the cache there is nothing to track.
+		h.done = true
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	for _, d := range h.dirs {
+		if strings.HasPrefix(rel, d+"/") {
+			return
+		}
+	}
+	h.done = true
+
+	dirs := make([]string, 0, len(h.dirs))
+	for _, d := range h.dirs {
+		dir := filepath.Join(h.cacheDir, filepath.FromSlash(d))
+		if err := fixupCacheDir(dir, h.cacheDir); err != nil {
+			diag.Warnf(diag.ReadyFileFailed, "Not reporting the hot kernels ready: %v", err)
+			return
+		}
+		dirs = append(dirs, dir)
+	}
+	h.ready(dirs)
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/benchgen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkHotKernels(t *testing.T) {
+	root := t.TempDir()
+	_, err := benchgen.Generate(root, benchgen.Options{Kernels: 3, BinarySize: 64, Backend: "cuda", Arch: "90", Seed: 1})
+	assert.NoError(t, err)
+	tc := DetectTritonCache(root)
+	if !assert.NotNil(t, tc) {
+		return
+	}
+
+	assert.Error(t, ValidateHotKernels([]string{"[attn"}))
+	assert.NoError(t, ValidateHotKernels([]string{"*attn*", "kernel_1"}))
+	assert.Empty(t, MarkHotKernels(tc, nil))
+
+	dirs := MarkHotKernels(tc, []string{"kernel_1", "*attn*"})
+	if !assert.Len(t, dirs, 1) {
+		return
+	}
+	assert.FileExists(t, filepath.Join(root, dirs[0], "kernel_1.json"))
+	for _, e := range tc.Metadata() {
+		m := e.(TritonCacheMetadata)
+		assert.Equal(t, m.Name == "kernel_1", m.Hot, m.Name)
+		assert.NotEmpty(t, m.Dir)
+	}
+}
+
+// orderedArchive returns a layer holding files in the order given.
+func orderedArchive(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1])), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(f[1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestExtractReportsHotKernels(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "cache")
+	manifest, err := json.Marshal(Manifest{"triton": {
+		TritonCacheMetadata{Hash: "a", Name: "_attn_fwd", Dir: "HOT", Hot: true},
+		TritonCacheMetadata{Hash: "b", Name: "_cold", Dir: "COLD"},
+	}})
+	assert.NoError(t, err)
+
+	extract := func(layer []byte) int {
+		calls := 0
+		hot := newHotTracker(cacheDir, func(dirs []string) {
+			calls++
+			assert.Equal(t, []string{filepath.Join(cacheDir, "HOT")}, dirs)
+			assert.FileExists(t, filepath.Join(cacheDir, "HOT", "_attn_fwd.cubin"))
+			assert.NoFileExists(t, filepath.Join(cacheDir, "COLD", "_cold.cubin"))
+		})
+		_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(layer), "io.triton.cache/", "io.triton.manifest/",
+			cacheDir, filepath.Join(root, "manifest"), nil, "", false, nil, hot, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, os.RemoveAll(cacheDir))
+		return calls
+	}
+
+	// Written by mcv create: the manifest, the hot entries, the rest.
+	assert.Equal(t, 1, extract(orderedArchive(t,
+		[2]string{"io.triton.manifest/manifest.json", string(manifest)},
+		[2]string{"io.triton.cache/HOT/_attn_fwd.cubin", "hot"},
+		[2]string{"io.triton.cache/HOT/_attn_fwd.json", "{}"},
+		[2]string{"io.triton.cache/COLD/_cold.cubin", "cold"},
+	)))
+
+	// With the manifest last, the hot entries are not known in time.
+	assert.Equal(t, 0, extract(orderedArchive(t,
+		[2]string{"io.triton.cache/HOT/_attn_fwd.cubin", "hot"},
+		[2]string{"io.triton.cache/COLD/_cold.cubin", "cold"},
+		[2]string{"io.triton.manifest/manifest.json", string(manifest)},
+	)))
+}
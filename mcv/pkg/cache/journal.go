@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// JournalFileName is the extraction journal kept in the cache directory
+// while an extract is in progress.
+const JournalFileName = ".mcv-extract-journal"
+
+const journalHeader = "mcv-extract-journal "
+
+// extractJournal records which archive entries have been fully written so
+// an interrupted extract can resume instead of starting over. The first
+// line identifies the content being extracted (the layer digest); each
+// following line is the size and name of a completed entry, recorded once
+// its file is synced to disk.
+type extractJournal struct {
+	path string
+	done map[string]int64
+	f    *os.File
+}
+
+// openJournal opens the journal in dir for content id. With resume set, a
+// journal left behind for the same id is reused; otherwise it is discarded.
+func openJournal(dir, id string, resume bool) (*extractJournal, error) {
+	j := &extractJournal{path: filepath.Join(dir, JournalFileName), done: map[string]int64{}}
+
+	if resume {
+		if err := j.load(id); err != nil {
+			diag.Warnf(diag.JournalIgnored, "Ignoring extraction journal %s: %v", j.path, err)
+			j.done = map[string]int64{}
+		} else if len(j.done) > 0 {
+			logging.Infof("Resuming extraction: %d entries already extracted", len(j.done))
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if len(j.done) == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(j.path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extraction journal: %w", err)
+	}
+	j.f = f
+	if len(j.done) == 0 {
+		if _, err := fmt.Fprintf(f, "%s%s\n", journalHeader, id); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write extraction journal: %w", err)
+		}
+	}
+	return j, nil
+}
+
+func (j *extractJournal) load(id string) error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || scanner.Text() != journalHeader+id {
+		return fmt.Errorf("journal belongs to different content")
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		size, name, ok := strings.Cut(line, " ")
+		n, err := strconv.ParseInt(size, 10, 64)
+		if !ok || err != nil || n < 0 {
+			return fmt.Errorf("invalid journal entry %q", line)
+		}
+		j.done[name] = n
+	}
+	return scanner.Err()
+}
+
+// Done reports whether entry was completed by a previous run and its
+// output file still has the size it was written with.
+func (j *extractJournal) Done(entry, filePath string, size int64) bool {
+	if j == nil {
+		return false
+	}
+	recorded, ok := j.done[entry]
+	if !ok || recorded != size {
+		return false
+	}
+	st, err := os.Stat(filePath)
+	return err == nil && st.Mode().IsRegular() && st.Size() == size
+}
+
+// Record marks entry, of size bytes, as completed. Its file must be synced
+// to disk already, so that a crash cannot leave a recorded entry empty.
+func (j *extractJournal) Record(entry string, size int64) error {
+	if j == nil {
+		return nil
+	}
+	if strings.Contains(entry, "\n") {
+		return nil
+	}
+	_, err := fmt.Fprintf(j.f, "%d %s\n", size, entry)
+	return err
+}
+
+// Close closes the journal, removing it when the extraction succeeded.
+func (j *extractJournal) Close(success bool) {
+	if j == nil {
+		return
+	}
+	j.f.Close()
+	if success {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			diag.Warnf(diag.CleanupFailed, "Failed to remove extraction journal %s: %v", j.path, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cacheArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestExtractResumesFromJournal(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	manifestDir := filepath.Join(t.TempDir(), "manifest")
+	archive := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin": "a",
+		"io.triton.cache/BBB/b.cubin": "b",
+	})
+
+	// Simulate an earlier run that completed AAA/a.cubin before being interrupted.
+	assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "AAA"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, "AAA", "a.cubin"), []byte("A"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, JournalFileName),
+		[]byte(journalHeader+"sha256:layer\n1 io.triton.cache/AAA/a.cubin\n"), 0644))
+
+	_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "sha256:layer", true, nil, nil, nil)
+	assert.NoError(t, err)
+
+	a, _ := os.ReadFile(filepath.Join(cacheDir, "AAA", "a.cubin"))
+	b, _ := os.ReadFile(filepath.Join(cacheDir, "BBB", "b.cubin"))
+	assert.Equal(t, "A", string(a))
+	assert.Equal(t, "b", string(b))
+	assert.NoFileExists(t, filepath.Join(cacheDir, JournalFileName))
+
+	// A journal for different content is ignored and everything is rewritten.
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, JournalFileName),
+		[]byte(journalHeader+"sha256:other\nio.triton.cache/AAA/a.cubin\n"), 0644))
+	_, err = extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "sha256:layer", true, nil, nil, nil)
+	assert.NoError(t, err)
+	a, _ = os.ReadFile(filepath.Join(cacheDir, "AAA", "a.cubin"))
+	assert.Equal(t, "a", string(a))
+}
+
+func TestExtractRewritesIncompleteJournaledEntries(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	manifestDir := filepath.Join(t.TempDir(), "manifest")
+	archive := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin": "aaaa",
+	})
+	for _, journal := range []string{
+		// Recorded, but the file lost its content in a crash.
+		"4 io.triton.cache/AAA/a.cubin\n",
+		// Recorded with another size.
+		"2 io.triton.cache/AAA/a.cubin\n",
+		// Written by a release that did not record sizes.
+		"io.triton.cache/AAA/a.cubin\n",
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "AAA"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, "AAA", "a.cubin"), nil, 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, JournalFileName),
+			[]byte(journalHeader+"sha256:layer\n"+journal), 0644))
+
+		_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+			cacheDir, manifestDir, nil, "sha256:layer", true, nil, nil, nil)
+		assert.NoError(t, err)
+		a, _ := os.ReadFile(filepath.Join(cacheDir, "AAA", "a.cubin"))
+		assert.Equal(t, "aaaa", string(a), journal)
+	}
+}
+
+// cancelAfter is a context that is canceled once Err has been called n
+// times.
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestExtractCanceledKeepsJournal(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	manifestDir := filepath.Join(t.TempDir(), "manifest")
+	archive := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin": "a",
+		"io.triton.cache/BBB/b.cubin": "b",
+	})
+
+	ctx := &cancelAfter{Context: context.Background(), n: 1}
+	_, err := extractCacheAndManifestDirectory(ctx, bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "sha256:layer", true, nil, nil, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	journal, err := os.ReadFile(filepath.Join(cacheDir, JournalFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(journal), "1 io.triton.cache/")
+
+	_, err = extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "sha256:layer", true, nil, nil, nil)
+	assert.NoError(t, err)
+	a, _ := os.ReadFile(filepath.Join(cacheDir, "AAA", "a.cubin"))
+	b, _ := os.ReadFile(filepath.Join(cacheDir, "BBB", "b.cubin"))
+	assert.Equal(t, "a", string(a))
+	assert.Equal(t, "b", string(b))
+	assert.NoFileExists(t, filepath.Join(cacheDir, JournalFileName))
+}
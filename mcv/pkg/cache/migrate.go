@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+// Triton cache layout versions understood by MigrateTritonCache.
+const (
+	LayoutV2 = "2.x"
+	LayoutV3 = "3.x"
+)
+
+// MigrationIssue records a kernel that could not be migrated mechanically
+// and has to be recompiled with the target Triton version.
+type MigrationIssue struct {
+	Path   string
+	Reason string
+}
+
+// MigrationReport summarizes the result of a cache migration.
+type MigrationReport struct {
+	Migrated       []string
+	Unchanged      []string
+	GroupsCreated  []string
+	NeedsRecompile []MigrationIssue
+}
+
+// NormalizeLayoutVersion maps user input such as "2", "2.3" or "2.x" to a
+// supported layout version.
+func NormalizeLayoutVersion(v string) (string, error) {
+	major, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(v), "v"), ".")
+	switch major {
+	case "2":
+		return LayoutV2, nil
+	case "3":
+		return LayoutV3, nil
+	default:
+		return "", fmt.Errorf("unsupported cache layout version: %q", v)
+	}
+}
+
+// MigrateTritonCache rewrites a Triton cache directory from one layout
+// version to another. Kernels whose metadata cannot be converted are left
+// untouched and reported in NeedsRecompile. With dryRun set nothing is
+// written to disk.
+func MigrateTritonCache(cacheDir, from, to string, dryRun bool) (*MigrationReport, error) {
+	from, err := NormalizeLayoutVersion(from)
+	if err != nil {
+		return nil, err
+	}
+	to, err = NormalizeLayoutVersion(to)
+	if err != nil {
+		return nil, err
+	}
+	if from != LayoutV2 || to != LayoutV3 {
+		return nil, fmt.Errorf("migration from %s to %s is not supported", from, to)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory %s: %w", cacheDir, err)
+	}
+
+	report := &MigrationReport{}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := migrateKernelDir(filepath.Join(cacheDir, e.Name()), dryRun, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// migrateKernelDir converts the metadata files in a single cache entry and
+// makes sure the entry has a group file.
+func migrateKernelDir(dir string, dryRun bool, report *MigrationReport) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read kernel directory %s: %w", dir, err)
+	}
+
+	hasGroup := false
+	var names []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		names = append(names, f.Name())
+		if strings.HasPrefix(f.Name(), "__grp__") && strings.HasSuffix(f.Name(), ".json") {
+			hasGroup = true
+		}
+	}
+
+	kernelName := ""
+	for _, n := range names {
+		if filepath.Ext(n) != ".json" || strings.HasPrefix(n, "__grp__") {
+			continue
+		}
+		path := filepath.Join(dir, n)
+		migrated, name, issue, err := migrateKernelMetadata(path, dryRun)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		kernelName = name
+
+		if issue == "" && !hasBinary(dir, name) {
+			issue = "no compiled kernel binary found"
+		}
+		switch {
+		case issue != "":
+			report.NeedsRecompile = append(report.NeedsRecompile, MigrationIssue{Path: path, Reason: issue})
+		case migrated:
+			report.Migrated = append(report.Migrated, path)
+		default:
+			report.Unchanged = append(report.Unchanged, path)
+		}
+	}
+
+	if kernelName == "" || hasGroup {
+		return nil
+	}
+
+	grpPath := filepath.Join(dir, "__grp__"+kernelName+".json")
+	report.GroupsCreated = append(report.GroupsCreated, grpPath)
+	if dryRun {
+		return nil
+	}
+	return writeGroupFile(grpPath, dir, names)
+}
+
+// migrateKernelMetadata converts one kernel metadata file in place. It
+// returns the kernel name (empty if the file is not kernel metadata) and a
+// reason when the kernel must be recompiled instead.
+func migrateKernelMetadata(path string, dryRun bool) (migrated bool, name, issue string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal(data, &meta); err != nil {
+		logging.Debugf("Skipping non-metadata JSON %s: %v", path, err)
+		return false, "", "", nil
+	}
+	name, _ = meta["name"].(string)
+	if name == "" {
+		return false, "", "", nil
+	}
+
+	// 3.x metadata already carries the target as an object.
+	if t, ok := meta["target"].(map[string]any); ok && t["backend"] != nil {
+		return false, name, "", nil
+	}
+
+	target, ok := legacyTarget(meta)
+	if !ok {
+		return false, name, "cannot determine compilation target from metadata", nil
+	}
+
+	meta["target"] = target
+	if _, ok := meta["backend_name"]; !ok {
+		meta["backend_name"] = target.Backend
+	}
+	if h, _ := meta["hash"].(string); h == "" {
+		// Pre-3.x metadata has no kernel hash; use the cache key so the
+		// entry can still be indexed.
+		meta["hash"] = filepath.Base(filepath.Dir(path))
+	}
+	for _, k := range []string{"device_type", "cc", "capability"} {
+		delete(meta, k)
+	}
+
+	if dryRun {
+		return true, name, "", nil
+	}
+	out, err := json.Marshal(meta)
+	if err != nil {
+		return false, name, "", fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return false, name, "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, name, "", nil
+}
+
+// legacyTarget derives a 3.x target from 2.x metadata, which stored it as a
+// [backend, arch] pair, a bare arch, or separate device_type/cc fields.
+func legacyTarget(meta map[string]any) (Target, bool) {
+	var t Target
+	switch v := meta["target"].(type) {
+	case []any:
+		if len(v) > 0 {
+			t.Backend, _ = v[0].(string)
+		}
+		if len(v) > 1 {
+			t.Arch = v[1]
+		}
+		if len(v) > 2 {
+			if w, ok := v[2].(float64); ok {
+				t.WarpSize = int(w)
+			}
+		}
+	case string, float64:
+		t.Arch = v
+	}
+
+	if t.Backend == "" {
+		for _, k := range []string{"backend_name", "device_type"} {
+			if s, ok := meta[k].(string); ok && s != "" {
+				t.Backend = s
+				break
+			}
+		}
+	}
+	if t.Arch == nil {
+		for _, k := range []string{"cc", "capability", "arch"} {
+			if a, ok := meta[k]; ok && a != nil {
+				t.Arch = a
+				break
+			}
+		}
+	}
+	if t.WarpSize == 0 {
+		if w, ok := meta["warp_size"].(float64); ok {
+			t.WarpSize = int(w)
+		}
+	}
+
+	if t.Backend == "" || t.Arch == nil || ConvertArchToString(t.Arch) == "" {
+		return t, false
+	}
+	if t.WarpSize == 0 {
+		switch t.Backend {
+		case "hip":
+			t.WarpSize = 64
+		default:
+			t.WarpSize = 32
+		}
+	}
+	return t, true
+}
+
+func hasBinary(dir, name string) bool {
+	for _, ext := range []string{".cubin", ".hsaco"} {
+		if _, err := os.Stat(filepath.Join(dir, name+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeGroupFile creates the __grp__ file Triton 3.x uses to look up all
+// artifacts of a cache entry.
+func writeGroupFile(grpPath, dir string, names []string) error {
+	children := make(map[string]string, len(names))
+	for _, n := range names {
+		children[n] = filepath.Join(dir, n)
+	}
+	out, err := json.Marshal(map[string]map[string]string{"child_paths": children})
+	if err != nil {
+		return fmt.Errorf("failed to marshal group file: %w", err)
+	}
+	if err := os.WriteFile(grpPath, out, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", grpPath, err)
+	}
+	return nil
+}
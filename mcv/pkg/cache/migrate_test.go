@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKernel(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func TestNormalizeLayoutVersion(t *testing.T) {
+	for in, want := range map[string]string{"2": LayoutV2, "2.3": LayoutV2, "v3.x": LayoutV3} {
+		got, err := NormalizeLayoutVersion(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := NormalizeLayoutVersion("1.0")
+	assert.Error(t, err)
+}
+
+func TestMigrateTritonCache(t *testing.T) {
+	root := t.TempDir()
+	legacy := filepath.Join(root, "0123456789abcdef0123456789abcdef")
+	writeKernel(t, legacy, map[string]string{
+		"add_kernel.json":  `{"name": "add_kernel", "num_warps": 4, "target": ["cuda", 80]}`,
+		"add_kernel.cubin": "bin",
+	})
+	noTarget := filepath.Join(root, "fedcba9876543210fedcba9876543210")
+	writeKernel(t, noTarget, map[string]string{
+		"mul_kernel.json":  `{"name": "mul_kernel", "num_warps": 4}`,
+		"mul_kernel.cubin": "bin",
+	})
+	current := filepath.Join(root, "CURRENT")
+	writeKernel(t, current, map[string]string{
+		"k.json":        `{"hash": "abc", "name": "k", "target": {"backend": "hip", "arch": "gfx90a", "warp_size": 64}}`,
+		"k.hsaco":       "bin",
+		"__grp__k.json": `{"child_paths": {}}`,
+	})
+
+	_, err := MigrateTritonCache(root, "3.x", "2.x", false)
+	assert.Error(t, err)
+
+	report, err := MigrateTritonCache(root, "2.x", "3.x", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(legacy, "add_kernel.json")}, report.Migrated)
+	assert.Equal(t, []string{filepath.Join(current, "k.json")}, report.Unchanged)
+	assert.Len(t, report.NeedsRecompile, 1)
+	assert.Equal(t, filepath.Join(noTarget, "mul_kernel.json"), report.NeedsRecompile[0].Path)
+	assert.Len(t, report.GroupsCreated, 2)
+
+	data, err := GetTritonCacheJSONData(filepath.Join(legacy, "add_kernel.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Base(legacy), data.Hash)
+	assert.Equal(t, "cuda", data.Target.Backend)
+	assert.Equal(t, "80", ConvertArchToString(data.Target.Arch))
+	assert.Equal(t, 32, data.Target.WarpSize)
+
+	raw, err := os.ReadFile(filepath.Join(legacy, "__grp__add_kernel.json"))
+	assert.NoError(t, err)
+	var grp map[string]map[string]string
+	assert.NoError(t, json.Unmarshal(raw, &grp))
+	assert.Equal(t, filepath.Join(legacy, "add_kernel.cubin"), grp["child_paths"]["add_kernel.cubin"])
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+)
+
+// OCI whiteout markers: ".wh.<name>" deletes <name> from the lower layers
+// and ".wh..wh..opq" hides everything the lower layers put in its
+// directory.
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// layerApplier applies a stack of layers in order with OCI whiteout
+// semantics. It remembers which layer wrote each path so whiteouts only
+// remove content extracted from lower layers, never files that were on the
+// host before the extract. It writes through store.
+type layerApplier struct {
+	layer   int
+	written map[string]int
+	store   CacheStore
+}
+
+// newLayerApplier returns an applier writing through store, or to the
+// host's filesystem if store is nil.
+func newLayerApplier(store CacheStore) *layerApplier {
+	if store == nil {
+		store = PosixDirStore{}
+	}
+	return &layerApplier{written: map[string]int{}, store: store}
+}
+
+// nextLayer starts applying the next layer up the stack.
+func (a *layerApplier) nextLayer() {
+	a.layer++
+}
+
+// record notes that the current layer wrote filePath. Extracted parent
+// directories are claimed by the current layer too, so a whiteout in the
+// same layer does not remove them with the new entry inside.
+func (a *layerApplier) record(filePath string) {
+	p := filepath.Clean(filePath)
+	a.written[p] = a.layer
+	for d := filepath.Dir(p); d != filepath.Dir(d); d = filepath.Dir(d) {
+		if _, ok := a.written[d]; ok {
+			a.written[d] = a.layer
+		}
+	}
+}
+
+// mkdirAll creates dir and its parents, recording the directories it had
+// to create as written by the current layer.
+func (a *layerApplier) mkdirAll(dir string) error {
+	var missing []string
+	for d := filepath.Clean(dir); d != filepath.Dir(d); d = filepath.Dir(d) {
+		if _, err := a.store.Lstat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := a.store.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		a.written[d] = a.layer
+	}
+	return nil
+}
+
+// replace clears the way for an entry at filePath when a lower layer put a
+// directory where this layer has a file, or the reverse.
+func (a *layerApplier) replace(filePath string, isDir bool) error {
+	if layer, ok := a.written[filepath.Clean(filePath)]; !ok || layer >= a.layer {
+		return nil
+	}
+	info, err := a.store.Lstat(filePath)
+	if err != nil || info.IsDir() == isDir {
+		return nil
+	}
+	if err := a.removeLower(filePath, true); err != nil {
+		return err
+	}
+	return a.store.RemoveAll(filePath)
+}
+
+func isWhiteout(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), whiteoutPrefix)
+}
+
+// whiteout applies filePath if it is a whiteout marker and reports whether
+// it was one. Markers are never extracted as files.
+func (a *layerApplier) whiteout(filePath string) (bool, error) {
+	if !isWhiteout(filePath) {
+		return false, nil
+	}
+	base := filepath.Base(filePath)
+	dir := filepath.Dir(filePath)
+	if base == opaqueWhiteout {
+		return true, a.removeLower(dir, false)
+	}
+	return true, a.removeLower(filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), true)
+}
+
+// removeLower removes the paths below target written by lower layers, and
+// target itself if self is set.
+func (a *layerApplier) removeLower(target string, self bool) error {
+	prefix := target + string(os.PathSeparator)
+	for p, layer := range a.written {
+		if layer >= a.layer || !(strings.HasPrefix(p, prefix) || (self && p == target)) {
+			continue
+		}
+		if err := a.store.RemoveAll(p); err != nil {
+			return fmt.Errorf("failed to apply whiteout for %s: %w", p, err)
+		}
+		delete(a.written, p)
+	}
+	return nil
+}
+
+// LayerExtractor extracts a cache from the layers of an image, applied
+// from the lowest to the topmost, so later layers override or delete
+// (through whiteouts) the files of earlier ones as a container runtime
+// would.
+type LayerExtractor struct {
+	ctx       context.Context
+	cacheType string
+	resume    bool
+	applier   *layerApplier
+	dirs      []string
+	hotReady  func(dirs []string)
+	skipped   *skippedEntries
+}
+
+// NewLayerExtractor returns an extractor for cacheType. With resume set,
+// each layer resumes from its extraction journal.
+func NewLayerExtractor(cacheType string, resume bool) (*LayerExtractor, error) {
+	switch cacheType {
+	case constants.Triton, constants.VLLM:
+	case "":
+		return nil, fmt.Errorf("cache type is empty")
+	default:
+		return nil, fmt.Errorf("unsupported cache type: %s", cacheType)
+	}
+	return &LayerExtractor{ctx: context.Background(), cacheType: cacheType, resume: resume, applier: newLayerApplier(nil)}, nil
+}
+
+// Apply extracts the next layer up the stack from r, journaling progress
+// under journalID.
+func (e *LayerExtractor) Apply(r io.Reader, journalID string) error {
+	var dirs []string
+	var err error
+	switch e.cacheType {
+	case constants.Triton:
+		dirs, err = extractCacheAndManifestDirectory(e.ctx, r, constants.MCVTritonCacheDir, "io.triton.manifest/",
+			constants.ExtractCacheDir, constants.ExtractManifestDir, tritonArtifactDirs(), journalID, e.resume, e.applier,
+			newHotTracker(constants.ExtractCacheDir, e.hotReady), e.skipped)
+	case constants.VLLM:
+		dirs, err = extractCacheAndManifestDirectory(e.ctx, r, constants.MCVVLLMCacheDir, "io.vllm.manifest/",
+			constants.ExtractCacheDir, constants.ExtractManifestDir, nil, journalID, e.resume, e.applier,
+			newHotTracker(constants.ExtractCacheDir, e.hotReady), e.skipped)
+	}
+	e.applier.nextLayer()
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if !stringInSlice(d, e.dirs) {
+			e.dirs = append(e.dirs, d)
+		}
+	}
+	return nil
+}
+
+// OnHotKernels makes the extractor call ready with the directories of the
+// hot entries of a layer, marked by mcv create --hot-kernels, once they are
+// extracted and while the other entries still are. Only set it when the
+// layer is the last one applied, as later layers may change hot entries.
+func (e *LayerExtractor) OnHotKernels(ready func(dirs []string)) {
+	e.hotReady = ready
+}
+
+// SetStore makes the extractor write through store instead of to the
+// host's filesystem.
+func (e *LayerExtractor) SetStore(store CacheStore) {
+	e.applier.store = store
+}
+
+// SetContext makes the extractor stop between entries once ctx is done,
+// keeping the journal of the layer it stopped in.
+func (e *LayerExtractor) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// SkipBadEntries makes the extractor skip the entries it cannot extract,
+// and the rest of a layer that cannot be read, instead of failing.
+// Skipped returns them.
+func (e *LayerExtractor) SkipBadEntries() {
+	e.skipped = &skippedEntries{}
+}
+
+// Skipped returns the entries skipped in all layers applied so far.
+func (e *LayerExtractor) Skipped() []SkippedEntry {
+	if e.skipped == nil {
+		return nil
+	}
+	return e.skipped.entries
+}
+
+// Dirs returns the cache directories extracted from all layers applied so
+// far.
+func (e *LayerExtractor) Dirs() []string {
+	return e.dirs
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractAppliesLayersWithWhiteouts(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "cache")
+	manifestDir := filepath.Join(root, "manifest")
+
+	// A file that was on the host before the extract must survive an opaque
+	// whiteout of the cache root.
+	assert.NoError(t, os.MkdirAll(cacheDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, "host.json"), []byte("host"), 0644))
+
+	base := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin":      "old",
+		"io.triton.cache/AAA/b.json":       "b",
+		"io.triton.cache/BBB/c.cubin":      "c",
+		"io.triton.cache/CCC/d.cubin":      "d",
+		"io.triton.manifest/manifest.json": "{}",
+	})
+	delta := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin":      "new",
+		"io.triton.cache/AAA/.wh.b.json":   "",
+		"io.triton.cache/BBB/.wh..wh..opq": "",
+		"io.triton.cache/BBB/e.cubin":      "e",
+		"io.triton.cache/.wh.CCC":          "",
+	})
+
+	overlay := newLayerApplier(nil)
+	for i, layer := range [][]byte{base, delta} {
+		_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(layer), "io.triton.cache/", "io.triton.manifest/",
+			cacheDir, manifestDir, nil, "", false, overlay, nil, nil)
+		assert.NoError(t, err, "layer %d", i)
+		overlay.nextLayer()
+	}
+
+	data, err := os.ReadFile(filepath.Join(cacheDir, "AAA", "a.cubin"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "AAA", "b.json"))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "AAA", ".wh.b.json"))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "BBB", "c.cubin"))
+	assert.FileExists(t, filepath.Join(cacheDir, "BBB", "e.cubin"))
+	assert.NoDirExists(t, filepath.Join(cacheDir, "CCC"))
+	assert.FileExists(t, filepath.Join(cacheDir, "host.json"))
+
+	// An opaque whiteout of the root only hides what lower layers wrote.
+	opaque := cacheArchive(t, map[string]string{"io.triton.cache/.wh..wh..opq": ""})
+	overlay.nextLayer()
+	_, err = extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(opaque), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "", false, overlay, nil, nil)
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(cacheDir, "AAA", "a.cubin"))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "BBB", "e.cubin"))
+	assert.FileExists(t, filepath.Join(cacheDir, "host.json"))
+}
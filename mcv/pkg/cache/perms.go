@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Permissions normalizes the ownership and modes of an extracted cache, so
+// a cache shared by containers running as different users stays readable,
+// and unchanged if the modes deny writes.
+type Permissions struct {
+	FileMode os.FileMode // Mode of regular files, 0 keeps the extracted mode
+	DirMode  os.FileMode // Mode of directories, 0 keeps the extracted mode
+	UID      int         // Owner, -1 keeps the current owner
+	GID      int         // Group, -1 keeps the current group
+}
+
+// ParsePermissions parses octal file and directory modes, such as "0444",
+// and an owner given as "user", "user:group" or ":group", by name or ID.
+// Empty values keep what extraction wrote.
+func ParsePermissions(fileMode, dirMode, owner string) (Permissions, error) {
+	p := Permissions{UID: -1, GID: -1}
+	var err error
+	if p.FileMode, err = parseMode(fileMode); err != nil {
+		return p, fmt.Errorf("invalid file mode: %w", err)
+	}
+	if p.DirMode, err = parseMode(dirMode); err != nil {
+		return p, fmt.Errorf("invalid directory mode: %w", err)
+	}
+	if owner == "" {
+		return p, nil
+	}
+	u, g, _ := strings.Cut(owner, ":")
+	if u != "" {
+		if p.UID, err = lookupID(u, func(name string) (string, error) {
+			usr, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return usr.Uid, nil
+		}); err != nil {
+			return p, fmt.Errorf("invalid owner %q: %w", owner, err)
+		}
+	}
+	if g != "" {
+		if p.GID, err = lookupID(g, func(name string) (string, error) {
+			grp, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return grp.Gid, nil
+		}); err != nil {
+			return p, fmt.Errorf("invalid owner %q: %w", owner, err)
+		}
+	}
+	return p, nil
+}
+
+func parseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m == 0 || m > 0o7777 {
+		return 0, fmt.Errorf("%q is not an octal mode such as 0444", s)
+	}
+	return os.FileMode(m&0o777) | modeBits(m), nil
+}
+
+// modeBits converts the setuid, setgid and sticky bits of an octal mode.
+func modeBits(m uint64) os.FileMode {
+	var mode os.FileMode
+	if m&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+func lookupID(s string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil && id >= 0 {
+		return id, nil
+	}
+	id, err := lookup(s)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
+
+// IsZero reports whether p leaves the extracted cache as is.
+func (p Permissions) IsZero() bool {
+	return p.FileMode == 0 && p.DirMode == 0 && !p.HasOwner()
+}
+
+// HasOwner reports whether p sets the owner or group.
+func (p Permissions) HasOwner() bool {
+	return p.UID >= 0 || p.GID >= 0
+}
+
+// ApplyEntries normalizes the extracted entries, kernel directories given
+// by path, as Apply does. The other files of the cache directory and the
+// directory itself are left alone, so kernels already there keep their
+// owner and modes, and modes denying writes do not keep the runtime or the
+// next extract from adding kernels. Entries no longer there, e.g. pruned,
+// are skipped.
+func (p Permissions) ApplyEntries(entries []string) error {
+	if p.IsZero() {
+		return nil
+	}
+	for _, e := range entries {
+		if _, err := os.Lstat(e); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := p.Apply(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply normalizes every file and directory under root, root included. Directories are
+// changed last, so modes denying writes or access do not stop the walk.
+// Symlinks are given to the owner but keep their mode.
+func (p Permissions) Apply(root string) error {
+	if p.IsZero() {
+		return nil
+	}
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p.HasOwner() {
+			if err := os.Lchown(path, p.UID, p.GID); err != nil {
+				return err
+			}
+		}
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, path)
+		case d.Type().IsRegular() && p.FileMode != 0:
+			return os.Chmod(path, p.FileMode)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to normalize permissions under %s: %w", root, err)
+	}
+	if p.DirMode == 0 {
+		return nil
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], p.DirMode); err != nil {
+			return fmt.Errorf("failed to normalize permissions under %s: %w", root, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePermissions(t *testing.T) {
+	p, err := ParsePermissions("", "", "")
+	assert.NoError(t, err)
+	assert.True(t, p.IsZero())
+
+	p, err = ParsePermissions("0444", "555", "1000:2000")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0444), p.FileMode)
+	assert.Equal(t, os.FileMode(0555), p.DirMode)
+	assert.Equal(t, 1000, p.UID)
+	assert.Equal(t, 2000, p.GID)
+
+	p, err = ParsePermissions("", "", ":0")
+	assert.NoError(t, err)
+	assert.Equal(t, -1, p.UID)
+	assert.Equal(t, 0, p.GID)
+
+	p, err = ParsePermissions("", "2775", "root")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0775)|os.ModeSetgid, p.DirMode)
+	assert.Equal(t, 0, p.UID)
+	assert.Equal(t, -1, p.GID)
+
+	for _, mode := range []string{"rw", "0", "0999", "17777"} {
+		_, err = ParsePermissions(mode, "", "")
+		assert.Error(t, err, mode)
+	}
+	_, err = ParsePermissions("", "", "no-such-user-mcv")
+	assert.Error(t, err)
+}
+
+func TestPermissionsApply(t *testing.T) {
+	root := t.TempDir()
+	kernel := filepath.Join(root, "AAA")
+	assert.NoError(t, os.MkdirAll(kernel, 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(kernel, "a.cubin"), []byte("a"), 0600))
+	assert.NoError(t, os.Symlink("a.cubin", filepath.Join(kernel, "link")))
+
+	p, err := ParsePermissions("0444", "0555", "")
+	assert.NoError(t, err)
+	assert.NoError(t, p.Apply(root))
+	t.Cleanup(func() {
+		// Let t.TempDir remove it.
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				_ = os.Chmod(path, 0755)
+			}
+			return nil
+		})
+	})
+
+	for path, want := range map[string]os.FileMode{
+		root:                             os.ModeDir | 0555,
+		kernel:                           os.ModeDir | 0555,
+		filepath.Join(kernel, "a.cubin"): 0444,
+	} {
+		st, err := os.Lstat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, st.Mode(), path)
+	}
+	st, err := os.Lstat(filepath.Join(kernel, "link"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, st.Mode().Type())
+}
+
+func TestPermissionsApplyEntries(t *testing.T) {
+	root := t.TempDir()
+	extracted := filepath.Join(root, "AAA")
+	existing := filepath.Join(root, "BBB")
+	for _, d := range []string{extracted, existing} {
+		assert.NoError(t, os.MkdirAll(d, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(d, "a.cubin"), []byte("a"), 0644))
+	}
+	rootSt, err := os.Stat(root)
+	assert.NoError(t, err)
+
+	p, err := ParsePermissions("0444", "0555", "")
+	assert.NoError(t, err)
+	assert.NoError(t, p.ApplyEntries([]string{extracted, filepath.Join(root, "pruned")}))
+	t.Cleanup(func() { _ = os.Chmod(extracted, 0755) })
+
+	for path, want := range map[string]os.FileMode{
+		root:                                rootSt.Mode(),
+		extracted:                           os.ModeDir | 0555,
+		filepath.Join(extracted, "a.cubin"): 0444,
+		existing:                            os.ModeDir | 0755,
+		filepath.Join(existing, "a.cubin"):  0644,
+	} {
+		st, err := os.Lstat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, st.Mode(), path)
+	}
+}
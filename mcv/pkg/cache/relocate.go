@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+)
+
+// CacheRootPlaceholder stands in for the cache directory in paths embedded
+// in packaged metadata. It is replaced with the extraction directory when
+// the cache is extracted, so images do not depend on the build host layout.
+const CacheRootPlaceholder = "@MCV_CACHE_ROOT@"
+
+// relocatableExts are the metadata file types that embed cache paths:
+// Triton's __grp__ JSON files and inductor/vLLM JSON and Python sources.
+var relocatableExts = map[string]bool{".json": true, ".py": true}
+
+// IsRelocatable reports whether path is a metadata file that may embed
+// cache paths and so be rewritten when packaged or extracted.
+func IsRelocatable(path string) bool {
+	return relocatableExts[filepath.Ext(path)]
+}
+
+func isGroupFile(name string) bool {
+	return strings.HasPrefix(name, "__grp__") && strings.HasSuffix(name, ".json")
+}
+
+// CanonicalizePaths rewrites absolute paths under any of roots in the
+// metadata files below dir to use CacheRootPlaceholder. Triton __grp__
+// child paths pointing at a kernel directory present in dir are rewritten
+// too, even when they were recorded under a different cache location. It
+// returns the number of files changed.
+func CanonicalizePaths(dir string, roots []string) (int, error) {
+	var clean []string
+	for _, r := range roots {
+		if r = filepath.Clean(r); filepath.IsAbs(r) && r != "/" {
+			clean = append(clean, r)
+		}
+	}
+
+	changed := 0
+	err := walkRelocatable(dir, func(path string, data []byte) ([]byte, error) {
+		out := data
+		if isGroupFile(filepath.Base(path)) {
+			var err error
+			if out, err = canonicalizeGroupFile(dir, out); err != nil {
+				diag.Warnf(diag.PathsNotRelocated, "Leaving paths in %s unchanged: %v", path, err)
+				out = data
+			}
+		}
+		for _, r := range clean {
+			// Only whole path components: the root itself or paths below it.
+			for _, sep := range []string{"/", `"`, "'"} {
+				out = bytes.ReplaceAll(out, []byte(r+sep), []byte(CacheRootPlaceholder+sep))
+			}
+		}
+		if !bytes.Equal(out, data) {
+			changed++
+		}
+		return out, nil
+	})
+	return changed, err
+}
+
+// canonicalizeGroupFile maps each child path whose kernel directory and
+// file exist in dir to a placeholder path.
+func canonicalizeGroupFile(dir string, data []byte) ([]byte, error) {
+	var parsed map[string]map[string]string
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+	children := parsed["child_paths"]
+	modified := false
+	for key, val := range children {
+		if strings.HasPrefix(val, CacheRootPlaceholder) {
+			continue
+		}
+		rel := filepath.Join(filepath.Base(filepath.Dir(val)), filepath.Base(val))
+		if _, err := os.Stat(filepath.Join(dir, rel)); err != nil {
+			continue
+		}
+		children[key] = CacheRootPlaceholder + "/" + filepath.ToSlash(rel)
+		modified = true
+	}
+	if !modified {
+		return data, nil
+	}
+	return json.MarshalIndent(parsed, "", "  ")
+}
+
+// ResolvePaths replaces CacheRootPlaceholder in the metadata files below
+// dir with root.
+func ResolvePaths(dir, root string) error {
+	return walkRelocatable(dir, func(_ string, data []byte) ([]byte, error) {
+		return bytes.ReplaceAll(data, []byte(CacheRootPlaceholder), []byte(filepath.Clean(root))), nil
+	})
+}
+
+// walkRelocatable calls fn with the content of every relocatable metadata
+// file below dir and writes back the result when it differs.
+func walkRelocatable(dir string, fn func(path string, data []byte) ([]byte, error)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !IsRelocatable(path) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out, err := fn(path, data)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(out, data) {
+			return nil
+		}
+		if err := os.WriteFile(path, out, info.Mode()); err != nil {
+			return fmt.Errorf("failed to rewrite paths in %s: %w", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeAndResolvePaths(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "ABC")
+	assert.NoError(t, os.MkdirAll(kernel, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(kernel, "add.cubin"), []byte("\x00"), 0644))
+
+	// The group file was written on another host with a custom cache dir.
+	grp := `{"child_paths": {"add.cubin": "/data/triton/ABC/add.cubin", "gone.json": "/data/triton/XYZ/gone.json"}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(kernel, "__grp__add.json"), []byte(grp), 0644))
+	inductor := `{"cache_dir": "/home/alice/.cache/vllm", "graph": "/home/alice/.cache/vllm/rank_0/graph.py", "other": "/home/alice/.cache/vllm2"}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(inductor), 0644))
+
+	n, err := CanonicalizePaths(dir, []string{"/home/alice/.cache/vllm"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	var parsed map[string]map[string]string
+	data, _ := os.ReadFile(filepath.Join(kernel, "__grp__add.json"))
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, CacheRootPlaceholder+"/ABC/add.cubin", parsed["child_paths"]["add.cubin"])
+	assert.Equal(t, "/data/triton/XYZ/gone.json", parsed["child_paths"]["gone.json"])
+
+	data, _ = os.ReadFile(filepath.Join(dir, "config.json"))
+	assert.Equal(t, `{"cache_dir": "@MCV_CACHE_ROOT@", "graph": "@MCV_CACHE_ROOT@/rank_0/graph.py", "other": "/home/alice/.cache/vllm2"}`, string(data))
+
+	assert.NoError(t, ResolvePaths(dir, "/mnt/cache/"))
+	data, _ = os.ReadFile(filepath.Join(dir, "config.json"))
+	assert.Equal(t, `{"cache_dir": "/mnt/cache", "graph": "/mnt/cache/rank_0/graph.py", "other": "/home/alice/.cache/vllm2"}`, string(data))
+	data, _ = os.ReadFile(filepath.Join(kernel, "__grp__add.json"))
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, "/mnt/cache/ABC/add.cubin", parsed["child_paths"]["add.cubin"])
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+)
+
+// What extraction does with an entry it cannot extract.
+const (
+	EntryErrorsFail = "fail" // Stop at the first bad entry
+	EntryErrorsSkip = "skip" // Skip bad entries, report them and extract the rest
+)
+
+// EntryErrorPolicies returns the accepted bad entry policies.
+func EntryErrorPolicies() []string {
+	return []string{EntryErrorsFail, EntryErrorsSkip}
+}
+
+// ValidateEntryErrorPolicy rejects an unknown bad entry policy.
+func ValidateEntryErrorPolicy(policy string) error {
+	if !slices.Contains(EntryErrorPolicies(), policy) {
+		return fmt.Errorf("invalid entry error policy %q: must be one of %s", policy, strings.Join(EntryErrorPolicies(), ", "))
+	}
+	return nil
+}
+
+// SkippedEntry is an entry of a cache layer that was not extracted.
+type SkippedEntry struct {
+	Name   string `json:"name"` // Path of the entry in the layer
+	Reason string `json:"reason"`
+}
+
+// skippedEntries records the entries skipped by an extraction that goes on
+// past bad entries. A nil *skippedEntries fails on the first instead.
+type skippedEntries struct {
+	entries []SkippedEntry
+}
+
+// skip records that the entry name was not extracted because of err, or
+// returns err when bad entries are not skipped.
+func (s *skippedEntries) skip(name string, err error) error {
+	if s == nil {
+		return err
+	}
+	diag.Warnf(diag.EntrySkipped, "Skipping %s: %v", name, err)
+	s.entries = append(s.entries, SkippedEntry{Name: name, Reason: err.Error()})
+	return nil
+}
+
+func (s *skippedEntries) count() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.entries)
+}
+
+// streamReader remembers the error reading a layer failed with, to tell an
+// unreadable layer, past which nothing can be extracted, from an entry
+// that could not be written.
+type streamReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// corrupt marks err as a corrupt cache when cause, the error reading a
+// layer failed with, shows that the layer does not decode or match its
+// digest rather than that it could not be read.
+func corrupt(cause, err error) error {
+	var flateErr flate.CorruptInputError
+	if errors.Is(cause, tar.ErrHeader) || errors.Is(cause, gzip.ErrHeader) || errors.Is(cause, gzip.ErrChecksum) ||
+		errors.Is(cause, zstd.ErrCRCMismatch) || errors.Is(cause, zstd.ErrMagicMismatch) || errors.As(cause, &flateErr) ||
+		// go-containerregistry's digest check has no exported error type.
+		strings.Contains(cause.Error(), "error verifying sha256 checksum") {
+		return failure.New(failure.CorruptCache, err)
+	}
+	return err
+}
+
+// withinDir reports whether path is dir or below it, which entries with
+// ".." in their names may not be.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractSkipsBadEntries(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "cache")
+	manifestDir := filepath.Join(root, "manifest")
+	archive := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin":    "a",
+		"io.triton.cache/BBB/b.cubin":    "b",
+		"io.triton.cache/../../evil.txt": "evil",
+	})
+	// A directory in the way of BBB/b.cubin makes it unwritable.
+	assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "BBB", "b.cubin", "x"), 0755))
+
+	_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "", false, nil, nil, nil)
+	assert.Error(t, err)
+
+	skipped := &skippedEntries{}
+	_, err = extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, manifestDir, nil, "", false, nil, nil, skipped)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(cacheDir, "AAA", "a.cubin"))
+	assert.NoFileExists(t, filepath.Join(root, "evil.txt"))
+	names := []string{}
+	for _, e := range skipped.entries {
+		names = append(names, e.Name)
+	}
+	assert.ElementsMatch(t, []string{"io.triton.cache/BBB/b.cubin", "io.triton.cache/../../evil.txt"}, names)
+}
+
+func TestExtractSkipsUnreadableLayer(t *testing.T) {
+	root := t.TempDir()
+	binary := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(binary)
+	archive := cacheArchive(t, map[string]string{"io.triton.cache/AAA/a.cubin": string(binary)})
+	truncated := archive[:len(archive)/2]
+
+	skipped := &skippedEntries{}
+	_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(truncated), "io.triton.cache/", "io.triton.manifest/",
+		filepath.Join(root, "cache"), filepath.Join(root, "manifest"), nil, "", false, nil, nil, skipped)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, skipped.count())
+	assert.Equal(t, "io.triton.cache/AAA/a.cubin", skipped.entries[0].Name)
+	assert.Contains(t, skipped.entries[0].Reason, "unreadable")
+}
+
+func TestExtractReportsCorruptLayer(t *testing.T) {
+	root := t.TempDir()
+	binary := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(binary)
+	archive := cacheArchive(t, map[string]string{"io.triton.cache/AAA/a.cubin": string(binary)})
+	corrupted := bytes.Clone(archive)
+	for i := len(corrupted) / 2; i < len(corrupted)/2+64; i++ {
+		corrupted[i] ^= 0xff
+	}
+
+	_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(corrupted), "io.triton.cache/", "io.triton.manifest/",
+		filepath.Join(root, "cache"), filepath.Join(root, "manifest"), nil, "", false, nil, nil, nil)
+	assert.Equal(t, failure.CorruptCache, failure.Classify(err))
+
+	// A layer that cannot be read to its end is not known to be corrupt.
+	_, err = extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive[:len(archive)/2]), "io.triton.cache/", "io.triton.manifest/",
+		filepath.Join(root, "cache2"), filepath.Join(root, "manifest2"), nil, "", false, nil, nil, nil)
+	assert.Error(t, err)
+	assert.Nil(t, failure.Classify(err))
+}
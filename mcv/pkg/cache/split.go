@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// SplitFromLabel records the image an image split by architecture was
+// split from.
+const SplitFromLabel = "cache.mcv.image/split-from"
+
+// ArchGroup is the kernels of a cache built for one GPU target.
+type ArchGroup struct {
+	Name    string   // Short name of the target, e.g. sm90 or gfx942
+	Backend string   // e.g. cuda or hip
+	Arch    string   // As Triton records it, e.g. 90 or gfx942
+	Dirs    []string // Kernel directories, relative to the cache root, sorted
+}
+
+// ArchName returns the short name of a GPU target used in image tags:
+// sm<arch> for CUDA, the arch itself for ROCm, e.g. gfx942, and
+// backend-arch for other backends.
+func ArchName(backend, arch string) string {
+	switch backend {
+	case "cuda":
+		return "sm" + arch
+	case "hip":
+		return arch
+	}
+	return backend + "-" + arch
+}
+
+// GroupKernelsByArch groups the Triton kernels of the cache at root, or of
+// the caches colocated there, by the GPU target they were built for, as
+// recorded in their metadata. Groups are sorted by name. Kernels whose
+// directory is unknown are left out of every group.
+func GroupKernelsByArch(root string) []ArchGroup {
+	type located struct {
+		dir   string // Cache directory, relative to root
+		cache Cache
+	}
+	var caches []located
+	if components := DetectComponents(root); components != nil {
+		for _, c := range components {
+			rel, err := filepath.Rel(root, c.Dir)
+			if err != nil {
+				continue
+			}
+			caches = append(caches, located{dir: filepath.ToSlash(rel), cache: c.Cache})
+		}
+	} else {
+		for _, c := range DetectCaches(root) {
+			caches = append(caches, located{dir: ".", cache: c})
+		}
+	}
+
+	groups := map[string]*ArchGroup{}
+	add := func(base string, m TritonCacheMetadata) {
+		if m.Dir == "" {
+			return
+		}
+		arch := ConvertArchToString(m.Arch)
+		name := ArchName(m.Backend, arch)
+		g, ok := groups[name]
+		if !ok {
+			g = &ArchGroup{Name: name, Backend: m.Backend, Arch: arch}
+			groups[name] = g
+		}
+		g.Dirs = append(g.Dirs, path.Join(base, m.Dir))
+	}
+	for _, c := range caches {
+		for _, e := range c.cache.Metadata() {
+			switch m := e.(type) {
+			case TritonCacheMetadata:
+				add(c.dir, m)
+			case VLLMCacheMetadata:
+				for _, te := range m.TritonCacheEntries {
+					if tm, ok := te.(TritonCacheMetadata); ok {
+						add(c.dir, tm)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]ArchGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Strings(g.Dirs)
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// CopyDirExcluding copies srcDir to dstDir but for the directories in
+// exclude, relative to srcDir. Files are hard linked where possible, as
+// the copies of a cache split by architecture are only read.
+func CopyDirExcluding(srcDir, dstDir string, exclude []string) error {
+	skip := map[string]bool{}
+	for _, d := range exclude {
+		skip[filepath.Clean(filepath.FromSlash(d))] = true
+	}
+	return filepath.Walk(srcDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		if skip[rel] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dstDir, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		if err := os.Link(p, target); err == nil {
+			return nil
+		}
+		return copyFile(p, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// OtherArchDirs returns the kernel directories of every group but the one
+// named keep, except those it shares with keep.
+func OtherArchDirs(groups []ArchGroup, keep string) []string {
+	kept := map[string]bool{}
+	for _, g := range groups {
+		if g.Name == keep {
+			for _, d := range g.Dirs {
+				kept[d] = true
+			}
+		}
+	}
+	var dirs []string
+	for _, g := range groups {
+		for _, d := range g.Dirs {
+			if g.Name != keep && !kept[d] {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	return dirs
+}
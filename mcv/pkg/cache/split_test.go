@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/benchgen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupKernelsByArch(t *testing.T) {
+	root := t.TempDir()
+	_, err := benchgen.Generate(root, benchgen.Options{Kernels: 3, BinarySize: 64, Backend: "cuda", Arch: "90", Seed: 1})
+	assert.NoError(t, err)
+	_, err = benchgen.Generate(root, benchgen.Options{Kernels: 2, BinarySize: 64, Backend: "hip", Arch: "gfx942", Seed: 2})
+	assert.NoError(t, err)
+
+	groups := GroupKernelsByArch(root)
+	if !assert.Len(t, groups, 2) {
+		return
+	}
+	assert.Equal(t, "gfx942", groups[0].Name)
+	assert.Len(t, groups[0].Dirs, 2)
+	assert.Equal(t, "sm90", groups[1].Name)
+	assert.Len(t, groups[1].Dirs, 3)
+
+	dst := filepath.Join(t.TempDir(), "sm90")
+	assert.NoError(t, CopyDirExcluding(root, dst, OtherArchDirs(groups, "sm90")))
+	for _, d := range groups[1].Dirs {
+		assert.DirExists(t, filepath.Join(dst, d))
+	}
+	for _, d := range groups[0].Dirs {
+		assert.NoDirExists(t, filepath.Join(dst, d))
+	}
+	split := GroupKernelsByArch(dst)
+	if assert.Len(t, split, 1) {
+		assert.Equal(t, groups[1], split[0])
+	}
+}
+
+func TestArchName(t *testing.T) {
+	assert.Equal(t, "sm90", ArchName("cuda", "90"))
+	assert.Equal(t, "gfx942", ArchName("hip", "gfx942"))
+	assert.Equal(t, "xpu-pvc", ArchName("xpu", "pvc"))
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/faults"
+	"github.com/redhat-et/MCU/mcv/pkg/iolimit"
+	"github.com/redhat-et/MCU/mcv/pkg/objstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// CacheStore is where extraction puts the files of cache layers. The tar
+// walker only decides which entry goes where and applies whiteouts; the
+// store does the writing, so a new extraction target only implements
+// CacheStore. Paths are those of the local view of the cache, where it
+// can be resolved and checked once extracted; a store that keeps the
+// files elsewhere stages them there and publishes them on Commit.
+type CacheStore interface {
+	// Prepare readies dir to receive a cache, before any layer is
+	// extracted into it.
+	Prepare(dir string) error
+	// MkdirAll creates the directory path and its missing parents.
+	MkdirAll(path string, mode os.FileMode) error
+	// WriteFile writes the size bytes read from r to path, replacing the
+	// file there only once all of them are written.
+	WriteFile(path string, r io.Reader, size int64, mode os.FileMode) error
+	// Lstat returns the file info of path, without following symlinks.
+	Lstat(path string) (os.FileInfo, error)
+	// RemoveAll removes path and everything below it.
+	RemoveAll(path string) error
+	// Commit is called once the cache is extracted into dir.
+	Commit(dir string) error
+}
+
+// Cache store kinds.
+const (
+	StoreDir   = "dir"   // A directory on the host's filesystem
+	StoreTmpfs = "tmpfs" // A directory on tmpfs, in memory
+	StoreS3    = "s3"    // An S3 bucket, or one of an S3-compatible service
+	StoreGCS   = "gcs"   // A Google Cloud Storage bucket
+)
+
+// StoreKinds returns the supported cache store kinds.
+func StoreKinds() []string {
+	return []string{StoreDir, StoreTmpfs, StoreS3, StoreGCS}
+}
+
+// IsObjectStore reports whether kind uploads caches to object storage
+// rather than leaving them on the host.
+func IsObjectStore(kind string) bool {
+	return kind == StoreS3 || kind == StoreGCS
+}
+
+// StoreOptions configure NewCacheStore.
+type StoreOptions struct {
+	Kind string
+	// TmpfsSize bounds the tmpfs mounted by a tmpfs store, 0 for the
+	// kernel default of half the memory.
+	TmpfsSize int64
+	// Bucket is the bucket[/prefix] an object store uploads to, Endpoint
+	// the URL of an S3-compatible service to use instead of the default.
+	Bucket   string
+	Endpoint string
+	// MountPath is where consumers see the bucket prefix, to which paths
+	// embedded in the uploaded metadata are resolved.
+	MountPath string
+	// Root is the local directory caches are staged in; it maps to the
+	// bucket prefix.
+	Root string
+}
+
+// NewCacheStore returns the store of opts.Kind.
+func NewCacheStore(opts StoreOptions) (CacheStore, error) {
+	if err := ValidateStoreKind(opts.Kind); err != nil {
+		return nil, err
+	}
+	switch opts.Kind {
+	case StoreTmpfs:
+		return &TmpfsStore{Size: opts.TmpfsSize}, nil
+	case StoreS3, StoreGCS:
+		return newObjectStore(opts)
+	}
+	return PosixDirStore{}, nil
+}
+
+// ValidateStoreKind checks kind is supported.
+func ValidateStoreKind(kind string) error {
+	if !slices.Contains(StoreKinds(), kind) {
+		return fmt.Errorf("unsupported cache store %q (supported: %s)", kind, strings.Join(StoreKinds(), ", "))
+	}
+	return nil
+}
+
+// PosixDirStore writes caches to directories of the host's filesystem.
+type PosixDirStore struct{}
+
+func (PosixDirStore) Prepare(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+func (PosixDirStore) MkdirAll(path string, mode os.FileMode) error {
+	return os.MkdirAll(path, mode)
+}
+
+// WriteFile writes to a temporary file first, synced before it is renamed,
+// so an interrupted extract, or a crash, never leaves a truncated file
+// behind under the final name for the journal to take as complete.
+func (PosixDirStore) WriteFile(filePath string, r io.Reader, size int64, mode os.FileMode) error {
+	// Create any parent directories if needed
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories for %s: %w", filePath, err)
+	}
+
+	tmpPath := filePath + ".partial"
+	outFile, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", filePath, err)
+	}
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(iolimit.WrapWriter(faults.WrapFile(outFile)), r); err != nil {
+		outFile.Close()
+		return fmt.Errorf("failed to copy content to file %s: %w", filePath, err)
+	}
+	if err := outFile.Sync(); err != nil {
+		outFile.Close()
+		return fmt.Errorf("failed to sync file %s: %w", filePath, err)
+	}
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set file permissions for %s: %w", filePath, err)
+	}
+
+	return os.Rename(tmpPath, filePath)
+}
+
+func (PosixDirStore) Lstat(path string) (os.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (PosixDirStore) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (PosixDirStore) Commit(string) error {
+	return nil
+}
+
+// tmpfsMagic is the statfs magic number of tmpfs.
+const tmpfsMagic = 0x01021994
+
+// TmpfsStore writes caches to tmpfs, for nodes whose disks are slow or
+// ephemeral, mounting one on the cache directory unless it already is on
+// tmpfs. Files are checked to fit before they are written, so a full
+// tmpfs fails the entry that does not fit with a clear error rather than
+// with a truncated file.
+type TmpfsStore struct {
+	PosixDirStore
+	// Size bounds the tmpfs mounted, in bytes; 0 leaves the kernel's
+	// default of half the memory.
+	Size int64
+}
+
+func (s *TmpfsStore) Prepare(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return fmt.Errorf("failed to stat the filesystem of %s: %w", dir, err)
+	}
+	if int64(st.Type) == tmpfsMagic {
+		return nil
+	}
+	opts := "mode=0755"
+	if s.Size > 0 {
+		opts += fmt.Sprintf(",size=%d", s.Size)
+	}
+	if err := runMount("-t", "tmpfs", "-o", opts, "tmpfs", dir); err != nil {
+		return err
+	}
+	logging.Infof("Mounted tmpfs on %s", dir)
+	return nil
+}
+
+func (s *TmpfsStore) WriteFile(path string, r io.Reader, size int64, mode os.FileMode) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(filepath.Dir(path), &st); err == nil {
+		if free := int64(st.Bavail) * int64(st.Bsize); size > free {
+			return fmt.Errorf("no room on tmpfs for %s: %d bytes needed, %d free", path, size, free)
+		}
+	}
+	return s.PosixDirStore.WriteFile(path, r, size, mode)
+}
+
+// ObjectStore uploads caches to an S3 or Google Cloud Storage bucket, for
+// serverless GPU platforms that stage caches in object storage. Files are
+// staged in the local cache directory, where they are checked as usual,
+// and uploaded on Commit under the bucket prefix, at their path relative
+// to Root. Paths embedded in metadata are first rewritten from Root to
+// MountPath, or to CacheRootPlaceholder when it is not set, so the cache
+// resolves where consumers mount the bucket.
+type ObjectStore struct {
+	PosixDirStore
+	Bucket    *objstore.Bucket
+	Root      string
+	MountPath string
+}
+
+func newObjectStore(opts StoreOptions) (*ObjectStore, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("the %s store needs a bucket", opts.Kind)
+	}
+	if opts.Root == "" {
+		return nil, fmt.Errorf("the %s store needs a staging directory", opts.Kind)
+	}
+	creds, err := objstore.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	scheme := objstore.SchemeS3
+	if opts.Kind == StoreGCS {
+		scheme = objstore.SchemeGCS
+	}
+	bucket, err := objstore.Open(scheme+"://"+strings.TrimPrefix(opts.Bucket, "/"), opts.Endpoint, "", creds)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectStore{Bucket: bucket, Root: filepath.Clean(opts.Root), MountPath: opts.MountPath}, nil
+}
+
+func (s *ObjectStore) Commit(dir string) error {
+	rel, err := filepath.Rel(s.Root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		rel = "."
+	}
+	remote := CacheRootPlaceholder
+	if s.MountPath != "" {
+		remote = filepath.Clean(s.MountPath)
+	}
+	err = walkRelocatable(dir, func(_ string, data []byte) ([]byte, error) {
+		for _, sep := range []string{"/", `"`, "'"} {
+			data = bytes.ReplaceAll(data, []byte(s.Root+sep), []byte(remote+sep))
+		}
+		return data, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite paths for %s: %w", s.Bucket, err)
+	}
+
+	bucket := *s.Bucket
+	bucket.Prefix = bucket.Key(filepath.ToSlash(rel))
+	ctx, cancel := registry.Context(context.Background(), registry.OpPush)
+	defer cancel()
+	n, err := bucket.UploadDir(ctx, dir, skipUpload)
+	if err != nil {
+		return registry.TimeoutError(ctx, err)
+	}
+	logging.Infof("Uploaded %d files to %s", n, &bucket)
+	return nil
+}
+
+// skipUpload reports whether rel is one of mcv's own bookkeeping files,
+// such as the extraction journal, lock and marker, or a partial write.
+func skipUpload(rel string) bool {
+	name := path.Base(rel)
+	return strings.HasPrefix(name, ".mcv-") || strings.HasSuffix(name, ".partial")
+}
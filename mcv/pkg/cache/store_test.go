@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingStore writes to the host's filesystem and records the files
+// written through it.
+type recordingStore struct {
+	PosixDirStore
+	written []string
+}
+
+func (s *recordingStore) WriteFile(path string, r io.Reader, size int64, mode os.FileMode) error {
+	s.written = append(s.written, path)
+	return s.PosixDirStore.WriteFile(path, r, size, mode)
+}
+
+func TestExtractWritesThroughStore(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "cache")
+	archive := cacheArchive(t, map[string]string{
+		"io.triton.cache/AAA/a.cubin":      "a",
+		"io.triton.manifest/manifest.json": "{}",
+	})
+
+	store := &recordingStore{}
+	_, err := extractCacheAndManifestDirectory(context.Background(), bytes.NewReader(archive), "io.triton.cache/", "io.triton.manifest/",
+		cacheDir, filepath.Join(root, "manifest"), nil, "", false, newLayerApplier(store), nil, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(cacheDir, "AAA", "a.cubin"), filepath.Join(root, "manifest", "manifest.json")}, store.written)
+	assert.FileExists(t, filepath.Join(cacheDir, "AAA", "a.cubin"))
+}
+
+func TestNewCacheStore(t *testing.T) {
+	s, err := NewCacheStore(StoreOptions{Kind: StoreDir})
+	assert.NoError(t, err)
+	assert.Equal(t, PosixDirStore{}, s)
+	s, err = NewCacheStore(StoreOptions{Kind: StoreTmpfs, TmpfsSize: 1 << 30})
+	assert.NoError(t, err)
+	assert.Equal(t, &TmpfsStore{Size: 1 << 30}, s)
+	_, err = NewCacheStore(StoreOptions{Kind: "nfs"})
+	assert.Error(t, err)
+	_, err = NewCacheStore(StoreOptions{Kind: StoreS3, Root: t.TempDir()})
+	assert.ErrorContains(t, err, "needs a bucket")
+}
+
+func TestObjectStoreCommit(t *testing.T) {
+	uploaded := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploaded[r.URL.Path] = string(body)
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "triton")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "AAA"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "AAA", "__grp__k.json"), []byte(`{"child_paths": {"k.cubin": "`+dir+`/AAA/k.cubin"}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "AAA", "k.cubin"), []byte("cubin"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, JournalFileName), []byte("journal"), 0644))
+
+	s, err := NewCacheStore(StoreOptions{Kind: StoreS3, Bucket: "caches/llama", Endpoint: srv.URL, MountPath: "/mnt/caches", Root: root})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Commit(dir))
+	assert.Equal(t, map[string]string{
+		"/caches/llama/triton/AAA/__grp__k.json": `{"child_paths": {"k.cubin": "/mnt/caches/triton/AAA/k.cubin"}}`,
+		"/caches/llama/triton/AAA/k.cubin":       "cubin",
+	}, uploaded)
+}
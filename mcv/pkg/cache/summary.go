@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MaxSummaryLabelBytes is the largest summary stored inline in an image
+// label. Registries limit the size of the image config, so larger
+// summaries are moved to a metadata layer and the label holds a pointer.
+const MaxSummaryLabelBytes = 16 * 1024
+
+// SummaryPointer replaces an oversize summary label. Path is the summary
+// file inside the image; Digest, when set, is the layer holding it.
+type SummaryPointer struct {
+	External bool   `json:"external"`
+	Path     string `json:"path"`
+	Digest   string `json:"digest,omitempty"`
+	Size     int    `json:"size"`
+}
+
+// SummaryFilePath returns the in-image path of an external summary for the
+// named cache type.
+func SummaryFilePath(cacheName string) string {
+	return fmt.Sprintf("io.%s.summary/summary.json", cacheName)
+}
+
+// IsSummaryLabel reports whether an image label key holds a cache summary.
+func IsSummaryLabel(key string) bool {
+	return strings.HasPrefix(key, "cache.") && strings.HasSuffix(key, ".image/summary")
+}
+
+// ParseSummaryLabel decodes a summary label value. It returns either the
+// inline summary or, for summaries stored outside the label, the pointer
+// to resolve.
+func ParseSummaryLabel(value string) (*Summary, *SummaryPointer, error) {
+	var ptr SummaryPointer
+	if err := json.Unmarshal([]byte(value), &ptr); err == nil && ptr.External {
+		return nil, &ptr, nil
+	}
+
+	var summary Summary
+	if err := json.Unmarshal([]byte(value), &summary); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse summary label: %w", err)
+	}
+	return &summary, nil, nil
+}
+
+// EncodeSummaryPointer returns the label value for ptr.
+func EncodeSummaryPointer(ptr SummaryPointer) string {
+	ptr.External = true
+	data, _ := json.Marshal(ptr)
+	return string(data)
+}
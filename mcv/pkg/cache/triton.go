@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,7 +17,9 @@ import (
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
 	"github.com/redhat-et/MCU/mcv/pkg/config"
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
 
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -24,6 +27,7 @@ type TritonCache struct {
 	path        string
 	tmpPath     string
 	allMetadata []TritonCacheMetadata
+	hostArchs   []string
 }
 
 func DetectTritonCache(cacheDir string) *TritonCache {
@@ -49,7 +53,7 @@ func DetectTritonCache(cacheDir string) *TritonCache {
 	})
 
 	if err != nil {
-		logging.WithError(err).Warnf("Error walking Triton cache directory: %s", cacheDir)
+		diag.Warnf(diag.CacheReadFailed, "Error walking Triton cache directory %s: %v", cacheDir, err)
 		return nil
 	}
 
@@ -57,7 +61,7 @@ func DetectTritonCache(cacheDir string) *TritonCache {
 		logging.Debugf("Triton cache detected in directory: %s", cacheDir)
 		metadata := getTritonMetadata(cacheDir)
 		if len(metadata) > 0 {
-			return &TritonCache{path: cacheDir, allMetadata: metadata}
+			return &TritonCache{path: cacheDir, allMetadata: metadata, hostArchs: DetectHostArchs(cacheDir)}
 		}
 	}
 
@@ -97,8 +101,11 @@ func getTritonMetadata(root string) []TritonCacheMetadata {
 			continue
 		}
 
+		dir, _ := filepath.Rel(root, filepath.Dir(f))
 		allMetadata = append(allMetadata, TritonCacheMetadata{
 			Hash: data.Hash,
+			Name: data.Name,
+			Dir:  filepath.ToSlash(dir),
 			Target: Target{
 				Backend:  data.Target.Backend,
 				Arch:     ConvertArchToString(data.Target.Arch),
@@ -113,6 +120,7 @@ func getTritonMetadata(root string) []TritonCacheMetadata {
 			NumStages: data.NumStages,
 			NumWarps:  data.NumWarps,
 			Debug:     data.Debug,
+			Shared:    data.Shared,
 			DummyKey:  dummyKey,
 		})
 	}
@@ -153,6 +161,7 @@ func (t *TritonCache) Summary() string {
 		logging.WithError(err).Error("failed to build Triton summary")
 		return ""
 	}
+	summary.HostArchs = t.hostArchs
 
 	jsonData, err := json.Marshal(summary)
 	if err != nil {
@@ -239,7 +248,9 @@ func CompareTritonCacheToGPU(cacheData *TritonCacheData, acc accelerator.Acceler
 			}
 		}
 
-		if backendMatch && archMatch && warpMatch && ptxMatch {
+		sharedMemMatch := gpu.SupportsSharedMemory(cacheData.Shared)
+
+		if backendMatch && archMatch && warpMatch && ptxMatch && sharedMemMatch {
 			return nil // match found
 		}
 
@@ -248,6 +259,7 @@ func CompareTritonCacheToGPU(cacheData *TritonCacheData, acc accelerator.Acceler
 			"arch_match":    archMatch,
 			"warp_match":    warpMatch,
 			"ptx_match":     ptxMatch,
+			"shared_match":  sharedMemMatch,
 			"cache_backend": cacheData.Target.Backend,
 			"gpu_backend":   gpu.Backend,
 			"cache_arch":    ConvertArchToString(cacheData.Target.Arch),
@@ -257,7 +269,7 @@ func CompareTritonCacheToGPU(cacheData *TritonCacheData, acc accelerator.Acceler
 		}).Debug("Triton cache entry mismatch")
 	}
 
-	return fmt.Errorf("no compatible GPU found for Triton cache metadata")
+	return failure.New(failure.Incompatible, errors.New("no compatible GPU found for Triton cache metadata"))
 }
 
 // checkFirstKeyHash checks if the first key in the JSON file is "Hash": "hashvalue"
@@ -310,7 +322,7 @@ func findAllTritonCacheJSON(cacheDir string) ([]string, error) {
 		return nil, err
 	}
 	if len(files) == 0 {
-		return nil, fmt.Errorf("no valid Triton cache JSON files found in %s", cacheDir)
+		return nil, failure.New(failure.NoCache, fmt.Errorf("no valid Triton cache JSON files found in %s", cacheDir))
 	}
 
 	return files, nil
@@ -325,7 +337,7 @@ func ConvertArchToString(arch any) string {
 	case float64:
 		return fmt.Sprintf("%.0f", v)
 	default:
-		logging.Warnf("Unexpected arch type: %T", v)
+		diag.Warnf(diag.UnexpectedArchValue, "Unexpected arch type: %T", v)
 		return ""
 	}
 }
@@ -365,10 +377,43 @@ func (t *TritonCache) SetTmpPath(path string) {
 
 func ExtractTritonCacheDirectory(r io.Reader) ([]string, error) {
 	return extractCacheAndManifestDirectory(
+		context.Background(),
 		r,
 		constants.MCVTritonCacheDir,
 		"io.triton.manifest/",
 		constants.ExtractCacheDir,
 		constants.ExtractManifestDir,
+		tritonArtifactDirs(),
+		"", false, nil, nil, nil,
 	)
 }
+
+// PruneTritonKernels removes the kernel directories under root whose target
+// keep rejects, and returns the directories removed. Kernel metadata found
+// directly in root is never removed.
+func PruneTritonKernels(root string, keep func(Target) bool) ([]string, error) {
+	files, err := findAllTritonCacheJSON(root)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if dir == filepath.Clean(root) {
+			continue
+		}
+		data, err := GetTritonCacheJSONData(f)
+		if err != nil || data == nil {
+			continue
+		}
+		target := Target{Backend: data.Target.Backend, Arch: ConvertArchToString(data.Target.Arch), WarpSize: data.Target.WarpSize}
+		if keep(target) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, fmt.Errorf("failed to remove kernel dir %s: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
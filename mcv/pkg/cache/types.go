@@ -8,6 +8,9 @@ type SummaryTargetInfo struct {
 
 type Summary struct {
 	Targets []SummaryTargetInfo `json:"targets"`
+	// HostArchs are the CPU architectures, as in runtime.GOARCH, of the
+	// host code in the cache; empty if the cache holds GPU code only.
+	HostArchs []string `json:"host_archs,omitempty"`
 }
 
 type TritonCacheData struct {
@@ -45,11 +48,15 @@ type TritonCacheData struct {
 
 type TritonCacheMetadata struct {
 	Hash       string `json:"hash"`
+	Name       string `json:"name,omitempty"` // Kernel name, e.g. _attn_fwd
+	Dir        string `json:"dir,omitempty"`  // Entry directory, relative to the cache
+	Hot        bool   `json:"hot,omitempty"`  // Extracted ahead of the other entries
 	DummyKey   string `json:"dummy_key,omitempty"`
 	PtxVersion int    `json:"ptx_version,omitempty"`
 	NumStages  int    `json:"num_stages,omitempty"`
 	NumWarps   int    `json:"num_warps,omitempty"`
 	Debug      bool   `json:"debug,omitempty"`
+	Shared     int    `json:"shared,omitempty"`
 	Target
 }
 
@@ -1,17 +1,20 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -31,11 +34,14 @@ type VLLMCache struct {
 	count       int
 	tritonCache *TritonCache
 	allMetadata []VLLMCacheMetadata
+	keyInputs   *VLLMKeyInputs
+	hostArchs   []string
 }
 
 type VLLMCacheMetadata struct {
-	VllmHash           string       `json:"vllmHash"`
-	TritonCacheEntries []CacheEntry `json:"triton"`
+	VllmHash           string         `json:"vllmHash"`
+	TritonCacheEntries []CacheEntry   `json:"triton"`
+	KeyInputs          *VLLMKeyInputs `json:"keyInputs,omitempty"`
 }
 
 // DetectVLLMCache walks the given root directory to detect whether VLLM-style cache artifacts exist
@@ -64,7 +70,7 @@ func DetectVLLMCache(cacheDir string) *VLLMCache {
 	})
 
 	if err != nil {
-		logging.WithError(err).Warnf("Error walking vllm cache directory: %s", cacheDir)
+		diag.Warnf(diag.CacheReadFailed, "Error walking vllm cache directory %s: %v", cacheDir, err)
 		return nil
 	}
 
@@ -72,7 +78,7 @@ func DetectVLLMCache(cacheDir string) *VLLMCache {
 	if found {
 		torchCompileCachePath = filepath.Join(cacheDir, "torch_compile_cache")
 		if _, err := os.Stat(torchCompileCachePath); os.IsNotExist(err) {
-			logging.Warnf("Torch compile cache path does not exist: %s", torchCompileCachePath)
+			diag.Warnf(diag.VLLMCacheIncomplete, "Torch compile cache path does not exist: %s", torchCompileCachePath)
 			return nil
 		}
 		entries, err := os.ReadDir(torchCompileCachePath)
@@ -94,20 +100,20 @@ func DetectVLLMCache(cacheDir string) *VLLMCache {
 					return nil
 				})
 				if err != nil || tritonCachePath == "" {
-					logging.Warnf("Triton cache path not found for entry: %s", entry.Name())
+					diag.Warnf(diag.VLLMCacheIncomplete, "Triton cache path not found for entry: %s", entry.Name())
 					continue
 				}
 
 				// Check if tritonCachePath exists
 				if _, err := os.Stat(tritonCachePath); os.IsNotExist(err) {
-					logging.Warnf("Triton cache path does not exist: %s", tritonCachePath)
+					diag.Warnf(diag.VLLMCacheIncomplete, "Triton cache path does not exist: %s", tritonCachePath)
 					continue
 				}
 
 				logging.Debugf("Inspecting potential Triton cache at: %s", tritonCachePath)
 				_tc := DetectTritonCache(tritonCachePath)
 				if _tc == nil {
-					logging.Warnf("Failed to detect Triton cache at: %s", tritonCachePath)
+					diag.Warnf(diag.VLLMCacheIncomplete, "Failed to detect Triton cache at: %s", tritonCachePath)
 					continue
 				}
 				tc = _tc
@@ -115,6 +121,16 @@ func DetectVLLMCache(cacheDir string) *VLLMCache {
 					VllmHash:           entry.Name(),
 					TritonCacheEntries: tc.Metadata(),
 				}
+				// Locate the Triton entries in the vLLM cache rather than
+				// in their Triton cache.
+				if rel, err := filepath.Rel(cacheDir, tritonCachePath); err == nil {
+					for i, e := range vllmMetadata.TritonCacheEntries {
+						if m, ok := e.(TritonCacheMetadata); ok {
+							m.Dir = path.Join(filepath.ToSlash(rel), m.Dir)
+							vllmMetadata.TritonCacheEntries[i] = m
+						}
+					}
+				}
 
 				logging.Debugf("Adding VLLM metadata: %+v", vllmMetadata)
 				metadata = append(metadata, vllmMetadata)
@@ -128,6 +144,7 @@ func DetectVLLMCache(cacheDir string) *VLLMCache {
 			tritonCache: tc,
 			count:       count,
 			allMetadata: metadata,
+			hostArchs:   DetectHostArchs(cacheDir),
 		}
 	}
 	return nil
@@ -159,6 +176,7 @@ func (v *VLLMCache) Summary() string {
 			return ""
 		}
 		summary = tempSummary
+		summary.HostArchs = v.hostArchs
 	}
 
 	jsonData, err := json.Marshal(summary)
@@ -173,11 +191,26 @@ func (v *VLLMCache) Summary() string {
 }
 
 func (v *VLLMCache) Labels() map[string]string {
-	return map[string]string{
+	labels := map[string]string{
 		cacheVLLMImageEntryCount: strconv.Itoa(v.EntryCount()),
 		cacheVLLMImageCacheSize:  strconv.FormatInt(v.CacheSizeBytes(), 10),
 		cacheVLLMImageSummary:    v.Summary(),
 	}
+	if v.keyInputs != nil {
+		if data, err := json.Marshal(v.keyInputs); err == nil {
+			labels[VLLMKeyInputsLabel] = string(data)
+		}
+	}
+	return labels
+}
+
+// SetKeyInputs records the versions of the vLLM installation that built the
+// cache in its labels and manifest entries.
+func (v *VLLMCache) SetKeyInputs(k *VLLMKeyInputs) {
+	v.keyInputs = k
+	for i := range v.allMetadata {
+		v.allMetadata[i].KeyInputs = k
+	}
 }
 
 func (v *VLLMCache) Metadata() []CacheEntry {
@@ -206,10 +239,13 @@ func (v *VLLMCache) SetTmpPath(path string) {
 // This is only used for *compat* variant.
 func ExtractVLLMCacheDirectory(r io.Reader) ([]string, error) {
 	return extractCacheAndManifestDirectory(
+		context.Background(),
 		r,
 		constants.MCVVLLMCacheDir,
 		"io.vllm.manifest/",
 		constants.ExtractCacheDir,
 		constants.ExtractManifestDir,
+		nil,
+		"", false, nil, nil, nil,
 	)
 }
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// VLLMKeyInputsLabel records the VLLMKeyInputs of the installation a vLLM
+// cache was packaged with, so extraction can check them before writing.
+const VLLMKeyInputsLabel = cacheVLLMImagePrefix + "/key-inputs"
+
+const probeTimeout = 30 * time.Second
+
+// probeScript prints the installed versions without importing the packages,
+// which is slow for vLLM and may log to stdout.
+const probeScript = `import json
+from importlib import metadata
+out = {}
+for key, dists in (("vllm", ["vllm"]), ("torch", ["torch"]),
+                   ("triton", ["triton", "pytorch-triton-rocm", "pytorch-triton"])):
+    for d in dists:
+        try:
+            out[key] = metadata.version(d)
+            break
+        except metadata.PackageNotFoundError:
+            pass
+print(json.dumps(out))
+`
+
+// VLLMKeyInputs are the package versions vLLM hashes into the names of its
+// compile cache directories. vLLM never looks up a cache built with other
+// versions, it compiles a new one next to it.
+type VLLMKeyInputs struct {
+	VLLM   string `json:"vllm"`
+	Torch  string `json:"torch,omitempty"`
+	Triton string `json:"triton,omitempty"`
+}
+
+// ProbeVLLMKeyInputs returns the versions installed for the python
+// interpreter, which may be a path into a virtual environment.
+func ProbeVLLMKeyInputs(python string) (*VLLMKeyInputs, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, python, "-c", probeScript)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w: %s", python, err, strings.TrimSpace(stderr.String()))
+	}
+	var k VLLMKeyInputs
+	if err := json.Unmarshal(out, &k); err != nil {
+		return nil, fmt.Errorf("invalid output from %s: %w", python, err)
+	}
+	if k.VLLM == "" {
+		return nil, fmt.Errorf("vLLM is not installed for %s", python)
+	}
+	return &k, nil
+}
+
+// ParseVLLMKeyInputs reads the key inputs label. It returns nil for images
+// packaged without one.
+func ParseVLLMKeyInputs(labels map[string]string) (*VLLMKeyInputs, error) {
+	v, ok := labels[VLLMKeyInputsLabel]
+	if !ok {
+		return nil, nil
+	}
+	var k VLLMKeyInputs
+	if err := json.Unmarshal([]byte(v), &k); err != nil {
+		return nil, fmt.Errorf("invalid %s label: %w", VLLMKeyInputsLabel, err)
+	}
+	return &k, nil
+}
+
+// Mismatches lists the versions that differ from local. Versions missing on
+// either side are not compared.
+func (k *VLLMKeyInputs) Mismatches(local *VLLMKeyInputs) []string {
+	var diffs []string
+	for _, f := range []struct{ name, packaged, local string }{
+		{"vllm", k.VLLM, local.VLLM},
+		{"torch", k.Torch, local.Torch},
+		{"triton", k.Triton, local.Triton},
+	} {
+		if f.packaged != "" && f.local != "" && f.packaged != f.local {
+			diffs = append(diffs, fmt.Sprintf("%s %s (installed %s)", f.name, f.packaged, f.local))
+		}
+	}
+	return diffs
+}
+
+// Namespace names a directory for caches built with these versions.
+func (k *VLLMKeyInputs) Namespace() string {
+	parts := []string{"vllm-" + k.VLLM}
+	if k.Torch != "" {
+		parts = append(parts, "torch-"+k.Torch)
+	}
+	if k.Triton != "" {
+		parts = append(parts, "triton-"+k.Triton)
+	}
+	return strings.NewReplacer("/", "_", " ", "_").Replace(strings.Join(parts, "_"))
+}
@@ -0,0 +1,155 @@
+// Package capture records which entries of a kernel cache a workload
+// compiles or loads, so a canary run can produce the include list of a
+// minimal cache image.
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the capture file format version.
+const Version = 1
+
+// Kernel directories are named after the kernel hash: base32 for current
+// Triton and inductor, hex for older Triton.
+var kernelDirRegex = regexp.MustCompile(`^([A-Z2-7]{52}|[a-z2-7]{52}|[0-9a-f]{64}|[0-9a-f]{32})$`)
+
+// Entry is a file or kernel directory touched during a capture, relative
+// to the cache directory.
+type Entry struct {
+	Path     string `json:"path"`
+	Compiled bool   `json:"compiled"` // Written during the capture
+	Used     bool   `json:"used"`     // Loaded, not written, during the capture
+
+	// Set by usage tracking: the number of samples the entry was read in,
+	// and when it was last read.
+	Uses     int        `json:"uses,omitempty"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+// Capture is the result of a capture run.
+type Capture struct {
+	Version  int       `json:"version"`
+	CacheDir string    `json:"cacheDir"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Entries  []Entry   `json:"entries"`
+	// Unused lists, for usage tracking, the entries of the cache that were
+	// not read. They are not packaged with --filter-from.
+	Unused []string `json:"unused,omitempty"`
+}
+
+// Include returns the paths to package, relative to the cache directory.
+func (c *Capture) Include() []string {
+	paths := make([]string, 0, len(c.Entries))
+	for _, e := range c.Entries {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+// Load reads a capture file.
+func Load(path string) (*Capture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture file: %w", err)
+	}
+	var c Capture
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid capture file %s: %w", path, err)
+	}
+	if c.Version != Version {
+		return nil, fmt.Errorf("capture file %s has version %d, expected %d", path, c.Version, Version)
+	}
+	for _, e := range c.Entries {
+		if e.Path == "" || filepath.IsAbs(e.Path) || strings.HasPrefix(filepath.Clean(e.Path), "..") {
+			return nil, fmt.Errorf("invalid capture file %s: path %q is not inside the cache", path, e.Path)
+		}
+	}
+	return &c, nil
+}
+
+// Save writes the capture file, replacing any previous one atomically.
+func (c *Capture) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal capture: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write capture file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write capture file: %w", err)
+	}
+	return nil
+}
+
+// recorder collects the entries touched during a capture.
+type recorder struct {
+	root    string
+	entries map[string]*Entry
+}
+
+func newRecorder(root string) *recorder {
+	return &recorder{root: root, entries: map[string]*Entry{}}
+}
+
+// record notes that the file at path was written or opened. Files in a
+// kernel directory stand for the whole directory, as a kernel needs all
+// its artifacts even if only some are opened.
+func (r *recorder) record(path string, compiled bool) {
+	rel, ok := entryPath(r.root, path)
+	if !ok {
+		return
+	}
+	e, ok := r.entries[rel]
+	if !ok {
+		e = &Entry{Path: rel}
+		r.entries[rel] = e
+	}
+	if compiled {
+		e.Compiled = true
+	} else {
+		e.Used = true
+	}
+}
+
+// entryPath returns the entry, relative to root, that the file at path
+// belongs to: its kernel directory, if in one, or else the file. It returns
+// false for files that are not cache entries.
+func entryPath(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		// In-progress writes are renamed into place when complete.
+		if strings.HasPrefix(part, "tmp.pid_") || strings.HasSuffix(part, ".lock") {
+			return "", false
+		}
+	}
+	if dir := filepath.Dir(rel); dir != "." && kernelDirRegex.MatchString(filepath.Base(dir)) {
+		rel = dir
+	}
+	return rel, true
+}
+
+func (r *recorder) capture(started time.Time) *Capture {
+	c := &Capture{Version: Version, CacheDir: r.root, Started: started, Finished: time.Now(), Entries: []Entry{}}
+	for _, e := range r.entries {
+		// Files are opened to be written too.
+		e.Used = e.Used && !e.Compiled
+		c.Entries = append(c.Entries, *e)
+	}
+	sort.Slice(c.Entries, func(i, j int) bool { return c.Entries[i].Path < c.Entries[j].Path })
+	return c
+}
@@ -0,0 +1,104 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const kernelHash = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567ABCDEFGHIJKLMNOPQRST"
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "rank_0_0"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "rank_0_0", "index.py"), []byte("x"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "unused.py"), []byte("x"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *Capture, 1)
+	go func() {
+		c, err := Watch(ctx, dir)
+		assert.NoError(t, err)
+		done <- c
+	}()
+	time.Sleep(300 * time.Millisecond)
+
+	// A kernel compiled the way Triton writes it: into a tmp dir, then renamed.
+	kernel := filepath.Join(dir, kernelHash)
+	assert.NoError(t, os.MkdirAll(filepath.Join(kernel, "tmp.pid_1_2"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(kernel, "tmp.pid_1_2", "k.cubin"), []byte("x"), 0644))
+	assert.NoError(t, os.Rename(filepath.Join(kernel, "tmp.pid_1_2", "k.cubin"), filepath.Join(kernel, "k.cubin")))
+	_, err := os.ReadFile(filepath.Join(dir, "rank_0_0", "index.py"))
+	assert.NoError(t, err)
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	c := <-done
+	assert.Equal(t, []Entry{
+		{Path: kernelHash, Compiled: true},
+		{Path: filepath.Join("rank_0_0", "index.py"), Used: true},
+	}, c.Entries)
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.json")
+	c := &Capture{Version: Version, CacheDir: "/cache", Entries: []Entry{{Path: kernelHash, Used: true}}}
+	assert.NoError(t, c.Save(path))
+
+	loaded, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{kernelHash}, loaded.Include())
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"version": 1, "entries": [{"path": "../etc"}]}`), 0644))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "not inside the cache")
+}
+
+func TestTracker(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	for _, f := range []string{filepath.Join(kernelHash, "k.cubin"), filepath.Join(kernelHash, "k.json"), "other.py", ".mcv-journal"} {
+		path := filepath.Join(dir, f)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+		// Read after extraction, before tracking started.
+		assert.NoError(t, os.Chtimes(path, time.Now(), mtime))
+	}
+	tr, err := NewTracker(dir, nil)
+	if err != nil && strings.Contains(err.Error(), "noatime") {
+		t.Skip(err)
+	}
+	assert.NoError(t, err)
+
+	// A read sets the access time past the one the tracker reset it to.
+	read := func(f string) {
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, f), time.Now(), mtime))
+	}
+	read(filepath.Join(kernelHash, "k.cubin"))
+	read(".mcv-journal")
+	n, err := tr.Sample()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	read(filepath.Join(kernelHash, "k.json"))
+	n, err = tr.Sample()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	c := tr.Capture()
+	assert.Len(t, c.Entries, 1)
+	assert.Equal(t, kernelHash, c.Entries[0].Path)
+	assert.Equal(t, 2, c.Entries[0].Uses)
+	assert.NotNil(t, c.Entries[0].LastUsed)
+	assert.Equal(t, []string{"other.py"}, c.Unused)
+	assert.Equal(t, []string{kernelHash}, c.Include())
+
+	// Tracking resumed from a saved result keeps the counts.
+	tr, err = NewTracker(dir, c)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tr.Capture().Entries[0].Uses)
+}
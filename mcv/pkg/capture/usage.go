@@ -0,0 +1,149 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"golang.org/x/sys/unix"
+)
+
+// Tracker reports which entries of an extracted cache workloads read, by
+// sampling the access times of its files. Unlike Watch it keeps no
+// watches, so it can run for days next to a workload at little cost.
+//
+// With relatime, the default, the kernel only updates a file's access time
+// when it is older than the file's modification time. After each sample
+// the tracker sets the access times of the files it can write back to
+// their modification time, so the next read is seen too.
+type Tracker struct {
+	root     string
+	started  time.Time
+	accessed map[string]time.Time // File to its access time at the last sample
+	entries  map[string]*Entry
+	armWarn  bool
+}
+
+// NewTracker starts tracking the cache in dir. Reads before it started are
+// not counted. With prev, the result of an earlier tracking of dir, counts
+// continue from there.
+func NewTracker(dir string, prev *Capture) (*Tracker, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var st unix.Statfs_t
+	if err := unix.Statfs(abs, &st); err != nil {
+		return nil, fmt.Errorf("cache directory %s does not exist", dir)
+	}
+	if st.Flags&unix.ST_NOATIME != 0 {
+		return nil, fmt.Errorf("%s is mounted with noatime, so reads of its files cannot be tracked", dir)
+	}
+
+	t := &Tracker{root: abs, started: time.Now(), accessed: map[string]time.Time{}, entries: map[string]*Entry{}}
+	if prev != nil && prev.CacheDir == abs {
+		t.started = prev.Started
+		for _, e := range prev.Entries {
+			e := e
+			t.entries[e.Path] = &e
+		}
+	}
+	if _, err := t.Sample(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Sample records the entries read since the last sample and returns how
+// many there were.
+func (t *Tracker) Sample() (int, error) {
+	read := map[string]bool{}
+	err := filepath.WalkDir(t.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != t.root {
+			// mcv's own files, such as its journal.
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, ok := entryPath(t.root, path)
+		if !ok {
+			return nil
+		}
+		var st unix.Stat_t
+		if err := unix.Stat(path, &st); err != nil {
+			return nil
+		}
+		atime := time.Unix(st.Atim.Unix())
+		mtime := time.Unix(st.Mtim.Unix())
+
+		e, ok := t.entries[rel]
+		if !ok {
+			e = &Entry{Path: rel}
+			t.entries[rel] = e
+		}
+		if last, seen := t.accessed[path]; seen && atime.After(last) {
+			read[rel] = true
+			if e.LastUsed == nil || atime.After(*e.LastUsed) {
+				e.LastUsed = &atime
+			}
+		}
+		t.accessed[path] = t.arm(path, atime, mtime)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to sample %s: %w", t.root, err)
+	}
+	for rel := range read {
+		t.entries[rel].Used = true
+		t.entries[rel].Uses++
+	}
+	return len(read), nil
+}
+
+// arm sets the access time of path back to its modification time, so the
+// next read updates it, and returns the access time the file is left with.
+func (t *Tracker) arm(path string, atime, mtime time.Time) time.Time {
+	if !atime.After(mtime) {
+		return atime
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		if !t.armWarn {
+			t.armWarn = true
+			diag.Warnf(diag.AccessTimesNotReset, "Cannot reset access times in %s (%v); reads less than a day apart may be counted once", t.root, err)
+		}
+		return atime
+	}
+	return mtime
+}
+
+// Capture returns the entries read so far, as a capture that packages
+// only them with --filter-from, and lists the others as unused.
+func (t *Tracker) Capture() *Capture {
+	c := &Capture{Version: Version, CacheDir: t.root, Started: t.started, Finished: time.Now(), Entries: []Entry{}}
+	for _, e := range t.entries {
+		if e.Uses > 0 {
+			c.Entries = append(c.Entries, *e)
+		} else {
+			c.Unused = append(c.Unused, e.Path)
+		}
+	}
+	sort.Slice(c.Entries, func(i, j int) bool { return c.Entries[i].Path < c.Entries[j].Path })
+	sort.Strings(c.Unused)
+	return c
+}
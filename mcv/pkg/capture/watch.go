@@ -0,0 +1,157 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	watchMask = unix.IN_OPEN | unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO
+	// pollInterval bounds how long a cancelled capture takes to stop.
+	pollInterval = 200 * time.Millisecond
+)
+
+// watcher is an inotify instance watching a directory tree.
+type watcher struct {
+	fd   int
+	dirs map[int]string // Watch descriptor to directory
+	rec  *recorder
+}
+
+// Watch records the cache entries under dir that are written or opened
+// until ctx is done. New directories are watched as they appear. Any other
+// process reading the cache meanwhile, such as a backup, is recorded too.
+func Watch(ctx context.Context, dir string) (*Capture, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if st, err := os.Stat(abs); err != nil || !st.IsDir() {
+		return nil, fmt.Errorf("cache directory %s does not exist", dir)
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	defer unix.Close(fd)
+
+	w := &watcher{fd: fd, dirs: map[int]string{}, rec: newRecorder(abs)}
+	started := time.Now()
+	if err := w.addTree(abs, false); err != nil {
+		return nil, err
+	}
+	logging.Infof("Capturing kernel cache use in %s (%d directories)", abs, len(w.dirs))
+
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-ctx.Done():
+			return w.rec.capture(started), nil
+		default:
+		}
+		n, err := unix.Poll(fds, int(pollInterval.Milliseconds()))
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return nil, fmt.Errorf("failed to poll inotify: %w", err)
+		}
+		if n <= 0 {
+			continue
+		}
+		n, err = unix.Read(fd, buf)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read inotify events: %w", err)
+		}
+		w.handle(buf[:n])
+	}
+}
+
+// handle processes a buffer of inotify events.
+func (w *watcher) handle(buf []byte) {
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+		off += unix.SizeofInotifyEvent + int(ev.Len)
+
+		if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+			diag.Warn(diag.CaptureEventsDropped, "Kernel cache events were dropped; the capture may be incomplete")
+			continue
+		}
+		if ev.Mask&unix.IN_IGNORED != 0 {
+			delete(w.dirs, int(ev.Wd))
+			continue
+		}
+		dir, ok := w.dirs[int(ev.Wd)]
+		if !ok {
+			continue
+		}
+		name := string(nameBytes)
+		for i := 0; i < len(name); i++ {
+			if name[i] == 0 {
+				name = name[:i]
+				break
+			}
+		}
+		if name == "" {
+			continue // Event on the watched directory itself
+		}
+		path := filepath.Join(dir, name)
+
+		if ev.Mask&unix.IN_ISDIR != 0 {
+			if ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				// Files may land in a new directory before it is watched.
+				if err := w.addTree(path, true); err != nil {
+					diag.Warnf(diag.CaptureWatchFailed, "Cannot watch %s: %v", path, err)
+				}
+			}
+			continue
+		}
+		switch {
+		case ev.Mask&(unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO) != 0:
+			w.rec.record(path, true)
+		case ev.Mask&unix.IN_OPEN != 0:
+			w.rec.record(path, false)
+		}
+	}
+}
+
+// addTree watches root and every directory below it. With record, the
+// files already there are recorded as compiled.
+func (w *watcher) addTree(root string, record bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // Removed while walking, e.g. a tmp dir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			if record && d.Type().IsRegular() {
+				w.rec.record(path, true)
+			}
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				return fs.SkipDir
+			}
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		w.dirs[wd] = path
+		return nil
+	})
+}
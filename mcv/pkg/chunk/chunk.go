@@ -0,0 +1,108 @@
+// Package chunk splits large cache files into content-defined chunks so
+// that small changes to a file only produce a few new chunks, and
+// reassembles them on extraction.
+package chunk
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"sort"
+)
+
+const (
+	MinSize = 256 << 10
+	AvgSize = 1 << 20
+	MaxSize = 4 << 20
+
+	// avgBits is log2(AvgSize): a boundary is cut when the top avgBits of
+	// the rolling hash are zero.
+	avgBits = 20
+)
+
+// gear maps each byte to a pseudo-random value for the rolling hash. It is
+// derived deterministically so chunk boundaries are stable across builds.
+var gear [256]uint64
+
+func init() {
+	for i := range gear {
+		sum := sha256.Sum256([]byte{byte(i)})
+		gear[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+}
+
+// Split reads r and calls fn with each content-defined chunk. Boundaries
+// depend only on the preceding 64 bytes, so inserting or changing data in
+// one place leaves the chunks elsewhere in the file unchanged. The slice
+// passed to fn is reused after fn returns.
+func Split(r io.Reader, fn func(data []byte) error) error {
+	br := bufio.NewReaderSize(r, 64<<10)
+	buf := make([]byte, 0, MaxSize)
+	var h uint64
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		buf = append(buf, b)
+		h = (h << 1) + gear[b]
+		if len(buf) >= MaxSize || (len(buf) >= MinSize && h>>(64-avgBits) == 0) {
+			if err := fn(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+			h = 0
+		}
+	}
+	if len(buf) > 0 {
+		return fn(buf)
+	}
+	return nil
+}
+
+// Digest returns the "sha256:<hex>" digest of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Packs groups chunk digests into packs of about target chunks each. The
+// digests are sorted and a pack ends at a digest chosen by its value, so
+// adding or removing a chunk changes only the pack it falls in and the
+// other packs, and the image layers built from them, are reused.
+func Packs(digests []string, target int) [][]string {
+	if target < 1 {
+		target = 1
+	}
+	sorted := append([]string(nil), digests...)
+	sort.Strings(sorted)
+
+	var packs [][]string
+	var cur []string
+	for i, d := range sorted {
+		if i > 0 && d == sorted[i-1] {
+			continue
+		}
+		cur = append(cur, d)
+		if len(cur) >= 4*target || endsPack(d, target) {
+			packs = append(packs, cur)
+			cur = nil
+		}
+	}
+	if len(cur) > 0 {
+		packs = append(packs, cur)
+	}
+	return packs
+}
+
+func endsPack(digest string, target int) bool {
+	raw, err := hex.DecodeString(digest[len(digest)-8:])
+	if err != nil {
+		return false
+	}
+	return binary.BigEndian.Uint32(raw)%uint32(target) == 0
+}
@@ -0,0 +1,132 @@
+package chunk
+
+import (
+	"archive/tar"
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func chunkDigests(t *testing.T, data []byte) []string {
+	var digests []string
+	assert.NoError(t, Split(bytes.NewReader(data), func(c []byte) error {
+		assert.LessOrEqual(t, len(c), MaxSize)
+		digests = append(digests, Digest(c))
+		return nil
+	}))
+	return digests
+}
+
+func TestSplitIsContentDefined(t *testing.T) {
+	data := randomData(12 << 20)
+	before := chunkDigests(t, data)
+	assert.Greater(t, len(before), 2)
+
+	// Patch a few bytes near the start: only the chunks around the edit
+	// should change.
+	edited := append([]byte(nil), data...)
+	copy(edited[100<<10:], "patched kernel")
+	after := chunkDigests(t, edited)
+
+	kept := map[string]bool{}
+	for _, d := range before {
+		kept[d] = true
+	}
+	changed := 0
+	for _, d := range after {
+		if !kept[d] {
+			changed++
+		}
+	}
+	assert.LessOrEqual(t, changed, 2)
+}
+
+func TestPacksAreStable(t *testing.T) {
+	var digests []string
+	for i := 0; i < 500; i++ {
+		digests = append(digests, Digest([]byte{byte(i), byte(i >> 8)}))
+	}
+	packs := Packs(digests, 16)
+	assert.Greater(t, len(packs), 1)
+
+	// Adding a chunk changes one pack at most.
+	grown := Packs(append(digests, Digest([]byte("new"))), 16)
+	old := map[string]bool{}
+	for _, p := range packs {
+		old[filepath.Join(p...)] = true
+	}
+	changed := 0
+	for _, p := range grown {
+		if !old[filepath.Join(p...)] {
+			changed++
+		}
+	}
+	assert.LessOrEqual(t, changed, 2)
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	big := randomData(6 << 20)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "rank_0"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "rank_0", "model.so"), big, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "small.json"), []byte("{}"), 0644))
+
+	storeDir := t.TempDir()
+	idx, err := ChunkFiles(dir, storeDir, 1<<20)
+	assert.NoError(t, err)
+	assert.Len(t, idx.Files, 1)
+	assert.NoFileExists(t, filepath.Join(dir, "rank_0", "model.so"))
+	assert.FileExists(t, filepath.Join(dir, "small.json"))
+
+	// Pack the chunks into a layer as the image builder does.
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for _, d := range idx.Digests() {
+		data, err := os.ReadFile(ChunkPath(storeDir, d))
+		assert.NoError(t, err)
+		name := LayerDir + "/sha256/" + d[len("sha256:"):]
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}))
+		_, err = tw.Write(data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+
+	loaded, err := LoadIndex(dir)
+	assert.NoError(t, err)
+	need := map[string]bool{}
+	for _, d := range loaded.Digests() {
+		need[d] = true
+	}
+	extracted := t.TempDir()
+	assert.NoError(t, CollectChunks(bytes.NewReader(layer.Bytes()), extracted, need))
+	assert.Empty(t, need)
+
+	assert.NoError(t, Reassemble(dir, extracted, loaded))
+	got, err := os.ReadFile(filepath.Join(dir, "rank_0", "model.so"))
+	assert.NoError(t, err)
+	assert.Equal(t, big, got)
+	assert.NoFileExists(t, filepath.Join(dir, IndexFileName))
+}
+
+func TestReassembleDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	storeDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "model.so"), randomData(2<<20), 0644))
+	idx, err := ChunkFiles(dir, storeDir, 1)
+	assert.NoError(t, err)
+
+	first := ChunkPath(storeDir, idx.Files[0].Chunks[0])
+	assert.NoError(t, os.WriteFile(first, []byte("tampered"), 0644))
+	assert.ErrorContains(t, Reassemble(dir, storeDir, idx), "digest mismatch")
+	assert.NoFileExists(t, filepath.Join(dir, "model.so"))
+}
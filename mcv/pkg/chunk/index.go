@@ -0,0 +1,275 @@
+package chunk
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+	"github.com/redhat-et/MCU/mcv/pkg/iolimit"
+)
+
+const (
+	// IndexFileName is the chunk index stored at the root of a chunked
+	// cache directory in place of the files it lists.
+	IndexFileName = ".mcv-chunks.json"
+
+	// LayerDir is where chunks are stored inside image layers, as
+	// LayerDir/sha256/<hex>.
+	LayerDir = "io.mcv.chunks"
+
+	// DefaultThreshold is the file size from which files are chunked.
+	DefaultThreshold = 4 * MaxSize
+
+	indexVersion = 1
+
+	// ChunkedLabel marks images whose cache layer holds a chunk index.
+	ChunkedLabel = "cache.mcv.image/chunked"
+)
+
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// File is a cache file stored as chunks.
+type File struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size"`
+	Digest string      `json:"digest"`
+	Chunks []string    `json:"chunks"`
+}
+
+// Index lists the chunked files of a cache directory.
+type Index struct {
+	Version int    `json:"version"`
+	Files   []File `json:"files"`
+}
+
+// Digests returns the distinct chunk digests referenced by the index.
+func (idx *Index) Digests() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, f := range idx.Files {
+		for _, c := range f.Chunks {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// ChunkPath returns where the chunk with digest is kept under storeDir.
+func ChunkPath(storeDir, digest string) string {
+	algo, hexDigest, _ := strings.Cut(digest, ":")
+	return filepath.Join(storeDir, algo, hexDigest)
+}
+
+// ChunkFiles replaces each regular file under dir of at least threshold
+// bytes with chunks written to storeDir, and writes the index of the
+// replaced files to dir. It returns a nil index when no file qualified.
+func ChunkFiles(dir, storeDir string, threshold int64) (*Index, error) {
+	idx := &Index{Version: indexVersion}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			// Chunk the target, removing only the link.
+			if info, err = os.Stat(p); err != nil {
+				return err
+			}
+		}
+		if !info.Mode().IsRegular() || info.Size() < threshold {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := chunkFile(p, storeDir)
+		if err != nil {
+			return fmt.Errorf("failed to chunk %s: %w", rel, err)
+		}
+		f.Path = filepath.ToSlash(rel)
+		f.Mode = info.Mode().Perm()
+		idx.Files = append(idx.Files, *f)
+		return os.Remove(p)
+	})
+	if err != nil || len(idx.Files) == 0 {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, IndexFileName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write chunk index: %w", err)
+	}
+	return idx, nil
+}
+
+func chunkFile(p, storeDir string) (*File, error) {
+	in, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	whole := sha256.New()
+	f := &File{}
+	err = Split(io.TeeReader(in, whole), func(data []byte) error {
+		digest := Digest(data)
+		f.Chunks = append(f.Chunks, digest)
+		f.Size += int64(len(data))
+		dest := ChunkPath(storeDir, digest)
+		if _, err := os.Stat(dest); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, data, 0644)
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.Digest = "sha256:" + hex.EncodeToString(whole.Sum(nil))
+	return f, nil
+}
+
+// LoadIndex reads the chunk index in dir. It returns nil if dir is not
+// chunked.
+func LoadIndex(dir string) (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(dir, IndexFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	idx := &Index{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("invalid chunk index: %w", err)
+	}
+	if idx.Version != indexVersion {
+		return nil, fmt.Errorf("unsupported chunk index version %d", idx.Version)
+	}
+	for _, f := range idx.Files {
+		for _, c := range f.Chunks {
+			if !digestPattern.MatchString(c) {
+				return nil, fmt.Errorf("invalid chunk digest %q in index", c)
+			}
+		}
+	}
+	return idx, nil
+}
+
+// CollectChunks copies the chunks in need from the layer tar stream r into
+// storeDir, verifying each against its digest. Collected chunks are
+// removed from need.
+func CollectChunks(r io.Reader, storeDir string, need map[string]bool) error {
+	tr := tar.NewReader(r)
+	prefix := LayerDir + "/"
+	for len(need) > 0 {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading chunk layer: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg || !strings.HasPrefix(h.Name, prefix) {
+			continue
+		}
+		digest := strings.Replace(strings.TrimPrefix(h.Name, prefix), "/", ":", 1)
+		if !need[digest] {
+			continue
+		}
+		if err := writeChunk(tr, storeDir, digest); err != nil {
+			return err
+		}
+		delete(need, digest)
+	}
+	return nil
+}
+
+func writeChunk(r io.Reader, storeDir, digest string) error {
+	dest := ChunkPath(storeDir, digest)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, sum), r); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if got := "sha256:" + hex.EncodeToString(sum.Sum(nil)); got != digest {
+		os.Remove(dest)
+		return failure.New(failure.CorruptCache, fmt.Errorf("chunk digest mismatch: expected %s, got %s", digest, got))
+	}
+	return nil
+}
+
+// Reassemble rebuilds the files listed in idx under dir from the chunks in
+// storeDir, verifies each file digest and removes the index.
+func Reassemble(dir, storeDir string, idx *Index) error {
+	for _, f := range idx.Files {
+		dest := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if !strings.HasPrefix(dest, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("chunked file %s escapes the cache directory", f.Path)
+		}
+		if err := reassembleFile(dest, storeDir, f); err != nil {
+			return fmt.Errorf("failed to reassemble %s: %w", f.Path, err)
+		}
+	}
+	return os.Remove(filepath.Join(dir, IndexFileName))
+}
+
+func reassembleFile(dest, storeDir string, f File) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".partial"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	sum := sha256.New()
+	w := io.MultiWriter(iolimit.WrapWriter(out), sum)
+	for _, c := range f.Chunks {
+		in, err := os.Open(ChunkPath(storeDir, c))
+		if err != nil {
+			out.Close()
+			return fmt.Errorf("missing chunk %s: %w", c, err)
+		}
+		_, err = io.Copy(w, in)
+		in.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if got := "sha256:" + hex.EncodeToString(sum.Sum(nil)); got != f.Digest {
+		return failure.New(failure.CorruptCache, fmt.Errorf("digest mismatch: expected %s, got %s", f.Digest, got))
+	}
+	return os.Rename(tmp, dest)
+}
@@ -4,34 +4,78 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/jaypipes/ghw"
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator"
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
 	"github.com/redhat-et/MCU/mcv/pkg/config"
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/cri"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
 	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+	"github.com/redhat-et/MCU/mcv/pkg/hwdiff"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/iolimit"
 	"github.com/redhat-et/MCU/mcv/pkg/logformat"
+	"github.com/redhat-et/MCU/mcv/pkg/placement"
 	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	"github.com/redhat-et/MCU/mcv/pkg/telemetry"
 	logging "github.com/sirupsen/logrus"
 )
 
 // Options encapsulates configurable settings for cache extraction operations.
 type Options struct {
-	ImageName       string // The name of the OCI image (e.g., quay.io/user/image:tag)
-	CacheDir        string // Path to store the cache; for triton defaults to ~/.triton/cache
-	EnableGPU       *bool  // Whether to enable GPU logic (nil = auto-detect, false = disable, true = force)
-	LogLevel        string // Logging level: debug, info, warning, error
-	EnableBaremetal *bool  // If true, enables full hardware checks including kernel dummy key validation (for baremetal envs only)
-	SkipPrecheck    *bool  // If true, skips summary-level preflight GPU compatibility checks
+	ImageName       string        // The name of the OCI image (e.g., quay.io/user/image:tag)
+	CacheDir        string        // Path to store the cache; for triton defaults to ~/.triton/cache
+	EnableGPU       *bool         // Whether to enable GPU logic (nil = auto-detect, false = disable, true = force)
+	LogLevel        string        // Logging level: debug, info, warning, error
+	EnableBaremetal *bool         // If true, enables full hardware checks including kernel dummy key validation (for baremetal envs only)
+	SkipPrecheck    *bool         // If true, skips summary-level preflight GPU compatibility checks
+	Resume          *bool         // If true, resumes an interrupted extraction from its journal
+	Force           *bool         // If true, extracts even if CacheDir's marker shows the image is already there
+	UpdatePin       *bool         // If true, re-pins a tag whose digest changed instead of refusing it
+	ContainerID     string        // If set, extracts into this running container; CacheDir is the path inside it
+	RuntimeEndpoint string        // CRI socket of the container runtime; empty uses crictl's default
+	Placement       string        // If set, a placement config mapping GPUs to cache dirs; replaces CacheDir
+	VLLMKeyMismatch string        // What to do with a vLLM cache built for other versions: refuse, namespace or ignore
+	MountFSImage    *bool         // If true, mounts a filesystem image cache on CacheDir instead of unpacking it
+	FileMode        string        // If set, the octal mode given to every extracted file, e.g. 0444
+	DirMode         string        // If set, the octal mode given to every extracted directory, e.g. 0555
+	Owner           string        // If set, the user[:group] given the extracted cache, by name or ID
+	ExpiredPolicy   string        // What to do with a cache image past its valid-until date: warn, block or ignore
+	SharedLock      string        // When to lock CacheDir against other nodes sharing it: auto, on or off
+	LockTimeout     time.Duration // If set, how long to wait for another node's extraction into CacheDir
+	SignaturePolicy string        // policy.json VerifyAndExtract checks signatures against; empty uses the host's
+	ReadyFile       string        // If set, written with "hot" once the hot kernels are extracted, then "complete"
+	EntryErrors     string        // What to do with a cache entry that cannot be extracted: fail, or skip and report it
+	Nice            int           // If set, the nice value to extract with, e.g. 10
+	IONice          string        // If set, the I/O priority to extract with, as class[:level], e.g. idle or best-effort:7
+	WriteLimit      float64       // If set, the MB/s to write extracted files at
+	Store           string        // If set, where to write the cache: dir, tmpfs, s3 or gcs
+	TmpfsSize       int64         // If set, the bytes of the tmpfs the tmpfs store mounts
+	Bucket          string        // If set, the bucket[/prefix] the s3 and gcs stores upload to
+	BucketEndpoint  string        // If set, the URL of the S3-compatible service to upload to, e.g. a MinIO server
+	BucketMount     string        // If set, where consumers see the bucket prefix; embedded paths are resolved to it
+	Kernels         string        // Which kernels to restore: all, or only those compatible with the GPUs
 }
 
-// xPU wraps CPU and GPU info
+// xPU wraps CPU and GPU info along with the host facts driver and toolkit
+// compatibility depend on.
 type xPU struct {
-	CPU *ghw.CPUInfo
-	Acc *ghw.AcceleratorInfo
+	CPU  *ghw.CPUInfo
+	Acc  *ghw.AcceleratorInfo
+	Host *hostinfo.Info
+	GPUs []devices.GPUDevice // Per-GPU details from the GPU backend, if loaded
 }
 
 // detectAccelerators detects hardware accelerators and enables GPU logic if supported hardware is found.
@@ -45,49 +89,134 @@ func detectAccelerators() error {
 }
 
 // GetXPUInfo returns combined CPU and accelerator information (e.g., GPUs,
-// FPGAs) for the current system using the ghw library. Used for diagnostics
-// or --hw-info output.
+// FPGAs) for the current system using the ghw library, plus host OS, kernel,
+// cgroup, container runtime and hypervisor facts. Used for diagnostics or
+// --hw-info output.
 func GetXPUInfo() (*xPU, error) {
 	cpuInfo, accInfo, err := devices.GetSystemHW()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get hardware info: %w", err)
 	}
 	return &xPU{
-		CPU: cpuInfo,
-		Acc: accInfo,
+		CPU:  cpuInfo,
+		Acc:  accInfo,
+		Host: hostinfo.Get(),
 	}, nil
 }
 
-// PrintXPUInfo logs or prints system CPU and accelerator (GPU) info
-// in a human-readable format for CLI users.
-func PrintXPUInfo(xpu *xPU) {
-	fmt.Println("=== CPU Information ===")
-	for _, proc := range xpu.CPU.Processors {
-		fmt.Printf("Vendor: %s, Model: %s, Cores: %d, Threads: %d\n",
-			proc.Vendor, proc.Model, proc.NumCores, proc.NumThreads)
+// GetXPUDetails returns GetXPUInfo plus the model, driver, memory and
+// architecture of each GPU as reported by the GPU backend. When no backend
+// can be started the GPU details are left empty.
+func GetXPUDetails() (*xPU, error) {
+	xpu, err := GetXPUInfo()
+	if err != nil {
+		return nil, err
 	}
-
-	fmt.Println("\n=== Accelerator Information ===")
 	if xpu.Acc == nil || len(xpu.Acc.Devices) == 0 {
-		fmt.Println("No Accelerator detected.")
-	} else {
-		for i, device := range xpu.Acc.Devices {
-			fmt.Printf("Accelerator %d:\n", i)
-			fmt.Printf("  Address: %s\n", device.Address)
-			if device.PCIDevice != nil {
-				fmt.Printf("  Vendor: %s\n", device.PCIDevice.Vendor.Name)
-				fmt.Printf("  Product: %s\n", device.PCIDevice.Product.Name)
-			} else {
-				fmt.Println("  PCI device information unavailable")
-			}
-		}
+		return xpu, nil
+	}
+	gpus, err := getGPUDevices()
+	if err != nil {
+		logging.WithError(err).Debug("GPU details unavailable, listing PCI devices only")
+		return xpu, nil
+	}
+	xpu.GPUs = gpus
+	return xpu, nil
+}
+
+// TakeHWSnapshot returns the current state of the host's GPUs and drivers,
+// for hw-diff. Hosts whose GPUs cannot be detected, e.g. because the
+// driver is gone, have none in the snapshot.
+func TakeHWSnapshot() hwdiff.Snapshot {
+	gpus, err := getGPUDevices()
+	if err != nil {
+		logging.WithError(err).Debug("No GPUs in the hardware snapshot")
+	}
+	return hwdiff.Take(hostinfo.Get(), gpus, time.Now())
+}
+
+// getGPUDevices collects the Triton info and summary of every GPU.
+func getGPUDevices() ([]devices.GPUDevice, error) {
+	acc, err := accelerator.New(config.GPU, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GPU accelerator: %w", err)
+	}
+	accelerator.GetRegistry().MustRegister(acc)
+
+	dev := acc.Device()
+	infos, err := dev.GetAllGPUInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU info: %w", err)
+	}
+	summaries, err := dev.GetAllSummaries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU summaries: %w", err)
+	}
+	byID := make(map[string]devices.DeviceSummary, len(summaries))
+	for _, s := range summaries {
+		byID[s.ID] = s
+	}
+
+	gpus := make([]devices.GPUDevice, 0, len(infos))
+	for _, info := range infos {
+		gpus = append(gpus, devices.GPUDevice{
+			ID:         info.ID,
+			TritonInfo: info,
+			Summary:    byID[strconv.Itoa(info.ID)],
+		})
 	}
+	return gpus, nil
 }
 
 // ExtractCache pulls and extracts a kernel cache from the specified OCI image.
 // It uses the provided options to configure behavior such as GPU checks, logging, and
 // output directory. If GPU checks are enabled, it also verifies hardware compatibility.
+// When a telemetry endpoint is configured, anonymized statistics of the
+// extraction are sent to it. Once the cache is extracted, it is reported
+// ready in the ready file, if one is configured. With the skip entry error
+// policy, a *fetcher.PartialError lists the entries that were not
+// extracted; the rest of the cache is ready.
 func ExtractCache(opts Options) (matchedIDs, unmatchedIDs []int, err error) {
+	return ExtractCacheContext(context.Background(), opts)
+}
+
+// ExtractCacheContext is ExtractCache stopping once ctx is done. The
+// extraction stops between the entries of a layer and keeps its journal,
+// so that calling it again with opts.Resume set picks up where it stopped.
+func ExtractCacheContext(ctx context.Context, opts Options) (matchedIDs, unmatchedIDs []int, err error) {
+	start := time.Now()
+	matchedIDs, unmatchedIDs, err = extractCache(ctx, opts)
+	reportExtraction(opts, time.Since(start), err)
+	if _, partial := fetcher.IsPartial(err); err == nil || partial {
+		fetcher.SignalReady(fetcher.ReadyComplete)
+	}
+	return matchedIDs, unmatchedIDs, err
+}
+
+// reportExtraction sends the telemetry of an extraction, if enabled. The
+// size of the cache is left out when it was extracted into a container or
+// per placement, as it is then not in one directory on this host.
+func reportExtraction(opts Options, d time.Duration, err error) {
+	endpoint := config.TelemetryEndpoint()
+	if endpoint == "" {
+		return
+	}
+	cacheDir := ""
+	if opts.ContainerID == "" && opts.Placement == "" {
+		cacheDir = constants.ExtractCacheDir
+	}
+	var gpus []devices.TritonGPUInfo
+	if config.IsGPUEnabled() {
+		if devs, gerr := getGPUDevices(); gerr == nil {
+			for _, g := range devs {
+				gpus = append(gpus, g.TritonInfo)
+			}
+		}
+	}
+	telemetry.Report(endpoint, telemetry.NewExtraction(err == nil, d, cacheDir, gpus))
+}
+
+func extractCache(ctx context.Context, opts Options) (matchedIDs, unmatchedIDs []int, err error) {
 	if opts.ImageName == "" {
 		return nil, nil, fmt.Errorf("image name must be specified")
 	}
@@ -96,13 +225,17 @@ func ExtractCache(opts Options) (matchedIDs, unmatchedIDs []int, err error) {
 		return nil, nil, fmt.Errorf("failed to initialize config: %w", err)
 	}
 
+	if err = checkImageRef(opts.ImageName); err != nil {
+		return nil, nil, err
+	}
+
 	if err = logformat.ConfigureLogging(opts.LogLevel); err != nil {
-		return nil, nil, fmt.Errorf("error configuring logging: %v", err)
+		return nil, nil, fmt.Errorf("error configuring logging: %w", err)
 	}
 
 	// Auto-detect accelerator hardware if GPU is not already enabled
 	if err = detectAccelerators(); err != nil {
-		logging.Warn("No accelerators detected, GPU logic disabled.")
+		diag.Warn(diag.NoAccelerator, "No accelerators detected, GPU logic disabled.")
 	}
 
 	if opts.SkipPrecheck != nil {
@@ -119,6 +252,123 @@ func ExtractCache(opts Options) (matchedIDs, unmatchedIDs []int, err error) {
 		}
 	}
 
+	if opts.Resume != nil {
+		config.SetResumeExtract(*opts.Resume)
+	}
+
+	if opts.Force != nil {
+		config.SetForceExtract(*opts.Force)
+	}
+
+	if opts.UpdatePin != nil {
+		config.SetUpdatePin(*opts.UpdatePin)
+	}
+
+	if opts.MountFSImage != nil {
+		config.SetMountFSImage(*opts.MountFSImage)
+	}
+
+	if opts.VLLMKeyMismatch != "" {
+		config.SetVLLMKeyMismatch(opts.VLLMKeyMismatch)
+	}
+	if err := fetcher.ValidateVLLMKeyPolicy(config.VLLMKeyMismatch()); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.ExpiredPolicy != "" {
+		config.SetExpiredPolicy(opts.ExpiredPolicy)
+	}
+	if err := fetcher.ValidateExpiredPolicy(config.ExpiredPolicy()); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.SharedLock != "" {
+		config.SetSharedLock(opts.SharedLock)
+	}
+	if err := fetcher.ValidateSharedLockMode(config.SharedLock()); err != nil {
+		return nil, nil, err
+	}
+	if opts.LockTimeout > 0 {
+		config.SetSharedLockWait(opts.LockTimeout)
+	}
+
+	if opts.EntryErrors != "" {
+		config.SetEntryErrors(opts.EntryErrors)
+	}
+	if err := cache.ValidateEntryErrorPolicy(config.EntryErrors()); err != nil {
+		return nil, nil, err
+	}
+
+	if err := applyPriority(opts); err != nil {
+		return nil, nil, err
+	}
+
+	store, tmpfsSize := config.CacheStore()
+	if opts.Store != "" {
+		store = opts.Store
+	}
+	if opts.TmpfsSize != 0 {
+		tmpfsSize = opts.TmpfsSize
+	}
+	if err := cache.ValidateStoreKind(store); err != nil {
+		return nil, nil, err
+	}
+	if store == cache.StoreTmpfs && (opts.ContainerID != "" || config.IsMountFSImageEnabled()) {
+		return nil, nil, fmt.Errorf("the tmpfs store cannot be used to extract into a container or to mount a filesystem image")
+	}
+	config.SetCacheStore(store, tmpfsSize)
+
+	bucket, endpoint, mount := config.Bucket()
+	if opts.Bucket != "" {
+		bucket = opts.Bucket
+	}
+	if opts.BucketEndpoint != "" {
+		endpoint = opts.BucketEndpoint
+	}
+	if opts.BucketMount != "" {
+		mount = opts.BucketMount
+	}
+	if cache.IsObjectStore(store) {
+		if bucket == "" {
+			return nil, nil, fmt.Errorf("the %s store needs a bucket", store)
+		}
+		if opts.ContainerID != "" || opts.Placement != "" || config.IsMountFSImageEnabled() {
+			return nil, nil, fmt.Errorf("the %s store cannot be used to extract into a container, with a placement or to mount a filesystem image", store)
+		}
+	}
+	config.SetBucket(bucket, endpoint, mount)
+
+	if opts.Kernels != "" {
+		config.SetKernels(opts.Kernels)
+	}
+	if err := fetcher.ValidateKernelPolicy(config.Kernels()); err != nil {
+		return nil, nil, err
+	}
+	if config.Kernels() == fetcher.KernelsCompatible && !config.IsGPUEnabled() {
+		return nil, nil, fmt.Errorf("restoring only the compatible kernels needs GPUs to check them against")
+	}
+
+	if opts.ReadyFile != "" {
+		config.SetReadyFile(opts.ReadyFile)
+	}
+	fetcher.ClearReady()
+
+	fileMode, dirMode, owner := config.ExtractPermissions()
+	if opts.FileMode != "" {
+		fileMode = opts.FileMode
+	}
+	if opts.DirMode != "" {
+		dirMode = opts.DirMode
+	}
+	if opts.Owner != "" {
+		owner = opts.Owner
+	}
+	config.SetExtractPermissions(fileMode, dirMode, owner)
+	perms, err := fetcher.ExtractPermissions()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// If caller asked to skip preflight, do not run it here or downstream.
 	// Otherwise, run it ONCE here, and then set SkipPrecheck=true so downstream won’t repeat it.
 	shouldRunPreflight := config.IsGPUEnabled() && !config.IsSkipPrecheckEnabled()
@@ -139,6 +389,15 @@ func ExtractCache(opts Options) (matchedIDs, unmatchedIDs []int, err error) {
 		logging.Debug("Skipping preflight (GPU disabled)")
 	}
 
+	// Both change the cache once it is extracted, so it is ready only then.
+	if opts.ContainerID != "" {
+		return matchedIDs, unmatchedIDs, fetcher.WithoutHotReady(func() error { return extractIntoContainer(ctx, opts, perms) })
+	}
+
+	if opts.Placement != "" {
+		return matchedIDs, unmatchedIDs, fetcher.WithoutHotReady(func() error { return extractPlacements(ctx, opts, perms) })
+	}
+
 	if opts.CacheDir != "" {
 		cacheDir := opts.CacheDir
 		if err := os.MkdirAll(cacheDir, 0755); err != nil {
@@ -147,7 +406,159 @@ func ExtractCache(opts Options) (matchedIDs, unmatchedIDs []int, err error) {
 		constants.ExtractCacheDir = cacheDir
 	}
 
-	return nil, nil, fetcher.New().FetchAndExtractCache(opts.ImageName)
+	return nil, nil, fetcher.New().FetchAndExtractCacheContext(ctx, opts.ImageName)
+}
+
+// applyPriority lowers the CPU and I/O priority of the extraction and
+// throttles its writes, as set in opts or the config, so that it does not
+// slow down the workloads on the host.
+func applyPriority(opts Options) error {
+	nice, ioNice, writeLimit := config.ExtractPriority()
+	if opts.Nice != 0 {
+		nice = opts.Nice
+	}
+	if opts.IONice != "" {
+		ioNice = opts.IONice
+	}
+	if opts.WriteLimit != 0 {
+		writeLimit = opts.WriteLimit
+	}
+	if writeLimit < 0 {
+		return fmt.Errorf("invalid write limit %g MB/s: must not be negative", writeLimit)
+	}
+	config.SetExtractPriority(nice, ioNice, writeLimit)
+
+	ioPrio, err := iolimit.ParseIOPriority(ioNice)
+	if err != nil {
+		return err
+	}
+	if err := iolimit.SetPriority(nice, ioPrio); err != nil {
+		return err
+	}
+	iolimit.SetWriteLimit(writeLimit)
+	if nice != 0 || ioNice != "" || writeLimit > 0 {
+		logging.Debugf("Extracting with nice %d, I/O priority %q and write limit %g MB/s", nice, ioNice, writeLimit)
+	}
+	return nil
+}
+
+// checkImageRef rejects a malformed digest in imageName and, in
+// digest-only mode, a reference without a digest.
+func checkImageRef(imageName string) error {
+	if err := imgref.ValidateDigest(imageName); err != nil {
+		return err
+	}
+	if config.IsDigestOnlyEnabled() {
+		return imgref.RequireDigest(imageName)
+	}
+	return nil
+}
+
+// extractIntoContainer extracts the cache into opts.CacheDir inside the
+// running container opts.ContainerID, through the container's root on the
+// host, and gives the new files to the owner of the directory they were
+// added to so the container's user can read and update them, unless perms
+// names another owner.
+func extractIntoContainer(ctx context.Context, opts Options, perms cache.Permissions) error {
+	if opts.CacheDir == "" {
+		return fmt.Errorf("a cache dir inside the container is required to extract into a container")
+	}
+	c, err := cri.Inspect(context.Background(), opts.RuntimeEndpoint, opts.ContainerID)
+	if err != nil {
+		return err
+	}
+	cacheDir, err := c.HostPath(opts.CacheDir)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := cri.Owner(cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to get the owner of %s in container %s: %w", opts.CacheDir, c.ID, err)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir in container %s: %w", c.ID, err)
+	}
+	logging.Infof("Extracting into container %s (%s) at %s", c.ID, c.Name, opts.CacheDir)
+	constants.ExtractCacheDir = cacheDir
+	if config.VLLMKeyMismatch() != fetcher.VLLMKeyIgnore {
+		// The host's vLLM installation says nothing about the container's.
+		logging.Info("Not checking the vLLM cache key against the container's installation")
+		config.SetVLLMKeyMismatch(fetcher.VLLMKeyIgnore)
+	}
+
+	// What was extracted of a partial extraction is given to the owner too.
+	extractErr := fetcher.New().FetchAndExtractCacheContext(ctx, opts.ImageName)
+	if _, partial := fetcher.IsPartial(extractErr); extractErr != nil && !partial {
+		return extractErr
+	}
+	if perms.HasOwner() {
+		return extractErr
+	}
+	if err := cri.Chown(cacheDir, uid, gid); err != nil {
+		return fmt.Errorf("failed to give the extracted cache to %d:%d: %w", uid, gid, err)
+	}
+	return extractErr
+}
+
+// extractPlacements extracts the cache into every directory of the
+// placement config opts.Placement, then removes from each directory the
+// kernels none of its GPUs can run. perms is applied after pruning, since
+// the directory modes may deny removing kernels, to the kernels extracted
+// only. The entries skipped in every directory are returned together in a
+// *fetcher.PartialError.
+func extractPlacements(ctx context.Context, opts Options, perms cache.Permissions) error {
+	f, err := placement.Load(opts.Placement)
+	if err != nil {
+		return err
+	}
+	if !config.IsGPUEnabled() {
+		return fmt.Errorf("placement needs GPU detection, which is disabled")
+	}
+	acc, err := accelerator.New(config.GPU, true)
+	if err != nil {
+		return fmt.Errorf("failed to initialize GPU accelerator: %w", err)
+	}
+	accelerator.GetRegistry().MustRegister(acc)
+	devInfo, err := preflightcheck.GetAllGPUInfo(acc)
+	if err != nil {
+		return fmt.Errorf("failed to get system GPU info: %w", err)
+	}
+	config.SetExtractPermissions("", "", "")
+
+	var skipped []cache.SkippedEntry
+	for _, p := range f.Placements {
+		gpus := p.Select(devInfo, devices.TritonGPUInfo.NUMANode)
+		if len(gpus) == 0 {
+			diag.Warnf(diag.NoGPUForPlacement, "No GPUs on this host for placement %s, skipping", p.Dir)
+			continue
+		}
+		if err := os.MkdirAll(p.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cache dir: %w", err)
+		}
+		logging.Infof("Extracting for GPUs %v to %s", extractGPUIDs(gpus), p.Dir)
+		constants.ExtractCacheDir = p.Dir
+		if err := fetcher.New().FetchAndExtractCacheContext(ctx, opts.ImageName); err != nil {
+			entries, partial := fetcher.IsPartial(err)
+			if !partial {
+				return fmt.Errorf("extraction to %s failed: %w", p.Dir, err)
+			}
+			skipped = append(skipped, entries...)
+		}
+		removed, err := cache.PruneTritonKernels(p.Dir, func(t cache.Target) bool { return placement.RunsOn(t, gpus) })
+		if err != nil {
+			return fmt.Errorf("failed to prune %s: %w", p.Dir, err)
+		}
+		if len(removed) > 0 {
+			logging.Infof("Removed %d kernel(s) from %s that GPUs %v cannot run", len(removed), p.Dir, extractGPUIDs(gpus))
+		}
+		if err := perms.ApplyEntries(fetcher.ExtractedEntries()); err != nil {
+			return err
+		}
+	}
+	if len(skipped) > 0 {
+		return &fetcher.PartialError{Skipped: skipped}
+	}
+	return nil
 }
 
 // GetSystemGPUInfo returns a summary of GPU devices with information
@@ -186,6 +597,49 @@ func GetSystemGPUInfo() (*devices.GPUFleetSummary, error) {
 	return summary, nil
 }
 
+// GetGPUInfoByUUID returns the GPU with the given UUID, as NVML (with or
+// without its GPU- prefix) or ROCm reports it. Orchestration layers refer
+// to GPUs this way rather than by enumeration order, which differs between
+// tools and can change across reboots.
+func GetGPUInfoByUUID(uuid string) (*devices.GPUDevice, error) {
+	return findGPUDevice(func(dev devices.Device) (devices.TritonGPUInfo, error) {
+		return devices.GetGPUInfoByUUID(dev, uuid)
+	})
+}
+
+// GetGPUInfoByPCIAddress returns the GPU at the given PCI address, such as
+// 0000:3b:00.0.
+func GetGPUInfoByPCIAddress(bdf string) (*devices.GPUDevice, error) {
+	return findGPUDevice(func(dev devices.Device) (devices.TritonGPUInfo, error) {
+		return devices.GetGPUInfoByPCIAddress(dev, bdf)
+	})
+}
+
+func findGPUDevice(lookup func(devices.Device) (devices.TritonGPUInfo, error)) (*devices.GPUDevice, error) {
+	if _, err := config.Initialize(config.ConfDir); err != nil {
+		return nil, fmt.Errorf("failed to initialize config: %w", err)
+	}
+	if err := detectAccelerators(); err != nil {
+		return nil, err
+	}
+	acc, err := accelerator.New(config.GPU, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GPU accelerator: %w", err)
+	}
+	accelerator.GetRegistry().MustRegister(acc)
+
+	dev := acc.Device()
+	info, err := lookup(dev)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := dev.GetSummary(info.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU summary: %w", err)
+	}
+	return &devices.GPUDevice{ID: info.ID, TritonInfo: info, Summary: summary}, nil
+}
+
 // PrintGPUSummary prints the fleet summary in a human-friendly form.
 func PrintGPUSummary(summary *devices.GPUFleetSummary) {
 	if summary == nil || len(summary.GPUs) == 0 {
@@ -197,6 +651,9 @@ func PrintGPUSummary(summary *devices.GPUFleetSummary) {
 	for _, g := range summary.GPUs {
 		fmt.Printf("  - GPU Type: %s\n", g.GPUType)
 		fmt.Printf("    Driver Version: %s\n", g.DriverVersion)
+		if g.Virtualization != "" {
+			fmt.Printf("    Virtualization: %s (%s)\n", g.Virtualization, g.Profile)
+		}
 		fmt.Printf("    IDs: %v\n", g.IDs)
 	}
 }
@@ -204,6 +661,9 @@ func PrintGPUSummary(summary *devices.GPUFleetSummary) {
 // PreflightCheck performs a compatibility check between the system’s detected GPUs
 // and the image’s embedded metadata (via summary label). This is a lightweight check
 // (label-only) intended to quickly identify supported GPUs for a given image.
+// Results are cached per image digest, GPU set and driver versions for
+// config.PreflightTTL(), so repeated checks on a node skip fetching the
+// image metadata, unless config.IsNoPreflightCacheEnabled().
 //
 // Returns slices of matched and unmatched GPUs, along with any error encountered.
 func PreflightCheck(imageName string) (matchedIDs, unmatchedIDs []int, err error) {
@@ -211,6 +671,10 @@ func PreflightCheck(imageName string) (matchedIDs, unmatchedIDs []int, err error
 		return nil, nil, fmt.Errorf("failed to initialize config: %w", err)
 	}
 
+	if err = checkImageRef(imageName); err != nil {
+		return nil, nil, err
+	}
+
 	// Initialize the GPU accelerator
 	acc, err := accelerator.New(config.GPU, true)
 	if err != nil {
@@ -225,6 +689,19 @@ func PreflightCheck(imageName string) (matchedIDs, unmatchedIDs []int, err error
 		return nil, nil, fmt.Errorf("failed to get system GPU info: %w", err)
 	}
 
+	// Reuse a recent result for the same image digest, GPUs and drivers
+	if config.IsNoPreflightCacheEnabled() {
+		logging.Debugf("Not using cached preflight results for %s", imageName)
+	} else if digest, derr := fetcher.ResolveDigest(imageName); derr != nil {
+		logging.Debugf("Not using preflight cache for %s: %v", imageName, derr)
+	} else if cached, ok := preflightcheck.LookupResult(config.PreflightCache(), digest, devInfo, config.PreflightTTL()); ok {
+		logging.Debugf("Using cached preflight result for %s", digest)
+		if len(cached.Matched) == 0 {
+			return nil, nil, failure.New(failure.Incompatible, errors.New("preflight check failed: no compatible GPU found (cached result)"))
+		}
+		return cached.Matched, cached.Unmatched, nil
+	}
+
 	// Fetch the image
 	img, err := fetcher.NewImgFetcher().FetchImg(imageName)
 	if err != nil {
@@ -233,14 +710,23 @@ func PreflightCheck(imageName string) (matchedIDs, unmatchedIDs []int, err error
 
 	// Run the compatibility check
 	matched, unmatched, err := preflightcheck.CompareCacheSummaryLabelToGPU(img, nil, devInfo)
-	if err != nil {
-		return nil, nil, fmt.Errorf("preflight check failed: %w", err)
-	}
 
 	// Convert matched/unmatched TritonGPUInfo slices into GPU IDs
 	matchedIDs = extractGPUIDs(matched)
 	unmatchedIDs = extractGPUIDs(unmatched)
 
+	// Only cache real outcomes, not failures to read the image labels
+	if err == nil || len(unmatched) > 0 {
+		if digest, derr := img.Digest(); derr == nil {
+			if serr := preflightcheck.StoreResult(config.PreflightCache(), digest.String(), devInfo, matchedIDs, unmatchedIDs, config.PreflightTTL()); serr != nil {
+				diag.Warnf(diag.PreflightCacheFailed, "Failed to cache preflight result: %v", serr)
+			}
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+
 	logging.Info("Preflight GPU compatibility check passed.")
 	return matchedIDs, unmatchedIDs, nil
 }
@@ -252,3 +738,56 @@ func extractGPUIDs(infos []devices.TritonGPUInfo) []int {
 	}
 	return ids
 }
+
+// GetHostReport returns this host's GPUs, GPU targets and host facts, and,
+// if imageName is set, whether the GPUs can use the image. A failed
+// compatibility check is recorded in the report rather than returned, so
+// fleet checks can tell incompatible hosts from unreachable ones. Hosts with
+// GPU support disabled report no GPUs.
+func GetHostReport(imageName string) (*fleet.HostReport, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	report := &fleet.HostReport{
+		Hostname: hostname,
+		Host:     hostinfo.Get(),
+		Image:    imageName,
+	}
+	if config.IsBaremetalEnabled() {
+		report.HostChecks = preflightcheck.RunBaremetalChecks().Checks
+	}
+	if !config.IsGPUEnabled() {
+		if imageName != "" {
+			report.Error = "GPU support is disabled"
+		}
+		return report, nil
+	}
+
+	summary, err := GetSystemGPUInfo()
+	if err != nil {
+		return nil, err
+	}
+	acc, err := accelerator.New(config.GPU, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GPU accelerator: %w", err)
+	}
+	accelerator.GetRegistry().MustRegister(acc)
+	devInfo, err := preflightcheck.GetAllGPUInfo(acc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system GPU info: %w", err)
+	}
+	report.GPUs = summary.GPUs
+	report.Targets = fleet.Targets(devInfo)
+	report.Devices = devInfo
+	if imageName == "" {
+		return report, nil
+	}
+
+	report.Matched, report.Unmatched, err = PreflightCheck(imageName)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Compatible = len(report.Matched) > 0
+	return report, nil
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	"github.com/redhat-et/MCU/mcv/pkg/sigverify"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Steps of VerifyAndExtract, in the order they run.
+const (
+	StepPreflight = "preflight"
+	StepSignature = "signature"
+	StepExtract   = "extract"
+)
+
+// Step statuses.
+const (
+	StepPassed  = "passed"
+	StepFailed  = "failed"
+	StepSkipped = "skipped"
+	StepPartial = "partial" // Extracted, but for the cache entries it skipped
+)
+
+// StepResult is the outcome of one step of VerifyAndExtract.
+type StepResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Detail  string  `json:"detail,omitempty"`
+	Seconds float64 `json:"seconds"`
+}
+
+// CompositeReport is the outcome of VerifyAndExtract.
+type CompositeReport struct {
+	Image     string       `json:"image"`
+	Digest    string       `json:"digest,omitempty"`
+	Matched   []int        `json:"matched"`
+	Unmatched []int        `json:"unmatched"`
+	Steps     []StepResult `json:"steps"`
+	Success   bool         `json:"success"`
+	// HostChecks are the results of the baremetal host probes, with
+	// --baremetal.
+	HostChecks []preflightcheck.CheckResult `json:"hostChecks,omitempty"`
+	// Skipped are the cache entries the extraction skipped, with the skip
+	// entry error policy.
+	Skipped []cache.SkippedEntry `json:"skipped,omitempty"`
+}
+
+// VerifyAndExtract checks in one process that the host's GPUs can use
+// opts.ImageName and that the image passes the signature policy in
+// opts.SignaturePolicy, then, if extract is set, extracts it. It stops at
+// the first step that fails, marking the rest skipped. The image is pinned
+// to the digest resolved up front, so the image extracted is the one
+// checked even if its tag moves meanwhile.
+func VerifyAndExtract(opts Options, extract bool) *CompositeReport {
+	rep := &CompositeReport{Image: opts.ImageName, Matched: []int{}, Unmatched: []int{}}
+
+	type step struct {
+		name string
+		run  func() (string, error)
+	}
+	steps := []step{
+		{StepPreflight, func() (string, error) { return rep.preflight(opts) }},
+		{StepSignature, func() (string, error) { return rep.verifySignature(opts) }},
+	}
+	if extract {
+		steps = append(steps, step{StepExtract, func() (string, error) { return rep.extract(opts) }})
+	}
+
+	defer func() {
+		if rep.Digest != "" {
+			config.SetExpectedDigest("")
+		}
+	}()
+	rep.Success = true
+	for _, s := range steps {
+		if !rep.Success {
+			rep.Steps = append(rep.Steps, StepResult{Name: s.name, Status: StepSkipped})
+			continue
+		}
+		start := time.Now()
+		detail, err := s.run()
+		res := StepResult{Name: s.name, Status: StepPassed, Detail: detail, Seconds: time.Since(start).Round(time.Millisecond).Seconds()}
+		if skipped, ok := fetcher.IsPartial(err); ok {
+			res.Status, res.Detail = StepPartial, err.Error()
+			rep.Skipped = skipped
+			diag.Warnf(diag.PartialExtraction, "%s partially succeeded: %v", s.name, err)
+		} else if err != nil {
+			res.Status, res.Detail = StepFailed, err.Error()
+			rep.Success = false
+			logging.Errorf("%s failed: %v", s.name, err)
+		} else {
+			logging.Infof("%s passed", s.name)
+		}
+		rep.Steps = append(rep.Steps, res)
+	}
+	return rep
+}
+
+// preflight requires at least one of the host's GPUs to be compatible
+// with the image, and records the image's digest.
+func (rep *CompositeReport) preflight(opts Options) (string, error) {
+	if opts.ImageName == "" {
+		return "", fmt.Errorf("image name must be specified")
+	}
+	if _, err := config.Initialize(config.ConfDir); err != nil {
+		return "", fmt.Errorf("failed to initialize config: %w", err)
+	}
+	if err := checkImageRef(opts.ImageName); err != nil {
+		return "", err
+	}
+	digest, err := fetcher.ResolveDigest(opts.ImageName)
+	if err != nil {
+		return "", err
+	}
+	rep.Digest = digest
+	config.SetExpectedDigest(digest)
+
+	if opts.EnableBaremetal != nil && *opts.EnableBaremetal {
+		host := preflightcheck.RunBaremetalChecks()
+		rep.HostChecks = host.Checks
+		if host.Failed() {
+			return "", errors.New("baremetal host preflight checks failed")
+		}
+	}
+
+	if !config.IsGPUEnabled() {
+		return "", fmt.Errorf("GPU support is disabled, compatibility cannot be checked")
+	}
+	matched, unmatched, err := PreflightCheck(opts.ImageName)
+	if err != nil {
+		return "", err
+	}
+	rep.Matched, rep.Unmatched = nonNil(matched), nonNil(unmatched)
+	if len(matched) == 0 {
+		return "", fmt.Errorf("no compatible GPU found")
+	}
+	return fmt.Sprintf("%d of %d GPU(s) compatible", len(matched), len(matched)+len(unmatched)), nil
+}
+
+func (rep *CompositeReport) verifySignature(opts Options) (string, error) {
+	if imgref.IsLayout(opts.ImageName) {
+		return "", fmt.Errorf("signatures are kept in registries, %s cannot be verified", opts.ImageName)
+	}
+	ref, err := name.ParseReference(opts.ImageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image name: %w", err)
+	}
+	pinned := ref.Context().Digest(rep.Digest).String()
+	ctx, cancel := registry.Context(context.Background(), registry.OpVerify)
+	defer cancel()
+	if err := sigverify.Verify(ctx, pinned, opts.SignaturePolicy); err != nil {
+		return "", registry.TimeoutError(ctx, err)
+	}
+	return pinned, nil
+}
+
+// extract extracts the image without running the preflight check again.
+// Fetching refuses the image if its digest is no longer the one checked.
+func (rep *CompositeReport) extract(opts Options) (string, error) {
+	skip := true
+	opts.SkipPrecheck = &skip
+	if _, _, err := ExtractCache(opts); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+func nonNil(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+)
+
+// CheckHostCompat checks imageName against the GPUs and CPU architecture
+// in rep, the report mcv host-report printed on another host, instead of
+// this host's. Only the image's manifest and config are pulled, here
+// rather than on that host. Every image of an index is checked, as this
+// host's platform says nothing about the other's; a single image gives a
+// single result.
+func CheckHostCompat(imageName string, rep *fleet.HostReport) ([]PlatformCompat, error) {
+	if _, err := config.Initialize(config.ConfDir); err != nil {
+		return nil, fmt.Errorf("failed to initialize config: %w", err)
+	}
+	if err := checkImageRef(imageName); err != nil {
+		return nil, err
+	}
+	if rep.Host == nil || rep.Host.CPUArch == "" {
+		return nil, errors.New("host report has no CPU architecture")
+	}
+	if len(rep.Devices) == 0 && len(rep.GPUs) > 0 {
+		return nil, errors.New("host report lists GPUs without their devices, upgrade mcv on the host")
+	}
+	arch := rep.Host.CPUArch
+
+	idx, err := fetcher.FetchIndex(imageName)
+	if err != nil {
+		return nil, err
+	}
+	if idx != nil {
+		return checkIndex(idx, rep.Devices, arch)
+	}
+
+	img, err := fetcher.FetchMetadata(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	var res PlatformCompat
+	if digest, err := img.Digest(); err == nil {
+		res.Digest = digest.String()
+	}
+	if err := checkImage(&res, img, rep.Devices, arch); err != nil {
+		return nil, err
+	}
+	return []PlatformCompat{res}, nil
+}
@@ -0,0 +1,273 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	ghwaccel "github.com/jaypipes/ghw/pkg/accelerator"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+)
+
+// deviceGroup is one row of the grouped accelerator table.
+type deviceGroup struct {
+	model   string
+	driver  string
+	memory  string
+	arch    string
+	backend string
+	ids     []int
+}
+
+// WriteXPUInfo writes the host, CPU and accelerator information as tables.
+// Accelerators are grouped by model and driver; wide lists every device
+// with all its details instead. Mixed driver versions or architectures
+// are flagged below the table.
+func WriteXPUInfo(w io.Writer, xpu *xPU, wide bool) error {
+	if xpu.Host != nil {
+		fmt.Fprintln(w, "=== Host Information ===")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "OS:\t%s\n", xpu.Host.OSRelease)
+		fmt.Fprintf(tw, "Kernel:\t%s\n", xpu.Host.KernelVersion)
+		fmt.Fprintf(tw, "Architecture:\t%s\n", xpu.Host.CPUArch)
+		fmt.Fprintf(tw, "Cgroup:\t%s\n", xpu.Host.CgroupVersion)
+		fmt.Fprintf(tw, "Container runtime:\t%s\n", xpu.Host.ContainerRuntime)
+		fmt.Fprintf(tw, "Hypervisor:\t%s\n", xpu.Host.Hypervisor)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	if xpu.CPU != nil {
+		fmt.Fprintln(w, "=== CPU Information ===")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VENDOR\tMODEL\tCORES\tTHREADS")
+		for _, proc := range xpu.CPU.Processors {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", proc.Vendor, proc.Model, proc.NumCores, proc.NumThreads)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "=== Accelerator Information ===")
+	if len(xpu.GPUs) == 0 && (xpu.Acc == nil || len(xpu.Acc.Devices) == 0) {
+		fmt.Fprintln(w, "No Accelerator detected.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	switch {
+	case wide && len(xpu.GPUs) > 0:
+		writeGPUDevices(tw, xpu.GPUs)
+	case wide:
+		writePCIDevices(tw, xpu.Acc.Devices)
+	default:
+		fmt.Fprintln(tw, "COUNT\tMODEL\tDRIVER\tMEMORY\tARCH\tBACKEND\tIDS")
+		for _, g := range groupDevices(xpu) {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				len(g.ids), g.model, g.driver, g.memory, g.arch, g.backend, formatIDs(g.ids))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, warning := range deviceAnomalies(xpu.GPUs) {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+	return nil
+}
+
+// PrintXPUInfo prints system CPU and accelerator (GPU) info in a
+// human-readable format for CLI users.
+func PrintXPUInfo(xpu *xPU) {
+	if err := WriteXPUInfo(os.Stdout, xpu, false); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print hardware info: %v\n", err)
+	}
+}
+
+func writeGPUDevices(w io.Writer, gpus []devices.GPUDevice) {
+	fmt.Fprintln(w, "ID\tCANONICAL\tPCI BUS\tUUID\tMODEL\tDRIVER\tMEMORY\tARCH\tWARP\tPTX\tBACKEND\tVIRTUALIZATION\tPROFILE")
+	for _, g := range gpus {
+		info := g.TritonInfo
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			g.ID, info.CanonicalID, orDash(info.PCIBusID), orDash(info.UUID), gpuModel(g), orDash(g.Summary.DriverVersion),
+			formatMemory(info.MemoryTotalMB), orDash(info.Arch), info.WarpSize, formatPTX(info.PTXVersion),
+			orDash(info.Backend), orDash(info.Virtualization), orDash(info.Profile))
+	}
+}
+
+func writePCIDevices(w io.Writer, devs []*ghwaccel.AcceleratorDevice) {
+	fmt.Fprintln(w, "ID\tADDRESS\tVENDOR\tPRODUCT\tDRIVER")
+	for i, d := range devs {
+		vendor, product, driver := pciNames(d)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i, d.Address, vendor, product, driver)
+	}
+}
+
+// groupDevices groups the GPUs reported by the GPU backend, or the PCI
+// accelerators when no backend is available, by model and driver.
+func groupDevices(xpu *xPU) []deviceGroup {
+	groups := map[string]*deviceGroup{}
+	add := func(g deviceGroup, id int) {
+		key := strings.Join([]string{g.model, g.driver, g.memory, g.arch, g.backend}, "\x00")
+		if _, ok := groups[key]; !ok {
+			groups[key] = &g
+		}
+		groups[key].ids = append(groups[key].ids, id)
+	}
+
+	if len(xpu.GPUs) > 0 {
+		for _, g := range xpu.GPUs {
+			add(deviceGroup{
+				model:   gpuModel(g),
+				driver:  orDash(g.Summary.DriverVersion),
+				memory:  formatMemory(g.TritonInfo.MemoryTotalMB),
+				arch:    orDash(g.TritonInfo.Arch),
+				backend: orDash(g.TritonInfo.Backend),
+			}, g.ID)
+		}
+	} else {
+		for i, d := range xpu.Acc.Devices {
+			vendor, product, driver := pciNames(d)
+			add(deviceGroup{
+				model:   strings.TrimSpace(vendor + " " + product),
+				driver:  driver,
+				memory:  "-",
+				arch:    "-",
+				backend: "-",
+			}, i)
+		}
+	}
+
+	out := make([]deviceGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Ints(g.ids)
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].model == out[j].model {
+			return out[i].driver < out[j].driver
+		}
+		return out[i].model < out[j].model
+	})
+	return out
+}
+
+// deviceAnomalies reports settings that should be uniform across the GPUs
+// of a host: a cache built for one driver or architecture may not load on
+// the rest.
+func deviceAnomalies(gpus []devices.GPUDevice) []string {
+	var warnings []string
+	drivers := map[string][]int{}
+	archs := map[string][]int{}
+	for _, g := range gpus {
+		if g.Summary.DriverVersion != "" {
+			drivers[g.Summary.DriverVersion] = append(drivers[g.Summary.DriverVersion], g.ID)
+		}
+		if g.TritonInfo.Arch != "" {
+			key := g.TritonInfo.Backend + ":" + g.TritonInfo.Arch
+			archs[key] = append(archs[key], g.ID)
+		}
+	}
+	if len(drivers) > 1 {
+		warnings = append(warnings, "mixed driver versions: "+describe(drivers))
+	}
+	if len(archs) > 1 {
+		warnings = append(warnings, "mixed GPU architectures: "+describe(archs))
+	}
+	return warnings
+}
+
+// describe lists the values of m with the GPUs having each, e.g.
+// "550.54 (GPUs 0-2), 535.1 (GPUs 3)".
+func describe(m map[string][]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ids := m[k]
+		sort.Ints(ids)
+		parts = append(parts, fmt.Sprintf("%s (GPUs %s)", k, formatIDs(ids)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func gpuModel(g devices.GPUDevice) string {
+	model := g.Summary.ProductName
+	if model == "" {
+		model = g.TritonInfo.Name
+	}
+	model = orDash(model)
+	if g.TritonInfo.Virtualization != "" {
+		model = fmt.Sprintf("%s (%s)", model, strings.TrimSpace(g.TritonInfo.Virtualization+" "+g.TritonInfo.Profile))
+	}
+	return model
+}
+
+func pciNames(d *ghwaccel.AcceleratorDevice) (vendor, product, driver string) {
+	if d.PCIDevice == nil {
+		return "-", "-", "-"
+	}
+	vendor, product = "-", "-"
+	if d.PCIDevice.Vendor != nil {
+		vendor = d.PCIDevice.Vendor.Name
+	}
+	if d.PCIDevice.Product != nil {
+		product = d.PCIDevice.Product.Name
+	}
+	return vendor, product, orDash(d.PCIDevice.Driver)
+}
+
+// formatIDs collapses sorted IDs into ranges, e.g. "0-3,6".
+func formatIDs(ids []int) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatMemory(mb uint64) string {
+	switch {
+	case mb == 0:
+		return "-"
+	case mb < 1024:
+		return fmt.Sprintf("%d MiB", mb)
+	default:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(mb)/1024), ".0") + " GiB"
+	}
+}
+
+func formatPTX(v int) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.Itoa(v)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/stretchr/testify/assert"
+)
+
+func testGPU(id int, driver string) devices.GPUDevice {
+	return devices.GPUDevice{
+		ID: id,
+		TritonInfo: devices.TritonGPUInfo{
+			ID:            id,
+			Name:          "NVIDIA H100 80GB HBM3",
+			Arch:          "90",
+			Backend:       "cuda",
+			WarpSize:      32,
+			MemoryTotalMB: 81559,
+			PCIBusID:      "0000:1b:00.0",
+		},
+		Summary: devices.DeviceSummary{ProductName: "NVIDIA H100 80GB HBM3", DriverVersion: driver},
+	}
+}
+
+func TestWriteXPUInfoGroupsDevices(t *testing.T) {
+	xpu := &xPU{GPUs: []devices.GPUDevice{
+		testGPU(0, "550.54.15"), testGPU(1, "550.54.15"), testGPU(2, "550.54.15"), testGPU(3, "535.104.05"),
+	}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteXPUInfo(&buf, xpu, false))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Equal(t, strings.Fields("COUNT MODEL DRIVER MEMORY ARCH BACKEND IDS"), strings.Fields(lines[1]))
+	assert.Equal(t, strings.Fields("1 NVIDIA H100 80GB HBM3 535.104.05 79.6 GiB 90 cuda 3"), strings.Fields(lines[2]))
+	assert.Equal(t, strings.Fields("3 NVIDIA H100 80GB HBM3 550.54.15 79.6 GiB 90 cuda 0-2"), strings.Fields(lines[3]))
+	assert.Equal(t, "Warning: mixed driver versions: 535.104.05 (GPUs 3), 550.54.15 (GPUs 0-2)", lines[4])
+
+	buf.Reset()
+	assert.NoError(t, WriteXPUInfo(&buf, xpu, true))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 7)
+	assert.Contains(t, lines[2], "0000:1b:00.0")
+}
+
+func TestWriteXPUInfoNoDevices(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteXPUInfo(&buf, &xPU{}, false))
+	assert.Equal(t, "=== Accelerator Information ===\nNo Accelerator detected.\n", buf.String())
+}
+
+func TestFormatIDs(t *testing.T) {
+	assert.Equal(t, "0-3,6,8-9", formatIDs([]int{0, 1, 2, 3, 6, 8, 9}))
+	assert.Equal(t, "", formatIDs(nil))
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	logging "github.com/sirupsen/logrus"
+)
+
+// attestationAnnotation marks the build attestations buildkit stores in
+// an index next to the images.
+const attestationAnnotation = "vnd.docker.reference.type"
+
+// PlatformCompat is whether the GPUs can use one image of an index.
+type PlatformCompat struct {
+	Digest    string                    `json:"digest"`
+	Platform  string                    `json:"platform,omitempty"`
+	Targets   []cache.SummaryTargetInfo `json:"targets,omitempty"`
+	Matched   []int                     `json:"matchedGPUs"`
+	Unmatched []int                     `json:"unmatchedGPUs"`
+	Error     string                    `json:"error,omitempty"`
+}
+
+// Compatible reports whether at least one GPU can use the image.
+func (p PlatformCompat) Compatible() bool {
+	return len(p.Matched) > 0
+}
+
+// CheckIndexCompat checks every image of the index imageName refers to
+// against the GPUs, in index order, where PreflightCheck only checks the
+// image selected for this host's platform. It returns nil if imageName is
+// a single image.
+func CheckIndexCompat(imageName string) ([]PlatformCompat, error) {
+	if _, err := config.Initialize(config.ConfDir); err != nil {
+		return nil, fmt.Errorf("failed to initialize config: %w", err)
+	}
+	if err := checkImageRef(imageName); err != nil {
+		return nil, err
+	}
+	idx, err := fetcher.FetchIndex(imageName)
+	if err != nil || idx == nil {
+		return nil, err
+	}
+
+	acc, err := accelerator.New(config.GPU, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GPU accelerator: %w", err)
+	}
+	accelerator.GetRegistry().MustRegister(acc)
+	devInfo, err := preflightcheck.GetAllGPUInfo(acc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system GPU info: %w", err)
+	}
+	return checkIndex(idx, devInfo, "")
+}
+
+// checkIndex checks the images of idx, and of the indexes it nests,
+// against devInfo on a host of CPU architecture arch, or this host if arch
+// is empty.
+func checkIndex(idx v1.ImageIndex, devInfo []devices.TritonGPUInfo, arch string) ([]PlatformCompat, error) {
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image index: %w", err)
+	}
+	var results []PlatformCompat
+	for _, d := range m.Manifests {
+		if d.Annotations[attestationAnnotation] != "" {
+			continue
+		}
+		if d.MediaType.IsIndex() {
+			child, err := idx.ImageIndex(d.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image index %s: %w", d.Digest, err)
+			}
+			nested, err := checkIndex(child, devInfo, arch)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, nested...)
+			continue
+		}
+		results = append(results, checkIndexImage(idx, d, devInfo, arch))
+	}
+	return results, nil
+}
+
+// checkIndexImage checks the image d of idx, recording why it cannot be
+// checked rather than failing, so that one bad entry does not hide the
+// others.
+func checkIndexImage(idx v1.ImageIndex, d v1.Descriptor, devInfo []devices.TritonGPUInfo, arch string) PlatformCompat {
+	res := PlatformCompat{Digest: d.Digest.String()}
+	if d.Platform != nil {
+		res.Platform = d.Platform.String()
+	}
+	img, err := idx.Image(d.Digest)
+	if err != nil {
+		res.Error = fmt.Sprintf("failed to read image: %v", err)
+		return res
+	}
+	_ = checkImage(&res, img, devInfo, arch)
+	return res
+}
+
+// checkImage records in res which of devInfo, on a host of CPU architecture
+// arch or this host if arch is empty, can use img. It returns the error
+// that kept img from being checked at all, also recorded in res.
+func checkImage(res *PlatformCompat, img v1.Image, devInfo []devices.TritonGPUInfo, arch string) error {
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		err = fmt.Errorf("failed to get image config: %w", err)
+		res.Error = err.Error()
+		return err
+	}
+	labels := configFile.Config.Labels
+	summary, err := preflightcheck.LoadSummary(img, labels)
+	if err != nil {
+		err = fmt.Errorf("not a cache image: %w", err)
+		res.Error = err.Error()
+		return err
+	}
+	res.Targets = summary.Targets
+
+	var matched, unmatched []devices.TritonGPUInfo
+	if arch == "" {
+		matched, unmatched, err = preflightcheck.CompareCacheSummaryLabelToGPU(img, labels, devInfo)
+	} else {
+		matched, unmatched, err = preflightcheck.CompareCacheSummaryLabelToHost(img, labels, devInfo, arch)
+	}
+	res.Matched, res.Unmatched = extractGPUIDs(matched), extractGPUIDs(unmatched)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	logging.Debugf("Image %s (%s): %d compatible GPU(s)", res.Digest, res.Platform, len(res.Matched))
+	return nil
+}
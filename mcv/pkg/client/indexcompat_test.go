@@ -0,0 +1,62 @@
+package client
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIndex(t *testing.T) {
+	image := func(summary string) v1.Image {
+		img, err := random.Image(64, 1)
+		assert.NoError(t, err)
+		if summary == "" {
+			return img
+		}
+		cfg, err := img.ConfigFile()
+		assert.NoError(t, err)
+		cfg.Config.Labels = map[string]string{"cache.triton.image/summary": summary}
+		img, err = mutate.ConfigFile(img, cfg)
+		assert.NoError(t, err)
+		return img
+	}
+	h100 := image(`{"targets":[{"backend":"cuda","arch":"90","warp_size":32}]}`)
+	a100 := image(`{"targets":[{"backend":"cuda","arch":"80","warp_size":32}]}`)
+	nested := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        a100,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}},
+	})
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: h100, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: nested},
+		mutate.IndexAddendum{Add: image(""), Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "s390x"}}},
+		mutate.IndexAddendum{Add: image(""), Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{attestationAnnotation: "attestation-manifest"},
+		}},
+	)
+
+	gpus := []devices.TritonGPUInfo{{ID: 0, Backend: "cuda", Arch: "90", WarpSize: 32}}
+	results, err := checkIndex(idx, gpus, "")
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+
+	assert.Equal(t, "linux/amd64", results[0].Platform)
+	assert.True(t, results[0].Compatible())
+	assert.Equal(t, []int{0}, results[0].Matched)
+
+	assert.Equal(t, "linux/arm64", results[1].Platform)
+	assert.False(t, results[1].Compatible())
+	assert.Equal(t, "80", results[1].Targets[0].Arch)
+	assert.Equal(t, []int{0}, results[1].Unmatched)
+	assert.NotEmpty(t, results[1].Error)
+
+	assert.Equal(t, "linux/s390x", results[2].Platform)
+	assert.False(t, results[2].Compatible())
+	assert.Contains(t, results[2].Error, "not a cache image")
+}
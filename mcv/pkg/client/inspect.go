@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+)
+
+// ImageInfo describes a cache image from its manifest and config, without
+// extracting it.
+type ImageInfo struct {
+	Image      string                    `json:"image"`
+	Digest     string                    `json:"digest"`
+	CacheTypes []string                  `json:"cacheTypes"`
+	Caches     []CacheInfo               `json:"caches"`
+	Targets    []cache.SummaryTargetInfo `json:"targets"`
+	HostArchs  []string                  `json:"hostArchs,omitempty"`
+	MCVLabels  map[string]string         `json:"mcvLabels,omitempty"`
+	Labels     map[string]string         `json:"labels"`
+	Manifest   *v1.Manifest              `json:"manifest"`
+}
+
+// CacheInfo describes one of the caches of an image, from its
+// cache.<type>.image/* labels.
+type CacheInfo struct {
+	Type      string            `json:"type"`
+	Entries   int               `json:"entries"`
+	SizeBytes int64             `json:"sizeBytes"`
+	Labels    map[string]string `json:"labels"`
+	Summary   json.RawMessage   `json:"summary,omitempty"`
+}
+
+// LayerBytes returns the compressed size of the image's layers.
+func (i *ImageInfo) LayerBytes() int64 {
+	var n int64
+	for _, l := range i.Manifest.Layers {
+		n += l.Size
+	}
+	return n
+}
+
+// InspectImage returns what the manifest and config of imageName say
+// about the caches it holds. Only a summary too large for its label is
+// read from a layer.
+func InspectImage(imageName string) (*ImageInfo, error) {
+	if err := checkImageRef(imageName); err != nil {
+		return nil, err
+	}
+	img, err := fetcher.FetchMetadata(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image digest: %w", err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image manifest: %w", err)
+	}
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image config: %w", err)
+	}
+	labels := configFile.Config.Labels
+
+	cacheTypes, err := preflightcheck.DetectCacheTypesFromLabels(labels)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a cache image: %w", imageName, err)
+	}
+	summary, err := preflightcheck.LoadSummary(img, labels)
+	if err != nil {
+		return nil, err
+	}
+	info := &ImageInfo{
+		Image:      imageName,
+		Digest:     digest.String(),
+		CacheTypes: cacheTypes,
+		Targets:    summary.Targets,
+		HostArchs:  summary.HostArchs,
+		MCVLabels:  labelsWithPrefix(labels, "cache.mcv.image/"),
+		Labels:     labels,
+		Manifest:   manifest,
+	}
+	for _, t := range cacheTypes {
+		c, err := cacheInfo(img, labels, t)
+		if err != nil {
+			return nil, err
+		}
+		info.Caches = append(info.Caches, c)
+	}
+	return info, nil
+}
+
+func cacheInfo(img v1.Image, labels map[string]string, cacheType string) (CacheInfo, error) {
+	prefix := fmt.Sprintf("cache.%s.image/", cacheType)
+	c := CacheInfo{Type: cacheType, Labels: labelsWithPrefix(labels, prefix)}
+	// Images built by older versions of mcv may lack the counts.
+	c.Entries, _ = strconv.Atoi(labels[prefix+"entry-count"])
+	c.SizeBytes, _ = strconv.ParseInt(labels[prefix+"cache-size-bytes"], 10, 64)
+	if value, ok := labels[prefix+"summary"]; ok {
+		data, err := preflightcheck.SummaryJSON(img, value)
+		if err != nil {
+			return c, fmt.Errorf("failed to read the %s summary: %w", cacheType, err)
+		}
+		c.Summary = data
+	}
+	return c, nil
+}
+
+func labelsWithPrefix(labels map[string]string, prefix string) map[string]string {
+	out := map[string]string{}
+	for k, v := range labels {
+		if strings.HasPrefix(k, prefix) {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheInfo(t *testing.T) {
+	img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	labels := map[string]string{
+		"cache.triton.image/entry-count":      "9",
+		"cache.triton.image/cache-size-bytes": "2048",
+		"cache.triton.image/summary":          `{"targets":[{"backend":"cuda","arch":"90","warp_size":32}]}`,
+		"cache.vllm.image/entry-count":        "3",
+		"cache.mcv.image/version":             "v0.1.0",
+	}
+
+	c, err := cacheInfo(img, labels, "triton")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, c.Entries)
+	assert.Equal(t, int64(2048), c.SizeBytes)
+	assert.Len(t, c.Labels, 3)
+	assert.JSONEq(t, labels["cache.triton.image/summary"], string(c.Summary))
+
+	// Labels an older mcv did not write are left zero.
+	c, err = cacheInfo(img, map[string]string{}, "vllm")
+	assert.NoError(t, err)
+	assert.Zero(t, c.Entries)
+	assert.Empty(t, c.Labels)
+	assert.Nil(t, c.Summary)
+}
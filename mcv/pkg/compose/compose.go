@@ -0,0 +1,131 @@
+// Package compose describes the full set of cache images a model needs,
+// e.g. the caches of a draft model, the main model and an embedder, and
+// extracts them together in dependency order.
+package compose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the compose file read when none is given.
+const DefaultFile = "mcv-compose.yaml"
+
+// File is a compose file.
+type File struct {
+	Model      string      `yaml:"model"`
+	Components []Component `yaml:"components"`
+}
+
+// Component is one cache image of the model.
+type Component struct {
+	Name      string   `yaml:"name"`                 // e.g. draft, main or embedder
+	Image     string   `yaml:"image"`                // Cache image to extract
+	Dir       string   `yaml:"dir,omitempty"`        // Extraction directory, defaults to the runtime cache directory
+	DependsOn []string `yaml:"depends_on,omitempty"` // Components to extract first
+}
+
+// Load reads and validates the compose file at path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compose file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse decodes and validates a compose file. Unknown keys are rejected so
+// typos do not silently drop settings.
+func Parse(data []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Validate checks that the model and every component are named, component
+// names are unique and dependencies exist and do not form a cycle.
+func (f *File) Validate() error {
+	if f.Model == "" {
+		return errors.New("model is required")
+	}
+	if len(f.Components) == 0 {
+		return errors.New("at least one component is required")
+	}
+	names := map[string]bool{}
+	for i, c := range f.Components {
+		if c.Name == "" {
+			return fmt.Errorf("component %d has no name", i+1)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate component %q", c.Name)
+		}
+		names[c.Name] = true
+		if c.Image == "" {
+			return fmt.Errorf("component %q has no image", c.Name)
+		}
+	}
+	for _, c := range f.Components {
+		for _, d := range c.DependsOn {
+			if !names[d] {
+				return fmt.Errorf("component %q depends on unknown component %q", c.Name, d)
+			}
+		}
+	}
+	_, err := f.Order()
+	return err
+}
+
+// Order returns the components so that each follows its dependencies,
+// otherwise keeping the order of the file.
+func (f *File) Order() ([]Component, error) {
+	byName := map[string]Component{}
+	for _, c := range f.Components {
+		byName[c.Name] = c
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var ordered []Component
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle through component %q", name)
+		}
+		state[name] = visiting
+		for _, d := range byName[name].DependsOn {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		ordered = append(ordered, byName[name])
+		return nil
+	}
+	for _, c := range f.Components {
+		if err := visit(c.Name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
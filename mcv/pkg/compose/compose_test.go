@@ -0,0 +1,106 @@
+package compose
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const example = `
+model: llama-3-70b
+components:
+  - name: main
+    image: quay.io/example/llama-70b-cache:v1
+    depends_on: [draft]
+  - name: embedder
+    image: quay.io/example/embedder-cache:v1
+    dir: /tmp/embedder
+  - name: draft
+    image: quay.io/example/llama-8b-cache:v1
+`
+
+func names(cs []Component) []string {
+	var out []string
+	for _, c := range cs {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func TestParseAndOrder(t *testing.T) {
+	f, err := Parse([]byte(example))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "llama-3-70b", f.Model)
+	ordered, err := f.Order()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"draft", "main", "embedder"}, names(ordered))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"no model":       "components: [{name: a, image: x}]",
+		"no components":  "model: m",
+		"duplicate":      "model: m\ncomponents: [{name: a, image: x}, {name: a, image: y}]",
+		"missing image":  "model: m\ncomponents: [{name: a}]",
+		"unknown dep":    "model: m\ncomponents: [{name: a, image: x, depends_on: [b]}]",
+		"cycle":          "model: m\ncomponents: [{name: a, image: x, depends_on: [b]}, {name: b, image: y, depends_on: [a]}]",
+		"unknown field":  "model: m\ncomponents: [{name: a, image: x, tag: v1}]",
+		"not a document": "model: [",
+	} {
+		_, err := Parse([]byte(doc))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestUpAndStatus(t *testing.T) {
+	f, err := Parse([]byte(example))
+	if !assert.NoError(t, err) {
+		return
+	}
+	stateDir := t.TempDir()
+
+	statuses, err := Status(f, stateDir)
+	assert.NoError(t, err)
+	for _, s := range statuses {
+		assert.Equal(t, StatePending, s.State, s.Name)
+	}
+
+	// The draft fails, so main is skipped; the embedder does not depend on
+	// it and is still extracted.
+	var extracted []string
+	err = Up(f, stateDir, func(c Component) (string, error) {
+		extracted = append(extracted, c.Name)
+		if c.Name == "draft" {
+			return "", errors.New("no compatible GPU")
+		}
+		return "sha256:" + c.Name, nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"draft", "embedder"}, extracted)
+
+	statuses, err = Status(f, stateDir)
+	assert.NoError(t, err)
+	got := map[string]string{}
+	for _, s := range statuses {
+		got[s.Name] = s.State
+	}
+	assert.Equal(t, map[string]string{"draft": StateFailed, "main": StateSkipped, "embedder": StateExtracted}, got)
+
+	assert.NoError(t, Up(f, stateDir, func(c Component) (string, error) { return "sha256:" + c.Name, nil }))
+
+	// Pointing a component at another image marks it changed until the
+	// next up.
+	f.Components[0].Image = "quay.io/example/llama-70b-cache:v2"
+	statuses, err = Status(f, stateDir)
+	assert.NoError(t, err)
+	for _, s := range statuses {
+		want := StateExtracted
+		if s.Name == "main" {
+			want = StateChanged
+		}
+		assert.Equal(t, want, s.State, s.Name)
+	}
+}
@@ -0,0 +1,173 @@
+package compose
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Component states recorded by Up and reported by Status.
+const (
+	StatePending   = "pending"   // Not extracted yet
+	StateExtracted = "extracted" // Extracted from the image named in the file
+	StateFailed    = "failed"    // The last extraction failed
+	StateSkipped   = "skipped"   // Not extracted because a dependency failed
+	StateChanged   = "changed"   // Extracted from an image the file no longer names
+)
+
+// ComponentState is what Up recorded about a component.
+type ComponentState struct {
+	Image       string    `json:"image"`
+	Digest      string    `json:"digest,omitempty"`
+	Dir         string    `json:"dir,omitempty"`
+	State       string    `json:"state"`
+	Error       string    `json:"error,omitempty"`
+	ExtractedAt time.Time `json:"extractedAt,omitempty"`
+}
+
+// State is what Up recorded about the components of a model.
+type State struct {
+	Model      string                    `json:"model"`
+	Components map[string]ComponentState `json:"components"`
+}
+
+// Extractor verifies and extracts the image of c and returns the digest
+// it extracted.
+type Extractor func(c Component) (digest string, err error)
+
+// StatePath returns where the state of model is kept under stateDir.
+func StatePath(stateDir, model string) string {
+	return filepath.Join(stateDir, filepath.Base(model)+".json")
+}
+
+// LoadState reads the state recorded for model, which is empty if nothing
+// was extracted yet.
+func LoadState(stateDir, model string) (*State, error) {
+	s := &State{Model: model, Components: map[string]ComponentState{}}
+	data, err := os.ReadFile(StatePath(stateDir, model))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse compose state: %w", err)
+	}
+	if s.Components == nil {
+		s.Components = map[string]ComponentState{}
+	}
+	return s, nil
+}
+
+func (s *State) save(stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create compose state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := StatePath(stateDir, s.Model)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write compose state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Up extracts every component of f in dependency order with extract and
+// records the outcome under stateDir. A component whose dependency failed
+// is skipped; Up carries on with the others and returns an error naming
+// every component that was not extracted.
+func Up(f *File, stateDir string, extract Extractor) error {
+	ordered, err := f.Order()
+	if err != nil {
+		return err
+	}
+	s, err := LoadState(stateDir, f.Model)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, c := range ordered {
+		cs := ComponentState{Image: c.Image, Dir: c.Dir}
+		if dep := firstUnavailable(c, s); dep != "" {
+			cs.State = StateSkipped
+			cs.Error = fmt.Sprintf("dependency %q was not extracted", dep)
+			diag.Warnf(diag.ComponentSkipped, "Skipping %s: %s", c.Name, cs.Error)
+		} else {
+			logging.Infof("Extracting %s from %s", c.Name, c.Image)
+			digest, err := extract(c)
+			if err != nil {
+				cs.State = StateFailed
+				cs.Error = err.Error()
+				logging.Errorf("Failed to extract %s: %v", c.Name, err)
+			} else {
+				cs.State = StateExtracted
+				cs.Digest = digest
+				cs.ExtractedAt = time.Now().UTC()
+			}
+		}
+		if cs.State != StateExtracted {
+			failed = append(failed, c.Name)
+		}
+		s.Components[c.Name] = cs
+		if err := s.save(stateDir); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d component(s) not extracted: %v", len(failed), len(ordered), failed)
+	}
+	return nil
+}
+
+func firstUnavailable(c Component, s *State) string {
+	for _, d := range c.DependsOn {
+		if s.Components[d].State != StateExtracted {
+			return d
+		}
+	}
+	return ""
+}
+
+// ComponentStatus is the state of a component of the compose file.
+type ComponentStatus struct {
+	Name           string
+	Image          string // Image named in the compose file
+	ComponentState        // What Up last recorded
+}
+
+// Status reports the state of each component of f in dependency order.
+// Components never extracted are pending, and those extracted from an
+// image other than the one the file names are changed.
+func Status(f *File, stateDir string) ([]ComponentStatus, error) {
+	ordered, err := f.Order()
+	if err != nil {
+		return nil, err
+	}
+	s, err := LoadState(stateDir, f.Model)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]ComponentStatus, 0, len(ordered))
+	for _, c := range ordered {
+		cs, ok := s.Components[c.Name]
+		switch {
+		case !ok:
+			cs = ComponentState{Image: c.Image, State: StatePending}
+		case cs.Image != c.Image:
+			cs.State = StateChanged
+		}
+		statuses = append(statuses, ComponentStatus{Name: c.Name, Image: c.Image, ComponentState: cs})
+	}
+	return statuses, nil
+}
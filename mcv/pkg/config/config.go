@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -22,6 +28,67 @@ type MCVConfig struct {
 	KubeConfig       string
 	EnabledBaremetal *bool
 	SkipPrecheck     *bool
+	ResumeExtract    *bool
+	ForceExtract     *bool // Extract even if the cache dir's marker shows the image was already extracted
+	BaseImage        string
+	Builder          string
+	PreflightCache   string        // File caching preflight results per image digest
+	PreflightTTL     time.Duration // How long cached preflight results stay valid, 0 disables
+	NoPreflightCache *bool         // Ignore cached preflight results, still recording new ones
+	DeviceCache      string        // File caching the detected GPUs
+	DeviceCacheTTL   time.Duration // How long the detected GPUs stay cached, 0 disables
+	DeviceFixture    string        // Recorded device library calls to replay instead of querying the GPUs
+	Containerized    *bool         // mcv runs in a container, with the host's root filesystem at HostRoot
+	HostRoot         string        // Where the host's root filesystem is mounted when containerized
+	HostHome         string        // Home directory on the host holding the Triton and vLLM caches
+	Compression      string        // Layer compression for --create: gzip or zstd
+	CompressionLevel int           // 0 selects the algorithm default
+	CompressionJobs  int           // Parallel compression workers, 0 uses all CPUs the cgroup limits allow
+	SecretScan       string        // Secret scan policy for --create
+	RegistryQPS      float64       // Registry requests per second, 0 disables the limit
+	RegistryBurst    int           // Requests allowed above RegistryQPS in a burst
+	RegistryRetries  int           // Retries of failed registry operations
+	BreakerFailures  int           // Consecutive registry failures that open the breaker, 0 disables it
+	BreakerCooldown  time.Duration // How long an open breaker rejects requests
+	PinDigests       bool          // Refuse to extract a tag whose digest differs from its pin
+	PinLockFile      string        // File recording the digest each tag is pinned to
+	UpdatePin        *bool         // Re-pin tags whose digest changed instead of refusing them
+	InventoryFile    string        // File recording the images extracted on the host
+	FIPS             bool          // Require the Go FIPS 140-3 module and FIPS approved digests
+	AnnotatePlugin   string        // Executable returning extra labels and annotations for --create
+	VLLMPython       string        // Python interpreter of the local vLLM installation
+	VLLMKeyMismatch  string        // What extract does with a vLLM cache built for other versions
+	MountFSImage     *bool         // Mount filesystem image caches instead of unpacking them
+	ExtractFileMode  string        // Octal mode given to extracted files, empty keeps theirs
+	ExtractDirMode   string        // Octal mode given to extracted directories, empty keeps theirs
+	ExtractOwner     string        // user[:group] given the extracted cache, empty keeps the extracting user
+	KeepTemp         *bool         // Keep temporary build and extract files for debugging
+	ExpiredPolicy    string        // What extract does with a cache image past its valid-until date
+	SharedLock       string        // When extract locks a cache dir shared with other nodes: auto, on or off
+	SharedLockWait   time.Duration // How long extract waits for another node's extraction, 0 waits forever
+	SharedLockStale  time.Duration // How long a shared dir lease may go unrenewed before it is taken over
+	TempMaxSize      int64         // Bytes of temporary files kept with --keep-temp, 0 for no limit
+	Telemetry        string        // Endpoint anonymized extraction statistics are sent to, empty sends none
+	ExpectedDigest   string        // Manifest digest the fetched image must have, set once it is verified
+	DigestOnly       *bool         // Refuse tag references when extracting or checking images
+	ReadyFile        string        // File extract writes once the hot kernels, then the whole cache, are ready
+	HWSnapshot       string        // File --hw-info records the host's GPUs and drivers to, for hw-diff
+	EntryErrors      string        // What extract does with a cache entry it cannot extract: fail or skip
+	Nice             int           // Nice value extract runs with, 0 to keep mcv's
+	IONice           string        // I/O priority extract runs with, as class[:level], "" to keep mcv's
+	WriteLimit       float64       // MB/s extract writes files at, 0 for no limit
+	CacheStore       string        // Where extract writes caches: dir, tmpfs, s3 or gcs
+	TmpfsSize        int64         // Bytes of the tmpfs the tmpfs store mounts, 0 for the kernel default
+	Bucket           string        // bucket[/prefix] the s3 and gcs stores upload caches to
+	BucketEndpoint   string        // URL of the S3-compatible service to upload to, "" for the store's default
+	BucketMount      string        // Where consumers see the bucket prefix, "" to upload paths as placeholders
+	Kernels          string        // Which kernels extract restores: all, or those compatible with the GPUs
+	SuppressWarnings []string      // Diagnostic codes whose warnings are logged at debug level only
+	Timeout          time.Duration // How long all network operations may take together, 0 for no limit
+	PullTimeout      time.Duration // How long pulling an image may take, 0 for no limit
+	PushTimeout      time.Duration // How long pushing or copying an image, or uploading to object storage, may take, 0 for no limit
+	SignTimeout      time.Duration // How long copying an image's signatures and attestations may take, 0 for no limit
+	VerifyTimeout    time.Duration // How long verifying an image's signature may take, 0 for no limit
 }
 
 type Config struct {
@@ -70,17 +137,144 @@ func Instance() *Config {
 }
 
 func getMCVConfig(confDir string) MCVConfig {
+	// Applied first for the options below to warn as asked.
+	suppress := parseListConfig(envSuppress, confDir)
+	diag.Suppress(suppress)
+
 	return MCVConfig{
-		EnabledGPU:       parseBoolEnv(envEnableGPU, true),
-		SkipPrecheck:     parseBoolEnv(envSkipPrecheck, false),
-		EnabledBaremetal: parseBoolEnv(envEnableBaremetal, false),
+		EnabledGPU:       parseBoolConfig(envEnableGPU, true, confDir),
+		SkipPrecheck:     parseBoolConfig(envSkipPrecheck, false, confDir),
+		EnabledBaremetal: parseBoolConfig(envEnableBaremetal, false, confDir),
+		ResumeExtract:    parseBoolConfig(envResumeExtract, false, confDir),
+		ForceExtract:     parseBoolConfig(envForceExtract, false, confDir),
 		MCVNamespace:     getConfig(envKeplerNamespace, defaultNamespace, confDir),
 		KubeConfig:       getConfig(envKubeConfig, defaultKubeConfig, confDir),
+		BaseImage:        getConfig(envBaseImage, defaultBaseImage, confDir),
+		Builder:          getConfig(envBuilder, "", confDir),
+		PreflightCache:   getConfig(envPreflightCache, defaultPreflightCache, confDir),
+		PreflightTTL:     parseDurationConfig(envPreflightTTL, defaultPreflightTTL, confDir),
+		NoPreflightCache: parseBoolConfig(envNoPreflight, false, confDir),
+		DeviceCache:      getConfig(envDeviceCache, defaultDeviceCache, confDir),
+		DeviceCacheTTL:   parseDurationConfig(envDeviceCacheTTL, defaultDeviceCacheTTL, confDir),
+		DeviceFixture:    getConfig(envDeviceFixture, "", confDir),
+		Containerized:    parseBoolConfig(envContainerized, false, confDir),
+		HostRoot:         getConfig(envHostRoot, defaultHostRoot, confDir),
+		HostHome:         getConfig(envHostHome, "", confDir),
+		Compression:      getConfig(envCompression, "", confDir),
+		CompressionLevel: parseIntConfig(envCompressionLvl, 0, confDir),
+		CompressionJobs:  parseIntConfig(envCompressionJobs, 0, confDir),
+		SecretScan:       getConfig(envSecretScan, "", confDir),
+		RegistryQPS:      parseFloatConfig(envRegistryQPS, defaultRegistryQPS, confDir),
+		RegistryBurst:    parseIntConfig(envRegistryBurst, defaultRegistryBurst, confDir),
+		RegistryRetries:  parseIntConfig(envRegistryRetries, defaultRegistryRetry, confDir),
+		BreakerFailures:  parseIntConfig(envBreakerFailures, defaultBreakerFails, confDir),
+		BreakerCooldown:  parseDurationConfig(envBreakerCooldown, defaultBreakerCool, confDir),
+		PinDigests:       strings.EqualFold(getConfig(envPinDigests, "false", confDir), "true"),
+		PinLockFile:      getConfig(envPinLockFile, constants.PinLockFile, confDir),
+		UpdatePin:        parseBoolConfig(envUpdatePin, false, confDir),
+		InventoryFile:    getConfig(envInventoryFile, constants.InventoryFile, confDir),
+		FIPS:             strings.EqualFold(getConfig(envFIPS, "false", confDir), "true"),
+		AnnotatePlugin:   getConfig(envAnnotatePlugin, "", confDir),
+		VLLMPython:       getConfig(envVLLMPython, defaultVLLMPython, confDir),
+		VLLMKeyMismatch:  getConfig(envVLLMKeyMismatch, defaultVLLMMismatch, confDir),
+		MountFSImage:     parseBoolConfig(envMountFSImage, false, confDir),
+		ExtractFileMode:  getConfig(envExtractFileMode, "", confDir),
+		ExtractDirMode:   getConfig(envExtractDirMode, "", confDir),
+		ExtractOwner:     getConfig(envExtractOwner, "", confDir),
+		KeepTemp:         parseBoolConfig(envKeepTemp, false, confDir),
+		ExpiredPolicy:    getConfig(envExpiredPolicy, defaultExpiredPolicy, confDir),
+		SharedLock:       getConfig(envSharedLock, defaultSharedLock, confDir),
+		SharedLockWait:   parseDurationConfig(envSharedLockWait, defaultLockTimeout, confDir),
+		SharedLockStale:  parseDurationConfig(envSharedLockStale, defaultLockStale, confDir),
+		TempMaxSize:      parseSizeConfig(envTempMaxSize, defaultTempMaxSize, confDir),
+		Telemetry:        getConfig(envTelemetry, "", confDir),
+		DigestOnly:       parseBoolConfig(envDigestOnly, false, confDir),
+		ReadyFile:        getConfig(envReadyFile, "", confDir),
+		HWSnapshot:       getConfig(envHWSnapshot, constants.HWSnapshotFile, confDir),
+		EntryErrors:      getConfig(envEntryErrors, defaultEntryErrors, confDir),
+		Nice:             parseIntConfig(envNice, 0, confDir),
+		IONice:           getConfig(envIONice, "", confDir),
+		WriteLimit:       parseFloatConfig(envWriteLimit, 0, confDir),
+		CacheStore:       getConfig(envCacheStore, defaultCacheStore, confDir),
+		TmpfsSize:        parseSizeConfig(envTmpfsSize, 0, confDir),
+		Bucket:           getConfig(envBucket, "", confDir),
+		BucketEndpoint:   getConfig(envBucketEndpoint, "", confDir),
+		BucketMount:      getConfig(envBucketMount, "", confDir),
+		Kernels:          getConfig(envKernels, defaultKernels, confDir),
+		SuppressWarnings: suppress,
+		Timeout:          parseDurationConfig(envTimeout, 0, confDir),
+		PullTimeout:      parseDurationConfig(envPullTimeout, defaultPullTimeout, confDir),
+		PushTimeout:      parseDurationConfig(envPushTimeout, defaultPushTimeout, confDir),
+		SignTimeout:      parseDurationConfig(envSignTimeout, defaultSignTimeout, confDir),
+		VerifyTimeout:    parseDurationConfig(envVerifyTimeout, defaultVerifyTimeout, confDir),
+	}
+}
+
+// parseListConfig returns the comma separated values of key.
+func parseListConfig(key, confDir string) []string {
+	var list []string
+	for _, v := range strings.Split(getConfig(key, "", confDir), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func parseIntConfig(key string, defaultVal int, confDir string) int {
+	val := getConfig(key, "", confDir)
+	if val == "" {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		diag.Warnf(diag.InvalidConfigValue, "Invalid integer %q for %s, using %d", val, key, defaultVal)
+		return defaultVal
+	}
+	return n
+}
+
+func parseFloatConfig(key string, defaultVal float64, confDir string) float64 {
+	val := getConfig(key, "", confDir)
+	if val == "" {
+		return defaultVal
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		diag.Warnf(diag.InvalidConfigValue, "Invalid number %q for %s, using %g", val, key, defaultVal)
+		return defaultVal
 	}
+	return f
 }
 
-func parseBoolEnv(key string, defaultVal bool) *bool {
-	if val, exists := os.LookupEnv(key); exists {
+func parseDurationConfig(key string, defaultVal time.Duration, confDir string) time.Duration {
+	val := getConfig(key, "", confDir)
+	if val == "" {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		diag.Warnf(diag.InvalidConfigValue, "Invalid duration %q for %s, using %s", val, key, defaultVal)
+		return defaultVal
+	}
+	return d
+}
+
+func parseSizeConfig(key string, defaultVal int64, confDir string) int64 {
+	val := getConfig(key, "", confDir)
+	if val == "" {
+		return defaultVal
+	}
+	n, err := units.RAMInBytes(val)
+	if err != nil || n < 0 {
+		diag.Warnf(diag.InvalidConfigValue, "Invalid size %q for %s, using %s", val, key, units.BytesSize(float64(defaultVal)))
+		return defaultVal
+	}
+	return n
+}
+
+func parseBoolConfig(key string, defaultVal bool, confDir string) *bool {
+	if val, exists := lookupConfig(key, confDir); exists {
 		b := strings.EqualFold(val, "true")
 		return &b
 	}
@@ -88,14 +282,61 @@ func parseBoolEnv(key string, defaultVal bool) *bool {
 }
 
 func getConfig(key, defaultValue, confDir string) string {
+	if value, exists := lookupConfig(key, confDir); exists {
+		return value
+	}
+	return defaultValue
+}
+
+// lookupConfig returns the value of key from, in order of precedence, the
+// environment, the file named key in confDir, or the KEY=value lines of
+// ConfFile in confDir.
+func lookupConfig(key, confDir string) (string, bool) {
 	if envValue, exists := os.LookupEnv(key); exists {
-		return envValue
+		return envValue, true
+	}
+	if confDir == "" {
+		return "", false
 	}
 	configFile := filepath.Join(confDir, key)
 	if value, err := os.ReadFile(configFile); err == nil {
-		return strings.TrimSpace(bytes.NewBuffer(value).String())
+		return strings.TrimSpace(bytes.NewBuffer(value).String()), true
 	}
-	return defaultValue
+	value, exists := readConfFile(confDir)[key]
+	return value, exists
+}
+
+// confFileCache holds the last ConfFile read, as every option is looked up
+// in it.
+var confFileCache struct {
+	path   string
+	values map[string]string
+}
+
+// readConfFile parses ConfFile in confDir, which holds KEY=value lines and
+// # comments.
+func readConfFile(confDir string) map[string]string {
+	path := filepath.Join(confDir, ConfFile)
+	if confFileCache.values != nil && confFileCache.path == path {
+		return confFileCache.values
+	}
+	values := map[string]string{}
+	if data, err := os.ReadFile(path); err == nil {
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				diag.Warnf(diag.InvalidConfigLine, "Ignoring line %d of %s: expected KEY=value", i+1, path)
+				continue
+			}
+			values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	confFileCache.path, confFileCache.values = path, values
+	return values
 }
 
 func logBoolConfigs() {
@@ -123,10 +364,30 @@ func SetEnabledBaremetal(enabled bool) {
 	instance.MCV.EnabledBaremetal = &b
 }
 
+func SetResumeExtract(enabled bool) {
+	b := enabled
+	instance.MCV.ResumeExtract = &b
+}
+
+func SetUpdatePin(enabled bool) {
+	b := enabled
+	instance.MCV.UpdatePin = &b
+}
+
+func SetMountFSImage(enabled bool) {
+	b := enabled
+	instance.MCV.MountFSImage = &b
+}
+
 func SetKubeConfig(k string) {
 	instance.MCV.KubeConfig = k
 }
 
+// Namespace returns the Kubernetes namespace MCV objects are created in.
+func Namespace() string {
+	return instance.MCV.MCVNamespace
+}
+
 func KubeConfig() string {
 	return instance.MCV.KubeConfig
 }
@@ -142,3 +403,411 @@ func IsSkipPrecheckEnabled() bool {
 func IsBaremetalEnabled() bool {
 	return instance.MCV.EnabledBaremetal != nil && *instance.MCV.EnabledBaremetal
 }
+
+func IsResumeExtractEnabled() bool {
+	return instance.MCV.ResumeExtract != nil && *instance.MCV.ResumeExtract
+}
+
+func SetForceExtract(enabled bool) {
+	b := enabled
+	instance.MCV.ForceExtract = &b
+}
+
+func IsForceExtractEnabled() bool {
+	return instance.MCV.ForceExtract != nil && *instance.MCV.ForceExtract
+}
+
+func SetKeepTemp(enabled bool) {
+	b := enabled
+	instance.MCV.KeepTemp = &b
+}
+
+func IsKeepTempEnabled() bool {
+	return instance != nil && instance.MCV.KeepTemp != nil && *instance.MCV.KeepTemp
+}
+
+func SetContainerized(enabled bool) {
+	b := enabled
+	instance.MCV.Containerized = &b
+}
+
+func IsContainerized() bool {
+	return instance.MCV.Containerized != nil && *instance.MCV.Containerized
+}
+
+func IsMountFSImageEnabled() bool {
+	return instance.MCV.MountFSImage != nil && *instance.MCV.MountFSImage
+}
+
+func BaseImage() string {
+	return instance.MCV.BaseImage
+}
+
+func Builder() string {
+	return instance.MCV.Builder
+}
+
+func PreflightCache() string {
+	return instance.MCV.PreflightCache
+}
+
+func PreflightTTL() time.Duration {
+	return instance.MCV.PreflightTTL
+}
+
+func SetDigestOnly(enabled bool) {
+	b := enabled
+	instance.MCV.DigestOnly = &b
+}
+
+func IsDigestOnlyEnabled() bool {
+	return instance != nil && instance.MCV.DigestOnly != nil && *instance.MCV.DigestOnly
+}
+
+func SetNoPreflightCache(enabled bool) {
+	b := enabled
+	instance.MCV.NoPreflightCache = &b
+}
+
+func IsNoPreflightCacheEnabled() bool {
+	return instance.MCV.NoPreflightCache != nil && *instance.MCV.NoPreflightCache
+}
+
+// DeviceCache returns the file caching the detected GPUs. Like
+// DeviceCacheTTL, it falls back to the default before Initialize, as GPU
+// detection can run first.
+func DeviceCache() string {
+	if instance == nil {
+		return defaultDeviceCache
+	}
+	return instance.MCV.DeviceCache
+}
+
+func DeviceCacheTTL() time.Duration {
+	if instance == nil {
+		return defaultDeviceCacheTTL
+	}
+	return instance.MCV.DeviceCacheTTL
+}
+
+// DeviceFixture returns the device fixture GPU detection replays instead
+// of querying the host's GPUs, or "". Before Initialize it is read from
+// the environment, as GPU detection can run first.
+func DeviceFixture() string {
+	if instance == nil {
+		return os.Getenv(envDeviceFixture)
+	}
+	return instance.MCV.DeviceFixture
+}
+
+// HostRoot returns where the host's root filesystem is mounted: "/" unless
+// containerized or a host root was given.
+func HostRoot() string {
+	if !IsContainerized() {
+		return "/"
+	}
+	return instance.MCV.HostRoot
+}
+
+func SetHostRoot(dir string) {
+	instance.MCV.HostRoot = dir
+}
+
+// HostHome returns the home directory on the host, relative to HostRoot,
+// the Triton and vLLM caches are found in when containerized, or "" to use
+// mcv's own home directory.
+func HostHome() string {
+	return instance.MCV.HostHome
+}
+
+func SetHostHome(dir string) {
+	instance.MCV.HostHome = dir
+}
+
+func SetDeviceCache(path string, ttl time.Duration) {
+	instance.MCV.DeviceCache = path
+	instance.MCV.DeviceCacheTTL = ttl
+}
+
+func Compression() string {
+	return instance.MCV.Compression
+}
+
+func CompressionLevel() int {
+	return instance.MCV.CompressionLevel
+}
+
+func CompressionWorkers() int {
+	return instance.MCV.CompressionJobs
+}
+
+func SecretScan() string {
+	return instance.MCV.SecretScan
+}
+
+func AnnotatePlugin() string {
+	return instance.MCV.AnnotatePlugin
+}
+
+func VLLMPython() string {
+	return instance.MCV.VLLMPython
+}
+
+func VLLMKeyMismatch() string {
+	return instance.MCV.VLLMKeyMismatch
+}
+
+func SetVLLMKeyMismatch(policy string) {
+	instance.MCV.VLLMKeyMismatch = policy
+}
+
+// ExtractPermissions returns the file mode, directory mode and owner
+// extracted caches are normalized to.
+func ExtractPermissions() (fileMode, dirMode, owner string) {
+	return instance.MCV.ExtractFileMode, instance.MCV.ExtractDirMode, instance.MCV.ExtractOwner
+}
+
+func SetExtractPermissions(fileMode, dirMode, owner string) {
+	instance.MCV.ExtractFileMode = fileMode
+	instance.MCV.ExtractDirMode = dirMode
+	instance.MCV.ExtractOwner = owner
+}
+
+func ExpiredPolicy() string {
+	return instance.MCV.ExpiredPolicy
+}
+
+func SetExpiredPolicy(policy string) {
+	instance.MCV.ExpiredPolicy = policy
+}
+
+// EntryErrors returns what extract does with a cache entry it cannot
+// extract: "fail" to stop, or "skip" to extract the rest and report it.
+func EntryErrors() string {
+	return instance.MCV.EntryErrors
+}
+
+func SetEntryErrors(policy string) {
+	instance.MCV.EntryErrors = policy
+}
+
+// ExtractPriority returns the nice value and the I/O priority, as
+// class[:level], extract runs with, and the MB/s it writes files at. Zero
+// values keep mcv's priorities and write without a limit.
+func ExtractPriority() (nice int, ioNice string, writeLimit float64) {
+	return instance.MCV.Nice, instance.MCV.IONice, instance.MCV.WriteLimit
+}
+
+func SetExtractPriority(nice int, ioNice string, writeLimit float64) {
+	instance.MCV.Nice = nice
+	instance.MCV.IONice = ioNice
+	instance.MCV.WriteLimit = writeLimit
+}
+
+// CacheStore returns where extract writes caches, "dir", "tmpfs", "s3" or
+// "gcs", and the bytes of the tmpfs the tmpfs store mounts, 0 for the
+// kernel default.
+func CacheStore() (kind string, tmpfsSize int64) {
+	return instance.MCV.CacheStore, instance.MCV.TmpfsSize
+}
+
+func SetCacheStore(kind string, tmpfsSize int64) {
+	instance.MCV.CacheStore = kind
+	instance.MCV.TmpfsSize = tmpfsSize
+}
+
+// Bucket returns the bucket[/prefix] the s3 and gcs stores upload caches
+// to, the endpoint of the service, "" for the store's default, and where
+// consumers see the prefix, "" to leave placeholders in uploaded paths.
+func Bucket() (bucket, endpoint, mount string) {
+	return instance.MCV.Bucket, instance.MCV.BucketEndpoint, instance.MCV.BucketMount
+}
+
+func SetBucket(bucket, endpoint, mount string) {
+	instance.MCV.Bucket = bucket
+	instance.MCV.BucketEndpoint = endpoint
+	instance.MCV.BucketMount = mount
+}
+
+// Kernels returns which kernels of an image extract restores: "all", or
+// "compatible" for only those that run on the GPUs.
+func Kernels() string {
+	return instance.MCV.Kernels
+}
+
+func SetKernels(policy string) {
+	instance.MCV.Kernels = policy
+}
+
+// SuppressedWarnings returns the diagnostic codes whose warnings are only
+// logged at debug level.
+func SuppressedWarnings() []string {
+	return instance.MCV.SuppressWarnings
+}
+
+func SetSuppressedWarnings(codes []string) {
+	// The flag bound to the environment repeats what was read at startup.
+	if slices.Equal(codes, instance.MCV.SuppressWarnings) {
+		return
+	}
+	instance.MCV.SuppressWarnings = codes
+	diag.Suppress(codes)
+}
+
+func SharedLock() string {
+	return instance.MCV.SharedLock
+}
+
+func SetSharedLock(mode string) {
+	instance.MCV.SharedLock = mode
+}
+
+func SharedLockWait() time.Duration {
+	return instance.MCV.SharedLockWait
+}
+
+func SetSharedLockWait(d time.Duration) {
+	instance.MCV.SharedLockWait = d
+}
+
+func SharedLockStale() time.Duration {
+	return instance.MCV.SharedLockStale
+}
+
+// TempMaxSize returns how many bytes of temporary files --keep-temp keeps
+// across runs before the oldest are removed, or 0 for no limit.
+func TempMaxSize() int64 {
+	if instance == nil {
+		return defaultTempMaxSize
+	}
+	return instance.MCV.TempMaxSize
+}
+
+// TelemetryEndpoint returns where anonymized extraction statistics are
+// sent, or "" when telemetry is off, as it is unless an endpoint is set.
+func TelemetryEndpoint() string {
+	if instance == nil {
+		return ""
+	}
+	return instance.MCV.Telemetry
+}
+
+func SetTelemetryEndpoint(endpoint string) {
+	instance.MCV.Telemetry = endpoint
+}
+
+// ReadyFile returns the file extract writes the readiness of the cache
+// to, "hot" once its hot kernels are extracted and "complete" once all of
+// it is, or "" to write none.
+func ReadyFile() string {
+	if instance == nil {
+		return ""
+	}
+	return instance.MCV.ReadyFile
+}
+
+func SetReadyFile(path string) {
+	instance.MCV.ReadyFile = path
+}
+
+// HWSnapshot returns the file the last snapshot of the host's GPUs and
+// drivers is kept in.
+func HWSnapshot() string {
+	if instance == nil {
+		return constants.HWSnapshotFile
+	}
+	return instance.MCV.HWSnapshot
+}
+
+// ExpectedDigest returns the manifest digest fetched images must have, or
+// "" to accept any.
+func ExpectedDigest() string {
+	if instance == nil {
+		return ""
+	}
+	return instance.MCV.ExpectedDigest
+}
+
+func SetExpectedDigest(digest string) {
+	instance.MCV.ExpectedDigest = digest
+}
+
+func RegistryQPS() float64 {
+	return instance.MCV.RegistryQPS
+}
+
+func RegistryBurst() int {
+	return instance.MCV.RegistryBurst
+}
+
+func RegistryRetries() int {
+	return instance.MCV.RegistryRetries
+}
+
+func RegistryBreakerFailures() int {
+	return instance.MCV.BreakerFailures
+}
+
+func RegistryBreakerCooldown() time.Duration {
+	return instance.MCV.BreakerCooldown
+}
+
+func Timeout() time.Duration {
+	return instance.MCV.Timeout
+}
+
+func SetTimeout(d time.Duration) {
+	instance.MCV.Timeout = d
+}
+
+func PullTimeout() time.Duration {
+	return instance.MCV.PullTimeout
+}
+
+func SetPullTimeout(d time.Duration) {
+	instance.MCV.PullTimeout = d
+}
+
+func PushTimeout() time.Duration {
+	return instance.MCV.PushTimeout
+}
+
+func SetPushTimeout(d time.Duration) {
+	instance.MCV.PushTimeout = d
+}
+
+func SignTimeout() time.Duration {
+	return instance.MCV.SignTimeout
+}
+
+func SetSignTimeout(d time.Duration) {
+	instance.MCV.SignTimeout = d
+}
+
+func VerifyTimeout() time.Duration {
+	return instance.MCV.VerifyTimeout
+}
+
+func SetVerifyTimeout(d time.Duration) {
+	instance.MCV.VerifyTimeout = d
+}
+
+func IsPinDigestsEnabled() bool {
+	return instance.MCV.PinDigests
+}
+
+func PinLockFile() string {
+	return instance.MCV.PinLockFile
+}
+
+func InventoryFile() string {
+	return instance.MCV.InventoryFile
+}
+
+func IsUpdatePinEnabled() bool {
+	return instance.MCV.UpdatePin != nil && *instance.MCV.UpdatePin
+}
+
+func IsFIPSRequired() bool {
+	return instance.MCV.FIPS
+}
@@ -20,7 +20,9 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,6 +56,34 @@ func TestEnvironmentOverrides(t *testing.T) {
 	assert.Equal(t, "/path/to/kubeconfig", cfg.MCV.KubeConfig)
 }
 
+func TestSuppressWarnings(t *testing.T) {
+	t.Setenv("MCV_SUPPRESS_WARNINGS", "MCV1203, mcv1302")
+	defer diag.Suppress(nil)
+
+	once = sync.Once{}
+	cfg, err := Initialize(t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MCV1203", "mcv1302"}, cfg.MCV.SuppressWarnings)
+	assert.True(t, diag.Suppressed(diag.ImageExpired))
+	assert.True(t, diag.Suppressed(diag.SeveralHostArchs))
+
+	SetSuppressedWarnings([]string{"MCV1101"})
+	assert.False(t, diag.Suppressed(diag.ImageExpired))
+	assert.True(t, diag.Suppressed(diag.NoAccelerator))
+}
+
+func TestTimeouts(t *testing.T) {
+	t.Setenv("MCV_TIMEOUT", "10m")
+	t.Setenv("MCV_VERIFY_TIMEOUT", "30s")
+
+	once = sync.Once{}
+	_, err := Initialize(t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, Timeout())
+	assert.Equal(t, 30*time.Second, VerifyTimeout())
+	assert.Equal(t, defaultPullTimeout, PullTimeout())
+}
+
 func TestSetters(t *testing.T) {
 	once = sync.Once{}
 	cfg, _ := Initialize(t.TempDir())
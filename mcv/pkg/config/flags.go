@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// FlagEnvPrefix starts the name of the environment variable bound to each
+// command line flag.
+const FlagEnvPrefix = "MCV_"
+
+// FlagEnv returns the environment variable bound to the flag name of the
+// command scope, the path of the command below mcv that defines the flag:
+// MCV_DEVICE_CACHE_TTL for --device-cache-ttl of mcv itself, and
+// MCV_FLEET_ROLLOUT_IMAGE for --image of mcv fleet-rollout.
+func FlagEnv(scope, name string) string {
+	if scope != "" {
+		name = scope + " " + name
+	}
+	return FlagEnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(name))
+}
+
+// BindFlags sets each flag in fs not given on the command line from its
+// environment variable or, failing that, from the config dir, so options
+// are taken from flags, then the environment, then the config dir, then
+// the defaults. scope returns the scope of a flag for FlagEnv, so that
+// the variables set for mcv do not fill in the flags of its subcommands;
+// nil binds every flag unscoped. Repeatable flags take a comma separated
+// list.
+func BindFlags(fs *pflag.FlagSet, scope func(name string) string) error {
+	confDir := ""
+	if instance != nil {
+		confDir = instance.ConfDir
+	}
+	var errs []error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "help" || f.Name == "version" {
+			return
+		}
+		key := FlagEnv("", f.Name)
+		if scope != nil {
+			key = FlagEnv(scope(f.Name), f.Name)
+		}
+		val, ok := lookupConfig(key, confDir)
+		if !ok {
+			return
+		}
+		values := []string{val}
+		if strings.HasSuffix(f.Value.Type(), "Array") {
+			values = strings.Split(val, ",")
+		}
+		for _, v := range values {
+			if err := fs.Set(f.Name, strings.TrimSpace(v)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: %w", key, val, err))
+				return
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagEnv(t *testing.T) {
+	assert.Equal(t, "MCV_DEVICE_CACHE_TTL", FlagEnv("", "device-cache-ttl"))
+	assert.Equal(t, "MCV_IMAGE", FlagEnv("", "image"))
+	assert.Equal(t, "MCV_REMOVE_IMAGE", FlagEnv("remove", "image"))
+	assert.Equal(t, "MCV_FLEET_ROLLOUT_IMAGE", FlagEnv("fleet-rollout", "image"))
+}
+
+func TestBindFlagsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ConfFile), []byte(`# Set by the DaemonSet
+MCV_IMAGE=quay.io/example/cache:v1
+MCV_DIR = "/models/triton"
+MCV_LOG_LEVEL=info
+`), 0644))
+	once = sync.Once{}
+	_, err := Initialize(dir)
+	assert.NoError(t, err)
+
+	t.Setenv("MCV_LOG_LEVEL", "debug")
+	t.Setenv("MCV_EXTRACT", "true")
+	t.Setenv("MCV_DEVICE_CACHE_TTL", "1h")
+	t.Setenv("MCV_LABEL", "a=1,b=2")
+
+	fs := pflag.NewFlagSet("mcv", pflag.ContinueOnError)
+	image := fs.String("image", "", "")
+	cacheDir := fs.String("dir", "", "")
+	logLevel := fs.String("log-level", "", "")
+	extract := fs.Bool("extract", false, "")
+	ttl := fs.Duration("device-cache-ttl", 10*time.Minute, "")
+	labels := fs.StringArray("label", nil, "")
+	builder := fs.String("builder", "native", "")
+	assert.NoError(t, fs.Parse([]string{"--image", "quay.io/example/cache:v2"}))
+
+	assert.NoError(t, BindFlags(fs, nil))
+	assert.Equal(t, "quay.io/example/cache:v2", *image) // The flag wins over the config file
+	assert.Equal(t, "debug", *logLevel)                 // The environment wins over the config file
+	assert.Equal(t, "/models/triton", *cacheDir)
+	assert.True(t, *extract)
+	assert.Equal(t, time.Hour, *ttl)
+	assert.Equal(t, []string{"a=1", "b=2"}, *labels)
+	assert.Equal(t, "native", *builder)
+	assert.False(t, fs.Changed("builder"))
+}
+
+func TestBindFlagsScoped(t *testing.T) {
+	once = sync.Once{}
+	_, err := Initialize(t.TempDir())
+	assert.NoError(t, err)
+
+	t.Setenv("MCV_IMAGE", "quay.io/example/cache:v1")
+	t.Setenv("MCV_OUTPUT", "json")
+	t.Setenv("MCV_LOG_LEVEL", "debug")
+	t.Setenv("MCV_CAPTURE_OUTPUT", "/tmp/capture")
+
+	fs := pflag.NewFlagSet("capture", pflag.ContinueOnError)
+	image := fs.String("image", "", "")
+	output := fs.String("output", "", "")
+	logLevel := fs.String("log-level", "", "")
+	assert.NoError(t, fs.Parse(nil))
+
+	assert.NoError(t, BindFlags(fs, func(name string) string {
+		if name == "log-level" {
+			return ""
+		}
+		return "capture"
+	}))
+	assert.Empty(t, *image)
+	assert.Equal(t, "/tmp/capture", *output)
+	assert.Equal(t, "debug", *logLevel)
+}
+
+func TestBindFlagsInvalidValue(t *testing.T) {
+	once = sync.Once{}
+	_, err := Initialize(t.TempDir())
+	assert.NoError(t, err)
+
+	t.Setenv("MCV_DEVICE_CACHE_TTL", "soon")
+	fs := pflag.NewFlagSet("mcv", pflag.ContinueOnError)
+	fs.Duration("device-cache-ttl", 0, "")
+	assert.ErrorContains(t, BindFlags(fs, nil), `invalid MCV_DEVICE_CACHE_TTL "soon"`)
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ConfFile), []byte("MCV_BUILDER=podman\nENABLE_GPU=false\nnot an option\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, envBaseImage), []byte("ubi9\n"), 0644))
+
+	mcv := getMCVConfig(dir)
+	assert.Equal(t, "podman", mcv.Builder)
+	assert.False(t, *mcv.EnabledGPU)
+	assert.Equal(t, "ubi9", mcv.BaseImage)
+}
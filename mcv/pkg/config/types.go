@@ -16,18 +16,105 @@ limitations under the License.
 
 package config
 
+import "time"
+
 const (
 	envEnableGPU       = "ENABLE_GPU"
 	envSkipPrecheck    = "SKIP_PRECHECK"
 	envEnableBaremetal = "ENABLE_BAREMETAL"
+	envResumeExtract   = "RESUME_EXTRACT"
+	envForceExtract    = "MCV_FORCE_EXTRACT"
 	envKubeConfig      = "KUBE_CONFIG"
 	envKeplerNamespace = "KEPLER_NAMESPACE"
+	envBaseImage       = "MCV_BASE_IMAGE"
+	envBuilder         = "MCV_BUILDER"
+	envPreflightCache  = "MCV_PREFLIGHT_CACHE"
+	envPreflightTTL    = "MCV_PREFLIGHT_CACHE_TTL"
+	envNoPreflight     = "MCV_NO_PREFLIGHT_CACHE"
+	envDeviceCache     = "MCV_DEVICE_CACHE"
+	envDeviceCacheTTL  = "MCV_DEVICE_CACHE_TTL"
+	envDeviceFixture   = "MCV_DEVICE_FIXTURE"
+	envContainerized   = "MCV_CONTAINERIZED"
+	envHostRoot        = "MCV_HOST_ROOT"
+	envHostHome        = "MCV_HOST_HOME"
+	envCompression     = "MCV_COMPRESSION"
+	envCompressionLvl  = "MCV_COMPRESSION_LEVEL"
+	envCompressionJobs = "MCV_COMPRESSION_WORKERS"
+	envSecretScan      = "MCV_SECRET_SCAN"
+	envRegistryQPS     = "MCV_REGISTRY_QPS"
+	envRegistryBurst   = "MCV_REGISTRY_BURST"
+	envRegistryRetries = "MCV_REGISTRY_RETRIES"
+	envBreakerFailures = "MCV_REGISTRY_BREAKER_FAILURES"
+	envBreakerCooldown = "MCV_REGISTRY_BREAKER_COOLDOWN"
+	envPinDigests      = "MCV_PIN_DIGESTS"
+	envPinLockFile     = "MCV_PIN_LOCK_FILE"
+	envUpdatePin       = "MCV_UPDATE_PIN"
+	envInventoryFile   = "MCV_INVENTORY_FILE"
+	envFIPS            = "MCV_FIPS"
+	envAnnotatePlugin  = "MCV_ANNOTATE_PLUGIN"
+	envVLLMPython      = "MCV_VLLM_PYTHON"
+	envVLLMKeyMismatch = "MCV_VLLM_KEY_MISMATCH"
+	envMountFSImage    = "MCV_MOUNT_FS_IMAGE"
+	envExtractFileMode = "MCV_EXTRACT_FILE_MODE"
+	envExtractDirMode  = "MCV_EXTRACT_DIR_MODE"
+	envExtractOwner    = "MCV_EXTRACT_OWNER"
+	envKeepTemp        = "MCV_KEEP_TEMP"
+	envExpiredPolicy   = "MCV_EXPIRED_POLICY"
+	envSharedLock      = "MCV_SHARED_LOCK"
+	envSharedLockWait  = "MCV_SHARED_LOCK_TIMEOUT"
+	envSharedLockStale = "MCV_SHARED_LOCK_STALE"
+	envTempMaxSize     = "MCV_TEMP_MAX_SIZE"
+	envTelemetry       = "MCV_TELEMETRY_ENDPOINT"
+	envDigestOnly      = "MCV_DIGEST_ONLY"
+	envReadyFile       = "MCV_READY_FILE"
+	envHWSnapshot      = "MCV_HW_SNAPSHOT"
+	envEntryErrors     = "MCV_ENTRY_ERRORS"
+	envNice            = "MCV_NICE"
+	envIONice          = "MCV_IONICE"
+	envWriteLimit      = "MCV_WRITE_LIMIT"
+	envCacheStore      = "MCV_CACHE_STORE"
+	envTmpfsSize       = "MCV_TMPFS_SIZE"
+	envBucket          = "MCV_BUCKET"
+	envBucketEndpoint  = "MCV_BUCKET_ENDPOINT"
+	envBucketMount     = "MCV_BUCKET_MOUNT"
+	envKernels         = "MCV_KERNELS"
+	envSuppress        = "MCV_SUPPRESS_WARNINGS"
+	envTimeout         = "MCV_TIMEOUT"
+	envPullTimeout     = "MCV_PULL_TIMEOUT"
+	envPushTimeout     = "MCV_PUSH_TIMEOUT"
+	envSignTimeout     = "MCV_SIGN_TIMEOUT"
+	envVerifyTimeout   = "MCV_VERIFY_TIMEOUT"
 
-	defaultNamespace  = "mcv"
-	defaultKubeConfig = ""
-	defaultConfDir    = "/tmp/mcv/"
-	defaultConfFile   = "mcv.config"
-	GPU               = "gpu"
+	defaultNamespace      = "mcv"
+	defaultKubeConfig     = ""
+	defaultBaseImage      = "scratch"
+	defaultPreflightCache = "/tmp/mcv_preflight_cache.json"
+	defaultPreflightTTL   = 10 * time.Minute
+	defaultDeviceCache    = "/tmp/device_cache.json"
+	defaultDeviceCacheTTL = 10 * time.Minute
+	defaultHostRoot       = "/host"
+	defaultRegistryQPS    = 10
+	defaultRegistryBurst  = 20
+	defaultRegistryRetry  = 4
+	defaultBreakerFails   = 5
+	defaultBreakerCool    = 30 * time.Second
+	defaultVLLMPython     = "python3"
+	defaultVLLMMismatch   = "refuse"
+	defaultExpiredPolicy  = "warn"
+	defaultEntryErrors    = "fail"
+	defaultCacheStore     = "dir"
+	defaultKernels        = "all"
+	defaultSharedLock     = "auto"
+	defaultLockTimeout    = 30 * time.Minute
+	defaultLockStale      = 2 * time.Minute
+	defaultTempMaxSize    = 5 << 30
+	defaultPullTimeout    = 30 * time.Minute
+	defaultPushTimeout    = 30 * time.Minute
+	defaultSignTimeout    = 5 * time.Minute
+	defaultVerifyTimeout  = 5 * time.Minute
+	defaultConfDir        = "/tmp/mcv/"
+	defaultConfFile       = "mcv.config"
+	GPU                   = "gpu"
 )
 
 var ConfDir string = "/tmp/mcv/"
@@ -4,7 +4,7 @@ import (
 	"os"
 	"path/filepath"
 
-	logging "github.com/sirupsen/logrus"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 )
 
 // Core default paths and environment keys
@@ -12,6 +12,7 @@ const (
 	VLLM             = "vllm"
 	Triton           = "triton"
 	MCVBuildDir      = "/tmp/.mcv"
+	MCVDebugDir      = "/tmp/.mcv-debug" // Where --keep-temp keeps temporary files
 	CacheDir         = "cache"
 	ManifestDir      = "manifest"
 	ManifestFileName = "manifest.json"
@@ -22,44 +23,78 @@ const (
 	MCVTritonManifestDir = "io.triton.manifest"
 	MCVVLLMCacheDir      = "io.vllm.cache"
 	MCVVLLMManifestDir   = "io.vllm.manifest"
+	MCVTritonDumpDir     = "io.triton.dump/"
+	MCVTritonOverrideDir = "io.triton.override/"
 
-	EnvTritonCacheDir = "TRITON_CACHE_DIR"
+	EnvTritonCacheDir    = "TRITON_CACHE_DIR"
+	EnvTritonDumpDir     = "TRITON_DUMP_DIR"
+	EnvTritonOverrideDir = "TRITON_OVERRIDE_DIR"
+	EnvImageStoreDir     = "MCV_IMAGE_STORE"
 )
 
 // Configurable runtime paths
 var (
 	TritonCacheDir     string
+	TritonDumpDir      string // Where Triton dumps IR when TRITON_KERNEL_DUMP is set
+	TritonOverrideDir  string // Where Triton loads IR from when TRITON_KERNEL_OVERRIDE is set
 	ExtractCacheDir    string
 	ExtractManifestDir string
 	VLLMCacheDir       string
+	ImageStoreDir      string // OCI layout directory holding locally built images
+	ComposeStateDir    string // Where mcv compose records what it extracted
+	ApplyStateFile     string // Where mcv apply records the caches it extracted
+	PinLockFile        string // Default lock file for digest pins
+	InventoryFile      string // Default file recording the images extracted on the host
+	HWSnapshotFile     string // Default file hw-info records the host's GPUs and drivers to
+	FSImageDir         string // Where mounted filesystem image caches are kept
+	TrustDir           string // Where signature trust material synced from the cluster is kept
 	HasTritonCache     bool
 	HasVLLMCache       bool
 	LogLevels          = []string{"debug", "info", "warning", "error"} // accepted log levels
 )
 
 func init() {
-	HasTritonCache = false
-	HasVLLMCache = false
 	ExtractCacheDir = ""
 	// Derive user's home directory as the Triton/vLLM caches are stored somewhere here.
 	home, err := os.UserHomeDir()
 	if err != nil || home == "" {
-		logging.Warnf("Failed to determine user home dir, falling back to /tmp: %v", err)
+		diag.Warnf(diag.NoHomeDir, "Failed to determine user home dir, falling back to /tmp: %v", err)
 		home = "/tmp"
 	}
 
-	// Determine Triton cache directory
-	if val := os.Getenv(EnvTritonCacheDir); val != "" {
-		TritonCacheDir = val
+	SetUserHome(home)
+	if val := os.Getenv(EnvImageStoreDir); val != "" {
+		ImageStoreDir = val
 	} else {
-		TritonCacheDir = filepath.Join(home, ".triton", "cache")
-	}
-	if _, err := os.Stat(TritonCacheDir); err == nil {
-		HasTritonCache = true
+		ImageStoreDir = filepath.Join(home, ".mcv", "oci")
 	}
+	ComposeStateDir = filepath.Join(home, ".mcv", "compose")
+	ApplyStateFile = filepath.Join(home, ".mcv", "node-caches.json")
+	PinLockFile = filepath.Join(home, ".mcv", "pins.json")
+	InventoryFile = filepath.Join(home, ".mcv", "installed.json")
+	HWSnapshotFile = filepath.Join(home, ".mcv", "hw-snapshot.json")
+	FSImageDir = filepath.Join(home, ".mcv", "fsimages")
+	TrustDir = filepath.Join(home, ".mcv", "trust")
+}
 
+// SetUserHome derives the Triton and vLLM cache paths not set by the
+// environment from home, such as the host user's home directory when mcv
+// runs in a container, and checks whether the caches exist.
+func SetUserHome(home string) {
+	TritonCacheDir = envOrDefault(EnvTritonCacheDir, filepath.Join(home, ".triton", "cache"))
+	TritonDumpDir = envOrDefault(EnvTritonDumpDir, filepath.Join(home, ".triton", "dump"))
+	TritonOverrideDir = envOrDefault(EnvTritonOverrideDir, filepath.Join(home, ".triton", "override"))
 	VLLMCacheDir = filepath.Join(home, VLLMCache)
-	if _, err := os.Stat(VLLMCacheDir); err == nil {
-		HasVLLMCache = true
+
+	_, err := os.Stat(TritonCacheDir)
+	HasTritonCache = err == nil
+	_, err = os.Stat(VLLMCacheDir)
+	HasVLLMCache = err == nil
+}
+
+func envOrDefault(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
 	}
+	return def
 }
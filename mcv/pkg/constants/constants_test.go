@@ -0,0 +1,29 @@
+package constants
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetUserHome(t *testing.T) {
+	t.Setenv(EnvTritonCacheDir, "")
+	t.Setenv(EnvTritonDumpDir, "")
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".triton", "cache"), 0755))
+
+	SetUserHome(home)
+	assert.Equal(t, filepath.Join(home, ".triton", "cache"), TritonCacheDir)
+	assert.Equal(t, filepath.Join(home, ".triton", "dump"), TritonDumpDir)
+	assert.Equal(t, filepath.Join(home, ".cache", "vllm"), VLLMCacheDir)
+	assert.True(t, HasTritonCache)
+	assert.False(t, HasVLLMCache)
+
+	// The environment still wins over the home directory.
+	t.Setenv(EnvTritonCacheDir, "/models/triton")
+	SetUserHome(home)
+	assert.Equal(t, "/models/triton", TritonCacheDir)
+	assert.False(t, HasTritonCache)
+}
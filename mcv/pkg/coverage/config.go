@@ -0,0 +1,158 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelConfig holds the vLLM serving settings that decide which compiled
+// artifacts a deployment asks for.
+type ModelConfig struct {
+	MaxModelLen          int    `json:"maxModelLen,omitempty"`
+	Dtype                string `json:"dtype,omitempty"`
+	TensorParallelSize   int    `json:"tensorParallelSize"`
+	PipelineParallelSize int    `json:"pipelineParallelSize"`
+	MaxNumBatchedTokens  int    `json:"maxNumBatchedTokens,omitempty"`
+	CompileSizes         []int  `json:"compileSizes,omitempty"`
+}
+
+// LoadModelConfig reads a vLLM config file, the YAML given to vllm serve
+// --config. Keys may be written with dashes or underscores; keys that do
+// not affect compilation are ignored.
+func LoadModelConfig(path string) (*ModelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model config: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid model config %s: %w", path, err)
+	}
+	cfg, err := parseModelConfig(normalizeKeys(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid model config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func parseModelConfig(raw map[string]any) (*ModelConfig, error) {
+	cfg := &ModelConfig{TensorParallelSize: 1, PipelineParallelSize: 1}
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"max-model-len", &cfg.MaxModelLen},
+		{"tensor-parallel-size", &cfg.TensorParallelSize},
+		{"pipeline-parallel-size", &cfg.PipelineParallelSize},
+		{"max-num-batched-tokens", &cfg.MaxNumBatchedTokens},
+	}
+	for _, f := range ints {
+		v, ok := raw[f.key]
+		if !ok {
+			continue
+		}
+		n, err := toInt(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: expected a non-negative integer, got %v", f.key, v)
+		}
+		*f.dst = n
+	}
+	if cfg.TensorParallelSize == 0 || cfg.PipelineParallelSize == 0 {
+		return nil, fmt.Errorf("parallel sizes must be at least 1")
+	}
+	if v, ok := raw["dtype"]; ok {
+		cfg.Dtype = fmt.Sprint(v)
+	}
+
+	// compile sizes are set in the compilation config, which the CLI takes
+	// as a JSON string and config files as a mapping.
+	comp, ok := raw["compilation-config"]
+	if s, isString := comp.(string); isString {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return nil, fmt.Errorf("compilation-config: %w", err)
+		}
+		comp = m
+	}
+	if m, isMap := comp.(map[string]any); ok && isMap {
+		if sizes, ok := normalizeKeys(m)["compile-sizes"]; ok {
+			list, isList := sizes.([]any)
+			if !isList {
+				return nil, fmt.Errorf("compile_sizes: expected a list, got %v", sizes)
+			}
+			for _, s := range list {
+				n, err := toInt(s)
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("compile_sizes: expected positive integers, got %v", s)
+				}
+				cfg.CompileSizes = append(cfg.CompileSizes, n)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// normalizeKeys returns m with underscores in its keys replaced by dashes.
+func normalizeKeys(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[strings.ReplaceAll(k, "_", "-")] = v
+	}
+	return out
+}
+
+func toInt(v any) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case float64:
+		if n != float64(int(n)) {
+			return 0, fmt.Errorf("not an integer: %v", n)
+		}
+		return int(n), nil
+	case string:
+		return strconv.Atoi(n)
+	}
+	return 0, fmt.Errorf("not an integer: %v", v)
+}
+
+// worldSize is the number of ranks that each compile their own graphs.
+func (c *ModelConfig) worldSize() int {
+	return c.TensorParallelSize * c.PipelineParallelSize
+}
+
+// requestedSizes returns the compile sizes vLLM will compile for: sizes
+// above the batch token limit are never used.
+func (c *ModelConfig) requestedSizes() (sizes, dropped []int) {
+	limit := c.MaxNumBatchedTokens
+	if limit == 0 {
+		limit = c.MaxModelLen
+	}
+	for _, s := range c.CompileSizes {
+		if limit > 0 && s > limit {
+			dropped = append(dropped, s)
+			continue
+		}
+		sizes = append(sizes, s)
+	}
+	return sizes, dropped
+}
+
+// kernelDtype maps a vLLM dtype to the Triton element type its kernels
+// use, or "" when it depends on the model.
+func kernelDtype(dtype string) string {
+	switch strings.ToLower(dtype) {
+	case "bfloat16", "bf16":
+		return "bf16"
+	case "float16", "half", "fp16":
+		return "fp16"
+	case "float32", "float", "fp32":
+		return "fp32"
+	}
+	return ""
+}
@@ -0,0 +1,315 @@
+// Package coverage estimates whether a packaged vLLM compile cache holds
+// the graphs and kernels a model deployment will ask for, so missing
+// entries, which vLLM compiles at startup, are found before deploying.
+package coverage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+const (
+	torchCompileDir  = "torch_compile_cache"
+	compileIndexFile = "vllm_compile_cache.py"
+
+	// dynamicShape stands for the graphs compiled for any batch size.
+	dynamicShape = -1
+)
+
+var (
+	rankDirRegex = regexp.MustCompile(`^rank_(\d+)(?:_(\d+))?$`)
+	// Keys of the compile index, e.g. (None, 3, 'inductor') or (8, 3, 'inductor').
+	indexKeyRegex = regexp.MustCompile(`\(\s*(None|\d+)\s*,\s*(\d+)\s*,\s*'[^']*'\s*\)\s*:`)
+	// Element types of Triton kernel pointer arguments, e.g. '*bf16'.
+	kernelTypeRegex = regexp.MustCompile(`'\*(bf16|fp16|fp32)'`)
+)
+
+// Entry is the coverage of one compiled configuration of the cache, one
+// torch_compile_cache/<hash> directory.
+type Entry struct {
+	Hash   string   `json:"hash"`
+	Ranks  []int    `json:"ranks"`
+	Usable bool     `json:"usable"`          // Built for the config's world size
+	Graphs int      `json:"graphs"`          // Dynamic-shape graphs per rank
+	Sizes  []int    `json:"sizes,omitempty"` // Batch sizes compiled on every rank
+	Dtypes []string `json:"dtypes,omitempty"`
+	Gaps   []string `json:"gaps,omitempty"`
+}
+
+// Report is the coverage of a cache for a model config.
+type Report struct {
+	CacheDir string      `json:"cacheDir"`
+	Config   ModelConfig `json:"config"`
+	Entries  []Entry     `json:"entries"`
+	Best     string      `json:"best,omitempty"` // Hash of the entry with the fewest gaps
+	Gaps     []string    `json:"gaps,omitempty"` // Gaps of the best entry
+	Notes    []string    `json:"notes,omitempty"`
+}
+
+// Covered reports whether an entry of the cache covers the config.
+func (r *Report) Covered() bool {
+	return r.Best != "" && len(r.Gaps) == 0
+}
+
+// rankGraphs is what one rank directory holds: the graph indices compiled
+// for each shape, and the Triton element types of its kernels.
+type rankGraphs struct {
+	shapes map[int]map[int]bool
+	dtypes map[string]bool
+}
+
+// Analyze compares the vLLM cache in cacheDir, a vLLM cache root or its
+// torch_compile_cache directory, with cfg. Each compiled configuration in
+// the cache is checked; vLLM uses at most one of them, so the report's
+// gaps are those of the closest one.
+func Analyze(cacheDir string, cfg *ModelConfig) (*Report, error) {
+	root := filepath.Join(cacheDir, torchCompileDir)
+	if filepath.Base(filepath.Clean(cacheDir)) == torchCompileDir {
+		root = cacheDir
+	}
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("no vLLM compile cache in %s: %w", cacheDir, err)
+	}
+
+	report := &Report{CacheDir: cacheDir, Config: *cfg}
+	sizes, dropped := cfg.requestedSizes()
+	if len(dropped) > 0 {
+		report.Notes = append(report.Notes, fmt.Sprintf(
+			"compile sizes %s exceed the batch token limit and are never compiled", formatInts(dropped)))
+	}
+	want := kernelDtype(cfg.Dtype)
+	if want == "" {
+		report.Notes = append(report.Notes, fmt.Sprintf("dtype %q depends on the model: kernel types not checked", cfg.Dtype))
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entry, err := analyzeEntry(filepath.Join(root, d.Name()), cfg, sizes, want)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			report.Entries = append(report.Entries, *entry)
+		}
+	}
+	if len(report.Entries) == 0 {
+		report.Gaps = []string{"the cache holds no compiled vLLM graphs: everything is compiled at startup"}
+		return report, nil
+	}
+
+	best := 0
+	for i, e := range report.Entries {
+		if closer(e, report.Entries[best]) {
+			best = i
+		}
+	}
+	report.Best = report.Entries[best].Hash
+	report.Gaps = report.Entries[best].Gaps
+	return report, nil
+}
+
+// analyzeEntry checks one torch_compile_cache/<hash> directory. It returns
+// nil if the directory holds no ranks.
+func analyzeEntry(dir string, cfg *ModelConfig, sizes []int, want string) (*Entry, error) {
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	ranks := map[int]*rankGraphs{}
+	for _, d := range dirs {
+		m := rankDirRegex.FindStringSubmatch(d.Name())
+		if !d.IsDir() || m == nil {
+			continue
+		}
+		rank, _ := strconv.Atoi(m[1])
+		rg, err := readRank(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := ranks[rank]; ok {
+			// Data parallel replicas of a rank compile the same graphs.
+			mergeRank(prev, rg)
+			continue
+		}
+		ranks[rank] = rg
+	}
+	if len(ranks) == 0 {
+		logging.Debugf("Skipping %s: no rank directories", dir)
+		return nil, nil
+	}
+
+	entry := &Entry{Hash: filepath.Base(dir)}
+	dtypes := map[string]bool{}
+	for r, rg := range ranks {
+		entry.Ranks = append(entry.Ranks, r)
+		if n := len(rg.shapes[dynamicShape]); n > entry.Graphs {
+			entry.Graphs = n
+		}
+		for t := range rg.dtypes {
+			dtypes[t] = true
+		}
+	}
+	sort.Ints(entry.Ranks)
+	for t := range dtypes {
+		entry.Dtypes = append(entry.Dtypes, t)
+	}
+	sort.Strings(entry.Dtypes)
+
+	// vLLM hashes the parallel config into the directory name, so a cache
+	// built for another world size is never used.
+	world := cfg.worldSize()
+	built := entry.Ranks[len(entry.Ranks)-1] + 1
+	entry.Usable = built == world
+	if !entry.Usable {
+		entry.Gaps = append(entry.Gaps, fmt.Sprintf(
+			"built for %d rank(s), the config runs %d (tensor parallel %d x pipeline parallel %d): vLLM will not use it",
+			built, world, cfg.TensorParallelSize, cfg.PipelineParallelSize))
+	} else if len(entry.Ranks) < world {
+		var missing []int
+		for r := 0; r < world; r++ {
+			if _, ok := ranks[r]; !ok {
+				missing = append(missing, r)
+			}
+		}
+		entry.Gaps = append(entry.Gaps, fmt.Sprintf("rank(s) %s have no compiled graphs", formatInts(missing)))
+	}
+
+	for _, r := range entry.Ranks {
+		if n := len(ranks[r].shapes[dynamicShape]); n < entry.Graphs {
+			entry.Gaps = append(entry.Gaps, fmt.Sprintf(
+				"rank %d is missing %d of %d dynamic-shape graphs", r, entry.Graphs-n, entry.Graphs))
+		}
+	}
+
+	for _, s := range sizes {
+		var lacking []int
+		for _, r := range entry.Ranks {
+			if len(ranks[r].shapes[s]) < entry.Graphs {
+				lacking = append(lacking, r)
+			}
+		}
+		if len(lacking) == 0 {
+			entry.Sizes = append(entry.Sizes, s)
+			continue
+		}
+		entry.Gaps = append(entry.Gaps, fmt.Sprintf("batch size %d is not compiled on rank(s) %s", s, formatInts(lacking)))
+	}
+
+	if gap := dtypeGap(want, dtypes); gap != "" {
+		entry.Gaps = append(entry.Gaps, gap)
+	}
+	return entry, nil
+}
+
+// closer reports whether a is closer to the config than b: entries vLLM
+// can use first, then those with fewer gaps and more ranks.
+func closer(a, b Entry) bool {
+	if a.Usable != b.Usable {
+		return a.Usable
+	}
+	if len(a.Gaps) != len(b.Gaps) {
+		return len(a.Gaps) < len(b.Gaps)
+	}
+	return len(a.Ranks) > len(b.Ranks)
+}
+
+// dtypeGap reports kernels compiled for another precision than want.
+func dtypeGap(want string, found map[string]bool) string {
+	if want == "" || len(found) == 0 {
+		return ""
+	}
+	var halves []string
+	for _, t := range []string{"bf16", "fp16"} {
+		if found[t] {
+			halves = append(halves, t)
+		}
+	}
+	switch {
+	case want == "fp32" && len(halves) > 0 && !found["fp32"],
+		want != "fp32" && len(halves) > 0 && !found[want]:
+		return fmt.Sprintf("kernels use %s but the config asks for %s: vLLM will recompile them",
+			strings.Join(halves, ", "), want)
+	}
+	return ""
+}
+
+// readRank reads the compile index and kernel sources of a rank directory.
+func readRank(dir string) (*rankGraphs, error) {
+	rg := &rankGraphs{shapes: map[int]map[int]bool{}, dtypes: map[string]bool{}}
+	data, err := os.ReadFile(filepath.Join(dir, compileIndexFile))
+	switch {
+	case os.IsNotExist(err):
+		logging.Debugf("No compile index in %s", dir)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read compile index: %w", err)
+	}
+	for _, m := range indexKeyRegex.FindAllStringSubmatch(string(data), -1) {
+		shape := dynamicShape
+		if m[1] != "None" {
+			shape, _ = strconv.Atoi(m[1])
+		}
+		graph, _ := strconv.Atoi(m[2])
+		if rg.shapes[shape] == nil {
+			rg.shapes[shape] = map[int]bool{}
+		}
+		rg.shapes[shape][graph] = true
+	}
+
+	// Inductor keeps the generated sources as inductor_cache/<xx>/<hash>.py.
+	sources, err := filepath.Glob(filepath.Join(dir, "inductor_cache", "*", "*.py"))
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range sources {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", src, err)
+		}
+		for _, m := range kernelTypeRegex.FindAllStringSubmatch(string(data), -1) {
+			rg.dtypes[m[1]] = true
+		}
+	}
+	return rg, nil
+}
+
+func mergeRank(dst, src *rankGraphs) {
+	for shape, graphs := range src.shapes {
+		if dst.shapes[shape] == nil {
+			dst.shapes[shape] = map[int]bool{}
+		}
+		for g := range graphs {
+			dst.shapes[shape][g] = true
+		}
+	}
+	for t := range src.dtypes {
+		dst.dtypes[t] = true
+	}
+}
+
+// formatInts collapses sorted integers into ranges, e.g. "0-3,6".
+func formatInts(ids []int) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,109 @@
+package coverage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeRank writes a rank directory whose compile index holds graphs
+// 0..graphs-1 for the dynamic shape and each of sizes, and one kernel
+// source of the given element type.
+func writeRank(t *testing.T, dir string, graphs int, sizes []int, dtype string) {
+	var index strings.Builder
+	index.WriteString("{")
+	for _, shape := range append([]string{"None"}, strings.Fields(strings.Trim(fmt.Sprint(sizes), "[]"))...) {
+		for g := 0; g < graphs; g++ {
+			fmt.Fprintf(&index, "(%s, %d, 'inductor'): ('h', '/p.py'),\n", shape, g)
+		}
+	}
+	index.WriteString("}")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "inductor_cache", "ab"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, compileIndexFile), []byte(index.String()), 0644))
+	src := fmt.Sprintf("triton_meta={'signature': {'in_ptr0': '*%s', 'out_ptr0': '*fp32'}}", dtype)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "inductor_cache", "ab", "cabc.py"), []byte(src), 0644))
+}
+
+func TestAnalyze(t *testing.T) {
+	root := t.TempDir()
+	tcc := filepath.Join(root, torchCompileDir)
+	// a1 was built with TP 2 and compile size 8, b2 with TP 1.
+	writeRank(t, filepath.Join(tcc, "a1", "rank_0_0"), 3, []int{8}, "bf16")
+	writeRank(t, filepath.Join(tcc, "a1", "rank_1_0"), 3, []int{8}, "bf16")
+	writeRank(t, filepath.Join(tcc, "b2", "rank_0_0"), 3, nil, "fp16")
+
+	cfg := &ModelConfig{Dtype: "bfloat16", TensorParallelSize: 2, PipelineParallelSize: 1, MaxNumBatchedTokens: 4096, CompileSizes: []int{8, 8192}}
+	report, err := Analyze(root, cfg)
+	assert.NoError(t, err)
+	assert.True(t, report.Covered())
+	assert.Equal(t, "a1", report.Best)
+	assert.Len(t, report.Entries, 2)
+	assert.Equal(t, []int{8}, report.Entries[0].Sizes)
+	assert.Equal(t, []string{"compile sizes 8192 exceed the batch token limit and are never compiled"}, report.Notes)
+
+	// A size that was not compiled, and the wrong dtype.
+	cfg = &ModelConfig{Dtype: "float16", TensorParallelSize: 2, PipelineParallelSize: 1, CompileSizes: []int{8, 16}}
+	report, err = Analyze(tcc, cfg)
+	assert.NoError(t, err)
+	assert.False(t, report.Covered())
+	assert.Equal(t, []string{
+		"batch size 16 is not compiled on rank(s) 0-1",
+		"kernels use bf16 but the config asks for fp16: vLLM will recompile them",
+	}, report.Gaps)
+
+	// Only b2 matches TP 1, but it misses the compile size.
+	cfg = &ModelConfig{Dtype: "auto", TensorParallelSize: 1, PipelineParallelSize: 1, CompileSizes: []int{8}}
+	report, err = Analyze(root, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "b2", report.Best)
+	assert.Equal(t, []string{"batch size 8 is not compiled on rank(s) 0"}, report.Gaps)
+	assert.Contains(t, report.Entries[0].Gaps[0], "built for 2 rank(s), the config runs 1")
+}
+
+func TestAnalyzeMissingRank(t *testing.T) {
+	root := t.TempDir()
+	writeRank(t, filepath.Join(root, torchCompileDir, "a1", "rank_0_0"), 2, nil, "bf16")
+	writeRank(t, filepath.Join(root, torchCompileDir, "a1", "rank_2_0"), 1, nil, "bf16")
+
+	report, err := Analyze(root, &ModelConfig{Dtype: "bf16", TensorParallelSize: 3, PipelineParallelSize: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"rank(s) 1 have no compiled graphs",
+		"rank 2 is missing 1 of 2 dynamic-shape graphs",
+	}, report.Gaps)
+
+	_, err = Analyze(t.TempDir(), &ModelConfig{TensorParallelSize: 1, PipelineParallelSize: 1})
+	assert.Error(t, err)
+}
+
+func TestLoadModelConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `model: meta-llama/Llama-3.1-8B-Instruct
+dtype: bfloat16
+max_model_len: 8192
+tensor-parallel-size: 4
+compilation-config: '{"compile_sizes": [1, 2, 4]}'
+`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	cfg, err := LoadModelConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &ModelConfig{
+		MaxModelLen:          8192,
+		Dtype:                "bfloat16",
+		TensorParallelSize:   4,
+		PipelineParallelSize: 1,
+		CompileSizes:         []int{1, 2, 4},
+	}, cfg)
+
+	assert.NoError(t, os.WriteFile(path, []byte("compilation_config:\n  compile-sizes: [0]\n"), 0644))
+	_, err = LoadModelConfig(path)
+	assert.ErrorContains(t, err, "compile_sizes")
+
+	assert.NoError(t, os.WriteFile(path, []byte("tensor_parallel_size: 0\n"), 0644))
+	_, err = LoadModelConfig(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+// Package cri locates the filesystem of a running container through the
+// container runtime's CRI socket, so a cache can be extracted into the
+// container without restarting it.
+package cri
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+const stateRunning = "CONTAINER_RUNNING"
+
+// Container is a running container as seen from the host.
+type Container struct {
+	ID   string
+	Name string
+	PID  int
+	// Root is the container's root filesystem on the host,
+	// /proc/<pid>/root under hostfs.Root().
+	Root string
+}
+
+// inspectOutput holds the fields of crictl inspect used here.
+type inspectOutput struct {
+	Status struct {
+		ID       string `json:"id"`
+		State    string `json:"state"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	} `json:"status"`
+	Info struct {
+		PID int `json:"pid"`
+	} `json:"info"`
+}
+
+// Inspect looks up container id with crictl. endpoint is the CRI socket,
+// e.g. unix:///run/containerd/containerd.sock; if empty crictl uses its
+// configured or default endpoint.
+func Inspect(ctx context.Context, endpoint, id string) (*Container, error) {
+	args := []string{}
+	if endpoint != "" {
+		args = append(args, "--runtime-endpoint", endpoint)
+	}
+	args = append(args, "inspect", "-o", "json", id)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "crictl", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("crictl inspect %s failed: %v: %s", id, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseInspect(stdout.Bytes())
+}
+
+func parseInspect(data []byte) (*Container, error) {
+	var out inspectOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid crictl inspect output: %w", err)
+	}
+	if out.Status.State != stateRunning {
+		return nil, fmt.Errorf("container %s is not running (state %s)", out.Status.ID, out.Status.State)
+	}
+	if out.Info.PID <= 0 {
+		return nil, fmt.Errorf("runtime reported no process for container %s; is crictl run with verbose info enabled?", out.Status.ID)
+	}
+	return &Container{
+		ID:   out.Status.ID,
+		Name: out.Status.Metadata.Name,
+		PID:  out.Info.PID,
+		Root: hostfs.Path(fmt.Sprintf("/proc/%d/root", out.Info.PID)),
+	}, nil
+}
+
+// HostPath returns where path inside the container is on the host.
+// Symlinks are resolved inside the container's root, so a link in the
+// container cannot point the extraction at the host's filesystem.
+func (c *Container) HostPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("container path %q must be absolute", path)
+	}
+	p, err := securejoin.SecureJoin(c.Root, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s in container %s: %w", path, c.ID, err)
+	}
+	return p, nil
+}
+
+// Owner returns the owner of path, or of its nearest existing parent, so
+// files added for the container can be given to the user it runs as.
+func Owner(path string) (uid, gid int, err error) {
+	for p := path; ; p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		if err == nil {
+			st, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				return 0, 0, fmt.Errorf("cannot read the owner of %s", p)
+			}
+			return int(st.Uid), int(st.Gid), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) || p == filepath.Dir(p) {
+			return 0, 0, err
+		}
+	}
+}
+
+// Chown gives every file and directory under root to uid and gid, without
+// following symlinks.
+func Chown(root string, uid, gid int) error {
+	return filepath.WalkDir(root, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, uid, gid)
+	})
+}
@@ -0,0 +1,62 @@
+package cri
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+func TestParseInspect(t *testing.T) {
+	c, err := parseInspect([]byte(`{
+  "status": {"id": "abc123", "state": "CONTAINER_RUNNING", "metadata": {"name": "vllm"}},
+  "info": {"pid": 4242}
+}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Container{ID: "abc123", Name: "vllm", PID: 4242, Root: "/proc/4242/root"}, c)
+
+	_, err = parseInspect([]byte(`{"status": {"id": "abc123", "state": "CONTAINER_EXITED"}, "info": {"pid": 0}}`))
+	assert.ErrorContains(t, err, "not running")
+
+	_, err = parseInspect([]byte(`{"status": {"id": "abc123", "state": "CONTAINER_RUNNING"}}`))
+	assert.ErrorContains(t, err, "no process")
+}
+
+func TestParseInspectUnderHostRoot(t *testing.T) {
+	assert.NoError(t, hostfs.SetRoot("/host"))
+	defer hostfs.SetRoot("/")
+
+	c, err := parseInspect([]byte(`{"status": {"id": "abc123", "state": "CONTAINER_RUNNING"}, "info": {"pid": 4242}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "/host/proc/4242/root", c.Root)
+}
+
+func TestHostPathStaysInRoot(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "home", "vllm"), 0755))
+	// An absolute link resolves inside the container, not on the host.
+	assert.NoError(t, os.Symlink("/etc", filepath.Join(root, "home", "vllm", ".cache")))
+	c := &Container{ID: "abc123", Root: root}
+
+	p, err := c.HostPath("/home/vllm/.cache/vllm")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "etc", "vllm"), p)
+
+	p, err = c.HostPath("/../../tmp")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "tmp"), p)
+
+	_, err = c.HostPath("relative/path")
+	assert.Error(t, err)
+}
+
+func TestOwnerOfMissingPath(t *testing.T) {
+	dir := t.TempDir()
+	uid, gid, err := Owner(filepath.Join(dir, "a", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getuid(), uid)
+	assert.Equal(t, os.Getgid(), gid)
+}
@@ -0,0 +1,233 @@
+package diag
+
+// Codes of the warnings mcv reports. A code is never reused for another
+// condition once released: scripts and suppression lists rely on it. The
+// hundreds group them by area: 10xx configuration and housekeeping, 11xx
+// GPUs and hardware detection, 12xx extraction, 13xx packaging, 14xx
+// registries, rollouts and trust, 15xx capture and usage tracking.
+const (
+	UnknownCode          Code = "MCV1000"
+	InvalidConfigValue   Code = "MCV1001"
+	InvalidConfigLine    Code = "MCV1002"
+	NoHomeDir            Code = "MCV1003"
+	FaultsInjected       Code = "MCV1004"
+	KeepingTemp          Code = "MCV1005"
+	CleanupFailed        Code = "MCV1006"
+	BuildDirLock         Code = "MCV1007"
+	PreflightCacheFailed Code = "MCV1008"
+	BootstrapStepSkipped Code = "MCV1009"
+	TempNotKept          Code = "MCV1010"
+
+	NoAccelerator       Code = "MCV1101"
+	NoCompatibleGPU     Code = "MCV1102"
+	NoGPUForPlacement   Code = "MCV1103"
+	DeviceRecording     Code = "MCV1104"
+	DeviceCacheCleanup  Code = "MCV1105"
+	DeviceEventsMissed  Code = "MCV1106"
+	HWSnapshotNotSaved  Code = "MCV1107"
+	UnexpectedArchValue Code = "MCV1108"
+	HostCheckWarning    Code = "MCV1109"
+
+	PartialExtraction   Code = "MCV1201"
+	EntrySkipped        Code = "MCV1202"
+	ImageExpired        Code = "MCV1203"
+	VLLMKeyMismatch     Code = "MCV1204"
+	IncompatibleKept    Code = "MCV1205"
+	PinUpdated          Code = "MCV1206"
+	MarkerIgnored       Code = "MCV1207"
+	JournalIgnored      Code = "MCV1208"
+	PathsNotRelocated   Code = "MCV1209"
+	ReadyFileFailed     Code = "MCV1210"
+	LeaseTakenOver      Code = "MCV1211"
+	LeaseLost           Code = "MCV1212"
+	LockReleaseFailed   Code = "MCV1213"
+	ExtractInterrupted  Code = "MCV1214"
+	ManifestsNotKept    Code = "MCV1215"
+	UnmountFailed       Code = "MCV1216"
+	ProbeStopFailed     Code = "MCV1217"
+	ComponentSkipped    Code = "MCV1218"
+	OptionalCacheFailed Code = "MCV1219"
+	CacheNotRemoved     Code = "MCV1220"
+	InventoryNotUpdated Code = "MCV1221"
+
+	CachesInSeveralDirs  Code = "MCV1301"
+	SeveralHostArchs     Code = "MCV1302"
+	PossibleSecret       Code = "MCV1303"
+	SecretsRedacted      Code = "MCV1304"
+	ReservedLabel        Code = "MCV1305"
+	PluginOverridesLabel Code = "MCV1306"
+	EntriesNotPackaged   Code = "MCV1307"
+	VLLMVersionsUnknown  Code = "MCV1308"
+	NoTritonCache        Code = "MCV1309"
+	VLLMCacheIncomplete  Code = "MCV1310"
+	SummaryInLayer       Code = "MCV1311"
+	HotLayerUnordered    Code = "MCV1312"
+	CacheReadFailed      Code = "MCV1313"
+	TemplateIgnored      Code = "MCV1314"
+
+	RegistryBreakerOpen Code = "MCV1401"
+	ReferrersNotCopied  Code = "MCV1402"
+	RolloutAborted      Code = "MCV1403"
+	TrustNotWatched     Code = "MCV1404"
+
+	CaptureEventsDropped Code = "MCV1501"
+	AccessTimesNotReset  Code = "MCV1502"
+	CapturedCommandFail  Code = "MCV1503"
+	CaptureWatchFailed   Code = "MCV1504"
+)
+
+var diagnostics = []Diagnostic{
+	{UnknownCode, "Unknown diagnostic code", `A code listed in MCV_SUPPRESS_WARNINGS is not one mcv reports, so it
+suppresses nothing. Check it for typos; mcv explain lists every code.`},
+	{InvalidConfigValue, "Invalid configuration value", `An environment variable or mcv.config entry holds a value that is not a
+valid number, duration or size, and mcv uses the option's default
+instead. Correct the value named in the warning.`},
+	{InvalidConfigLine, "Malformed configuration file line", `A line of mcv.config in the config directory is not KEY=value and was
+ignored. Blank lines and lines starting with # are allowed.`},
+	{NoHomeDir, "Home directory unknown", `mcv could not determine the user's home directory and looks for and
+keeps its files under /tmp instead. Set HOME, or run mcv as a user with
+a home directory.`},
+	{FaultsInjected, "Extraction faults injected", `MCV_FAULTS is set, so extraction deliberately fails at the points it
+names. It is meant for testing recovery only; unset it in production.`},
+	{KeepingTemp, "Keeping temporary files", `--keep-temp (MCV_KEEP_TEMP) is set, so the build context, image layout
+and fetched manifests are kept in the directory named for debugging.
+Remove it once done; MCV_TEMP_MAX_SIZE bounds how much is kept.`},
+	{CleanupFailed, "Temporary files not removed", `mcv could not remove a temporary file or directory it created. It does
+not affect the result, but the files take up space until removed by
+hand or by mcv cleanup.`},
+	{BuildDirLock, "Build directory not locked", `mcv could not lock its build directory, so another mcv process may
+remove it while it is in use. Check the permissions of the directory
+named.`},
+	{PreflightCacheFailed, "Preflight result not cached", `The result of the GPU compatibility check could not be written to the
+preflight cache (MCV_PREFLIGHT_CACHE), so the next run checks again.`},
+	{BootstrapStepSkipped, "Bootstrap step skipped", `mcv bootstrap did not run a setup step because its command is not
+installed, e.g. semodule on hosts without SELinux. Run the step by hand
+if the host needs it.`},
+	{TempNotKept, "Temporary files not kept", `With --keep-temp, a temporary file or directory could not be moved to
+the debug directory. It is left where it was, or not kept at all.`},
+
+	{NoAccelerator, "No accelerator found", `No supported GPU was detected, so GPU compatibility checks are
+disabled. Check that the GPU drivers and their libraries are installed
+and visible to mcv, e.g. mounted into its container.`},
+	{NoCompatibleGPU, "No compatible GPU", `None of the host's GPUs matches the targets the image was built for,
+so its kernels cannot be used here. mcv inspect shows the image's
+targets and mcv gpu-info the host's; build a cache for this GPU.`},
+	{NoGPUForPlacement, "No GPU for a placement", `A placement file maps a cache directory to GPUs or a NUMA node this
+host does not have, and that directory is not extracted.`},
+	{DeviceRecording, "Device recording incomplete", `--record-devices could not record what a GPU library or tool reports,
+so a replay of the recording lacks it.`},
+	{DeviceCacheCleanup, "Device cache not removed", `The file caching the detected GPUs (MCV_DEVICE_CACHE) could not be
+removed, and may be read again until it expires.`},
+	{DeviceEventsMissed, "GPU hot-plug events missed", `Device events were lost while watching for GPU changes, so mcv detects
+the GPUs again to catch up.`},
+	{HWSnapshotNotSaved, "Hardware snapshot not recorded", `hw-info could not record the host's hardware to MCV_HW_SNAPSHOT, so
+mcv hw-diff compares with an older snapshot.`},
+	{UnexpectedArchValue, "Unrecognised GPU target", `A cache entry records its GPU target in a form mcv does not recognise,
+and the entry is treated as having no target.`},
+	{HostCheckWarning, "Host check warning", `A baremetal preflight check (--baremetal) found something on the host
+that may keep the GPUs from using the cache, without ruling it out. The
+warning names the check and what it found.`},
+
+	{PartialExtraction, "Cache extracted partially", `With --entry-errors skip, entries that were corrupt or could not be
+written were skipped, and the rest of the cache extracted. The workload
+compiles the skipped kernels again. The directory is not marked as
+extracted, so the next run retries them.`},
+	{EntrySkipped, "Cache entry skipped", `A cache entry was corrupt or could not be written and was skipped, as
+--entry-errors skip asks. See MCV1201.`},
+	{ImageExpired, "Cache image expired", `The image is past the valid-until date it was built with, so its
+kernels may target a retired driver. Rebuild it, or extract with
+--expired block to refuse expired images.`},
+	{VLLMKeyMismatch, "vLLM cache built for other versions", `The vLLM cache was built with other vLLM, torch or Triton versions than
+the local installation, which would not use it, so it is extracted to
+its own directory for a matching installation. --vllm-key-mismatch
+chooses what extract does instead.`},
+	{IncompatibleKept, "Incompatible kernel kept", `With MCV_KERNELS=compatible, a kernel the manifest does not locate
+could not be filtered out and was extracted anyway.`},
+	{PinUpdated, "Digest pin updated", `A pinned tag now points at another digest and --update-pin recorded the
+new one. Without it the extraction would have been refused.`},
+	{MarkerIgnored, "Extraction marker ignored", `The marker recording which image was extracted to the directory could
+not be read, so the image is extracted again.`},
+	{JournalIgnored, "Extraction journal ignored", `The journal of an interrupted extraction could not be read, so the
+extraction starts over instead of resuming.`},
+	{PathsNotRelocated, "Paths not rewritten", `Paths recorded in a cache file could not be rewritten for the directory
+it was extracted to. The entry may be recompiled on first use.`},
+	{ReadyFileFailed, "Ready file not written", `The file extract writes to report the cache ready (MCV_READY_FILE)
+could not be written or removed, so whatever waits on it is not told.`},
+	{LeaseTakenOver, "Shared lock taken over", `Another node held the lock of a shared cache directory but stopped
+renewing it, so it is presumed dead and this node took over.
+MCV_SHARED_LOCK_STALE sets how long that takes.`},
+	{LeaseLost, "Shared lock lost", `The lock of a shared cache directory could not be renewed, and another
+node may start extracting to it.`},
+	{LockReleaseFailed, "Shared lock not released", `The lock of a shared cache directory could not be released, so other
+nodes wait until it goes stale.`},
+	{ExtractInterrupted, "Extraction interrupted", `mcv was stopped before the cache was extracted. The next run resumes
+or restarts the extraction.`},
+	{ManifestsNotKept, "Fetched manifests not kept", `The manifests fetched for the extraction could not be kept for
+debugging or later inspection.`},
+	{UnmountFailed, "Filesystem image not unmounted", `A filesystem image cache could not be unmounted and stays mounted until
+unmounted by hand.`},
+	{ProbeStopFailed, "Probe server not stopped", `The readiness probe server did not shut down cleanly.`},
+	{ComponentSkipped, "Component skipped", `A composed cache component was not extracted because a component it
+depends on was not.`},
+	{OptionalCacheFailed, "Optional cache not applied", `mcv apply could not extract a cache the node caches file marks optional,
+e.g. because no GPU of the node can use it, and carried on with the
+others. The next apply tries again.`},
+	{CacheNotRemoved, "Cache no longer listed kept", `A cache mcv apply extracted is no longer in the node caches file, and
+was left in place because pruning is off. Remove it with mcv apply
+--prune.`},
+	{InventoryNotUpdated, "Inventory not updated", `The cache was extracted, but could not be recorded in the inventory of
+the images extracted on the host (MCV_INVENTORY_FILE), so mcv
+list-installed does not show it and mcv remove cannot remove it.
+Extracting it again records it.`},
+
+	{CachesInSeveralDirs, "Caches in several directories", `The same kind of cache was found in more than one of the directories
+to package. Package them as separate images.`},
+	{SeveralHostArchs, "Several CPU architectures", `The cache holds host code built for several CPU architectures, e.g.
+caches merged from different hosts. Each host can only use the code of
+its own architecture; package each architecture separately.`},
+	{PossibleSecret, "Possible secret in the cache", `The secret scan found something that looks like a credential in a
+cache file. Check the file named; --secret-scan redact removes them.`},
+	{SecretsRedacted, "Secrets redacted", `The secret scan removed possible credentials from the packaged cache.`},
+	{ReservedLabel, "Reserved label ignored", `A label or annotation given with --label or by the annotate plugin
+uses a key mcv sets itself, and was ignored.`},
+	{PluginOverridesLabel, "Label overridden by plugin", `The annotate plugin returned a label also given with --label, and the
+plugin's value is used.`},
+	{EntriesNotPackaged, "Cache entries not packaged", `Entries were left out of the image by --filter-from or because they
+could not be read.`},
+	{VLLMVersionsUnknown, "vLLM versions not recorded", `The vLLM, torch and Triton versions the cache was built with could not
+be determined, so extract cannot check that they match. Set
+MCV_VLLM_PYTHON to the interpreter of the vLLM installation.`},
+	{NoTritonCache, "No Triton cache", `A directory given to package holds no Triton cache and was ignored.`},
+	{VLLMCacheIncomplete, "vLLM cache incomplete", `A vLLM cache entry lacks its torch compile or Triton cache, and is
+packaged without it.`},
+	{SummaryInLayer, "Summary stored in a layer", `The cache summary is larger than registries accept in the image config,
+so it is stored in a metadata layer the label points to instead. No
+action is needed.`},
+	{HotLayerUnordered, "Hot kernels not extracted first", `The builder used does not order the cache layer, so the hot kernels are
+marked in the manifest but not extracted first. Use the native builder.`},
+	{CacheReadFailed, "Cache directory not fully read", `Part of a cache directory could not be read while looking for caches
+or host code in it, so the entries there are left out. Check its
+permissions.`},
+	{TemplateIgnored, "Dockerfile template ignored", `MCV_DOCKERFILE_TEMPLATE is set, but images are assembled without a
+Dockerfile, so the template is not used. Use --base-image, --label,
+--copy or --annotate-plugin instead.`},
+
+	{RegistryBreakerOpen, "Registry paused", `Requests to a registry kept failing, so mcv pauses them for
+MCV_REGISTRY_BREAKER_COOLDOWN before trying again.`},
+	{ReferrersNotCopied, "Referrers not copied", `The destination of mcv copy cannot hold OCI referrers or cosign
+signatures, attestations and SBOMs, so they were not copied.`},
+	{RolloutAborted, "Rollout aborted", `Too many hosts failed to update or pass the health check, so the fleet
+rollout stopped, and rolls back if a previous image was given.`},
+	{TrustNotWatched, "Trust material not watched", `The ConfigMap or Secret holding the signature policy or keys cannot be
+watched, so changes to it are not picked up until mcv restarts.`},
+
+	{CaptureEventsDropped, "Capture events dropped", `The kernel could not deliver every file event, so the capture may miss
+entries. Raise fs.inotify.max_queued_events.`},
+	{AccessTimesNotReset, "Access times not reset", `mcv usage could not reset file access times, so with relatime reads less
+than a day apart may be counted once.`},
+	{CapturedCommandFail, "Captured command failed", `The command run by mcv capture exited non-zero, so the capture may not
+cover the whole workload.`},
+	{CaptureWatchFailed, "Directory not watched", `mcv capture could not watch a directory created in the cache, so the
+entries written to it are missed. Check its permissions, or raise
+fs.inotify.max_user_watches if the limit was reached.`},
+}
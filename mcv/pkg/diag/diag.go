@@ -0,0 +1,104 @@
+// Package diag reports warnings under stable codes, such as MCV1102, that
+// mcv explain describes and that can be suppressed one by one once known
+// to be acceptable.
+package diag
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+// Code identifies a kind of warning.
+type Code string
+
+// Diagnostic describes the condition a Code reports.
+type Diagnostic struct {
+	Code        Code   `json:"code"`
+	Title       string `json:"title"`
+	Explanation string `json:"explanation"`
+}
+
+var (
+	mu         sync.RWMutex
+	suppressed = map[Code]bool{}
+)
+
+// All returns every diagnostic mcv reports, by code.
+func All() []Diagnostic {
+	return diagnostics
+}
+
+// Lookup returns the diagnostic of code, accepting it in any case.
+func Lookup(code string) (Diagnostic, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, d := range diagnostics {
+		if string(d.Code) == code {
+			return d, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+// Suppress logs the warnings of codes, each possibly a comma separated
+// list, at debug level from now on, in place of any codes suppressed
+// before. Unknown codes are warned about.
+func Suppress(codes []string) {
+	set := map[Code]bool{}
+	var unknown []string
+	for _, list := range codes {
+		for _, c := range strings.Split(list, ",") {
+			if c = strings.TrimSpace(c); c == "" {
+				continue
+			}
+			d, ok := Lookup(c)
+			if !ok {
+				unknown = append(unknown, c)
+				continue
+			}
+			set[d.Code] = true
+		}
+	}
+	mu.Lock()
+	suppressed = set
+	mu.Unlock()
+	if len(unknown) > 0 {
+		Warnf(UnknownCode, "Not suppressing unknown diagnostic code(s) %s", strings.Join(unknown, ", "))
+	}
+}
+
+// Suppressed reports whether the warnings of code are suppressed.
+func Suppressed(code Code) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return suppressed[code]
+}
+
+// Warn logs a warning under code.
+func Warn(code Code, args ...any) {
+	log(code, fmt.Sprint(args...))
+}
+
+// Warnf logs a formatted warning under code.
+func Warnf(code Code, format string, args ...any) {
+	log(code, fmt.Sprintf(format, args...))
+}
+
+func log(code Code, msg string) {
+	entry := logging.WithField("code", string(code))
+	// The caller logrus reports is this file, so name the one that warned.
+	if logging.StandardLogger().ReportCaller {
+		if _, file, line, ok := runtime.Caller(2); ok {
+			entry = entry.WithField("at", fmt.Sprintf("%s:%d", filepath.Base(file), line))
+		}
+	}
+	if Suppressed(code) {
+		entry.Debug(msg)
+		return
+	}
+	entry.Warn(msg)
+}
@@ -0,0 +1,55 @@
+package diag
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodesAreUniqueAndDescribed(t *testing.T) {
+	format := regexp.MustCompile(`^MCV1\d{3}$`)
+	seen := map[Code]bool{}
+	for _, d := range All() {
+		assert.Regexp(t, format, string(d.Code))
+		assert.False(t, seen[d.Code], "%s listed twice", d.Code)
+		seen[d.Code] = true
+		assert.NotEmpty(t, d.Title, d.Code)
+		assert.NotEmpty(t, d.Explanation, d.Code)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	d, ok := Lookup(" mcv1102 ")
+	assert.True(t, ok)
+	assert.Equal(t, NoCompatibleGPU, d.Code)
+
+	_, ok = Lookup("MCV9999")
+	assert.False(t, ok)
+}
+
+func TestSuppress(t *testing.T) {
+	var buf bytes.Buffer
+	defer logging.SetOutput(logging.StandardLogger().Out)
+	logging.SetOutput(&buf)
+	defer Suppress(nil)
+
+	Suppress([]string{"mcv1203", "MCV9999, MCV1302", ""})
+	assert.True(t, Suppressed(ImageExpired))
+	assert.True(t, Suppressed(SeveralHostArchs))
+	assert.False(t, Suppressed(NoAccelerator))
+	assert.Contains(t, buf.String(), "MCV9999")
+	assert.Contains(t, buf.String(), "code="+string(UnknownCode))
+
+	buf.Reset()
+	Warnf(ImageExpired, "expired %d", 1)
+	assert.Empty(t, buf.String())
+	Warn(NoAccelerator, "no accelerator")
+	assert.Contains(t, buf.String(), "no accelerator")
+	assert.Contains(t, buf.String(), "code="+string(NoAccelerator))
+
+	Suppress(nil)
+	assert.False(t, Suppressed(ImageExpired))
+}
@@ -0,0 +1,410 @@
+// Package doctor checks that the local environment can build and extract
+// cache images: user namespaces, image storage, registry access, GPU
+// drivers and libraries, and the permissions and free space of the
+// directories MCV writes to. Each failed probe comes with a remedy.
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	"golang.org/x/sys/unix"
+)
+
+// Status is the outcome of a probe.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Free space below which the disk probe warns or fails.
+const (
+	warnFreeBytes = 10 << 30
+	failFreeBytes = 1 << 30
+)
+
+// Result is the outcome of one probe, with what to do about it.
+type Result struct {
+	Probe  string `json:"probe"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Remedy string `json:"remedy,omitempty"`
+}
+
+// Options selects what Run checks.
+type Options struct {
+	Builder  string   // Image builder backend; only buildah needs user namespaces and image storage
+	Registry string   // Registry host to reach, e.g. quay.io
+	Image    string   // If set, an image that must be readable with the local credentials
+	Dirs     []string // Directories MCV writes to
+}
+
+// env is the host the probes look at, so tests can fake it.
+type env struct {
+	root     string // Prefix of /proc, /sys and /etc
+	euid     int
+	username string
+	hasApp   func(string) bool
+	store    func() (driver, graphRoot string, err error)
+}
+
+func hostEnv() env {
+	e := env{root: hostfs.Root(), euid: os.Geteuid(), hasApp: utils.HasApp, store: storeOptions}
+	if u, err := user.Current(); err == nil {
+		e.username = u.Username
+	}
+	return e
+}
+
+// Run runs every probe and returns their results in order.
+func Run(ctx context.Context, opts Options) []Result {
+	e := hostEnv()
+	var results []Result
+	if canBuild {
+		results = append(results, e.userNamespaces(opts.Builder), e.storageDriver(opts.Builder))
+	}
+	results = append(results, registryAccess(ctx, opts.Registry, opts.Image))
+	results = append(results, e.gpuDriver()...)
+	results = append(results, dirPermissions(opts.Dirs)...)
+	return append(results, diskSpace(opts.Dirs)...)
+}
+
+// Failed reports whether any result failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// advisory turns a failure into a warning when the builder in use does not
+// need what the probe checks.
+func advisory(r Result, needed bool, why string) Result {
+	if !needed && r.Status == StatusFail {
+		r.Status = StatusWarn
+		r.Detail += " (" + why + ")"
+	}
+	return r
+}
+
+func (e env) read(path string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(e.root, path))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
+
+func (e env) exists(path string) bool {
+	_, err := os.Stat(filepath.Join(e.root, path))
+	return err == nil
+}
+
+// userNamespaces checks rootless buildah builds can create a user namespace
+// with the user's subordinate IDs.
+func (e env) userNamespaces(builder string) Result {
+	r := Result{Probe: "user namespaces", Status: StatusOK}
+	if e.euid == 0 {
+		r.Detail = "running as root, none needed"
+		return r
+	}
+	needed, why := builder == "buildah", "only the buildah builder needs them"
+	if v, ok := e.read("proc/sys/user/max_user_namespaces"); ok && v == "0" {
+		return advisory(Result{Probe: r.Probe, Status: StatusFail,
+			Detail: "user namespaces are disabled (user.max_user_namespaces=0)",
+			Remedy: "sysctl -w user.max_user_namespaces=15000, or use the native builder"}, needed, why)
+	}
+	if v, ok := e.read("proc/sys/kernel/unprivileged_userns_clone"); ok && v == "0" {
+		return advisory(Result{Probe: r.Probe, Status: StatusFail,
+			Detail: "unprivileged user namespaces are disabled (kernel.unprivileged_userns_clone=0)",
+			Remedy: "sysctl -w kernel.unprivileged_userns_clone=1, or use the native builder"}, needed, why)
+	}
+	var missing []string
+	for _, f := range []string{"etc/subuid", "etc/subgid"} {
+		if !e.hasSubIDs(f) {
+			missing = append(missing, "/"+f)
+		}
+	}
+	if len(missing) > 0 {
+		return advisory(Result{Probe: r.Probe, Status: StatusFail,
+			Detail: fmt.Sprintf("no range for %s in %s", e.username, strings.Join(missing, " and ")),
+			Remedy: fmt.Sprintf("usermod --add-subuids 100000-165535 --add-subgids 100000-165535 %s", e.username)}, needed, why)
+	}
+	r.Detail = fmt.Sprintf("enabled, with subordinate IDs for %s", e.username)
+	return r
+}
+
+// hasSubIDs reports whether the subordinate ID file has a range for the
+// user, by name or ID.
+func (e env) hasSubIDs(path string) bool {
+	f, err := os.Open(filepath.Join(e.root, path))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	uid := strconv.Itoa(e.euid)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		owner, _, _ := strings.Cut(strings.TrimSpace(s.Text()), ":")
+		if owner != "" && (owner == e.username || owner == uid) {
+			return true
+		}
+	}
+	return false
+}
+
+// storageDriver checks the containers/storage driver buildah builds use.
+func (e env) storageDriver(builder string) Result {
+	needed, why := builder == "buildah", "only the buildah builder uses it"
+	r := Result{Probe: "storage driver"}
+	driver, graphRoot, err := e.store()
+	if err != nil {
+		r.Status, r.Detail = StatusFail, fmt.Sprintf("cannot read the storage configuration: %v", err)
+		r.Remedy = "fix /etc/containers/storage.conf or ~/.config/containers/storage.conf"
+		return advisory(r, needed, why)
+	}
+	if driver == "" {
+		driver = "overlay"
+	}
+	r.Detail = fmt.Sprintf("%s in %s", driver, graphRoot)
+	switch driver {
+	case "overlay":
+		if !e.hasFilesystem("overlay") && !e.hasApp("fuse-overlayfs") {
+			r.Status = StatusFail
+			r.Detail += ", but the kernel has no overlay filesystem"
+			r.Remedy = "modprobe overlay, or install fuse-overlayfs"
+			return advisory(r, needed, why)
+		}
+	case "vfs":
+		r.Status = StatusWarn
+		r.Detail += ", which copies every layer in full"
+		r.Remedy = `set driver = "overlay" in storage.conf`
+		return r
+	}
+	r.Status = StatusOK
+	return r
+}
+
+func (e env) hasFilesystem(fs string) bool {
+	data, _ := e.read("proc/filesystems")
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[len(fields)-1] == fs {
+			return true
+		}
+	}
+	return false
+}
+
+// registryAccess checks the registry answers, and that the image, if any,
+// can be read with the local credentials.
+func registryAccess(ctx context.Context, host, image string) Result {
+	r := Result{Probe: "registry"}
+	if image != "" {
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			r.Status, r.Detail = StatusFail, fmt.Sprintf("invalid image %s: %v", image, err)
+			return r
+		}
+		host = ref.Context().RegistryStr()
+	}
+	if host == "" {
+		r.Status, r.Detail = StatusSkip, "no registry given"
+		return r
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/v2/", nil)
+	if err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		return r
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		r.Status, r.Detail = StatusFail, fmt.Sprintf("%s is unreachable: %v", host, err)
+		r.Remedy = "check DNS, HTTPS_PROXY and firewall rules for the registry"
+		return r
+	}
+	resp.Body.Close()
+	// Registries answer 401 to anonymous requests of the API root.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		r.Status, r.Detail = StatusFail, fmt.Sprintf("%s answered %s", host, resp.Status)
+		r.Remedy = "check the registry host name"
+		return r
+	}
+	r.Status, r.Detail = StatusOK, fmt.Sprintf("%s is reachable", host)
+
+	if image != "" {
+		ref, _ := name.ParseReference(image)
+		if _, err := remote.Head(ref, registry.Options(remote.WithContext(ctx))...); err != nil {
+			r.Status, r.Detail = StatusFail, fmt.Sprintf("cannot read %s: %v", image, err)
+			r.Remedy = "log in with podman login or docker login, and check the image name"
+			return r
+		}
+		r.Detail = fmt.Sprintf("%s is readable", image)
+	}
+	return r
+}
+
+// libDirs are where GPU user space libraries are installed.
+var libDirs = []string{
+	"usr/lib64", "usr/lib/x86_64-linux-gnu", "usr/lib/aarch64-linux-gnu", "usr/lib",
+	"usr/local/cuda/lib64", "opt/rocm/lib",
+}
+
+// gpuDriver checks the NVIDIA and AMD kernel drivers and the libraries and
+// tools MCV uses with them.
+func (e env) gpuDriver() []Result {
+	type vendor struct {
+		name, driver, lib, install string
+		tools                      []string
+	}
+	vendors := []vendor{
+		{name: "NVIDIA", driver: "proc/driver/nvidia/version", lib: "libcuda.so*",
+			tools: []string{"nvidia-smi"}, install: "install the NVIDIA driver's user space libraries (libcuda)"},
+		{name: "AMD", driver: "sys/module/amdgpu", lib: "libamdhip64.so*",
+			tools: []string{"amd-smi", "rocm-smi"}, install: "install ROCm (libamdhip64)"},
+	}
+	var results []Result
+	for _, v := range vendors {
+		if !e.exists(v.driver) {
+			continue
+		}
+		r := Result{Probe: v.name + " GPU", Status: StatusOK}
+		if !e.hasLib(v.lib) {
+			r.Status, r.Detail = StatusFail, fmt.Sprintf("driver loaded, but %s was not found", strings.TrimSuffix(v.lib, "*"))
+			r.Remedy = v.install
+			results = append(results, r)
+			continue
+		}
+		r.Detail = "driver and libraries found"
+		if version, ok := e.read(v.driver); ok && version != "" {
+			// The NVIDIA driver names its version, the amdgpu module dir
+			// holds no text.
+			r.Detail = strings.Join(strings.Fields(strings.SplitN(version, "\n", 2)[0]), " ")
+		}
+		tool := ""
+		for _, t := range v.tools {
+			if e.hasApp(t) {
+				tool = t
+				break
+			}
+		}
+		if tool == "" {
+			r.Status = StatusWarn
+			r.Detail += fmt.Sprintf(", but %s was not found", strings.Join(v.tools, " or "))
+			r.Remedy = fmt.Sprintf("install %s so MCV can read GPU details", v.tools[0])
+		}
+		results = append(results, r)
+	}
+	if len(results) == 0 {
+		results = append(results, Result{Probe: "GPU", Status: StatusWarn,
+			Detail: "no NVIDIA or AMD GPU driver is loaded",
+			Remedy: "load the GPU driver, or pass --no-gpu to skip GPU checks"})
+	}
+	return results
+}
+
+func (e env) hasLib(pattern string) bool {
+	for _, dir := range libDirs {
+		if matches, _ := filepath.Glob(filepath.Join(e.root, dir, pattern)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// existingAncestor returns dir, or its nearest parent that exists.
+func existingAncestor(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// dirPermissions checks MCV can write to each directory, or create it.
+func dirPermissions(dirs []string) []Result {
+	var results []Result
+	for _, dir := range dirs {
+		r := Result{Probe: "permissions " + dir, Status: StatusOK}
+		target := existingAncestor(dir)
+		if err := unix.Access(target, unix.W_OK|unix.X_OK); err != nil {
+			r.Status, r.Detail = StatusFail, fmt.Sprintf("%s is not writable: %v", target, err)
+			r.Remedy = fmt.Sprintf("chown %s %s, or use another directory", currentUser(), target)
+		} else if target == dir {
+			r.Detail = "writable"
+		} else {
+			r.Detail = fmt.Sprintf("will be created in %s", target)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(os.Geteuid())
+}
+
+// diskSpace checks the free space of each filesystem holding the
+// directories.
+func diskSpace(dirs []string) []Result {
+	var results []Result
+	seen := map[unix.Fsid]bool{}
+	for _, dir := range dirs {
+		target := existingAncestor(dir)
+		var st unix.Statfs_t
+		if err := unix.Statfs(target, &st); err != nil {
+			results = append(results, Result{Probe: "disk space " + dir, Status: StatusWarn,
+				Detail: fmt.Sprintf("cannot stat %s: %v", target, err)})
+			continue
+		}
+		if seen[st.Fsid] {
+			continue
+		}
+		seen[st.Fsid] = true
+		results = append(results, freeSpace(dir, st.Bavail*uint64(st.Bsize)))
+	}
+	return results
+}
+
+func freeSpace(dir string, free uint64) Result {
+	r := Result{Probe: "disk space " + dir, Status: StatusOK, Detail: fmt.Sprintf("%.1f GiB free", float64(free)/(1<<30))}
+	switch {
+	case free < failFreeBytes:
+		r.Status = StatusFail
+		r.Remedy = "free space, or point the directory at a larger filesystem"
+	case free < warnFreeBytes:
+		r.Status = StatusWarn
+		r.Remedy = "large caches may not fit; free space or use a larger filesystem"
+	}
+	return r
+}
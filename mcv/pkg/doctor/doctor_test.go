@@ -0,0 +1,113 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+func testEnv(t *testing.T, files map[string]string, apps ...string) env {
+	root := t.TempDir()
+	writeFiles(t, root, files)
+	return env{
+		root:     root,
+		euid:     1000,
+		username: "alice",
+		hasApp: func(app string) bool {
+			for _, a := range apps {
+				if a == app {
+					return true
+				}
+			}
+			return false
+		},
+		store: func() (string, string, error) { return "overlay", "/home/alice/.local/share/containers/storage", nil },
+	}
+}
+
+func TestUserNamespaces(t *testing.T) {
+	e := testEnv(t, map[string]string{
+		"proc/sys/user/max_user_namespaces": "63000\n",
+		"etc/subuid":                        "alice:100000:65536\n",
+		"etc/subgid":                        "1000:100000:65536\n",
+	})
+	assert.Equal(t, StatusOK, e.userNamespaces("buildah").Status)
+
+	e = testEnv(t, map[string]string{"etc/subuid": "bob:100000:65536\n"})
+	r := e.userNamespaces("buildah")
+	assert.Equal(t, StatusFail, r.Status)
+	assert.Contains(t, r.Detail, "/etc/subuid and /etc/subgid")
+	assert.Contains(t, r.Remedy, "usermod")
+	// The native builder does not need them.
+	assert.Equal(t, StatusWarn, e.userNamespaces("native").Status)
+
+	e = testEnv(t, map[string]string{"proc/sys/user/max_user_namespaces": "0\n"})
+	assert.Contains(t, e.userNamespaces("buildah").Detail, "max_user_namespaces=0")
+
+	e.euid = 0
+	assert.Equal(t, StatusOK, e.userNamespaces("buildah").Status)
+}
+
+func TestStorageDriver(t *testing.T) {
+	e := testEnv(t, map[string]string{"proc/filesystems": "nodev\tproc\nnodev\toverlay\n"})
+	assert.Equal(t, StatusOK, e.storageDriver("buildah").Status)
+
+	e = testEnv(t, map[string]string{"proc/filesystems": "nodev\tproc\n"})
+	assert.Equal(t, StatusFail, e.storageDriver("buildah").Status)
+	e.hasApp = func(app string) bool { return app == "fuse-overlayfs" }
+	assert.Equal(t, StatusOK, e.storageDriver("buildah").Status)
+
+	e.store = func() (string, string, error) { return "vfs", "/var/lib/containers/storage", nil }
+	assert.Equal(t, StatusWarn, e.storageDriver("buildah").Status)
+}
+
+func TestGPUDriver(t *testing.T) {
+	e := testEnv(t, nil)
+	results := e.gpuDriver()
+	assert.Len(t, results, 1)
+	assert.Equal(t, StatusWarn, results[0].Status)
+
+	e = testEnv(t, map[string]string{
+		"proc/driver/nvidia/version": "NVRM version: NVIDIA UNIX x86_64 Kernel Module  550.54.15\nGCC version: 12\n",
+	}, "nvidia-smi")
+	results = e.gpuDriver()
+	assert.Equal(t, StatusFail, results[0].Status)
+	assert.Contains(t, results[0].Detail, "libcuda.so")
+
+	writeFiles(t, e.root, map[string]string{"usr/lib64/libcuda.so.1": ""})
+	results = e.gpuDriver()
+	assert.Equal(t, StatusOK, results[0].Status)
+	assert.Equal(t, "NVRM version: NVIDIA UNIX x86_64 Kernel Module 550.54.15", results[0].Detail)
+
+	e = testEnv(t, map[string]string{"sys/module/amdgpu/version": "", "opt/rocm/lib/libamdhip64.so.6": ""})
+	results = e.gpuDriver()
+	assert.Equal(t, "AMD GPU", results[0].Probe)
+	assert.Equal(t, StatusWarn, results[0].Status)
+	assert.Contains(t, results[0].Remedy, "amd-smi")
+}
+
+func TestDirs(t *testing.T) {
+	dir := t.TempDir()
+	results := dirPermissions([]string{dir, filepath.Join(dir, "a", "b")})
+	assert.Equal(t, StatusOK, results[0].Status)
+	assert.Equal(t, "writable", results[0].Detail)
+	assert.Equal(t, "will be created in "+dir, results[1].Detail)
+
+	// Both are on the same filesystem.
+	assert.Len(t, diskSpace([]string{dir, filepath.Join(dir, "a")}), 1)
+
+	assert.Equal(t, StatusFail, freeSpace(dir, 512<<20).Status)
+	assert.Equal(t, StatusWarn, freeSpace(dir, 5<<30).Status)
+	assert.Equal(t, StatusOK, freeSpace(dir, 50<<30).Status)
+}
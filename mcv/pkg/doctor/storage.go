@@ -0,0 +1,17 @@
+//go:build !mcv_edge
+
+package doctor
+
+import "github.com/containers/storage"
+
+// canBuild reports whether this mcv can build images, so the probes of
+// what building needs apply.
+const canBuild = true
+
+func storeOptions() (string, string, error) {
+	opts, err := storage.DefaultStoreOptions()
+	if err != nil {
+		return "", "", err
+	}
+	return opts.GraphDriverName, opts.GraphRoot, nil
+}
@@ -0,0 +1,12 @@
+//go:build mcv_edge
+
+package doctor
+
+import "errors"
+
+// Edge builds cannot build images and leave out containers/storage.
+const canBuild = false
+
+func storeOptions() (string, string, error) {
+	return "", "", errors.New("edge builds do not use containers storage")
+}
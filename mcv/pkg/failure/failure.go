@@ -0,0 +1,88 @@
+// Package failure classifies the errors mcv fails with, so that its exit
+// code tells scripts and CI pipelines why it failed, whatever the command.
+package failure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Class is a kind of failure.
+type Class struct {
+	Name        string `json:"name"`
+	ExitCode    int    `json:"exitCode"`
+	Description string `json:"description"`
+}
+
+var (
+	Incompatible = &Class{"incompatible", 40, "The host's GPUs, or its CPU architecture, cannot use the cache"}
+	Auth         = &Class{"auth", 41, "The registry refused the credentials, or gave none access to the image"}
+	CorruptCache = &Class{"corrupt-cache", 42, "A layer or cache file does not match its digest or cannot be decoded"}
+	NoCache      = &Class{"no-cache", 43, "No Triton or vLLM cache was found in the directory or image"}
+	NotFound     = &Class{"not-found", 44, "The image or repository does not exist"}
+	Timeout      = &Class{"timeout", 45, "A network operation did not finish within its timeout"}
+)
+
+// Classes returns every class, by exit code.
+func Classes() []*Class {
+	return []*Class{Incompatible, Auth, CorruptCache, NoCache, NotFound, Timeout}
+}
+
+// Error is an error of a known class.
+type Error struct {
+	Class *Class
+	Err   error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New returns err marked as a failure of class, or nil if err is nil.
+func New(class *Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// Classify returns the class err was marked with, or the one its registry
+// response or expired deadline shows, and nil for other errors.
+func Classify(err error) *Class {
+	if err == nil {
+		return nil
+	}
+	var fe *Error
+	if errors.As(err, &fe) {
+		return fe.Class
+	}
+	var te *transport.Error
+	if errors.As(err, &te) {
+		switch {
+		case te.StatusCode == http.StatusUnauthorized || te.StatusCode == http.StatusForbidden ||
+			hasCode(te, transport.UnauthorizedErrorCode, transport.DeniedErrorCode):
+			return Auth
+		case te.StatusCode == http.StatusNotFound ||
+			hasCode(te, transport.ManifestUnknownErrorCode, transport.NameUnknownErrorCode):
+			return NotFound
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+	return nil
+}
+
+func hasCode(te *transport.Error, codes ...transport.ErrorCode) bool {
+	for _, d := range te.Errors {
+		for _, c := range codes {
+			if d.Code == c {
+				return true
+			}
+		}
+	}
+	return false
+}
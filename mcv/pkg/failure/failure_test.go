@@ -0,0 +1,44 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	denied := &transport.Error{StatusCode: http.StatusUnauthorized}
+	unknown := &transport.Error{StatusCode: http.StatusBadRequest, Errors: []transport.Diagnostic{{Code: transport.ManifestUnknownErrorCode}}}
+
+	tests := []struct {
+		err  error
+		want *Class
+	}{
+		{nil, nil},
+		{errors.New("disk full"), nil},
+		{fmt.Errorf("preflight check failed: %w", New(Incompatible, errors.New("no compatible GPU found"))), Incompatible},
+		{fmt.Errorf("failed to fetch image: %w", denied), Auth},
+		{unknown, NotFound},
+		{&transport.Error{StatusCode: http.StatusServiceUnavailable}, nil},
+		{fmt.Errorf("pull: %w", context.DeadlineExceeded), Timeout},
+		// A marked class wins over what the cause shows.
+		{New(CorruptCache, fmt.Errorf("reading: %w", context.DeadlineExceeded)), CorruptCache},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Classify(tt.err), "%v", tt.err)
+	}
+	assert.NoError(t, New(NoCache, nil))
+}
+
+func TestClassesHaveDistinctExitCodes(t *testing.T) {
+	seen := map[int]bool{}
+	for _, c := range Classes() {
+		assert.False(t, seen[c.ExitCode], c.Name)
+		seen[c.ExitCode] = true
+	}
+}
@@ -0,0 +1,23 @@
+//go:build mcv_faults
+
+package faults
+
+import (
+	"os"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+// init only records the faults: logging is configured later, so the
+// warning that faults are active is logged on first use.
+func init() {
+	spec := os.Getenv(EnvFaults)
+	if spec == "" {
+		return
+	}
+	f, err := Parse(spec)
+	if err != nil {
+		logging.Fatalf("Invalid %s: %v", EnvFaults, err)
+	}
+	active = f
+}
@@ -0,0 +1,167 @@
+// Package faults injects failures into image extraction so operators can
+// check that interrupted extracts leave no partial files behind and resume
+// correctly before relying on that in production.
+//
+// Injection is compiled in only with the mcv_faults build tag (see
+// "make build-faults") and is then configured with the MCV_FAULTS
+// environment variable, a comma separated list of:
+//
+//	truncate-layer=SIZE   end each layer stream early after SIZE bytes
+//	corrupt-digest=SIZE   flip the byte at offset SIZE of each layer stream
+//	slow-network=SIZE     read layers at SIZE bytes per second
+//	disk-full=SIZE        fail extracted file writes with ENOSPC after SIZE bytes in total
+//
+// e.g. MCV_FAULTS=slow-network=1MiB,disk-full=64MiB.
+package faults
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+)
+
+// EnvFaults selects the faults to inject.
+const EnvFaults = "MCV_FAULTS"
+
+// Faults are the failures to inject. Zero values disable a fault.
+type Faults struct {
+	TruncateLayer int64 // Bytes of each layer to deliver before failing
+	CorruptOffset int64 // Offset of the layer byte to flip, -1 disables
+	ReadRate      int64 // Layer read rate in bytes per second
+	DiskFull      int64 // Bytes that may be written before writes fail
+
+	mu      sync.Mutex
+	written int64
+}
+
+// active is set from the environment in binaries built with mcv_faults.
+var (
+	active *Faults
+	warn   sync.Once
+)
+
+// Parse parses a fault list as accepted in MCV_FAULTS.
+func Parse(spec string) (*Faults, error) {
+	f := &Faults{CorruptOffset: -1}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault %q needs a size, e.g. %s=1MiB", name, name)
+		}
+		n, err := units.RAMInBytes(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid size %q for fault %s", value, name)
+		}
+		switch name {
+		case "truncate-layer":
+			f.TruncateLayer = n
+		case "corrupt-digest":
+			f.CorruptOffset = n
+		case "slow-network":
+			if n == 0 {
+				return nil, fmt.Errorf("slow-network rate must be positive")
+			}
+			f.ReadRate = n
+		case "disk-full":
+			f.DiskFull = n
+		default:
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+	}
+	return f, nil
+}
+
+// Enabled reports whether any fault is being injected.
+func Enabled() bool {
+	return active != nil
+}
+
+// WrapLayer returns rc with the configured layer faults applied to what is
+// read from it.
+func WrapLayer(rc io.ReadCloser) io.ReadCloser {
+	if active == nil {
+		return rc
+	}
+	warnActive()
+	return &layerReader{ReadCloser: rc, f: active}
+}
+
+// WrapFile returns w with the configured disk faults applied to writes.
+func WrapFile(w io.Writer) io.Writer {
+	if active == nil || active.DiskFull == 0 {
+		return w
+	}
+	warnActive()
+	return &diskWriter{w: w, f: active}
+}
+
+func warnActive() {
+	warn.Do(func() {
+		diag.Warnf(diag.FaultsInjected, "Injecting extraction faults from %s", EnvFaults)
+	})
+}
+
+type layerReader struct {
+	io.ReadCloser
+	f   *Faults
+	off int64
+}
+
+func (l *layerReader) Read(p []byte) (int, error) {
+	if t := l.f.TruncateLayer; t > 0 {
+		if l.off >= t {
+			return 0, fmt.Errorf("injected fault: layer truncated after %d bytes: %w", t, io.ErrUnexpectedEOF)
+		}
+		if rest := t - l.off; int64(len(p)) > rest {
+			p = p[:rest]
+		}
+	}
+	if r := l.f.ReadRate; r > 0 && int64(len(p)) > r {
+		p = p[:r]
+	}
+	start := time.Now()
+	n, err := l.ReadCloser.Read(p)
+	if c := l.f.CorruptOffset; c >= l.off && c < l.off+int64(n) {
+		p[c-l.off] ^= 0xff
+	}
+	l.off += int64(n)
+	if r := l.f.ReadRate; r > 0 && n > 0 {
+		time.Sleep(time.Duration(n)*time.Second/time.Duration(r) - time.Since(start))
+	}
+	return n, err
+}
+
+type diskWriter struct {
+	w io.Writer
+	f *Faults
+}
+
+func (d *diskWriter) Write(p []byte) (int, error) {
+	d.f.mu.Lock()
+	room := d.f.DiskFull - d.f.written
+	if room < 0 {
+		room = 0
+	}
+	n := int64(len(p))
+	if n > room {
+		n = room
+	}
+	d.f.written += n
+	d.f.mu.Unlock()
+
+	written, err := d.w.Write(p[:n])
+	if err == nil && n < int64(len(p)) {
+		err = fmt.Errorf("injected fault: %w", syscall.ENOSPC)
+	}
+	return written, err
+}
@@ -0,0 +1,68 @@
+package faults
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func inject(t *testing.T, spec string) {
+	f, err := Parse(spec)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	active = f
+	t.Cleanup(func() { active = nil })
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse("truncate-layer=1KiB, corrupt-digest=0,slow-network=1MiB,disk-full=2MiB")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), f.TruncateLayer)
+	assert.Equal(t, int64(0), f.CorruptOffset)
+	assert.Equal(t, int64(1<<20), f.ReadRate)
+	assert.Equal(t, int64(2<<20), f.DiskFull)
+
+	for _, spec := range []string{"disk-full", "disk-full=lots", "slow-network=0", "flood=1"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	rc := io.NopCloser(bytes.NewReader(nil))
+	assert.Equal(t, rc, WrapLayer(rc))
+	var buf bytes.Buffer
+	assert.Equal(t, io.Writer(&buf), WrapFile(&buf))
+}
+
+func TestWrapLayer(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 100)
+
+	inject(t, "truncate-layer=10")
+	got, err := io.ReadAll(WrapLayer(io.NopCloser(bytes.NewReader(data))))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Len(t, got, 10)
+
+	inject(t, "corrupt-digest=50")
+	got, err = io.ReadAll(WrapLayer(io.NopCloser(bytes.NewReader(data))))
+	assert.NoError(t, err)
+	assert.Equal(t, byte('a'^0xff), got[50])
+	assert.Equal(t, len(data)-1, bytes.Count(got, []byte("a")))
+}
+
+func TestWrapFile_DiskFull(t *testing.T) {
+	inject(t, "disk-full=10")
+	var first, second bytes.Buffer
+	_, err := WrapFile(&first).Write(make([]byte, 6))
+	assert.NoError(t, err)
+
+	// The budget is shared by all files of the extract.
+	n, err := WrapFile(&second).Write(make([]byte, 6))
+	assert.True(t, errors.Is(err, syscall.ENOSPC))
+	assert.Equal(t, 4, n)
+}
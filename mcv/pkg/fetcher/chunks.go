@@ -0,0 +1,62 @@
+package fetcher
+
+import (
+	"fmt"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/chunk"
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	logging "github.com/sirupsen/logrus"
+)
+
+// reassembleChunks rebuilds the chunked files of an extracted cache from
+// the chunk layers of img.
+func reassembleChunks(img v1.Image, cacheDir string) error {
+	idx, err := chunk.LoadIndex(cacheDir)
+	if err != nil || idx == nil {
+		return err
+	}
+
+	need := map[string]bool{}
+	for _, d := range idx.Digests() {
+		need[d] = true
+	}
+
+	storeDir, err := os.MkdirTemp("", "mcv-chunks-")
+	if err != nil {
+		return fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	defer utils.RemoveTemp(storeDir)
+
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("failed to get image layers: %w", err)
+	}
+	for _, layer := range layers {
+		if len(need) == 0 {
+			break
+		}
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+		err = chunk.CollectChunks(rc, storeDir, need)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if len(need) > 0 {
+		return fmt.Errorf("image is missing %d chunk(s) of the chunked cache files", len(need))
+	}
+
+	if err := chunk.Reassemble(cacheDir, storeDir, idx); err != nil {
+		return err
+	}
+	logging.Infof("Reassembled %d chunked file(s)", len(idx.Files))
+
+	// Reassembled files were not present when paths were resolved.
+	return cache.ResolvePaths(cacheDir, cacheDir)
+}
@@ -0,0 +1,92 @@
+package fetcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Kernel policies: which of the kernels of an image extract restores.
+const (
+	KernelsAll        = "all"        // Every kernel, if one runs on the GPUs
+	KernelsCompatible = "compatible" // Only the kernels that run on the GPUs
+)
+
+// KernelPolicies returns the supported kernel policies.
+func KernelPolicies() []string {
+	return []string{KernelsAll, KernelsCompatible}
+}
+
+// ValidateKernelPolicy checks policy is supported.
+func ValidateKernelPolicy(policy string) error {
+	for _, p := range KernelPolicies() {
+		if policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported kernel policy %q (supported: %s)", policy, strings.Join(KernelPolicies(), ", "))
+}
+
+// pruneIncompatibleKernels removes from cacheDir the directories of the
+// Triton entries of the manifest at manifestPath built for none of the
+// GPUs, so that an image built for several architectures only leaves the
+// kernels of the local ones. A directory shared with a compatible entry
+// is kept. It fails, leaving the cache for the caller to clean up, if no
+// kernel is compatible, and returns the number of entries removed.
+func pruneIncompatibleKernels(cacheDir, manifestPath, ct string, devInfo []devices.TritonGPUInfo) (int, error) {
+	entries, err := preflightcheck.ManifestTritonEntries(manifestPath, ct)
+	if err != nil {
+		return 0, err
+	}
+	compatible, incompatible := preflightcheck.SplitTritonEntriesByGPU(entries, devInfo)
+	if len(compatible) == 0 {
+		return 0, fmt.Errorf("none of the %d kernels is compatible with the GPUs (built for %s)", len(entries), targetList(incompatible))
+	}
+
+	keep := map[string]bool{}
+	for _, e := range compatible {
+		keep[e.Dir] = true
+	}
+	removed := 0
+	for _, e := range incompatible {
+		if e.Dir == "" {
+			diag.Warnf(diag.IncompatibleKept, "Keeping incompatible kernel %s: the manifest does not locate it", e.Hash)
+			continue
+		}
+		if keep[e.Dir] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cacheDir, filepath.FromSlash(e.Dir))); err != nil {
+			return removed, fmt.Errorf("failed to remove incompatible kernel %s: %w", e.Dir, err)
+		}
+		keep[e.Dir] = true // Counted once
+		removed++
+	}
+	if removed > 0 {
+		logging.Infof("Restored the %d of %d kernels compatible with the GPUs, removed %d built for %s",
+			len(compatible), len(entries), removed, targetList(incompatible))
+	}
+	return removed, nil
+}
+
+// targetList returns the distinct backend:arch targets of entries, sorted.
+func targetList(entries []cache.TritonCacheMetadata) string {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[fmt.Sprintf("%s:%v", e.Backend, e.Arch)] = true
+	}
+	targets := make([]string, 0, len(seen))
+	for t := range seen {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	return strings.Join(targets, ", ")
+}
@@ -0,0 +1,45 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneIncompatibleKernels(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+
+	cacheDir := t.TempDir()
+	for _, d := range []string{"h100", "a100", "mi300"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, d), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, d, "k.json"), []byte("{}"), 0644))
+	}
+	// Archs are strings in manifests written by mcv, numbers in older ones.
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	assert.NoError(t, os.WriteFile(manifest, []byte(`{"triton": [
+		{"hash": "h", "dir": "h100", "backend": "cuda", "arch": "90", "warp_size": 32, "ptx_version": 8},
+		{"hash": "a", "dir": "a100", "backend": "cuda", "arch": 80, "warp_size": 32, "ptx_version": 8},
+		{"hash": "m", "dir": "mi300", "backend": "hip", "arch": "gfx942", "warp_size": 64}
+	]}`), 0644))
+	h100 := []devices.TritonGPUInfo{{Backend: "cuda", Arch: "90", WarpSize: 32, PTXVersion: 8}}
+
+	removed, err := pruneIncompatibleKernels(cacheDir, manifest, constants.Triton, h100)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.DirExists(t, filepath.Join(cacheDir, "h100"))
+	assert.NoDirExists(t, filepath.Join(cacheDir, "a100"))
+	assert.NoDirExists(t, filepath.Join(cacheDir, "mi300"))
+
+	l40 := []devices.TritonGPUInfo{{Backend: "cuda", Arch: "89", WarpSize: 32, PTXVersion: 8}}
+	_, err = pruneIncompatibleKernels(cacheDir, manifest, constants.Triton, l40)
+	assert.ErrorContains(t, err, "built for cuda:80, cuda:90, hip:gfx942")
+
+	assert.NoError(t, ValidateKernelPolicy(KernelsCompatible))
+	assert.Error(t, ValidateKernelPolicy("some"))
+}
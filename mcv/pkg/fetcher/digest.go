@@ -0,0 +1,57 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+)
+
+// ResolveDigest returns the manifest digest of imgName without pulling the
+// image config or layers. A digest reference is its own digest and an OCI
+// layout is read directly; otherwise the local image store is consulted
+// first, then the registry via a HEAD request.
+func ResolveDigest(imgName string) (string, error) {
+	if d := imgref.Digest(imgName); d != "" {
+		return d, nil
+	}
+	if imgref.IsLayout(imgName) {
+		img, err := (&layoutFetcher{}).FetchImg(imgName)
+		if err != nil {
+			return "", err
+		}
+		d, err := img.Digest()
+		if err != nil {
+			return "", fmt.Errorf("failed to get image digest: %w", err)
+		}
+		return d.String(), nil
+	}
+	if !strings.Contains(imgName, ":") {
+		imgName = fmt.Sprintf("%s:latest", imgName)
+	}
+
+	if img, err := imgstore.Load(imgName); err == nil {
+		if d, err := img.Digest(); err == nil {
+			return d.String(), nil
+		}
+	}
+
+	ref, err := name.ParseReference(imgName)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image name: %w", err)
+	}
+	ctx, cancel := registry.Context(context.Background(), registry.OpPull)
+	defer cancel()
+	desc, err := remote.Head(ref, registry.Options(remote.WithContext(ctx))...)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve image digest: %w", registry.TimeoutError(ctx, err))
+	}
+	logging.Debugf("Resolved %s to %s", imgName, desc.Digest)
+	return desc.Digest.String(), nil
+}
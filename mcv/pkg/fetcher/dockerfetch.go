@@ -1,3 +1,5 @@
+//go:build !mcv_edge
+
 package fetcher
 
 import (
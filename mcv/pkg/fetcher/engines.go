@@ -0,0 +1,29 @@
+//go:build !mcv_edge
+
+package fetcher
+
+import (
+	"github.com/redhat-et/MCU/mcv/pkg/utils"
+	logging "github.com/sirupsen/logrus"
+)
+
+// engineFetchers returns fetchers for the images held by the docker and
+// podman installed on the host.
+func engineFetchers() []Fetcher {
+	var fetchers []Fetcher
+	addFetcher := func(fetcher Fetcher, err error) {
+		if err == nil {
+			fetchers = append(fetchers, fetcher)
+		} else {
+			logging.Debugf("Failed to init fetcher: %v", err)
+		}
+	}
+
+	if utils.HasApp("docker") {
+		addFetcher(newDockerFetcher())
+	}
+	if utils.HasApp("podman") {
+		addFetcher(newPodmanFetcher())
+	}
+	return fetchers
+}
@@ -0,0 +1,10 @@
+//go:build mcv_edge
+
+package fetcher
+
+// engineFetchers returns no fetchers in edge builds, which leave out the
+// docker and podman clients: images come from the local image store, OCI
+// layouts and registries.
+func engineFetchers() []Fetcher {
+	return nil
+}
@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// What extract does with a cache image past its valid-until date.
+const (
+	ExpiredWarn   = "warn"   // Log a warning and extract
+	ExpiredBlock  = "block"  // Fail the extraction
+	ExpiredIgnore = "ignore" // Extract as usual
+)
+
+// ExpiredPolicies returns the supported expired cache policies.
+func ExpiredPolicies() []string {
+	return []string{ExpiredWarn, ExpiredBlock, ExpiredIgnore}
+}
+
+// ValidateExpiredPolicy checks policy is supported.
+func ValidateExpiredPolicy(policy string) error {
+	for _, p := range ExpiredPolicies() {
+		if policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported expired cache policy %q (supported: %s)", policy, strings.Join(ExpiredPolicies(), ", "))
+}
+
+// checkExpiry applies policy to an image whose labels say it expired
+// before now. Images without an expiry never expire.
+func checkExpiry(labels map[string]string, policy string, now time.Time) error {
+	if policy == ExpiredIgnore {
+		return nil
+	}
+	validUntil, ok, err := cache.ValidUntil(labels)
+	if err != nil || !ok {
+		return err
+	}
+	if now.Before(validUntil) {
+		logging.Debugf("Cache image valid until %s", validUntil.Format(time.RFC3339))
+		return nil
+	}
+	msg := fmt.Sprintf("cache image expired on %s", validUntil.Format(time.RFC3339))
+	if policy == ExpiredBlock {
+		return fmt.Errorf("%s; rebuild it, or extract with --expired warn", msg)
+	}
+	diag.Warnf(diag.ImageExpired, "The %s; its kernels may target a retired driver", msg)
+	return nil
+}
@@ -0,0 +1,25 @@
+package fetcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	expired := map[string]string{cache.ValidUntilLabel: "2025-05-31T00:00:00Z"}
+	valid := map[string]string{cache.ValidUntilLabel: "2025-07-01T00:00:00Z"}
+
+	assert.NoError(t, checkExpiry(expired, ExpiredWarn, now))
+	assert.ErrorContains(t, checkExpiry(expired, ExpiredBlock, now), "expired on 2025-05-31")
+	assert.NoError(t, checkExpiry(expired, ExpiredIgnore, now))
+	assert.NoError(t, checkExpiry(valid, ExpiredBlock, now))
+	assert.NoError(t, checkExpiry(map[string]string{}, ExpiredBlock, now))
+	assert.Error(t, checkExpiry(map[string]string{cache.ValidUntilLabel: "June"}, ExpiredBlock, now))
+
+	assert.NoError(t, ValidateExpiredPolicy(ExpiredBlock))
+	assert.Error(t, ValidateExpiredPolicy("delete"))
+}
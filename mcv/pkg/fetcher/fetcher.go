@@ -9,6 +9,7 @@ import (
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
 	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
 )
@@ -24,51 +25,97 @@ type fetcher struct {
 
 // Factory function to create a new Fetcher with the specified backend.
 func NewFetcher() Fetcher {
-	var localFetchers []Fetcher
+	localFetchers := append([]Fetcher{&storeFetcher{}}, engineFetchers()...)
+	return &fetcher{local: localFetchers, remote: &remoteFetcher{}}
+}
 
-	addFetcher := func(fetcher Fetcher, err error) {
-		if err == nil {
-			localFetchers = append(localFetchers, fetcher)
-		} else {
-			logging.Debugf("Failed to init fetcher: %v", err)
-		}
+// hasDigest reports whether img has the manifest digest want, or want is
+// empty.
+func hasDigest(img v1.Image, want string) bool {
+	if want == "" {
+		return true
 	}
+	d, err := img.Digest()
+	return err == nil && d.String() == want
+}
 
-	if utils.HasApp("docker") {
-		addFetcher(newDockerFetcher())
+func (f *fetcher) FetchImg(imgName string) (v1.Image, error) {
+	if imgref.IsLayout(imgName) {
+		return (&layoutFetcher{}).FetchImg(imgName)
 	}
-	if utils.HasApp("podman") {
-		addFetcher(newPodmanFetcher())
+
+	// Try to fetch locally first
+	if img := f.fetchLocal(imgName); img != nil {
+		return img, nil
 	}
 
-	return &fetcher{local: localFetchers, remote: &remoteFetcher{}}
+	// If local fetch fails, try fetching the image remotely
+	img, err := f.remote.FetchImg(imgName)
+	if err != nil || img == nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+
+	return img, nil
 }
 
-func (f *fetcher) FetchImg(imgName string) (v1.Image, error) {
-	// Try to fetch locally first
+// FetchLocal returns imgName from mcv's image store or a local container
+// engine, without trying its registry.
+func FetchLocal(imgName string) (v1.Image, error) {
+	if img := NewFetcher().(*fetcher).fetchLocal(imgName); img != nil {
+		return img, nil
+	}
+	return nil, fmt.Errorf("image %s not found locally", imgName)
+}
+
+// FetchMetadata returns imgName for reading its manifest and config: from
+// an OCI layout, mcv's image store or its registry, which serves them
+// without the layers. Images only a local container engine has are
+// exported from it in full, as FetchImg does.
+func FetchMetadata(imgName string) (v1.Image, error) {
+	if imgref.IsLayout(imgName) {
+		return (&layoutFetcher{}).FetchImg(imgName)
+	}
+	if img, err := (&storeFetcher{}).FetchImg(imgName); err == nil && hasDigest(img, imgref.Digest(imgName)) {
+		return img, nil
+	}
+	img, err := (&remoteFetcher{}).FetchImg(imgName)
+	if err == nil {
+		return img, nil
+	}
+	logging.Debugf("Looking for %s in the local container engines: %v", imgName, err)
+	if local, lerr := FetchLocal(imgName); lerr == nil {
+		return local, nil
+	}
+	return nil, err
+}
+
+func (f *fetcher) fetchLocal(imgName string) v1.Image {
 	for _, localFetcher := range f.local {
 		logging.Debugf("Trying local fetcher: %T", localFetcher)
 
 		img, _ := localFetcher.FetchImg(imgName)
+		if img != nil && !hasDigest(img, imgref.Digest(imgName)) {
+			// Images exported from docker or podman are re-serialized and
+			// lose their registry digest, so a digest reference is only
+			// satisfied locally by an identical manifest.
+			logging.Debugf("Image found by %T is not %s", localFetcher, imgref.Digest(imgName))
+			img = nil
+		}
 		if img != nil {
 			logging.Debugf("Image found locally using %T", localFetcher)
-			return img, nil
+			return img
 		}
 
 		// If error or image is nil, log and continue to the next fetcher
 		logging.Debugf("Failed to fetch image locally using %T:", localFetcher)
 	}
-
-	// If local fetch fails, try fetching the image remotely
-	img, err := f.remote.FetchImg(imgName)
-	if err != nil || img == nil {
-		return nil, fmt.Errorf("failed to fetch image: %w", err)
-	}
-
-	return img, nil
+	return nil
 }
 
 func fetchToTempTar(fetchFn func(io.Writer) error) (v1.Image, error) {
+	if err := utils.LockBuildDir(); err != nil {
+		return nil, err
+	}
 	tmpDir := filepath.Join(constants.MCVBuildDir, constants.CacheDir)
 
 	if err := os.MkdirAll(tmpDir, 0755); err != nil {
@@ -79,7 +126,7 @@ func fetchToTempTar(fetchFn func(io.Writer) error) (v1.Image, error) {
 	tarballFilePath := path.Join(tmpDir, "tmp.tar")
 	tarballFile, err := os.Create(tarballFilePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tarball file: %v", err)
+		return nil, fmt.Errorf("failed to create tarball file: %w", err)
 	}
 
 	if err := fetchFn(tarballFile); err != nil {
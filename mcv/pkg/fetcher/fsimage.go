@@ -0,0 +1,134 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/faults"
+	"github.com/redhat-et/MCU/mcv/pkg/iolimit"
+	logging "github.com/sirupsen/logrus"
+)
+
+// extractFSImage restores a cache stored as a filesystem image layer: it
+// is mounted, or unpacked, on constants.ExtractCacheDir, then the tar
+// layers with the manifest are extracted on top. It reports false for
+// images without a filesystem image layer.
+func extractFSImage(img v1.Image, cacheType string) ([]string, bool, error) {
+	layers, err := cacheLayers(img, cacheType)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not fetch layers: %w", err)
+	}
+	var fsLayer v1.Layer
+	var format string
+	var rest []v1.Layer
+	for _, l := range layers {
+		mt, err := l.MediaType()
+		if err != nil {
+			return nil, false, fmt.Errorf("could not get media type: %w", err)
+		}
+		if f := cache.FSImageFormat(string(mt)); f != "" && fsLayer == nil {
+			fsLayer, format = l, f
+			continue
+		}
+		rest = append(rest, l)
+	}
+	if fsLayer == nil {
+		return nil, false, nil
+	}
+	digest, err := fsLayer.Digest()
+	if err != nil {
+		return nil, true, fmt.Errorf("could not get layer digest: %w", err)
+	}
+
+	cacheDir := constants.ExtractCacheDir
+	var dirs []string
+	if config.IsMountFSImageEnabled() {
+		// The image backs the mount, so it is kept next to the overlay state.
+		stateDir := filepath.Join(constants.FSImageDir, digest.Hex)
+		blob := filepath.Join(stateDir, "cache."+format)
+		if err := saveLayer(fsLayer, blob); err != nil {
+			return nil, true, err
+		}
+		if err := cache.MountFSImage(blob, format, stateDir, cacheDir); err != nil {
+			return nil, true, err
+		}
+	} else {
+		blob := filepath.Join(constants.MCVBuildDir, digest.Hex+"."+format)
+		if err := saveLayer(fsLayer, blob); err != nil {
+			return nil, true, err
+		}
+		defer os.Remove(blob)
+		existing := dirEntries(cacheDir)
+		if err := cache.UnpackFSImage(blob, format, cacheDir); err != nil {
+			return nil, true, err
+		}
+		for name := range dirEntries(cacheDir) {
+			if !existing[name] {
+				dirs = append(dirs, filepath.Join(cacheDir, name))
+			}
+		}
+		logging.Infof("Unpacked %s cache image into %s", format, cacheDir)
+	}
+
+	if len(rest) > 0 {
+		tarDirs, err := extractLayers(rest, cacheType, types.OCILayer, types.OCILayerZStd)
+		if err != nil {
+			return nil, true, err
+		}
+		dirs = append(dirs, tarDirs...)
+	}
+	return dirs, true, nil
+}
+
+// saveLayer writes the content of layer to path, unless a previous extract
+// already did.
+func saveLayer(layer v1.Layer, path string) error {
+	size, err := layer.Size()
+	if err != nil {
+		return fmt.Errorf("could not get layer size: %w", err)
+	}
+	if st, err := os.Stat(path); err == nil && st.Size() == size {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	r, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("could not get layer content: %w", err)
+	}
+	r = faults.WrapLayer(r)
+	defer r.Close()
+	tmp := path + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(iolimit.WrapWriter(f), r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("could not download cache image: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func dirEntries(dir string) map[string]bool {
+	names := map[string]bool{}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	return names
+}
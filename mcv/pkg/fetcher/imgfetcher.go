@@ -21,8 +21,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -31,8 +33,13 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator"
 	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/chunk"
 	"github.com/redhat-et/MCU/mcv/pkg/config"
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/faults"
+	"github.com/redhat-et/MCU/mcv/pkg/fips"
+	"github.com/redhat-et/MCU/mcv/pkg/pin"
 	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
 	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
@@ -59,6 +66,7 @@ type CacheExtractor interface {
 // ImgMgr retrieves cache images.
 type ImgMgr interface {
 	FetchAndExtractCache(imgName string) error
+	FetchAndExtractCacheContext(ctx context.Context, imgName string) error
 }
 
 // Factory function to create a new ImgMgr.
@@ -141,6 +149,15 @@ func (i *imgFetcher) FetchImg(imgName string) (v1.Image, error) {
 		return nil, fmt.Errorf("failed to get image digest: %w", err)
 	}
 	logging.Debugf("Img Digest: %s", digest)
+	if want := config.ExpectedDigest(); want != "" && digest.String() != want {
+		return nil, fmt.Errorf("image %s is %s, not the verified %s", imgName, digest, want)
+	}
+
+	if config.IsFIPSRequired() {
+		if err := fips.CheckImage(img); err != nil {
+			return nil, fmt.Errorf("image rejected in FIPS mode: %w", err)
+		}
+	}
 
 	size, err := img.Size()
 	if err != nil {
@@ -152,14 +169,14 @@ func (i *imgFetcher) FetchImg(imgName string) (v1.Image, error) {
 }
 
 func (e *cacheExtractor) ExtractCache(img v1.Image) error {
-	var extractedDirs []string
-	ct := ""
-
 	// Fetch image manifest
 	manifest, err := img.Manifest()
 	if err != nil {
 		return fmt.Errorf("failed to fetch manifest: %w", err)
 	}
+	if config.IsKeepTempEnabled() {
+		keepFetchedImage(img)
+	}
 
 	configFile, err := img.ConfigFile()
 	if err != nil {
@@ -170,32 +187,37 @@ func (e *cacheExtractor) ExtractCache(img v1.Image) error {
 	if labels == nil {
 		return errors.New("image has no labels")
 	}
+	if err := checkExpiry(labels, config.ExpiredPolicy(), time.Now()); err != nil {
+		return err
+	}
 
+	if err := utils.LockBuildDir(); err != nil {
+		return err
+	}
 	// Ensure manifest output directory exists
 	constants.ExtractManifestDir = filepath.Join(constants.MCVBuildDir, constants.ManifestDir)
 	if err = os.MkdirAll(constants.ExtractManifestDir, 0755); err != nil {
-		logging.Warnf("Failed to create manifest directory %s: %v", constants.ExtractManifestDir, err)
+		diag.Warnf(diag.ManifestsNotKept, "Failed to create manifest directory %s: %v", constants.ExtractManifestDir, err)
 	}
 	logging.Debugf("Extracting manifest to directory: %s", constants.ExtractManifestDir)
 
-	cacheType, err := preflightcheck.DetectCacheTypeFromLabels(labels)
+	cacheTypes, err := preflightcheck.DetectCacheTypesFromLabels(labels)
 	if err != nil {
 		return err
 	}
-	ct = cacheType
-
-	if constants.ExtractCacheDir == "" {
-		switch cacheType {
-		case constants.Triton:
-			constants.ExtractCacheDir = constants.TritonCacheDir
-		case constants.VLLM:
-			constants.ExtractCacheDir = constants.VLLMCacheDir
-		default:
-			return fmt.Errorf("unsupported cache type: %s", cacheType)
+	for _, ct := range cacheTypes {
+		if defaultCacheDir(ct) == "" {
+			return fmt.Errorf("unsupported cache type: %s", ct)
 		}
 	}
 
-	logging.Infof("Extracting cache to directory: %s", constants.ExtractCacheDir)
+	if config.IsBaremetalEnabled() && !config.IsSkipPrecheckEnabled() {
+		report := preflightcheck.RunBaremetalChecks()
+		report.Log()
+		if report.Failed() {
+			return errors.New("baremetal host preflight checks failed")
+		}
+	}
 
 	if config.IsGPUEnabled() && !config.IsSkipPrecheckEnabled() {
 		devInfo, err := preflightcheck.GetAllGPUInfo(e.acc)
@@ -214,21 +236,159 @@ func (e *cacheExtractor) ExtractCache(img v1.Image) error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := utils.CleanupMCVDirs(ctx, ""); err != nil {
-			logging.Warnf("cleanup failed: %v", err)
+			diag.Warnf(diag.CleanupFailed, "cleanup failed: %v", err)
 		}
 	}()
 
+	// An object store stages the cache locally before uploading it: in the
+	// requested directory, or a temporary one.
+	kind, tmpfsSize := config.CacheStore()
+	if cache.IsObjectStore(kind) && constants.ExtractCacheDir == "" {
+		staging, err := os.MkdirTemp("", "mcv-upload-")
+		if err != nil {
+			return fmt.Errorf("failed to create a staging directory: %w", err)
+		}
+		constants.ExtractCacheDir = staging
+		defer func() {
+			os.RemoveAll(staging)
+			constants.ExtractCacheDir = ""
+		}()
+	}
+
+	if len(cacheTypes) == 1 {
+		if constants.ExtractCacheDir == "" {
+			constants.ExtractCacheDir = defaultCacheDir(cacheTypes[0])
+		}
+		store, err := newCacheStore(kind, tmpfsSize, constants.ExtractCacheDir)
+		if err != nil {
+			return err
+		}
+		return e.extractCacheType(img, manifest.MediaType, labels, cacheTypes[0], store)
+	}
+
+	// Colocated caches each go to their own directory: where the runtime
+	// looks for them, or a subdirectory per type of the requested one.
+	requested := constants.ExtractCacheDir
+	defer func() { constants.ExtractCacheDir = requested }()
+	store, err := newCacheStore(kind, tmpfsSize, requested)
+	if err != nil {
+		return err
+	}
+	logging.Infof("Image holds colocated caches: %v", cacheTypes)
+	for _, ct := range cacheTypes {
+		constants.ExtractCacheDir = defaultCacheDir(ct)
+		if requested != "" {
+			constants.ExtractCacheDir = filepath.Join(requested, ct)
+		}
+		if err := e.extractCacheType(img, manifest.MediaType, labels, ct, store); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ManifestMediaTypes are the image manifest schemas ExtractCache reads.
+var ManifestMediaTypes = []types.MediaType{types.OCIManifestSchema1, types.DockerManifestSchema2}
+
+// defaultCacheDir returns where the runtime reads caches of cacheType from.
+func defaultCacheDir(cacheType string) string {
+	switch cacheType {
+	case constants.Triton:
+		return constants.TritonCacheDir
+	case constants.VLLM:
+		return constants.VLLMCacheDir
+	}
+	return ""
+}
+
+// newCacheStore returns the configured cache store, of kind, for caches
+// extracted below root.
+func newCacheStore(kind string, tmpfsSize int64, root string) (cache.CacheStore, error) {
+	bucket, endpoint, mount := config.Bucket()
+	return cache.NewCacheStore(cache.StoreOptions{
+		Kind:      kind,
+		TmpfsSize: tmpfsSize,
+		Bucket:    bucket,
+		Endpoint:  endpoint,
+		MountPath: mount,
+		Root:      root,
+	})
+}
+
+// extractCacheType extracts the cache of type ct from img into
+// constants.ExtractCacheDir through store, unless it is already there,
+// locked against other nodes if the directory is shared, and normalizes
+// its permissions.
+func (e *cacheExtractor) extractCacheType(img v1.Image, mediaType types.MediaType, labels map[string]string, ct string, store cache.CacheStore) error {
+	if ct == constants.VLLM {
+		dir, err := checkVLLMKey(labels, constants.ExtractCacheDir, config.VLLMKeyMismatch(), config.VLLMPython())
+		if err != nil {
+			return err
+		}
+		constants.ExtractCacheDir = dir
+	}
+	if err := store.Prepare(constants.ExtractCacheDir); err != nil {
+		return fmt.Errorf("failed to prepare %s: %w", constants.ExtractCacheDir, err)
+	}
+	cacheStore = store
+	defer func() { cacheStore = nil }()
+	reused, err := extractOnce(img, constants.ExtractCacheDir, func() error {
+		if err := e.extractInto(img, mediaType, labels, ct); err != nil {
+			return err
+		}
+		return store.Commit(constants.ExtractCacheDir)
+	})
+	if err != nil {
+		return err
+	}
+	entries := extractedKernelDirs
+	noteInstall(constants.ExtractCacheDir, reused)
+	if reused {
+		return nil
+	}
+	extractedEntries = append(extractedEntries, entries...)
+
+	// Only what was extracted: kernels already in the directory keep their
+	// owner and modes, and the directory stays writable.
+	perms, err := ExtractPermissions()
+	if err != nil {
+		return err
+	}
+	return perms.ApplyEntries(entries)
+}
+
+// extractInto extracts the cache of type ct from img into
+// constants.ExtractCacheDir and checks its manifest against the GPUs.
+func (e *cacheExtractor) extractInto(img v1.Image, mediaType types.MediaType, labels map[string]string, ct string) error {
+	logging.Infof("Extracting cache to directory: %s", constants.ExtractCacheDir)
+
+	hotKernelsReady = reportHotKernels(labels, labels[chunk.ChunkedLabel] == "true")
+	defer func() { hotKernelsReady = nil }()
+
+	var extractedDirs []string
 	var extractErr error
 
-	switch manifest.MediaType {
+	switch mediaType {
 	case types.DockerManifestSchema2:
 		extractedDirs, extractErr = extractDockerImg(img, ct)
 	default:
+		// A cache stored as a filesystem image is mounted or unpacked.
+		var handled bool
+		extractedDirs, handled, extractErr = extractFSImage(img, ct)
+		if handled {
+			break
+		}
 		// Try to parse it as the "compat" variant image with a single "application/vnd.oci.image.layer.v1.tar+gzip" layer.
 		extractedDirs, extractErr = extractOCIStandardImg(img, ct)
 		if extractErr != nil {
 			// Otherwise, try to parse it as the *oci* variant image with custom artifact media types.
-			extractedDirs, extractErr = extractOCIArtifactImg(img, ct)
+			var artifactErr error
+			extractedDirs, artifactErr = extractOCIArtifactImg(img, ct)
+			if artifactErr == nil {
+				extractErr = nil
+			} else {
+				extractErr = fmt.Errorf("%w (as an artifact image: %v)", extractErr, artifactErr)
+			}
 		}
 	}
 
@@ -236,8 +396,34 @@ func (e *cacheExtractor) ExtractCache(img v1.Image) error {
 		return fmt.Errorf("could not extract %s Cache: %w", ct, extractErr)
 	}
 
-	// Full manifest compatibility check (after extraction)
+	if labels[chunk.ChunkedLabel] == "true" {
+		if err := reassembleChunks(img, constants.ExtractCacheDir); err != nil {
+			return fmt.Errorf("could not reassemble chunked %s cache: %w", ct, err)
+		}
+	}
+
+	cleanup := func() {
+		for _, dir := range extractedDirs {
+			if rmErr := os.RemoveAll(dir); rmErr != nil {
+				diag.Warnf(diag.CleanupFailed, "Failed to clean up extracted kernel dir %s: %v", dir, rmErr)
+			}
+		}
+	}
+
+	// With --kernels compatible, only the kernels of the local GPUs stay.
 	manifestPath := filepath.Join(constants.ExtractManifestDir, constants.ManifestFileName)
+	if config.Kernels() == KernelsCompatible {
+		devInfo, err := preflightcheck.GetAllGPUInfo(e.acc)
+		if err != nil || devInfo == nil {
+			return fmt.Errorf("failed to get GPU info: %w", err)
+		}
+		if _, err := pruneIncompatibleKernels(constants.ExtractCacheDir, manifestPath, ct, devInfo); err != nil {
+			cleanup()
+			return fmt.Errorf("kernel compatibility check failed: %w", err)
+		}
+	}
+
+	// Full manifest compatibility check (after extraction)
 	if config.IsGPUEnabled() && config.IsBaremetalEnabled() && !config.IsSkipPrecheckEnabled() {
 		devInfo, err := preflightcheck.GetAllGPUInfo(e.acc)
 		if err != nil || devInfo == nil {
@@ -245,32 +431,83 @@ func (e *cacheExtractor) ExtractCache(img v1.Image) error {
 		}
 
 		if err := preflightcheck.CompareCacheManifestToGPU(manifestPath, ct, devInfo); err != nil {
-			for _, dir := range extractedDirs {
-				if rmErr := os.RemoveAll(dir); rmErr != nil {
-					logging.Warnf("Failed to clean up extracted kernel dir %s: %v", dir, rmErr)
-				}
-			}
+			cleanup()
 			return fmt.Errorf("manifest check failed: %w", err)
 		}
 	}
-
+	extractedKernelDirs = extractedDirs
 	return nil
 }
 
 func (i *imgMgr) FetchAndExtractCache(imgName string) error {
+	return i.FetchAndExtractCacheContext(context.Background(), imgName)
+}
+
+// FetchAndExtractCacheContext is FetchAndExtractCache stopping once ctx is
+// done: while waiting for the shared lock, or between the entries of a
+// layer, whose journal is kept so that a later extraction with resume set
+// picks up where this one stopped.
+func (i *imgMgr) FetchAndExtractCacheContext(ctx context.Context, imgName string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("extraction canceled: %w", err)
+	}
+	skippedEntries, installs, extractedEntries = nil, nil, nil
+	extractCtx = ctx
+	defer func() { skippedEntries, installs, extractCtx = nil, nil, context.Background() }()
 	img, err := i.fetcher.FetchImg(imgName)
 	if err != nil {
 		return err
 	}
 
+	var lock *pin.Lock
+	var digest string
+	if config.IsPinDigestsEnabled() {
+		if lock, digest, err = checkPin(img, imgName); err != nil {
+			return err
+		}
+	}
+
 	err = i.extractor.ExtractCache(img)
 	if err != nil {
 		return err
 	}
 
+	if lock != nil && lock.Set(imgName, digest) {
+		if err := lock.Save(config.PinLockFile()); err != nil {
+			return fmt.Errorf("failed to record digest pin: %w", err)
+		}
+		logging.Infof("Pinned %s to %s", pin.Key(imgName), digest)
+	}
+	if d, err := img.Digest(); err == nil {
+		recordInstalls(imgName, d.String())
+	}
+	if len(skippedEntries) > 0 {
+		return &PartialError{Skipped: skippedEntries}
+	}
 	return nil
 }
 
+// checkPin loads the pin lock file and refuses img if imgName is pinned to
+// another digest, unless pins may be updated. It returns the lock and the
+// digest to record once the extraction succeeds.
+func checkPin(img v1.Image, imgName string) (*pin.Lock, string, error) {
+	d, err := img.Digest()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get image digest: %w", err)
+	}
+	lock, err := pin.Load(config.PinLockFile())
+	if err != nil {
+		return nil, "", err
+	}
+	if err := lock.Check(imgName, d.String()); err != nil {
+		if !config.IsUpdatePinEnabled() {
+			return nil, "", fmt.Errorf("%w (use --update-pin to accept the new digest)", err)
+		}
+		diag.Warnf(diag.PinUpdated, "Updating pin: %v", err)
+	}
+	return lock, d.String(), nil
+}
+
 // extractOCIArtifactImg extracts the triton/vllm cache from the
 // *oci* variant Kernel Cache image:  //TODO ADD URL
 func extractOCIArtifactImg(img v1.Image, cacheType string) ([]string, error) {
@@ -280,7 +517,7 @@ func extractOCIArtifactImg(img v1.Image, cacheType string) ([]string, error) {
 
 	layers, err := img.Layers()
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch layers: %v", err)
+		return nil, fmt.Errorf("could not fetch layers: %w", err)
 	}
 
 	// The image must be single-layered.
@@ -314,13 +551,14 @@ func extractOCIArtifactImg(img v1.Image, cacheType string) ([]string, error) {
 	// since internally it tries to umcompress it as gzipped blob.
 	r, err := layer.Compressed()
 	if err != nil {
-		return nil, fmt.Errorf("could not get layer content: %v", err)
+		return nil, fmt.Errorf("could not get layer content: %w", err)
 	}
+	r = faults.WrapLayer(r)
 	defer r.Close()
 
-	dirs, err := cache.ExtractCacheDirectory(r, cacheType)
+	dirs, err := extractLayer(layer, r, cacheType)
 	if err != nil {
-		return nil, fmt.Errorf("could not extract %s Kernel Cache: %v", cacheType, err)
+		return nil, fmt.Errorf("could not extract %s Kernel Cache: %w", cacheType, err)
 	}
 	return dirs, nil
 }
@@ -334,9 +572,9 @@ func extractDockerImg(img v1.Image, cacheType string) ([]string, error) {
 		return nil, fmt.Errorf("cache type is empty")
 	}
 
-	layers, err := img.Layers()
+	layers, err := cacheLayers(img, cacheType)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch layers: %v", err)
+		return nil, fmt.Errorf("could not fetch layers: %w", err)
 	}
 
 	// The image must have at least one layer.
@@ -344,28 +582,8 @@ func extractDockerImg(img v1.Image, cacheType string) ([]string, error) {
 		return nil, errors.New("number of layers must be greater than zero")
 	}
 
-	layer := layers[len(layers)-1]
-	mt, err := layer.MediaType()
-	if err != nil {
-		return nil, fmt.Errorf("could not get media type: %v", err)
-	}
-
 	// Media type must be application/vnd.docker.image.rootfs.diff.tar.gzip.
-	if mt != types.DockerLayer {
-		return nil, fmt.Errorf("invalid media type %s (expect %s)", mt, types.DockerLayer)
-	}
-
-	r, err := layer.Compressed()
-	if err != nil {
-		return nil, fmt.Errorf("could not get layer content: %v", err)
-	}
-	defer r.Close()
-
-	dirs, err := cache.ExtractCacheDirectory(r, cacheType)
-	if err != nil {
-		return nil, fmt.Errorf("could not extract %s Kernel Cache: %v", cacheType, err)
-	}
-	return dirs, nil
+	return extractLayers(layers, cacheType, types.DockerLayer)
 }
 
 // extractOCIStandardImg extracts the Triton/vLLM Kernel Cache from the
@@ -376,9 +594,9 @@ func extractOCIStandardImg(img v1.Image, cacheType string) ([]string, error) {
 		return nil, fmt.Errorf("cache type is empty")
 	}
 
-	layers, err := img.Layers()
+	layers, err := cacheLayers(img, cacheType)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch layers: %v", err)
+		return nil, fmt.Errorf("could not fetch layers: %w", err)
 	}
 
 	// The image must have at least one layer.
@@ -386,26 +604,182 @@ func extractOCIStandardImg(img v1.Image, cacheType string) ([]string, error) {
 		return nil, fmt.Errorf("number of layers must be greater than zero")
 	}
 
-	layer := layers[len(layers)-1]
-	mt, err := layer.MediaType()
+	// Layers must be "application/vnd.oci.image.layer.v1.tar+gzip" or the
+	// zstd counterpart.
+	return extractLayers(layers, cacheType, types.OCILayer, types.OCILayerZStd)
+}
+
+// cacheLayers returns the layers of img that may hold the cache of
+// cacheType: those mcv wrote, but those annotated as holding another cache
+// type. The layers of a base image the cache was built on are skipped.
+func cacheLayers(img v1.Image, cacheType string) ([]v1.Layer, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Layers) != len(layers) {
+		return layers, nil
+	}
+	ours := mcvLayers(img, manifest)
+	var typed []v1.Layer
+	for i, l := range layers {
+		if !ours[i] {
+			continue
+		}
+		if t, ok := manifest.Layers[i].Annotations[cache.LayerTypeAnnotation]; ok && t != cacheType {
+			continue
+		}
+		typed = append(typed, l)
+	}
+	return typed, nil
+}
+
+// mcvLayers reports which layers of img mcv create wrote: those annotated
+// with a cache type, or with an mcv create history entry. If none is, as
+// in images built by other tools, the cache is in the top layer.
+func mcvLayers(img v1.Image, manifest *v1.Manifest) []bool {
+	ours := make([]bool, len(manifest.Layers))
+	found := false
+	for i, l := range manifest.Layers {
+		if _, ok := l.Annotations[cache.LayerTypeAnnotation]; ok {
+			ours[i], found = true, true
+		}
+	}
+	if cfg, err := img.ConfigFile(); err == nil {
+		var history []v1.History
+		for _, h := range cfg.History {
+			if !h.EmptyLayer {
+				history = append(history, h)
+			}
+		}
+		// History not matching the layers one for one cannot tell them apart.
+		if len(history) == len(ours) {
+			for i, h := range history {
+				if strings.HasPrefix(h.CreatedBy, "mcv create") {
+					ours[i], found = true, true
+				}
+			}
+		}
+	}
+	if !found && len(ours) > 0 {
+		ours[len(ours)-1] = true
+	}
+	return ours
+}
+
+// extractLayers applies the cache content of layers from the bottom up, so
+// a cache layer stacked on an earlier cache image (a delta image) replaces
+// its files and deletes them through OCI whiteouts, as a container runtime
+// would. Every layer must have one of the accepted media types; base image
+// layers are not passed in, see cacheLayers.
+func extractLayers(layers []v1.Layer, cacheType string, accepted ...types.MediaType) ([]string, error) {
+	for _, layer := range layers {
+		mt, err := layer.MediaType()
+		if err != nil {
+			return nil, fmt.Errorf("could not get media type: %w", err)
+		}
+		if !slices.Contains(accepted, mt) {
+			return nil, fmt.Errorf("invalid media type %s (expect %s)", mt, joinMediaTypes(accepted))
+		}
+	}
+
+	e, err := newLayerExtractor(cacheType, len(layers) == 1)
 	if err != nil {
-		return nil, fmt.Errorf("could not get media type: %v", err)
+		return nil, err
+	}
+	for i, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("could not get layer digest: %w", err)
+		}
+		r, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("could not get layer content: %w", err)
+		}
+		r = faults.WrapLayer(r)
+		err = e.Apply(r, digest.String())
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not extract %s Kernel Cache from layer %d: %w", cacheType, i+1, err)
+		}
 	}
+	skippedEntries = append(skippedEntries, e.Skipped()...)
+	return e.Dirs(), nil
+}
 
-	// Check if the layer is "application/vnd.oci.image.layer.v1.tar+gzip".
-	if types.OCILayer != mt {
-		return nil, fmt.Errorf("invalid media type %s (expect %s)", mt, types.OCILayer)
+func joinMediaTypes(mts []types.MediaType) string {
+	names := make([]string, len(mts))
+	for i, mt := range mts {
+		names[i] = string(mt)
 	}
+	return strings.Join(names, " or ")
+}
 
-	r, err := layer.Compressed()
+// extractLayer extracts the cache content read from layer, journaling
+// progress under the layer digest so an interrupted extract can be resumed.
+func extractLayer(layer v1.Layer, r io.Reader, cacheType string) ([]string, error) {
+	digest, err := layer.Digest()
 	if err != nil {
-		return nil, fmt.Errorf("could not get layer content: %v", err)
+		return nil, fmt.Errorf("could not get layer digest: %w", err)
 	}
-	defer r.Close()
+	e, err := newLayerExtractor(cacheType, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Apply(r, digest.String()); err != nil {
+		return nil, err
+	}
+	skippedEntries = append(skippedEntries, e.Skipped()...)
+	return e.Dirs(), nil
+}
+
+// cacheStore, if set, is the store the cache being extracted is written
+// through.
+var cacheStore cache.CacheStore
 
-	dirs, err := cache.ExtractCacheDirectory(r, cacheType)
+// extractCtx is the context of the current FetchAndExtractCacheContext.
+var extractCtx = context.Background()
+
+// newLayerExtractor returns the extractor of the layers of a cache, which
+// reports its hot kernels ready if the cache is in a single layer.
+func newLayerExtractor(cacheType string, single bool) (*cache.LayerExtractor, error) {
+	e, err := cache.NewLayerExtractor(cacheType, config.IsResumeExtractEnabled())
 	if err != nil {
-		return nil, fmt.Errorf("could not extract %s Kernel Cache: %v", cacheType, err)
+		return nil, err
+	}
+	skipBadEntries(e)
+	e.SetContext(extractCtx)
+	if cacheStore != nil {
+		e.SetStore(cacheStore)
+	}
+	if single && hotKernelsReady != nil {
+		e.OnHotKernels(hotKernelsReady)
+	}
+	return e, nil
+}
+
+// keepFetchedImage writes the manifest and config of img to the build dir,
+// which is kept with --keep-temp, for debugging.
+func keepFetchedImage(img v1.Image) {
+	dir := filepath.Join(constants.MCVBuildDir, "fetched")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		diag.Warnf(diag.ManifestsNotKept, "Failed to keep the fetched manifests: %v", err)
+		return
+	}
+	for name, raw := range map[string]func() ([]byte, error){
+		"manifest.json": img.RawManifest,
+		"config.json":   img.RawConfigFile,
+	} {
+		data, err := raw()
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, name), data, 0644)
+		}
+		if err != nil {
+			diag.Warnf(diag.ManifestsNotKept, "Failed to keep the fetched %s: %v", name, err)
+		}
 	}
-	return dirs, nil
 }
@@ -0,0 +1,57 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+)
+
+// FetchIndex returns the image index imgName refers to in an OCI layout
+// or a registry, or nil if it refers to a single image. Images in mcv's
+// image store are single images. A registry that cannot be reached is
+// left for FetchImg to report, or to find the image locally.
+func FetchIndex(imgName string) (v1.ImageIndex, error) {
+	if imgref.IsLayout(imgName) {
+		dir, tag, digest := imgref.SplitLayout(imgName)
+		p, err := layout.FromPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open OCI layout %s: %w", dir, err)
+		}
+		idx, err := p.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OCI layout %s: %w", dir, err)
+		}
+		d, err := layoutDescriptor(idx, dir, tag, digest)
+		if err != nil || !d.MediaType.IsIndex() {
+			return nil, err
+		}
+		return idx.ImageIndex(d.Digest)
+	}
+
+	if _, err := imgstore.Load(imgName); err == nil {
+		return nil, nil
+	}
+	ref, err := registry.ParseReference(imgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image name: %w", err)
+	}
+	// The images of the index are read once it is returned, under the
+	// same deadline.
+	ctx, _ := registry.Context(context.Background(), registry.OpPull)
+	desc, err := remote.Get(ref, registry.Options(remote.WithContext(ctx))...)
+	if err != nil {
+		logging.Debugf("Not checking whether %s is an image index: %v", imgName, err)
+		return nil, nil
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, nil
+	}
+	return desc.ImageIndex()
+}
@@ -0,0 +1,72 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/inventory"
+)
+
+// extractedKernelDirs holds the kernel directories the last extractInto
+// wrote.
+var extractedKernelDirs []string
+
+// installs collects the directories the current FetchAndExtractCache
+// extracted into, to record in the inventory once it succeeds.
+var installs []inventory.Install
+
+// noteInstall notes that the cache was extracted into dir, or found there
+// already if reused. Caches uploaded to an object store are not on the
+// host, and are not noted.
+func noteInstall(dir string, reused bool) {
+	defer func() { extractedKernelDirs = nil }()
+	if kind, _ := config.CacheStore(); cache.IsObjectStore(kind) {
+		return
+	}
+	var entries []string
+	if !reused {
+		for _, d := range extractedKernelDirs {
+			rel, err := filepath.Rel(dir, d)
+			if err != nil || rel == "." || !filepath.IsLocal(rel) {
+				continue
+			}
+			// Kernels of other GPUs may have been pruned since.
+			if _, err := os.Stat(d); err == nil {
+				entries = append(entries, rel)
+			}
+		}
+		if len(entries) == 0 {
+			return
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	installs = append(installs, inventory.Install{Dir: dir, Entries: entries})
+}
+
+// recordInstalls records in the inventory that imgName, of digest, was
+// extracted into the directories noted. The extraction succeeded even if
+// it cannot be recorded.
+func recordInstalls(imgName, digest string) {
+	if len(installs) == 0 {
+		return
+	}
+	path := config.InventoryFile()
+	inv, err := inventory.Load(path)
+	if err != nil {
+		diag.Warnf(diag.InventoryNotUpdated, "Not recording %s as installed: %v", imgName, err)
+		return
+	}
+	for _, in := range installs {
+		in.Image, in.Digest, in.InstalledAt = imgName, digest, time.Now().UTC()
+		inv.Record(in)
+	}
+	if err := inv.Save(path); err != nil {
+		diag.Warnf(diag.InventoryNotUpdated, "Not recording %s as installed: %v", imgName, err)
+	}
+}
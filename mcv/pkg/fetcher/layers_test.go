@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func appendLayer(t *testing.T, img v1.Image, createdBy string, annotations map[string]string) (v1.Image, v1.Hash) {
+	l, err := random.Layer(64, types.OCILayer)
+	assert.NoError(t, err)
+	d, err := l.Digest()
+	assert.NoError(t, err)
+	img, err = mutate.Append(img, mutate.Addendum{
+		Layer:       l,
+		MediaType:   types.OCILayer,
+		Annotations: annotations,
+		History:     v1.History{CreatedBy: createdBy},
+	})
+	assert.NoError(t, err)
+	return img, d
+}
+
+func layerDigests(t *testing.T, layers []v1.Layer) []v1.Hash {
+	var digests []v1.Hash
+	for _, l := range layers {
+		d, err := l.Digest()
+		assert.NoError(t, err)
+		digests = append(digests, d)
+	}
+	return digests
+}
+
+func TestCacheLayers(t *testing.T) {
+	base := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+
+	// The layers of a base OS image are skipped.
+	img, _ := appendLayer(t, base, "/bin/sh -c #(nop) ADD file:rootfs in /", nil)
+	img, chunks := appendLayer(t, img, "mcv create", nil)
+	img, vllm := appendLayer(t, img, "mcv create img", map[string]string{cache.LayerTypeAnnotation: "vllm"})
+	img, triton := appendLayer(t, img, "mcv create img", map[string]string{cache.LayerTypeAnnotation: "triton"})
+	layers, err := cacheLayers(img, "triton")
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.Hash{chunks, triton}, layerDigests(t, layers))
+	layers, err = cacheLayers(img, "vllm")
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.Hash{chunks, vllm}, layerDigests(t, layers))
+
+	// Images built by other tools hold the cache in the top layer.
+	img, _ = appendLayer(t, base, "ADD rootfs", nil)
+	img, top := appendLayer(t, img, "COPY cache /", nil)
+	layers, err = cacheLayers(img, "triton")
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.Hash{top}, layerDigests(t, layers))
+}
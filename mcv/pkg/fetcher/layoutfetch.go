@@ -0,0 +1,111 @@
+package fetcher
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	logging "github.com/sirupsen/logrus"
+)
+
+// layoutFetcher reads images from an OCI image layout directory, such as
+// one written by skopeo, buildkit or oras, referenced as
+// oci:/path[:tag|@digest].
+type layoutFetcher struct{}
+
+func (l *layoutFetcher) FetchImg(imgName string) (v1.Image, error) {
+	dir, tag, digest := imgref.SplitLayout(imgName)
+	logging.Debugf("Reading image from OCI layout %s", dir)
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI layout %s: %w", dir, err)
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI layout %s: %w", dir, err)
+	}
+	return layoutImage(idx, dir, tag, digest)
+}
+
+// layoutImage returns the image in idx with the given tag or digest or,
+// when neither is given, its only image. Nested indexes, as written for
+// multi-platform images, resolve to the image for this platform.
+func layoutImage(idx v1.ImageIndex, dir, tag, digest string) (v1.Image, error) {
+	d, err := layoutDescriptor(idx, dir, tag, digest)
+	if err != nil {
+		return nil, err
+	}
+	if !d.MediaType.IsIndex() {
+		return idx.Image(d.Digest)
+	}
+	child, err := idx.ImageIndex(d.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image index %s in OCI layout %s: %w", d.Digest, dir, err)
+	}
+	cm, err := child.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image index %s in OCI layout %s: %w", d.Digest, dir, err)
+	}
+	platform := v1.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	for _, cd := range cm.Manifests {
+		if cd.Platform == nil || cd.Platform.Satisfies(platform) || len(cm.Manifests) == 1 {
+			return child.Image(cd.Digest)
+		}
+	}
+	return nil, fmt.Errorf("image index %s in OCI layout %s has no image for %s/%s", d.Digest, dir, platform.OS, platform.Architecture)
+}
+
+// layoutDescriptor returns the entry of idx with the given tag or digest
+// or, when neither is given, its only entry.
+func layoutDescriptor(idx v1.ImageIndex, dir, tag, digest string) (v1.Descriptor, error) {
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("failed to read OCI layout %s: %w", dir, err)
+	}
+
+	// skopeo and buildkit name images by tag alone; other tools, and
+	// mcv's own store, by the full reference ending in the tag.
+	var matches, suffixMatches []v1.Descriptor
+	var names []string
+	for _, d := range m.Manifests {
+		name := d.Annotations[imgstore.RefNameAnnotation]
+		if name != "" {
+			names = append(names, name)
+		}
+		switch {
+		case digest != "":
+			if d.Digest.String() == digest {
+				matches = append(matches, d)
+			}
+		case tag != "":
+			if name == tag {
+				matches = append(matches, d)
+			} else if strings.HasSuffix(name, ":"+tag) {
+				suffixMatches = append(suffixMatches, d)
+			}
+		default:
+			matches = append(matches, d)
+		}
+	}
+	if len(matches) == 0 {
+		matches = suffixMatches
+	}
+	switch {
+	case len(matches) == 0 && tag != "":
+		return v1.Descriptor{}, fmt.Errorf("no image tagged %s in OCI layout %s (images: %s)", tag, dir, strings.Join(names, ", "))
+	case len(matches) == 0 && digest != "":
+		return v1.Descriptor{}, fmt.Errorf("no image %s in OCI layout %s", digest, dir)
+	case len(matches) == 0:
+		return v1.Descriptor{}, fmt.Errorf("OCI layout %s holds no image", dir)
+	case len(matches) > 1 && digest == "":
+		return v1.Descriptor{}, fmt.Errorf("OCI layout %s holds %d matching images, select one with %s%s@<digest> (images: %s)",
+			dir, len(matches), imgref.LayoutPrefix, dir, strings.Join(names, ", "))
+	}
+	return matches[0], nil
+}
+
+var _ Fetcher = (*layoutFetcher)(nil)
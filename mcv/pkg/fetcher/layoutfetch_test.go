@@ -0,0 +1,70 @@
+package fetcher
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayoutFetcher(t *testing.T) {
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	assert.NoError(t, err)
+
+	v1Img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	v2Img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	assert.NoError(t, p.AppendImage(v1Img, layout.WithAnnotations(map[string]string{imgstore.RefNameAnnotation: "v1"})))
+	assert.NoError(t, p.AppendImage(v2Img, layout.WithAnnotations(map[string]string{imgstore.RefNameAnnotation: "quay.io/org/cache:v2"})))
+
+	digestOf := func(img v1.Image) string {
+		d, err := img.Digest()
+		assert.NoError(t, err)
+		return d.String()
+	}
+	f := NewFetcher()
+	for ref, want := range map[string]v1.Image{
+		"oci:" + dir + ":v1":                 v1Img,
+		"oci:" + dir + ":v2":                 v2Img,
+		"oci:" + dir + "@" + digestOf(v2Img): v2Img,
+	} {
+		img, err := f.FetchImg(ref)
+		assert.NoError(t, err, ref)
+		assert.Equal(t, digestOf(want), digestOf(img), ref)
+	}
+
+	_, err = f.FetchImg("oci:" + dir)
+	assert.ErrorContains(t, err, "2 matching images")
+	_, err = f.FetchImg("oci:" + dir + ":v3")
+	assert.ErrorContains(t, err, "no image tagged v3")
+	_, err = f.FetchImg("oci:" + t.TempDir())
+	assert.Error(t, err)
+
+	d, err := ResolveDigest("oci:" + dir + ":v1")
+	assert.NoError(t, err)
+	assert.Equal(t, digestOf(v1Img), d)
+}
+
+func TestLayoutFetcherIndex(t *testing.T) {
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	assert.NoError(t, err)
+
+	img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+	assert.NoError(t, p.AppendIndex(idx))
+
+	got, err := (&layoutFetcher{}).FetchImg("oci:" + dir)
+	assert.NoError(t, err)
+	want, _ := img.Digest()
+	gotDigest, _ := got.Digest()
+	assert.Equal(t, want, gotDigest)
+}
@@ -0,0 +1,46 @@
+package fetcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/sharedfs"
+)
+
+// overwriteHook, if set, is asked before extracting into a directory that
+// holds files of another image or of no image.
+var overwriteHook func(dir string, files int) error
+
+// OnOverwrite makes extraction call hook before it writes into a directory
+// already holding files other than those of the image, e.g. to ask for
+// confirmation or move them aside; files is how many there are. The
+// extraction stops with the error hook returns. Resumed extractions and
+// extractions repairing a cache of the same image do not call it.
+func OnOverwrite(hook func(dir string, files int) error) {
+	overwriteHook = hook
+}
+
+// MoveAside moves what dir holds to a new directory next to it, named
+// after dir and the time, and returns that directory. The lock of an
+// extraction into dir in progress stays.
+func MoveAside(dir string) (string, error) {
+	backup := fmt.Sprintf("%s.bak-%s", filepath.Clean(dir), time.Now().Format("20060102-150405"))
+	if err := os.Mkdir(backup, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if e.Name() == sharedfs.LockFileName {
+			continue
+		}
+		if err := os.Rename(filepath.Join(dir, e.Name()), filepath.Join(backup, e.Name())); err != nil {
+			return "", fmt.Errorf("failed to move %s aside to %s: %w", e.Name(), backup, err)
+		}
+	}
+	return backup, nil
+}
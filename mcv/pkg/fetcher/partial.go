@@ -0,0 +1,42 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+)
+
+// PartialError is returned by an extraction that skipped the cache entries
+// it could not extract, with the skip entry error policy, and extracted
+// the rest.
+type PartialError struct {
+	Skipped []cache.SkippedEntry
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d cache entries could not be extracted", len(e.Skipped))
+}
+
+// IsPartial reports whether err is only that some entries were skipped,
+// returning them if so.
+func IsPartial(err error) ([]cache.SkippedEntry, bool) {
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		return partial.Skipped, true
+	}
+	return nil, false
+}
+
+// skippedEntries collects the entries skipped by the layer extractors of
+// the current FetchAndExtractCache.
+var skippedEntries []cache.SkippedEntry
+
+// skipBadEntries makes e skip bad entries if the entry error policy says
+// to.
+func skipBadEntries(e *cache.LayerExtractor) {
+	if config.EntryErrors() == cache.EntryErrorsSkip {
+		e.SkipBadEntries()
+	}
+}
@@ -0,0 +1,23 @@
+package fetcher
+
+import (
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+)
+
+// ExtractPermissions returns the ownership and modes extracted caches are
+// normalized to.
+func ExtractPermissions() (cache.Permissions, error) {
+	return cache.ParsePermissions(config.ExtractPermissions())
+}
+
+// extractedEntries holds the kernel directories the last
+// FetchAndExtractCache wrote, in every directory it extracted into.
+var extractedEntries []string
+
+// ExtractedEntries returns the kernel directories the last
+// FetchAndExtractCache wrote, for their permissions to be normalized
+// without touching the other files of the cache directories.
+func ExtractedEntries() []string {
+	return extractedEntries
+}
@@ -0,0 +1,115 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	logging "github.com/sirupsen/logrus"
+)
+
+// The stages of readiness written to the ready file.
+const (
+	// ReadyHot means the hot kernels are extracted and the runtime can
+	// start, while the other kernels still are being extracted.
+	ReadyHot = "hot"
+	// ReadyComplete means the whole cache is extracted.
+	ReadyComplete = "complete"
+)
+
+// hotKernelsReady, if set, is called by the extraction of a single cache
+// layer with the directories of its hot entries once they are extracted.
+var hotKernelsReady func(dirs []string)
+
+// hotReadyDisabled keeps the hot kernels from being reported ready, see
+// WithoutHotReady.
+var hotReadyDisabled bool
+
+// readyHook, if set, is told each stage as it is reached.
+var readyHook func(stage string)
+
+// OnReady makes SignalReady also call hook with each stage reached, e.g.
+// to serve the readiness of the extraction.
+func OnReady(hook func(stage string)) {
+	readyHook = hook
+}
+
+// WithoutHotReady runs extract without reporting the hot kernels ready,
+// for extractions that go on changing the cache once FetchAndExtractCache
+// returns, such as pruning or changing its owner.
+func WithoutHotReady(extract func() error) error {
+	hotReadyDisabled = true
+	defer func() { hotReadyDisabled = false }()
+	return extract()
+}
+
+// ClearReady removes the ready file of an earlier extraction.
+func ClearReady() {
+	if path := config.ReadyFile(); path != "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			diag.Warnf(diag.ReadyFileFailed, "Failed to remove ready file %s: %v", path, err)
+		}
+	}
+}
+
+// SignalReady reports the cache ready to use at stage, in the log, in the
+// ready file if one is configured and to the OnReady hook.
+func SignalReady(stage string) {
+	switch stage {
+	case ReadyHot:
+		logging.Info("Hot kernels extracted, the cache is ready to use while the rest is extracted")
+	case ReadyComplete:
+		logging.Debug("Cache extraction complete")
+	}
+	if readyHook != nil {
+		readyHook(stage)
+	}
+	path := config.ReadyFile()
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		diag.Warnf(diag.ReadyFileFailed, "Failed to write ready file %s: %v", path, err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(stage+"\n"), 0644); err != nil {
+		diag.Warnf(diag.ReadyFileFailed, "Failed to write ready file %s: %v", path, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		diag.Warnf(diag.ReadyFileFailed, "Failed to write ready file %s: %v", path, err)
+	}
+}
+
+// reportHotKernels returns the function the extraction of the image with
+// labels calls once its hot kernels are extracted, or nil when they are
+// not usable before the whole extraction is done: when the image holds
+// several caches, its files are reassembled from chunks afterwards, or the
+// manifest check afterwards may remove them.
+func reportHotKernels(labels map[string]string, chunked bool) func(dirs []string) {
+	if hotReadyDisabled {
+		return nil
+	}
+	if types, err := preflightcheck.DetectCacheTypesFromLabels(labels); err != nil || len(types) != 1 {
+		return nil
+	}
+	if chunked || (config.IsGPUEnabled() && config.IsBaremetalEnabled() && !config.IsSkipPrecheckEnabled()) {
+		return nil
+	}
+	return func(dirs []string) {
+		perms, err := ExtractPermissions()
+		for _, d := range dirs {
+			if err == nil {
+				err = perms.Apply(d)
+			}
+		}
+		if err != nil {
+			diag.Warnf(diag.ReadyFileFailed, "Not reporting the hot kernels ready: %v", err)
+			return
+		}
+		SignalReady(ReadyHot)
+	}
+}
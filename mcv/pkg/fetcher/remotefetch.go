@@ -1,12 +1,12 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -14,15 +14,18 @@ type remoteFetcher struct{}
 
 func (r *remoteFetcher) FetchImg(imgName string) (v1.Image, error) {
 	// Parse the image name into a reference (e.g., quay.io/tkm/triton-cache)
-	ref, err := name.ParseReference(imgName)
+	ref, err := registry.ParseReference(imgName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image name: %w", err)
 	}
 
 	logging.Debugf("Retrieve remote Img %s!!!!!!!!", imgName)
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	// The layers are read once the image is returned, under the same
+	// deadline.
+	ctx, _ := registry.Context(context.Background(), registry.OpPull)
+	img, err := remote.Image(ref, registry.Options(remote.WithContext(ctx))...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch image: %w", err)
+		return nil, fmt.Errorf("failed to fetch image: %w", registry.TimeoutError(ctx, err))
 	}
 
 	// Print the image details
@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/sharedfs"
+	logging "github.com/sirupsen/logrus"
+)
+
+// When extract locks a cache directory against other nodes sharing it.
+const (
+	SharedLockAuto = "auto" // Lock directories on network filesystems
+	SharedLockOn   = "on"   // Always lock
+	SharedLockOff  = "off"  // Never lock
+)
+
+// SharedLockModes returns the supported shared lock modes.
+func SharedLockModes() []string {
+	return []string{SharedLockAuto, SharedLockOn, SharedLockOff}
+}
+
+// ValidateSharedLockMode checks mode is supported.
+func ValidateSharedLockMode(mode string) error {
+	for _, m := range SharedLockModes() {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported shared lock mode %q (supported: %s)", mode, strings.Join(SharedLockModes(), ", "))
+}
+
+// extractOnce runs extract into dir unless img was already extracted
+// there, as recorded by the directory's marker, and reports whether the
+// earlier result was reused. If dir is shared with other nodes, it holds
+// the directory's lease while it does, so of several nodes extracting img
+// into it one does it and the others wait, then reuse its result.
+func extractOnce(img v1.Image, dir string, extract func() error) (bool, error) {
+	digest, err := img.Digest()
+	if err != nil {
+		return false, fmt.Errorf("failed to get image digest: %w", err)
+	}
+	mode := config.SharedLock()
+	fs := sharedfs.Filesystem(dir)
+	if mode == SharedLockOff || (mode == SharedLockAuto && fs == "") {
+		return extractUnlessMarked(dir, digest.String(), nil, extract)
+	}
+	if fs != "" {
+		logging.Debugf("%s is on %s, locking it against other nodes", dir, fs)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	ctx := extractCtx
+	if wait := config.SharedLockWait(); wait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wait)
+		defer cancel()
+	}
+	lease, err := sharedfs.Acquire(ctx, dir, sharedfs.Options{StaleAfter: config.SharedLockStale()})
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := lease.Release(); err != nil {
+			diag.Warnf(diag.LockReleaseFailed, "Failed to release the lock on %s: %v", dir, err)
+		}
+	}()
+	return extractUnlessMarked(dir, digest.String(), lease, extract)
+}
+
+// extractUnlessMarked runs extract into dir, then marks it as holding
+// digest, unless its marker shows digest was already extracted there and
+// none of the files are missing. With --force it always extracts. Before
+// extracting over files of another image, or of none, it calls the
+// OnOverwrite hook.
+func extractUnlessMarked(dir, digest string, lease *sharedfs.Lease, extract func() error) (bool, error) {
+	marker, err := sharedfs.ReadMarker(dir)
+	if err != nil {
+		diag.Warnf(diag.MarkerIgnored, "Ignoring extraction marker: %v", err)
+	}
+	if marker != nil && marker.Digest == digest && !config.IsForceExtractEnabled() {
+		if files := countFiles(dir); files >= marker.Files {
+			logging.Infof("%s already extracted this image into %s at %s, reusing it (--force to extract again)",
+				marker.Owner, dir, marker.Time.Format(time.RFC3339))
+			return true, nil
+		}
+		logging.Infof("Files extracted into %s are missing, extracting again", dir)
+	}
+	if overwriteHook != nil && (marker == nil || marker.Digest != digest) && !config.IsResumeExtractEnabled() {
+		if files := countFiles(dir); files > 0 {
+			if err := overwriteHook(dir, files); err != nil {
+				return false, err
+			}
+		}
+	}
+	// Until the new marker is written, the directory holds no complete
+	// cache to reuse.
+	if err := sharedfs.RemoveMarker(dir); err != nil {
+		return false, fmt.Errorf("failed to remove extraction marker: %w", err)
+	}
+
+	skipped := len(skippedEntries)
+	if err := extract(); err != nil {
+		return false, err
+	}
+	if lease != nil {
+		if err := lease.Lost(); err != nil {
+			return false, fmt.Errorf("another node may have extracted into %s at the same time: %w", dir, err)
+		}
+	}
+	if len(skippedEntries) > skipped {
+		// Extracting again retries the skipped entries.
+		diag.Warnf(diag.PartialExtraction, "Not marking %s as extracted: %d entries were skipped", dir, len(skippedEntries)-skipped)
+		return false, nil
+	}
+	return false, sharedfs.WriteMarker(dir, digest, countFiles(dir))
+}
+
+// countFiles returns the number of regular files under dir, besides those
+// coordinating extractions.
+func countFiles(dir string) int {
+	n := 0
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() && d.Name() != sharedfs.MarkerFileName && d.Name() != sharedfs.LockFileName {
+			n++
+		}
+		return nil
+	})
+	return n
+}
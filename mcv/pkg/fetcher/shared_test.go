@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/sharedfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractOnceShared(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+	config.SetSharedLock(SharedLockOn)
+	defer config.SetSharedLock(SharedLockAuto)
+
+	img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "cache")
+	extractions := 0
+	extract := func() error {
+		extractions++
+		return os.WriteFile(filepath.Join(dir, "kernel"), []byte("k"), 0644)
+	}
+
+	reused, err := extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.False(t, reused)
+	assert.NoFileExists(t, filepath.Join(dir, sharedfs.LockFileName))
+
+	// Another node extracting the same image reuses the cache.
+	reused, err = extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, 1, extractions)
+
+	other, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	reused, err = extractOnce(other, dir, extract)
+	assert.NoError(t, err)
+	assert.False(t, reused)
+	assert.Equal(t, 2, extractions)
+
+	assert.ErrorContains(t, ValidateSharedLockMode("always"), "unsupported shared lock mode")
+}
+
+func TestExtractOnce(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+	config.SetSharedLock(SharedLockOff)
+	defer config.SetSharedLock(SharedLockAuto)
+
+	img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "cache")
+	extractions := 0
+	extract := func() error {
+		extractions++
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "ab"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "ab", "kernel.cubin"), []byte("k"), 0644))
+		return os.WriteFile(filepath.Join(dir, "ab", "kernel.json"), []byte("{}"), 0644)
+	}
+
+	reused, err := extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.False(t, reused)
+	marker, err := sharedfs.ReadMarker(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, marker.Files)
+
+	// A restarted init container skips the extraction.
+	reused, err = extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, 1, extractions)
+
+	// Unless files were removed since.
+	assert.NoError(t, os.Remove(filepath.Join(dir, "ab", "kernel.cubin")))
+	reused, err = extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.False(t, reused)
+	assert.Equal(t, 2, extractions)
+
+	// Or it is forced.
+	config.SetForceExtract(true)
+	defer config.SetForceExtract(false)
+	reused, err = extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.False(t, reused)
+	assert.Equal(t, 3, extractions)
+}
+
+func TestExtractOnce_Overwrite(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+	config.SetSharedLock(SharedLockOff)
+	defer config.SetSharedLock(SharedLockAuto)
+
+	img, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "cache")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "old.cubin"), []byte("old"), 0644))
+	extract := func() error {
+		return os.WriteFile(filepath.Join(dir, "kernel.cubin"), []byte("k"), 0644)
+	}
+
+	// Declining stops the extraction before it writes anything.
+	var asked []int
+	OnOverwrite(func(d string, files int) error {
+		asked = append(asked, files)
+		return os.ErrExist
+	})
+	defer OnOverwrite(nil)
+	_, err = extractOnce(img, dir, extract)
+	assert.ErrorIs(t, err, os.ErrExist)
+	assert.Equal(t, []int{1}, asked)
+	assert.NoFileExists(t, filepath.Join(dir, "kernel.cubin"))
+
+	// Moving the files aside leaves dir empty for the extraction.
+	var backup string
+	OnOverwrite(func(d string, files int) error {
+		backup, err = MoveAside(d)
+		return err
+	})
+	_, err = extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(backup, "old.cubin"))
+	assert.NoFileExists(t, filepath.Join(dir, "old.cubin"))
+	assert.FileExists(t, filepath.Join(dir, "kernel.cubin"))
+
+	// Extracting the same image again does not ask.
+	OnOverwrite(func(d string, files int) error {
+		t.Fatal("asked before extracting the image dir already holds")
+		return nil
+	})
+	config.SetForceExtract(true)
+	defer config.SetForceExtract(false)
+	_, err = extractOnce(img, dir, extract)
+	assert.NoError(t, err)
+}
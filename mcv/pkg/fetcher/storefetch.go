@@ -0,0 +1,17 @@
+package fetcher
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	logging "github.com/sirupsen/logrus"
+)
+
+// storeFetcher loads images built by mcv from the local OCI layout store.
+type storeFetcher struct{}
+
+func (s *storeFetcher) FetchImg(imgName string) (v1.Image, error) {
+	logging.Debugf("Checking for image: %s in %s", imgName, imgstore.Path())
+	return imgstore.Load(imgName)
+}
+
+var _ Fetcher = (*storeFetcher)(nil)
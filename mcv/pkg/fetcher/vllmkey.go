@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// What extract does with a vLLM cache built for other vLLM, torch or
+// Triton versions than the local ones.
+const (
+	VLLMKeyRefuse    = "refuse"    // Fail the extraction
+	VLLMKeyNamespace = "namespace" // Extract into a subdirectory named after the versions
+	VLLMKeyIgnore    = "ignore"    // Extract as usual
+)
+
+// VLLMKeyPolicies returns the supported vLLM key mismatch policies.
+func VLLMKeyPolicies() []string {
+	return []string{VLLMKeyRefuse, VLLMKeyNamespace, VLLMKeyIgnore}
+}
+
+// ValidateVLLMKeyPolicy checks policy is supported.
+func ValidateVLLMKeyPolicy(policy string) error {
+	for _, p := range VLLMKeyPolicies() {
+		if policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported vLLM key mismatch policy %q (supported: %s)", policy, strings.Join(VLLMKeyPolicies(), ", "))
+}
+
+// checkVLLMKey compares the versions a vLLM cache was packaged with to the
+// installation of python, and returns the directory to extract it to. The
+// check is skipped when either side's versions are unknown.
+func checkVLLMKey(labels map[string]string, cacheDir, policy, python string) (string, error) {
+	if policy == VLLMKeyIgnore {
+		return cacheDir, nil
+	}
+	packaged, err := cache.ParseVLLMKeyInputs(labels)
+	if err != nil {
+		return "", err
+	}
+	if packaged == nil {
+		logging.Debug("Image records no vLLM versions, not checking the cache key")
+		return cacheDir, nil
+	}
+	local, err := cache.ProbeVLLMKeyInputs(python)
+	if err != nil {
+		logging.Infof("Not checking the vLLM cache key: %v", err)
+		return cacheDir, nil
+	}
+	diffs := packaged.Mismatches(local)
+	if len(diffs) == 0 {
+		return cacheDir, nil
+	}
+
+	msg := fmt.Sprintf("the vLLM cache was built with %s: vLLM would not use it", strings.Join(diffs, ", "))
+	if policy == VLLMKeyNamespace {
+		dir := filepath.Join(cacheDir, packaged.Namespace())
+		diag.Warnf(diag.VLLMKeyMismatch, "Extracting to %s, for use with VLLM_CACHE_ROOT=%s by a matching installation: %s", dir, dir, msg)
+		return dir, nil
+	}
+	return "", fmt.Errorf("%s (use --vllm-key-mismatch %s or %s to extract it anyway)", msg, VLLMKeyNamespace, VLLMKeyIgnore)
+}
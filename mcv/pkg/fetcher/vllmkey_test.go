@@ -0,0 +1,51 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePython writes an interpreter reporting the given versions.
+func fakePython(t *testing.T, versions string) string {
+	path := filepath.Join(t.TempDir(), "python3")
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho '"+versions+"'\n"), 0755))
+	return path
+}
+
+func TestCheckVLLMKey(t *testing.T) {
+	labels := map[string]string{cache.VLLMKeyInputsLabel: `{"vllm": "0.9.1", "torch": "2.7.0"}`}
+	same := fakePython(t, `{"vllm": "0.9.1", "torch": "2.7.0", "triton": "3.3.0"}`)
+	newer := fakePython(t, `{"vllm": "0.10.0", "torch": "2.7.1"}`)
+
+	dir, err := checkVLLMKey(labels, "/cache", VLLMKeyRefuse, same)
+	assert.NoError(t, err)
+	assert.Equal(t, "/cache", dir)
+
+	_, err = checkVLLMKey(labels, "/cache", VLLMKeyRefuse, newer)
+	assert.ErrorContains(t, err, "vllm 0.9.1 (installed 0.10.0), torch 2.7.0 (installed 2.7.1)")
+
+	dir, err = checkVLLMKey(labels, "/cache", VLLMKeyNamespace, newer)
+	assert.NoError(t, err)
+	assert.Equal(t, "/cache/vllm-0.9.1_torch-2.7.0", dir)
+
+	dir, err = checkVLLMKey(labels, "/cache", VLLMKeyIgnore, newer)
+	assert.NoError(t, err)
+	assert.Equal(t, "/cache", dir)
+
+	// Unknown versions on either side skip the check.
+	dir, err = checkVLLMKey(nil, "/cache", VLLMKeyRefuse, newer)
+	assert.NoError(t, err)
+	assert.Equal(t, "/cache", dir)
+	dir, err = checkVLLMKey(labels, "/cache", VLLMKeyRefuse, fakePython(t, `{"torch": "2.7.1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "/cache", dir)
+}
+
+func TestValidateVLLMKeyPolicy(t *testing.T) {
+	assert.NoError(t, ValidateVLLMKeyPolicy(VLLMKeyNamespace))
+	assert.Error(t, ValidateVLLMKeyPolicy("skip"))
+}
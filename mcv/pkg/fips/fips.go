@@ -0,0 +1,83 @@
+// Package fips checks that mcv runs with Go's FIPS 140-3 cryptographic
+// module and only accepts images whose content is addressed with approved
+// hash algorithms.
+package fips
+
+import (
+	"crypto/fips140"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrNotEnabled is returned by Verify when FIPS mode is required but Go's
+// FIPS 140-3 module is not enabled.
+var ErrNotEnabled = errors.New("FIPS mode is required but the Go FIPS 140-3 module is not enabled; " +
+	"run with GODEBUG=fips140=on or use an mcv built with make build-fips")
+
+// approvedDigests are the digest algorithms OCI allows that are FIPS
+// approved.
+var approvedDigests = map[string]bool{"sha256": true, "sha384": true, "sha512": true}
+
+// Enabled reports whether Go's FIPS 140-3 module is enabled.
+func Enabled() bool {
+	return fips140.Enabled()
+}
+
+// Verify checks at startup that FIPS mode can be honoured. mcv hashes
+// with SHA-256 only, and in FIPS mode the Go module also restricts TLS to
+// approved algorithms, so enabling the module is all that is needed.
+func Verify() error {
+	if !Enabled() {
+		return ErrNotEnabled
+	}
+	return nil
+}
+
+// CheckDigest returns an error if d uses an algorithm that is not FIPS
+// approved.
+func CheckDigest(d v1.Hash) error {
+	if !approvedDigests[d.Algorithm] {
+		return fmt.Errorf("digest %s uses %s, which is not FIPS approved", d, d.Algorithm)
+	}
+	return nil
+}
+
+// CheckImage returns an error if the digest of img, its config or any of
+// its layers uses an algorithm that is not FIPS approved.
+func CheckImage(img v1.Image) error {
+	d, err := img.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to get image digest: %w", err)
+	}
+	if err := CheckDigest(d); err != nil {
+		return err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("failed to get image manifest: %w", err)
+	}
+	if err := CheckDigest(m.Config.Digest); err != nil {
+		return fmt.Errorf("image config: %w", err)
+	}
+	for _, l := range m.Layers {
+		if err := CheckDigest(l.Digest); err != nil {
+			return fmt.Errorf("layer: %w", err)
+		}
+	}
+	return nil
+}
+
+// Report describes the FIPS state for --version output.
+func Report(required bool) string {
+	module := "disabled"
+	if Enabled() {
+		module = "enabled"
+	}
+	mode := "off"
+	if required {
+		mode = "required"
+	}
+	return fmt.Sprintf("FIPS mode: %s (Go FIPS 140-3 module %s)", mode, module)
+}
@@ -0,0 +1,30 @@
+package fips
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDigest(t *testing.T) {
+	assert.NoError(t, CheckDigest(v1.Hash{Algorithm: "sha256", Hex: "ab"}))
+	assert.NoError(t, CheckDigest(v1.Hash{Algorithm: "sha512", Hex: "ab"}))
+	assert.ErrorContains(t, CheckDigest(v1.Hash{Algorithm: "blake3", Hex: "ab"}), "not FIPS approved")
+}
+
+func TestCheckImage(t *testing.T) {
+	img, err := random.Image(64, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, CheckImage(img))
+}
+
+func TestVerify(t *testing.T) {
+	if Enabled() {
+		assert.NoError(t, Verify())
+	} else {
+		assert.ErrorIs(t, Verify(), ErrNotEnabled)
+	}
+	assert.Contains(t, Report(true), "FIPS mode: required")
+}
@@ -0,0 +1,244 @@
+// Package fleet collects GPU and compatibility reports from several hosts
+// and flags differences between them that would make a cache image roll
+// out to some hosts but not others.
+package fleet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	"gopkg.in/yaml.v3"
+)
+
+// Host is a host to check, reached over SSH.
+type Host struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`           // Host name or IP, defaults to Name
+	User    string `yaml:"user,omitempty"`    // SSH user, defaults to the SSH client's
+	Port    int    `yaml:"port,omitempty"`    // SSH port, defaults to the SSH client's
+	MCVPath string `yaml:"mcvPath,omitempty"` // mcv on the host, defaults to "mcv"
+}
+
+// HostsFile lists the hosts of a fleet.
+type HostsFile struct {
+	Hosts []Host `yaml:"hosts"`
+}
+
+// LoadHosts reads and validates a hosts file.
+func LoadHosts(path string) ([]Host, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	var f HostsFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid hosts file %s: %w", path, err)
+	}
+	if len(f.Hosts) == 0 {
+		return nil, errors.New("hosts file lists no hosts")
+	}
+	seen := map[string]bool{}
+	for i := range f.Hosts {
+		h := &f.Hosts[i]
+		if h.Name == "" {
+			return nil, fmt.Errorf("host %d has no name", i+1)
+		}
+		if seen[h.Name] {
+			return nil, fmt.Errorf("duplicate host %q", h.Name)
+		}
+		seen[h.Name] = true
+		if h.Address == "" {
+			h.Address = h.Name
+		}
+		if h.MCVPath == "" {
+			h.MCVPath = "mcv"
+		}
+	}
+	return f.Hosts, nil
+}
+
+// ParseHost returns the host an ssh destination, [USER@]HOST, names, with
+// mcvPath as its mcv.
+func ParseHost(dest, mcvPath string) (Host, error) {
+	h := Host{MCVPath: mcvPath}
+	if i := strings.LastIndex(dest, "@"); i >= 0 {
+		h.User, dest = dest[:i], dest[i+1:]
+		if h.User == "" {
+			return Host{}, errors.New("empty user before @")
+		}
+	}
+	if dest == "" || strings.HasPrefix(dest, "-") {
+		return Host{}, fmt.Errorf("invalid host %q", dest)
+	}
+	h.Name, h.Address = dest, dest
+	if h.MCVPath == "" {
+		h.MCVPath = "mcv"
+	}
+	return h, nil
+}
+
+// HostReport is what mcv host-report prints on each host.
+type HostReport struct {
+	Hostname string             `json:"hostname"`
+	Host     *hostinfo.Info     `json:"host,omitempty"`
+	GPUs     []devices.GPUGroup `json:"gpus"`
+	// Targets are the distinct backend/arch/warp size combinations of the
+	// GPUs, e.g. "cuda:90:32"; kernels only run on matching targets.
+	Targets []string `json:"targets"`
+	// Devices are the GPUs as the preflight check sees them, so that
+	// mcv check-compat --ssh can check an image against them centrally.
+	Devices []devices.TritonGPUInfo `json:"devices,omitempty"`
+	// HostChecks are the results of the baremetal host probes, with
+	// mcv host-report --baremetal.
+	HostChecks []preflightcheck.CheckResult `json:"hostChecks,omitempty"`
+
+	Image      string `json:"image,omitempty"`
+	Compatible bool   `json:"compatible"`
+	Matched    []int  `json:"matched,omitempty"`
+	Unmatched  []int  `json:"unmatched,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// LoadHostReport reads a report mcv host-report printed, from stdin if
+// path is "-".
+func LoadHostReport(path string) (*HostReport, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host report: %w", err)
+	}
+	var r HostReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid host report %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Targets returns the sorted distinct targets of devInfo.
+func Targets(devInfo []devices.TritonGPUInfo) []string {
+	seen := map[string]bool{}
+	var targets []string
+	for _, g := range devInfo {
+		t := fmt.Sprintf("%s:%s:%d", g.Backend, g.Arch, g.WarpSize)
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Result is the outcome of checking one host.
+type Result struct {
+	Host   Host
+	Report *HostReport // nil if the host could not be checked
+	Err    error
+}
+
+// Analyze returns the problems found across results: hosts that could not
+// be checked or cannot use the image, and properties that differ between
+// hosts.
+func Analyze(results []Result) []string {
+	var issues []string
+	var checked []Result
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			issues = append(issues, fmt.Sprintf("%s: not checked: %v", r.Host.Name, r.Err))
+		case r.Report.Image != "" && !r.Report.Compatible:
+			reason := "no compatible GPU"
+			if r.Report.Error != "" {
+				reason = r.Report.Error
+			}
+			issues = append(issues, fmt.Sprintf("%s: incompatible with %s: %s", r.Host.Name, r.Report.Image, reason))
+			checked = append(checked, r)
+		case len(r.Report.Unmatched) > 0:
+			issues = append(issues, fmt.Sprintf("%s: GPUs %v cannot use %s", r.Host.Name, r.Report.Unmatched, r.Report.Image))
+			checked = append(checked, r)
+		default:
+			checked = append(checked, r)
+		}
+	}
+
+	properties := []struct {
+		name   string
+		values func(*HostReport) []string
+	}{
+		{"GPU types", func(h *HostReport) []string {
+			return groupValues(h, func(g devices.GPUGroup) string { return g.GPUType })
+		}},
+		{"driver versions", func(h *HostReport) []string {
+			return groupValues(h, func(g devices.GPUGroup) string { return g.DriverVersion })
+		}},
+		{"GPU targets", func(h *HostReport) []string { return []string{strings.Join(h.Targets, ",")} }},
+		{"CPU architectures", func(h *HostReport) []string {
+			if h.Host == nil || h.Host.CPUArch == "" {
+				return nil
+			}
+			return []string{h.Host.CPUArch}
+		}},
+	}
+	for _, p := range properties {
+		if issue := mixed(p.name, checked, p.values); issue != "" {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func groupValues(h *HostReport, value func(devices.GPUGroup) string) []string {
+	seen := map[string]bool{}
+	var values []string
+	for _, g := range h.GPUs {
+		if v := value(g); !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return []string{strings.Join(values, ",")}
+}
+
+// mixed describes which hosts have which value of a property, or returns
+// "" if all hosts agree.
+func mixed(name string, results []Result, values func(*HostReport) []string) string {
+	hosts := map[string][]string{}
+	for _, r := range results {
+		for _, v := range values(r.Report) {
+			if v == "" {
+				v = "none"
+			}
+			hosts[v] = append(hosts[v], r.Host.Name)
+		}
+	}
+	if len(hosts) < 2 {
+		return ""
+	}
+	keys := make([]string, 0, len(hosts))
+	for v := range hosts {
+		keys = append(keys, v)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, v := range keys {
+		parts = append(parts, fmt.Sprintf("%s (%s)", v, strings.Join(hosts[v], ", ")))
+	}
+	return fmt.Sprintf("mixed %s: %s", name, strings.Join(parts, "; "))
+}
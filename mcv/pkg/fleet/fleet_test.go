@@ -0,0 +1,121 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`hosts:
+  - name: gpu-1
+  - name: gpu-2
+    address: 10.0.0.2
+    user: core
+    port: 2222
+    mcvPath: /usr/local/bin/mcv
+`), 0644))
+	hosts, err := LoadHosts(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Host{
+		{Name: "gpu-1", Address: "gpu-1", MCVPath: "mcv"},
+		{Name: "gpu-2", Address: "10.0.0.2", User: "core", Port: 2222, MCVPath: "/usr/local/bin/mcv"},
+	}, hosts)
+
+	assert.NoError(t, os.WriteFile(path, []byte("hosts:\n  - name: a\n  - name: a\n"), 0644))
+	_, err = LoadHosts(path)
+	assert.ErrorContains(t, err, "duplicate host")
+
+	assert.NoError(t, os.WriteFile(path, []byte("hosts:\n  - name: a\n    addr: x\n"), 0644))
+	_, err = LoadHosts(path)
+	assert.Error(t, err)
+}
+
+func TestTargets(t *testing.T) {
+	targets := Targets([]devices.TritonGPUInfo{
+		{Backend: "cuda", Arch: "90", WarpSize: 32, ID: 0},
+		{Backend: "cuda", Arch: "90", WarpSize: 32, ID: 1},
+		{Backend: "cuda", Arch: "80", WarpSize: 32, ID: 2},
+	})
+	assert.Equal(t, []string{"cuda:80:32", "cuda:90:32"}, targets)
+}
+
+func h100(driver string) *HostReport {
+	return &HostReport{
+		GPUs:       []devices.GPUGroup{{GPUType: "nvidia-h100", DriverVersion: driver, IDs: []int{0, 1}}},
+		Targets:    []string{"cuda:90:32"},
+		Image:      "quay.io/mcv/cache:1",
+		Compatible: true,
+		Matched:    []int{0, 1},
+	}
+}
+
+func TestCheckAndAnalyze(t *testing.T) {
+	hosts := []Host{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	run := func(_ context.Context, h Host, image string) (*HostReport, error) {
+		switch h.Name {
+		case "b":
+			return h100("550.54"), nil
+		case "c":
+			r := h100("535.43")
+			r.Compatible, r.Matched, r.Error = false, nil, "preflight check failed"
+			return r, nil
+		case "d":
+			return nil, errors.New("connection refused")
+		}
+		return h100("535.43"), nil
+	}
+	results := Check(context.Background(), hosts, "quay.io/mcv/cache:1", 2, run)
+	for i, r := range results {
+		assert.Equal(t, hosts[i].Name, r.Host.Name)
+	}
+
+	assert.Equal(t, []string{
+		"c: incompatible with quay.io/mcv/cache:1: preflight check failed",
+		"d: not checked: connection refused",
+		"mixed driver versions: 535.43 (a, c); 550.54 (b)",
+	}, Analyze(results))
+}
+
+func TestAnalyzeHomogeneous(t *testing.T) {
+	results := []Result{
+		{Host: Host{Name: "a"}, Report: h100("535.43")},
+		{Host: Host{Name: "b"}, Report: h100("535.43")},
+	}
+	assert.Empty(t, Analyze(results))
+
+	results[1].Report.Targets = []string{"cuda:90:32", "cuda:80:32"}
+	assert.Equal(t, []string{"mixed GPU targets: cuda:90:32 (a); cuda:90:32,cuda:80:32 (b)"}, Analyze(results))
+}
+
+func TestAnalyzeMixedCPUArchs(t *testing.T) {
+	results := []Result{
+		{Host: Host{Name: "a"}, Report: h100("535.43")},
+		{Host: Host{Name: "b"}, Report: h100("535.43")},
+	}
+	results[0].Report.Host = &hostinfo.Info{CPUArch: "amd64"}
+	results[1].Report.Host = &hostinfo.Info{CPUArch: "arm64"}
+	assert.Equal(t, []string{"mixed CPU architectures: amd64 (a); arm64 (b)"}, Analyze(results))
+}
+
+func TestParseHost(t *testing.T) {
+	h, err := ParseHost("core@gpu-1.example.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, Host{Name: "gpu-1.example.com", Address: "gpu-1.example.com", User: "core", MCVPath: "mcv"}, h)
+
+	h, err = ParseHost("10.0.0.2", "/usr/local/bin/mcv")
+	assert.NoError(t, err)
+	assert.Equal(t, Host{Name: "10.0.0.2", Address: "10.0.0.2", MCVPath: "/usr/local/bin/mcv"}, h)
+
+	for _, dest := range []string{"", "core@", "@gpu-1", "-oProxyCommand=x"} {
+		_, err = ParseHost(dest, "")
+		assert.Error(t, err, dest)
+	}
+}
@@ -0,0 +1,240 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// RolloutOptions describe how to roll a cache image out to a fleet.
+type RolloutOptions struct {
+	Image    string
+	Previous string // Image to roll back to, none if empty
+	CacheDir string
+	// ExtractArgs are extra mcv options to extract with on each host,
+	// e.g. --kernels compatible.
+	ExtractArgs []string
+
+	Canary      int // Hosts in the first wave, which must all succeed
+	WaveSize    int // Hosts in each later wave
+	MaxFailures int // Failed hosts tolerated after the canary wave
+
+	// HealthCmd is run on each host after extracting, through its shell,
+	// until it succeeds or HealthTimeout passes. An empty HealthCmd
+	// counts a host as healthy once extracted.
+	HealthCmd      string
+	HealthTimeout  time.Duration
+	HealthInterval time.Duration
+	Pause          time.Duration // Wait between waves
+}
+
+// Status is where a host is in a rollout.
+type Status string
+
+const (
+	StatusPending        Status = "pending"
+	StatusUpdated        Status = "updated"
+	StatusFailed         Status = "failed"
+	StatusRolledBack     Status = "rolled back"
+	StatusRollbackFailed Status = "rollback failed"
+)
+
+// HostRollout is the outcome of a rollout on one host.
+type HostRollout struct {
+	Host   Host
+	Wave   int // 0 is the canary wave
+	Status Status
+	Detail string
+}
+
+// RolloutResult is the outcome of a rollout.
+type RolloutResult struct {
+	Hosts   []HostRollout
+	Aborted string // Why the rollout stopped, empty if it completed
+}
+
+// Waves splits hosts into a canary wave of canary hosts followed by waves
+// of waveSize hosts, in the order of hosts.
+func Waves(hosts []Host, canary, waveSize int) [][]Host {
+	if canary < 1 {
+		canary = 1
+	}
+	if waveSize < 1 {
+		waveSize = len(hosts)
+	}
+	var waves [][]Host
+	for start, size := 0, canary; start < len(hosts); start, size = start+size, waveSize {
+		waves = append(waves, hosts[start:min(start+size, len(hosts))])
+	}
+	return waves
+}
+
+// Rollout extracts opts.Image on the hosts wave by wave, checking each
+// host's health before moving on. When the canary wave has a failure, or
+// more than opts.MaxFailures hosts fail overall, it stops and extracts
+// opts.Previous again on every host it changed.
+func Rollout(ctx context.Context, hosts []Host, opts RolloutOptions, run Exec) *RolloutResult {
+	waves := Waves(hosts, opts.Canary, opts.WaveSize)
+	res := &RolloutResult{}
+	byName := map[string]*HostRollout{}
+	for w, wave := range waves {
+		for _, h := range wave {
+			res.Hosts = append(res.Hosts, HostRollout{Host: h, Wave: w, Status: StatusPending})
+		}
+	}
+	for i := range res.Hosts {
+		byName[res.Hosts[i].Host.Name] = &res.Hosts[i]
+	}
+
+	failures := 0
+	for w, wave := range waves {
+		if w > 0 && opts.Pause > 0 {
+			logging.Infof("Waiting %s before wave %d", opts.Pause, w)
+			select {
+			case <-time.After(opts.Pause):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			res.Aborted = "interrupted"
+			break
+		}
+		name := fmt.Sprintf("wave %d", w)
+		if w == 0 {
+			name = "canary wave"
+		}
+		logging.Infof("Rolling %s out to the %s: %d host(s)", opts.Image, name, len(wave))
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(wave))
+		for i, h := range wave {
+			wg.Add(1)
+			go func(i int, h Host) {
+				defer wg.Done()
+				errs[i] = updateHost(ctx, h, opts, run)
+			}(i, h)
+		}
+		wg.Wait()
+
+		waveFailures := 0
+		for i, h := range wave {
+			hr := byName[h.Name]
+			if errs[i] != nil {
+				hr.Status, hr.Detail = StatusFailed, errs[i].Error()
+				waveFailures++
+				logging.Errorf("%s: %v", h.Name, errs[i])
+				continue
+			}
+			hr.Status = StatusUpdated
+		}
+		failures += waveFailures
+		switch {
+		case w == 0 && waveFailures > 0:
+			res.Aborted = fmt.Sprintf("%d of %d canary host(s) failed", waveFailures, len(wave))
+		case failures > opts.MaxFailures:
+			res.Aborted = fmt.Sprintf("%d host(s) failed, more than the %d allowed", failures, opts.MaxFailures)
+		}
+		if res.Aborted != "" {
+			break
+		}
+	}
+
+	if res.Aborted != "" {
+		rollback(context.WithoutCancel(ctx), res, opts, run)
+	}
+	return res
+}
+
+// updateHost extracts the image on h and waits for it to be healthy.
+func updateHost(ctx context.Context, h Host, opts RolloutOptions, run Exec) error {
+	if err := extract(ctx, h, opts.Image, opts, run); err != nil {
+		return fmt.Errorf("extract failed: %w", err)
+	}
+	if opts.HealthCmd == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.HealthTimeout)
+	defer cancel()
+	interval := opts.HealthInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	for {
+		_, err := run(ctx, h, opts.HealthCmd)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not healthy after %s: %w", opts.HealthTimeout, err)
+		case <-time.After(interval):
+		}
+	}
+}
+
+func extract(ctx context.Context, h Host, image string, opts RolloutOptions, run Exec) error {
+	args := []string{h.MCVPath, "--extract", "--force", "-i", image, "-d", opts.CacheDir}
+	args = append(args, opts.ExtractArgs...)
+	for i := range args {
+		args[i] = shellQuote(args[i])
+	}
+	_, err := run(ctx, h, args...)
+	return err
+}
+
+// rollback extracts the previous image again on the hosts the rollout
+// changed, including those that failed part way.
+func rollback(ctx context.Context, res *RolloutResult, opts RolloutOptions, run Exec) {
+	if opts.Previous == "" {
+		diag.Warnf(diag.RolloutAborted, "Rollout aborted: %s; no previous image to roll back to", res.Aborted)
+		return
+	}
+	diag.Warnf(diag.RolloutAborted, "Rollout aborted: %s; rolling back to %s", res.Aborted, opts.Previous)
+	var wg sync.WaitGroup
+	for i := range res.Hosts {
+		hr := &res.Hosts[i]
+		if hr.Status != StatusUpdated && hr.Status != StatusFailed {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := extract(ctx, hr.Host, opts.Previous, opts, run); err != nil {
+				hr.Status, hr.Detail = StatusRollbackFailed, joinDetail(hr.Detail, "rollback: "+err.Error())
+				logging.Errorf("%s: rollback failed: %v", hr.Host.Name, err)
+				return
+			}
+			hr.Status = StatusRolledBack
+		}()
+	}
+	wg.Wait()
+}
+
+// ValidateRollout checks opts before a rollout starts.
+func ValidateRollout(opts RolloutOptions) error {
+	switch {
+	case opts.Image == "":
+		return errors.New("no image to roll out")
+	case opts.CacheDir == "":
+		return errors.New("no cache directory to extract to")
+	case opts.Canary < 1:
+		return errors.New("the canary wave needs at least one host")
+	case opts.WaveSize < 0 || opts.MaxFailures < 0:
+		return errors.New("wave size and max failures cannot be negative")
+	case opts.HealthCmd != "" && opts.HealthTimeout <= 0:
+		return errors.New("a health command needs a timeout")
+	}
+	return nil
+}
+
+func joinDetail(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "; " + b
+}
@@ -0,0 +1,88 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hostList(names ...string) []Host {
+	var hosts []Host
+	for _, n := range names {
+		hosts = append(hosts, Host{Name: n, Address: n, MCVPath: "mcv"})
+	}
+	return hosts
+}
+
+// fakeExec records the commands run on each host and fails those for
+// which fail returns true.
+type fakeExec struct {
+	mu   sync.Mutex
+	runs map[string][]string
+	fail func(host, command string) bool
+}
+
+func (f *fakeExec) run(_ context.Context, h Host, args ...string) ([]byte, error) {
+	command := strings.Join(args, " ")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.runs == nil {
+		f.runs = map[string][]string{}
+	}
+	f.runs[h.Name] = append(f.runs[h.Name], command)
+	if f.fail != nil && f.fail(h.Name, command) {
+		return nil, errors.New("exit status 1")
+	}
+	return nil, nil
+}
+
+func statuses(res *RolloutResult) map[string]Status {
+	s := map[string]Status{}
+	for _, h := range res.Hosts {
+		s[h.Host.Name] = h.Status
+	}
+	return s
+}
+
+func TestWaves(t *testing.T) {
+	hosts := hostList("a", "b", "c", "d", "e")
+	waves := Waves(hosts, 1, 2)
+	assert.Equal(t, [][]Host{hosts[:1], hosts[1:3], hosts[3:]}, waves)
+	assert.Equal(t, [][]Host{hosts[:2], hosts[2:]}, Waves(hosts, 2, 0))
+}
+
+func TestRollout(t *testing.T) {
+	opts := RolloutOptions{Image: "quay.io/mcv/cache:2", Previous: "quay.io/mcv/cache:1", CacheDir: "/var/cache/triton",
+		Canary: 1, WaveSize: 2, HealthCmd: "curl -sf localhost:8000/health", HealthTimeout: time.Second}
+	assert.NoError(t, ValidateRollout(opts))
+
+	f := &fakeExec{}
+	res := Rollout(context.Background(), hostList("a", "b", "c"), opts, f.run)
+	assert.Empty(t, res.Aborted)
+	assert.Equal(t, map[string]Status{"a": StatusUpdated, "b": StatusUpdated, "c": StatusUpdated}, statuses(res))
+	assert.Equal(t, []string{"mcv --extract --force -i quay.io/mcv/cache:2 -d /var/cache/triton", "curl -sf localhost:8000/health"}, f.runs["a"])
+
+	// A canary that never becomes healthy stops the rollout there.
+	f = &fakeExec{fail: func(host, command string) bool { return host == "a" && strings.HasPrefix(command, "curl") }}
+	opts.HealthInterval = 10 * time.Millisecond
+	opts.HealthTimeout = 50 * time.Millisecond
+	res = Rollout(context.Background(), hostList("a", "b", "c"), opts, f.run)
+	assert.Equal(t, "1 of 1 canary host(s) failed", res.Aborted)
+	assert.Equal(t, map[string]Status{"a": StatusRolledBack, "b": StatusPending, "c": StatusPending}, statuses(res))
+	assert.Equal(t, "mcv --extract --force -i quay.io/mcv/cache:1 -d /var/cache/triton", f.runs["a"][len(f.runs["a"])-1])
+	assert.Empty(t, f.runs["b"])
+}
+
+func TestRolloutMaxFailures(t *testing.T) {
+	opts := RolloutOptions{Image: "cache:2", CacheDir: "/cache", Canary: 1, WaveSize: 1, MaxFailures: 1}
+	f := &fakeExec{fail: func(host, command string) bool { return host != "a" }}
+	res := Rollout(context.Background(), hostList("a", "b", "c", "d"), opts, f.run)
+	assert.Equal(t, "2 host(s) failed, more than the 1 allowed", res.Aborted)
+	// Without a previous image the hosts are left as they are.
+	assert.Equal(t, map[string]Status{"a": StatusUpdated, "b": StatusFailed, "c": StatusFailed, "d": StatusPending}, statuses(res))
+}
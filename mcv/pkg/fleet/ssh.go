@@ -0,0 +1,120 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Runner collects the report of one host.
+type Runner func(ctx context.Context, h Host, image string) (*HostReport, error)
+
+// Exec runs a command on a host, through its shell, and returns what it
+// printed on stdout. The remote shell joins args with spaces and parses
+// them again, so arguments must be quoted with shellQuote.
+type Exec func(ctx context.Context, h Host, args ...string) ([]byte, error)
+
+// SSHExec runs commands with the ssh client, in batch mode so hosts that
+// would prompt for a password fail instead. A failed command's error ends
+// with the last line it printed on stderr.
+func SSHExec(extraArgs ...string) Exec {
+	return func(ctx context.Context, h Host, command ...string) ([]byte, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ssh", sshArgs(h, extraArgs, command)...)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if i := strings.LastIndex(msg, "\n"); i >= 0 {
+				msg = msg[i+1:]
+			}
+			if msg == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// sshArgs returns the arguments of ssh running command on h. The target
+// follows "--", so an address starting with "-" is not taken for an
+// option.
+func sshArgs(h Host, extraArgs, command []string) []string {
+	args := append([]string{"-o", "BatchMode=yes"}, extraArgs...)
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	target := h.Address
+	if h.User != "" {
+		target = h.User + "@" + target
+	}
+	args = append(args, "--", target)
+	return append(args, command...)
+}
+
+// SSHRunner runs mcv host-report on each host with SSHExec.
+func SSHRunner(extraArgs ...string) Runner {
+	run := SSHExec(extraArgs...)
+	return func(ctx context.Context, h Host, image string) (*HostReport, error) {
+		out, err := run(ctx, h, hostReportCommand(h, image)...)
+		if err != nil {
+			return nil, err
+		}
+		var r HostReport
+		if err := json.Unmarshal(out, &r); err != nil {
+			return nil, fmt.Errorf("invalid host report: %w", err)
+		}
+		return &r, nil
+	}
+}
+
+// hostReportCommand returns the command running mcv host-report on h,
+// quoted for its shell.
+func hostReportCommand(h Host, image string) []string {
+	args := []string{h.MCVPath, "host-report"}
+	if image != "" {
+		args = append(args, "-i", image)
+	}
+	for i := range args {
+		args[i] = shellQuote(args[i])
+	}
+	return args
+}
+
+// shellQuote quotes s for a POSIX shell unless it needs no quoting.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Check collects the report of every host with run, at most parallel at a
+// time, and returns the results in the order of hosts.
+func Check(ctx context.Context, hosts []Host, image string, parallel int, run Runner) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]Result, len(hosts))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h Host) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			r, err := run(ctx, h, image)
+			results[i] = Result{Host: h, Report: r, Err: err}
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
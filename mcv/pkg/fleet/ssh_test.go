@@ -0,0 +1,27 @@
+package fleet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "quay.io/mcv/cache:1", shellQuote("quay.io/mcv/cache:1"))
+	assert.Equal(t, `'/mnt/my cache'`, shellQuote("/mnt/my cache"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, "''", shellQuote(""))
+}
+
+func TestSSHArgs(t *testing.T) {
+	h := Host{Address: "-oProxyCommand=x", User: "core", Port: 2222}
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-i", "key", "-p", "2222", "--", "core@-oProxyCommand=x", "mcv", "host-report"},
+		sshArgs(h, []string{"-i", "key"}, []string{"mcv", "host-report"}))
+}
+
+func TestHostReportCommand(t *testing.T) {
+	h := Host{MCVPath: "/opt/my tools/mcv"}
+	assert.Equal(t, []string{"'/opt/my tools/mcv'", "host-report", "-i", "'quay.io/mcv/cache:1;reboot'"},
+		hostReportCommand(h, "quay.io/mcv/cache:1;reboot"))
+	assert.Equal(t, []string{"'/opt/my tools/mcv'", "host-report"}, hostReportCommand(h, ""))
+}
@@ -0,0 +1,31 @@
+// Package hostfs locates the host's /sys, /proc, /dev, /etc and home
+// directories. They are at / unless mcv runs in a container with the
+// host's root filesystem mounted elsewhere, such as /host.
+package hostfs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+var root = "/"
+
+// Root returns where the host's root filesystem is mounted.
+func Root() string {
+	return root
+}
+
+// SetRoot sets where the host's root filesystem is mounted, for mcv and
+// for the hardware detection library it uses.
+func SetRoot(dir string) error {
+	root = dir
+	if dir == "/" {
+		return os.Unsetenv("GHW_CHROOT")
+	}
+	return os.Setenv("GHW_CHROOT", dir)
+}
+
+// Path returns the path of the host's file p, such as /sys/class/drm.
+func Path(p string) string {
+	return filepath.Join(root, p)
+}
@@ -0,0 +1,22 @@
+package hostfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRoot(t *testing.T) {
+	assert.Equal(t, "/sys/class/drm", Path("/sys/class/drm"))
+
+	assert.NoError(t, SetRoot("/host"))
+	assert.Equal(t, "/host", Root())
+	assert.Equal(t, "/host/sys/class/drm", Path("/sys/class/drm"))
+	assert.Equal(t, "/host", os.Getenv("GHW_CHROOT"))
+
+	assert.NoError(t, SetRoot("/"))
+	assert.Equal(t, "/proc/cmdline", Path("/proc/cmdline"))
+	_, set := os.LookupEnv("GHW_CHROOT")
+	assert.False(t, set)
+}
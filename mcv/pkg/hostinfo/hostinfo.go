@@ -0,0 +1,167 @@
+// Package hostinfo collects host facts that GPU driver and toolkit
+// compatibility depend on: OS release, kernel version, CPU architecture,
+// cgroup version, container runtime and hypervisor.
+package hostinfo
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+const unknown = "unknown"
+
+// Info describes the host MCV is running on.
+type Info struct {
+	OSRelease        string `json:"osRelease"`
+	KernelVersion    string `json:"kernelVersion"`
+	CPUArch          string `json:"cpuArch"` // as in GOARCH, e.g. "arm64"
+	CgroupVersion    string `json:"cgroupVersion"`
+	ContainerRuntime string `json:"containerRuntime"` // "none" when not containerized
+	Hypervisor       string `json:"hypervisor"`       // "none" on bare metal
+}
+
+// Get returns facts about the current host. Detection is best effort;
+// fields that cannot be determined are reported as "unknown".
+func Get() *Info {
+	return detect(hostfs.Root())
+}
+
+func detect(root string) *Info {
+	return &Info{
+		OSRelease:        osRelease(root),
+		KernelVersion:    kernelVersion(root),
+		CPUArch:          runtime.GOARCH,
+		CgroupVersion:    cgroupVersion(root),
+		ContainerRuntime: containerRuntime(root),
+		Hypervisor:       hypervisor(root),
+	}
+}
+
+func readTrimmed(root, path string) string {
+	data, err := os.ReadFile(filepath.Join(root, path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func exists(root, path string) bool {
+	_, err := os.Stat(filepath.Join(root, path))
+	return err == nil
+}
+
+func osRelease(root string) string {
+	f, err := os.Open(filepath.Join(root, "etc/os-release"))
+	if err != nil {
+		return unknown
+	}
+	defer f.Close()
+
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			fields[k] = strings.Trim(v, `"'`)
+		}
+	}
+	for _, k := range []string{"PRETTY_NAME", "NAME"} {
+		if fields[k] != "" {
+			return fields[k]
+		}
+	}
+	return unknown
+}
+
+func kernelVersion(root string) string {
+	if v := readTrimmed(root, "proc/sys/kernel/osrelease"); v != "" {
+		return v
+	}
+	return unknown
+}
+
+func cgroupVersion(root string) string {
+	switch {
+	case exists(root, "sys/fs/cgroup/cgroup.controllers"):
+		return "v2"
+	case exists(root, "sys/fs/cgroup"):
+		return "v1"
+	default:
+		return unknown
+	}
+}
+
+func containerRuntime(root string) string {
+	switch {
+	case exists(root, "run/.containerenv"):
+		return "podman"
+	case exists(root, ".dockerenv"):
+		return "docker"
+	}
+
+	// systemd and most OCI runtimes export $container to PID 1.
+	if env, err := os.ReadFile(filepath.Join(root, "proc/1/environ")); err == nil {
+		for _, kv := range strings.Split(string(env), "\x00") {
+			if v, ok := strings.CutPrefix(kv, "container="); ok && v != "" {
+				return v
+			}
+		}
+	}
+
+	cgroup := readTrimmed(root, "proc/1/cgroup")
+	for _, hint := range []struct{ match, runtime string }{
+		{"kubepods", "kubernetes"},
+		{"crio", "cri-o"},
+		{"containerd", "containerd"},
+		{"docker", "docker"},
+		{"libpod", "podman"},
+		{"lxc", "lxc"},
+	} {
+		if strings.Contains(cgroup, hint.match) {
+			return hint.runtime
+		}
+	}
+	return "none"
+}
+
+func hypervisor(root string) string {
+	if t := readTrimmed(root, "sys/hypervisor/type"); t != "" {
+		return t
+	}
+
+	dmi := strings.ToLower(readTrimmed(root, "sys/class/dmi/id/sys_vendor") + " " +
+		readTrimmed(root, "sys/class/dmi/id/product_name"))
+	for _, hint := range []struct{ match, name string }{
+		{"qemu", "kvm"},
+		{"kvm", "kvm"},
+		{"vmware", "vmware"},
+		{"virtualbox", "virtualbox"},
+		{"microsoft corporation virtual machine", "hyperv"},
+		{"amazon ec2", "aws-nitro"},
+		{"google compute engine", "gce"},
+		{"xen", "xen"},
+	} {
+		if strings.Contains(dmi, hint.match) {
+			return hint.name
+		}
+	}
+
+	// The CPU hypervisor flag is set for guests we could not identify.
+	if cpuinfo := readTrimmed(root, "proc/cpuinfo"); cpuinfo != "" {
+		for _, line := range strings.Split(cpuinfo, "\n") {
+			if strings.HasPrefix(line, "flags") {
+				if strings.Contains(line, " hypervisor") {
+					return unknown
+				}
+				break
+			}
+		}
+		return "none"
+	}
+	return unknown
+}
@@ -0,0 +1,55 @@
+package hostinfo
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"etc/os-release":                   "NAME=\"Fedora Linux\"\nPRETTY_NAME=\"Fedora Linux 42\"\n",
+		"proc/sys/kernel/osrelease":        "6.14.0-63.fc42.x86_64\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu memory",
+		"run/.containerenv":                "",
+		"sys/class/dmi/id/sys_vendor":      "QEMU\n",
+		"sys/class/dmi/id/product_name":    "Standard PC (Q35 + ICH9, 2009)\n",
+	})
+
+	info := detect(root)
+	assert.Equal(t, "Fedora Linux 42", info.OSRelease)
+	assert.Equal(t, "6.14.0-63.fc42.x86_64", info.KernelVersion)
+	assert.Equal(t, runtime.GOARCH, info.CPUArch)
+	assert.Equal(t, "v2", info.CgroupVersion)
+	assert.Equal(t, "podman", info.ContainerRuntime)
+	assert.Equal(t, "kvm", info.Hypervisor)
+}
+
+func TestDetect_BareMetal(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sys/fs/cgroup/memory/memory.limit_in_bytes": "0",
+		"proc/1/cgroup":               "0::/init.scope\n",
+		"proc/cpuinfo":                "processor\t: 0\nflags\t\t: fpu vme sse\n",
+		"sys/class/dmi/id/sys_vendor": "Dell Inc.\n",
+	})
+
+	info := detect(root)
+	assert.Equal(t, unknown, info.OSRelease)
+	assert.Equal(t, "v1", info.CgroupVersion)
+	assert.Equal(t, "none", info.ContainerRuntime)
+	assert.Equal(t, "none", info.Hypervisor)
+}
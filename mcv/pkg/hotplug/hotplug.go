@@ -0,0 +1,106 @@
+// Package hotplug watches the kernel's device events for GPUs being added
+// or removed, e.g. during maintenance or after a driver reload, so
+// long-running mcv processes can detect the GPUs again instead of
+// reporting the ones they saw at startup.
+package hotplug
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions of the device events Watch reports.
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+	ActionBind   = "bind"
+	ActionUnbind = "unbind"
+)
+
+// Event is a kernel device event (uevent) about a GPU.
+type Event struct {
+	Action    string
+	Subsystem string
+	DevPath   string // e.g. /devices/pci0000:00/0000:00:01.0/0000:01:00.0
+	PCISlot   string // e.g. 0000:01:00.0, empty for DRM and accel devices
+}
+
+// PCI base classes of GPUs: display controllers, and processing
+// accelerators for compute-only devices.
+const (
+	pciClassDisplay     = 0x03
+	pciClassAccelerator = 0x12
+)
+
+// parseUEvent decodes a kernel uevent, "ACTION@DEVPATH" followed by
+// KEY=VALUE pairs, each NUL-terminated. It reports whether the event
+// adds or removes a GPU: a display or accelerator PCI function, or a DRM
+// card or compute accelerator node.
+func parseUEvent(msg []byte) (Event, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) == 0 || !bytes.Contains(fields[0], []byte("@")) {
+		return Event{}, false
+	}
+	env := map[string]string{}
+	for _, f := range fields[1:] {
+		if k, v, ok := strings.Cut(string(f), "="); ok {
+			env[k] = v
+		}
+	}
+
+	ev := Event{Action: env["ACTION"], Subsystem: env["SUBSYSTEM"], DevPath: env["DEVPATH"]}
+	switch ev.Action {
+	case ActionAdd, ActionRemove, ActionBind, ActionUnbind:
+	default:
+		return Event{}, false
+	}
+	switch ev.Subsystem {
+	case "pci":
+		class, err := strconv.ParseUint(env["PCI_CLASS"], 16, 32)
+		if err != nil || (class>>16 != pciClassDisplay && class>>16 != pciClassAccelerator) {
+			return Event{}, false
+		}
+		ev.PCISlot = env["PCI_SLOT_NAME"]
+		return ev, true
+	case "drm":
+		// Only cards, not their connectors (card0-DP-1) or render nodes.
+		name := ev.DevPath[strings.LastIndex(ev.DevPath, "/")+1:]
+		rest, ok := strings.CutPrefix(name, "card")
+		if !ok || rest == "" || strings.Contains(rest, "-") {
+			return Event{}, false
+		}
+		return ev, true
+	case "accel":
+		return ev, true
+	}
+	return Event{}, false
+}
+
+// Settle returns first and the events that follow it until none arrives
+// for quiet, as adding or removing a GPU sends a burst of events: for
+// the PCI function, its driver binding and its device nodes.
+func Settle(ctx context.Context, events <-chan Event, first Event, quiet time.Duration) []Event {
+	burst := []Event{first}
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return burst
+		case <-timer.C:
+			return burst
+		case ev, ok := <-events:
+			if !ok {
+				return burst
+			}
+			burst = append(burst, ev)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(quiet)
+		}
+	}
+}
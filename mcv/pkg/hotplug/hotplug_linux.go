@@ -0,0 +1,71 @@
+package hotplug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// ueventGroup is the netlink multicast group of kernel uevents, as
+// opposed to the events udev rebroadcasts after processing them.
+const ueventGroup = 1
+
+// pollInterval bounds how long Watch takes to notice ctx is done.
+const pollInterval = time.Second
+
+// Watch reports GPU events until ctx is done. The kernel only sends
+// uevents to the host's network namespace, so in a container Watch needs
+// host networking. When events are lost because the socket buffer
+// overflowed, Watch reports an add event without a device, so callers
+// detect the GPUs again.
+func Watch(ctx context.Context) (<-chan Event, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uevent socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: ueventGroup}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind uevent socket: %w", err)
+	}
+	tv := unix.NsecToTimeval(pollInterval.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set uevent socket timeout: %w", err)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer unix.Close(fd)
+		buf := make([]byte, 64*1024)
+		for ctx.Err() == nil {
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			var ev Event
+			switch {
+			case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+				continue
+			case errors.Is(err, unix.ENOBUFS):
+				diag.Warn(diag.DeviceEventsMissed, "Missed device events, detecting GPUs again")
+				ev = Event{Action: ActionAdd}
+			case err != nil:
+				logging.Errorf("Stopped watching device events: %v", err)
+				return
+			default:
+				var ok bool
+				if ev, ok = parseUEvent(buf[:n]); !ok {
+					continue
+				}
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package hotplug
+
+import (
+	"context"
+	"errors"
+)
+
+// Watch is only supported on Linux.
+func Watch(ctx context.Context) (<-chan Event, error) {
+	return nil, errors.New("watching device events is only supported on Linux")
+}
@@ -0,0 +1,56 @@
+package hotplug
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func uevent(header string, env ...string) []byte {
+	return []byte(header + "\x00" + strings.Join(env, "\x00") + "\x00")
+}
+
+func TestParseUEvent(t *testing.T) {
+	gpu := "/devices/pci0000:00/0000:00:01.0/0000:01:00.0"
+	tests := []struct {
+		name string
+		msg  []byte
+		want *Event
+	}{
+		{"GPU added", uevent("add@"+gpu, "ACTION=add", "DEVPATH="+gpu, "SUBSYSTEM=pci", "PCI_CLASS=30200", "PCI_SLOT_NAME=0000:01:00.0"),
+			&Event{Action: ActionAdd, Subsystem: "pci", DevPath: gpu, PCISlot: "0000:01:00.0"}},
+		{"accelerator removed", uevent("remove@"+gpu, "ACTION=remove", "DEVPATH="+gpu, "SUBSYSTEM=pci", "PCI_CLASS=120000", "PCI_SLOT_NAME=0000:01:00.0"),
+			&Event{Action: ActionRemove, Subsystem: "pci", DevPath: gpu, PCISlot: "0000:01:00.0"}},
+		{"NIC added", uevent("add@"+gpu, "ACTION=add", "DEVPATH="+gpu, "SUBSYSTEM=pci", "PCI_CLASS=20000"), nil},
+		{"GPU changed", uevent("change@"+gpu, "ACTION=change", "DEVPATH="+gpu, "SUBSYSTEM=pci", "PCI_CLASS=30000"), nil},
+		{"DRM card", uevent("add@"+gpu+"/drm/card1", "ACTION=add", "DEVPATH="+gpu+"/drm/card1", "SUBSYSTEM=drm"),
+			&Event{Action: ActionAdd, Subsystem: "drm", DevPath: gpu + "/drm/card1"}},
+		{"DRM connector", uevent("add@"+gpu+"/drm/card1/card1-DP-1", "ACTION=add", "DEVPATH="+gpu+"/drm/card1/card1-DP-1", "SUBSYSTEM=drm"), nil},
+		{"DRM render node", uevent("add@"+gpu+"/drm/renderD128", "ACTION=add", "DEVPATH="+gpu+"/drm/renderD128", "SUBSYSTEM=drm"), nil},
+		{"udev event", []byte("libudev\x00\xfe\xed\xca\xfe"), nil},
+	}
+	for _, tt := range tests {
+		ev, ok := parseUEvent(tt.msg)
+		if tt.want == nil {
+			assert.False(t, ok, tt.name)
+			continue
+		}
+		assert.True(t, ok, tt.name)
+		assert.Equal(t, *tt.want, ev, tt.name)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	events := make(chan Event, 3)
+	events <- Event{Action: ActionBind}
+	events <- Event{Action: ActionAdd, Subsystem: "drm"}
+	burst := Settle(context.Background(), events, Event{Action: ActionAdd}, 50*time.Millisecond)
+	assert.Len(t, burst, 3)
+
+	close(events)
+	burst = Settle(context.Background(), events, Event{Action: ActionRemove}, time.Hour)
+	assert.Equal(t, []Event{{Action: ActionRemove}}, burst)
+}
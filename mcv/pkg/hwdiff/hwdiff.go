@@ -0,0 +1,233 @@
+// Package hwdiff records snapshots of a host's GPUs and drivers and
+// compares them, so that driver upgrades, GPUs gone missing or ECC mode
+// changes that may invalidate the caches deployed on the host are noticed
+// before workloads fail.
+package hwdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+)
+
+// Snapshot is the state of a host's hardware at some time.
+type Snapshot struct {
+	Taken time.Time      `json:"taken"`
+	Host  *hostinfo.Info `json:"host,omitempty"`
+	GPUs  []GPU          `json:"gpus"`
+}
+
+// GPU is the state of one GPU in a snapshot.
+type GPU struct {
+	ID             int    `json:"id"` // TritonGPUInfo.CanonicalID
+	UUID           string `json:"uuid,omitempty"`
+	PCIBusID       string `json:"pciBusId,omitempty"`
+	Product        string `json:"product,omitempty"`
+	Backend        string `json:"backend"`
+	Arch           string `json:"arch"`
+	Driver         string `json:"driver,omitempty"`
+	PTXVersion     int    `json:"ptxVersion,omitempty"`
+	ECC            string `json:"ecc,omitempty"`
+	Virtualization string `json:"virtualization,omitempty"`
+}
+
+// Take returns the snapshot of host and gpus taken at now.
+func Take(host *hostinfo.Info, gpus []devices.GPUDevice, now time.Time) Snapshot {
+	s := Snapshot{Taken: now.UTC(), Host: host, GPUs: []GPU{}}
+	for _, g := range gpus {
+		s.GPUs = append(s.GPUs, GPU{
+			ID:             g.TritonInfo.CanonicalID,
+			UUID:           g.TritonInfo.UUID,
+			PCIBusID:       g.TritonInfo.PCIBusID,
+			Product:        g.Summary.ProductName,
+			Backend:        g.TritonInfo.Backend,
+			Arch:           g.TritonInfo.Arch,
+			Driver:         g.Summary.DriverVersion,
+			PTXVersion:     g.TritonInfo.PTXVersion,
+			ECC:            g.TritonInfo.ECC,
+			Virtualization: g.TritonInfo.Virtualization,
+		})
+	}
+	sort.Slice(s.GPUs, func(i, j int) bool { return s.GPUs[i].ID < s.GPUs[j].ID })
+	return s
+}
+
+// Load reads the snapshot saved at path.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hardware snapshot: %w", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse hardware snapshot %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes s to path, replacing the snapshot there.
+func Save(path string, s Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create hardware snapshot dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write hardware snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write hardware snapshot: %w", err)
+	}
+	return nil
+}
+
+// Kinds of Change.
+const (
+	KindMissing        = "missing"        // A GPU of the baseline is gone
+	KindAdded          = "added"          // A GPU is not in the baseline
+	KindDriver         = "driver"         // The GPU driver version changed
+	KindArch           = "arch"           // The GPU's backend or architecture changed
+	KindECC            = "ecc"            // ECC was enabled or disabled
+	KindVirtualization = "virtualization" // The GPU became or stopped being virtual
+	KindKernel         = "kernel"         // The host's kernel changed
+)
+
+// Change is a difference between two snapshots.
+type Change struct {
+	Kind string `json:"kind"`
+	// GPU names the GPU that changed, as in the baseline, empty for host
+	// changes.
+	GPU    string `json:"gpu,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	Impact string `json:"impact"`
+}
+
+// Diff returns the changes from base to cur. GPUs are matched by UUID,
+// then by PCI address, and by position only when they have neither.
+func Diff(base, cur Snapshot) []Change {
+	var changes []Change
+	if base.Host != nil && cur.Host != nil && base.Host.KernelVersion != cur.Host.KernelVersion {
+		changes = append(changes, Change{Kind: KindKernel, Old: base.Host.KernelVersion, New: cur.Host.KernelVersion,
+			Impact: "the GPU kernel modules were likely rebuilt or replaced"})
+	}
+
+	matched := make([]bool, len(cur.GPUs))
+	find := func(key func(GPU) string, g GPU) int {
+		k := key(g)
+		if k == "" {
+			return -1
+		}
+		for i, c := range cur.GPUs {
+			if !matched[i] && key(c) == k {
+				return i
+			}
+		}
+		return -1
+	}
+	keys := []func(GPU) string{
+		func(g GPU) string { return g.UUID },
+		func(g GPU) string { return g.PCIBusID },
+		func(g GPU) string {
+			if g.UUID != "" || g.PCIBusID != "" {
+				return ""
+			}
+			return strconv.Itoa(g.ID)
+		},
+	}
+	match := make([]int, len(base.GPUs))
+	for j := range match {
+		match[j] = -1
+	}
+	for _, key := range keys {
+		for j, b := range base.GPUs {
+			if match[j] < 0 {
+				if i := find(key, b); i >= 0 {
+					match[j] = i
+					matched[i] = true
+				}
+			}
+		}
+	}
+
+	for j, b := range base.GPUs {
+		i := match[j]
+		if i < 0 {
+			changes = append(changes, Change{Kind: KindMissing, GPU: name(b), Old: describe(b),
+				Impact: "workloads scheduled for this GPU cannot use its cache"})
+			continue
+		}
+		changes = append(changes, diffGPU(b, cur.GPUs[i])...)
+	}
+	for i, c := range cur.GPUs {
+		if !matched[i] {
+			changes = append(changes, Change{Kind: KindAdded, GPU: name(c), New: describe(c),
+				Impact: "check that the deployed caches have kernels for this GPU"})
+		}
+	}
+	return changes
+}
+
+func diffGPU(b, c GPU) []Change {
+	var changes []Change
+	if b.Backend != c.Backend || b.Arch != c.Arch {
+		changes = append(changes, Change{Kind: KindArch, GPU: name(b), Old: b.Backend + ":" + b.Arch, New: c.Backend + ":" + c.Arch,
+			Impact: "kernels built for the old architecture do not run on this GPU"})
+	}
+	if b.Driver != c.Driver {
+		impact := "caches built with the old driver should be checked again with mcv --check-compat"
+		if c.PTXVersion != 0 && c.PTXVersion < b.PTXVersion {
+			impact = "the driver was downgraded; kernels built for the newer driver may fail to load"
+		}
+		changes = append(changes, Change{Kind: KindDriver, GPU: name(b), Old: b.Driver, New: c.Driver, Impact: impact})
+	}
+	if b.ECC != c.ECC {
+		changes = append(changes, Change{Kind: KindECC, GPU: name(b), Old: orUnknown(b.ECC), New: orUnknown(c.ECC),
+			Impact: "the usable GPU memory changed, which may change the kernels autotuned for it"})
+	}
+	if b.Virtualization != c.Virtualization {
+		changes = append(changes, Change{Kind: KindVirtualization, GPU: name(b), Old: orPhysical(b.Virtualization), New: orPhysical(c.Virtualization),
+			Impact: "the GPU's limits changed, caches built on the old setup may not match"})
+	}
+	return changes
+}
+
+// name identifies g in changes.
+func name(g GPU) string {
+	if g.PCIBusID != "" {
+		return fmt.Sprintf("%d (%s)", g.ID, g.PCIBusID)
+	}
+	return strconv.Itoa(g.ID)
+}
+
+func describe(g GPU) string {
+	d := g.Backend + ":" + g.Arch
+	if g.Product != "" {
+		d = g.Product + " " + d
+	}
+	return d
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func orPhysical(s string) string {
+	if s == "" {
+		return "physical"
+	}
+	return s
+}
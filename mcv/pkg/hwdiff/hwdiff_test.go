@@ -0,0 +1,70 @@
+package hwdiff
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func gpu(id int, bdf, driver string, ptx int, ecc string) devices.GPUDevice {
+	return devices.GPUDevice{
+		ID: id,
+		TritonInfo: devices.TritonGPUInfo{UUID: "GPU-" + bdf, PCIBusID: bdf, Backend: "cuda", Arch: "90",
+			PTXVersion: ptx, ECC: ecc, CanonicalID: id},
+		Summary: devices.DeviceSummary{ProductName: "NVIDIA H100", DriverVersion: driver},
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hw", "snapshot.json")
+	_, err := Load(path)
+	assert.Error(t, err)
+
+	s := Take(&hostinfo.Info{KernelVersion: "6.8.0"}, []devices.GPUDevice{gpu(1, "0000:0f:00.0", "550.54.15", 550, devices.ECCEnabled), gpu(0, "0000:07:00.0", "550.54.15", 550, devices.ECCEnabled)},
+		time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 0, s.GPUs[0].ID)
+	assert.NoError(t, Save(path, s))
+	loaded, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, s, *loaded)
+	assert.Empty(t, Diff(s, *loaded))
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+	base := Take(&hostinfo.Info{KernelVersion: "6.8.0"}, []devices.GPUDevice{
+		gpu(0, "0000:07:00.0", "550.54.15", 550, devices.ECCEnabled),
+		gpu(1, "0000:0f:00.0", "550.54.15", 550, devices.ECCEnabled),
+		gpu(2, "0000:47:00.0", "550.54.15", 550, devices.ECCEnabled),
+	}, now)
+	// GPU 1 is gone, so the GPU at 0000:47:00.0 now comes second.
+	cur := Take(&hostinfo.Info{KernelVersion: "6.8.0"}, []devices.GPUDevice{
+		gpu(0, "0000:07:00.0", "535.104.05", 535, devices.ECCEnabled),
+		gpu(1, "0000:47:00.0", "550.54.15", 550, devices.ECCDisabled),
+	}, now)
+
+	changes := Diff(base, cur)
+	kinds := map[string][]string{}
+	for _, c := range changes {
+		kinds[c.Kind] = append(kinds[c.Kind], c.GPU)
+	}
+	assert.Equal(t, map[string][]string{
+		KindDriver:  {"0 (0000:07:00.0)"},
+		KindMissing: {"1 (0000:0f:00.0)"},
+		KindECC:     {"2 (0000:47:00.0)"},
+	}, kinds)
+	assert.Contains(t, changes[0].Impact, "downgraded")
+
+	cur.Host.KernelVersion = "6.8.1"
+	cur.GPUs = append(cur.GPUs, GPU{ID: 2, Backend: "hip", Arch: "gfx942"})
+	kinds = map[string][]string{}
+	for _, c := range Diff(base, cur) {
+		kinds[c.Kind] = append(kinds[c.Kind], c.GPU)
+	}
+	assert.Equal(t, []string{""}, kinds[KindKernel])
+	assert.Equal(t, []string{"2"}, kinds[KindAdded])
+}
@@ -0,0 +1,113 @@
+package imgbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+)
+
+// Standard OCI annotation keys set on built images.
+const (
+	AnnotationCreated  = "org.opencontainers.image.created"
+	AnnotationTitle    = "org.opencontainers.image.title"
+	AnnotationSource   = "org.opencontainers.image.source"
+	AnnotationRevision = "org.opencontainers.image.revision"
+
+	// AnnotationCacheTypes lists the cache types packaged in the image.
+	AnnotationCacheTypes = "cache.mcv.image/types"
+)
+
+// buildAnnotations describes the build inputs so registry UIs can show
+// where an image came from and what hardware it targets.
+// Annotations from the annotate plugin are included, but cannot replace the
+// ones MCV sets.
+func buildAnnotations(imageName string, prep *buildContext, opts BuildOptions, created time.Time) map[string]string {
+	annotations := map[string]string{
+		AnnotationCreated:    created.UTC().Format(time.RFC3339),
+		AnnotationTitle:      imageTitle(imageName),
+		AnnotationCacheTypes: strings.Join(cache.CacheTypes(prep.Caches), ","),
+	}
+	if opts.Source != "" {
+		annotations[AnnotationSource] = opts.Source
+	}
+	if opts.Revision != "" {
+		annotations[AnnotationRevision] = opts.Revision
+	}
+	if !opts.ValidUntil.IsZero() {
+		annotations[cache.ValidUntilLabel] = opts.ValidUntil.UTC().Format(time.RFC3339)
+	}
+	for _, c := range prep.Caches {
+		annotations[fmt.Sprintf("cache.%s.image/variant", c.Name())] = "compat"
+		if targets := summaryTargets(c.Summary()); targets != "" {
+			annotations[fmt.Sprintf("cache.%s.image/targets", c.Name())] = targets
+		}
+	}
+	for k, v := range prep.Annotations {
+		if _, ok := annotations[k]; ok {
+			diag.Warnf(diag.ReservedLabel, "Ignoring plugin annotation %s: reserved by MCV", k)
+			continue
+		}
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// provenanceLabels returns the build provenance that is also exposed as
+// image labels, which some registries show instead of annotations.
+func provenanceLabels(opts BuildOptions) map[string]string {
+	labels := map[string]string{}
+	if opts.Source != "" {
+		labels[AnnotationSource] = opts.Source
+	}
+	if opts.Revision != "" {
+		labels[AnnotationRevision] = opts.Revision
+	}
+	if !opts.ValidUntil.IsZero() {
+		labels[cache.ValidUntilLabel] = opts.ValidUntil.UTC().Format(time.RFC3339)
+	}
+	return labels
+}
+
+// summaryTargets renders the targets of a cache summary as a sorted list
+// of backend:arch pairs, e.g. "cuda:90,hip:gfx942".
+func summaryTargets(summary string) string {
+	var s cache.Summary
+	if summary == "" || json.Unmarshal([]byte(summary), &s) != nil {
+		return ""
+	}
+	seen := map[string]bool{}
+	var targets []string
+	for _, t := range s.Targets {
+		key := t.Backend + ":" + t.Arch
+		if !seen[key] {
+			seen[key] = true
+			targets = append(targets, key)
+		}
+	}
+	sort.Strings(targets)
+	return strings.Join(targets, ",")
+}
+
+// cacheLayerComment summarizes the contents of the cache layer for its
+// history entry.
+func cacheLayerComment(prep *buildContext) string {
+	return layerComment(prep.Caches, prep.ExtraCopies)
+}
+
+func layerComment(caches []cache.Cache, extra []CopySpec) string {
+	parts := make([]string, 0, len(caches)+len(extra))
+	for _, c := range caches {
+		parts = append(parts, fmt.Sprintf("%s cache (%d entries, %s)",
+			c.Name(), c.EntryCount(), units.BytesSize(float64(c.CacheSizeBytes()))))
+	}
+	for _, e := range extra {
+		parts = append(parts, "extra "+e.Dest)
+	}
+	return "cache and manifest layer: " + strings.Join(parts, ", ")
+}
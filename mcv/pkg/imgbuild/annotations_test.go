@@ -0,0 +1,48 @@
+package imgbuild
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCache struct {
+	name    string
+	summary string
+}
+
+func (f *fakeCache) Name() string                 { return f.name }
+func (f *fakeCache) EntryCount() int              { return 2 }
+func (f *fakeCache) CacheSizeBytes() int64        { return 2048 }
+func (f *fakeCache) Summary() string              { return f.summary }
+func (f *fakeCache) Metadata() []cache.CacheEntry { return nil }
+func (f *fakeCache) Labels() map[string]string    { return nil }
+func (f *fakeCache) ManifestTag() string          { return "io." + f.name + ".manifest" }
+func (f *fakeCache) CacheTag() string             { return "io." + f.name + ".cache" }
+func (f *fakeCache) SetTmpPath(string)            {}
+
+func TestBuildAnnotations(t *testing.T) {
+	prep := &buildContext{
+		Caches: []cache.Cache{&fakeCache{
+			name:    "triton",
+			summary: `{"targets":[{"backend":"hip","arch":"gfx942","warp_size":64},{"backend":"cuda","arch":"90","warp_size":32},{"backend":"cuda","arch":"90","warp_size":32}]}`,
+		}},
+		ExtraCopies: []CopySpec{{Dest: "/licenses/LICENSE"}},
+	}
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	opts := BuildOptions{Source: "https://github.com/example/model", Revision: "abc123"}
+
+	assert.Equal(t, map[string]string{
+		AnnotationCreated:            "2025-06-01T12:00:00Z",
+		AnnotationTitle:              "cache",
+		AnnotationSource:             "https://github.com/example/model",
+		AnnotationRevision:           "abc123",
+		AnnotationCacheTypes:         "triton",
+		"cache.triton.image/variant": "compat",
+		"cache.triton.image/targets": "cuda:90,hip:gfx942",
+	}, buildAnnotations("quay.io/example/cache:v1", prep, opts, created))
+
+	assert.Equal(t, "cache and manifest layer: triton cache (2 entries, 2KiB), extra /licenses/LICENSE", cacheLayerComment(prep))
+}
@@ -3,22 +3,32 @@ package imgbuild
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/containers/buildah"
 	"github.com/containers/common/pkg/config"
 	is "github.com/containers/image/v5/storage"
 	"github.com/containers/storage"
+	"github.com/redhat-et/MCU/mcv/pkg/chunk"
 	logging "github.com/sirupsen/logrus"
 )
 
-type buildahBuilder struct{}
+type buildahBuilder struct {
+	opts   BuildOptions
+	digest string
+}
+
+func (b *buildahBuilder) ImageDigest() string {
+	return b.digest
+}
 
 func (b *buildahBuilder) CreateImage(imageName, cacheDir string) error {
-	prep, err := prepareBuildContext("buildah", cacheDir)
+	prep, err := prepareBuildContext("buildah", cacheDir, b.opts)
 	if err != nil {
 		return err
 	}
-	defer CleanupDirs(prep.CacheBuildDir, prep.ManifestBuildDir)
+	defer CleanupDirs(prep.stagedDirs()...)
 
 	buildStoreOptions, err := storage.DefaultStoreOptions()
 	if err != nil {
@@ -53,9 +63,14 @@ func (b *buildahBuilder) CreateImage(imageName, cacheDir string) error {
 		return fmt.Errorf("error creating the image reference: %v", err)
 	}
 
+	baseImage := b.opts.BaseImage
+	if baseImage == "" {
+		baseImage = DefaultBaseImage
+	}
+
 	builderOpts := buildah.BuilderOptions{
 		Capabilities: capabilitiesForRoot,
-		FromImage:    "scratch",
+		FromImage:    baseImage,
 	}
 
 	ctx := context.TODO()
@@ -82,14 +97,55 @@ func (b *buildahBuilder) CreateImage(imageName, cacheDir string) error {
 		return fmt.Errorf("error adding %s to builder: %v", prep.CacheBuildDir, err)
 	}
 
+	for _, c := range prep.colocated() {
+		if err = builder.Add(c.ManifestTag, false, addOptions, c.ManifestBuildDir+"/."); err != nil {
+			return fmt.Errorf("error adding manifest %s to builder: %v", c.ManifestBuildDir, err)
+		}
+		if err = builder.Add(c.CacheTag, false, addOptions, c.CacheBuildDir+"/."); err != nil {
+			return fmt.Errorf("error adding %s to builder: %v", c.CacheBuildDir, err)
+		}
+	}
+
+	// Buildah commits a single layer, so external summaries are added next
+	// to the cache and located by path.
+	for _, s := range prep.Summaries {
+		if err = builder.Add(s.Dest, false, addOptions, s.Src); err != nil {
+			return fmt.Errorf("error adding summary %s to builder: %v", s.Dest, err)
+		}
+	}
+
+	// Chunks of chunked files also share the single layer, so they only
+	// save space, not pulls.
+	if prep.ChunkStore != "" {
+		if err = builder.Add(chunk.LayerDir, false, addOptions, prep.ChunkStore+"/."); err != nil {
+			return fmt.Errorf("error adding chunks to builder: %v", err)
+		}
+	}
+
+	for _, c := range prep.ExtraCopies {
+		src := filepath.Join(prep.BuildRoot, c.ContextPath)
+		if err = builder.Add(c.Dest, false, addOptions, src); err != nil {
+			return fmt.Errorf("error adding %s to builder: %v", c.Src, err)
+		}
+	}
+
+	for k, v := range provenanceLabels(b.opts) {
+		builder.SetLabel(k, v)
+	}
 	for k, v := range prep.Labels {
 		builder.SetLabel(k, v)
 	}
+	for k, v := range buildAnnotations(imageName, prep, b.opts, time.Now()) {
+		builder.SetAnnotation(k, v)
+	}
+	builder.SetCreatedBy("mcv create " + imageName)
+	builder.SetHistoryComment(cacheLayerComment(prep))
 
-	imageID, _, _, err := builder.Commit(ctx, imageRef, buildah.CommitOptions{Squash: true})
+	imageID, _, digest, err := builder.Commit(ctx, imageRef, buildah.CommitOptions{Squash: true})
 	if err != nil {
 		return err
 	}
+	b.digest = digest.String()
 	logging.Infof("Image built! %s", imageID)
 
 	// Cleanup
@@ -3,23 +3,63 @@ package imgbuild
 import (
 	"fmt"
 
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
 	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
 )
 
+// Supported image builder backends.
+const (
+	BuilderNative  = "native"
+	BuilderBuildah = "buildah"
+	BuilderDocker  = "docker"
+)
+
+// ImageBuilder builds a cache image from a cache directory.
 type ImageBuilder interface {
 	CreateImage(imgName string, cacheDir string) error
 }
 
+// Digester is implemented by builders that know the manifest digest of
+// the image they last created. Images built by docker have none until
+// they are pushed.
+type Digester interface {
+	ImageDigest() string
+}
+
 var HasApp = utils.HasApp
 
-func New() (ImageBuilder, error) {
-	if HasApp("buildah") {
+// Builders returns the names of the supported builder backends.
+func Builders() []string {
+	return []string{BuilderNative, BuilderBuildah, BuilderDocker}
+}
+
+// New returns the image builder for the requested backend. An empty backend
+// selects the native builder, which assembles the OCI image directly and
+// stores it in the local image store.
+func New(backend string, opts BuildOptions) (ImageBuilder, error) {
+	if opts.FSImage != "" && backend != "" && backend != BuilderNative {
+		return nil, fmt.Errorf("%s cache images need the %s builder", opts.FSImage, BuilderNative)
+	}
+	if len(opts.HotKernels) > 0 && backend != "" && backend != BuilderNative {
+		diag.Warnf(diag.HotLayerUnordered, "The %s builder does not order the cache layer: hot kernels are marked in the manifest but not extracted first", backend)
+	}
+	switch backend {
+	case "", BuilderNative:
+		logging.Infof("Assembling the image natively")
+		return &nativeBuilder{opts: opts}, nil
+	case BuilderBuildah:
+		if !HasApp("buildah") {
+			return nil, fmt.Errorf("builder %s requested but buildah was not found", backend)
+		}
 		logging.Infof("Using buildah to build the image")
-		return &buildahBuilder{}, nil
-	} else if HasApp("docker") {
+		return &buildahBuilder{opts: opts}, nil
+	case BuilderDocker:
+		if !HasApp("docker") {
+			return nil, fmt.Errorf("builder %s requested but docker was not found", backend)
+		}
 		logging.Infof("Using docker to build the image")
-		return &dockerBuilder{}, nil
+		return &dockerBuilder{opts: opts}, nil
 	}
-	return nil, fmt.Errorf("unsupported builder: neither buildah nor docker found")
+	return nil, fmt.Errorf("unsupported builder %q: must be one of %v", backend, Builders())
 }
@@ -6,6 +6,12 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestNew_NativeDefault(t *testing.T) {
+	builder, err := New("", BuildOptions{})
+	assert.NoError(t, err)
+	assert.IsType(t, &nativeBuilder{}, builder)
+}
+
 func TestNew_BuildahAvailable(t *testing.T) {
 	origHasApp := HasApp
 	defer func() { HasApp = origHasApp }()
@@ -14,12 +20,12 @@ func TestNew_BuildahAvailable(t *testing.T) {
 		return tool == "buildah"
 	}
 
-	builder, err := New()
+	builder, err := New(BuilderBuildah, BuildOptions{})
 	assert.NoError(t, err)
 	assert.IsType(t, &buildahBuilder{}, builder)
 }
 
-func TestNew_DockerFallback(t *testing.T) {
+func TestNew_DockerAvailable(t *testing.T) {
 	origHasApp := HasApp
 	defer func() { HasApp = origHasApp }()
 
@@ -27,12 +33,12 @@ func TestNew_DockerFallback(t *testing.T) {
 		return tool == "docker"
 	}
 
-	builder, err := New()
+	builder, err := New(BuilderDocker, BuildOptions{})
 	assert.NoError(t, err)
 	assert.IsType(t, &dockerBuilder{}, builder)
 }
 
-func TestNew_Unsupported(t *testing.T) {
+func TestNew_BackendMissing(t *testing.T) {
 	origHasApp := HasApp
 	defer func() { HasApp = origHasApp }()
 
@@ -40,7 +46,13 @@ func TestNew_Unsupported(t *testing.T) {
 		return false
 	}
 
-	builder, err := New()
+	builder, err := New(BuilderBuildah, BuildOptions{})
+	assert.Nil(t, builder)
+	assert.Error(t, err)
+}
+
+func TestNew_Unsupported(t *testing.T) {
+	builder, err := New("kaniko", BuildOptions{})
 	assert.Nil(t, builder)
 	assert.Error(t, err)
 }
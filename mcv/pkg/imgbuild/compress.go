@@ -0,0 +1,106 @@
+package imgbuild
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/redhat-et/MCU/mcv/pkg/reslimit"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	// defaultGzipLevel matches what go-containerregistry uses for layers it
+	// compresses itself, so packing speed and size are unchanged by default.
+	defaultGzipLevel = pgzip.BestSpeed
+	defaultZstdLevel = 3
+	maxZstdLevel     = 22
+
+	// gzipBlockSize is the amount of input each pgzip worker compresses at a
+	// time.
+	gzipBlockSize = 1 << 20
+)
+
+// Compression selects how the native builder compresses image layers.
+// Zero values select gzip at the default level using every CPU.
+type Compression struct {
+	Algorithm string // gzip or zstd
+	Level     int    // 0 selects the algorithm default
+	Workers   int    // Parallel encoders, 0 uses all CPUs the cgroup limits allow
+}
+
+// Compressions returns the supported layer compression algorithms.
+func Compressions() []string {
+	return []string{CompressionGzip, CompressionZstd}
+}
+
+// Validate checks the algorithm and level and fills in defaults.
+func (c *Compression) Validate() error {
+	c.Algorithm = strings.ToLower(c.Algorithm)
+	if c.Algorithm == "" {
+		c.Algorithm = CompressionGzip
+	}
+	switch c.Algorithm {
+	case CompressionGzip:
+		if c.Level == 0 {
+			c.Level = defaultGzipLevel
+		}
+		if c.Level < pgzip.BestSpeed || c.Level > pgzip.BestCompression {
+			return fmt.Errorf("invalid gzip compression level %d (expect %d-%d)", c.Level, pgzip.BestSpeed, pgzip.BestCompression)
+		}
+	case CompressionZstd:
+		if c.Level == 0 {
+			c.Level = defaultZstdLevel
+		}
+		if c.Level < 1 || c.Level > maxZstdLevel {
+			return fmt.Errorf("invalid zstd compression level %d (expect 1-%d)", c.Level, maxZstdLevel)
+		}
+	default:
+		return fmt.Errorf("unsupported compression %q (supported: %s)", c.Algorithm, strings.Join(Compressions(), ", "))
+	}
+	if c.Workers < 0 {
+		return fmt.Errorf("invalid compression worker count %d", c.Workers)
+	}
+	if c.Workers == 0 {
+		c.Workers = reslimit.Workers()
+	}
+	return nil
+}
+
+// MediaType returns the OCI layer media type for the algorithm.
+func (c Compression) MediaType() types.MediaType {
+	if c.Algorithm == CompressionZstd {
+		return types.OCILayerZStd
+	}
+	return types.OCILayer
+}
+
+func (c Compression) ggcrCompression() compression.Compression {
+	if c.Algorithm == CompressionZstd {
+		return compression.ZStd
+	}
+	return compression.GZip
+}
+
+// newWriter returns an encoder writing compressed data to w.
+func (c Compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.Algorithm == CompressionZstd {
+		return zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.Level)),
+			zstd.WithEncoderConcurrency(c.Workers))
+	}
+	gw, err := pgzip.NewWriterLevel(w, c.Level)
+	if err != nil {
+		return nil, err
+	}
+	if err := gw.SetConcurrency(gzipBlockSize, c.Workers); err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
@@ -0,0 +1,65 @@
+package imgbuild
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionValidate(t *testing.T) {
+	c := Compression{}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, CompressionGzip, c.Algorithm)
+	assert.Equal(t, defaultGzipLevel, c.Level)
+	assert.Positive(t, c.Workers)
+
+	c = Compression{Algorithm: "ZSTD", Level: 19, Workers: 2}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, CompressionZstd, c.Algorithm)
+	assert.Equal(t, types.OCILayerZStd, c.MediaType())
+
+	assert.Error(t, (&Compression{Level: 10}).Validate())
+	assert.Error(t, (&Compression{Algorithm: CompressionZstd, Level: 23}).Validate())
+	assert.Error(t, (&Compression{Algorithm: "lz4"}).Validate())
+	assert.Error(t, (&Compression{Workers: -1}).Validate())
+}
+
+func TestNewTarLayer_Compression(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "kernel.json"), []byte(`{"hash":"abc"}`), 0644))
+
+	for _, algo := range Compressions() {
+		layer, err := newTarLayer([]layerEntry{{Src: src, Dest: "io.triton.cache"}}, Compression{Algorithm: algo, Workers: 2})
+		if !assert.NoError(t, err, algo) {
+			continue
+		}
+
+		mt, err := layer.MediaType()
+		assert.NoError(t, err)
+		assert.Equal(t, Compression{Algorithm: algo}.MediaType(), mt)
+
+		rc, err := layer.Uncompressed()
+		if !assert.NoError(t, err, algo) {
+			continue
+		}
+		var names []string
+		tr := tar.NewReader(rc)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err, algo) {
+				break
+			}
+			names = append(names, hdr.Name)
+		}
+		rc.Close()
+		assert.Contains(t, names, "io.triton.cache/kernel.json", algo)
+	}
+}
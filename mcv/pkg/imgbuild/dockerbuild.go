@@ -21,65 +21,57 @@ import (
 	"io"
 	"os"
 
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/archive"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	logging "github.com/sirupsen/logrus"
 )
 
-type dockerBuilder struct{}
+type dockerBuilder struct {
+	opts BuildOptions
+}
 
-// Docker implementation of the ImageBuilder interface.
+// Docker implementation of the ImageBuilder interface. The image is
+// assembled natively and loaded into the Docker daemon.
 func (d *dockerBuilder) CreateImage(imageName, cacheDir string) error {
-	prep, err := prepareBuildContext("docker", cacheDir)
+	prep, err := prepareBuildContext("docker", cacheDir, d.opts)
 	if err != nil {
 		return err
 	}
-	defer CleanupDirs(prep.CacheBuildDir, prep.ManifestBuildDir)
-
-	dockerfilePath := DockerfilePath(prep.BuildRoot)
+	defer CleanupDirs(prep.stagedDirs()...)
 
-	err = GenerateDockerfile(imageName, prep.CacheTag, prep.ManifestTag, dockerfilePath)
+	img, err := assembleImage(imageName, prep, d.opts)
 	if err != nil {
-		return fmt.Errorf("failed to generate Dockerfile: %w", err)
+		return err
 	}
-	defer os.Remove(dockerfilePath)
+	keepImage(img, prep)
 
-	apiClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	imageWithTag := NormalizeImageTag(imageName)
+	ref, err := name.ParseReference(imageWithTag)
 	if err != nil {
-		return fmt.Errorf("failed to create Docker client: %w", err)
+		return fmt.Errorf("invalid image name %s: %w", imageWithTag, err)
 	}
 
-	tar, err := archive.TarWithOptions(prep.BuildRoot, &archive.TarOptions{IncludeSourceDir: false}) //nolint:staticcheck // SA1019: archive.TarWithOptions is deprecated but no alternative exists
+	apiClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		return fmt.Errorf("error creating tar: %w", err)
-	}
-	defer tar.Close()
-
-	buildOptions := types.ImageBuildOptions{
-		Dockerfile: "Dockerfile",
-		Tags:       []string{imageName},
-		NoCache:    true,
-		Remove:     false,
-		Labels:     prep.Labels,
+		return fmt.Errorf("failed to create Docker client: %w", err)
 	}
+	defer apiClient.Close()
 
-	buildResponse, err := apiClient.ImageBuild(context.Background(), tar, buildOptions)
-	if err != nil {
-		return fmt.Errorf("error building image: %w", err)
-	}
-	defer buildResponse.Body.Close()
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarball.Write(ref, img, pw))
+	}()
 
-	_, err = io.Copy(os.Stdout, buildResponse.Body)
+	resp, err := apiClient.ImageLoad(context.Background(), pr)
 	if err != nil {
-		return fmt.Errorf("error reading build output: %w", err)
+		pr.CloseWithError(err)
+		return fmt.Errorf("error loading image into docker: %w", err)
 	}
+	defer resp.Body.Close()
 
-	imageWithTag := NormalizeImageTag(imageName)
-
-	err = apiClient.ImageTag(context.Background(), imageName, imageWithTag)
-	if err != nil {
-		return fmt.Errorf("error tagging image: %w", err)
+	if _, err = io.Copy(os.Stdout, resp.Body); err != nil {
+		return fmt.Errorf("error reading load output: %w", err)
 	}
 	logging.Info("Docker image built successfully")
 
@@ -0,0 +1,249 @@
+package imgbuild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	units "github.com/docker/go-units"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// DefaultExcludes matches transient files that are never part of a usable
+// cache: lock files, Triton's in-progress tmp directories and core dumps.
+var DefaultExcludes = []string{"*.lock", "tmp.pid_*", "core", "core.[0-9]*"}
+
+// ContentFilter drops files from the cache directory before it is packaged.
+// Patterns containing a "/" are matched against the path relative to the
+// cache directory, other patterns against the file or directory name.
+type ContentFilter struct {
+	Exclude     []string // Glob patterns to skip, in addition to DefaultExcludes
+	MaxFileSize int64    // Files larger than this many bytes are skipped, 0 disables
+	// Include lists the paths, relative to the cache directory, to package;
+	// everything else is skipped. A directory includes all it holds. Nil
+	// includes everything.
+	Include []string
+}
+
+// SkippedFile is a cache file left out of the image and why.
+type SkippedFile struct {
+	Path   string
+	Size   int64
+	Reason string
+}
+
+// ParseFileSize parses a size such as "512M" or "2GiB" for --max-file-size.
+func ParseFileSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := units.RAMInBytes(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid file size %q", s)
+	}
+	return n, nil
+}
+
+// Validate checks the exclude patterns are well formed.
+func (f ContentFilter) Validate() error {
+	for _, p := range f.Exclude {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// excluded returns the pattern matching rel, or "" if none does.
+func (f ContentFilter) excluded(rel string) string {
+	name := filepath.Base(rel)
+	for _, patterns := range [][]string{DefaultExcludes, f.Exclude} {
+		for _, p := range patterns {
+			target := name
+			if strings.Contains(p, "/") {
+				target = filepath.ToSlash(rel)
+			}
+			if ok, _ := filepath.Match(p, target); ok {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// includeSet answers whether a path is selected by ContentFilter.Include.
+type includeSet struct {
+	paths   map[string]bool // Included paths
+	parents map[string]bool // Directories holding included paths
+}
+
+func newIncludeSet(include []string) *includeSet {
+	if include == nil {
+		return nil
+	}
+	s := &includeSet{paths: map[string]bool{}, parents: map[string]bool{}}
+	for _, p := range include {
+		p = filepath.Clean(p)
+		s.paths[p] = true
+		for dir := filepath.Dir(p); dir != "."; dir = filepath.Dir(dir) {
+			s.parents[dir] = true
+		}
+	}
+	return s
+}
+
+// skips reports whether rel is left out: it is not included, is not inside
+// an included directory and, for directories, holds nothing included.
+func (s *includeSet) skips(rel string, dir bool) bool {
+	if s == nil || (dir && s.parents[rel]) {
+		return false
+	}
+	for p := rel; p != "."; p = filepath.Dir(p) {
+		if s.paths[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// forComponent returns the filter for a cache in compDir, with the include
+// paths, relative to cacheDir, made relative to compDir.
+func (f ContentFilter) forComponent(cacheDir, compDir string) ContentFilter {
+	if f.Include == nil {
+		return f
+	}
+	cacheDir, _ = filepath.Abs(cacheDir)
+	compDir, _ = filepath.Abs(compDir)
+	include := []string{}
+	for _, p := range f.Include {
+		path := filepath.Join(cacheDir, p)
+		if up, err := filepath.Rel(path, compDir); err == nil && !strings.HasPrefix(up, "..") {
+			f.Include = nil // The whole cache is included
+			return f
+		}
+		rel, err := filepath.Rel(compDir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue // Part of another cache
+		}
+		include = append(include, rel)
+	}
+	f.Include = include
+	return f
+}
+
+// copyFiltered copies srcDir to dstDir like cache.CopyDir, leaving out
+// anything the filter rejects, and returns what was skipped. Files of at
+// least streamMin bytes that are never rewritten are linked rather than
+// copied; 0 copies everything.
+func copyFiltered(srcDir, dstDir string, f ContentFilter, streamMin int64) ([]SkippedFile, error) {
+	var skipped []SkippedFile
+	include := newIncludeSet(f.Include)
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstDir, rel)
+
+		if rel != "." {
+			if p := f.excluded(rel); p != "" {
+				if info.IsDir() {
+					skipped = append(skipped, SkippedFile{Path: rel + "/", Size: dirSize(path), Reason: "matches " + p})
+					return filepath.SkipDir
+				}
+				skipped = append(skipped, SkippedFile{Path: rel, Size: info.Size(), Reason: "matches " + p})
+				return nil
+			}
+			if include.skips(rel, info.IsDir()) {
+				if info.IsDir() {
+					skipped = append(skipped, SkippedFile{Path: rel + "/", Size: dirSize(path), Reason: "not in the include list"})
+					return filepath.SkipDir
+				}
+				skipped = append(skipped, SkippedFile{Path: rel, Size: info.Size(), Reason: "not in the include list"})
+				return nil
+			}
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			// Follow links to files, as cache.CopyDir does.
+			if st, err := os.Stat(path); err == nil {
+				info = st
+			}
+		}
+		if !info.Mode().IsRegular() {
+			skipped = append(skipped, SkippedFile{Path: rel, Reason: "not a regular file"})
+			return nil
+		}
+		if f.MaxFileSize > 0 && info.Size() > f.MaxFileSize {
+			skipped = append(skipped, SkippedFile{Path: rel, Size: info.Size(),
+				Reason: fmt.Sprintf("larger than %s", units.BytesSize(float64(f.MaxFileSize)))})
+			return nil
+		}
+		if streamMin > 0 && info.Size() >= streamMin && !cache.IsRelocatable(path) {
+			return linkFile(path, target)
+		}
+		return copyFile(path, target, info.Mode())
+	})
+	return skipped, err
+}
+
+func dirSize(dir string) int64 {
+	var total int64
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// linkFile stages src as a symlink, so the layer writer streams it from
+// the source cache instead of from a second copy on disk.
+func linkFile(src, dst string) error {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(abs, dst)
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// logSkipped reports the files left out of the image.
+func logSkipped(skipped []SkippedFile) {
+	if len(skipped) == 0 {
+		return
+	}
+	var total int64
+	for _, s := range skipped {
+		total += s.Size
+		logging.Infof("Skipped %s (%s): %s", s.Path, units.BytesSize(float64(s.Size)), s.Reason)
+	}
+	diag.Warnf(diag.EntriesNotPackaged, "Skipped %d cache entries (%s) while packaging", len(skipped), units.BytesSize(float64(total)))
+}
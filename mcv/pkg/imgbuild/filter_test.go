@@ -0,0 +1,139 @@
+package imgbuild
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyFiltered(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]int{
+		"ABC/kernel.json":          10,
+		"ABC/kernel.cubin":         100,
+		"ABC/kernel.lock":          0,
+		"tmp.pid_42_x/partial.bin": 5,
+		"core.1234":                50,
+		"DEF/debug.log":            5,
+		"DEF/huge.so":              2048,
+	}
+	for path, size := range files {
+		full := filepath.Join(src, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, make([]byte, size), 0644))
+	}
+
+	dst := t.TempDir()
+	skipped, err := copyFiltered(src, dst, ContentFilter{Exclude: []string{"*.log"}, MaxFileSize: 1024}, 0)
+	assert.NoError(t, err)
+
+	reasons := map[string]string{}
+	for _, s := range skipped {
+		reasons[s.Path] = s.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"ABC/kernel.lock": "matches *.lock",
+		"tmp.pid_42_x/":   "matches tmp.pid_*",
+		"core.1234":       "matches core.[0-9]*",
+		"DEF/debug.log":   "matches *.log",
+		"DEF/huge.so":     "larger than 1KiB",
+	}, reasons)
+
+	for _, kept := range []string{"ABC/kernel.json", "ABC/kernel.cubin"} {
+		_, err := os.Stat(filepath.Join(dst, kept))
+		assert.NoError(t, err, kept)
+	}
+	_, err = os.Stat(filepath.Join(dst, "tmp.pid_42_x"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopyFiltered_Include(t *testing.T) {
+	src := t.TempDir()
+	for _, path := range []string{"triton/ABC/kernel.json", "triton/ABC/kernel.cubin", "triton/DEF/kernel.json",
+		"vllm/rank_0_0/index.py", "vllm/rank_0_0/other.py", "unused/x.py"} {
+		full := filepath.Join(src, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, []byte("x"), 0644))
+	}
+
+	dst := t.TempDir()
+	skipped, err := copyFiltered(src, dst, ContentFilter{Include: []string{"triton/ABC", "vllm/rank_0_0/index.py"}}, 0)
+	assert.NoError(t, err)
+
+	var paths []string
+	for _, s := range skipped {
+		paths = append(paths, s.Path)
+	}
+	assert.ElementsMatch(t, []string{"triton/DEF/", "vllm/rank_0_0/other.py", "unused/"}, paths)
+	for _, kept := range []string{"triton/ABC/kernel.json", "triton/ABC/kernel.cubin", "vllm/rank_0_0/index.py"} {
+		_, err := os.Stat(filepath.Join(dst, kept))
+		assert.NoError(t, err, kept)
+	}
+}
+
+func TestCopyFiltered_StreamsLargeFiles(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "ABC"), 0755))
+	binary := bytes.Repeat([]byte{0xab}, 4096)
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "ABC", "kernel.cubin"), binary, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "ABC", "kernel.json"), make([]byte, 4096), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "ABC", "kernel.ptx"), []byte("small"), 0644))
+
+	dst := t.TempDir()
+	_, err := copyFiltered(src, dst, ContentFilter{}, 1024)
+	assert.NoError(t, err)
+
+	// Only the large binary is linked; metadata may be rewritten, so it is
+	// always copied.
+	for name, linked := range map[string]bool{"kernel.cubin": true, "kernel.json": false, "kernel.ptx": false} {
+		info, err := os.Lstat(filepath.Join(dst, "ABC", name))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, linked, info.Mode()&os.ModeSymlink != 0, name)
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeTar(&buf, []layerEntry{{Src: dst, Dest: "io.triton.cache"}}))
+	tr := tar.NewReader(&buf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			assert.Fail(t, "streamed file missing from the layer")
+			return
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		if h.Name == "io.triton.cache/ABC/kernel.cubin" {
+			assert.Equal(t, byte(tar.TypeReg), h.Typeflag)
+			data, err := io.ReadAll(tr)
+			assert.NoError(t, err)
+			assert.Equal(t, binary, data)
+			return
+		}
+	}
+}
+
+func TestContentFilterValidate(t *testing.T) {
+	assert.NoError(t, ContentFilter{Exclude: []string{"*.tmp", "ABC/*"}}.Validate())
+	assert.Error(t, ContentFilter{Exclude: []string{"[abc"}}.Validate())
+
+	n, err := ParseFileSize("512M")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(512*1024*1024), n)
+	_, err = ParseFileSize("lots")
+	assert.Error(t, err)
+}
+
+func TestContentFilterForComponent(t *testing.T) {
+	f := ContentFilter{Include: []string{"triton/ABC", "vllm/rank_0_0/index.py"}}
+	assert.Equal(t, []string{"ABC"}, f.forComponent("/cache", "/cache/triton").Include)
+	assert.Equal(t, []string{}, f.forComponent("/cache", "/cache/flashinfer").Include)
+	assert.Nil(t, ContentFilter{Include: []string{"."}}.forComponent("/cache", "/cache/triton").Include)
+	assert.Nil(t, ContentFilter{}.forComponent("/cache", "/cache/triton").Include)
+}
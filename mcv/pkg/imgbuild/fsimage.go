@@ -0,0 +1,100 @@
+package imgbuild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+)
+
+// fsImageTools are the programs that build each filesystem image format.
+var fsImageTools = map[string]string{
+	cache.FSImageSquashfs: "mksquashfs",
+	cache.FSImageErofs:    "mkfs.erofs",
+}
+
+// buildFSImage writes the contents of dir to out as a filesystem image.
+// Files are owned by root, as in tar layers.
+func buildFSImage(format, dir, out string) error {
+	tool := fsImageTools[format]
+	if tool == "" {
+		return cache.ValidateFSImageFormat(format)
+	}
+	if !HasApp(tool) {
+		return fmt.Errorf("%s images need %s, which was not found", format, tool)
+	}
+	var cmd *exec.Cmd
+	switch format {
+	case cache.FSImageSquashfs:
+		cmd = exec.Command(tool, dir, out, "-noappend", "-all-root", "-no-progress")
+	case cache.FSImageErofs:
+		cmd = exec.Command(tool, "--all-root", "-T0", out, dir)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", tool, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// appendFSImageLayer adds the cache of prep as a filesystem image layer.
+func appendFSImageLayer(base v1.Image, prep *buildContext, format, imageName string, created time.Time) (v1.Image, error) {
+	out := filepath.Join(prep.BuildRoot, "cache."+format)
+	if err := buildFSImage(format, prep.CacheBuildDir, out); err != nil {
+		return nil, err
+	}
+	layer, err := newFileLayer(out, types.MediaType(cache.FSImageMediaType(format)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s cache layer: %w", format, err)
+	}
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer: layer,
+		History: v1.History{
+			Created:   v1.Time{Time: created},
+			CreatedBy: "mcv create " + imageName,
+			Comment:   fmt.Sprintf("%s cache image", format),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append %s cache layer: %w", format, err)
+	}
+	return img, nil
+}
+
+// fileLayer is a layer stored as is in a file, such as a filesystem image,
+// rather than as a compressed tar archive.
+type fileLayer struct {
+	path      string
+	digest    v1.Hash
+	size      int64
+	mediaType types.MediaType
+}
+
+func newFileLayer(path string, mediaType types.MediaType) (*fileLayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	digest, size, err := v1.SHA256(f)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLayer{path: path, digest: digest, size: size, mediaType: mediaType}, nil
+}
+
+func (l *fileLayer) Digest() (v1.Hash, error)             { return l.digest, nil }
+func (l *fileLayer) DiffID() (v1.Hash, error)             { return l.digest, nil }
+func (l *fileLayer) Compressed() (io.ReadCloser, error)   { return os.Open(l.path) }
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error) { return os.Open(l.path) }
+func (l *fileLayer) Size() (int64, error)                 { return l.size, nil }
+func (l *fileLayer) MediaType() (types.MediaType, error)  { return l.mediaType, nil }
+
+var _ v1.Layer = (*fileLayer)(nil)
@@ -0,0 +1,86 @@
+package imgbuild
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssembleImage_FSImage(t *testing.T) {
+	// A stand-in for mksquashfs that archives the directory.
+	bin := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "mksquashfs"), []byte("#!/bin/sh\ntar -C \"$1\" -cf \"$2\" .\n"), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "io.triton.cache")
+	manifestDir := filepath.Join(root, "io.triton.manifest")
+	assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "ABC"), 0755))
+	assert.NoError(t, os.MkdirAll(manifestDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, "ABC", "kernel.json"), []byte(`{"hash":"abc"}`), 0644))
+	manifestPath := filepath.Join(manifestDir, "manifest.json")
+	assert.NoError(t, os.WriteFile(manifestPath, []byte(`{"triton":[]}`), 0644))
+
+	prep := &buildContext{
+		Labels:           map[string]string{},
+		ManifestTag:      "io.triton.manifest",
+		CacheTag:         "io.triton.cache/",
+		CacheBuildDir:    cacheDir,
+		ManifestBuildDir: manifestDir,
+		ManifestPath:     manifestPath,
+		BuildRoot:        root,
+	}
+	img, err := assembleImage("quay.io/example/cache:v1", prep, BuildOptions{FSImage: cache.FSImageSquashfs})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cfg, err := img.ConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.FSImageSquashfs, cfg.Config.Labels[cache.FSImageLabel])
+
+	layers, err := img.Layers()
+	assert.NoError(t, err)
+	if !assert.Len(t, layers, 2) {
+		return
+	}
+
+	// The tar layer holds the manifest only, the cache is in the image.
+	rc, err := layers[0].Uncompressed()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+	var names []string
+	tr := tar.NewReader(rc)
+	for h, err := tr.Next(); err != io.EOF; h, err = tr.Next() {
+		if !assert.NoError(t, err) {
+			return
+		}
+		names = append(names, h.Name)
+	}
+	assert.Contains(t, names, "io.triton.manifest/manifest.json")
+	assert.NotContains(t, names, "io.triton.cache/ABC/kernel.json")
+
+	mt, err := layers[1].MediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, types.MediaType(cache.FSImageMediaType(cache.FSImageSquashfs)), mt)
+	assert.Equal(t, cache.FSImageSquashfs, cache.FSImageFormat(string(mt)))
+
+	digest, err := layers[1].Digest()
+	assert.NoError(t, err)
+	diffID, err := layers[1].DiffID()
+	assert.NoError(t, err)
+	assert.Equal(t, digest, diffID)
+}
+
+func TestNew_FSImageNeedsNative(t *testing.T) {
+	_, err := New(BuilderBuildah, BuildOptions{FSImage: cache.FSImageErofs})
+	assert.ErrorContains(t, err, "native")
+}
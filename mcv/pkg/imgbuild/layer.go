@@ -0,0 +1,185 @@
+package imgbuild
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// layerEntry maps a directory (or file) on disk to a path inside the layer.
+type layerEntry struct {
+	Src  string
+	Dest string
+}
+
+// cacheLayerEntries returns the layer layout MCV requires: the manifest
+// under the manifest tag, the cache contents under the cache tag, hot
+// entries first, and any user-supplied extra copies. withCache false leaves
+// the cache out, for caches stored in a filesystem image layer.
+func cacheLayerEntries(prep *buildContext, withCache bool) []layerEntry {
+	entries := []layerEntry{
+		{Src: prep.ManifestPath, Dest: filepath.Join(layerPath(prep.ManifestTag), "manifest.json")},
+	}
+	if withCache {
+		entries = append(entries, cacheEntries(prep.CacheBuildDir, prep.CacheTag, prep.HotDirs)...)
+	}
+	for _, c := range prep.ExtraCopies {
+		entries = append(entries, layerEntry{
+			Src:  filepath.Join(prep.BuildRoot, c.ContextPath),
+			Dest: layerPath(c.Dest),
+		})
+	}
+	return entries
+}
+
+// componentLayerEntries returns the layer layout of a colocated cache: its
+// manifest and the cache.
+func componentLayerEntries(c cacheComponent) []layerEntry {
+	return append([]layerEntry{
+		{Src: c.ManifestPath, Dest: filepath.Join(layerPath(c.ManifestTag), "manifest.json")},
+	}, cacheEntries(c.CacheBuildDir, c.CacheTag, c.HotDirs)...)
+}
+
+// cacheEntries returns the entries of the cache staged in dir: the hot
+// entry directories first, then the rest, which writeTar does not write
+// again. The manifest goes ahead of them so extraction knows the hot
+// entries when it reaches them.
+func cacheEntries(dir, tag string, hotDirs []string) []layerEntry {
+	var entries []layerEntry
+	for _, d := range hotDirs {
+		entries = append(entries, layerEntry{
+			Src:  filepath.Join(dir, filepath.FromSlash(d)),
+			Dest: filepath.Join(layerPath(tag), filepath.FromSlash(d)),
+		})
+	}
+	return append(entries, layerEntry{Src: dir, Dest: layerPath(tag)})
+}
+
+// layerPath converts a tag such as "./io.vllm.cache" or "io.triton.cache/"
+// into a clean relative tar path.
+func layerPath(p string) string {
+	p = strings.TrimPrefix(filepath.Clean("/"+p), "/")
+	return p
+}
+
+// newTarLayer returns an OCI layer that streams the given entries from disk
+// each time the layer content is read, compressing them with c.
+func newTarLayer(entries []layerEntry, c Compression) (v1.Layer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	opener := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeCompressedTar(pw, entries, c))
+		}()
+		return pr, nil
+	}
+	return tarball.LayerFromOpener(opener,
+		tarball.WithMediaType(c.MediaType()),
+		tarball.WithCompression(c.ggcrCompression()),
+		tarball.WithCompressionLevel(c.Level))
+}
+
+// writeCompressedTar compresses the tar stream here rather than leaving it
+// to go-containerregistry, whose gzip encoder is single-threaded.
+func writeCompressedTar(w io.Writer, entries []layerEntry, c Compression) error {
+	cw, err := c.newWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := writeTar(cw, entries); err != nil {
+		cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+func writeTar(w io.Writer, entries []layerEntry) error {
+	tw := tar.NewWriter(w)
+	written := map[string]bool{}
+	for _, e := range entries {
+		if err := addParents(tw, e.Dest, written); err != nil {
+			return err
+		}
+		if err := addPath(tw, e.Src, e.Dest, written); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// addParents writes directory headers for every parent of dest so the layer
+// extracts cleanly with any tar implementation.
+func addParents(tw *tar.Writer, dest string, written map[string]bool) error {
+	dir := filepath.Dir(dest)
+	if dir == "." || dir == "/" || written[dir] {
+		return nil
+	}
+	if err := addParents(tw, dir, written); err != nil {
+		return err
+	}
+	written[dir] = true
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0755,
+	})
+}
+
+func addPath(tw *tar.Writer, src, dest string, written map[string]bool) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(dest, rel))
+		if written[name] {
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			// Links to files are staged cache files streamed from their
+			// source; other links are left out.
+			st, err := os.Stat(path)
+			if err != nil || !st.Mode().IsRegular() {
+				return nil
+			}
+			info = st
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+		written[name] = true
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
@@ -0,0 +1,337 @@
+package imgbuild
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/chunk"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	logging "github.com/sirupsen/logrus"
+)
+
+// nativeBuilder assembles the image directly with go-containerregistry,
+// without a Dockerfile, container engine or user namespace.
+type nativeBuilder struct {
+	opts   BuildOptions
+	digest string
+}
+
+func (n *nativeBuilder) ImageDigest() string {
+	return n.digest
+}
+
+func (n *nativeBuilder) CreateImage(imageName, cacheDir string) error {
+	prep, err := prepareBuildContext("native", cacheDir, n.opts)
+	if err != nil {
+		return err
+	}
+	defer CleanupDirs(prep.stagedDirs()...)
+
+	img, err := assembleImage(imageName, prep, n.opts)
+	if err != nil {
+		return err
+	}
+	keepImage(img, prep)
+
+	imageWithTag := NormalizeImageTag(imageName)
+	if err = imgstore.Save(img, imageWithTag); err != nil {
+		return err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to compute image digest: %w", err)
+	}
+	n.digest = digest.String()
+	logging.Infof("Image built! %s@%s (stored in %s)", imageWithTag, digest, imgstore.Path())
+
+	// Cleanup
+	if err := CleanupWithTimeout(); err != nil {
+		return fmt.Errorf("cleanup error: %w", err)
+	}
+	return nil
+}
+
+// assembleImage appends the cache layer to the base image and sets the
+// labels and annotations MCV requires.
+func assembleImage(imageName string, prep *buildContext, opts BuildOptions) (v1.Image, error) {
+	comp := opts.Compression
+	if err := comp.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.FSImage != "" && (len(prep.Components) > 1 || len(prep.ChunkPacks) > 0) {
+		return nil, fmt.Errorf("%s cache images cannot hold colocated caches or chunked layers", opts.FSImage)
+	}
+	base, err := baseImage(opts.BaseImage)
+	if err != nil {
+		return nil, err
+	}
+
+	created := time.Now()
+	labels := provenanceLabels(opts)
+	for k, v := range prep.Labels {
+		labels[k] = v
+	}
+
+	// Chunk packs go first: they change least between builds, so registries
+	// and clients can reuse them when only a few chunks changed.
+	for i, pack := range prep.ChunkPacks {
+		base, err = appendChunkLayer(base, prep.ChunkStore, pack, comp, created)
+		if err != nil {
+			return nil, fmt.Errorf("failed to append chunk layer %d: %w", i+1, err)
+		}
+	}
+
+	// Oversize summaries go in their own small layer ahead of the cache
+	// layer, so readers can fetch them without pulling the cache, and the
+	// cache stays in the last layer.
+	if len(prep.Summaries) > 0 {
+		base, err = appendSummaryLayer(base, prep.Summaries, labels, comp, created)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Colocated caches of other types get a layer each, so extraction of
+	// one type skips the others.
+	for _, c := range prep.colocated() {
+		base, err = appendComponentLayer(base, c, comp, imageName, created)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// With a filesystem image, the tar layer keeps the manifest and extra
+	// copies, the cache goes in the filesystem image layer on top.
+	entries := cacheLayerEntries(prep, opts.FSImage == "")
+	if opts.FSImage != "" {
+		labels[cache.FSImageLabel] = opts.FSImage
+	}
+	layer, err := newTarLayer(entries, comp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache layer: %w", err)
+	}
+
+	comment := cacheLayerComment(prep)
+	if opts.FSImage != "" {
+		comment = "cache manifest layer"
+	}
+	var layerAnnotations map[string]string
+	if len(prep.Components) > 1 {
+		primary := prep.Components[0].Cache
+		comment = layerComment([]cache.Cache{primary}, prep.ExtraCopies)
+		layerAnnotations = map[string]string{cache.LayerTypeAnnotation: primary.Name()}
+	}
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:       layer,
+		MediaType:   comp.MediaType(),
+		Annotations: layerAnnotations,
+		History: v1.History{
+			Created:   v1.Time{Time: created},
+			CreatedBy: "mcv create " + imageName,
+			Comment:   comment,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append cache layer: %w", err)
+	}
+	if opts.FSImage != "" {
+		if img, err = appendFSImageLayer(img, prep, opts.FSImage, imageName, created); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image config: %w", err)
+	}
+	cfg = cfg.DeepCopy()
+	if cfg.Config.Labels == nil {
+		cfg.Config.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		cfg.Config.Labels[k] = v
+	}
+	cfg.Config.Labels[AnnotationTitle] = imageTitle(imageName)
+	cfg.Created = v1.Time{Time: created}
+	if cfg.OS == "" {
+		cfg.OS = "linux"
+	}
+	if cfg.Architecture == "" {
+		cfg.Architecture = runtime.GOARCH
+	}
+
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set image config: %w", err)
+	}
+
+	return mutate.Annotations(img, buildAnnotations(imageName, prep, opts, created)).(v1.Image), nil
+}
+
+// appendSummaryLayer adds a layer holding the external summaries and points
+// their labels at it.
+func appendSummaryLayer(base v1.Image, summaries []externalSummary, labels map[string]string, c Compression, created time.Time) (v1.Image, error) {
+	entries := make([]layerEntry, 0, len(summaries))
+	for _, s := range summaries {
+		entries = append(entries, layerEntry{Src: s.Src, Dest: s.Dest})
+	}
+	layer, err := newTarLayer(entries, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create summary layer: %w", err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute summary layer digest: %w", err)
+	}
+	for _, s := range summaries {
+		labels[s.LabelKey] = cache.EncodeSummaryPointer(cache.SummaryPointer{
+			Path:   s.Dest,
+			Digest: digest.String(),
+			Size:   s.Size,
+		})
+	}
+
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:     layer,
+		MediaType: c.MediaType(),
+		History: v1.History{
+			Created:   v1.Time{Time: created},
+			CreatedBy: "mcv create",
+			Comment:   "cache summary layer",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append summary layer: %w", err)
+	}
+	return img, nil
+}
+
+// appendComponentLayer adds a layer holding the colocated cache c and its
+// manifest, annotated with its cache type.
+func appendComponentLayer(base v1.Image, c cacheComponent, comp Compression, imageName string, created time.Time) (v1.Image, error) {
+	layer, err := newTarLayer(componentLayerEntries(c), comp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s cache layer: %w", c.Cache.Name(), err)
+	}
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:       layer,
+		MediaType:   comp.MediaType(),
+		Annotations: map[string]string{cache.LayerTypeAnnotation: c.Cache.Name()},
+		History: v1.History{
+			Created:   v1.Time{Time: created},
+			CreatedBy: "mcv create " + imageName,
+			Comment:   layerComment([]cache.Cache{c.Cache}, nil),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append %s cache layer: %w", c.Cache.Name(), err)
+	}
+	return img, nil
+}
+
+// appendChunkLayer adds a layer holding the given chunks from storeDir.
+func appendChunkLayer(base v1.Image, storeDir string, pack []string, c Compression, created time.Time) (v1.Image, error) {
+	entries := make([]layerEntry, 0, len(pack))
+	for _, d := range pack {
+		algo, hexDigest, _ := strings.Cut(d, ":")
+		entries = append(entries, layerEntry{
+			Src:  chunk.ChunkPath(storeDir, d),
+			Dest: path.Join(chunk.LayerDir, algo, hexDigest),
+		})
+	}
+	layer, err := newTarLayer(entries, c)
+	if err != nil {
+		return nil, err
+	}
+	return mutate.Append(base, mutate.Addendum{
+		Layer:     layer,
+		MediaType: c.MediaType(),
+		History: v1.History{
+			Created:   v1.Time{Time: created},
+			CreatedBy: "mcv create",
+			Comment:   fmt.Sprintf("cache chunk layer (%d chunks)", len(pack)),
+		},
+	})
+}
+
+func baseImage(ref string) (v1.Image, error) {
+	if ref == "" || ref == DefaultBaseImage {
+		img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+		return mutate.ConfigMediaType(img, types.OCIConfigJSON), nil
+	}
+
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base image %s: %w", ref, err)
+	}
+	// The layers are read while building, under the same deadline.
+	ctx, _ := registry.Context(context.Background(), registry.OpPull)
+	img, err := remote.Image(parsed, registry.Options(remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch base image %s: %w", ref, registry.TimeoutError(ctx, err))
+	}
+	return ociImage(img)
+}
+
+// ociLayerTypes maps Docker layer media types to their OCI equivalents.
+var ociLayerTypes = map[types.MediaType]types.MediaType{
+	types.DockerLayer:             types.OCILayer,
+	types.DockerUncompressedLayer: types.OCIUncompressedLayer,
+	types.DockerForeignLayer:      types.OCIRestrictedLayer,
+}
+
+// ociImage returns img as an OCI image: the layers mcv appends are OCI
+// layers, which a Docker schema 2 manifest cannot hold, and extraction
+// rejects. The layers and config are kept; only the media types change.
+func ociImage(img v1.Image) (v1.Image, error) {
+	mt, err := img.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image media type: %w", err)
+	}
+	if mt != types.DockerManifestSchema2 {
+		return img, nil
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image config: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image layers: %w", err)
+	}
+	out := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	for _, l := range layers {
+		lmt, err := l.MediaType()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read base image layer media type: %w", err)
+		}
+		if oci, ok := ociLayerTypes[lmt]; ok {
+			lmt = oci
+		}
+		if out, err = mutate.Append(out, mutate.Addendum{Layer: l, MediaType: lmt}); err != nil {
+			return nil, fmt.Errorf("failed to convert base image: %w", err)
+		}
+	}
+	// The base config, with its history and diff IDs, stays as it was.
+	return mutate.ConfigFile(out, cfg.DeepCopy())
+}
+
+func imageTitle(imageName string) string {
+	parts := strings.Split(imageName, "/")
+	return strings.Split(parts[len(parts)-1], ":")[0]
+}
+
+var _ ImageBuilder = (*nativeBuilder)(nil)
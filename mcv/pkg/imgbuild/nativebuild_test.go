@@ -0,0 +1,295 @@
+package imgbuild
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/preflightcheck"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssembleImage(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "io.triton.cache")
+	manifestDir := filepath.Join(root, "io.triton.manifest")
+	assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "ABC"), 0755))
+	assert.NoError(t, os.MkdirAll(manifestDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, "ABC", "kernel.json"), []byte(`{"hash":"abc"}`), 0644))
+	manifestPath := filepath.Join(manifestDir, "manifest.json")
+	assert.NoError(t, os.WriteFile(manifestPath, []byte(`{"triton":[]}`), 0644))
+
+	prep := &buildContext{
+		Labels:           map[string]string{"cache.triton.image/entry-count": "1"},
+		ManifestTag:      "io.triton.manifest",
+		CacheTag:         "io.triton.cache/",
+		CacheBuildDir:    cacheDir,
+		ManifestBuildDir: manifestDir,
+		ManifestPath:     manifestPath,
+		BuildRoot:        root,
+	}
+
+	img, err := assembleImage("quay.io/example/cache:v1", prep, BuildOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mt, err := img.MediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, types.OCIManifestSchema1, mt)
+
+	cfg, err := img.ConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", cfg.Config.Labels["cache.triton.image/entry-count"])
+	assert.Equal(t, "cache", cfg.Config.Labels["org.opencontainers.image.title"])
+
+	layers, err := img.Layers()
+	assert.NoError(t, err)
+	if !assert.Len(t, layers, 1) {
+		return
+	}
+
+	lmt, err := layers[0].MediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, types.OCILayer, lmt)
+
+	rc, err := layers[0].Uncompressed()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	var names []string
+	tr := tar.NewReader(rc)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		names = append(names, h.Name)
+	}
+	assert.Contains(t, names, "io.triton.cache/ABC/kernel.json")
+	assert.Contains(t, names, "io.triton.manifest/manifest.json")
+}
+
+func TestAssembleImage_ExternalSummary(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "io.triton.cache")
+	assert.NoError(t, os.MkdirAll(cacheDir, 0755))
+	manifestPath := filepath.Join(root, "manifest.json")
+	assert.NoError(t, os.WriteFile(manifestPath, []byte(`{"triton":[]}`), 0644))
+
+	summary := `{"targets":[{"backend":"cuda","arch":"80","warp_size":32}]}`
+	summaryPath := filepath.Join(root, "summary.json")
+	assert.NoError(t, os.WriteFile(summaryPath, []byte(summary), 0644))
+
+	key := "cache.triton.image/summary"
+	prep := &buildContext{
+		Labels:        map[string]string{key: cache.EncodeSummaryPointer(cache.SummaryPointer{Path: cache.SummaryFilePath("triton")})},
+		ManifestTag:   "io.triton.manifest",
+		CacheTag:      "io.triton.cache/",
+		CacheBuildDir: cacheDir,
+		ManifestPath:  manifestPath,
+		BuildRoot:     root,
+		Summaries: []externalSummary{{
+			LabelKey: key,
+			Src:      summaryPath,
+			Dest:     cache.SummaryFilePath("triton"),
+			Size:     len(summary),
+		}},
+	}
+
+	img, err := assembleImage("quay.io/example/cache:v1", prep, BuildOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The summary layer comes first so the cache stays in the last layer.
+	layers, err := img.Layers()
+	assert.NoError(t, err)
+	assert.Len(t, layers, 2)
+
+	cfg, err := img.ConfigFile()
+	assert.NoError(t, err)
+	_, ptr, err := cache.ParseSummaryLabel(cfg.Config.Labels[key])
+	assert.NoError(t, err)
+	if !assert.NotNil(t, ptr) {
+		return
+	}
+	first, _ := layers[0].Digest()
+	assert.Equal(t, first.String(), ptr.Digest)
+
+	loaded, err := preflightcheck.LoadSummary(img, cfg.Config.Labels)
+	assert.NoError(t, err)
+	if assert.NotNil(t, loaded) && assert.Len(t, loaded.Targets, 1) {
+		assert.Equal(t, "80", loaded.Targets[0].Arch)
+	}
+}
+
+func TestAssembleImage_ColocatedCaches(t *testing.T) {
+	root := t.TempDir()
+	var components []cacheComponent
+	for _, name := range []string{"vllm", "triton"} {
+		c := &fakeCache{name: name}
+		cacheDir := filepath.Join(root, c.CacheTag())
+		manifestDir := filepath.Join(root, c.ManifestTag())
+		assert.NoError(t, os.MkdirAll(cacheDir, 0755))
+		assert.NoError(t, os.MkdirAll(manifestDir, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, name+".json"), []byte("{}"), 0644))
+		manifestPath := filepath.Join(manifestDir, "manifest.json")
+		assert.NoError(t, os.WriteFile(manifestPath, []byte("{}"), 0644))
+		components = append(components, cacheComponent{
+			Cache:            c,
+			CacheTag:         c.CacheTag(),
+			CacheBuildDir:    cacheDir,
+			ManifestTag:      c.ManifestTag(),
+			ManifestBuildDir: manifestDir,
+			ManifestPath:     manifestPath,
+		})
+	}
+	primary := components[0]
+	prep := &buildContext{
+		Caches:           []cache.Cache{components[0].Cache, components[1].Cache},
+		Labels:           map[string]string{},
+		ManifestTag:      primary.ManifestTag,
+		CacheTag:         primary.CacheTag,
+		CacheBuildDir:    primary.CacheBuildDir,
+		ManifestBuildDir: primary.ManifestBuildDir,
+		ManifestPath:     primary.ManifestPath,
+		BuildRoot:        root,
+		Components:       components,
+	}
+
+	img, err := assembleImage("quay.io/example/cache:v1", prep, BuildOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	manifest, err := img.Manifest()
+	assert.NoError(t, err)
+	layers, err := img.Layers()
+	assert.NoError(t, err)
+	if !assert.Len(t, manifest.Layers, 2) {
+		return
+	}
+
+	// Each cache type gets its own layer holding only its cache and
+	// manifest; the first component stays in the last layer.
+	for i, name := range []string{"triton", "vllm"} {
+		assert.Equal(t, name, manifest.Layers[i].Annotations[cache.LayerTypeAnnotation])
+		rc, err := layers[i].Uncompressed()
+		if !assert.NoError(t, err) {
+			return
+		}
+		var names []string
+		tr := tar.NewReader(rc)
+		for {
+			h, err := tr.Next()
+			if err != nil {
+				break
+			}
+			if h.Typeflag == tar.TypeReg {
+				names = append(names, h.Name)
+			}
+		}
+		rc.Close()
+		assert.ElementsMatch(t, []string{"io." + name + ".cache/" + name + ".json", "io." + name + ".manifest/manifest.json"}, names)
+	}
+}
+
+func TestAssembleImage_HotEntriesFirst(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, "io.triton.cache")
+	manifestDir := filepath.Join(root, "io.triton.manifest")
+	for _, dir := range []string{"AAA", "HOT", "ZZZ"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, dir), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, dir, "kernel.cubin"), []byte(dir), 0644))
+	}
+	assert.NoError(t, os.MkdirAll(manifestDir, 0755))
+	manifestPath := filepath.Join(manifestDir, "manifest.json")
+	assert.NoError(t, os.WriteFile(manifestPath, []byte(`{"triton":[]}`), 0644))
+
+	prep := &buildContext{
+		Labels:           map[string]string{},
+		ManifestTag:      "io.triton.manifest",
+		CacheTag:         "io.triton.cache/",
+		CacheBuildDir:    cacheDir,
+		ManifestBuildDir: manifestDir,
+		ManifestPath:     manifestPath,
+		HotDirs:          []string{"HOT"},
+		BuildRoot:        root,
+	}
+	img, err := assembleImage("quay.io/example/cache:v1", prep, BuildOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	layers, err := img.Layers()
+	assert.NoError(t, err)
+	rc, err := layers[len(layers)-1].Uncompressed()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	// The manifest, then the hot entries, then the rest, each once.
+	var names []string
+	tr := tar.NewReader(rc)
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if h.Typeflag == tar.TypeReg {
+			names = append(names, h.Name)
+		}
+	}
+	assert.Equal(t, []string{
+		"io.triton.manifest/manifest.json",
+		"io.triton.cache/HOT/kernel.cubin",
+		"io.triton.cache/AAA/kernel.cubin",
+		"io.triton.cache/ZZZ/kernel.cubin",
+	}, names)
+}
+
+func TestOCIImage(t *testing.T) {
+	base, err := random.Image(64, 2)
+	assert.NoError(t, err)
+	base = mutate.MediaType(base, types.DockerManifestSchema2)
+	baseCfg, err := base.ConfigFile()
+	assert.NoError(t, err)
+
+	img, err := ociImage(base)
+	assert.NoError(t, err)
+	mt, err := img.MediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, types.OCIManifestSchema1, mt)
+	m, err := img.Manifest()
+	assert.NoError(t, err)
+	assert.Equal(t, types.OCIConfigJSON, m.Config.MediaType)
+	baseLayers, err := base.Layers()
+	assert.NoError(t, err)
+	if assert.Len(t, m.Layers, 2) {
+		for i, l := range m.Layers {
+			assert.Equal(t, types.OCILayer, l.MediaType)
+			d, err := baseLayers[i].Digest()
+			assert.NoError(t, err)
+			assert.Equal(t, d, l.Digest)
+		}
+	}
+	cfg, err := img.ConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, baseCfg.RootFS, cfg.RootFS)
+
+	// OCI images are kept as they are.
+	same, err := ociImage(img)
+	assert.NoError(t, err)
+	assert.Equal(t, img, same)
+}
@@ -0,0 +1,97 @@
+package imgbuild
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+// annotatePluginTimeout bounds how long an annotate plugin may run.
+const annotatePluginTimeout = 30 * time.Second
+
+// PluginMetadata is what an annotate plugin writes to stdout: labels and
+// annotations to embed in the image, e.g. a cost center, team or model
+// registry ID.
+type PluginMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// runAnnotatePlugin runs the executable at path with the cache manifest
+// JSON on stdin and returns the metadata it prints. The build fails if the
+// plugin fails, so images never miss the metadata an organization requires.
+func runAnnotatePlugin(path string, manifest cache.Manifest) (*PluginMetadata, error) {
+	input, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest for annotate plugin: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), annotatePluginTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("annotate plugin %s failed: %w: %s", path, err, msg)
+		}
+		return nil, fmt.Errorf("annotate plugin %s failed: %w", path, err)
+	}
+
+	var meta PluginMetadata
+	dec := json.NewDecoder(&stdout)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&meta); err != nil {
+		return nil, fmt.Errorf("invalid output from annotate plugin %s: %w", path, err)
+	}
+	for k := range meta.Labels {
+		if k == "" {
+			return nil, fmt.Errorf("annotate plugin %s returned an empty label key", path)
+		}
+	}
+	for k := range meta.Annotations {
+		if k == "" {
+			return nil, fmt.Errorf("annotate plugin %s returned an empty annotation key", path)
+		}
+	}
+	logging.Debugf("Annotate plugin %s returned %d labels and %d annotations",
+		path, len(meta.Labels), len(meta.Annotations))
+	return &meta, nil
+}
+
+// pluginLabels returns the user labels with the plugin labels added. The
+// plugin wins over --label, as it enforces organization policy.
+func pluginLabels(extra map[string]string, meta *PluginMetadata) map[string]string {
+	if meta == nil || len(meta.Labels) == 0 {
+		return extra
+	}
+	merged := make(map[string]string, len(extra)+len(meta.Labels))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range meta.Labels {
+		if old, ok := extra[k]; ok && old != v {
+			diag.Warnf(diag.PluginOverridesLabel, "Annotate plugin overrides label %s", k)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func pluginAnnotations(meta *PluginMetadata) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	return meta.Annotations
+}
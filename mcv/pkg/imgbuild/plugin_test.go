@@ -0,0 +1,50 @@
+package imgbuild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePlugin(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "plugin")
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+	return path
+}
+
+func TestRunAnnotatePlugin(t *testing.T) {
+	// The plugin echoes the cache types it was given back as a label.
+	plugin := writePlugin(t, `types=$(grep -o '"triton"' | tr -d '"')
+cat <<EOF
+{"labels": {"org.example/team": "inference", "org.example/types": "$types"},
+ "annotations": {"org.example/cost-center": "cc-42", "`+AnnotationTitle+`": "override"}}
+EOF
+`)
+	meta, err := runAnnotatePlugin(plugin, cache.Manifest{"triton": nil})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"org.example/team": "inference", "org.example/types": "triton"}, meta.Labels)
+
+	labels := pluginLabels(map[string]string{"org.example/team": "other", "user": "x"}, meta)
+	assert.Equal(t, "inference", labels["org.example/team"])
+	assert.Equal(t, "x", labels["user"])
+
+	prep := &buildContext{Caches: []cache.Cache{&fakeCache{name: "triton"}}, Annotations: meta.Annotations}
+	annotations := buildAnnotations("quay.io/example/cache:v1", prep, BuildOptions{}, time.Now())
+	assert.Equal(t, "cc-42", annotations["org.example/cost-center"])
+	assert.Equal(t, "cache", annotations[AnnotationTitle])
+}
+
+func TestRunAnnotatePluginErrors(t *testing.T) {
+	_, err := runAnnotatePlugin(writePlugin(t, "echo 'no registry ID' >&2; exit 1\n"), cache.Manifest{})
+	assert.ErrorContains(t, err, "no registry ID")
+
+	_, err = runAnnotatePlugin(writePlugin(t, `echo '{"labels": {}, "extra": true}'`+"\n"), cache.Manifest{})
+	assert.ErrorContains(t, err, "invalid output")
+
+	_, err = runAnnotatePlugin(filepath.Join(t.TempDir(), "missing"), cache.Manifest{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package imgbuild
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+)
+
+// Secret scan policies applied to the cache before packaging.
+const (
+	SecretScanOff    = "off"
+	SecretScanWarn   = "warn"
+	SecretScanRedact = "redact"
+	SecretScanFail   = "fail"
+)
+
+// maxScanFileSize bounds the files inspected by the secret scan. Metadata
+// files are small; anything larger is a compiled artifact.
+const maxScanFileSize = 8 << 20
+
+// SecretScanPolicies returns the supported secret scan policies.
+func SecretScanPolicies() []string {
+	return []string{SecretScanOff, SecretScanWarn, SecretScanRedact, SecretScanFail}
+}
+
+// ValidateSecretScanPolicy checks policy is supported. Empty means off.
+func ValidateSecretScanPolicy(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	for _, p := range SecretScanPolicies() {
+		if policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported secret scan policy %q (supported: %s)", policy, strings.Join(SecretScanPolicies(), ", "))
+}
+
+// SecretFinding is a likely secret found in a cache file. The matched value
+// itself is not kept so findings can be logged safely.
+type SecretFinding struct {
+	Path string
+	Line int
+	Kind string
+}
+
+type secretRule struct {
+	kind    string
+	re      *regexp.Regexp // Matched line by line to report findings
+	redact  *regexp.Regexp // Matched across the file when redacting, defaults to re
+	replace string
+}
+
+var secretRules = []secretRule{
+	{
+		kind:    "private key",
+		re:      regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+		redact:  regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`),
+		replace: "<redacted private key>",
+	},
+	{
+		kind:    "AWS access key",
+		re:      regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+		replace: "<redacted>",
+	},
+	{
+		kind:    "GitHub token",
+		re:      regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+		replace: "<redacted>",
+	},
+	{
+		kind:    "Hugging Face token",
+		re:      regexp.MustCompile(`\bhf_[A-Za-z0-9]{30,}\b`),
+		replace: "<redacted>",
+	},
+	{
+		kind:    "bearer token",
+		re:      regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/=-]{16,}`),
+		replace: "${1}<redacted>",
+	},
+	{
+		kind:    "credential assignment",
+		re:      regexp.MustCompile(`(?i)((?:api[_-]?key|access[_-]?token|auth[_-]?token|secret[_-]?key|client[_-]?secret|password|passwd|hf[_-]?token)["']?\s*[:=]\s*["']?)([^\s"',}<]{8,})`),
+		replace: "${1}<redacted>",
+	},
+	{
+		kind:    "home directory path",
+		re:      regexp.MustCompile(`(/home|/Users)/[A-Za-z0-9._-]+`),
+		replace: "${1}/<user>",
+	},
+}
+
+// scanSecrets inspects the text files under dir for likely secrets and
+// user-identifying paths. With the redact policy matches are rewritten in
+// place, so dir must be the staged copy, never the user's cache.
+func scanSecrets(dir, policy string) ([]SecretFinding, error) {
+	if policy == "" || policy == SecretScanOff {
+		return nil, nil
+	}
+
+	var findings []SecretFinding
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || info.Size() > maxScanFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if isBinary(data) {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+
+		found := findSecrets(rel, data)
+		if len(found) == 0 {
+			return nil
+		}
+		findings = append(findings, found...)
+		if policy == SecretScanRedact {
+			for _, r := range secretRules {
+				re := r.re
+				if r.redact != nil {
+					re = r.redact
+				}
+				data = re.ReplaceAll(data, []byte(r.replace))
+			}
+			return os.WriteFile(path, data, info.Mode())
+		}
+		return nil
+	})
+	return findings, err
+}
+
+func findSecrets(rel string, data []byte) []SecretFinding {
+	var findings []SecretFinding
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxScanFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		for _, r := range secretRules {
+			if r.re.Match(scanner.Bytes()) {
+				findings = append(findings, SecretFinding{Path: rel, Line: line, Kind: r.kind})
+			}
+		}
+	}
+	return findings
+}
+
+// isBinary treats content with a NUL byte near the start as binary.
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// applySecretScan runs the scan over the staged cache and enforces policy.
+func applySecretScan(dir, policy string) error {
+	findings, err := scanSecrets(dir, policy)
+	if err != nil {
+		return fmt.Errorf("secret scan failed: %w", err)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	for _, f := range findings {
+		diag.Warnf(diag.PossibleSecret, "Possible %s in %s:%d", f.Kind, f.Path, f.Line)
+	}
+	switch policy {
+	case SecretScanFail:
+		return fmt.Errorf("secret scan found %d possible secret(s) in the cache; remove them or use --secret-scan=redact", len(findings))
+	case SecretScanRedact:
+		diag.Warnf(diag.SecretsRedacted, "Redacted %d possible secret(s) from the packaged cache", len(findings))
+	}
+	return nil
+}
@@ -1,17 +1,66 @@
 package imgbuild
 
-import "github.com/redhat-et/MCU/mcv/pkg/cache"
-
-const DockerfileTemplate = `FROM scratch
-LABEL org.opencontainers.image.title={{ .ImageTitle }}
-COPY "./{{ .CacheDir }}." "./{{ .CacheDir }}"
-COPY "./{{ .ManifestDir }}/manifest.json" "./{{ .ManifestDir }}/manifest.json"
-`
-
-type DockerfileData struct {
-	ImageTitle  string
-	CacheDir    string
-	ManifestDir string
+import (
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+)
+
+const DefaultBaseImage = "scratch"
+
+// CopySpec describes an additional file or directory to add to the image.
+// Src is a host path, Dest is the path inside the image. ContextPath is
+// filled in when the source is staged into the build context.
+type CopySpec struct {
+	Src         string
+	Dest        string
+	ContextPath string
+}
+
+// BuildOptions holds user customizations applied on top of the layout MCV
+// requires. MCV labels and the cache/manifest copies are always injected
+// and cannot be overridden.
+type BuildOptions struct {
+	BaseImage   string            // Base image, defaults to scratch
+	ExtraLabels map[string]string // Additional image labels
+	ExtraCopies []CopySpec        // Additional files/directories to add
+	Compression Compression       // Layer compression, native builder only
+	Filter      ContentFilter     // Cache files to leave out of the image
+	SecretScan  string            // Secret scan policy: off, warn, redact or fail
+
+	// AnnotatePlugin is an executable run with the cache manifest JSON on
+	// stdin that prints extra labels and annotations to embed.
+	AnnotatePlugin string
+
+	// VLLMPython is the python interpreter of the vLLM installation that
+	// built a vLLM cache, probed for the versions vLLM keys its cache on.
+	// Empty records none.
+	VLLMPython string
+
+	TritonDumpDir     string // Triton dump directory to package with the cache
+	TritonOverrideDir string // Triton override directory to package with the cache
+
+	Source   string // URL of the sources the cache was built from
+	Revision string // Source revision, e.g. a git commit
+
+	// FSImage stores the cache as a squashfs or erofs filesystem image
+	// layer that extraction can mount instead of unpacking. Empty stores a
+	// tar layer. Native builder only.
+	FSImage string
+
+	// ValidUntil, if set, is recorded as the image's expiry, so extraction
+	// can warn about or block stale caches.
+	ValidUntil time.Time
+
+	// ChunkThreshold enables content-defined chunking of cache files of at
+	// least this many bytes, stored in separate deduplicated layers. 0
+	// disables chunking.
+	ChunkThreshold int64
+
+	// HotKernels are patterns of Triton kernel names, e.g. "*attn*", whose
+	// entries are marked hot in the manifest and written first in the
+	// cache layer, so extraction restores them first. Native builder only.
+	HotKernels []string
 }
 
 type buildContext struct {
@@ -22,5 +71,56 @@ type buildContext struct {
 	CacheBuildDir    string
 	ManifestBuildDir string
 	ManifestPath     string
+	HotDirs          []string // Hot entry directories, relative to CacheBuildDir
 	BuildRoot        string
+	ExtraCopies      []CopySpec
+	Summaries        []externalSummary
+	Skipped          []SkippedFile
+	ChunkStore       string            // Directory holding the chunks of chunked files
+	ChunkPacks       [][]string        // Chunk digests grouped into one layer each
+	Annotations      map[string]string // Extra annotations from the annotate plugin
+
+	// Components holds every staged cache type. The first is also described
+	// by the cache and manifest fields above; colocated caches of other
+	// types follow and get a layer each.
+	Components []cacheComponent
+}
+
+// cacheComponent is one cache type staged with its own manifest.
+type cacheComponent struct {
+	Cache            cache.Cache
+	CacheTag         string
+	CacheBuildDir    string
+	ManifestTag      string
+	ManifestBuildDir string
+	ManifestPath     string
+	HotDirs          []string
+}
+
+// colocated returns the staged caches packaged next to the first one.
+func (b *buildContext) colocated() []cacheComponent {
+	if len(b.Components) < 2 {
+		return nil
+	}
+	return b.Components[1:]
+}
+
+// stagedDirs returns the build directories to remove once the image is
+// built.
+func (b *buildContext) stagedDirs() []string {
+	dirs := []string{b.CacheBuildDir, b.ManifestBuildDir}
+	for _, c := range b.colocated() {
+		dirs = append(dirs, c.CacheBuildDir, c.ManifestBuildDir)
+	}
+	return dirs
+}
+
+// externalSummary is a cache summary too large for an image label. It is
+// staged at Src and stored in the image at Dest, and the label at LabelKey
+// is replaced with a cache.SummaryPointer.
+type externalSummary struct {
+	LabelKey string
+	Src      string
+	Dest     string
+	Size     int
 }
@@ -2,122 +2,414 @@ package imgbuild
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
+	units "github.com/docker/go-units"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/chunk"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
 	"github.com/redhat-et/MCU/mcv/pkg/utils"
 	logging "github.com/sirupsen/logrus"
 )
 
-func GenerateDockerfile(imageName, cacheDir, manifestDir, outputPath string) error {
-	parts := strings.Split(imageName, "/")
-	fullImageName := parts[len(parts)-1]
-	imageTitle := strings.Split(fullImageName, ":")[0]
+// ParseLabels converts key=value pairs into a label map.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", p)
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
 
-	data := DockerfileData{
-		ImageTitle:  imageTitle,
-		CacheDir:    cacheDir,
-		ManifestDir: manifestDir,
+// ParseCopySpecs converts src:dest pairs into copy specs.
+func ParseCopySpecs(pairs []string) ([]CopySpec, error) {
+	specs := make([]CopySpec, 0, len(pairs))
+	for _, p := range pairs {
+		src, dest, ok := strings.Cut(p, ":")
+		if !ok || src == "" || dest == "" {
+			return nil, fmt.Errorf("invalid copy %q, expected src:dest", p)
+		}
+		specs = append(specs, CopySpec{Src: src, Dest: dest})
 	}
+	return specs, nil
+}
 
-	tmpl, err := template.New("dockerfile").Parse(DockerfileTemplate)
-	if err != nil {
-		return fmt.Errorf("error parsing template: %w", err)
+// stageExtraCopies copies user-supplied files into the build context so the
+// builders can reference them relative to the build root.
+func stageExtraCopies(buildRoot string, specs []CopySpec) ([]CopySpec, error) {
+	staged := make([]CopySpec, 0, len(specs))
+	for i, c := range specs {
+		if _, err := os.Stat(c.Src); err != nil {
+			return nil, fmt.Errorf("extra copy source %s: %w", c.Src, err)
+		}
+		ctxPath := filepath.Join("extra", strconv.Itoa(i), filepath.Base(c.Src))
+		target := filepath.Join(buildRoot, ctxPath)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := cache.CopyDir(c.Src, target); err != nil {
+			return nil, fmt.Errorf("error staging %s: %w", c.Src, err)
+		}
+		logging.Debugf("staged extra copy %s -> %s", c.Src, ctxPath)
+		staged = append(staged, CopySpec{Src: c.Src, Dest: c.Dest, ContextPath: ctxPath})
 	}
+	return staged, nil
+}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("error creating Dockerfile: %w", err)
+// mergeLabels combines user labels with the MCV labels. MCV labels always
+// take precedence.
+func mergeLabels(mcvLabels, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(mcvLabels)+len(extra))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range mcvLabels {
+		if _, ok := extra[k]; ok {
+			diag.Warnf(diag.ReservedLabel, "Ignoring user label %s: reserved by MCV", k)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// streamFileSize is the size from which cache files are streamed from the
+// source directory into the layer instead of being copied into the build
+// directory. Such files are too large for the secret scan and are not
+// metadata, so nothing rewrites them while staging.
+const streamFileSize = maxScanFileSize + 1
+
+func prepareBuildContext(buildType, cacheDir string, opts BuildOptions) (*buildContext, error) {
+	components := cache.DetectComponents(cacheDir)
+	if components == nil {
+		caches := cache.DetectCaches(cacheDir)
+		if len(caches) == 0 {
+			return nil, failure.New(failure.NoCache, fmt.Errorf("no Triton or vLLM cache found in %s", cacheDir))
+		}
+		components = []cache.Component{{Dir: cacheDir, Cache: caches[0]}}
+	}
+	var caches []cache.Cache
+	for _, c := range components {
+		caches = append(caches, c.Cache)
+	}
+	logging.Infof("Detected cache components: %v", cache.CacheTypes(caches))
+
+	if err := utils.LockBuildDir(); err != nil {
+		return nil, err
+	}
+	buildRoot := filepath.Join(constants.MCVBuildDir, buildType)
+
+	// Buildah and filesystem image tools copy the staged tree as is, so
+	// they need real files.
+	var streamMin int64
+	if buildType != "buildah" && opts.FSImage == "" {
+		streamMin = streamFileSize
+	}
+
+	var staged []cacheComponent
+	var skipped []SkippedFile
+	for _, c := range components {
+		sc, s, err := stageComponent(buildRoot, cacheDir, c, opts, streamMin)
+		if err != nil {
+			return nil, err
+		}
+		staged = append(staged, *sc)
+		skipped = append(skipped, s...)
 	}
-	defer file.Close()
+	logSkipped(skipped)
 
-	if err = tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("error executing template: %w", err)
+	caches = caches[:0]
+	var chunkDirs []string
+	for _, sc := range staged {
+		caches = append(caches, sc.Cache)
+		chunkDirs = append(chunkDirs, sc.CacheBuildDir)
 	}
 
-	content, err := os.ReadFile(outputPath)
+	mcvLabels := cache.BuildLabels(caches)
+	artifacts := tritonArtifactCopies(caches, opts, mcvLabels)
+	extraCopies, err := stageExtraCopies(buildRoot, append(append([]CopySpec{}, opts.ExtraCopies...), artifacts...))
 	if err != nil {
-		return fmt.Errorf("error reading generated Dockerfile: %w", err)
+		return nil, err
 	}
-	logging.Debugf("Generated Dockerfile content:\n\n%s", content)
 
-	if _, err = os.Stat(outputPath); os.IsNotExist(err) {
-		return fmt.Errorf("dockerfile not found at %s", outputPath)
+	var pluginMeta *PluginMetadata
+	if opts.AnnotatePlugin != "" {
+		if pluginMeta, err = runAnnotatePlugin(opts.AnnotatePlugin, cache.BuildManifest(caches)); err != nil {
+			return nil, err
+		}
+	}
+
+	labels := mergeLabels(mcvLabels, pluginLabels(opts.ExtraLabels, pluginMeta))
+	summaries, err := externalizeSummaries(buildRoot, caches, labels)
+	if err != nil {
+		return nil, err
 	}
-	logging.Infof("Dockerfile generated successfully at %s", outputPath)
-	return nil
-}
 
-func prepareBuildContext(buildType, cacheDir string) (*buildContext, error) {
-	caches := cache.DetectCaches(cacheDir)
-	if len(caches) == 0 {
-		return nil, errors.New("failed to detect cache type")
+	// Chunk last, so labels and the manifest describe the original files.
+	chunkStore, packs, err := chunkLargeFiles(buildRoot, chunkDirs, opts.ChunkThreshold)
+	if err != nil {
+		return nil, err
 	}
-	logging.Infof("Detected cache components: %v", cache.CacheTypes(caches))
+	if len(packs) > 0 {
+		labels[chunk.ChunkedLabel] = "true"
+	}
+
+	primary := staged[0]
+	return &buildContext{
+		Caches:           caches,
+		Labels:           labels,
+		ManifestTag:      primary.ManifestTag,
+		CacheTag:         primary.CacheTag,
+		CacheBuildDir:    primary.CacheBuildDir,
+		ManifestBuildDir: primary.ManifestBuildDir,
+		ManifestPath:     primary.ManifestPath,
+		HotDirs:          primary.HotDirs,
+		BuildRoot:        buildRoot,
+		ExtraCopies:      extraCopies,
+		Summaries:        summaries,
+		Skipped:          skipped,
+		ChunkStore:       chunkStore,
+		ChunkPacks:       packs,
+		Annotations:      pluginAnnotations(pluginMeta),
+		Components:       staged,
+	}, nil
+}
 
-	manifestTag, cacheTag, err := cache.GetTagsFromCaches(caches)
+// stageComponent copies the cache of c into its own directories under
+// buildRoot, applying the content filter and secret scan, and writes its
+// manifest.
+func stageComponent(buildRoot, cacheDir string, c cache.Component, opts BuildOptions, streamMin int64) (*cacheComponent, []SkippedFile, error) {
+	manifestTag, cacheTag, err := cache.GetTagsFromCaches([]cache.Cache{c.Cache})
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving manifest/cache tags: %v", err)
+		return nil, nil, fmt.Errorf("error retrieving manifest/cache tags: %w", err)
 	}
 	logging.Debugf("manifestTag: %s", manifestTag)
 	logging.Debugf("cacheTag: %s", cacheTag)
 
-	buildRoot := filepath.Join(constants.MCVBuildDir, buildType)
-
 	cacheBuildDir := filepath.Join(buildRoot, cacheTag)
 	manifestBuildDir := filepath.Join(buildRoot, manifestTag)
 
 	if err := os.MkdirAll(cacheBuildDir, 0755); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	logging.Debugf("cache build dir: %s", cacheBuildDir)
 
 	if err := os.MkdirAll(manifestBuildDir, 0755); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	logging.Debugf("manifest build dir: %s", manifestBuildDir)
 
-	if err := cache.CopyDir(cacheDir, cacheBuildDir); err != nil {
-		return nil, fmt.Errorf("error copying contents: %v", err)
+	skipped, err := copyFiltered(c.Dir, cacheBuildDir, opts.Filter.forComponent(cacheDir, c.Dir), streamMin)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error copying contents: %w", err)
+	}
+	if rel, err := filepath.Rel(cacheDir, c.Dir); err == nil && rel != "." {
+		// Report colocated caches relative to the directory given to create.
+		for i := range skipped {
+			skipped[i].Path = filepath.Join(rel, skipped[i].Path)
+		}
+	}
+	if err := canonicalizePaths(c.Dir, cacheBuildDir); err != nil {
+		return nil, nil, err
+	}
+	if err := applySecretScan(cacheBuildDir, opts.SecretScan); err != nil {
+		return nil, nil, err
 	}
 
-	cache.SetCachesBuildDir(caches, cacheBuildDir)
+	cc := c.Cache
+	if len(skipped) > 0 {
+		// Describe only what is packaged, not what was filtered out.
+		caches := cache.DetectCaches(cacheBuildDir)
+		if len(caches) == 0 || caches[0].Name() != cc.Name() {
+			return nil, nil, failure.New(failure.NoCache, fmt.Errorf("no %s cache content left after applying filters", cc.Name()))
+		}
+		cc = caches[0]
+	}
+	if v, ok := cc.(*cache.VLLMCache); ok && opts.VLLMPython != "" {
+		k, err := cache.ProbeVLLMKeyInputs(opts.VLLMPython)
+		if err != nil {
+			diag.Warnf(diag.VLLMVersionsUnknown, "vLLM versions not recorded, extraction cannot check the cache will be used: %v", err)
+		} else {
+			logging.Infof("Recording vLLM %s, torch %s, triton %s as the cache key inputs", k.VLLM, orUnknown(k.Torch), orUnknown(k.Triton))
+			v.SetKeyInputs(k)
+		}
+	}
+	cache.SetCachesBuildDir([]cache.Cache{cc}, cacheBuildDir)
+	hotDirs := cache.MarkHotKernels(cc, opts.HotKernels)
 
-	labels := cache.BuildLabels(caches)
-	manifest := cache.BuildManifest(caches)
 	manifestPath := filepath.Join(manifestBuildDir, "manifest.json")
-
-	if err := cache.WriteManifest(manifestPath, manifest); err != nil {
-		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	if err := cache.WriteManifest(manifestPath, cache.BuildManifest([]cache.Cache{cc})); err != nil {
+		return nil, nil, fmt.Errorf("failed to write manifest: %w", err)
 	}
 
-	return &buildContext{
-		Caches:           caches,
-		Labels:           labels,
-		ManifestTag:      manifestTag,
+	return &cacheComponent{
+		Cache:            cc,
 		CacheTag:         cacheTag,
 		CacheBuildDir:    cacheBuildDir,
+		ManifestTag:      manifestTag,
 		ManifestBuildDir: manifestBuildDir,
 		ManifestPath:     manifestPath,
-		BuildRoot:        buildRoot,
-	}, nil
+		HotDirs:          hotDirs,
+	}, skipped, nil
+}
+
+// tritonArtifactCopies returns copies packaging the Triton dump and override
+// directories next to the cache, and labels the image as carrying them.
+func tritonArtifactCopies(caches []cache.Cache, opts BuildOptions, labels map[string]string) []CopySpec {
+	dirs := []struct{ src, dest, label string }{
+		{opts.TritonDumpDir, constants.MCVTritonDumpDir, "cache.triton.image/dump"},
+		{opts.TritonOverrideDir, constants.MCVTritonOverrideDir, "cache.triton.image/override"},
+	}
+
+	hasTriton := false
+	for _, c := range caches {
+		hasTriton = hasTriton || c.Name() == constants.Triton
+	}
+
+	var copies []CopySpec
+	for _, d := range dirs {
+		if d.src == "" {
+			continue
+		}
+		if !hasTriton {
+			diag.Warnf(diag.NoTritonCache, "Ignoring %s: no Triton cache detected", d.src)
+			continue
+		}
+		copies = append(copies, CopySpec{Src: d.src, Dest: d.dest})
+		labels[d.label] = "true"
+	}
+	return copies
 }
 
+// chunkPackSize is the average number of chunks stored per layer.
+const chunkPackSize = 64
+
+// chunkLargeFiles splits the files under cacheBuildDirs of at least
+// threshold bytes into chunks and groups the chunks into layer packs.
+// Each directory gets its own chunk index; the chunks share one store.
+func chunkLargeFiles(buildRoot string, cacheBuildDirs []string, threshold int64) (string, [][]string, error) {
+	if threshold <= 0 {
+		return "", nil, nil
+	}
+	storeDir := filepath.Join(buildRoot, "chunks")
+	files := 0
+	var digests []string
+	seen := map[string]bool{}
+	for _, dir := range cacheBuildDirs {
+		idx, err := chunk.ChunkFiles(dir, storeDir, threshold)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to chunk cache files: %w", err)
+		}
+		if idx == nil {
+			continue
+		}
+		files += len(idx.Files)
+		for _, d := range idx.Digests() {
+			if !seen[d] {
+				seen[d] = true
+				digests = append(digests, d)
+			}
+		}
+	}
+	if files == 0 {
+		logging.Infof("No cache files of at least %s to chunk", units.BytesSize(float64(threshold)))
+		return "", nil, nil
+	}
+	packs := chunk.Packs(digests, chunkPackSize)
+	logging.Infof("Chunked %d file(s) into %d unique chunk(s) in %d layer(s)", files, len(digests), len(packs))
+	return storeDir, packs, nil
+}
+
+// canonicalizePaths replaces the source cache location in the staged
+// metadata with a placeholder so the image extracts to any directory.
+func canonicalizePaths(cacheDir, cacheBuildDir string) error {
+	roots := []string{cacheDir}
+	if abs, err := filepath.Abs(cacheDir); err == nil {
+		roots = append(roots, abs)
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil && resolved != abs {
+			roots = append(roots, resolved)
+		}
+	}
+	n, err := cache.CanonicalizePaths(cacheBuildDir, roots)
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize cache paths: %w", err)
+	}
+	if n > 0 {
+		logging.Infof("Made embedded paths relocatable in %d metadata file(s)", n)
+	}
+	return nil
+}
+
+// externalizeSummaries moves summary labels larger than
+// cache.MaxSummaryLabelBytes into files staged under buildRoot and replaces
+// the labels with pointers to them.
+func externalizeSummaries(buildRoot string, caches []cache.Cache, labels map[string]string) ([]externalSummary, error) {
+	var out []externalSummary
+	for _, c := range caches {
+		key := fmt.Sprintf("cache.%s.image/summary", c.Name())
+		value, ok := labels[key]
+		if !ok || len(value) <= cache.MaxSummaryLabelBytes {
+			continue
+		}
+
+		dest := cache.SummaryFilePath(c.Name())
+		src := filepath.Join(buildRoot, dest)
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(src, []byte(value), 0644); err != nil {
+			return nil, fmt.Errorf("failed to stage summary for %s: %w", c.Name(), err)
+		}
+		diag.Warnf(diag.SummaryInLayer, "%s summary is %d bytes; storing it in a metadata layer instead of the label", c.Name(), len(value))
+
+		labels[key] = cache.EncodeSummaryPointer(cache.SummaryPointer{Path: dest, Size: len(value)})
+		out = append(out, externalSummary{LabelKey: key, Src: src, Dest: dest, Size: len(value)})
+	}
+	return out, nil
+}
+
+// CleanupDirs removes staged build directories. With --keep-temp they are
+// kept with the rest of the build dir instead.
 func CleanupDirs(dirs ...string) {
+	if config.IsKeepTempEnabled() {
+		return
+	}
 	for _, dir := range dirs {
 		if err := os.RemoveAll(dir); err != nil {
-			logging.Warnf("Failed to remove %s: %v", dir, err)
+			diag.Warnf(diag.CleanupFailed, "Failed to remove %s: %v", dir, err)
 		}
 	}
 }
 
+// keepImage writes img to an OCI layout in the build root, which is kept
+// with --keep-temp, so its layer blobs can be inspected.
+func keepImage(img v1.Image, prep *buildContext) {
+	if !config.IsKeepTempEnabled() {
+		return
+	}
+	p, err := layout.Write(filepath.Join(prep.BuildRoot, "image"), empty.Index)
+	if err == nil {
+		err = p.AppendImage(img)
+	}
+	if err != nil {
+		diag.Warnf(diag.TempNotKept, "Failed to keep the image layout: %v", err)
+	}
+}
+
 func CleanupWithTimeout() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -131,6 +423,9 @@ func NormalizeImageTag(imageName string) string {
 	return imageName
 }
 
-func DockerfilePath(buildRoot string) string {
-	return filepath.Join(buildRoot, "Dockerfile")
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
 }
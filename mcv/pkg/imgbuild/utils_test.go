@@ -9,20 +9,6 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGenerateDockerfile(t *testing.T) {
-	tmpDir := t.TempDir()
-	outputPath := filepath.Join(tmpDir, "Dockerfile")
-
-	err := GenerateDockerfile("myorg/myimage:1.0", "cacheLayer", "manifestLayer", outputPath)
-	assert.NoError(t, err)
-
-	content, err := os.ReadFile(outputPath)
-	assert.NoError(t, err)
-	assert.Contains(t, string(content), "FROM scratch")
-	assert.Contains(t, string(content), "COPY \"./cacheLayer.")
-	assert.Contains(t, string(content), "COPY \"./manifestLayer/manifest.json")
-}
-
 func TestCleanupDirs(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "dummy.txt")
@@ -44,3 +30,28 @@ func TestCleanupWithTimeout(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Less(t, duration.Milliseconds(), int64(5000))
 }
+
+func TestParseLabelsAndCopySpecs(t *testing.T) {
+	labels, err := ParseLabels([]string{"team=ml", "cost-center=42"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "ml", "cost-center": "42"}, labels)
+
+	_, err = ParseLabels([]string{"novalue"})
+	assert.Error(t, err)
+
+	specs, err := ParseCopySpecs([]string{"./LICENSE:/licenses/LICENSE"})
+	assert.NoError(t, err)
+	assert.Equal(t, []CopySpec{{Src: "./LICENSE", Dest: "/licenses/LICENSE"}}, specs)
+
+	_, err = ParseCopySpecs([]string{"missing-dest"})
+	assert.Error(t, err)
+}
+
+func TestMergeLabels_MCVLabelsWin(t *testing.T) {
+	merged := mergeLabels(
+		map[string]string{"cache.triton.image/entry-count": "3"},
+		map[string]string{"cache.triton.image/entry-count": "99", "team": "ml"},
+	)
+	assert.Equal(t, "3", merged["cache.triton.image/entry-count"])
+	assert.Equal(t, "ml", merged["team"])
+}
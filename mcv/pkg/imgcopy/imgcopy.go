@@ -0,0 +1,379 @@
+// Package imgcopy copies cache images between registries, OCI layout
+// directories and skopeo dir: directories, as skopeo copy does. Manifests
+// are copied byte for byte, so the digest and every label and annotation
+// mcv relies on survive the copy, together with the image's signatures and
+// the artifacts attached to it: OCI referrers and the signatures,
+// attestations and SBOMs cosign stores under sha256-<hex> tags.
+package imgcopy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/manifest"
+	ocilayout "github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	"github.com/redhat-et/MCU/mcv/pkg/imgstore"
+	"github.com/redhat-et/MCU/mcv/pkg/registry"
+	"github.com/redhat-et/MCU/mcv/pkg/sigverify"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Transports Copy reads and writes, named as skopeo names them.
+const (
+	TransportDocker = "docker"
+	TransportLayout = "oci"
+	TransportDir    = "dir"
+)
+
+// unsupported are the other skopeo transports, refused by name rather
+// than mistaken for registry hosts.
+var unsupported = []string{"docker-archive:", "docker-daemon:", "oci-archive:", "containers-storage:", "ostree:", "sif:", "tarball:"}
+
+// cosignSuffixes end the tags cosign stores signatures, attestations and
+// SBOMs under.
+var cosignSuffixes = []string{".sig", ".att", ".sbom"}
+
+// Location is an image to copy from or to.
+type Location struct {
+	Transport string
+	Name      string // Image reference, layout path[:tag] or directory
+	ref       types.ImageReference
+}
+
+func (l *Location) String() string {
+	if l.Transport == TransportDocker {
+		return TransportDocker + "://" + l.Name
+	}
+	return l.Transport + ":" + l.Name
+}
+
+// ParseLocation parses a location as skopeo does: docker://ref,
+// oci:/path[:tag] or dir:/path. A reference without a transport names a
+// registry image.
+func ParseLocation(s string) (*Location, error) {
+	for _, p := range unsupported {
+		if strings.HasPrefix(s, p) {
+			return nil, fmt.Errorf("unsupported location %s: only registries, %s and %s: are supported", s, imgref.LayoutPrefix, TransportDir)
+		}
+	}
+	switch {
+	case imgref.IsLayout(s):
+		if imgref.IsDigest(s) {
+			return nil, fmt.Errorf("invalid location %s: images in an OCI layout are copied by tag", s)
+		}
+		if err := imgref.Validate(s); err != nil {
+			return nil, err
+		}
+		path := strings.TrimPrefix(s, imgref.LayoutPrefix)
+		ref, err := ocilayout.ParseReference(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid location %s: %w", s, err)
+		}
+		return &Location{Transport: TransportLayout, Name: path, ref: ref}, nil
+	case strings.HasPrefix(s, TransportDir+":"):
+		path := strings.TrimPrefix(s, TransportDir+":")
+		if path == "" {
+			return nil, fmt.Errorf("invalid location %s: no directory", s)
+		}
+		ref, err := directory.NewReference(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid location %s: %w", s, err)
+		}
+		return &Location{Transport: TransportDir, Name: path, ref: ref}, nil
+	}
+	img := strings.TrimPrefix(s, TransportDocker+"://")
+	if err := imgref.Validate(img); err != nil {
+		return nil, err
+	}
+	ref, err := docker.ParseReference("//" + img)
+	if err != nil {
+		return nil, fmt.Errorf("invalid location %s: %w", s, err)
+	}
+	return &Location{Transport: TransportDocker, Name: img, ref: ref}, nil
+}
+
+// Options configures Copy.
+type Options struct {
+	SignaturePolicy string // Policy the source must pass, the host's when empty
+	InsecurePolicy  bool   // Accept any source image, ignoring the policy
+}
+
+// Result reports what Copy copied.
+type Result struct {
+	Digest      string // Manifest digest, the same at source and destination
+	Attachments int    // Referrers and cosign artifacts copied
+	Skipped     int    // Attachments the destination has no place for
+}
+
+var newSystemContext = sigverify.SystemContext
+
+// Copy copies the image at src, all platforms of it if it is an index, to
+// dst, then copies what is attached to it. The source must pass the
+// signature policy unless opts.InsecurePolicy is set. A dir: destination
+// has no place for referrers or cosign artifacts; they are counted as
+// skipped. The attachments are copied within the registry's sign timeout.
+func Copy(ctx context.Context, src, dst *Location, opts Options) (*Result, error) {
+	sys := newSystemContext(opts.SignaturePolicy)
+	pc, err := policyContext(sys, opts.InsecurePolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = pc.Destroy() }()
+
+	report := io.Discard
+	if logging.IsLevelEnabled(logging.DebugLevel) {
+		report = os.Stderr
+	}
+	logging.Infof("Copying %s to %s", src, dst)
+	copied, err := copy.Image(ctx, pc, dst.ref, src.ref, &copy.Options{
+		SourceCtx:          sys,
+		DestinationCtx:     sys,
+		PreserveDigests:    true,
+		ImageListSelection: copy.CopyAllImages,
+		ReportWriter:       report,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	d, err := manifest.Digest(copied)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest digest: %w", err)
+	}
+	res := &Result{Digest: d.String()}
+	digest, err := v1.NewHash(res.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest digest: %w", err)
+	}
+
+	ctx, cancel := registry.Context(ctx, registry.OpSign)
+	defer cancel()
+	attachments, err := src.attachments(ctx, digest)
+	if err != nil {
+		return res, registry.TimeoutError(ctx, err)
+	}
+	for _, a := range attachments {
+		if dst.Transport == TransportDir {
+			res.Skipped++
+			continue
+		}
+		if err := dst.attach(ctx, a); err != nil {
+			return res, fmt.Errorf("failed to copy %s to %s: %w", a, dst, registry.TimeoutError(ctx, err))
+		}
+		res.Attachments++
+	}
+	if res.Skipped > 0 {
+		diag.Warnf(diag.ReferrersNotCopied, "%s cannot hold referrers or cosign artifacts, %d not copied", dst, res.Skipped)
+	}
+	return res, nil
+}
+
+func policyContext(sys *types.SystemContext, insecure bool) (*signature.PolicyContext, error) {
+	if !insecure {
+		return sigverify.PolicyContext(sys)
+	}
+	return signature.NewPolicyContext(&signature.Policy{
+		Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()},
+	})
+}
+
+// attachment is an artifact attached to a copied image, either as an OCI
+// referrer or under a cosign tag.
+type attachment struct {
+	tag    string // Cosign tag, empty for referrers
+	digest v1.Hash
+	img    v1.Image
+	idx    v1.ImageIndex
+}
+
+func (a attachment) String() string {
+	if a.tag != "" {
+		return a.tag
+	}
+	return "referrer " + a.digest.String()
+}
+
+func cosignTags(digest v1.Hash) map[string]bool {
+	tags := map[string]bool{}
+	for _, s := range cosignSuffixes {
+		tags[digest.Algorithm+"-"+digest.Hex+s] = true
+	}
+	return tags
+}
+
+// attachments returns what is attached to the image digest at l.
+func (l *Location) attachments(ctx context.Context, digest v1.Hash) ([]attachment, error) {
+	switch l.Transport {
+	case TransportDocker:
+		return registryAttachments(ctx, l.Name, digest)
+	case TransportLayout:
+		return layoutAttachments(l.layoutDir(), digest)
+	}
+	return nil, nil
+}
+
+func registryAttachments(ctx context.Context, img string, digest v1.Hash) ([]attachment, error) {
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image name: %w", err)
+	}
+	repo := ref.Context()
+	opts := registry.Options(remote.WithContext(ctx))
+
+	referrers, err := remote.Referrers(repo.Digest(digest.String()), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %s: %w", img, err)
+	}
+	m, err := referrers.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %s: %w", img, err)
+	}
+	var out []attachment
+	for _, d := range m.Manifests {
+		desc, err := remote.Get(repo.Digest(d.Digest.String()), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read referrer %s of %s: %w", d.Digest, img, err)
+		}
+		a, err := remoteAttachment("", desc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+
+	for tag := range cosignTags(digest) {
+		desc, err := remote.Get(repo.Tag(tag), opts...)
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of %s: %w", tag, img, err)
+		}
+		a, err := remoteAttachment(tag, desc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func remoteAttachment(tag string, desc *remote.Descriptor) (attachment, error) {
+	a := attachment{tag: tag, digest: desc.Digest}
+	var err error
+	if desc.MediaType.IsIndex() {
+		a.idx, err = desc.ImageIndex()
+	} else {
+		a.img, err = desc.Image()
+	}
+	if err != nil {
+		return a, fmt.Errorf("failed to read %s: %w", a, err)
+	}
+	return a, nil
+}
+
+func layoutAttachments(dir string, digest v1.Hash) ([]attachment, error) {
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI layout %s: %w", dir, err)
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI layout %s: %w", dir, err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI layout %s: %w", dir, err)
+	}
+
+	tags := cosignTags(digest)
+	var out []attachment
+	for _, d := range m.Manifests {
+		a := attachment{digest: d.Digest}
+		if tag := d.Annotations[imgstore.RefNameAnnotation]; tags[tag] {
+			a.tag = tag
+		} else {
+			raw, err := p.Bytes(d.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifest %s in OCI layout %s: %w", d.Digest, dir, err)
+			}
+			var subject struct {
+				Subject *v1.Descriptor `json:"subject"`
+			}
+			if json.Unmarshal(raw, &subject) != nil || subject.Subject == nil || subject.Subject.Digest != digest {
+				continue
+			}
+		}
+		if d.MediaType.IsIndex() {
+			a.idx, err = idx.ImageIndex(d.Digest)
+		} else {
+			a.img, err = idx.Image(d.Digest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in OCI layout %s: %w", a, dir, err)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// attach writes a to l: referrers by digest and cosign artifacts under
+// their tag.
+func (l *Location) attach(ctx context.Context, a attachment) error {
+	switch l.Transport {
+	case TransportDocker:
+		ref, err := name.ParseReference(l.Name)
+		if err != nil {
+			return fmt.Errorf("failed to parse image name: %w", err)
+		}
+		var target name.Reference = ref.Context().Digest(a.digest.String())
+		if a.tag != "" {
+			target = ref.Context().Tag(a.tag)
+		}
+		opts := registry.Options(remote.WithContext(ctx))
+		if a.idx != nil {
+			return remote.WriteIndex(target, a.idx, opts...)
+		}
+		return remote.Write(target, a.img, opts...)
+	case TransportLayout:
+		p, err := layout.FromPath(l.layoutDir())
+		if err != nil {
+			return err
+		}
+		matcher := match.Digests(a.digest)
+		var opts []layout.Option
+		if a.tag != "" {
+			matcher = match.Name(a.tag)
+			opts = append(opts, layout.WithAnnotations(map[string]string{imgstore.RefNameAnnotation: a.tag}))
+		}
+		if a.idx != nil {
+			return p.ReplaceIndex(a.idx, matcher, opts...)
+		}
+		return p.ReplaceImage(a.img, matcher, opts...)
+	}
+	return fmt.Errorf("%s cannot hold attachments", l)
+}
+
+func (l *Location) layoutDir() string {
+	dir, _, _ := imgref.SplitLayout(imgref.LayoutPrefix + l.Name)
+	return dir
+}
@@ -0,0 +1,132 @@
+package imgcopy
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/sigverify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLocation(t *testing.T) {
+	for in, want := range map[string]Location{
+		"quay.io/org/cache:v1":          {Transport: TransportDocker, Name: "quay.io/org/cache:v1"},
+		"docker://quay.io/org/cache:v1": {Transport: TransportDocker, Name: "quay.io/org/cache:v1"},
+		"oci:/mnt/caches:v1":            {Transport: TransportLayout, Name: "/mnt/caches:v1"},
+		"dir:/mnt/cache":                {Transport: TransportDir, Name: "/mnt/cache"},
+	} {
+		loc, err := ParseLocation(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want.Transport, loc.Transport, in)
+		assert.Equal(t, want.Name, loc.Name, in)
+	}
+	for _, in := range []string{"docker-archive:/tmp/cache.tar", "oci:/mnt/caches@sha256:" + strings.Repeat("a", 64), "dir:", "quay.io/Org/cache:v1"} {
+		_, err := ParseLocation(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	srv := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	img, err := random.Image(1024, 2)
+	assert.NoError(t, err)
+	img = mutate.Annotations(img, map[string]string{"cache.triton.image/variant": "multi"}).(v1.Image)
+	ref, err := name.ParseReference(host + "/cache:v1")
+	assert.NoError(t, err)
+	assert.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	assert.NoError(t, err)
+
+	// An OCI referrer and a cosign signature.
+	desc, err := partial.Descriptor(img)
+	assert.NoError(t, err)
+	sbom, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	sbom = mutate.Subject(sbom, *desc).(v1.Image)
+	sbomDigest, err := sbom.Digest()
+	assert.NoError(t, err)
+	assert.NoError(t, remote.Write(ref.Context().Digest(sbomDigest.String()), sbom))
+	sig, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	sigTag := "sha256-" + digest.Hex + ".sig"
+	assert.NoError(t, remote.Write(ref.Context().Tag(sigTag), sig))
+
+	orig := newSystemContext
+	defer func() { newSystemContext = orig }()
+	dir := t.TempDir()
+	registriesConf := filepath.Join(dir, "registries.conf")
+	assert.NoError(t, os.WriteFile(registriesConf, nil, 0644))
+	newSystemContext = func(policyPath string) *types.SystemContext {
+		sys := sigverify.SystemContext(policyPath)
+		sys.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+		sys.SystemRegistriesConfPath = registriesConf
+		sys.RegistriesDirPath = dir
+		return sys
+	}
+	opts := Options{InsecurePolicy: true}
+
+	copyTo := func(src, dst string) *Result {
+		s, err := ParseLocation(src)
+		assert.NoError(t, err)
+		d, err := ParseLocation(dst)
+		assert.NoError(t, err)
+		res, err := Copy(context.Background(), s, d, opts)
+		assert.NoError(t, err, "%s -> %s", src, dst)
+		return res
+	}
+
+	// registry -> layout -> registry keeps the digest and the attachments.
+	layoutLoc := "oci:" + filepath.Join(dir, "layout") + ":v1"
+	res := copyTo(host+"/cache:v1", layoutLoc)
+	assert.Equal(t, digest.String(), res.Digest)
+	assert.Equal(t, 2, res.Attachments)
+	res = copyTo(layoutLoc, "docker://"+host+"/mirror:v1")
+	assert.Equal(t, digest.String(), res.Digest)
+	assert.Equal(t, 2, res.Attachments)
+
+	mirror, err := name.ParseReference(host + "/mirror:v1")
+	assert.NoError(t, err)
+	got, err := remote.Get(mirror)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, got.Digest)
+	referrers, err := remote.Referrers(mirror.Context().Digest(digest.String()))
+	assert.NoError(t, err)
+	m, err := referrers.IndexManifest()
+	assert.NoError(t, err)
+	if assert.Len(t, m.Manifests, 1) {
+		assert.Equal(t, sbomDigest, m.Manifests[0].Digest)
+	}
+	_, err = remote.Get(mirror.Context().Tag(sigTag))
+	assert.NoError(t, err)
+
+	// dir: keeps the image but has no place for attachments.
+	res = copyTo(host+"/cache:v1", "dir:"+filepath.Join(dir, "plain"))
+	assert.Equal(t, digest.String(), res.Digest)
+	assert.Equal(t, 0, res.Attachments)
+	assert.Equal(t, 2, res.Skipped)
+
+	// The source must pass the signature policy.
+	reject := filepath.Join(dir, "reject.json")
+	assert.NoError(t, os.WriteFile(reject, []byte(`{"default":[{"type":"reject"}]}`), 0644))
+	s, _ := ParseLocation(host + "/cache:v1")
+	d, _ := ParseLocation(host + "/rejected:v1")
+	_, err = Copy(context.Background(), s, d, Options{SignaturePolicy: reject})
+	assert.Error(t, err)
+}
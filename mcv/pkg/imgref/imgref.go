@@ -0,0 +1,166 @@
+// Package imgref handles the image references mcv accepts: a repository
+// with a tag, a digest (repo@sha256:...), or both (repo:tag@sha256:...),
+// in which case the digest is what is fetched. An OCI image layout
+// directory is referenced as oci:/path, oci:/path:tag or
+// oci:/path@sha256:..., as skopeo does.
+package imgref
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+)
+
+// LayoutPrefix starts references to an OCI image layout directory.
+const LayoutPrefix = "oci:"
+
+var (
+	digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	tagPattern    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+// IsLayout reports whether ref names an image in an OCI layout directory.
+func IsLayout(ref string) bool {
+	return strings.HasPrefix(ref, LayoutPrefix)
+}
+
+// SplitLayout returns the directory of the layout reference ref and the
+// tag or digest selecting an image in it, either of which may be empty.
+// As with skopeo, the directory ends at the first colon, so the tag may be
+// a full image reference such as quay.io/org/cache:v1.
+func SplitLayout(ref string) (dir, tag, digest string) {
+	dir = strings.TrimPrefix(ref, LayoutPrefix)
+	if i := strings.LastIndex(dir, "@"); i >= 0 {
+		return dir[:i], "", dir[i+1:]
+	}
+	if i := strings.Index(dir, ":"); i >= 0 {
+		return dir[:i], dir[i+1:], ""
+	}
+	return dir, "", ""
+}
+
+// Validate returns an error if ref is not a valid image reference, as
+// parsed by containers/image. It also accepts registries given as an IPv6
+// literal in brackets, such as [fd00::1]:5000/cache:v1.
+func Validate(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("image name is empty")
+	}
+	if IsLayout(ref) {
+		return validateLayout(ref)
+	}
+	named := ref
+	if strings.HasPrefix(ref, "[") {
+		end := strings.Index(ref, "]")
+		if end < 0 {
+			return fmt.Errorf("invalid image name %s: unterminated IPv6 address", ref)
+		}
+		if ip := net.ParseIP(ref[1:end]); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid image name %s: %q is not an IPv6 address", ref, ref[1:end])
+		}
+		rest := ref[end+1:]
+		if !strings.HasPrefix(rest, "/") && !(strings.HasPrefix(rest, ":") && strings.Contains(rest, "/")) {
+			return fmt.Errorf("invalid image name %s: no repository after the registry", ref)
+		}
+		// The containers/image grammar has no IPv6 hosts; check the rest
+		// of the reference under a stand-in host.
+		named = "ipv6.invalid" + rest
+	}
+	if _, err := reference.ParseNormalizedNamed(named); err != nil {
+		// The parser only reports a lowercase rule when the whole reference,
+		// registry included, would parse in lowercase.
+		if lower := lowerPath(named); lower != named && parses(lower) {
+			return fmt.Errorf("invalid image name %s: repository paths must be lowercase, the registry may accept %s", ref, lowerPath(ref))
+		}
+		return fmt.Errorf("invalid image name %s: %w", ref, err)
+	}
+	return ValidateDigest(ref)
+}
+
+func validateLayout(ref string) error {
+	dir, tag, _ := SplitLayout(ref)
+	if dir == "" {
+		return fmt.Errorf("invalid image name %s: no layout directory, use %s/path[:tag]", ref, LayoutPrefix)
+	}
+	if tag != "" && !tagPattern.MatchString(tag) && !parses(tag) {
+		return fmt.Errorf("invalid image name %s: invalid tag %q", ref, tag)
+	}
+	return ValidateDigest(ref)
+}
+
+func parses(ref string) bool {
+	_, err := reference.ParseNormalizedNamed(ref)
+	return err == nil
+}
+
+// lowerPath returns ref with its repository path, but not its registry,
+// tag or digest, in lowercase.
+func lowerPath(ref string) string {
+	repo := Repository(ref)
+	suffix := ref[len(repo):]
+	if i := strings.Index(repo, "/"); i >= 0 && strings.ContainsAny(repo[:i], ".:[") {
+		return repo[:i] + strings.ToLower(repo[i:]) + suffix
+	}
+	return strings.ToLower(repo) + suffix
+}
+
+// Digest returns the digest ref names, or "" if it names none.
+func Digest(ref string) string {
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+// IsDigest reports whether ref names a digest.
+func IsDigest(ref string) bool {
+	return Digest(ref) != ""
+}
+
+// ValidateDigest returns an error if ref names a digest that is not a
+// sha256 digest.
+func ValidateDigest(ref string) error {
+	if d := Digest(ref); d != "" && !digestPattern.MatchString(d) {
+		return fmt.Errorf("invalid digest %q in %s: expected sha256:<64 hex digits>", d, ref)
+	}
+	return nil
+}
+
+// Repository returns ref without its tag and digest.
+func Repository(ref string) string {
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// Tag returns the tag ref names, or "" if it names none.
+func Tag(ref string) string {
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+// WithDigest returns the reference to digest in ref's repository.
+func WithDigest(ref, digest string) string {
+	return Repository(ref) + "@" + digest
+}
+
+// RequireDigest returns an error unless ref names a digest, for nodes
+// that must only extract immutable references.
+func RequireDigest(ref string) error {
+	if !IsDigest(ref) {
+		return fmt.Errorf("digest-only mode: %s is not a digest reference, use %s@sha256:<digest>", ref, Repository(ref))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package imgref
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefs(t *testing.T) {
+	d := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		ref, repo, tag, digest string
+	}{
+		{"quay.io/org/cache", "quay.io/org/cache", "", ""},
+		{"quay.io/org/cache:v1", "quay.io/org/cache", "v1", ""},
+		{"localhost:5000/cache:v1", "localhost:5000/cache", "v1", ""},
+		{"localhost:5000/cache", "localhost:5000/cache", "", ""},
+		{"quay.io/org/cache@" + d, "quay.io/org/cache", "", d},
+		{"quay.io/org/cache:v1@" + d, "quay.io/org/cache", "v1", d},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.repo, Repository(tt.ref), tt.ref)
+		assert.Equal(t, tt.tag, Tag(tt.ref), tt.ref)
+		assert.Equal(t, tt.digest, Digest(tt.ref), tt.ref)
+		assert.Equal(t, tt.digest != "", RequireDigest(tt.ref) == nil, tt.ref)
+		assert.NoError(t, ValidateDigest(tt.ref), tt.ref)
+	}
+	assert.Equal(t, "quay.io/org/cache@"+d, WithDigest("quay.io/org/cache:v1", d))
+	assert.Error(t, ValidateDigest("quay.io/org/cache@sha256:abc"))
+	assert.Error(t, ValidateDigest("quay.io/org/cache@md5:"+strings.Repeat("a", 32)))
+}
+
+func TestValidate(t *testing.T) {
+	d := "sha256:" + strings.Repeat("0", 64)
+	for _, ref := range []string{
+		"cache",
+		"org/cache:v1",
+		"quay.io/org/team/cache:v1.2-rocm_6",
+		"localhost/cache",
+		"localhost:5000/cache",
+		"localhost:5000/org/cache:latest",
+		"127.0.0.1:5000/cache",
+		"MyRegistry.Example.com:5000/org/cache",
+		"[::1]:5000/cache:v1",
+		"[fd00::10]/org/cache",
+		"registry.example.com/org/cache@" + d,
+		"registry.example.com/org/cache:v1@" + d,
+		"registry.example.com/org__x/cache--y",
+	} {
+		assert.NoError(t, Validate(ref), ref)
+	}
+
+	for _, ref := range []string{
+		"",
+		"quay.io/Org/Cache:v1",
+		"quay.io/org/cache:",
+		"quay.io/org/cache:-v1",
+		"quay.io/org/cache@sha256:abc",
+		"[::1]:5000",
+		"[::1/cache",
+		"[127.0.0.1]:5000/cache",
+		"[not-an-ip]/cache",
+		"https://quay.io/org/cache",
+	} {
+		assert.Error(t, Validate(ref), ref)
+	}
+
+	err := Validate("quay.io/Org/Cache:v1")
+	assert.ErrorContains(t, err, "quay.io/org/cache:v1")
+	assert.ErrorContains(t, Validate("MyReg.local/Org/Cache"), "MyReg.local/org/cache")
+}
+
+func TestSplitLayout(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	for ref, want := range map[string][3]string{
+		"oci:/mnt/caches":                      {"/mnt/caches", "", ""},
+		"oci:/mnt/caches:v1":                   {"/mnt/caches", "v1", ""},
+		"oci:/mnt/caches:quay.io/org/cache:v1": {"/mnt/caches", "quay.io/org/cache:v1", ""},
+		"oci:/mnt/caches@" + digest:            {"/mnt/caches", "", digest},
+	} {
+		dir, tag, d := SplitLayout(ref)
+		assert.Equal(t, want, [3]string{dir, tag, d}, ref)
+		assert.NoError(t, Validate(ref), ref)
+	}
+	assert.Error(t, Validate("oci:"))
+	assert.Error(t, Validate("oci:/mnt/caches:-v1"))
+}
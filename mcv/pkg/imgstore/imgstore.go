@@ -0,0 +1,114 @@
+// Package imgstore keeps locally built cache images in an OCI image layout
+// directory so they can be extracted, inspected or pushed without a
+// container engine.
+package imgstore
+
+import (
+	"fmt"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+	logging "github.com/sirupsen/logrus"
+)
+
+// RefNameAnnotation is the standard OCI annotation used to name images in a layout index.
+const RefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// Path returns the OCI layout directory used as the local image store.
+func Path() string {
+	return constants.ImageStoreDir
+}
+
+func open(create bool) (layout.Path, error) {
+	dir := Path()
+	p, err := layout.FromPath(dir)
+	if err == nil {
+		return p, nil
+	}
+	if !create {
+		return "", fmt.Errorf("image store %s not found: %w", dir, err)
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create image store %s: %w", dir, err)
+	}
+	return layout.Write(dir, empty.Index)
+}
+
+// Save writes img to the local store under ref, replacing any image that
+// was previously stored with the same reference.
+func Save(img v1.Image, ref string) error {
+	p, err := open(true)
+	if err != nil {
+		return err
+	}
+	err = p.ReplaceImage(img, match.Name(ref), layout.WithAnnotations(map[string]string{
+		RefNameAnnotation: ref,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to write image %s to store: %w", ref, err)
+	}
+	logging.Debugf("Stored image %s in %s", ref, Path())
+	return nil
+}
+
+// Load returns the image stored under ref.
+func Load(ref string) (v1.Image, error) {
+	p, err := open(false)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := find(p, ref)
+	if err != nil {
+		return nil, err
+	}
+	return p.Image(desc.Digest)
+}
+
+// List returns the references of all images in the local store.
+func List() ([]string, error) {
+	p, err := open(false)
+	if err != nil {
+		return nil, nil
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]string, 0, len(m.Manifests))
+	for _, d := range m.Manifests {
+		if name, ok := d.Annotations[RefNameAnnotation]; ok {
+			refs = append(refs, name)
+		}
+	}
+	return refs, nil
+}
+
+func find(p layout.Path, ref string) (*v1.Descriptor, error) {
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	// A digest reference matches the image with that digest stored under
+	// any tag of the repository.
+	digest, repo := imgref.Digest(ref), imgref.Repository(ref)
+	for i, d := range m.Manifests {
+		stored := d.Annotations[RefNameAnnotation]
+		if stored == ref || digest != "" && d.Digest.String() == digest && imgref.Repository(stored) == repo {
+			return &m.Manifests[i], nil
+		}
+	}
+	return nil, fmt.Errorf("image %s not found in store %s", ref, Path())
+}
@@ -0,0 +1,217 @@
+// Package inventory records the cache images extracted on the host, into
+// which directory, and the kernel entries each one wrote there, so that
+// caches can be listed, removed one image at a time without touching the
+// entries of others, and upgraded when their tag moves.
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/pin"
+	"github.com/redhat-et/MCU/mcv/pkg/sharedfs"
+)
+
+// Install is an image extracted into a directory.
+type Install struct {
+	Image  string `json:"image"` // Reference extracted, by tag or digest
+	Digest string `json:"digest"`
+	Dir    string `json:"dir"`
+	// Entries are the kernel directories the extraction wrote, relative to
+	// Dir. Other files of Dir, e.g. kernels compiled at run time, are not
+	// the image's.
+	Entries     []string  `json:"entries"`
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// Inventory is the contents of an inventory file.
+type Inventory struct {
+	Installed []Install `json:"installed"`
+}
+
+// Load reads the inventory file at path; a missing file is an empty
+// inventory.
+func Load(path string) (*Inventory, error) {
+	inv := &Inventory{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return inv, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	if err := json.Unmarshal(data, inv); err != nil {
+		return nil, fmt.Errorf("invalid inventory %s: %w", path, err)
+	}
+	return inv, nil
+}
+
+// Save writes the inventory to path, replacing the previous file
+// atomically.
+func (inv *Inventory) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Record adds in, replacing the install of the same digest in the same
+// directory. Extracting an image again that finds it already there
+// writes no entries, so those recorded before are kept.
+func (inv *Inventory) Record(in Install) {
+	in.Dir = filepath.Clean(in.Dir)
+	sort.Strings(in.Entries)
+	for i, old := range inv.Installed {
+		if old.Dir == in.Dir && old.Digest == in.Digest {
+			if len(in.Entries) == 0 {
+				in.Entries = old.Entries
+			}
+			inv.Installed[i] = in
+			return
+		}
+	}
+	inv.Installed = append(inv.Installed, in)
+}
+
+// Find returns the installs of image, a reference as extracted or a
+// digest, in dir, or in any directory if dir is "".
+func (inv *Inventory) Find(image, dir string) []Install {
+	var found []Install
+	for _, in := range inv.Installed {
+		if dir != "" && in.Dir != filepath.Clean(dir) {
+			continue
+		}
+		if in.Is(image) {
+			found = append(found, in)
+		}
+	}
+	return found
+}
+
+// Is reports whether in is of image, a reference as extracted or a digest.
+func (in Install) Is(image string) bool {
+	return key(in.Image) == key(image) || in.Digest == image
+}
+
+// key returns the reference image is recorded under, with an implicit
+// latest tag made explicit.
+func key(image string) string {
+	if k := pin.Key(image); k != "" {
+		return k
+	}
+	return image
+}
+
+// Missing returns how many of the entries of in are no longer there.
+func Missing(in Install) int {
+	missing := 0
+	for _, e := range in.Entries {
+		if _, err := os.Stat(filepath.Join(in.Dir, e)); err != nil {
+			missing++
+		}
+	}
+	return missing
+}
+
+// Owned returns the entries of in no other install in the same directory
+// wrote too.
+func (inv *Inventory) Owned(in Install) []string {
+	claimed := map[string]bool{}
+	for _, other := range inv.Installed {
+		if other.Dir == in.Dir && other.Digest != in.Digest {
+			for _, e := range other.Entries {
+				claimed[e] = true
+			}
+		}
+	}
+	var owned []string
+	for _, e := range in.Entries {
+		if !claimed[e] {
+			owned = append(owned, e)
+		}
+	}
+	return owned
+}
+
+// Remove deletes the entries Owned returns for in, and the directories
+// they leave empty, and forgets in. If the extraction marker of the directory is
+// in's, it is removed too, so that extracting the image again does not
+// skip it. It returns the entries deleted, those already gone aside.
+func (inv *Inventory) Remove(in Install) ([]string, error) {
+	var removed []string
+	for _, e := range inv.Owned(in) {
+		path := filepath.Join(in.Dir, e)
+		if !within(path, in.Dir) {
+			return removed, fmt.Errorf("entry %s is outside of %s", e, in.Dir)
+		}
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := makeWritable(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, e)
+		for d := filepath.Dir(path); within(d, in.Dir); d = filepath.Dir(d) {
+			if os.Remove(d) != nil {
+				break
+			}
+		}
+	}
+	marker, err := sharedfs.ReadMarker(in.Dir)
+	if err == nil && marker != nil && marker.Digest == in.Digest {
+		if err := sharedfs.RemoveMarker(in.Dir); err != nil {
+			return removed, fmt.Errorf("failed to remove extraction marker: %w", err)
+		}
+	}
+	var kept []Install
+	for _, other := range inv.Installed {
+		if other.Dir != in.Dir || other.Digest != in.Digest {
+			kept = append(kept, other)
+		}
+	}
+	inv.Installed = kept
+	return removed, nil
+}
+
+// makeWritable gives the owner full access to the directories of the tree
+// at path, which MCV_EXTRACT_DIR_MODE may have made read-only, so that
+// they can be emptied.
+func makeWritable(path string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if mode := info.Mode().Perm(); mode&0700 != 0700 {
+			return os.Chmod(p, mode|0700)
+		}
+		return nil
+	})
+}
+
+// within reports whether path is below dir.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
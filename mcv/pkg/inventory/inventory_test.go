@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/sharedfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeEntry(t *testing.T, dir, entry string) {
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, entry), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, entry, "kernel.cubin"), []byte(entry), 0644))
+}
+
+func TestRecordAndFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcv", "installed.json")
+	inv, err := Load(path)
+	assert.NoError(t, err)
+	inv.Record(Install{Image: "quay.io/mcv/cache", Digest: "sha256:aaa", Dir: "/c/", Entries: []string{"b", "a"}})
+	inv.Record(Install{Image: "quay.io/mcv/other:v1", Digest: "sha256:bbb", Dir: "/c"})
+	// Extracting an image found already there keeps its entries.
+	inv.Record(Install{Image: "quay.io/mcv/cache:latest", Digest: "sha256:aaa", Dir: "/c"})
+	assert.NoError(t, inv.Save(path))
+
+	inv, err = Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, inv.Installed, 2)
+	found := inv.Find("quay.io/mcv/cache", "")
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, []string{"a", "b"}, found[0].Entries)
+		assert.Equal(t, "/c", found[0].Dir)
+	}
+	assert.Len(t, inv.Find("sha256:bbb", "/c"), 1)
+	assert.Empty(t, inv.Find("quay.io/mcv/other:v1", "/d"))
+	assert.Empty(t, inv.Find("quay.io/mcv/other:v2", ""))
+}
+
+func TestRemove(t *testing.T) {
+	dir := t.TempDir()
+	for _, e := range []string{"aaa", "shared", filepath.Join("vllm", "rank_0_0"), "runtime"} {
+		writeEntry(t, dir, e)
+	}
+	assert.NoError(t, sharedfs.WriteMarker(dir, "sha256:one", 4))
+	inv := &Inventory{}
+	one := Install{Image: "quay.io/mcv/one:v1", Digest: "sha256:one", Dir: dir, Entries: []string{"aaa", "gone", "shared", filepath.Join("vllm", "rank_0_0")}}
+	inv.Record(one)
+	inv.Record(Install{Image: "quay.io/mcv/two:v1", Digest: "sha256:two", Dir: dir, Entries: []string{"shared"}})
+
+	assert.Equal(t, []string{"aaa", "gone", filepath.Join("vllm", "rank_0_0")}, inv.Owned(one))
+	assert.Equal(t, 1, Missing(one))
+	removed, err := inv.Remove(one)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aaa", filepath.Join("vllm", "rank_0_0")}, removed)
+
+	// The other image's entries, those of no image and dir stay.
+	assert.NoDirExists(t, filepath.Join(dir, "aaa"))
+	assert.NoDirExists(t, filepath.Join(dir, "vllm"))
+	assert.DirExists(t, filepath.Join(dir, "shared"))
+	assert.DirExists(t, filepath.Join(dir, "runtime"))
+	marker, err := sharedfs.ReadMarker(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, marker)
+	assert.Empty(t, inv.Find("quay.io/mcv/one:v1", ""))
+	assert.Len(t, inv.Installed, 1)
+
+	_, err = inv.Remove(Install{Digest: "sha256:bad", Dir: dir, Entries: []string{"../outside"}})
+	assert.ErrorContains(t, err, "outside")
+}
+
+func TestRemoveReadOnlyEntry(t *testing.T) {
+	dir := t.TempDir()
+	writeEntry(t, dir, filepath.Join("vllm", "rank_0_0"))
+	entry := filepath.Join(dir, "vllm", "rank_0_0")
+	// As left by an extraction with MCV_EXTRACT_DIR_MODE=0555.
+	assert.NoError(t, os.MkdirAll(filepath.Join(entry, "inner"), 0755))
+	assert.NoError(t, os.Chmod(filepath.Join(entry, "inner"), 0555))
+	assert.NoError(t, os.Chmod(entry, 0555))
+	t.Cleanup(func() { _ = makeWritable(dir) })
+
+	inv := &Inventory{}
+	in := Install{Image: "quay.io/mcv/one:v1", Digest: "sha256:one", Dir: dir, Entries: []string{filepath.Join("vllm", "rank_0_0")}}
+	inv.Record(in)
+	removed, err := inv.Remove(in)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("vllm", "rank_0_0")}, removed)
+	assert.NoDirExists(t, filepath.Join(dir, "vllm"))
+}
@@ -0,0 +1,175 @@
+// Package iolimit lowers the CPU and I/O priority of mcv and throttles the
+// rate it writes extracted files at, so that refreshing a cache on a busy
+// inference node does not cause latency spikes in the workloads it serves.
+package iolimit
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// I/O scheduling classes, as in ionice(1).
+const (
+	ClassRealtime   = "realtime"    // Served first; needs CAP_SYS_ADMIN
+	ClassBestEffort = "best-effort" // The default class, by level
+	ClassIdle       = "idle"        // Served only when no other process does I/O
+)
+
+// Classes returns the supported I/O scheduling classes.
+func Classes() []string {
+	return []string{ClassRealtime, ClassBestEffort, ClassIdle}
+}
+
+// IOPriority is an I/O scheduling class and, for the realtime and
+// best-effort classes, a level from 0 (highest) to 7 (lowest). The zero
+// IOPriority leaves the priority unchanged.
+type IOPriority struct {
+	Class string
+	Level int
+}
+
+// defaultLevel is the level of a class given without one, as ionice does.
+const defaultLevel = 4
+
+// ParseIOPriority parses an I/O priority given as class[:level], e.g.
+// "idle" or "best-effort:7". An empty s leaves the priority unchanged.
+func ParseIOPriority(s string) (IOPriority, error) {
+	if s == "" {
+		return IOPriority{}, nil
+	}
+	class, level, hasLevel := strings.Cut(s, ":")
+	p := IOPriority{Class: class, Level: defaultLevel}
+	switch class {
+	case ClassRealtime, ClassBestEffort:
+	case ClassIdle:
+		if hasLevel {
+			return IOPriority{}, fmt.Errorf("invalid I/O priority %q: the idle class has no level", s)
+		}
+		p.Level = 0
+	default:
+		return IOPriority{}, fmt.Errorf("invalid I/O priority %q: class must be one of %s", s, strings.Join(Classes(), ", "))
+	}
+	if hasLevel {
+		n, err := strconv.Atoi(level)
+		if err != nil || n < 0 || n > 7 {
+			return IOPriority{}, fmt.Errorf("invalid I/O priority %q: level must be 0 to 7", s)
+		}
+		p.Level = n
+	}
+	return p, nil
+}
+
+func (p IOPriority) String() string {
+	if p.Class == ClassIdle || p.Class == "" {
+		return p.Class
+	}
+	return fmt.Sprintf("%s:%d", p.Class, p.Level)
+}
+
+// ValidateNice checks nice is a nice value, from -20 to 19.
+func ValidateNice(nice int) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("invalid nice value %d: must be -20 to 19", nice)
+	}
+	return nil
+}
+
+// SetPriority sets the nice value and the I/O priority of every thread of
+// mcv, and so of those it starts later. A nice value of 0 and the zero
+// IOPriority leave them unchanged.
+func SetPriority(nice int, ioPrio IOPriority) error {
+	if err := ValidateNice(nice); err != nil {
+		return err
+	}
+	if nice == 0 && ioPrio.Class == "" {
+		return nil
+	}
+	return setPriority(nice, ioPrio)
+}
+
+// limit throttles the writers WrapWriter returns, or is nil.
+var limit struct {
+	sync.Mutex
+	l *limiter
+}
+
+// SetWriteLimit limits the writers WrapWriter returns to writing mbps
+// megabytes per second between them, or lifts the limit for mbps 0.
+func SetWriteLimit(mbps float64) {
+	limit.Lock()
+	defer limit.Unlock()
+	limit.l = nil
+	if mbps > 0 {
+		limit.l = newLimiter(mbps * 1e6)
+	}
+}
+
+// WrapWriter returns w throttled to the rate set with SetWriteLimit, or w
+// if there is none.
+func WrapWriter(w io.Writer) io.Writer {
+	limit.Lock()
+	defer limit.Unlock()
+	if limit.l == nil {
+		return w
+	}
+	return &writer{w: w, l: limit.l}
+}
+
+// chunk is the most a throttled writer writes at once, so that writers
+// sharing a limit take turns.
+const chunk = 64 << 10
+
+type writer struct {
+	w io.Writer
+	l *limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), chunk)
+		w.l.wait(n)
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// limiter is a token bucket of bytes, refilled at rate bytes per second up
+// to a quarter of a second of writes.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	burst := max(rate/4, chunk)
+	return &limiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until n bytes may be written. Writers go into debt rather
+// than wait for the bucket to hold n, so the next ones wait it off.
+func (l *limiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
@@ -0,0 +1,44 @@
+package iolimit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants, from linux/ioprio.h.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var ioprioClasses = map[string]int{ClassRealtime: 1, ClassBestEffort: 2, ClassIdle: 3}
+
+// setPriority sets the priorities of each thread, as Linux keeps them per
+// thread: setting the process's would only change the calling thread's.
+func setPriority(nice int, ioPrio IOPriority) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fmt.Errorf("failed to list threads: %w", err)
+	}
+	for _, t := range tasks {
+		tid, err := strconv.Atoi(t.Name())
+		if err != nil {
+			continue
+		}
+		if nice != 0 {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, tid, nice); err != nil {
+				return fmt.Errorf("failed to set nice value %d: %w", nice, err)
+			}
+		}
+		if ioPrio.Class != "" {
+			prio := ioprioClasses[ioPrio.Class]<<ioprioClassShift | ioPrio.Level
+			if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+				return fmt.Errorf("failed to set I/O priority %s: %w", ioPrio, errno)
+			}
+		}
+	}
+	return nil
+}
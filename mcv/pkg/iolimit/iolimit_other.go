@@ -0,0 +1,10 @@
+//go:build !linux
+
+package iolimit
+
+import "errors"
+
+// setPriority is only supported on Linux.
+func setPriority(nice int, ioPrio IOPriority) error {
+	return errors.New("setting the nice value and I/O priority is only supported on Linux")
+}
@@ -0,0 +1,44 @@
+package iolimit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIOPriority(t *testing.T) {
+	for s, want := range map[string]IOPriority{
+		"":              {},
+		"idle":          {Class: ClassIdle},
+		"best-effort":   {Class: ClassBestEffort, Level: 4},
+		"best-effort:7": {Class: ClassBestEffort, Level: 7},
+		"realtime:0":    {Class: ClassRealtime},
+	} {
+		p, err := ParseIOPriority(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, p, s)
+	}
+	for _, s := range []string{"low", "best-effort:8", "best-effort:x", "idle:3"} {
+		_, err := ParseIOPriority(s)
+		assert.Error(t, err, s)
+	}
+	assert.Equal(t, "best-effort:7", IOPriority{Class: ClassBestEffort, Level: 7}.String())
+}
+
+func TestWriteLimit(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Same(t, &buf, WrapWriter(&buf))
+
+	SetWriteLimit(1)
+	defer SetWriteLimit(0)
+	w := WrapWriter(&buf)
+	start := time.Now()
+	n, err := w.Write(make([]byte, 500_000))
+	assert.NoError(t, err)
+	assert.Equal(t, 500_000, n)
+	// The first 250 KB fill the burst, the rest is written at 1 MB/s.
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, 500_000, buf.Len())
+}
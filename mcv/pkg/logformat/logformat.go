@@ -26,19 +26,20 @@ import (
 )
 
 /*
-Default is the default formatter for our logs
+Default is the default formatter for our logs, masking secrets as Redact does
 */
-var Default = &logging.TextFormatter{
+var Default logging.Formatter = &Redactor{&logging.TextFormatter{
 	FullTimestamp:    true,
 	TimestampFormat:  "2006-01-02 15:04:05",
 	ForceColors:      true,
 	CallerPrettyfier: func(frame *runtime.Frame) (string, string) { return "", "" },
-}
+}}
 
 /*
-Debug is the debug formatter for our logs, it prints additional data to aid with debugging
+Debug is the debug formatter for our logs, it prints additional data to aid with debugging.
+Debug logs hold errors and URLs from registries and object stores, so it masks secrets too
 */
-var Debug = &logging.TextFormatter{
+var Debug logging.Formatter = &Redactor{&logging.TextFormatter{
 	FullTimestamp:   true,
 	TimestampFormat: "2006-01-02 15:04:05",
 	ForceColors:     true,
@@ -48,7 +49,7 @@ var Debug = &logging.TextFormatter{
 		fileName := " [" + path.Base(frame.File) + ":" + strconv.Itoa(frame.Line) + "]"
 		return funcName, fileName
 	},
-}
+}}
 
 func ConfigureLogging(logLevel string) error {
 	if logLevel != "" {
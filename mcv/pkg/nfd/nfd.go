@@ -0,0 +1,168 @@
+// Package nfd publishes the GPUs and GPU targets MCV detects to Node
+// Feature Discovery (NFD), so pods can be scheduled on nodes that can run
+// their caches. Features are written as a feature file for NFD's local
+// source, or as a NodeFeature custom resource (NFD v0.14+).
+package nfd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Feature names in NodeFeature objects, for use in NodeFeatureRules.
+const (
+	TargetFeature = "mcv.target" // Flags, one per GPU target such as cuda-90-32
+	GPUFeature    = "mcv.gpu"    // Instances, one per group of identical GPUs
+	HostFeature   = "mcv.host"   // Attributes of the host
+)
+
+// NodeNameLabel tells NFD which node a NodeFeature describes.
+const NodeNameLabel = "nfd.node.kubernetes.io/node-name"
+
+// NodeFeatureResource is the NodeFeature custom resource.
+var NodeFeatureResource = schema.GroupVersionResource{Group: "nfd.k8s-sigs.io", Version: "v1alpha1", Resource: "nodefeatures"}
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// labelPart turns s into something valid in a label name or value.
+func labelPart(s string) string {
+	s = strings.Trim(invalidLabelChars.ReplaceAllString(s, "-"), "-._")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-._")
+	}
+	return s
+}
+
+// Labels returns the node labels for report. NFD prefixes them with
+// feature.node.kubernetes.io/.
+func Labels(report *fleet.HostReport) map[string]string {
+	labels := map[string]string{}
+	for _, t := range report.Targets {
+		labels["mcv.target."+labelPart(t)] = "true"
+	}
+	count := 0
+	for _, g := range report.GPUs {
+		count += len(g.IDs)
+	}
+	if count > 0 {
+		labels["mcv.gpu.count"] = strconv.Itoa(count)
+	}
+	if len(report.GPUs) == 1 {
+		labels["mcv.gpu.type"] = labelPart(report.GPUs[0].GPUType)
+		labels["mcv.gpu.driver"] = labelPart(report.GPUs[0].DriverVersion)
+	}
+	return labels
+}
+
+// WriteFeatureFile writes labels to path, a file in NFD's features.d
+// directory. With a ttl, NFD drops the labels if the file is not rewritten
+// in time.
+func WriteFeatureFile(path string, labels map[string]string, ttl time.Duration) error {
+	var b strings.Builder
+	b.WriteString("# Written by mcv\n")
+	if ttl > 0 {
+		fmt.Fprintf(&b, "# +expiry-time=%s\n", time.Now().Add(ttl).UTC().Format(time.RFC3339))
+	}
+	for _, k := range sortedKeys(labels) {
+		fmt.Fprintf(&b, "%s=%s\n", k, labels[k])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create feature file dir: %w", err)
+	}
+	// NFD may read the file at any time, so it is replaced in one step.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write feature file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write feature file: %w", err)
+	}
+	return nil
+}
+
+// NodeFeatureName is the name of the NodeFeature MCV publishes for node.
+func NodeFeatureName(node string) string {
+	return node + "-mcv"
+}
+
+// NodeFeature returns the NodeFeature object describing report on node.
+func NodeFeature(node, namespace string, report *fleet.HostReport) *unstructured.Unstructured {
+	targets := map[string]interface{}{}
+	for _, t := range report.Targets {
+		targets[labelPart(t)] = map[string]interface{}{}
+	}
+	var gpus []interface{}
+	for _, g := range report.GPUs {
+		attrs := map[string]interface{}{
+			"type":   g.GPUType,
+			"driver": g.DriverVersion,
+			"count":  strconv.Itoa(len(g.IDs)),
+			"ids":    joinInts(g.IDs),
+		}
+		if g.Virtualization != "" {
+			attrs["virtualization"] = g.Virtualization
+		}
+		if g.Profile != "" {
+			attrs["profile"] = g.Profile
+		}
+		gpus = append(gpus, map[string]interface{}{"attributes": attrs})
+	}
+	host := map[string]interface{}{}
+	if report.Host != nil {
+		host["os"] = report.Host.OSRelease
+		host["kernel"] = report.Host.KernelVersion
+		host["arch"] = report.Host.CPUArch
+		host["cgroup"] = report.Host.CgroupVersion
+		host["hypervisor"] = report.Host.Hypervisor
+	}
+
+	labels := map[string]interface{}{}
+	for k, v := range Labels(report) {
+		labels[k] = v
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"features": map[string]interface{}{
+				"flags":      map[string]interface{}{TargetFeature: map[string]interface{}{"elements": targets}},
+				"attributes": map[string]interface{}{HostFeature: map[string]interface{}{"elements": host}},
+				"instances":  map[string]interface{}{GPUFeature: map[string]interface{}{"elements": gpus}},
+			},
+			"labels": labels,
+		},
+	}}
+	obj.SetAPIVersion(NodeFeatureResource.GroupVersion().String())
+	obj.SetKind("NodeFeature")
+	obj.SetName(NodeFeatureName(node))
+	obj.SetNamespace(namespace)
+	obj.SetLabels(map[string]string{NodeNameLabel: node})
+	return obj
+}
+
+func joinInts(ns []int) string {
+	parts := make([]string, 0, len(ns))
+	for _, n := range ns {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package nfd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testReport() *fleet.HostReport {
+	return &fleet.HostReport{
+		Hostname: "gpu-1",
+		Host:     &hostinfo.Info{OSRelease: "RHEL 9.4", KernelVersion: "5.14.0", CgroupVersion: "v2", Hypervisor: "none"},
+		GPUs:     []devices.GPUGroup{{GPUType: "NVIDIA H100 80GB HBM3", DriverVersion: "550.54.15", IDs: []int{0, 1, 2, 3}}},
+		Targets:  []string{"cuda:90:32"},
+	}
+}
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"mcv.target.cuda-90-32": "true",
+		"mcv.gpu.count":         "4",
+		"mcv.gpu.type":          "NVIDIA-H100-80GB-HBM3",
+		"mcv.gpu.driver":        "550.54.15",
+	}, Labels(testReport()))
+}
+
+func TestWriteFeatureFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "features.d", "mcv")
+	assert.NoError(t, WriteFeatureFile(path, Labels(testReport()), time.Minute))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.True(t, strings.HasPrefix(lines[1], "# +expiry-time="))
+	assert.Equal(t, []string{"mcv.gpu.count=4", "mcv.gpu.driver=550.54.15", "mcv.gpu.type=NVIDIA-H100-80GB-HBM3", "mcv.target.cuda-90-32=true"}, lines[2:])
+}
+
+func TestPublish(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{NodeFeatureResource: "NodeFeatureList"})
+	ctx := context.Background()
+
+	report := testReport()
+	assert.NoError(t, Publish(ctx, client, NodeFeature("gpu-1", "mcv", report)))
+	report.Targets = []string{"cuda:80:32"}
+	assert.NoError(t, Publish(ctx, client, NodeFeature("gpu-1", "mcv", report)))
+
+	got, err := client.Resource(NodeFeatureResource).Namespace("mcv").Get(ctx, "gpu-1-mcv", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "gpu-1", got.GetLabels()[NodeNameLabel])
+	targets, _, _ := unstructured.NestedMap(got.Object, "spec", "features", "flags", TargetFeature, "elements")
+	assert.Equal(t, map[string]interface{}{"cuda-80-32": map[string]interface{}{}}, targets)
+	gpus, _, _ := unstructured.NestedSlice(got.Object, "spec", "features", "instances", GPUFeature, "elements")
+	assert.Len(t, gpus, 1)
+	ids, _, _ := unstructured.NestedString(gpus[0].(map[string]interface{}), "attributes", "ids")
+	assert.Equal(t, "0,1,2,3", ids)
+}
@@ -0,0 +1,105 @@
+package nfd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Leader election timings, the client-go defaults.
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// RESTConfig returns the config to reach the cluster with: kubeconfig if
+// set, otherwise the pod's service account.
+func RESTConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		cfg, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("not running in a cluster and no kubeconfig given: %w", err)
+		}
+		return cfg, nil
+	}
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s: %w", kubeconfig, err)
+	}
+	return cfg, nil
+}
+
+// Publish creates the NodeFeature obj, or replaces the spec of the existing
+// one.
+func Publish(ctx context.Context, client dynamic.Interface, obj *unstructured.Unstructured) error {
+	res := client.Resource(NodeFeatureResource).Namespace(obj.GetNamespace())
+	current, err := res.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := res.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create NodeFeature %s: %w", obj.GetName(), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get NodeFeature %s: %w", obj.GetName(), err)
+	}
+	current.Object["spec"] = obj.Object["spec"]
+	labels := current.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range obj.GetLabels() {
+		labels[k] = v
+	}
+	current.SetLabels(labels)
+	if _, err := res.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update NodeFeature %s: %w", obj.GetName(), err)
+	}
+	return nil
+}
+
+// RunElected runs fn once this replica holds the Lease lock in namespace,
+// so that of several replicas publishing for the same node only one
+// writes. fn's context is cancelled when the lease is lost. RunElected
+// returns when fn does, releasing the lease, or when ctx is cancelled.
+// leases is a coordination.k8s.io/v1 client, which is all the lock needs.
+func RunElected(ctx context.Context, leases coordinationv1.CoordinationV1Interface, namespace, lock, identity string, fn func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fnErr error
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: lock, Namespace: namespace},
+			Client:     leases,
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				fnErr = fn(ctx)
+				cancel()
+			},
+			OnStoppedLeading: func() {},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up leader election: %w", err)
+	}
+	elector.Run(ctx)
+	return fnErr
+}
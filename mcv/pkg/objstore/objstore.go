@@ -0,0 +1,417 @@
+// Package objstore uploads files to S3-compatible object storage: Amazon
+// S3, Google Cloud Storage through its XML API with HMAC keys, MinIO and
+// others. Requests are signed with AWS Signature Version 4, so no cloud
+// SDK is needed.
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bucket URL schemes.
+const (
+	SchemeS3  = "s3"
+	SchemeGCS = "gs"
+)
+
+// DefaultRetries is how many times a request failing with a server error,
+// throttling or a connection error is retried, with exponential backoff.
+const DefaultRetries = 4
+
+// Files larger than multipartThreshold are uploaded in parts, since S3
+// rejects single uploads over 5 GiB. Parts are at least minPartSize, and
+// larger if a file would need more than maxParts.
+const (
+	multipartThreshold = 256 << 20
+	minPartSize        = 64 << 20
+	maxParts           = 10000
+)
+
+// emptyHash is the SHA-256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Credentials sign the requests to a bucket. For Google Cloud Storage,
+// they are an HMAC key of a service account.
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// CredentialsFromEnv returns the credentials in AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and, for temporary ones, AWS_SESSION_TOKEN. These
+// static keys are the only credentials supported: web identity tokens
+// (IRSA, GKE workload identity) and instance profiles are not exchanged
+// for keys.
+func CredentialsFromEnv() (Credentials, error) {
+	c := Credentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return Credentials{}, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to write to object storage; " +
+			"web identity and instance profile credentials are not supported")
+	}
+	return c, nil
+}
+
+// Bucket is a bucket, and a prefix in it, files are uploaded to.
+type Bucket struct {
+	Name   string
+	Prefix string // Without leading or trailing slashes
+	Region string
+	// Retries of a request failing with a server error, throttling or a
+	// connection error.
+	Retries int
+
+	endpoint  *url.URL
+	pathStyle bool
+	creds     Credentials
+	client    *http.Client
+	now       func() time.Time
+	backoff   time.Duration // Delay before the first retry, doubled for each
+	threshold int64         // Size above which files are uploaded in parts
+	partSize  int64
+}
+
+// Open returns the bucket named by rawURL, s3://bucket[/prefix] or
+// gs://bucket[/prefix]. endpoint, if set, is the URL of an S3-compatible
+// service to use instead of the default of the scheme, such as MinIO,
+// addressed with path-style URLs. region defaults to AWS_REGION, then
+// us-east-1, for S3 and to auto for Google Cloud Storage.
+func Open(rawURL, endpoint, region string, creds Credentials) (*Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid bucket URL %q: expect s3://bucket[/prefix] or gs://bucket[/prefix]", rawURL)
+	}
+	b := &Bucket{
+		Name:    u.Host,
+		Prefix:  strings.Trim(u.Path, "/"),
+		Region:  region,
+		Retries: DefaultRetries,
+
+		creds:     creds,
+		client:    http.DefaultClient,
+		now:       time.Now,
+		backoff:   time.Second,
+		threshold: multipartThreshold,
+		partSize:  minPartSize,
+	}
+	switch u.Scheme {
+	case SchemeS3:
+		if b.Region == "" {
+			b.Region = os.Getenv("AWS_REGION")
+		}
+		if b.Region == "" {
+			b.Region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", b.Name, b.Region)
+		}
+	case SchemeGCS:
+		if b.Region == "" {
+			b.Region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bucket URL scheme %q: expect %s or %s", u.Scheme, SchemeS3, SchemeGCS)
+	}
+	// Only Amazon's endpoints address the bucket by host name.
+	b.pathStyle = !strings.HasPrefix(endpoint, "https://"+b.Name+".")
+	if b.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/")); err != nil || b.endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint %q", endpoint)
+	}
+	return b, nil
+}
+
+// String returns the URL of the bucket and prefix.
+func (b *Bucket) String() string {
+	return strings.TrimSuffix(fmt.Sprintf("%s/%s/%s", b.endpoint, b.Name, b.Prefix), "/")
+}
+
+// Key returns the key of rel under the bucket's prefix.
+func (b *Bucket) Key(rel string) string {
+	return path.Join(b.Prefix, rel)
+}
+
+// UploadDir uploads the regular files below dir, for which skip, if set,
+// returns false, to the bucket's prefix joined with their path relative
+// to dir. It returns the number of files uploaded.
+func (b *Bucket) UploadDir(ctx context.Context, dir string, skip func(rel string) bool) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip != nil && skip(rel) {
+			return nil
+		}
+		if err := b.PutFile(ctx, b.Key(rel), p); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// PutFile uploads the file at p as key, in parts if it is large. Requests
+// failing with a server error, throttling or a connection error are
+// retried; ctx bounds the whole upload.
+func (b *Bucket) PutFile(ctx context.Context, key, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() > b.threshold {
+		return b.putMultipart(ctx, key, f, st.Size())
+	}
+	_, err = b.putSection(ctx, "upload "+key, b.objectURL(key), f, 0, st.Size())
+	return err
+}
+
+// putSection uploads n bytes of f from off with a PUT to rawURL, and
+// returns the ETag of what was uploaded.
+func (b *Bucket) putSection(ctx context.Context, what, rawURL string, f *os.File, off, n int64) (string, error) {
+	sum := sha256.New()
+	if _, err := io.Copy(sum, io.NewSectionReader(f, off, n)); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	resp, err := b.do(ctx, what, hex.EncodeToString(sum.Sum(nil)), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, rawURL, io.NewSectionReader(f, off, n))
+		if err == nil {
+			req.ContentLength = n
+		}
+		return req, err
+	})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// putMultipart uploads the size bytes of f as key with a multipart upload,
+// aborted if a part fails so the bucket does not keep the parts.
+func (b *Bucket) putMultipart(ctx context.Context, key string, f *os.File, size int64) error {
+	resp, err := b.do(ctx, "start upload of "+key, emptyHash, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, b.objectURL(key)+"?uploads=", nil)
+	})
+	if err != nil {
+		return err
+	}
+	var started initiateMultipartUploadResult
+	err = xml.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	if err != nil || started.UploadID == "" {
+		return fmt.Errorf("failed to start upload of %s: invalid response: %v", key, err)
+	}
+
+	complete := completeMultipartUpload{}
+	partSize := max(b.partSize, (size+maxParts-1)/maxParts)
+	for n, off := 1, int64(0); off < size; n, off = n+1, off+partSize {
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {started.UploadID}}
+		etag, err := b.putSection(ctx, fmt.Sprintf("upload part %d of %s", n, key),
+			b.objectURL(key)+"?"+query.Encode(), f, off, min(partSize, size-off))
+		if err != nil {
+			b.abortMultipart(key, started.UploadID)
+			return err
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: n, ETag: etag})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	completeURL := b.objectURL(key) + "?" + url.Values{"uploadId": {started.UploadID}}.Encode()
+	resp, err = b.do(ctx, "complete upload of "+key, hex.EncodeToString(sum[:]), func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, completeURL, bytes.NewReader(body))
+	})
+	if err != nil {
+		b.abortMultipart(key, started.UploadID)
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 may report a failure to complete in the body of a 200 response.
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if bytes.Contains(reply, []byte("<Error>")) {
+		b.abortMultipart(key, started.UploadID)
+		return fmt.Errorf("failed to complete upload of %s: %s", key, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// abortMultipart discards the parts of a failed multipart upload, on a
+// context of its own since the upload's may be what expired.
+func (b *Bucket) abortMultipart(key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	abortURL := b.objectURL(key) + "?" + url.Values{"uploadId": {uploadID}}.Encode()
+	resp, err := b.do(ctx, "abort upload of "+key, emptyHash, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodDelete, abortURL, nil)
+	})
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// do signs and sends the request newReq returns until it succeeds, or
+// fails with an error other than a server error, throttling or a
+// connection error, or b.Retries retries have failed. newReq is called
+// for each attempt, so the body is read from its start again. what names
+// the request in errors.
+func (b *Bucket) do(ctx context.Context, what, payloadHash string, newReq func() (*http.Request, error)) (*http.Response, error) {
+	delay := b.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		b.sign(req, payloadHash)
+		resp, err := b.client.Do(req)
+		retry := attempt < b.Retries && ctx.Err() == nil
+		if err == nil {
+			if resp.StatusCode/100 == 2 {
+				return resp, nil
+			}
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+			retry = retry && (resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests)
+		}
+		if !retry {
+			return nil, fmt.Errorf("failed to %s: %w", what, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to %s: %w", what, err)
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+func (b *Bucket) objectURL(key string) string {
+	u := *b.endpoint
+	if b.pathStyle {
+		u.Path += "/" + b.Name
+	}
+	u.Path += "/" + key
+	u.RawPath = u.Path[:len(u.Path)-len(key)] + encodePath(key)
+	return u.String()
+}
+
+// sign adds the AWS Signature Version 4 of req, whose payload hashes to
+// payloadHash, to its headers. It signs the host and every header set.
+func (b *Bucket) sign(req *http.Request, payloadHash string) {
+	now := b.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonical.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), b.Region)
+	requestHash := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+b.creds.SecretKey), now.Format("20060102"))
+	for _, part := range []string{b.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.creds.AccessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// encodePath escapes every byte of key but the unreserved characters and
+// slashes, as Signature Version 4 requires of S3 object keys.
+func encodePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,10 @@
+//go:build mcv_pciids
+
+package pciids
+
+import _ "embed"
+
+// data is the pci.ids file fetched by make pci-ids.
+//
+//go:embed pci.ids
+var data []byte
@@ -0,0 +1,5 @@
+//go:build !mcv_pciids
+
+package pciids
+
+var data []byte
@@ -0,0 +1,44 @@
+// Package pciids provides the PCI IDs database compiled into mcv with the
+// mcv_pciids build tag, for container images that do not ship one. GPU model
+// names are looked up in it.
+package pciids
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Embedded reports whether mcv was built with a PCI IDs database.
+func Embedded() bool {
+	return len(data) > 0
+}
+
+// Install writes the embedded database to ~/.cache/pci.ids, where the PCI
+// IDs library looks first, unless a database is already there.
+func Install() error {
+	if !Embedded() {
+		return nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to find the PCI IDs cache: %w", err)
+	}
+	path := filepath.Join(home, ".cache", "pci.ids")
+	if _, err := os.Stat(path); err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to install the PCI IDs database: %w", err)
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to install the PCI IDs database: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to install the PCI IDs database: %w", err)
+	}
+	return nil
+}
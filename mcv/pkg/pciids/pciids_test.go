@@ -0,0 +1,32 @@
+package pciids
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstall(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".cache", "pci.ids")
+
+	defer func(d []byte) { data = d }(data)
+	data = nil
+	assert.NoError(t, Install())
+	assert.NoFileExists(t, path)
+
+	data = []byte("10de  NVIDIA Corporation\n")
+	assert.NoError(t, Install())
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// A database already there is kept.
+	assert.NoError(t, os.WriteFile(path, []byte("local\n"), 0644))
+	assert.NoError(t, Install())
+	got, _ = os.ReadFile(path)
+	assert.Equal(t, "local\n", string(got))
+}
@@ -0,0 +1,105 @@
+// Package pin records the digest each image tag resolved to the first time
+// it was extracted, so a tag that is later moved to another image is
+// refused instead of silently extracted.
+package pin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/imgref"
+)
+
+// ErrDrift is returned when a tag resolves to a different digest than the
+// one pinned for it.
+var ErrDrift = errors.New("image digest drifted from its pin")
+
+// Pin is the digest recorded for an image tag.
+type Pin struct {
+	Digest   string    `json:"digest"`
+	PinnedAt time.Time `json:"pinnedAt"`
+}
+
+// Lock is the contents of a lock file, keyed by image reference.
+type Lock struct {
+	Pins map[string]Pin `json:"pins"`
+}
+
+// Load reads the lock file at path; a missing file is an empty lock.
+func Load(path string) (*Lock, error) {
+	l := &Lock{Pins: map[string]Pin{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", path, err)
+	}
+	if l.Pins == nil {
+		l.Pins = map[string]Pin{}
+	}
+	return l, nil
+}
+
+// Save writes the lock to path, replacing the previous file atomically.
+func (l *Lock) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create lock file directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Key returns the lock key for imageName: the reference with an implicit
+// latest tag made explicit. Digest references need no pin and return "".
+// OCI layout references have no implicit tag and are their own key.
+func Key(imageName string) string {
+	if strings.Contains(imageName, "@") {
+		return ""
+	}
+	if imgref.IsLayout(imageName) {
+		return imageName
+	}
+	if i := strings.LastIndex(imageName, ":"); i < 0 || strings.Contains(imageName[i:], "/") {
+		return imageName + ":latest"
+	}
+	return imageName
+}
+
+// Check returns ErrDrift if imageName is pinned to a digest other than
+// digest. Unpinned images and digest references always pass.
+func (l *Lock) Check(imageName, digest string) error {
+	key := Key(imageName)
+	if key == "" {
+		return nil
+	}
+	if p, ok := l.Pins[key]; ok && p.Digest != digest {
+		return fmt.Errorf("%w: %s is pinned to %s but resolves to %s", ErrDrift, key, p.Digest, digest)
+	}
+	return nil
+}
+
+// Set pins imageName to digest; it reports whether the pin changed.
+func (l *Lock) Set(imageName, digest string) bool {
+	key := Key(imageName)
+	if key == "" || l.Pins[key].Digest == digest {
+		return false
+	}
+	l.Pins[key] = Pin{Digest: digest, PinnedAt: time.Now().UTC()}
+	return true
+}
@@ -0,0 +1,36 @@
+package pin
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "quay.io/mcv/cache:latest", Key("quay.io/mcv/cache"))
+	assert.Equal(t, "localhost:5000/cache:latest", Key("localhost:5000/cache"))
+	assert.Equal(t, "localhost:5000/cache:v1", Key("localhost:5000/cache:v1"))
+	assert.Equal(t, "", Key("quay.io/mcv/cache@sha256:abc"))
+}
+
+func TestLockCheckAndSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcv", "pins.json")
+	l, err := Load(path)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Check("quay.io/mcv/cache:v1", "sha256:aaa"))
+	assert.True(t, l.Set("quay.io/mcv/cache:v1", "sha256:aaa"))
+	assert.False(t, l.Set("quay.io/mcv/cache:v1", "sha256:aaa"))
+	assert.NoError(t, l.Save(path))
+
+	l, err = Load(path)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Check("quay.io/mcv/cache:v1", "sha256:aaa"))
+	err = l.Check("quay.io/mcv/cache:v1", "sha256:bbb")
+	assert.True(t, errors.Is(err, ErrDrift))
+	assert.NoError(t, l.Check("quay.io/mcv/cache@sha256:bbb", "sha256:bbb"))
+
+	assert.True(t, l.Set("quay.io/mcv/cache:v1", "sha256:bbb"))
+	assert.NoError(t, l.Check("quay.io/mcv/cache:v1", "sha256:bbb"))
+}
@@ -0,0 +1,127 @@
+// Package placement maps sets of GPUs to cache directories, so hosts with
+// per-GPU or per-NUMA-node cache roots get the kernels their GPUs run from
+// a directory close to them.
+package placement
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"gopkg.in/yaml.v3"
+)
+
+// Placement is a cache directory and the GPUs that read from it, chosen by
+// ID or by the NUMA node they are attached to.
+type Placement struct {
+	Dir  string `yaml:"dir"`
+	GPUs string `yaml:"gpus,omitempty"` // IDs and ranges, e.g. "0-3,6"
+	NUMA *int   `yaml:"numa,omitempty"`
+}
+
+// File is a placement config.
+type File struct {
+	Placements []Placement `yaml:"placements"`
+}
+
+// Load reads and validates a placement config.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read placement file: %w", err)
+	}
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid placement file %s: %w", path, err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid placement file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Validate checks that every placement has its own absolute directory and
+// selects GPUs either by ID or by NUMA node.
+func (f *File) Validate() error {
+	if len(f.Placements) == 0 {
+		return errors.New("no placements")
+	}
+	dirs := map[string]bool{}
+	for i, p := range f.Placements {
+		if !filepath.IsAbs(p.Dir) {
+			return fmt.Errorf("placement %d: dir %q must be absolute", i+1, p.Dir)
+		}
+		if dirs[filepath.Clean(p.Dir)] {
+			return fmt.Errorf("placement %d: dir %s is used twice", i+1, p.Dir)
+		}
+		dirs[filepath.Clean(p.Dir)] = true
+		if (p.GPUs == "") == (p.NUMA == nil) {
+			return fmt.Errorf("placement %d: set one of gpus or numa", i+1)
+		}
+		if _, err := ParseIDs(p.GPUs); err != nil {
+			return fmt.Errorf("placement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// ParseIDs parses a list of GPU IDs and ranges such as "0-3,6".
+func ParseIDs(s string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid GPU ID %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid GPU range %q", part)
+			}
+		}
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Select returns the GPUs of gpus that p covers. numaNode reports the NUMA
+// node of a GPU, normally devices.TritonGPUInfo.NUMANode.
+func (p Placement) Select(gpus []devices.TritonGPUInfo, numaNode func(devices.TritonGPUInfo) int) []devices.TritonGPUInfo {
+	want := map[int]bool{}
+	ids, _ := ParseIDs(p.GPUs)
+	for _, id := range ids {
+		want[id] = true
+	}
+	var selected []devices.TritonGPUInfo
+	for _, g := range gpus {
+		if (p.NUMA != nil && numaNode(g) == *p.NUMA) || want[g.ID] {
+			selected = append(selected, g)
+		}
+	}
+	return selected
+}
+
+// RunsOn reports whether a kernel built for target runs on any of gpus.
+func RunsOn(target cache.Target, gpus []devices.TritonGPUInfo) bool {
+	for _, g := range gpus {
+		if target.Backend == g.Backend && cache.ConvertArchToString(target.Arch) == g.Arch && target.WarpSize == g.WarpSize {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package placement
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/benchgen"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "placement.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`placements:
+  - dir: /mnt/nvme0/triton
+    gpus: 0-3
+  - dir: /mnt/nvme1/triton
+    numa: 1
+`), 0644))
+	f, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, f.Placements, 2)
+	assert.Equal(t, 1, *f.Placements[1].NUMA)
+
+	for _, bad := range []string{
+		"placements:\n  - dir: relative\n    gpus: 0\n",
+		"placements:\n  - dir: /a\n    gpus: 0\n    numa: 0\n",
+		"placements:\n  - dir: /a\n",
+		"placements:\n  - dir: /a\n    gpus: 3-1\n",
+		"placements:\n  - dir: /a\n    gpus: 0\n  - dir: /a/\n    gpus: 1\n",
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(bad), 0644))
+		_, err := Load(path)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseIDs(t *testing.T) {
+	ids, err := ParseIDs("0-3, 6")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 6}, ids)
+}
+
+func TestSelect(t *testing.T) {
+	var gpus []devices.TritonGPUInfo
+	for id := 0; id < 8; id++ {
+		gpus = append(gpus, devices.TritonGPUInfo{ID: id})
+	}
+	numa := func(g devices.TritonGPUInfo) int { return g.ID / 4 }
+	node := 1
+
+	ids := func(gpus []devices.TritonGPUInfo) []int {
+		var ids []int
+		for _, g := range gpus {
+			ids = append(ids, g.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int{0, 1, 2, 3}, ids(Placement{GPUs: "0-3"}.Select(gpus, numa)))
+	assert.Equal(t, []int{4, 5, 6, 7}, ids(Placement{NUMA: &node}.Select(gpus, numa)))
+}
+
+func TestPruneToPlacement(t *testing.T) {
+	dir := t.TempDir()
+	_, err := benchgen.Generate(dir, benchgen.Options{Kernels: 2, BinarySize: 16, Backend: "cuda", Arch: "90", Seed: 1})
+	assert.NoError(t, err)
+	_, err = benchgen.Generate(dir, benchgen.Options{Kernels: 3, BinarySize: 16, Backend: "cuda", Arch: "80", Seed: 2})
+	assert.NoError(t, err)
+
+	h100 := []devices.TritonGPUInfo{{ID: 0, Backend: "cuda", Arch: "90", WarpSize: 32}}
+	removed, err := cache.PruneTritonKernels(dir, func(target cache.Target) bool { return RunsOn(target, h100) })
+	assert.NoError(t, err)
+	assert.Len(t, removed, 3)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
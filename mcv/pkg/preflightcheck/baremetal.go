@@ -0,0 +1,236 @@
+package preflightcheck
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+	logging "github.com/sirupsen/logrus"
+)
+
+// CheckStatus is the outcome of a single host preflight probe.
+type CheckStatus string
+
+const (
+	StatusPass CheckStatus = "pass"
+	StatusWarn CheckStatus = "warn"
+	StatusFail CheckStatus = "fail"
+	StatusSkip CheckStatus = "skip"
+)
+
+// CheckResult is the result of one host preflight probe.
+type CheckResult struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail"`
+}
+
+// HostReport collects the results of the baremetal host probes.
+type HostReport struct {
+	Checks []CheckResult `json:"checks"`
+}
+
+// Failed reports whether any probe failed.
+func (r *HostReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Log writes each probe result at a level matching its status.
+func (r *HostReport) Log() {
+	for _, c := range r.Checks {
+		entry := logging.WithField("check", c.Name)
+		switch c.Status {
+		case StatusFail:
+			entry.Errorf("%s: %s", c.Status, c.Detail)
+		case StatusWarn:
+			diag.Warnf(diag.HostCheckWarning, "%s: %s: %s", c.Name, c.Status, c.Detail)
+		default:
+			entry.Debugf("%s: %s", c.Status, c.Detail)
+		}
+	}
+}
+
+// RunBaremetalChecks probes host settings that affect GPU workloads on
+// baremetal: IOMMU, PCIe ACS override, hugepages, resizable BAR and NUMA
+// balancing.
+func RunBaremetalChecks() *HostReport {
+	return runHostChecks(hostfs.Root())
+}
+
+func runHostChecks(root string) *HostReport {
+	return &HostReport{Checks: []CheckResult{
+		checkIOMMU(root),
+		checkACSOverride(root),
+		checkHugepages(root),
+		checkResizableBAR(root),
+		checkNUMABalancing(root),
+	}}
+}
+
+func checkIOMMU(root string) CheckResult {
+	r := CheckResult{Name: "iommu"}
+	groups, err := os.ReadDir(filepath.Join(root, "sys/kernel/iommu_groups"))
+	if err != nil || len(groups) == 0 {
+		r.Status = StatusWarn
+		r.Detail = "IOMMU is not enabled; device passthrough and isolation are unavailable"
+		return r
+	}
+	r.Status = StatusPass
+	r.Detail = fmt.Sprintf("IOMMU enabled with %d group(s)", len(groups))
+	return r
+}
+
+func checkACSOverride(root string) CheckResult {
+	r := CheckResult{Name: "pcie-acs"}
+	cmdline, err := os.ReadFile(filepath.Join(root, "proc/cmdline"))
+	if err != nil {
+		r.Status = StatusSkip
+		r.Detail = "kernel command line unavailable"
+		return r
+	}
+	if strings.Contains(string(cmdline), "pcie_acs_override") {
+		r.Status = StatusWarn
+		r.Detail = "pcie_acs_override is set; IOMMU groups do not reflect real device isolation"
+		return r
+	}
+	r.Status = StatusPass
+	r.Detail = "no ACS override"
+	return r
+}
+
+func checkHugepages(root string) CheckResult {
+	r := CheckResult{Name: "hugepages"}
+	f, err := os.Open(filepath.Join(root, "proc/meminfo"))
+	if err != nil {
+		r.Status = StatusSkip
+		r.Detail = "meminfo unavailable"
+		return r
+	}
+	defer f.Close()
+
+	values := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if n, err := strconv.Atoi(fields[1]); err == nil {
+			values[strings.TrimSuffix(fields[0], ":")] = n
+		}
+	}
+
+	total, free := values["HugePages_Total"], values["HugePages_Free"]
+	switch {
+	case total == 0:
+		r.Status = StatusWarn
+		r.Detail = "no hugepages reserved"
+	case free == 0:
+		// Extraction needs none, and reserved hugepages are often all
+		// taken by DPDK or VMs.
+		r.Status = StatusWarn
+		r.Detail = fmt.Sprintf("all %d hugepages are in use", total)
+	default:
+		r.Status = StatusPass
+		r.Detail = fmt.Sprintf("%d of %d hugepages free", free, total)
+	}
+	return r
+}
+
+const (
+	pciExtCapStart     = 0x100
+	pciExtCapResizeBAR = 0x15
+	pciClassDisplay    = "0x03"
+	pciClassProcessing = "0x12"
+)
+
+func checkResizableBAR(root string) CheckResult {
+	r := CheckResult{Name: "resizable-bar"}
+	devDir := filepath.Join(root, "sys/bus/pci/devices")
+	devs, err := os.ReadDir(devDir)
+	if err != nil {
+		r.Status = StatusSkip
+		r.Detail = "PCI sysfs unavailable"
+		return r
+	}
+
+	var gpus, enabled, unreadable int
+	for _, d := range devs {
+		class, _ := os.ReadFile(filepath.Join(devDir, d.Name(), "class"))
+		c := strings.TrimSpace(string(class))
+		if !strings.HasPrefix(c, pciClassDisplay) && !strings.HasPrefix(c, pciClassProcessing) {
+			continue
+		}
+		gpus++
+		cfg, err := os.ReadFile(filepath.Join(devDir, d.Name(), "config"))
+		if err != nil || len(cfg) <= pciExtCapStart {
+			// Extended config space is only readable by root.
+			unreadable++
+			continue
+		}
+		if hasExtCapability(cfg, pciExtCapResizeBAR) {
+			enabled++
+		}
+	}
+
+	switch {
+	case gpus == 0:
+		r.Status = StatusSkip
+		r.Detail = "no GPU devices found"
+	case unreadable == gpus:
+		r.Status = StatusSkip
+		r.Detail = "PCI extended config space not readable (run as root)"
+	case enabled+unreadable < gpus:
+		r.Status = StatusWarn
+		r.Detail = fmt.Sprintf("resizable BAR not available on %d of %d GPU(s)", gpus-enabled-unreadable, gpus)
+	default:
+		r.Status = StatusPass
+		r.Detail = fmt.Sprintf("resizable BAR available on %d GPU(s)", enabled)
+	}
+	return r
+}
+
+// hasExtCapability walks the PCIe extended capability list looking for id.
+func hasExtCapability(cfg []byte, id uint16) bool {
+	offset := pciExtCapStart
+	for i := 0; i < 48 && offset >= pciExtCapStart && offset+4 <= len(cfg); i++ {
+		header := binary.LittleEndian.Uint32(cfg[offset:])
+		if header == 0 {
+			return false
+		}
+		if uint16(header&0xffff) == id {
+			return true
+		}
+		offset = int(header>>20) & 0xffc
+	}
+	return false
+}
+
+func checkNUMABalancing(root string) CheckResult {
+	r := CheckResult{Name: "numa-balancing"}
+	data, err := os.ReadFile(filepath.Join(root, "proc/sys/kernel/numa_balancing"))
+	if err != nil {
+		r.Status = StatusSkip
+		r.Detail = "NUMA balancing not supported by this kernel"
+		return r
+	}
+	if strings.TrimSpace(string(data)) != "0" {
+		r.Status = StatusWarn
+		r.Detail = "automatic NUMA balancing is enabled and may migrate pages used by GPU workloads"
+		return r
+	}
+	r.Status = StatusPass
+	r.Detail = "automatic NUMA balancing disabled"
+	return r
+}
@@ -0,0 +1,48 @@
+package preflightcheck
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeHostFile(t *testing.T, root, path string, content []byte) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+	assert.NoError(t, os.WriteFile(full, content, 0644))
+}
+
+func TestRunHostChecks(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sys/kernel/iommu_groups/0"), 0755))
+	writeHostFile(t, root, "proc/cmdline", []byte("BOOT_IMAGE=/vmlinuz pcie_acs_override=downstream"))
+	writeHostFile(t, root, "proc/meminfo", []byte("MemTotal: 1024 kB\nHugePages_Total: 4\nHugePages_Free: 0\n"))
+	writeHostFile(t, root, "proc/sys/kernel/numa_balancing", []byte("0\n"))
+
+	// One GPU exposing the resizable BAR extended capability.
+	cfg := make([]byte, 4096)
+	binary.LittleEndian.PutUint32(cfg[0x100:], uint32(pciExtCapResizeBAR)|1<<16)
+	writeHostFile(t, root, "sys/bus/pci/devices/0000:01:00.0/class", []byte("0x030000\n"))
+	writeHostFile(t, root, "sys/bus/pci/devices/0000:01:00.0/config", cfg)
+
+	report := runHostChecks(root)
+	statuses := map[string]CheckStatus{}
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	assert.Equal(t, map[string]CheckStatus{
+		"iommu":          StatusPass,
+		"pcie-acs":       StatusWarn,
+		"hugepages":      StatusWarn,
+		"resizable-bar":  StatusPass,
+		"numa-balancing": StatusPass,
+	}, statuses)
+	assert.False(t, report.Failed())
+
+	report.Checks = append(report.Checks, CheckResult{Name: "other", Status: StatusFail})
+	assert.True(t, report.Failed())
+}
@@ -0,0 +1,81 @@
+package preflightcheck
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices/fixtures"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// replayGPUs returns the GPUs detected on a recorded host.
+func replayGPUs(t *testing.T, name string) []devices.TritonGPUInfo {
+	f, err := fixtures.Load(name)
+	assert.NoError(t, err)
+	defer devices.UseFixture(f)()
+
+	dev := devices.Startup(config.GPU)
+	if !assert.NotNil(t, dev, name) {
+		return nil
+	}
+	defer dev.Shutdown()
+	gpus, err := dev.GetAllGPUInfo()
+	assert.NoError(t, err, name)
+	return gpus
+}
+
+func TestPreflightOnRecordedHosts(t *testing.T) {
+	labels := func(summary string) map[string]string {
+		return map[string]string{"cache.triton.image/summary": summary}
+	}
+	summaries := map[string]string{
+		"cuda-80":   `{"targets":[{"backend":"cuda","arch":"80","warp_size":32}]}`,
+		"cuda-90":   `{"targets":[{"backend":"cuda","arch":"90","warp_size":32}]}`,
+		"gfx90a":    `{"targets":[{"backend":"hip","arch":"gfx90a","warp_size":64}]}`,
+		"gfx942":    `{"targets":[{"backend":"hip","arch":"gfx942","warp_size":64}]}`,
+		"multi-gpu": `{"targets":[{"backend":"cuda","arch":"80","warp_size":32},{"backend":"cuda","arch":"90","warp_size":32},{"backend":"hip","arch":"gfx942","warp_size":64}]}`,
+	}
+	// The caches each recorded host runs.
+	compatible := map[string][]string{
+		"a100-sxm4-80gb": {"cuda-80", "multi-gpu"},
+		"h100-sxm5-80gb": {"cuda-90", "multi-gpu"},
+		"mi250x":         {"gfx90a"},
+		"mi300x":         {"gfx942", "multi-gpu"},
+	}
+	assert.ElementsMatch(t, fixtures.Names(), []string{"a100-sxm4-80gb", "h100-sxm5-80gb", "mi250x", "mi300x"})
+
+	for host, runs := range compatible {
+		gpus := replayGPUs(t, host)
+		assert.Len(t, gpus, 8, host)
+		for name, summary := range summaries {
+			matched, _, err := CompareCacheSummaryLabelToGPU(nil, labels(summary), gpus)
+			if slices.Contains(runs, name) {
+				assert.NoError(t, err, "%s on %s", name, host)
+				assert.Len(t, matched, len(gpus), "%s on %s", name, host)
+			} else {
+				assert.Error(t, err, "%s on %s", name, host)
+				assert.Empty(t, matched, "%s on %s", name, host)
+			}
+		}
+	}
+}
+
+func TestPreflightPTXOnRecordedHosts(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+
+	// Triton entries also need the driver the cache was compiled with.
+	entry := func(arch string, ptx int) []cache.TritonCacheMetadata {
+		return []cache.TritonCacheMetadata{{Hash: "h", Target: cache.Target{Backend: "cuda", Arch: arch, WarpSize: 32}, PtxVersion: ptx}}
+	}
+	a100 := replayGPUs(t, "a100-sxm4-80gb")
+	h100 := replayGPUs(t, "h100-sxm5-80gb")
+
+	assert.NoError(t, CompareTritonEntriesToGPU(entry("80", 535), a100))
+	assert.Error(t, CompareTritonEntriesToGPU(entry("80", 550), a100))
+	assert.NoError(t, CompareTritonEntriesToGPU(entry("90", 550), h100))
+	assert.Error(t, CompareTritonEntriesToGPU(entry("80", 550), h100))
+}
@@ -0,0 +1,75 @@
+package preflightcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+)
+
+// ManifestTritonEntries returns the Triton entries of the manifest of a
+// cache of cacheType at manifestPath, those nested in vLLM entries for a
+// vLLM cache. Each records the target its kernel was built for, with the
+// arch as a string even if the manifest holds a number.
+func ManifestTritonEntries(manifestPath, cacheType string) ([]cache.TritonCacheMetadata, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest file: %w", err)
+	}
+
+	var entries []cache.TritonCacheMetadata
+	switch cacheType {
+	case constants.Triton:
+		var manifest cache.TritonManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest JSON: %w", err)
+		}
+		entries = manifest.Triton
+	case constants.VLLM:
+		var manifest struct {
+			VLLM []struct {
+				Triton []cache.TritonCacheMetadata `json:"triton"`
+			} `json:"vllm"`
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse VLLM manifest JSON: %w", err)
+		}
+		for _, v := range manifest.VLLM {
+			entries = append(entries, v.Triton...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cache type: %s", cacheType)
+	}
+
+	for i := range entries {
+		if entries[i].Arch != nil {
+			entries[i].Arch = cache.ConvertArchToString(entries[i].Arch)
+		}
+	}
+	return entries, nil
+}
+
+// SplitTritonEntriesByGPU splits entries into those built for the target
+// of one of the GPUs, as the summary check matches them, and whose shared
+// memory it guarantees, and the others.
+func SplitTritonEntriesByGPU(entries []cache.TritonCacheMetadata, devInfo []devices.TritonGPUInfo) (compatible, incompatible []cache.TritonCacheMetadata) {
+	for _, entry := range entries {
+		match := false
+		for _, gpu := range devInfo {
+			if entry.Backend == gpu.Backend && entry.Arch == gpu.Arch && entry.WarpSize == gpu.WarpSize &&
+				gpu.SupportsSharedMemory(entry.Shared) {
+				match = true
+				break
+			}
+		}
+		if match {
+			compatible = append(compatible, entry)
+		} else {
+			incompatible = append(incompatible, entry)
+		}
+	}
+	return compatible, incompatible
+}
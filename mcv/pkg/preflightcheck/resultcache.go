@@ -0,0 +1,131 @@
+package preflightcheck
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	logging "github.com/sirupsen/logrus"
+)
+
+// CachedResult is a preflight outcome for one image digest on one set of GPUs.
+type CachedResult struct {
+	Digest      string    `json:"digest"`
+	Fingerprint string    `json:"fingerprint"`
+	Drivers     string    `json:"drivers,omitempty"` // GPU driver versions the result was computed with
+	Matched     []int     `json:"matched"`
+	Unmatched   []int     `json:"unmatched"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type resultCache struct {
+	Results map[string]CachedResult `json:"results"`
+}
+
+// driverVersions returns the loaded GPU driver versions; replaced in tests.
+var driverVersions = devices.LoadedDriverVersions
+
+// GPUFingerprint identifies the GPU properties and driver versions a
+// preflight result depends on, so cached results are invalidated when the
+// hardware or its drivers change.
+func GPUFingerprint(devInfo []devices.TritonGPUInfo) string {
+	return gpuFingerprint(devInfo, driverVersions())
+}
+
+func gpuFingerprint(devInfo []devices.TritonGPUInfo, drivers string) string {
+	keys := make([]string, 0, len(devInfo))
+	for _, g := range devInfo {
+		keys = append(keys, fmt.Sprintf("%d/%s/%s/%d/%d/%s/%s",
+			g.ID, g.Backend, g.Arch, g.WarpSize, g.PTXVersion, g.Virtualization, g.Profile))
+	}
+	sort.Strings(keys)
+	sum := sha256.New()
+	sum.Write([]byte("drivers " + drivers + "\n"))
+	for _, k := range keys {
+		sum.Write([]byte(k + "\n"))
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func resultKey(digest, fingerprint string) string {
+	return digest + "@" + fingerprint
+}
+
+func loadResultCache(path string) *resultCache {
+	c := &resultCache{Results: map[string]CachedResult{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Results == nil {
+		logging.Debugf("Ignoring unreadable preflight cache %s: %v", path, err)
+		return &resultCache{Results: map[string]CachedResult{}}
+	}
+	return c
+}
+
+// LookupResult returns a cached preflight result for digest on the given
+// GPUs if one exists and is younger than ttl.
+func LookupResult(path, digest string, devInfo []devices.TritonGPUInfo, ttl time.Duration) (*CachedResult, bool) {
+	if ttl <= 0 || path == "" || digest == "" {
+		return nil, false
+	}
+	r, ok := loadResultCache(path).Results[resultKey(digest, GPUFingerprint(devInfo))]
+	if !ok || time.Since(r.Timestamp) > ttl {
+		return nil, false
+	}
+	return &r, true
+}
+
+// StoreResult records a preflight result, dropping entries older than ttl.
+// The file is replaced atomically so concurrent callers on a node never see
+// a partial write.
+func StoreResult(path, digest string, devInfo []devices.TritonGPUInfo, matched, unmatched []int, ttl time.Duration) error {
+	if ttl <= 0 || path == "" || digest == "" {
+		return nil
+	}
+
+	c := loadResultCache(path)
+	for k, r := range c.Results {
+		if time.Since(r.Timestamp) > ttl {
+			delete(c.Results, k)
+		}
+	}
+	drivers := driverVersions()
+	fp := gpuFingerprint(devInfo, drivers)
+	c.Results[resultKey(digest, fp)] = CachedResult{
+		Digest:      digest,
+		Fingerprint: fp,
+		Drivers:     drivers,
+		Matched:     matched,
+		Unmatched:   unmatched,
+		Timestamp:   time.Now(),
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal preflight cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create preflight cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".preflight-*")
+	if err != nil {
+		return fmt.Errorf("failed to create preflight cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write preflight cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write preflight cache: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,43 @@
+package preflightcheck
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "preflight.json")
+	gpus := []devices.TritonGPUInfo{{ID: 0, Backend: "cuda", Arch: "80", WarpSize: 32}}
+
+	_, ok := LookupResult(path, "sha256:aaa", gpus, time.Minute)
+	assert.False(t, ok)
+
+	assert.NoError(t, StoreResult(path, "sha256:aaa", gpus, []int{0}, nil, time.Minute))
+	r, ok := LookupResult(path, "sha256:aaa", gpus, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, []int{0}, r.Matched)
+
+	// A new digest or different GPUs invalidate the cached result.
+	_, ok = LookupResult(path, "sha256:bbb", gpus, time.Minute)
+	assert.False(t, ok)
+	otherGPUs := []devices.TritonGPUInfo{{ID: 0, Backend: "cuda", Arch: "90", WarpSize: 32}}
+	_, ok = LookupResult(path, "sha256:aaa", otherGPUs, time.Minute)
+	assert.False(t, ok)
+
+	// So does a driver upgrade.
+	driverVersions = func() string { return "nvidia=560.28.03" }
+	defer func() { driverVersions = devices.LoadedDriverVersions }()
+	_, ok = LookupResult(path, "sha256:aaa", gpus, time.Minute)
+	assert.False(t, ok)
+	driverVersions = devices.LoadedDriverVersions
+
+	// Expired and disabled lookups miss.
+	_, ok = LookupResult(path, "sha256:aaa", gpus, time.Nanosecond)
+	assert.False(t, ok)
+	_, ok = LookupResult(path, "sha256:aaa", gpus, 0)
+	assert.False(t, ok)
+}
@@ -0,0 +1,115 @@
+package preflightcheck
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/redhat-et/MCU/mcv/pkg/cache"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
+	logging "github.com/sirupsen/logrus"
+)
+
+// LoadSummary returns the cache summary stored in the image labels. When the
+// summary was too large for a label, the label holds a pointer and the
+// summary is read from the referenced metadata layer.
+func LoadSummary(img v1.Image, labels map[string]string) (*cache.Summary, error) {
+	summaryStr, ok := labels["cache.triton.image/summary"]
+	if !ok {
+		if summaryStr, ok = labels["cache.vllm.image/summary"]; !ok {
+			return nil, failure.New(failure.NoCache, errors.New("image missing cache summary label"))
+		}
+	}
+
+	summary, ptr, err := cache.ParseSummaryLabel(summaryStr)
+	if err != nil {
+		return nil, err
+	}
+	if ptr == nil {
+		return summary, nil
+	}
+	if img == nil {
+		return nil, errors.New("summary is stored outside the labels but no image was provided")
+	}
+
+	data, err := readExternalSummary(img, ptr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read external summary %s: %w", ptr.Path, err)
+	}
+	summary = &cache.Summary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, fmt.Errorf("failed to parse external summary: %w", err)
+	}
+	return summary, nil
+}
+
+// SummaryJSON returns the summary the summary label value holds, read from
+// the metadata layer it points to when it was too large for a label.
+func SummaryJSON(img v1.Image, value string) ([]byte, error) {
+	_, ptr, err := cache.ParseSummaryLabel(value)
+	if err != nil {
+		return nil, err
+	}
+	if ptr == nil {
+		return []byte(value), nil
+	}
+	data, err := readExternalSummary(img, ptr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read external summary %s: %w", ptr.Path, err)
+	}
+	return data, nil
+}
+
+// readExternalSummary reads the summary file from the layer named in the
+// pointer, or searches all layers when the pointer carries no digest.
+func readExternalSummary(img v1.Image, ptr *cache.SummaryPointer) ([]byte, error) {
+	if ptr.Digest != "" {
+		h, err := v1.NewHash(ptr.Digest)
+		if err != nil {
+			return nil, err
+		}
+		layer, err := img.LayerByDigest(h)
+		if err != nil {
+			return nil, err
+		}
+		return readFileFromLayer(layer, ptr.Path)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		data, err := readFileFromLayer(layer, ptr.Path)
+		if err == nil {
+			return data, nil
+		}
+		logging.Debugf("summary not found in layer: %v", err)
+	}
+	return nil, errors.New("summary file not found in any layer")
+}
+
+func readFileFromLayer(layer v1.Layer, name string) ([]byte, error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found", name)
+		} else if err != nil {
+			return nil, err
+		}
+		if path.Clean(h.Name) == path.Clean(name) {
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
 	"github.com/redhat-et/MCU/mcv/pkg/cache"
 	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -71,7 +72,13 @@ func CompareTritonEntriesToGPU(entries []cache.TritonCacheMetadata, devInfo []de
 				ptxMatches = entry.PtxVersion == gpuInfo.PTXVersion
 			}
 
-			if backendMatches && archMatches && warpMatches && ptxMatches && dummyKeyMatches {
+			sharedMemOK := gpuInfo.SupportsSharedMemory(entry.Shared)
+			if !sharedMemOK {
+				logging.Debugf("Cache entry %s needs %d bytes of shared memory, more than virtual GPU %d (%s) guarantees",
+					entry.Hash, entry.Shared, gpuInfo.ID, gpuInfo.Profile)
+			}
+
+			if backendMatches && archMatches && warpMatches && ptxMatches && dummyKeyMatches && sharedMemOK {
 				logging.Infof("Compatible cache found: %s", entry.Hash)
 				hasMatch = true
 				break
@@ -87,7 +94,7 @@ func CompareTritonEntriesToGPU(entries []cache.TritonCacheMetadata, devInfo []de
 		return nil
 	}
 	if backendMismatch {
-		return fmt.Errorf("incompatibility detected: backend mismatch")
+		return failure.New(failure.Incompatible, errors.New("incompatibility detected: backend mismatch"))
 	}
-	return fmt.Errorf("no compatible GPU found")
+	return failure.New(failure.Incompatible, errors.New("no compatible GPU found"))
 }
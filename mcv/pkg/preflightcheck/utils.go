@@ -1,20 +1,41 @@
 package preflightcheck
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"runtime"
+	"slices"
+	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator"
 	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
-	"github.com/redhat-et/MCU/mcv/pkg/cache"
 	"github.com/redhat-et/MCU/mcv/pkg/config"
 	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/failure"
 	logging "github.com/sirupsen/logrus"
 )
 
+// hostArch is the CPU architecture of this host; overridden in tests.
+var hostArch = runtime.GOARCH
+
 func CompareCacheSummaryLabelToGPU(img v1.Image, labels map[string]string, devInfo []devices.TritonGPUInfo) (matched, unmatched []devices.TritonGPUInfo, err error) {
+	return compareCacheSummary(img, labels, devInfo, CheckHostArch)
+}
+
+// CompareCacheSummaryLabelToHost is CompareCacheSummaryLabelToGPU for the
+// GPUs and CPU architecture arch, as in GOARCH, of another host, such as
+// one reached over SSH.
+func CompareCacheSummaryLabelToHost(img v1.Image, labels map[string]string, devInfo []devices.TritonGPUInfo, arch string) (matched, unmatched []devices.TritonGPUInfo, err error) {
+	return compareCacheSummary(img, labels, devInfo, func(archs []string) error {
+		if len(archs) == 0 || slices.Contains(archs, arch) {
+			return nil
+		}
+		return failure.New(failure.Incompatible, fmt.Errorf("cache holds host code built for %s, the host is %s", strings.Join(archs, ", "), arch))
+	})
+}
+
+func compareCacheSummary(img v1.Image, labels map[string]string, devInfo []devices.TritonGPUInfo, checkArch func([]string) error) (matched, unmatched []devices.TritonGPUInfo, err error) {
 	logging.Debug("Starting cache summary label preflight check...")
 	if labels == nil {
 		configFile, ret := img.ConfigFile()
@@ -24,20 +45,16 @@ func CompareCacheSummaryLabelToGPU(img v1.Image, labels map[string]string, devIn
 
 		labels = configFile.Config.Labels
 		if labels == nil {
-			return nil, nil, errors.New("image has no labels")
+			return nil, nil, failure.New(failure.NoCache, errors.New("image has no labels"))
 		}
 	}
 
-	summaryStr, ok := labels["cache.triton.image/summary"]
-	if !ok {
-		if summaryStr, ok = labels["cache.vllm.image/summary"]; !ok {
-			return nil, nil, errors.New("image missing cache summary label")
-		}
+	summary, err := LoadSummary(img, labels)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	var summary cache.Summary
-	if err = json.Unmarshal([]byte(summaryStr), &summary); err != nil {
-		return nil, nil, fmt.Errorf("failed to parse summary label: %w", err)
+	if err := checkArch(summary.HostArchs); err != nil {
+		return nil, devInfo, err
 	}
 
 	for _, gpu := range devInfo {
@@ -61,16 +78,27 @@ func CompareCacheSummaryLabelToGPU(img v1.Image, labels map[string]string, devIn
 	}
 
 	if len(matched) == 0 {
-		err = fmt.Errorf("no compatible GPU found from summary preflight check")
+		err = failure.New(failure.Incompatible, errors.New("no compatible GPU found from summary preflight check"))
 	}
 
 	return matched, unmatched, err
 }
 
+// CheckHostArch rejects caches whose host code, such as Triton's launcher
+// stubs, was built for other CPU architectures than this host's, e.g. an
+// x86 cache on a Grace Hopper node. Caches of GPU code only record no
+// architecture and pass on any host.
+func CheckHostArch(archs []string) error {
+	if len(archs) == 0 || slices.Contains(archs, hostArch) {
+		return nil
+	}
+	return failure.New(failure.Incompatible, fmt.Errorf("cache holds host code built for %s, this host is %s", strings.Join(archs, ", "), hostArch))
+}
+
 // DetectCacheTypeFromLabels inspects image labels to determine cache type ("triton" or "vllm")
 func DetectCacheTypeFromLabels(labels map[string]string) (string, error) {
 	if labels == nil {
-		return "", fmt.Errorf("no labels provided")
+		return "", failure.New(failure.NoCache, errors.New("no labels provided"))
 	}
 	if _, ok := labels["cache.triton.image/summary"]; ok {
 		return constants.Triton, nil
@@ -78,7 +106,25 @@ func DetectCacheTypeFromLabels(labels map[string]string) (string, error) {
 	if _, ok := labels["cache.vllm.image/summary"]; ok {
 		return constants.VLLM, nil
 	}
-	return "", fmt.Errorf("unknown cache type from labels")
+	return "", failure.New(failure.NoCache, errors.New("unknown cache type from labels"))
+}
+
+// DetectCacheTypesFromLabels returns every cache type with a summary label,
+// for images packaging colocated caches of several types.
+func DetectCacheTypesFromLabels(labels map[string]string) ([]string, error) {
+	if labels == nil {
+		return nil, failure.New(failure.NoCache, errors.New("no labels provided"))
+	}
+	var cacheTypes []string
+	for _, ct := range []string{constants.Triton, constants.VLLM} {
+		if _, ok := labels[fmt.Sprintf("cache.%s.image/summary", ct)]; ok {
+			cacheTypes = append(cacheTypes, ct)
+		}
+	}
+	if len(cacheTypes) == 0 {
+		return nil, failure.New(failure.NoCache, errors.New("unknown cache type from labels"))
+	}
+	return cacheTypes, nil
 }
 
 // CompareCacheManifestToGPU dispatches manifest comparison based on cache type
@@ -0,0 +1,47 @@
+package preflightcheck
+
+import (
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareCacheSummaryLabelToGPUHostArch(t *testing.T) {
+	orig := hostArch
+	defer func() { hostArch = orig }()
+	hostArch = "arm64"
+
+	gpus := []devices.TritonGPUInfo{{ID: 0, Backend: "cuda", Arch: "90", WarpSize: 32}}
+	labels := func(summary string) map[string]string {
+		return map[string]string{"cache.triton.image/summary": summary}
+	}
+
+	// GPU code alone runs on any host.
+	matched, _, err := CompareCacheSummaryLabelToGPU(nil, labels(`{"targets":[{"backend":"cuda","arch":"90","warp_size":32}]}`), gpus)
+	assert.NoError(t, err)
+	assert.Len(t, matched, 1)
+
+	matched, unmatched, err := CompareCacheSummaryLabelToGPU(nil, labels(`{"targets":[{"backend":"cuda","arch":"90","warp_size":32}],"host_archs":["amd64"]}`), gpus)
+	assert.ErrorContains(t, err, "built for amd64, this host is arm64")
+	assert.Empty(t, matched)
+	assert.Equal(t, gpus, unmatched)
+
+	matched, _, err = CompareCacheSummaryLabelToGPU(nil, labels(`{"targets":[{"backend":"cuda","arch":"90","warp_size":32}],"host_archs":["arm64"]}`), gpus)
+	assert.NoError(t, err)
+	assert.Len(t, matched, 1)
+}
+
+func TestCompareCacheSummaryLabelToHost(t *testing.T) {
+	gpus := []devices.TritonGPUInfo{{ID: 0, Backend: "cuda", Arch: "90", WarpSize: 32}}
+	labels := map[string]string{"cache.triton.image/summary": `{"targets":[{"backend":"cuda","arch":"90","warp_size":32}],"host_archs":["amd64"]}`}
+
+	// The other host's architecture counts, not this one's.
+	matched, _, err := CompareCacheSummaryLabelToHost(nil, labels, gpus, "arm64")
+	assert.ErrorContains(t, err, "built for amd64, the host is arm64")
+	assert.Empty(t, matched)
+
+	matched, _, err = CompareCacheSummaryLabelToHost(nil, labels, gpus, "amd64")
+	assert.NoError(t, err)
+	assert.Len(t, matched, 1)
+}
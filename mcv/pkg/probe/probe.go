@@ -0,0 +1,121 @@
+// Package probe serves the readiness of a cache extraction over HTTP, so
+// mcv can extract in the background of a Kubernetes sidecar or init
+// container while startup and readiness probes hold back the workload.
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	logging "github.com/sirupsen/logrus"
+)
+
+// ReadyPath is the path of the readiness endpoint.
+const ReadyPath = "/readyz"
+
+// stages orders the stages of an extraction, each ready for more uses
+// than the one before.
+var stages = []string{fetcher.ReadyHot, fetcher.ReadyComplete}
+
+// Stages returns the stages a probe can wait for.
+func Stages() []string {
+	return append([]string(nil), stages...)
+}
+
+// ValidateStage rejects a stage a probe cannot wait for.
+func ValidateStage(stage string) error {
+	if rank(stage) < 0 {
+		return fmt.Errorf("invalid ready stage %q: must be one of %s", stage, strings.Join(stages, ", "))
+	}
+	return nil
+}
+
+func rank(stage string) int {
+	for i, s := range stages {
+		if s == stage {
+			return i
+		}
+	}
+	return -1
+}
+
+// Server answers ReadyPath with 200 once the extraction reached the stage
+// it waits for, and with 503 until then.
+type Server struct {
+	want string
+
+	mu      sync.Mutex
+	reached string
+
+	srv *http.Server
+}
+
+// New returns a server waiting for stage, one of Stages.
+func New(stage string) (*Server, error) {
+	if err := ValidateStage(stage); err != nil {
+		return nil, err
+	}
+	s := &Server{want: stage}
+	mux := http.NewServeMux()
+	mux.HandleFunc(ReadyPath, s.serveReady)
+	s.srv = &http.Server{Handler: mux}
+	return s, nil
+}
+
+// Reach records that the extraction reached stage. Stages before the one
+// already reached are ignored.
+func (s *Server) Reach(stage string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rank(stage) > rank(s.reached) {
+		s.reached = stage
+	}
+}
+
+// Ready reports whether the stage the server waits for is reached.
+func (s *Server) Ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return rank(s.reached) >= rank(s.want)
+}
+
+func (s *Server) serveReady(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	reached := s.reached
+	s.mu.Unlock()
+	if reached == "" {
+		reached = "extracting"
+	}
+	if !s.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintln(w, reached)
+}
+
+// Start listens on addr, e.g. ":8081", and serves in the background
+// until Shutdown.
+func (s *Server) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for probes on %s: %w", addr, err)
+	}
+	logging.Infof("Serving readiness on %s%s, ready once %s", l.Addr(), ReadyPath, s.want)
+	go func() {
+		if err := s.srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Errorf("Probe server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops serving, waiting for the requests in flight until ctx is
+// done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
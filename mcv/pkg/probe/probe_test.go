@@ -0,0 +1,45 @@
+package probe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/redhat-et/MCU/mcv/pkg/fetcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerReady(t *testing.T) {
+	_, err := New("warm")
+	assert.Error(t, err)
+
+	probe := func(s *Server) (int, string) {
+		rec := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	hot, err := New(fetcher.ReadyHot)
+	assert.NoError(t, err)
+	complete, err := New(fetcher.ReadyComplete)
+	assert.NoError(t, err)
+	for _, s := range []*Server{hot, complete} {
+		code, body := probe(s)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "extracting", body)
+		s.Reach(fetcher.ReadyHot)
+	}
+
+	code, body := probe(hot)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fetcher.ReadyHot, body)
+	code, _ = probe(complete)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	complete.Reach(fetcher.ReadyComplete)
+	complete.Reach(fetcher.ReadyHot)
+	code, body = probe(complete)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fetcher.ReadyComplete, body)
+}
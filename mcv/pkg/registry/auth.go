@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+var (
+	keychain authn.Keychain = authn.DefaultKeychain
+	insecure bool
+)
+
+// SetAuthFile makes every registry call take its credentials from the
+// docker config or containers auth.json at path instead of the default
+// locations. It must be called before any registry call.
+func SetAuthFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read auth file: %w", err)
+	}
+	defer f.Close()
+	cf, err := config.LoadFromReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid auth file %s: %w", path, err)
+	}
+	keychain = &fileKeychain{cf: cf}
+	return nil
+}
+
+// SetInsecure lets registry calls use plain HTTP, and HTTPS without
+// verifying the registry's certificate. It must be called before any
+// registry call.
+func SetInsecure(on bool) {
+	insecure = on
+}
+
+// ParseReference parses an image reference, allowing plain HTTP with
+// SetInsecure.
+func ParseReference(s string) (name.Reference, error) {
+	var opts []name.Option
+	if insecure {
+		opts = append(opts, name.Insecure)
+	}
+	return name.ParseReference(s, opts...)
+}
+
+// baseTransport returns the transport registry calls go through before
+// rate limiting.
+func baseTransport() http.RoundTripper {
+	if !insecure {
+		return remote.DefaultTransport
+	}
+	t := remote.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // Asked for with --insecure
+	return t
+}
+
+// fileKeychain resolves credentials from one auth file, looking them up
+// by repository, then by registry, as the default keychain does.
+type fileKeychain struct {
+	cf *configfile.ConfigFile
+}
+
+func (k *fileKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+		cfg, err := k.cf.GetAuthConfig(key)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Username != "" || cfg.Password != "" || cfg.Auth != "" || cfg.IdentityToken != "" || cfg.RegistryToken != "" {
+			return authn.FromConfig(authn.AuthConfig{
+				Username:      cfg.Username,
+				Password:      cfg.Password,
+				Auth:          cfg.Auth,
+				IdentityToken: cfg.IdentityToken,
+				RegistryToken: cfg.RegistryToken,
+			}), nil
+		}
+	}
+	return authn.Anonymous, nil
+}
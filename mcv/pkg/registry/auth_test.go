@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAuthFile(t *testing.T) {
+	defer func() { keychain = authn.DefaultKeychain }()
+
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	path := filepath.Join(t.TempDir(), "auth.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"auths": {
+		"registry.example.com": {"auth": "`+auth+`"},
+		"registry.example.com/team/cache": {"username": "robot", "password": "token"}
+	}}`), 0o600))
+	assert.NoError(t, SetAuthFile(path))
+
+	resolve := func(s string) *authn.AuthConfig {
+		repo, err := name.NewRepository(s)
+		assert.NoError(t, err)
+		a, err := keychain.Resolve(repo)
+		assert.NoError(t, err)
+		cfg, err := a.Authorization()
+		assert.NoError(t, err)
+		return cfg
+	}
+	assert.Equal(t, "robot", resolve("registry.example.com/team/cache").Username)
+	assert.Equal(t, "user", resolve("registry.example.com/other").Username)
+	assert.Equal(t, &authn.AuthConfig{}, resolve("quay.io/example/cache"))
+
+	assert.Error(t, SetAuthFile(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+func TestParseReferenceInsecure(t *testing.T) {
+	defer SetInsecure(false)
+
+	ref, err := ParseReference("registry.example.com/cache:v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https", ref.Context().Scheme())
+
+	SetInsecure(true)
+	ref, err = ParseReference("registry.example.com/cache:v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "http", ref.Context().Scheme())
+}
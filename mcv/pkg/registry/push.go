@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	logging "github.com/sirupsen/logrus"
+)
+
+// PushStats reports what a push had to do for each layer blob.
+type PushStats struct {
+	Layers        int   // Layer blobs in the image
+	Existing      int   // Already present in the target repository
+	Mountable     int   // Found in a mount source on the same registry
+	Uploaded      int   // Neither present nor mountable
+	SkippedBytes  int64 // Compressed size of existing and mountable blobs
+	UploadedBytes int64 // Compressed size of uploaded blobs
+}
+
+// mountedImage replaces the layers of an image with ones that may be
+// mounted from another repository.
+type mountedImage struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (m *mountedImage) Layers() ([]v1.Layer, error) {
+	return m.layers, nil
+}
+
+// Push writes img to ref. Before anything is uploaded every layer blob is
+// looked up in the target repository by digest, and blobs that are missing
+// there but present in one of the mountFrom repositories on the same
+// registry are requested as cross-repository mounts, so re-pushing a mostly
+// unchanged cache only uploads the layers that changed. Registries that do
+// not support mounting fall back to a normal upload.
+func Push(ctx context.Context, img v1.Image, ref name.Reference, mountFrom []name.Repository, extra ...remote.Option) (*PushStats, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image layers: %w", err)
+	}
+
+	target := ref.Context()
+	var sources []name.Repository
+	for _, repo := range mountFrom {
+		if repo.Registry.String() != target.Registry.String() || repo.String() == target.String() {
+			logging.Debugf("Not mounting from %s: not another repository on %s", repo, target.RegistryStr())
+			continue
+		}
+		sources = append(sources, repo)
+	}
+
+	checker := &blobChecker{ctx: ctx, clients: map[string]*http.Client{}}
+	stats := &PushStats{Layers: len(layers)}
+	wrapped := make([]v1.Layer, 0, len(layers))
+	for _, l := range layers {
+		digest, err := l.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get layer digest: %w", err)
+		}
+		size, err := l.Size()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get size of layer %s: %w", digest, err)
+		}
+
+		if checker.exists(target, digest) {
+			stats.Existing++
+			stats.SkippedBytes += size
+			wrapped = append(wrapped, l)
+			continue
+		}
+
+		mounted := false
+		for _, repo := range sources {
+			if checker.exists(repo, digest) {
+				wrapped = append(wrapped, &remote.MountableLayer{Layer: l, Reference: repo.Digest(digest.String())})
+				mounted = true
+				break
+			}
+		}
+		if mounted {
+			stats.Mountable++
+			stats.SkippedBytes += size
+			continue
+		}
+
+		stats.Uploaded++
+		stats.UploadedBytes += size
+		wrapped = append(wrapped, l)
+	}
+
+	logging.WithFields(logging.Fields{
+		"layers":   stats.Layers,
+		"existing": stats.Existing,
+		"mount":    stats.Mountable,
+		"upload":   stats.Uploaded,
+	}).Debugf("Pushing %s", ref)
+
+	opts := Options(append([]remote.Option{remote.WithContext(ctx)}, extra...)...)
+	if err := remote.Write(ref, &mountedImage{Image: img, layers: wrapped}, opts...); err != nil {
+		return nil, fmt.Errorf("failed to push %s: %w", ref, err)
+	}
+	return stats, nil
+}
+
+// blobChecker answers HEAD requests for blobs, keeping one authenticated
+// client per repository.
+type blobChecker struct {
+	ctx     context.Context
+	clients map[string]*http.Client
+}
+
+func (c *blobChecker) client(repo name.Repository) (*http.Client, error) {
+	if client, ok := c.clients[repo.String()]; ok {
+		return client, nil
+	}
+	auth, err := keychain.Resolve(repo)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := transport.NewWithContext(c.ctx, repo.Registry, auth, SharedTransport(),
+		[]string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: rt}
+	c.clients[repo.String()] = client
+	return client, nil
+}
+
+// exists reports whether repo has the blob. Lookup failures count as
+// missing: the push itself reports errors that matter.
+func (c *blobChecker) exists(repo name.Repository, digest v1.Hash) bool {
+	client, err := c.client(repo)
+	if err != nil {
+		logging.WithError(err).Debugf("Cannot check blobs in %s", repo)
+		return false
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/blobs/%s", repo.Scheme(), repo.RegistryStr(), repo.RepositoryStr(), digest)
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		logging.WithError(err).Debugf("Cannot check blob %s in %s", digest, repo)
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushSkipsPresentBlobs(t *testing.T) {
+	var uploads, mounts int32
+	reg := ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0)))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The in-memory registry shares blobs between repositories; keep
+		// "other" empty so its blobs have to be mounted.
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/other/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/blobs/uploads/") {
+			if r.URL.Query().Get("mount") != "" {
+				atomic.AddInt32(&mounts, 1)
+			} else {
+				atomic.AddInt32(&uploads, 1)
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	ref := func(s string) name.Reference {
+		r, err := name.ParseReference(host+"/"+s, name.Insecure)
+		assert.NoError(t, err)
+		return r
+	}
+	ctx := context.Background()
+
+	img, err := random.Image(1024, 2)
+	assert.NoError(t, err)
+
+	stats, err := Push(ctx, img, ref("cache:v1"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, PushStats{Layers: 2, Uploaded: 2, UploadedBytes: stats.UploadedBytes}, *stats)
+
+	// Pushing the same image again uploads no layer.
+	atomic.StoreInt32(&uploads, 0)
+	stats, err = Push(ctx, img, ref("cache:v1"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Existing)
+	assert.Equal(t, 0, stats.Uploaded)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&uploads))
+
+	// Only the changed layer is uploaded.
+	extra, err := random.Layer(512, types.DockerLayer)
+	assert.NoError(t, err)
+	img2, err := mutate.AppendLayers(img, extra)
+	assert.NoError(t, err)
+	stats, err = Push(ctx, img2, ref("cache:v2"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Existing)
+	assert.Equal(t, 1, stats.Uploaded)
+
+	// A new repository mounts the blobs from an existing one.
+	atomic.StoreInt32(&mounts, 0)
+	stats, err = Push(ctx, img2, ref("other:v2"), []name.Repository{ref("cache:v1").Context()})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Mountable)
+	assert.Equal(t, int64(0), stats.UploadedBytes)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&mounts))
+}
@@ -0,0 +1,93 @@
+// Package registry holds the client settings shared by every registry
+// interaction. All requests go through one transport that rate limits
+// them, stops calling a registry that keeps failing for a cooldown period,
+// and retries transient errors with jittered backoff, so a fleet-wide
+// rollout does not overload an internal registry.
+package registry
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+)
+
+// Limits configures the shared registry transport.
+type Limits struct {
+	QPS             float64       // Requests per second, 0 disables the limit
+	Burst           int           // Requests allowed above QPS in a burst
+	Retries         int           // Retries of failed operations
+	BreakerFailures int           // Consecutive failures that open a host's breaker, 0 disables it
+	BreakerCooldown time.Duration // How long an open breaker rejects requests
+}
+
+// DefaultLimits are used when no configuration has been loaded.
+var DefaultLimits = Limits{
+	QPS:             10,
+	Burst:           20,
+	Retries:         4,
+	BreakerFailures: 5,
+	BreakerCooldown: 30 * time.Second,
+}
+
+// retryStatusCodes are the responses retried with backoff: the go-containerregistry
+// defaults plus 429 so throttled requests back off instead of failing.
+var retryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+	499, // nginx-specific, client closed request
+	522, // Cloudflare-specific, connection timeout
+}
+
+var (
+	shared     *Transport
+	sharedOnce sync.Once
+)
+
+func configuredLimits() Limits {
+	if config.Instance() == nil {
+		return DefaultLimits
+	}
+	return Limits{
+		QPS:             config.RegistryQPS(),
+		Burst:           config.RegistryBurst(),
+		Retries:         config.RegistryRetries(),
+		BreakerFailures: config.RegistryBreakerFailures(),
+		BreakerCooldown: config.RegistryBreakerCooldown(),
+	}
+}
+
+// SharedTransport returns the process-wide registry transport, created
+// from the configuration on first use.
+func SharedTransport() *Transport {
+	sharedOnce.Do(func() {
+		shared = NewTransport(baseTransport(), configuredLimits())
+	})
+	return shared
+}
+
+// Options returns the remote options every registry call should use:
+// credentials from the default keychain, or the auth file given to
+// SetAuthFile, the shared transport and jittered retries. extra options
+// are appended.
+func Options(extra ...remote.Option) []remote.Option {
+	t := SharedTransport()
+	opts := []remote.Option{
+		remote.WithAuthFromKeychain(keychain),
+		remote.WithTransport(t),
+		remote.WithRetryStatusCodes(retryStatusCodes...),
+		remote.WithRetryBackoff(remote.Backoff{
+			Duration: time.Second,
+			Factor:   2.0,
+			Jitter:   0.5,
+			Steps:    t.limits.Retries + 1,
+		}),
+	}
+	return append(opts, extra...)
+}
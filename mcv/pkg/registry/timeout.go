@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+)
+
+// Operation is a kind of network operation with its own timeout.
+type Operation string
+
+const (
+	OpPull   Operation = "pull"
+	OpPush   Operation = "push"
+	OpSign   Operation = "sign"
+	OpVerify Operation = "verify"
+)
+
+// started is when the overall timeout began.
+var started = time.Now()
+
+// Timeout returns how long op may take, 0 for no limit.
+func Timeout(op Operation) time.Duration {
+	if config.Instance() == nil {
+		return 0
+	}
+	switch op {
+	case OpPull:
+		return config.PullTimeout()
+	case OpPush:
+		return config.PushTimeout()
+	case OpSign:
+		return config.SignTimeout()
+	case OpVerify:
+		return config.VerifyTimeout()
+	}
+	return 0
+}
+
+// Context returns a context for op, derived from parent, that expires once
+// op's timeout has passed or at the end of the overall timeout counted
+// from mcv's start, whichever comes first, so that a registry that stops
+// responding fails the operation instead of blocking it forever. Images
+// fetched with the context read their layers lazily, after the fetch
+// returns: callers returning one may leave cancel uncalled, the context
+// being released when it expires.
+func Context(parent context.Context, op Operation) (context.Context, context.CancelFunc) {
+	ctx, cancelTotal := parent, context.CancelFunc(func() {})
+	if config.Instance() != nil && config.Timeout() > 0 {
+		total := config.Timeout()
+		ctx, cancelTotal = context.WithDeadlineCause(ctx, started.Add(total),
+			&timeoutError{fmt.Sprintf("mcv timed out after %s (--timeout)", total)})
+	}
+	d := Timeout(op)
+	if d <= 0 {
+		return ctx, cancelTotal
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, d, &timeoutError{fmt.Sprintf("%s timed out after %s (--%s-timeout)", op, d, op)})
+	return ctx, func() {
+		cancel()
+		cancelTotal()
+	}
+}
+
+// timeoutError names the timeout that expired. It is a
+// context.DeadlineExceeded.
+type timeoutError struct {
+	msg string
+}
+
+func (e *timeoutError) Error() string { return e.msg }
+
+func (e *timeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// TimeoutError returns err, saying which timeout expired if ctx did and
+// err does not already.
+func TimeoutError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	// Registry clients report the cause, though not always wrapped.
+	cause := context.Cause(ctx)
+	if errors.Is(err, cause) || strings.Contains(err.Error(), cause.Error()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", cause, err)
+}
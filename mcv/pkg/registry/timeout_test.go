@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextTimesOutHungRegistry(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+	defer config.SetPullTimeout(config.PullTimeout())
+	config.SetPullTimeout(200 * time.Millisecond)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(srv.URL, "http://")+"/cache:v1", name.Insecure)
+	assert.NoError(t, err)
+
+	ctx, cancel := Context(context.Background(), OpPull)
+	defer cancel()
+	start := time.Now()
+	_, err = remote.Head(ref, remote.WithContext(ctx))
+	err = TimeoutError(ctx, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "pull timed out after 200ms (--pull-timeout)")
+
+	// Other operations keep their own timeouts.
+	ctx, cancel = Context(context.Background(), OpVerify)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(config.VerifyTimeout()), deadline, time.Second)
+}
+
+func TestTimeoutErrorOnlyOnDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := TimeoutError(ctx, context.Canceled)
+	assert.Equal(t, context.Canceled, err)
+	assert.NoError(t, TimeoutError(ctx, nil))
+}
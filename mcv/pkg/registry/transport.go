@@ -0,0 +1,228 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+)
+
+// ErrCircuitOpen is returned for requests to a registry whose breaker is
+// open. It is not retried.
+var ErrCircuitOpen = errors.New("registry circuit breaker open")
+
+// Transport rate limits requests across all registries and keeps a
+// circuit breaker per registry host.
+type Transport struct {
+	base    http.RoundTripper
+	limits  Limits
+	limiter *limiter
+	now     func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// NewTransport wraps base with the given limits.
+func NewTransport(base http.RoundTripper, limits Limits) *Transport {
+	t := &Transport{
+		base:     base,
+		limits:   limits,
+		now:      time.Now,
+		breakers: map[string]*breaker{},
+	}
+	if limits.QPS > 0 {
+		t.limiter = newLimiter(limits.QPS, limits.Burst)
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	b := t.breaker(host)
+	if wait, ok := b.allow(t.now(), t.limits.BreakerFailures); !ok {
+		return nil, fmt.Errorf("%w for %s, retry in %s", ErrCircuitOpen, host, wait.Round(time.Second))
+	}
+	// A registry that asked us to slow down is waited for, not failed.
+	if err := sleep(req.Context(), b.paused(t.now())); err != nil {
+		b.release()
+		return nil, err
+	}
+	if t.limiter != nil {
+		if err := t.limiter.wait(req.Context()); err != nil {
+			b.release()
+			return nil, err
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		if errors.Is(err, context.Canceled) {
+			b.release()
+		} else {
+			t.fail(b, host, 0)
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		t.fail(b, host, retryAfter(resp))
+	default:
+		b.succeed()
+	}
+	return resp, err
+}
+
+func (t *Transport) breaker(host string) *breaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+func (t *Transport) fail(b *breaker, host string, pause time.Duration) {
+	if b.fail(t.now(), t.limits.BreakerFailures, t.limits.BreakerCooldown, pause) {
+		diag.Warnf(diag.RegistryBreakerOpen, "Registry %s keeps failing, pausing requests for %s", host, t.limits.BreakerCooldown)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter returns the delay requested by a Retry-After header in
+// seconds, or 0.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// breaker opens after a number of consecutive failures and rejects
+// requests until its cooldown passes. It then lets a single probe through:
+// success closes it, failure opens it again.
+type breaker struct {
+	mu         sync.Mutex
+	failures   int
+	openUntil  time.Time
+	pauseUntil time.Time // Set from Retry-After
+	probing    bool
+}
+
+// allow reports whether a request may be sent, or how long until one may.
+func (b *breaker) allow(now time.Time, threshold int) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.openUntil) {
+		return b.openUntil.Sub(now), false
+	}
+	if threshold <= 0 || b.failures < threshold {
+		return 0, true
+	}
+	if b.probing {
+		return 0, false
+	}
+	b.probing = true
+	return 0, true
+}
+
+// paused returns how long the registry asked requests to wait.
+func (b *breaker) paused(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.pauseUntil) {
+		return b.pauseUntil.Sub(now)
+	}
+	return 0
+}
+
+func (b *breaker) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// fail records a failure and delays further requests by pause. It reports
+// whether the breaker opened.
+func (b *breaker) fail(now time.Time, threshold int, cooldown, pause time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	b.failures++
+	opened := false
+	if threshold > 0 && b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+		opened = true
+	}
+	if until := now.Add(pause); until.After(b.pauseUntil) {
+		b.pauseUntil = until
+	}
+	return opened
+}
+
+// limiter is a token bucket shared by all registry requests.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(qps float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{rate: qps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a request may be sent or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	var calls, healthy int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	tr := NewTransport(http.DefaultTransport, Limits{BreakerFailures: 3, BreakerCooldown: time.Minute})
+	tr.now = func() time.Time { return now }
+	client := &http.Client{Transport: tr}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err := client.Get(srv.URL)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// After the cooldown a probe goes through and closes the breaker.
+	now = now.Add(2 * time.Minute)
+	atomic.StoreInt32(&healthy, 1)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+}
+
+func TestRetryAfterPausesRequests(t *testing.T) {
+	b := &breaker{}
+	now := time.Now()
+	b.fail(now, 5, time.Minute, 2*time.Second)
+	_, ok := b.allow(now, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, b.paused(now))
+	assert.Zero(t, b.paused(now.Add(3*time.Second)))
+}
+
+func TestLimiterSpacesRequests(t *testing.T) {
+	l := newLimiter(50, 1)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, l.wait(t.Context()))
+	}
+	// One burst token, then three more at 50/s.
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
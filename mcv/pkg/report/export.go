@@ -0,0 +1,137 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter writes a report in one format.
+type Exporter interface {
+	Export(w io.Writer, r *Report) error
+}
+
+// ExporterFunc adapts a function to an Exporter.
+type ExporterFunc func(w io.Writer, r *Report) error
+
+func (f ExporterFunc) Export(w io.Writer, r *Report) error {
+	return f(w, r)
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]Exporter{
+		"csv":      ExporterFunc(exportCSV),
+		"markdown": ExporterFunc(exportMarkdown),
+		"html":     ExporterFunc(exportHTML),
+	}
+)
+
+// Register makes an exporter available under format, replacing any
+// exporter registered under it before.
+func Register(format string, e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[format] = e
+}
+
+// Formats returns the registered formats.
+func Formats() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	formats := make([]string, 0, len(exporters))
+	for f := range exporters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Get returns the exporter registered under format.
+func Get(format string) (Exporter, error) {
+	exportersMu.RLock()
+	e, ok := exporters[format]
+	exportersMu.RUnlock()
+	if ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("unsupported report format %q (supported: %s)", format, strings.Join(Formats(), ", "))
+}
+
+// exportCSV writes the rows with a header row. Issues are left out, as
+// they do not fit the columns.
+func exportCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(r.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func exportMarkdown(w io.Writer, r *Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\nGenerated %s.\n\n", r.Title, r.Generated.Format(time.RFC3339))
+	b.WriteString("| " + strings.Join(Header, " | ") + " |\n")
+	b.WriteString(strings.Repeat("| --- ", len(Header)) + "|\n")
+	for _, row := range r.Rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = markdownEscaper.Replace(c)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	if len(r.Issues) > 0 {
+		b.WriteString("\n## Issues\n\n")
+		for _, issue := range r.Issues {
+			fmt.Fprintf(&b, "- %s\n", markdownEscaper.Replace(issue))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "<", "&lt;", ">", "&gt;")
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Report.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>{{.Report.Title}}</h1>
+<p>Generated {{rfc3339 .Report.Generated}}.</p>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Report.Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Report.Issues}}<h2>Issues</h2>
+<ul>
+{{range .Report.Issues}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+func exportHTML(w io.Writer, r *Report) error {
+	return htmlReport.Execute(w, struct {
+		Header []string
+		Report *Report
+	}{Header, r})
+}
@@ -0,0 +1,90 @@
+// Package report lays out host and fleet GPU compatibility reports as a
+// table, and exports them as CSV, Markdown or HTML for attaching to change
+// tickets. Other formats can be registered.
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+)
+
+// Header names the columns of a report.
+var Header = []string{"HOST", "OS", "KERNEL", "GPU TYPE", "COUNT", "IDS", "DRIVER", "VIRTUALIZATION", "TARGETS", "IMAGE", "COMPATIBLE", "ERROR"}
+
+// Report is a host or fleet report with a row per GPU type of each host.
+type Report struct {
+	Title     string
+	Generated time.Time
+	Rows      [][]string
+	// Issues are differences between hosts that would make an image roll
+	// out to some hosts but not others.
+	Issues []string
+}
+
+// ForHost returns the report of one host.
+func ForHost(r *fleet.HostReport) *Report {
+	return &Report{
+		Title:     "GPU report for " + r.Hostname,
+		Generated: time.Now().UTC(),
+		Rows:      hostRows(r.Hostname, r, nil),
+	}
+}
+
+// ForFleet returns the report of a fleet check of image, which may be
+// empty.
+func ForFleet(image string, results []fleet.Result) *Report {
+	title := "Fleet GPU report"
+	if image != "" {
+		title += " for " + image
+	}
+	rep := &Report{Title: title, Generated: time.Now().UTC(), Issues: fleet.Analyze(results)}
+	for _, res := range results {
+		rep.Rows = append(rep.Rows, hostRows(res.Host.Name, res.Report, res.Err)...)
+	}
+	return rep
+}
+
+// hostRows returns a row per GPU type of a host, or a single row for a
+// host without GPUs or that could not be checked.
+func hostRows(name string, r *fleet.HostReport, err error) [][]string {
+	if err != nil || r == nil {
+		msg := "unreachable"
+		if err != nil {
+			msg = err.Error()
+		}
+		return [][]string{{name, "", "", "", "", "", "", "", "", "", "", msg}}
+	}
+
+	var osRelease, kernel string
+	if r.Host != nil {
+		osRelease, kernel = r.Host.OSRelease, r.Host.KernelVersion
+	}
+	compatible := ""
+	if r.Image != "" {
+		compatible = fmt.Sprintf("%t", r.Compatible)
+	}
+	row := func(gpuType, count, ids, driver, virt string) []string {
+		return []string{name, osRelease, kernel, gpuType, count, ids, driver, virt,
+			strings.Join(r.Targets, " "), r.Image, compatible, r.Error}
+	}
+
+	if len(r.GPUs) == 0 {
+		return [][]string{row("", "0", "", "", "")}
+	}
+	rows := make([][]string, 0, len(r.GPUs))
+	for _, g := range r.GPUs {
+		virt := g.Virtualization
+		if g.Profile != "" {
+			virt = strings.TrimSpace(virt + " " + g.Profile)
+		}
+		ids := make([]string, len(g.IDs))
+		for i, id := range g.IDs {
+			ids[i] = fmt.Sprint(id)
+		}
+		rows = append(rows, row(g.GPUType, fmt.Sprint(len(g.IDs)), strings.Join(ids, " "), g.DriverVersion, virt))
+	}
+	return rows
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/fleet"
+	"github.com/redhat-et/MCU/mcv/pkg/hostinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResults() []fleet.Result {
+	return []fleet.Result{
+		{Host: fleet.Host{Name: "gpu-1"}, Report: &fleet.HostReport{
+			Hostname: "gpu-1",
+			Host:     &hostinfo.Info{OSRelease: "RHEL 9.4", KernelVersion: "5.14.0"},
+			GPUs: []devices.GPUGroup{
+				{GPUType: "NVIDIA A100", DriverVersion: "550.54", Virtualization: "mig", Profile: "1g.10gb", IDs: []int{0, 1}},
+			},
+			Targets:    []string{"cuda-80"},
+			Image:      "quay.io/example/cache:v1",
+			Compatible: true,
+		}},
+		{Host: fleet.Host{Name: "gpu-2"}, Report: &fleet.HostReport{Hostname: "gpu-2", Image: "quay.io/example/cache:v1"}},
+		{Host: fleet.Host{Name: "gpu-3"}, Err: errors.New("ssh: connection refused")},
+	}
+}
+
+func TestForFleet(t *testing.T) {
+	rep := ForFleet("quay.io/example/cache:v1", testResults())
+	assert.Equal(t, "Fleet GPU report for quay.io/example/cache:v1", rep.Title)
+	assert.Equal(t, [][]string{
+		{"gpu-1", "RHEL 9.4", "5.14.0", "NVIDIA A100", "2", "0 1", "550.54", "mig 1g.10gb", "cuda-80", "quay.io/example/cache:v1", "true", ""},
+		{"gpu-2", "", "", "", "0", "", "", "", "", "quay.io/example/cache:v1", "false", ""},
+		{"gpu-3", "", "", "", "", "", "", "", "", "", "", "ssh: connection refused"},
+	}, rep.Rows)
+	assert.NotEmpty(t, rep.Issues)
+	for _, row := range rep.Rows {
+		assert.Len(t, row, len(Header))
+	}
+}
+
+func TestExporters(t *testing.T) {
+	rep := &Report{
+		Title:     "Fleet GPU report",
+		Generated: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Rows:      [][]string{{"a|b", "", "", "<x>", "1", "0", "", "", "", "", "", ""}},
+		Issues:    []string{"mixed <drivers>"},
+	}
+
+	var b bytes.Buffer
+	e, err := Get("csv")
+	assert.NoError(t, err)
+	assert.NoError(t, e.Export(&b, rep))
+	assert.Equal(t, "HOST,OS,KERNEL,GPU TYPE,COUNT,IDS,DRIVER,VIRTUALIZATION,TARGETS,IMAGE,COMPATIBLE,ERROR\na|b,,,<x>,1,0,,,,,,\n", b.String())
+
+	b.Reset()
+	e, err = Get("markdown")
+	assert.NoError(t, err)
+	assert.NoError(t, e.Export(&b, rep))
+	assert.Contains(t, b.String(), "# Fleet GPU report\n\nGenerated 2024-01-02T03:04:05Z.")
+	assert.Contains(t, b.String(), `| a\|b |  |  | &lt;x&gt; | 1 |`)
+	assert.Contains(t, b.String(), "## Issues\n\n- mixed &lt;drivers&gt;\n")
+
+	b.Reset()
+	e, err = Get("html")
+	assert.NoError(t, err)
+	assert.NoError(t, e.Export(&b, rep))
+	assert.Contains(t, b.String(), "<th>GPU TYPE</th>")
+	assert.Contains(t, b.String(), "<td>&lt;x&gt;</td>")
+	assert.Contains(t, b.String(), "<li>mixed &lt;drivers&gt;</li>")
+
+	_, err = Get("pdf")
+	assert.ErrorContains(t, err, "csv, html, markdown")
+}
+
+func TestRegister(t *testing.T) {
+	Register("count", ExporterFunc(func(w io.Writer, r *Report) error {
+		_, err := io.WriteString(w, "rows\n")
+		return err
+	}))
+	defer func() {
+		exportersMu.Lock()
+		delete(exporters, "count")
+		exportersMu.Unlock()
+	}()
+	assert.Contains(t, Formats(), "count")
+	e, err := Get("count")
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, e.Export(&b, &Report{}))
+	assert.Equal(t, "rows\n", b.String())
+}
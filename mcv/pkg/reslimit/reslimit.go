@@ -0,0 +1,249 @@
+// Package reslimit detects the memory and CPU limits of the cgroup mcv
+// runs in, as a pod does, and sizes the Go runtime, the decompression of
+// layers and worker pools to them, so that unpacking multi-GB layers under
+// tight limits does not get mcv OOM-killed or throttled.
+package reslimit
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+const (
+	// memoryShare is the share of the memory limit the Go heap is kept
+	// under, leaving room for the stacks, the page cache charged to the
+	// cgroup and the decoders' buffers.
+	memoryShare = 0.75
+	// workerMemory is the memory each worker, such as a compression or
+	// decompression goroutine, is budgeted.
+	workerMemory = 256 << 20
+	// lowMemory is the limit under which decoders trade speed for memory.
+	lowMemory = 2 << 30
+
+	minBuffer     = 64 << 10
+	maxBuffer     = 1 << 20
+	defaultBuffer = maxBuffer
+)
+
+// Limits are the resources the cgroup of mcv lets it use. Zero values
+// mean no limit.
+type Limits struct {
+	Memory int64   `json:"memory,omitempty"` // Bytes
+	CPUs   float64 `json:"cpus,omitempty"`
+}
+
+// Current returns the limits of the cgroup mcv runs in, detected once.
+var Current = sync.OnceValue(func() Limits { return detect("/") })
+
+// Apply sizes the Go runtime to the current limits: the number of threads
+// running Go code to the CPU limit and the heap to the memory limit,
+// unless set with GOMAXPROCS or GOMEMLIMIT.
+func Apply() {
+	l := Current()
+	if l.CPUs > 0 && os.Getenv("GOMAXPROCS") == "" {
+		procs := max(1, int(math.Ceil(l.CPUs)))
+		if procs < runtime.GOMAXPROCS(0) {
+			runtime.GOMAXPROCS(procs)
+			logging.Debugf("Using %d CPUs, the cgroup CPU limit", procs)
+		}
+	}
+	if l.Memory > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(int64(float64(l.Memory) * memoryShare))
+		logging.Debugf("Keeping the heap under %d MiB, for the cgroup memory limit of %d MiB",
+			int64(float64(l.Memory)*memoryShare)>>20, l.Memory>>20)
+	}
+}
+
+// Workers returns how many workers to run in parallel, such as
+// compression encoders: one per CPU mcv may use, fewer if the memory
+// limit cannot hold them.
+func Workers() int {
+	return Current().Workers(runtime.GOMAXPROCS(0))
+}
+
+// Workers returns how many of procs workers l has the memory for, at
+// least one.
+func (l Limits) Workers(procs int) int {
+	if l.Memory > 0 {
+		procs = min(procs, int(l.Memory/workerMemory))
+	}
+	return max(1, procs)
+}
+
+// LowMemory reports whether the memory limit is tight enough for decoders
+// to favor memory over speed.
+func LowMemory() bool {
+	return Current().LowMemory()
+}
+
+func (l Limits) LowMemory() bool {
+	return l.Memory > 0 && l.Memory < lowMemory
+}
+
+// BufferSize returns the size of the buffer to read compressed layers
+// through.
+func BufferSize() int {
+	return Current().BufferSize()
+}
+
+// BufferSize returns a thousandth of the memory limit, from 64 KiB to
+// 1 MiB, or 1 MiB without a limit.
+func (l Limits) BufferSize() int {
+	if l.Memory == 0 {
+		return defaultBuffer
+	}
+	return int(min(max(l.Memory/1024, minBuffer), maxBuffer))
+}
+
+// DecoderMemory returns the most memory a decoder may allocate, or 0 for
+// no limit, so that a layer asking for more fails instead of getting mcv
+// OOM-killed.
+func DecoderMemory() uint64 {
+	if m := Current().Memory; m > 0 {
+		return uint64(m / 2)
+	}
+	return 0
+}
+
+func (l Limits) String() string {
+	memory, cpus := "none", "none"
+	if l.Memory > 0 {
+		memory = fmt.Sprintf("%d MiB", l.Memory>>20)
+	}
+	if l.CPUs > 0 {
+		cpus = strconv.FormatFloat(l.CPUs, 'g', -1, 64)
+	}
+	return fmt.Sprintf("memory %s, CPUs %s", memory, cpus)
+}
+
+// detect reads the limits of the cgroup of the process from the cgroup
+// filesystem under root. The tightest limit of the cgroup and its
+// ancestors applies, as a pod's limits are set on a parent of its
+// containers' cgroups.
+func detect(root string) Limits {
+	data, err := os.ReadFile(filepath.Join(root, "proc/self/cgroup"))
+	if err != nil {
+		return Limits{}
+	}
+	var l Limits
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		controllers, path := strings.Split(parts[1], ","), parts[2]
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			base := filepath.Join(root, "sys/fs/cgroup")
+			l = l.tighten(walk(base, path, memoryV2, cpuV2))
+		case slices.Contains(controllers, "memory"):
+			l = l.tighten(walk(filepath.Join(root, "sys/fs/cgroup/memory"), path, memoryV1, nil))
+		case slices.Contains(controllers, "cpu"):
+			l = l.tighten(walk(filepath.Join(root, "sys/fs/cgroup/cpu"), path, nil, cpuV1))
+		}
+	}
+	return l
+}
+
+// walk reads the limits of the cgroup at path below the hierarchy mounted
+// at base and of its ancestors. In a cgroup namespace, or with the
+// container's cgroup mounted at base, path is not below base and the
+// limits of base itself are read.
+func walk(base, path string, memory func(dir string) int64, cpus func(dir string) float64) Limits {
+	dir := filepath.Join(base, path)
+	if _, err := os.Stat(dir); err != nil {
+		dir = base
+	}
+	var l Limits
+	for {
+		var d Limits
+		if memory != nil {
+			d.Memory = memory(dir)
+		}
+		if cpus != nil {
+			d.CPUs = cpus(dir)
+		}
+		l = l.tighten(d)
+		if dir == base || !strings.HasPrefix(dir, base) {
+			return l
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// tighten returns l with the limits of o that are tighter.
+func (l Limits) tighten(o Limits) Limits {
+	if o.Memory > 0 && (l.Memory == 0 || o.Memory < l.Memory) {
+		l.Memory = o.Memory
+	}
+	if o.CPUs > 0 && (l.CPUs == 0 || o.CPUs < l.CPUs) {
+		l.CPUs = o.CPUs
+	}
+	return l
+}
+
+func memoryV2(dir string) int64 {
+	return readInt(filepath.Join(dir, "memory.max"))
+}
+
+func cpuV2(dir string) float64 {
+	fields := strings.Fields(readFile(filepath.Join(dir, "cpu.max")))
+	if len(fields) != 2 {
+		return 0
+	}
+	return ratio(fields[0], fields[1])
+}
+
+// unlimitedV1 is above the memory limit cgroup v1 reports for no limit,
+// the largest page-aligned int64.
+const unlimitedV1 = 1 << 62
+
+func memoryV1(dir string) int64 {
+	if n := readInt(filepath.Join(dir, "memory.limit_in_bytes")); n < unlimitedV1 {
+		return n
+	}
+	return 0
+}
+
+func cpuV1(dir string) float64 {
+	return ratio(readFile(filepath.Join(dir, "cpu.cfs_quota_us")), readFile(filepath.Join(dir, "cpu.cfs_period_us")))
+}
+
+// ratio returns quota/period, or 0 if quota is "max", -1 or invalid.
+func ratio(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+func readInt(path string) int64 {
+	n, err := strconv.ParseInt(readFile(path), 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+func readFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,72 @@
+package reslimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+}
+
+func TestDetectV2(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/cgroup": "0::/kubepods/pod1/ctr",
+		// The pod's limits are tighter than the container's.
+		"sys/fs/cgroup/kubepods/pod1/memory.max":     "1073741824",
+		"sys/fs/cgroup/kubepods/pod1/cpu.max":        "max 100000",
+		"sys/fs/cgroup/kubepods/pod1/ctr/memory.max": "max",
+		"sys/fs/cgroup/kubepods/pod1/ctr/cpu.max":    "150000 100000",
+	})
+	assert.Equal(t, Limits{Memory: 1 << 30, CPUs: 1.5}, detect(root))
+
+	// In a cgroup namespace, the container's cgroup is the root.
+	writeFiles(t, root, map[string]string{
+		"proc/self/cgroup":         "0::/",
+		"sys/fs/cgroup/memory.max": "536870912",
+	})
+	assert.Equal(t, Limits{Memory: 512 << 20}, detect(root))
+}
+
+func TestDetectV1(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/cgroup": "4:memory:/docker/abc\n2:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc",
+		// The container's cgroup is mounted at the hierarchy's root.
+		"sys/fs/cgroup/memory/memory.limit_in_bytes": "268435456",
+		"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         "-1",
+		"sys/fs/cgroup/cpu/cpu.cfs_period_us":        "100000",
+	})
+	assert.Equal(t, Limits{Memory: 256 << 20}, detect(root))
+
+	writeFiles(t, root, map[string]string{
+		"sys/fs/cgroup/memory/memory.limit_in_bytes": "9223372036854771712",
+		"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         "50000",
+	})
+	assert.Equal(t, Limits{CPUs: 0.5}, detect(root))
+
+	assert.Equal(t, Limits{}, detect(t.TempDir()))
+}
+
+func TestSizing(t *testing.T) {
+	assert.Equal(t, 8, Limits{}.Workers(8))
+	assert.Equal(t, 2, Limits{Memory: 512 << 20}.Workers(8))
+	assert.Equal(t, 1, Limits{Memory: 64 << 20}.Workers(8))
+
+	assert.False(t, Limits{}.LowMemory())
+	assert.True(t, Limits{Memory: 1 << 30}.LowMemory())
+
+	assert.Equal(t, 1<<20, Limits{}.BufferSize())
+	assert.Equal(t, 64<<10, Limits{Memory: 32 << 20}.BufferSize())
+	assert.Equal(t, 256<<10, Limits{Memory: 256 << 20}.BufferSize())
+	assert.Equal(t, 1<<20, Limits{Memory: 16 << 30}.BufferSize())
+}
@@ -0,0 +1,378 @@
+// Package sharedfs coordinates extractions into a cache directory shared by
+// several nodes over NFS, GPFS or another network filesystem. One
+// extractor holds a lease file in the directory while it extracts, and
+// records what it extracted in a marker; the others wait for it and reuse
+// the result instead of extracting the same cache over it. The marker also
+// lets a restarted extractor skip an extraction it already completed.
+//
+// The lease is a file created exclusively, which network filesystems
+// support, rather than a POSIX lock, which some do not, or lose silently.
+// Its holder touches it periodically. A lease that stops changing for the
+// stale timeout, measured on the waiter's clock so clock skew between
+// nodes does not matter, is taken over. Each lease file holds a random
+// token, which its holder checks before touching or removing it, so that
+// a holder whose lease was taken over finds out instead of keeping alive,
+// or removing, the lease of the node that took over.
+package sharedfs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// LockFileName is the lease file held while a node extracts.
+	LockFileName = ".mcv-extract.lock"
+	// MarkerFileName records the image last extracted into the directory.
+	MarkerFileName = ".mcv-extracted"
+)
+
+// Defaults for Options.
+const (
+	DefaultStaleAfter = 2 * time.Minute
+	DefaultPoll       = time.Second
+)
+
+// networkFilesystems maps statfs magic numbers to filesystem names.
+var networkFilesystems = map[int64]string{
+	0x6969:     "nfs",
+	0x47504653: "gpfs",
+	0x0bd00bd0: "lustre",
+	0x00c36400: "cephfs",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+}
+
+// Filesystem returns the name of the network filesystem dir, or its
+// nearest existing parent, is on, or "" for local filesystems.
+func Filesystem(dir string) string {
+	for {
+		var st unix.Statfs_t
+		if err := unix.Statfs(dir, &st); err == nil {
+			return networkFilesystems[int64(st.Type)]
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Owner identifies the holder of a lease or the node that extracted a
+// cache.
+type Owner struct {
+	Host string    `json:"host"`
+	PID  int       `json:"pid"`
+	Time time.Time `json:"time"`
+}
+
+// Self returns the Owner for this process.
+func Self() Owner {
+	host, _ := os.Hostname()
+	return Owner{Host: host, PID: os.Getpid(), Time: time.Now().UTC()}
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%s (pid %d)", o.Host, o.PID)
+}
+
+// Marker records the image extracted into a directory.
+type Marker struct {
+	Digest string `json:"digest"`
+	Files  int    `json:"files"` // Files in the directory after extracting
+	Owner
+}
+
+// ReadMarker returns the marker in dir, or nil if there is none.
+func ReadMarker(dir string) (*Marker, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MarkerFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Marker
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid extraction marker in %s: %w", dir, err)
+	}
+	return &m, nil
+}
+
+// WriteMarker records that digest was extracted into dir, which then held
+// files files.
+func WriteMarker(dir, digest string, files int) error {
+	data, err := json.Marshal(Marker{Digest: digest, Files: files, Owner: Self()})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, MarkerFileName), data)
+}
+
+// RemoveMarker removes the marker, before the directory is changed.
+func RemoveMarker(dir string) error {
+	err := os.Remove(filepath.Join(dir, MarkerFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Options configures Acquire.
+type Options struct {
+	StaleAfter time.Duration // How long a lease may go untouched before it is taken over
+	Poll       time.Duration // How often waiters check the lease
+}
+
+// leaseFile is the contents of a lease file.
+type leaseFile struct {
+	Owner
+	Token string `json:"token"` // Random, to tell this lease from one taken over
+}
+
+// Lease is a held lease on a shared directory.
+type Lease struct {
+	path   string
+	token  string
+	stop   chan struct{}
+	done   sync.WaitGroup
+	mu     sync.Mutex
+	lost   error
+	closed bool
+}
+
+// Acquire waits until it holds the lease on dir, or ctx is done. The lease
+// is kept alive until Release.
+func Acquire(ctx context.Context, dir string, opts Options) (*Lease, error) {
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = DefaultStaleAfter
+	}
+	if opts.Poll <= 0 {
+		opts.Poll = DefaultPoll
+	}
+	path := filepath.Join(dir, LockFileName)
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to create lease token: %w", err)
+	}
+	lf := leaseFile{Owner: Self(), Token: hex.EncodeToString(token)}
+	owner, err := json.Marshal(lf)
+	if err != nil {
+		return nil, err
+	}
+
+	var seen struct {
+		mtime time.Time
+		since time.Time
+	}
+	logged := false
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, werr := f.Write(owner)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lease %s: %w", path, werr)
+			}
+			return newLease(path, lf.Token, opts.StaleAfter), nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lease %s: %w", path, err)
+		}
+
+		st, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Released since the create; try again straight away.
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to check lease %s: %w", path, err)
+		}
+		now := time.Now()
+		if !st.ModTime().Equal(seen.mtime) {
+			seen.mtime, seen.since = st.ModTime(), now
+		} else if now.Sub(seen.since) >= opts.StaleAfter {
+			breakStale(path, seen.mtime)
+			seen.mtime = time.Time{}
+			continue
+		}
+		if !logged {
+			holder := "another node"
+			if o, err := readOwner(path); err == nil {
+				holder = o.String()
+			}
+			logging.Infof("Waiting for %s to finish extracting into %s", holder, dir)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for the lease on %s: %w", dir, ctx.Err())
+		case <-time.After(opts.Poll):
+		}
+	}
+}
+
+// breakStale removes the stale lease at path. It is renamed first, so of
+// several waiters breaking it only one succeeds, and put back if its holder
+// touched it in the meantime.
+func breakStale(path string, mtime time.Time) {
+	owner := "another node"
+	if o, err := readOwner(path); err == nil {
+		owner = o.String()
+	}
+	stale := fmt.Sprintf("%s.stale.%d", path, os.Getpid())
+	if err := os.Rename(path, stale); err != nil {
+		return
+	}
+	if st, err := os.Stat(stale); err == nil && !st.ModTime().Equal(mtime) {
+		if err := os.Link(stale, path); err == nil {
+			os.Remove(stale)
+			return
+		}
+	}
+	os.Remove(stale)
+	diag.Warnf(diag.LeaseTakenOver, "Took over the lease of %s, which stopped updating it", owner)
+}
+
+func readOwner(path string) (Owner, error) {
+	var o Owner
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &o)
+	}
+	return o, err
+}
+
+func newLease(path, token string, staleAfter time.Duration) *Lease {
+	l := &Lease{path: path, token: token, stop: make(chan struct{})}
+	l.done.Add(1)
+	go l.heartbeat(staleAfter / 4)
+	return l
+}
+
+// heartbeat touches the lease so waiters see it is alive, as long as it is
+// still this lease.
+func (l *Lease) heartbeat(every time.Duration) {
+	defer l.done.Done()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			err := l.check(l.path)
+			if err == nil {
+				now := time.Now()
+				err = os.Chtimes(l.path, now, now)
+			}
+			if err != nil {
+				l.setLost(err)
+				diag.Warn(diag.LeaseLost, l.Lost())
+				return
+			}
+		}
+	}
+}
+
+// errTakenOver is returned when the lease file is another node's.
+var errTakenOver = errors.New("taken over by another node")
+
+// check returns an error unless the lease file at path is this lease.
+func (l *Lease) check(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var lf leaseFile
+	if json.Unmarshal(data, &lf) != nil || lf.Token != l.token {
+		if lf.Host != "" {
+			return fmt.Errorf("%w: now held by %s", errTakenOver, lf.Owner)
+		}
+		return errTakenOver
+	}
+	return nil
+}
+
+func (l *Lease) setLost(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lost == nil {
+		l.lost = fmt.Errorf("lost the lease %s: %w", l.path, err)
+	}
+}
+
+// Lost returns an error if the lease was taken over or removed while held.
+func (l *Lease) Lost() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lost
+}
+
+// Release stops the heartbeat and removes the lease.
+func (l *Lease) Release() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+	close(l.stop)
+	l.done.Wait()
+	if err := l.Lost(); err != nil {
+		return err
+	}
+	// Move the lease aside before checking it is this one, so that a node
+	// taking it over in between is not removed with it; put back a lease
+	// that turns out to be another node's.
+	released := fmt.Sprintf("%s.release.%d", l.path, os.Getpid())
+	if err := os.Rename(l.path, released); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			l.setLost(err)
+			return l.Lost()
+		}
+		return fmt.Errorf("failed to release lease %s: %w", l.path, err)
+	}
+	if err := l.check(released); err != nil {
+		if lerr := os.Link(released, l.path); lerr == nil {
+			os.Remove(released)
+		}
+		l.setLost(err)
+		return l.Lost()
+	}
+	if err := os.Remove(released); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to release lease %s: %w", l.path, err)
+	}
+	return nil
+}
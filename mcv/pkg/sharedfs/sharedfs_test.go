@@ -0,0 +1,111 @@
+package sharedfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireWaitsForHolder(t *testing.T) {
+	dir := t.TempDir()
+	lease, err := Acquire(context.Background(), dir, Options{})
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, LockFileName))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = Acquire(ctx, dir, Options{Poll: 10 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan error)
+	go func() {
+		l, err := Acquire(context.Background(), dir, Options{Poll: 10 * time.Millisecond})
+		if err == nil {
+			err = l.Release()
+		}
+		acquired <- err
+	}()
+	assert.NoError(t, lease.Release())
+	assert.NoError(t, <-acquired)
+	assert.NoFileExists(t, filepath.Join(dir, LockFileName))
+}
+
+func TestAcquireTakesOverStaleLease(t *testing.T) {
+	dir := t.TempDir()
+	// A lease left behind by a node that died.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, LockFileName), []byte(`{"host":"gone","pid":1}`), 0644))
+
+	lease, err := Acquire(context.Background(), dir, Options{StaleAfter: 50 * time.Millisecond, Poll: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	owner, err := readOwner(filepath.Join(dir, LockFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), owner.PID)
+	assert.NoError(t, lease.Release())
+}
+
+func TestLeaseLost(t *testing.T) {
+	dir := t.TempDir()
+	lease, err := Acquire(context.Background(), dir, Options{StaleAfter: 40 * time.Millisecond})
+	assert.NoError(t, err)
+	assert.NoError(t, os.Remove(filepath.Join(dir, LockFileName)))
+	assert.Eventually(t, func() bool { return lease.Lost() != nil }, time.Second, 5*time.Millisecond)
+	assert.Error(t, lease.Release())
+}
+
+func TestMarker(t *testing.T) {
+	dir := t.TempDir()
+	m, err := ReadMarker(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	assert.NoError(t, WriteMarker(dir, "sha256:abc", 3))
+	m, err = ReadMarker(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:abc", m.Digest)
+	assert.Equal(t, 3, m.Files)
+	assert.Equal(t, os.Getpid(), m.PID)
+
+	assert.NoError(t, RemoveMarker(dir))
+	assert.NoError(t, RemoveMarker(dir))
+	m, err = ReadMarker(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestLeaseTakenOver(t *testing.T) {
+	dir := t.TempDir()
+	lease, err := Acquire(context.Background(), dir, Options{StaleAfter: 40 * time.Millisecond})
+	assert.NoError(t, err)
+	// Another node takes the lease over, e.g. while this one was paused.
+	path := filepath.Join(dir, LockFileName)
+	other := []byte(`{"host":"other","pid":2,"token":"theirs"}`)
+	assert.NoError(t, os.WriteFile(path, other, 0644))
+
+	assert.Eventually(t, func() bool { return lease.Lost() != nil }, time.Second, 5*time.Millisecond)
+	assert.ErrorContains(t, lease.Lost(), "other (pid 2)")
+	assert.Error(t, lease.Release())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, other, data)
+}
+
+func TestReleaseKeepsTakenOverLease(t *testing.T) {
+	dir := t.TempDir()
+	lease, err := Acquire(context.Background(), dir, Options{StaleAfter: time.Hour})
+	assert.NoError(t, err)
+	path := filepath.Join(dir, LockFileName)
+	other := []byte(`{"host":"other","pid":2,"token":"theirs"}`)
+	assert.NoError(t, os.WriteFile(path, other, 0644))
+
+	assert.ErrorContains(t, lease.Release(), "taken over")
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, other, data)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
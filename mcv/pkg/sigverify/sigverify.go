@@ -0,0 +1,99 @@
+// Package sigverify checks cache images against the containers signature
+// policy (policy.json), the same policy podman and CRI-O enforce, so an
+// image mcv extracts is held to the rules a container running it would be.
+// Policies can require GPG or sigstore (cosign) signatures; where sigstore
+// signatures are looked up is set in registries.d as for other tools.
+package sigverify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/redhat-et/MCU/mcv/pkg/hostfs"
+)
+
+// Verify checks the registry image imgRef, which should name a digest so
+// that what is verified is what is later pulled, against the policy in
+// policyPath, or the host's default policy when policyPath is empty.
+func Verify(ctx context.Context, imgRef, policyPath string) error {
+	ref, err := docker.ParseReference("//" + imgRef)
+	if err != nil {
+		return fmt.Errorf("failed to parse image name: %w", err)
+	}
+
+	sys := newSystemContext(policyPath)
+	pc, err := PolicyContext(sys)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = pc.Destroy() }()
+
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return fmt.Errorf("failed to read image %s: %w", imgRef, err)
+	}
+	defer src.Close()
+
+	if _, err := pc.IsRunningImageAllowed(ctx, image.UnparsedInstance(src, nil)); err != nil {
+		return fmt.Errorf("image %s rejected by signature policy: %w", imgRef, err)
+	}
+	return nil
+}
+
+// PolicyContext returns a context enforcing the signature policy sys
+// points to. Callers must Destroy it.
+func PolicyContext(sys *types.SystemContext) (*signature.PolicyContext, error) {
+	policy, err := signature.DefaultPolicy(sys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signature policy: %w", err)
+	}
+	pc, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature policy: %w", err)
+	}
+	return pc, nil
+}
+
+var newSystemContext = SystemContext
+
+var (
+	trustMu         sync.RWMutex
+	trustPolicy     string
+	trustRegistries string
+)
+
+// UseTrust makes the policy in policyPath, and the registries.d settings
+// in registriesDir, the default in place of the host's. The files are
+// read on every verification, so they can be replaced while mcv runs.
+func UseTrust(policyPath, registriesDir string) {
+	trustMu.Lock()
+	defer trustMu.Unlock()
+	trustPolicy, trustRegistries = policyPath, registriesDir
+}
+
+// SystemContext returns the containers settings to use: the policy in
+// policyPath, if set, then the one set with UseTrust, and otherwise the
+// host's policy.json and registries.d, also when mcv runs in a container.
+func SystemContext(policyPath string) *types.SystemContext {
+	sys := &types.SystemContext{SignaturePolicyPath: policyPath}
+	if policyPath == "" {
+		trustMu.RLock()
+		sys.SignaturePolicyPath = trustPolicy
+		if trustRegistries != "" {
+			if _, err := os.Stat(trustRegistries); err == nil {
+				sys.RegistriesDirPath = trustRegistries
+			}
+		}
+		trustMu.RUnlock()
+	}
+	if root := hostfs.Root(); root != "/" {
+		sys.RootForImplicitAbsolutePaths = root
+	}
+	return sys
+}
@@ -0,0 +1,59 @@
+package sigverify
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	img, err := random.Image(1024, 1)
+	assert.NoError(t, err)
+	ref, err := name.ParseReference(host+"/cache:v1", name.Insecure)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	assert.NoError(t, err)
+	pinned := host + "/cache@" + digest.String()
+
+	orig := newSystemContext
+	defer func() { newSystemContext = orig }()
+	dir := t.TempDir()
+	registriesConf := filepath.Join(dir, "registries.conf")
+	assert.NoError(t, os.WriteFile(registriesConf, nil, 0644))
+	newSystemContext = func(policyPath string) *types.SystemContext {
+		sys := SystemContext(policyPath)
+		sys.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+		sys.SystemRegistriesConfPath = registriesConf
+		sys.RegistriesDirPath = dir
+		return sys
+	}
+
+	accept := filepath.Join(dir, "accept.json")
+	assert.NoError(t, os.WriteFile(accept, []byte(`{"default":[{"type":"insecureAcceptAnything"}]}`), 0644))
+	assert.NoError(t, Verify(context.Background(), pinned, accept))
+
+	// An unsigned image fails a policy requiring signatures for its registry.
+	signed := filepath.Join(dir, "signed.json")
+	assert.NoError(t, os.WriteFile(signed, []byte(`{"default":[{"type":"insecureAcceptAnything"}],
+"transports":{"docker":{"`+host+`":[{"type":"signedBy","keyType":"GPGKeys","keyPath":"`+filepath.Join(dir, "key.gpg")+`"}]}}}`), 0644))
+	assert.ErrorContains(t, Verify(context.Background(), pinned, signed), "rejected by signature policy")
+
+	assert.ErrorContains(t, Verify(context.Background(), pinned, filepath.Join(dir, "missing.json")), "failed to load signature policy")
+}
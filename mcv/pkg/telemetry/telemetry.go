@@ -0,0 +1,126 @@
+// Package telemetry reports anonymized extraction statistics to an
+// endpoint the operator configures, so platform teams can see how well
+// their caches work across a fleet. Nothing is sent unless an endpoint is
+// set. Reports carry no host names, image names, digests, paths, GPU UUIDs
+// or error messages.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/redhat-et/MCU/mcv/pkg/build"
+	logging "github.com/sirupsen/logrus"
+)
+
+// Schema is the version of the Extraction payload.
+const Schema = 1
+
+// sendTimeout bounds how long a report may delay the command.
+const sendTimeout = 5 * time.Second
+
+// Extraction is the report of one cache extraction.
+type Extraction struct {
+	Schema     int     `json:"schema"`
+	MCVVersion string  `json:"mcvVersion"`
+	Success    bool    `json:"success"`
+	Seconds    float64 `json:"seconds"`
+	// CacheBytes and CacheFiles are the size of the cache directory after
+	// the extraction, 0 when the cache did not go to one directory on this
+	// host.
+	CacheBytes int64 `json:"cacheBytes"`
+	CacheFiles int   `json:"cacheFiles"`
+	// GPUs counts the host's GPUs by backend and architecture, e.g.
+	// "cuda:80".
+	GPUs map[string]int `json:"gpus,omitempty"`
+}
+
+// NewExtraction returns the report of an extraction into cacheDir, which
+// may be empty, that took d and ran on gpus.
+func NewExtraction(success bool, d time.Duration, cacheDir string, gpus []devices.TritonGPUInfo) Extraction {
+	e := Extraction{
+		Schema:     Schema,
+		MCVVersion: build.Version,
+		Success:    success,
+		Seconds:    d.Round(time.Millisecond).Seconds(),
+		GPUs:       GPUArchs(gpus),
+	}
+	if cacheDir != "" {
+		e.CacheBytes, e.CacheFiles = dirStats(cacheDir)
+	}
+	return e
+}
+
+// GPUArchs counts gpus by backend and architecture.
+func GPUArchs(gpus []devices.TritonGPUInfo) map[string]int {
+	if len(gpus) == 0 {
+		return nil
+	}
+	archs := make(map[string]int)
+	for _, g := range gpus {
+		archs[fmt.Sprintf("%s:%s", g.Backend, g.Arch)]++
+	}
+	return archs
+}
+
+// Send posts e as JSON to endpoint.
+func Send(ctx context.Context, endpoint string, e Extraction) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid telemetry endpoint %q: %w", endpoint, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mcv/"+build.Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("telemetry endpoint %s returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Report sends e to endpoint if one is set. Failures are only logged, so
+// telemetry never fails a command.
+func Report(endpoint string, e Extraction) {
+	if endpoint == "" {
+		return
+	}
+	if logging.IsLevelEnabled(logging.DebugLevel) {
+		body, _ := json.Marshal(e)
+		logging.Debugf("Sending telemetry to %s: %s", endpoint, body)
+	}
+	if err := Send(context.Background(), endpoint, e); err != nil {
+		logging.Debugf("Failed to send telemetry: %v", err)
+	}
+}
+
+// dirStats returns the bytes and number of regular files under dir.
+func dirStats(dir string) (size int64, files int) {
+	_ = filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files
+}
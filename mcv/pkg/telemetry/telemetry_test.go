@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/accelerator/devices"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExtraction(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "abc"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "abc", "kernel.json"), []byte("{}"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "abc", "kernel.cubin"), make([]byte, 100), 0644))
+
+	e := NewExtraction(true, 1500*time.Millisecond, dir, []devices.TritonGPUInfo{
+		{Backend: "cuda", Arch: "80", UUID: "GPU-1"},
+		{Backend: "cuda", Arch: "80", UUID: "GPU-2"},
+		{Backend: "hip", Arch: "gfx90a", UUID: "GPU-3"},
+	})
+	assert.Equal(t, Schema, e.Schema)
+	assert.True(t, e.Success)
+	assert.Equal(t, 1.5, e.Seconds)
+	assert.Equal(t, int64(102), e.CacheBytes)
+	assert.Equal(t, 2, e.CacheFiles)
+	assert.Equal(t, map[string]int{"cuda:80": 2, "hip:gfx90a": 1}, e.GPUs)
+
+	e = NewExtraction(false, time.Second, "", nil)
+	assert.Zero(t, e.CacheBytes)
+	assert.Nil(t, e.GPUs)
+}
+
+func TestSend(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	e := NewExtraction(true, time.Second, "", []devices.TritonGPUInfo{{Backend: "cuda", Arch: "90", UUID: "GPU-secret", Name: "H100"}})
+	assert.NoError(t, Send(context.Background(), srv.URL, e))
+	assert.Equal(t, map[string]any{"cuda:90": float64(1)}, got["gpus"])
+	body, _ := json.Marshal(got)
+	assert.NotContains(t, string(body), "GPU-secret")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.ErrorContains(t, Send(context.Background(), failing.URL, e), "500")
+}
@@ -0,0 +1,227 @@
+// Package trust keeps a local copy of the signature trust material, the
+// containers policy.json, registries.d files and the keys the policy
+// references, loaded from Kubernetes ConfigMaps and Secrets. Rotating keys
+// or changing the policy then only takes an update of those objects rather
+// than of every node's files.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containers/image/v5/signature"
+)
+
+// Entries of the ConfigMaps and Secrets trust material is loaded from.
+// Any other entry is a key file the policy may reference by its name.
+const (
+	PolicyKey        = "policy.json"
+	RegistriesSuffix = ".yaml" // registries.d files
+)
+
+// Names of the files in a version of the local copy.
+const (
+	currentLink   = "current"
+	policyFile    = "policy.json"
+	registriesDir = "registries.d"
+	keysDir       = "keys"
+)
+
+// Material is one version of the trust material.
+type Material struct {
+	Policy     []byte            // policy.json
+	Registries map[string][]byte // registries.d files, by name
+	Keys       map[string][]byte // Key files, by name
+}
+
+// Add adds the entries of a ConfigMap or Secret to m.
+func (m *Material) Add(data map[string][]byte) error {
+	if m.Registries == nil {
+		m.Registries = map[string][]byte{}
+	}
+	if m.Keys == nil {
+		m.Keys = map[string][]byte{}
+	}
+	for name, value := range data {
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("invalid trust entry name %q", name)
+		}
+		switch {
+		case name == PolicyKey:
+			if m.Policy != nil {
+				return fmt.Errorf("%s is given more than once", PolicyKey)
+			}
+			m.Policy = value
+		case strings.HasSuffix(name, RegistriesSuffix):
+			m.Registries[name] = value
+		default:
+			m.Keys[name] = value
+		}
+	}
+	return nil
+}
+
+// digest returns a digest of the content of m, naming its version.
+func (m *Material) digest() string {
+	h := sha256.New()
+	write := func(kind string, files map[string][]byte) {
+		names := make([]string, 0, len(files))
+		for n := range files {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(h, "%s/%s %d\n", kind, n, len(files[n]))
+			h.Write(files[n])
+		}
+	}
+	write("policy", map[string][]byte{PolicyKey: m.Policy})
+	write("registries", m.Registries)
+	write("keys", m.Keys)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Dir is the local copy of the trust material. Each version is written to
+// its own directory and the current one is switched to atomically, so a
+// verification never reads a half-written policy.
+type Dir struct {
+	Root string
+}
+
+// PolicyPath returns the path of the current policy.json.
+func (d Dir) PolicyPath() string {
+	return filepath.Join(d.Root, currentLink, policyFile)
+}
+
+// RegistriesDir returns the path of the current registries.d directory.
+func (d Dir) RegistriesDir() string {
+	return filepath.Join(d.Root, currentLink, registriesDir)
+}
+
+// Write makes m the current version and reports whether it differs from
+// the previous one. Relative key paths in the policy, such as "keyPath":
+// "cosign.pub", are resolved to the key files of m. A policy that does not
+// parse is rejected and the current version kept.
+func (d Dir) Write(m *Material) (bool, error) {
+	if m.Policy == nil {
+		return false, fmt.Errorf("no %s in the trust material", PolicyKey)
+	}
+	policy, err := resolveKeyPaths(m.Policy, filepath.Join(d.Root, currentLink, keysDir))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", PolicyKey, err)
+	}
+	if _, err := signature.NewPolicyFromBytes(policy); err != nil {
+		return false, fmt.Errorf("invalid %s: %w", PolicyKey, err)
+	}
+
+	if err := os.MkdirAll(d.Root, 0700); err != nil {
+		return false, err
+	}
+	version := "v-" + m.digest()
+	if current, err := os.Readlink(filepath.Join(d.Root, currentLink)); err == nil && current == version {
+		return false, nil
+	}
+	if _, err := os.Stat(filepath.Join(d.Root, version)); os.IsNotExist(err) {
+		if err := writeVersion(d.Root, version, policy, m); err != nil {
+			return false, err
+		}
+	}
+
+	tmpLink := filepath.Join(d.Root, ".current-"+version)
+	_ = os.Remove(tmpLink)
+	if err := os.Symlink(version, tmpLink); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpLink, filepath.Join(d.Root, currentLink)); err != nil {
+		os.Remove(tmpLink)
+		return false, fmt.Errorf("failed to switch to the new trust material: %w", err)
+	}
+
+	// Verifications read the keys through the current link, so the other
+	// versions are no longer used.
+	entries, _ := os.ReadDir(d.Root)
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), "v-") && e.Name() != version {
+			os.RemoveAll(filepath.Join(d.Root, e.Name()))
+		}
+	}
+	return true, nil
+}
+
+func writeVersion(root, version string, policy []byte, m *Material) error {
+	tmp, err := os.MkdirTemp(root, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string][]byte{policyFile: policy}
+	for n, v := range m.Registries {
+		files[filepath.Join(registriesDir, n)] = v
+	}
+	for n, v := range m.Keys {
+		files[filepath.Join(keysDir, n)] = v
+	}
+	for _, dir := range []string{registriesDir, keysDir} {
+		if err := os.Mkdir(filepath.Join(tmp, dir), 0700); err != nil {
+			return err
+		}
+	}
+	for n, v := range files {
+		if err := os.WriteFile(filepath.Join(tmp, n), v, 0600); err != nil {
+			return fmt.Errorf("failed to write trust file %s: %w", n, err)
+		}
+	}
+	if err := os.Chmod(tmp, 0700); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(root, version))
+}
+
+// resolveKeyPaths joins the relative paths of the policy's "...Path" and
+// "...Paths" fields, such as keyPath, keyPaths or rekorPublicKeyPath, to
+// keys.
+func resolveKeyPaths(policy []byte, keys string) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(policy, &doc); err != nil {
+		return nil, err
+	}
+	resolve := func(v any) any {
+		if s, ok := v.(string); ok && s != "" && !filepath.IsAbs(s) {
+			return filepath.Join(keys, s)
+		}
+		return v
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				switch {
+				case strings.HasSuffix(k, "Path"):
+					t[k] = resolve(child)
+				case strings.HasSuffix(k, "Paths"):
+					if list, ok := child.([]any); ok {
+						for i := range list {
+							list[i] = resolve(list[i])
+						}
+					}
+				default:
+					walk(child)
+				}
+			}
+		case []any:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,74 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const signedPolicy = `{"default": [{"type": "reject"}], "transports": {"docker": {"quay.io/example": [{"type": "sigstoreSigned", "keyPath": "cosign.pub"}]}}}`
+
+func TestMaterialAdd(t *testing.T) {
+	var m Material
+	assert.NoError(t, m.Add(map[string][]byte{PolicyKey: []byte("{}"), "quay.yaml": []byte("docker: {}"), "cosign.pub": []byte("key")}))
+	assert.Equal(t, []byte("{}"), m.Policy)
+	assert.Equal(t, map[string][]byte{"quay.yaml": []byte("docker: {}")}, m.Registries)
+	assert.Equal(t, map[string][]byte{"cosign.pub": []byte("key")}, m.Keys)
+
+	assert.ErrorContains(t, m.Add(map[string][]byte{PolicyKey: []byte("{}")}), "more than once")
+	assert.ErrorContains(t, m.Add(map[string][]byte{"../key": nil}), "invalid trust entry name")
+}
+
+func TestDirWrite(t *testing.T) {
+	d := Dir{Root: t.TempDir()}
+	var m Material
+	assert.NoError(t, m.Add(map[string][]byte{PolicyKey: []byte(signedPolicy), "cosign.pub": []byte("v1")}))
+	changed, err := d.Write(&m)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	policy, err := os.ReadFile(d.PolicyPath())
+	assert.NoError(t, err)
+	keyPath := filepath.Join(d.Root, "current", "keys", "cosign.pub")
+	assert.Contains(t, string(policy), `"keyPath": "`+keyPath+`"`)
+	key, err := os.ReadFile(keyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(key))
+
+	changed, err = d.Write(&m)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// A rotated key replaces the previous version.
+	m.Keys["cosign.pub"] = []byte("v2")
+	changed, err = d.Write(&m)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	key, _ = os.ReadFile(keyPath)
+	assert.Equal(t, "v2", string(key))
+	versions, _ := filepath.Glob(filepath.Join(d.Root, "v-*"))
+	assert.Len(t, versions, 1)
+
+	// An invalid policy leaves the current version in place.
+	_, err = d.Write(&Material{Policy: []byte(`{"default": [{"type": "unknown"}]}`)})
+	assert.ErrorContains(t, err, "invalid policy.json")
+	_, err = d.Write(&Material{})
+	assert.ErrorContains(t, err, "no policy.json")
+	key, _ = os.ReadFile(keyPath)
+	assert.Equal(t, "v2", string(key))
+}
+
+func TestParseRef(t *testing.T) {
+	ref, err := ParseRef("trust", "mcv")
+	assert.NoError(t, err)
+	assert.Equal(t, Ref{Namespace: "mcv", Name: "trust"}, ref)
+	ref, err = ParseRef("security/trust", "mcv")
+	assert.NoError(t, err)
+	assert.Equal(t, "security/trust", ref.String())
+	for _, s := range []string{"", "a/b/c", "ns/"} {
+		_, err := ParseRef(s, "mcv")
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,157 @@
+package trust
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Ref names a ConfigMap or Secret.
+type Ref struct {
+	Namespace string
+	Name      string
+}
+
+// ParseRef parses [namespace/]name, in namespace unless it names one.
+func ParseRef(s, namespace string) (Ref, error) {
+	name := s
+	if ns, n, ok := strings.Cut(s, "/"); ok {
+		namespace, name = ns, n
+	}
+	if name == "" || namespace == "" || strings.Contains(name, "/") {
+		return Ref{}, fmt.Errorf("invalid object reference %q: expect [namespace/]name", s)
+	}
+	return Ref{Namespace: namespace, Name: name}, nil
+}
+
+func (r Ref) String() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// Syncer copies the trust material of a ConfigMap, a Secret or both to a
+// local Dir. Either may be left unset; together they must hold a policy.
+type Syncer struct {
+	Kube      kubernetes.Interface
+	ConfigMap *Ref
+	Secret    *Ref
+	Dir       Dir
+}
+
+// Sync reads the objects and makes their content the current version of
+// the local copy, reporting whether it changed.
+func (s *Syncer) Sync(ctx context.Context) (bool, error) {
+	var m Material
+	if s.ConfigMap != nil {
+		cm, err := s.Kube.CoreV1().ConfigMaps(s.ConfigMap.Namespace).Get(ctx, s.ConfigMap.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to read trust ConfigMap %s: %w", s.ConfigMap, err)
+		}
+		if err := m.Add(configMapData(cm)); err != nil {
+			return false, fmt.Errorf("ConfigMap %s: %w", s.ConfigMap, err)
+		}
+	}
+	if s.Secret != nil {
+		secret, err := s.Kube.CoreV1().Secrets(s.Secret.Namespace).Get(ctx, s.Secret.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to read trust Secret %s: %w", s.Secret, err)
+		}
+		if err := m.Add(secret.Data); err != nil {
+			return false, fmt.Errorf("secret %s: %w", s.Secret, err)
+		}
+	}
+	return s.Dir.Write(&m)
+}
+
+func (s *Syncer) String() string {
+	var refs []string
+	if s.ConfigMap != nil {
+		refs = append(refs, "ConfigMap "+s.ConfigMap.String())
+	}
+	if s.Secret != nil {
+		refs = append(refs, "Secret "+s.Secret.String())
+	}
+	return strings.Join(refs, " and ")
+}
+
+func configMapData(cm *corev1.ConfigMap) map[string][]byte {
+	data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		data[k] = v
+	}
+	return data
+}
+
+// Run watches the objects and syncs the local copy again whenever one of
+// them changes, until ctx is done. A change that cannot be synced, such
+// as an invalid policy, is logged and the last good version kept.
+func (s *Syncer) Run(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { notify() },
+		UpdateFunc: func(any, any) { notify() },
+		DeleteFunc: func(any) { notify() },
+	}
+
+	watch := func(ref *Ref, informer func(informers.SharedInformerFactory) cache.SharedIndexInformer) {
+		if ref == nil {
+			return
+		}
+		factory := informers.NewSharedInformerFactoryWithOptions(s.Kube, 0,
+			informers.WithNamespace(ref.Namespace),
+			informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+				o.FieldSelector = fields.OneTermEqualSelector("metadata.name", ref.Name).String()
+			}))
+		if _, err := informer(factory).AddEventHandler(handler); err != nil {
+			diag.Warnf(diag.TrustNotWatched, "Not watching %s for trust changes: %v", ref, err)
+			return
+		}
+		factory.Start(ctx.Done())
+	}
+	watch(s.ConfigMap, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().ConfigMaps().Informer()
+	})
+	watch(s.Secret, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Secrets().Informer()
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			// Let the updates of a rotation touching both objects land.
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			changed, err := s.Sync(ctx)
+			if err != nil {
+				logging.Errorf("Keeping the current trust material: %v", err)
+				continue
+			}
+			if changed {
+				logging.Infof("Reloaded the trust material from %s", s)
+			}
+		}
+	}
+}
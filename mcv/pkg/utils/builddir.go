@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// buildLockName is locked shared by every mcv process using the build dir,
+// so cleanup can tell whether another one is. It is never removed, since a
+// new file would not be locked by the processes holding the old one.
+const buildLockName = ".lock"
+
+// ErrBuildDirInUse is returned when another mcv process is using the build
+// dir.
+var ErrBuildDirInUse = errors.New("in use by another mcv process")
+
+var (
+	buildLockMu sync.Mutex
+	buildLock   *os.File
+)
+
+// TempEntry is a file or directory of mcv's temporary state.
+type TempEntry struct {
+	Path string
+	Size int64
+}
+
+// LockBuildDir marks constants.MCVBuildDir as used by this process until it
+// exits. The first time, if no other mcv process is using it, it removes
+// what earlier runs that failed or were killed left there.
+func LockBuildDir() error {
+	buildLockMu.Lock()
+	defer buildLockMu.Unlock()
+	if buildLock != nil {
+		return nil
+	}
+	f, err := lockBuildDir(constants.MCVBuildDir)
+	if err != nil {
+		return err
+	}
+	buildLock = f
+	return nil
+}
+
+func lockBuildDir(dir string) (*os.File, error) {
+	f, err := openBuildLock(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err == nil {
+		for _, e := range buildDirEntries(dir) {
+			logging.Debugf("Removing %s left by an earlier run", e.Path)
+			if err := os.RemoveAll(e.Path); err != nil {
+				diag.Warnf(diag.CleanupFailed, "Failed to remove %s: %v", e.Path, err)
+			}
+		}
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", dir, err)
+	}
+	return f, nil
+}
+
+func openBuildLock(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, buildLockName), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the lock of %s: %w", dir, err)
+	}
+	return f, nil
+}
+
+// CleanBuildDir removes the content of constants.MCVBuildDir, moving it
+// to KeptTempDir with --keep-temp, unless another mcv process is using it.
+// With dryRun it only returns what it would remove.
+func CleanBuildDir(dryRun bool) ([]TempEntry, error) {
+	return cleanBuildDir(constants.MCVBuildDir, dryRun)
+}
+
+func cleanBuildDir(dir string, dryRun bool) ([]TempEntry, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	buildLockMu.Lock()
+	defer buildLockMu.Unlock()
+
+	// Another process's shared lock stops this process's own from being
+	// converted to an exclusive one.
+	f := buildLock
+	if f == nil || dir != constants.MCVBuildDir {
+		var err error
+		if f, err = openBuildLock(dir); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	} else {
+		defer func() {
+			if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err != nil {
+				diag.Warnf(diag.BuildDirLock, "Failed to lock %s: %v", dir, err)
+			}
+		}()
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%s is %w", dir, ErrBuildDirInUse)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", dir, err)
+	}
+
+	entries := buildDirEntries(dir)
+	if dryRun {
+		return entries, nil
+	}
+	var errs []error
+	for _, e := range entries {
+		if err := RemoveTemp(e.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return entries, errors.Join(errs...)
+}
+
+// buildDirEntries returns what is in the build dir besides its lock.
+func buildDirEntries(dir string) []TempEntry {
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var entries []TempEntry
+	for _, n := range names {
+		if n.Name() == buildLockName {
+			continue
+		}
+		path := filepath.Join(dir, n.Name())
+		entries = append(entries, TempEntry{Path: path, Size: DiskUsage(path)})
+	}
+	return entries
+}
+
+// CleanKeptTemp removes the directories --keep-temp kept in
+// constants.MCVDebugDir, except those of mcv processes still running. With
+// dryRun it only returns what it would remove.
+func CleanKeptTemp(dryRun bool) ([]TempEntry, error) {
+	var removed []TempEntry
+	var errs []error
+	for _, e := range keptRuns(constants.MCVDebugDir) {
+		if keptRunActive(e.Path) {
+			logging.Debugf("Leaving %s: its mcv process is still running", e.Path)
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(e.Path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		removed = append(removed, e)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// pruneKeptTemp removes the oldest directories kept in dir until they take
+// at most maxSize bytes in total. The directory of this process is kept.
+func pruneKeptTemp(dir, current string, maxSize int64) {
+	if maxSize <= 0 {
+		return
+	}
+	runs := keptRuns(dir)
+	var total int64
+	for _, r := range runs {
+		total += r.Size
+	}
+	for _, r := range runs {
+		if total <= maxSize {
+			return
+		}
+		if r.Path == current || keptRunActive(r.Path) {
+			continue
+		}
+		if err := os.RemoveAll(r.Path); err != nil {
+			diag.Warnf(diag.CleanupFailed, "Failed to remove %s: %v", r.Path, err)
+			continue
+		}
+		logging.Infof("Removed %s to keep %s under its size limit", r.Path, dir)
+		total -= r.Size
+	}
+}
+
+// keptRuns returns the directories kept in dir, oldest first.
+func keptRuns(dir string) []TempEntry {
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var runs []TempEntry
+	for _, n := range names {
+		if n.IsDir() {
+			path := filepath.Join(dir, n.Name())
+			runs = append(runs, TempEntry{Path: path, Size: DiskUsage(path)})
+		}
+	}
+	// Names start with a sortable timestamp.
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Path < runs[j].Path })
+	return runs
+}
+
+// keptRunActive reports whether the process a kept directory, named
+// <date>-<time>-<pid>, belongs to is still running.
+func keptRunActive(path string) bool {
+	name := filepath.Base(path)
+	pid, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	if err != nil || pid <= 0 {
+		return false
+	}
+	if pid == os.Getpid() {
+		return true
+	}
+	err = unix.Kill(pid, 0)
+	return err == nil || errors.Is(err, unix.EPERM)
+}
+
+// DiskUsage returns the total size of the files under path.
+func DiskUsage(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
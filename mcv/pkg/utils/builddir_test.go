@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestLockBuildDirRemovesLeftovers(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "native", "cache"), 0755))
+
+	f, err := lockBuildDir(dir)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoDirExists(t, filepath.Join(dir, "native"))
+	assert.FileExists(t, filepath.Join(dir, buildLockName))
+
+	// A second user finds the first one's files in use.
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "fetched"), 0755))
+	g, err := lockBuildDir(dir)
+	assert.NoError(t, err)
+	defer g.Close()
+	assert.DirExists(t, filepath.Join(dir, "fetched"))
+}
+
+func TestCleanBuildDirInUse(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "native"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "native", "layer"), make([]byte, 1024), 0644))
+
+	holder, err := openBuildLock(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, unix.Flock(int(holder.Fd()), unix.LOCK_SH))
+	_, err = cleanBuildDir(dir, false)
+	assert.ErrorIs(t, err, ErrBuildDirInUse)
+	assert.DirExists(t, filepath.Join(dir, "native"))
+	holder.Close()
+
+	entries, err := cleanBuildDir(dir, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []TempEntry{{Path: filepath.Join(dir, "native"), Size: 1024}}, entries)
+	assert.DirExists(t, filepath.Join(dir, "native"))
+
+	_, err = cleanBuildDir(dir, false)
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, "native"))
+	assert.FileExists(t, filepath.Join(dir, buildLockName))
+}
+
+func TestPruneKeptTemp(t *testing.T) {
+	dir := t.TempDir()
+	run := func(name string, size int) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(path, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(path, "f"), make([]byte, size), 0644))
+		return path
+	}
+	oldest := run("20260101-000000-999999999", 300)
+	older := run("20260102-000000-999999998", 300)
+	current := run(fmt.Sprintf("20260103-000000-%d", os.Getpid()), 300)
+
+	pruneKeptTemp(dir, current, 700)
+	assert.NoDirExists(t, oldest)
+	assert.DirExists(t, older)
+	assert.DirExists(t, current)
+
+	pruneKeptTemp(dir, current, 1)
+	assert.NoDirExists(t, older)
+	assert.DirExists(t, current)
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-et/MCU/mcv/pkg/config"
+	"github.com/redhat-et/MCU/mcv/pkg/constants"
+	"github.com/redhat-et/MCU/mcv/pkg/diag"
+	logging "github.com/sirupsen/logrus"
+)
+
+var (
+	keptDirOnce sync.Once
+	keptDir     string
+	keptDirErr  error
+)
+
+// KeptTempDir returns the timestamped directory under
+// constants.MCVDebugDir that temporary files are moved to with --keep-temp,
+// creating it, and logging where it is, on first use. Directories kept by
+// earlier runs are then removed, oldest first, to keep the total under
+// config.TempMaxSize.
+func KeptTempDir() (string, error) {
+	keptDirOnce.Do(func() {
+		name := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+		keptDir = filepath.Join(constants.MCVDebugDir, name)
+		if keptDirErr = os.MkdirAll(keptDir, 0755); keptDirErr == nil {
+			diag.Warnf(diag.KeepingTemp, "Keeping temporary files for debugging in %s", keptDir)
+			pruneKeptTemp(constants.MCVDebugDir, keptDir, config.TempMaxSize())
+		}
+	})
+	return keptDir, keptDirErr
+}
+
+// RemoveTemp removes the temporary file or directory path or, with
+// --keep-temp, moves it to KeptTempDir. Paths under constants.MCVBuildDir
+// keep their place in it, under "build".
+func RemoveTemp(path string) error {
+	if !config.IsKeepTempEnabled() {
+		return os.RemoveAll(path)
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return nil
+	}
+	dir, err := KeptTempDir()
+	if err != nil {
+		diag.Warnf(diag.TempNotKept, "Leaving %s in place: %v", path, err)
+		return nil
+	}
+
+	name := filepath.Base(path)
+	if rel, err := filepath.Rel(constants.MCVBuildDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = filepath.Join("build", rel)
+	}
+	dst := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = fmt.Sprintf("%s-%d", filepath.Join(dir, name), i)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		diag.Warnf(diag.TempNotKept, "Leaving %s in place: %v", path, err)
+		return nil
+	}
+	// Renaming fails across filesystems, where copying could take long, so
+	// the files are then left where they are.
+	if err := os.Rename(path, dst); err != nil {
+		diag.Warnf(diag.TempNotKept, "Leaving %s in place: %v", path, err)
+		return nil
+	}
+	logging.Debugf("Kept %s as %s", path, dst)
+	return nil
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -31,12 +32,21 @@ func HasApp(app string) bool {
 	return err == nil
 }
 
-// CleanupMCVDirs removes the temporary MCV directory using os.RemoveAll.
+// CleanupMCVDirs removes the temporary MCV directory, or keeps it with
+// --keep-temp. The build dir is left alone while another mcv process is
+// using it; the last one to finish cleans it up.
 func CleanupMCVDirs(ctx context.Context, path string) error {
-	if path == "" {
-		path = constants.MCVBuildDir
+	if path == "" || path == constants.MCVBuildDir {
+		if _, err := CleanBuildDir(false); errors.Is(err, ErrBuildDirInUse) {
+			logging.Debugf("Leaving %s: %v", constants.MCVBuildDir, err)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to clean %s: %w", constants.MCVBuildDir, err)
+		}
+		logging.Debugf("Directory %s successfully cleaned.", constants.MCVBuildDir)
+		return nil
 	}
-	if err := os.RemoveAll(path); err != nil {
+	if err := RemoveTemp(path); err != nil {
 		return fmt.Errorf("failed to delete %s: %w", path, err)
 	}
 	logging.Debugf("Directory %s successfully deleted.", path)
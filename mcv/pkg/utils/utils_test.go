@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/redhat-et/MCU/mcv/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,3 +83,25 @@ func TestCleanupMCVDirs(t *testing.T) {
 	_, statErr := os.Stat(testDir)
 	assert.True(t, os.IsNotExist(statErr))
 }
+
+func TestRemoveTemp(t *testing.T) {
+	_, err := config.Initialize(t.TempDir())
+	assert.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "mcv-chunks-1")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, RemoveTemp(dir))
+	assert.NoDirExists(t, dir)
+
+	config.SetKeepTemp(true)
+	defer config.SetKeepTemp(false)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "chunk"), []byte("c"), 0644))
+	assert.NoError(t, RemoveTemp(dir))
+	assert.NoDirExists(t, dir)
+
+	kept, err := KeptTempDir()
+	assert.NoError(t, err)
+	defer os.RemoveAll(kept)
+	assert.FileExists(t, filepath.Join(kept, "mcv-chunks-1", "chunk"))
+}